@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-mongodb-test/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestAPIKeyOr(t *testing.T) {
+	setTestSecret(t)
+	SetAPIKeys([]APIKey{
+		{Name: "reporting", Scope: APIKeyScopeRead, Hash: HashAPIKey("read-key")},
+		{Name: "sync", Scope: APIKeyScopeWrite, Hash: HashAPIKey("write-key")},
+	})
+	defer SetAPIKeys(nil)
+
+	tests := []struct {
+		name           string
+		method         string
+		apiKey         string
+		bearer         string
+		expectedStatus int
+		expectedActor  string
+	}{
+		{"Read key reads", http.MethodGet, "read-key", "", http.StatusOK, "api_key:reporting"},
+		{"Read key writes", http.MethodPost, "read-key", "", http.StatusForbidden, ""},
+		{"Write key writes", http.MethodPost, "write-key", "", http.StatusOK, "api_key:sync"},
+		{"Unknown key", http.MethodGet, "guess", "", http.StatusUnauthorized, ""},
+		// A key is checked on its own, so an invalid one is not rescued by a valid token
+		{"Unknown key with a token", http.MethodGet, "guess", "Bearer " + newTestToken(t, models.RoleUser, models.RoleAdmin), http.StatusUnauthorized, ""},
+		{"Admin token", http.MethodPost, "", "Bearer " + newTestToken(t, models.RoleUser, models.RoleAdmin), http.StatusOK, "testuser"},
+		{"User token", http.MethodGet, "", "Bearer " + newTestToken(t, models.RoleUser), http.StatusForbidden, ""},
+		{"Neither", http.MethodGet, "", "", http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.Match([]string{http.MethodGet, http.MethodPost}, "/", func(c echo.Context) error {
+				claims, ok := ClaimsFrom(c.Request().Context())
+				if !ok || claims.UserID != tt.expectedActor {
+					t.Errorf("Expected claims for %s, got %+v", tt.expectedActor, claims)
+				}
+				return c.NoContent(http.StatusOK)
+			}, APIKeyOr(Authenticate(), RequireRole(models.RoleAdmin)))
+
+			req := httptest.NewRequest(tt.method, "/", nil)
+			if tt.apiKey != "" {
+				req.Header.Set(HeaderAPIKey, tt.apiKey)
+			}
+			if tt.bearer != "" {
+				req.Header.Set(echo.HeaderAuthorization, tt.bearer)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestAPIKeyOr_NoKeysConfigured(t *testing.T) {
+	SetAPIKeys(nil)
+
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, APIKeyOr(Authenticate()))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(HeaderAPIKey, "anything")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"go-mongodb-test/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenTTL is how long an issued access token stays valid
+const TokenTTL = 24 * time.Hour
+
+var ErrMissingSecret = errors.New("JWT_SECRET is not set")
+
+// Claims is the payload carried by access tokens
+type Claims struct {
+	ID     string   `json:"id"`
+	UserID string   `json:"user_id"`
+	Roles  []string `json:"roles"`
+	// Tenant is the tenant the token was issued by; a token is only accepted for it
+	Tenant string `json:"tenant,omitempty"`
+	// SessionID is the login session whose refresh tokens the token was issued with
+	SessionID string `json:"sid,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// configuredSecret is set once at startup by SetSecret
+var configuredSecret []byte
+
+// SetSecret sets the signing secret, JWT_SECRET in the configuration
+func SetSecret(secret string) {
+	configuredSecret = []byte(secret)
+}
+
+// getSecret returns the secret passed to SetSecret
+func getSecret() ([]byte, error) {
+	if len(configuredSecret) == 0 {
+		return nil, ErrMissingSecret
+	}
+	return configuredSecret, nil
+}
+
+// GenerateToken issues a signed HS256 token for the given user
+func GenerateToken(user *models.User) (string, error) {
+	return GenerateTenantToken(user, "")
+}
+
+// GenerateTenantToken issues a token for a user of tenant, which Authenticate then
+// only accepts on requests for the same tenant
+func GenerateTenantToken(user *models.User, tenant string) (string, error) {
+	return GenerateSessionToken(user, tenant, "")
+}
+
+// GenerateSessionToken issues a tenant token that also names the session it
+// belongs to, so that the session can tell itself apart from the user's others
+func GenerateSessionToken(user *models.User, tenant, sessionID string) (string, error) {
+	secret, err := getSecret()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := Claims{
+		ID:        user.ID.Hex(),
+		UserID:    user.UserID,
+		Roles:     user.Roles,
+		Tenant:    tenant,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID.Hex(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(TokenTTL)),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(secret)
+}
+
+// ParseToken validates a signed token and returns its claims
+func ParseToken(tokenString string) (*Claims, error) {
+	secret, err := getSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{}
+	_, err = jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"go-mongodb-test/models"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// setTestSecret signs and checks the test's tokens with a fixed secret
+func setTestSecret(t *testing.T) {
+	t.Helper()
+	SetSecret("test-secret")
+	t.Cleanup(func() { SetSecret("") })
+}
+
+func TestGenerateToken_MissingSecret(t *testing.T) {
+	// The environment is read once by the config package, never here
+	t.Setenv("JWT_SECRET", "env-secret")
+
+	_, err := GenerateToken(&models.User{ID: bson.NewObjectID(), UserID: "testuser"})
+	if !errors.Is(err, ErrMissingSecret) {
+		t.Errorf("Expected ErrMissingSecret, got %v", err)
+	}
+}
+
+func TestGenerateAndParseToken(t *testing.T) {
+	setTestSecret(t)
+
+	user := &models.User{ID: bson.NewObjectID(), UserID: "testuser", Roles: []string{models.RoleAdmin}}
+	token, err := GenerateToken(user)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if token == "" {
+		t.Fatal("Expected non-empty token")
+	}
+
+	claims, err := ParseToken(token)
+	if err != nil {
+		t.Fatalf("Expected token to parse, got %v", err)
+	}
+
+	if claims.ID != user.ID.Hex() {
+		t.Errorf("Expected ID claim '%s', got '%s'", user.ID.Hex(), claims.ID)
+	}
+
+	if claims.UserID != user.UserID {
+		t.Errorf("Expected user_id claim '%s', got '%s'", user.UserID, claims.UserID)
+	}
+
+	if len(claims.Roles) != 1 || claims.Roles[0] != models.RoleAdmin {
+		t.Errorf("Expected roles claim [admin], got %v", claims.Roles)
+	}
+}
+
+func TestGenerateSessionToken(t *testing.T) {
+	setTestSecret(t)
+
+	sessionID := bson.NewObjectID().Hex()
+	token, err := GenerateSessionToken(&models.User{ID: bson.NewObjectID(), UserID: "testuser"}, "acme", sessionID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	claims, err := ParseToken(token)
+	if err != nil {
+		t.Fatalf("Expected token to parse, got %v", err)
+	}
+	if claims.SessionID != sessionID || claims.Tenant != "acme" {
+		t.Errorf("Expected session %s of tenant acme, got %s of %s", sessionID, claims.SessionID, claims.Tenant)
+	}
+}
+
+func TestParseToken_WrongSecret(t *testing.T) {
+	setTestSecret(t)
+
+	token, err := GenerateToken(&models.User{ID: bson.NewObjectID(), UserID: "testuser"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	SetSecret("another-secret")
+	if _, err := ParseToken(token); err == nil {
+		t.Error("Expected error when parsing token signed with a different secret")
+	}
+}
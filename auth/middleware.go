@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// claimsContextKey is where Authenticate stores the verified claims
+const claimsContextKey = "auth_claims"
+
+// ClaimsFromContext returns the claims stored by Authenticate, if any
+func ClaimsFromContext(c echo.Context) (*Claims, bool) {
+	claims, ok := c.Get(claimsContextKey).(*Claims)
+	return claims, ok
+}
+
+// claimsKey is where Authenticate stores the claims in the request context
+type claimsKey struct{}
+
+// WithClaims returns a copy of ctx carrying claims
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFrom returns the claims carried by a request context, so that code which
+// only sees the context, such as the services, can tell who is acting
+func ClaimsFrom(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok
+}
+
+// Authenticate requires a valid "Authorization: Bearer <token>" header, issued for
+// the tenant of the request if any, and stores the token's claims in the echo and
+// request contexts, answering 401 otherwise
+func Authenticate() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Request().Header.Get(echo.HeaderAuthorization)
+			tokenString, found := strings.CutPrefix(header, "Bearer ")
+			if !found || tokenString == "" {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Missing bearer token")
+			}
+
+			claims, err := ParseToken(tokenString)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired token")
+			}
+			// Every tenant's tokens are signed with the same secret, so a token from
+			// one tenant must not open another
+			if claims.Tenant != TenantFrom(c.Request().Context()) {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Token was issued for another tenant")
+			}
+
+			c.Set(claimsContextKey, claims)
+			c.SetRequest(c.Request().WithContext(WithClaims(c.Request().Context(), claims)))
+			return next(c)
+		}
+	}
+}
+
+// RequireRole allows the request only when the authenticated claims carry role.
+// It must run after Authenticate.
+func RequireRole(role string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, ok := ClaimsFromContext(c)
+			if !ok {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Authentication required")
+			}
+
+			if !slices.Contains(claims.Roles, role) {
+				return echo.NewHTTPError(http.StatusForbidden, "Insufficient permissions")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// RequireSelfOrRole allows the request only when the authenticated claims belong to
+// the user named by the path parameter param, or carry role. It must run after
+// Authenticate.
+func RequireSelfOrRole(param, role string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, ok := ClaimsFromContext(c)
+			if !ok {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Authentication required")
+			}
+
+			if claims.ID != c.Param(param) && !slices.Contains(claims.Roles, role) {
+				return echo.NewHTTPError(http.StatusForbidden, "Insufficient permissions")
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-mongodb-test/models"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func newTestToken(t *testing.T, roles ...string) string {
+	t.Helper()
+	token, err := GenerateToken(&models.User{ID: bson.NewObjectID(), UserID: "testuser", Roles: roles})
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	return token
+}
+
+func TestAuthenticate(t *testing.T) {
+	setTestSecret(t)
+
+	tests := []struct {
+		name           string
+		header         string
+		expectedStatus int
+	}{
+		{"Valid token", "Bearer " + newTestToken(t, models.RoleUser), http.StatusOK},
+		{"Missing header", "", http.StatusUnauthorized},
+		{"Wrong scheme", "Basic dXNlcjpwYXNz", http.StatusUnauthorized},
+		{"Invalid token", "Bearer not-a-token", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.GET("/", func(c echo.Context) error {
+				claims, ok := ClaimsFromContext(c)
+				if !ok || claims.UserID != "testuser" {
+					t.Errorf("Expected claims for testuser in context, got %+v", claims)
+				}
+				if fromRequest, ok := ClaimsFrom(c.Request().Context()); !ok || fromRequest != claims {
+					t.Errorf("Expected the same claims in the request context, got %+v", fromRequest)
+				}
+				return c.NoContent(http.StatusOK)
+			}, Authenticate())
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(echo.HeaderAuthorization, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestAuthenticate_Tenant(t *testing.T) {
+	setTestSecret(t)
+
+	acmeToken, err := GenerateTenantToken(&models.User{ID: bson.NewObjectID(), UserID: "testuser"}, "acme")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		token          string
+		tenant         string
+		expectedStatus int
+	}{
+		{"Same tenant", acmeToken, "acme", http.StatusOK},
+		{"Other tenant", acmeToken, "globex", http.StatusUnauthorized},
+		{"Without tenants", acmeToken, "", http.StatusUnauthorized},
+		{"Token without a tenant", newTestToken(t), "acme", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.GET("/", func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			}, Authenticate())
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req = req.WithContext(WithTenant(req.Context(), tt.tenant))
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	setTestSecret(t)
+
+	tests := []struct {
+		name           string
+		header         string
+		expectedStatus int
+	}{
+		{"Admin", "Bearer " + newTestToken(t, models.RoleUser, models.RoleAdmin), http.StatusOK},
+		{"Regular user", "Bearer " + newTestToken(t, models.RoleUser), http.StatusForbidden},
+		{"No roles", "Bearer " + newTestToken(t), http.StatusForbidden},
+		{"Unauthenticated", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.GET("/", func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			}, Authenticate(), RequireRole(models.RoleAdmin))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(echo.HeaderAuthorization, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestRequireRole_WithoutAuthenticate(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	handler := RequireRole(models.RoleAdmin)(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	var httpErr *echo.HTTPError
+	if err := handler(c); !errors.As(err, &httpErr) || httpErr.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected a %d error, got %v", http.StatusUnauthorized, err)
+	}
+	if rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Errorf("Expected the error to be left to the error handler, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRequireSelfOrRole(t *testing.T) {
+	setTestSecret(t)
+	self := bson.NewObjectID()
+	selfToken, err := GenerateToken(&models.User{ID: self, UserID: "testuser", Roles: []string{models.RoleUser}})
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		header         string
+		expectedStatus int
+	}{
+		{"Self", "Bearer " + selfToken, http.StatusOK},
+		{"Admin", "Bearer " + newTestToken(t, models.RoleUser, models.RoleAdmin), http.StatusOK},
+		{"Another user", "Bearer " + newTestToken(t, models.RoleUser), http.StatusForbidden},
+		{"Unauthenticated", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.PATCH("/users/:id", func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			}, Authenticate(), RequireSelfOrRole("id", models.RoleAdmin))
+
+			req := httptest.NewRequest(http.MethodPatch, "/users/"+self.Hex(), nil)
+			if tt.header != "" {
+				req.Header.Set(echo.HeaderAuthorization, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+		})
+	}
+}
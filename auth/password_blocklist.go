@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+//go:embed common_passwords.txt
+var commonPasswords string
+
+// PasswordBlocklist holds passwords that must not be chosen. Entries are either
+// plain passwords, matched case-insensitively, or SHA-1 hashes in the format of
+// the Have I Been Pwned downloads ("HASH" or "HASH:count"), matched exactly.
+type PasswordBlocklist struct {
+	plain  map[string]struct{}
+	hashes map[string]struct{}
+}
+
+// DefaultPasswordBlocklist returns the bundled list of the most common passwords
+func DefaultPasswordBlocklist() *PasswordBlocklist {
+	blocklist, err := readPasswordBlocklist(strings.NewReader(commonPasswords))
+	if err != nil {
+		panic(fmt.Sprintf("invalid bundled password list: %v", err))
+	}
+	return blocklist
+}
+
+// LoadPasswordBlocklist reads a list with one entry per line from path. Blank
+// lines and lines starting with # are skipped.
+func LoadPasswordBlocklist(path string) (*PasswordBlocklist, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open password blocklist: %w", err)
+	}
+	defer file.Close()
+
+	blocklist, err := readPasswordBlocklist(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read password blocklist %s: %w", path, err)
+	}
+	return blocklist, nil
+}
+
+func readPasswordBlocklist(r io.Reader) (*PasswordBlocklist, error) {
+	blocklist := &PasswordBlocklist{
+		plain:  make(map[string]struct{}),
+		hashes: make(map[string]struct{}),
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if hash, ok := sha1Entry(line); ok {
+			blocklist.hashes[hash] = struct{}{}
+		} else {
+			blocklist.plain[strings.ToLower(line)] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return blocklist, nil
+}
+
+// sha1Entry recognizes a Have I Been Pwned line and returns its uppercase hash
+func sha1Entry(line string) (string, bool) {
+	hash, _, _ := strings.Cut(line, ":")
+	if len(hash) != sha1.Size*2 {
+		return "", false
+	}
+	if _, err := hex.DecodeString(hash); err != nil {
+		return "", false
+	}
+	return strings.ToUpper(hash), true
+}
+
+// Contains reports whether password is on the list
+func (b *PasswordBlocklist) Contains(password string) bool {
+	if _, ok := b.plain[strings.ToLower(password)]; ok {
+		return true
+	}
+	if len(b.hashes) == 0 {
+		return false
+	}
+
+	sum := sha1.Sum([]byte(password))
+	_, ok := b.hashes[strings.ToUpper(hex.EncodeToString(sum[:]))]
+	return ok
+}
+
+// Len returns the number of entries on the list
+func (b *PasswordBlocklist) Len() int {
+	return len(b.plain) + len(b.hashes)
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultPasswordBlocklist(t *testing.T) {
+	blocklist := DefaultPasswordBlocklist()
+
+	for _, password := range []string{"password123", "Password123", "qwerty", "iloveyou"} {
+		if !blocklist.Contains(password) {
+			t.Errorf("Expected '%s' to be blocked", password)
+		}
+	}
+	if blocklist.Contains("correct-horse-battery") {
+		t.Error("Expected an uncommon password to pass")
+	}
+	if blocklist.Contains("# The most common passwords from public breach corpora, one per line.") {
+		t.Error("Expected comment lines to be skipped")
+	}
+}
+
+func TestLoadPasswordBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	// SHA-1 of "hunter2", in the uppercase HASH:count format of the HIBP downloads
+	contents := "# ops supplied list\n\nletmein-please\nF3BBBD66A63D4BF1747940578EC3D0103530E21D:17\n"
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("Failed to write list: %v", err)
+	}
+
+	blocklist, err := LoadPasswordBlocklist(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if blocklist.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", blocklist.Len())
+	}
+	if !blocklist.Contains("LetMeIn-Please") {
+		t.Error("Expected a plain entry to match case-insensitively")
+	}
+	if !blocklist.Contains("hunter2") {
+		t.Error("Expected a hashed entry to match")
+	}
+	if blocklist.Contains("Hunter2") {
+		t.Error("Expected hashed entries to match exactly")
+	}
+
+	if _, err := LoadPasswordBlocklist(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
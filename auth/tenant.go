@@ -0,0 +1,21 @@
+package auth
+
+import "context"
+
+// HeaderTenantID names the tenant a request is for when the API serves several
+const HeaderTenantID = "X-Tenant-ID"
+
+// tenantKey is where the tenant of a request is stored in its context
+type tenantKey struct{}
+
+// WithTenant returns a copy of ctx carrying the tenant a request is for
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFrom returns the tenant carried by a request context, or "" when the API
+// is not split into tenants
+func TenantFrom(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestGenerateRandomToken(t *testing.T) {
+	first, err := GenerateRandomToken()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	second, err := GenerateRandomToken()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if first == second {
+		t.Error("Expected successive tokens to differ")
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(first)
+	if err != nil {
+		t.Fatalf("Expected URL-safe base64 token, got %v", err)
+	}
+	if len(decoded) != randomTokenBytes {
+		t.Errorf("Expected %d random bytes, got %d", randomTokenBytes, len(decoded))
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	hash := HashToken("token")
+
+	if hash != HashToken("token") {
+		t.Error("Expected hashing to be deterministic")
+	}
+	if hash == HashToken("other") {
+		t.Error("Expected different tokens to hash differently")
+	}
+	if len(hash) != 64 {
+		t.Errorf("Expected 64 hex characters, got %d", len(hash))
+	}
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+)
+
+func withEncryptionKey(t *testing.T, key []byte) {
+	t.Helper()
+	previous := encryptionKey
+	SetEncryptionKey(key)
+	t.Cleanup(func() { SetEncryptionKey(previous) })
+}
+
+func TestEncryptSecret(t *testing.T) {
+	withEncryptionKey(t, bytes.Repeat([]byte{1}, EncryptionKeySize))
+
+	encrypted, err := EncryptSecret("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if bytes.Contains([]byte(encrypted), []byte("JBSWY3DPEHPK3PXP")) {
+		t.Error("Expected the secret not to be stored in plaintext")
+	}
+
+	decrypted, err := DecryptSecret(encrypted)
+	if err != nil || decrypted != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("Expected the secret back, got '%s', %v", decrypted, err)
+	}
+
+	withEncryptionKey(t, bytes.Repeat([]byte{2}, EncryptionKeySize))
+	if _, err := DecryptSecret(encrypted); err == nil {
+		t.Error("Expected an error when decrypting with a different key")
+	}
+}
+
+func TestEncryptSecret_MissingKey(t *testing.T) {
+	withEncryptionKey(t, nil)
+
+	if _, err := EncryptSecret("JBSWY3DPEHPK3PXP"); !errors.Is(err, ErrMissingEncryptionKey) {
+		t.Errorf("Expected ErrMissingEncryptionKey, got %v", err)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	withEncryptionKey(t, bytes.Repeat([]byte{1}, EncryptionKeySize))
+
+	key, err := GenerateTOTPKey("alice")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	encrypted, err := EncryptSecret(key.Secret())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	code, err := totp.GenerateCode(key.Secret(), time.Now())
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+
+	if ok, err := ValidateTOTP(code, encrypted); err != nil || !ok {
+		t.Errorf("Expected the current code to be accepted, got %v, %v", ok, err)
+	}
+	if ok, _ := ValidateTOTP("000000", encrypted); ok && code != "000000" {
+		t.Error("Expected a wrong code to be rejected")
+	}
+}
@@ -0,0 +1,15 @@
+package auth
+
+// emailVerificationRequired is set once at startup by SetRequireEmailVerification
+var emailVerificationRequired bool
+
+// SetRequireEmailVerification sets whether login is refused for accounts that have
+// not verified their email, REQUIRE_EMAIL_VERIFICATION in the configuration
+func SetRequireEmailVerification(required bool) {
+	emailVerificationRequired = required
+}
+
+// RequireEmailVerification reports the setting passed to SetRequireEmailVerification
+func RequireEmailVerification() bool {
+	return emailVerificationRequired
+}
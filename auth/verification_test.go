@@ -0,0 +1,15 @@
+package auth
+
+import "testing"
+
+func TestSetRequireEmailVerification(t *testing.T) {
+	if RequireEmailVerification() {
+		t.Fatal("Expected email verification to be optional by default")
+	}
+
+	SetRequireEmailVerification(true)
+	defer SetRequireEmailVerification(false)
+	if !RequireEmailVerification() {
+		t.Error("Expected email verification to be required")
+	}
+}
@@ -0,0 +1,604 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// DefaultPort is used when PORT is unset
+	DefaultPort = "8080"
+	// DefaultRequestTimeout is used when REQUEST_TIMEOUT is unset
+	DefaultRequestTimeout = 30 * time.Second
+	// DefaultRateLimitPerMinute is used when RATE_LIMIT_PER_MINUTE is unset
+	DefaultRateLimitPerMinute = 10
+	// DefaultAvailabilityRateLimitPerMinute is used when AVAILABILITY_RATE_LIMIT_PER_MINUTE is unset
+	DefaultAvailabilityRateLimitPerMinute = 30
+	// DefaultUserCacheTTL is used when USER_CACHE_TTL is unset
+	DefaultUserCacheTTL = time.Minute
+	// DefaultLoginMaxFailures is used when LOGIN_MAX_FAILURES is unset
+	DefaultLoginMaxFailures = 5
+	// DefaultLoginLockoutDuration is used when LOGIN_LOCKOUT_DURATION is unset
+	DefaultLoginLockoutDuration = 15 * time.Minute
+	// DefaultPasswordResetTokenTTL is used when PASSWORD_RESET_TOKEN_TTL is unset
+	DefaultPasswordResetTokenTTL = time.Hour
+	// DefaultRefreshTokenTTL is used when REFRESH_TOKEN_TTL is unset
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+	// DefaultPasswordHashAlgo is used when PASSWORD_HASH_ALGO is unset
+	DefaultPasswordHashAlgo = "bcrypt"
+	// DefaultBcryptCost is used when BCRYPT_COST is unset
+	DefaultBcryptCost = bcrypt.DefaultCost
+
+	// StorageMongo keeps users in MongoDB, the default
+	StorageMongo = "mongo"
+	// StorageMemory keeps users in process memory, for demos of the user CRUD routes
+	// without MongoDB; login, tokens and the other routes that need the collections
+	// answer 501
+	StorageMemory = "memory"
+
+	// DefaultMongoURI is used when MONGODB_URI is unset
+	DefaultMongoURI = "mongodb://localhost:27017"
+	// DefaultDatabaseName is used when neither DATABASE_NAME nor MONGODB_DB_NAME is set
+	DefaultDatabaseName = "user_management"
+	// DefaultConnectRetries keeps the original single-attempt behavior
+	DefaultConnectRetries = 1
+	// DefaultConnectBackoff is the wait before the first retry; it doubles after each failure
+	DefaultConnectBackoff = time.Second
+	// DefaultOperationTimeout is used when MONGODB_OPERATION_TIMEOUT is unset
+	DefaultOperationTimeout = 10 * time.Second
+)
+
+// Config holds the settings read from the environment at startup
+type Config struct {
+	Port               string
+	APIPrefix          string
+	RequestTimeout     time.Duration
+	RateLimitPerMinute int
+	AllowDestructive   bool
+	// AvailabilityRateLimitPerMinute is the per-IP budget of GET /users/available,
+	// separate from RateLimitPerMinute so that signup forms checking as the user
+	// types do not use up the login budget
+	AvailabilityRateLimitPerMinute int
+	// MaintenanceMode starts the server with writes answered by 503; admins can
+	// turn it off at runtime with POST /admin/maintenance
+	MaintenanceMode bool
+	// ResponseEnvelope wraps successful responses in {"data": ..., "meta": ...};
+	// off by default so that existing clients keep the bare bodies
+	ResponseEnvelope bool
+	JWTSecret        string
+	// TOTPEncryptionKey is the decoded AES-256 key for stored TOTP secrets; two-factor
+	// enrollment is unavailable while it is empty
+	TOTPEncryptionKey string
+	// BlockCommonPasswords rejects new passwords found on a blocklist: the bundled
+	// list of common passwords, or PasswordBlocklistFile when that is set
+	BlockCommonPasswords  bool
+	PasswordBlocklistFile string
+	// PasswordHashAlgo hashes new passwords; hashes made by the other algorithm still
+	// verify and are replaced on the next successful login
+	PasswordHashAlgo string
+	// BcryptCost is the cost of new bcrypt hashes; stored hashes below it are
+	// replaced on the next successful login
+	BcryptCost int
+	// RequireEmailVerification refuses login to accounts that have not verified their email
+	RequireEmailVerification bool
+	// LoginMaxFailures consecutive failed logins lock an account for LoginLockoutDuration
+	LoginMaxFailures     int
+	LoginLockoutDuration time.Duration
+	// PasswordResetTokenTTL and RefreshTokenTTL are how long each kind of token stays
+	// valid; MongoDB deletes the tokens once they expire
+	PasswordResetTokenTTL time.Duration
+	RefreshTokenTTL       time.Duration
+	// MaxConcurrentRequests caps API requests in flight; zero, the default, means no cap
+	MaxConcurrentRequests int
+	// UserEventsEnabled registers the server-sent event stream of user changes, which
+	// holds a connection open per subscriber
+	UserEventsEnabled bool
+	// UserCacheSize caps the in-memory user cache; zero, the default, disables it
+	UserCacheSize int
+	UserCacheTTL  time.Duration
+	// Storage is StorageMongo or StorageMemory; the Mongo settings are ignored in memory
+	Storage string
+	// SeedFile names a JSON array of users created at startup if missing; empty skips seeding
+	SeedFile string
+	// TrustedProxies are the networks whose X-Forwarded-For and X-Real-IP headers name
+	// the client. With none, the client is always the direct peer.
+	TrustedProxies []*net.IPNet
+	// APIKeys are the service keys accepted in X-API-Key on the admin user routes
+	APIKeys []APIKey
+	// Tenants, when set, serve each request from the database of the tenant named
+	// by its X-Tenant-ID header; requests for any other tenant are rejected
+	Tenants []Tenant
+	CORS    CORS
+	Mongo   Mongo
+}
+
+// CORS holds the cross-origin policy for browser clients
+type CORS struct {
+	// AllowedOrigins empty allows every origin, which is only appropriate for local
+	// development; AllowedMethods empty allows the usual REST methods
+	AllowedOrigins []string
+	AllowedMethods []string
+	// AllowCredentials lets browsers send cookies and auth headers; it needs
+	// AllowedOrigins, since every origin could otherwise read credentialed responses
+	AllowCredentials bool
+}
+
+// APIKey is one API_KEYS entry: a named key, known only by its SHA-256, that may
+// read, or read and write
+type APIKey struct {
+	Name  string
+	Scope string
+	Hash  [sha256.Size]byte
+}
+
+// APIKeyScopes are the accepted API key scopes
+var APIKeyScopes = []string{"read", "write"}
+
+// Tenant is one TENANTS entry: a tenant ID and the database holding its users
+type Tenant struct {
+	ID       string
+	Database string
+}
+
+// tenantNamePattern limits tenant IDs and database names to characters MongoDB
+// allows in a database name and that need no escaping in a header
+var tenantNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,63}$`)
+
+// Mongo holds the MongoDB connection settings
+type Mongo struct {
+	URI      string
+	Database string
+	// User and Password are only applied when User is set; the URI may embed them instead
+	User     string
+	Password string
+	TLS      bool
+	CAFile   string
+	// Zero pool values keep the driver defaults: a maximum of 100 connections,
+	// no minimum, and no idle timeout
+	MaxPoolSize     uint64
+	MinPoolSize     uint64
+	MaxConnIdleTime time.Duration
+	// ConnectRetries is the total number of connection attempts
+	ConnectRetries int
+	ConnectBackoff time.Duration
+	// OperationTimeout bounds each service call, within the request's own deadline
+	OperationTimeout time.Duration
+	// ReadPreference, WriteConcern and WriteJournal apply to every operation; empty
+	// values keep what the URI sets, which defaults to primary reads and w=1
+	ReadPreference string
+	WriteConcern   string
+	WriteJournal   bool
+	// ListReadPreference overrides ReadPreference for listing, searching, counting
+	// and exporting users, which can tolerate slightly stale results
+	ListReadPreference string
+}
+
+// PasswordHashAlgos are the accepted PASSWORD_HASH_ALGO values
+var PasswordHashAlgos = []string{"bcrypt", "argon2id"}
+
+// CORSMethods are the accepted CORS_ALLOWED_METHODS entries
+var CORSMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// Storages are the accepted STORAGE values
+var Storages = []string{StorageMongo, StorageMemory}
+
+// ReadPreferenceModes are the accepted MONGODB_READ_PREFERENCE and
+// MONGODB_LIST_READ_PREFERENCE values
+var ReadPreferenceModes = []string{"primary", "primaryPreferred", "secondary", "secondaryPreferred", "nearest"}
+
+// Load reads the configuration from environment variables and validates it,
+// reporting every invalid setting at once
+func Load() (*Config, error) {
+	return load(os.Getenv)
+}
+
+// load builds the configuration from getenv, which tests replace with a map lookup
+func load(getenv func(string) string) (*Config, error) {
+	r := reader{getenv: getenv}
+
+	cfg := &Config{
+		Port:               r.port("PORT"),
+		APIPrefix:          getenv("API_PREFIX"),
+		RequestTimeout:     r.positiveDuration("REQUEST_TIMEOUT", DefaultRequestTimeout),
+		RateLimitPerMinute: r.positiveInt("RATE_LIMIT_PER_MINUTE", DefaultRateLimitPerMinute),
+		// The availability check is budgeted apart from the credential endpoints
+		AvailabilityRateLimitPerMinute: r.positiveInt("AVAILABILITY_RATE_LIMIT_PER_MINUTE", DefaultAvailabilityRateLimitPerMinute),
+		// Anything but exactly "true" keeps the destructive routes off
+		AllowDestructive:      getenv("ALLOW_DESTRUCTIVE") == "true",
+		MaintenanceMode:       r.boolean("MAINTENANCE_MODE"),
+		ResponseEnvelope:      r.boolean("RESPONSE_ENVELOPE"),
+		JWTSecret:             r.required("JWT_SECRET"),
+		TOTPEncryptionKey:     r.encryptionKey("TOTP_ENCRYPTION_KEY"),
+		BlockCommonPasswords:  r.boolean("BLOCK_COMMON_PASSWORDS"),
+		PasswordBlocklistFile: getenv("PASSWORD_BLOCKLIST_FILE"),
+		PasswordHashAlgo:      r.passwordHashAlgo("PASSWORD_HASH_ALGO"),
+		BcryptCost:            r.intBetween("BCRYPT_COST", bcrypt.MinCost, bcrypt.MaxCost, DefaultBcryptCost),
+		LoginMaxFailures:      r.positiveInt("LOGIN_MAX_FAILURES", DefaultLoginMaxFailures),
+		LoginLockoutDuration:  r.positiveDuration("LOGIN_LOCKOUT_DURATION", DefaultLoginLockoutDuration),
+		PasswordResetTokenTTL: r.positiveDuration("PASSWORD_RESET_TOKEN_TTL", DefaultPasswordResetTokenTTL),
+		RefreshTokenTTL:       r.positiveDuration("REFRESH_TOKEN_TTL", DefaultRefreshTokenTTL),
+		MaxConcurrentRequests: int(r.nonNegativeInt("MAX_CONCURRENT_REQUESTS")),
+		UserEventsEnabled:     r.boolean("USER_EVENTS_ENABLED"),
+		UserCacheSize:         int(r.nonNegativeInt("USER_CACHE_SIZE")),
+		UserCacheTTL:          r.positiveDuration("USER_CACHE_TTL", DefaultUserCacheTTL),
+		Storage:               r.storage("STORAGE"),
+		SeedFile:              getenv("SEED_FILE"),
+		TrustedProxies:        r.networks("TRUSTED_PROXIES"),
+		APIKeys:               r.apiKeys("API_KEYS"),
+		Tenants:               r.tenants("TENANTS"),
+		// Unset leaves login open to accounts that have not verified their email
+		RequireEmailVerification: r.boolean("REQUIRE_EMAIL_VERIFICATION"),
+		CORS: CORS{
+			AllowedOrigins:   r.list("CORS_ALLOWED_ORIGINS"),
+			AllowedMethods:   r.corsMethods("CORS_ALLOWED_METHODS"),
+			AllowCredentials: r.boolean("CORS_ALLOW_CREDENTIALS"),
+		},
+		Mongo: Mongo{
+			URI:                r.mongoURI("MONGODB_URI"),
+			Database:           databaseName(getenv),
+			User:               getenv("MONGODB_USER"),
+			Password:           getenv("MONGODB_PASSWORD"),
+			TLS:                r.boolean("MONGODB_TLS"),
+			CAFile:             getenv("MONGODB_CA_FILE"),
+			MaxPoolSize:        r.nonNegativeInt("MONGODB_MAX_POOL_SIZE"),
+			MinPoolSize:        r.nonNegativeInt("MONGODB_MIN_POOL_SIZE"),
+			MaxConnIdleTime:    r.positiveDuration("MONGODB_MAX_CONN_IDLE_TIME", 0),
+			ConnectRetries:     r.positiveInt("MONGODB_CONNECT_RETRIES", DefaultConnectRetries),
+			ConnectBackoff:     r.positiveDuration("MONGODB_CONNECT_BACKOFF", DefaultConnectBackoff),
+			OperationTimeout:   r.positiveDuration("MONGODB_OPERATION_TIMEOUT", DefaultOperationTimeout),
+			ReadPreference:     r.readPreference("MONGODB_READ_PREFERENCE"),
+			WriteConcern:       r.writeConcern("MONGODB_WRITE_CONCERN"),
+			WriteJournal:       r.boolean("MONGODB_WRITE_JOURNAL"),
+			ListReadPreference: r.readPreference("MONGODB_LIST_READ_PREFERENCE"),
+		},
+	}
+
+	if cfg.Mongo.MaxPoolSize > 0 && cfg.Mongo.MinPoolSize > cfg.Mongo.MaxPoolSize {
+		r.fail("MONGODB_MIN_POOL_SIZE", "must not exceed MONGODB_MAX_POOL_SIZE (%d)", cfg.Mongo.MaxPoolSize)
+	}
+
+	if cfg.CORS.AllowCredentials && (len(cfg.CORS.AllowedOrigins) == 0 || slices.Contains(cfg.CORS.AllowedOrigins, "*")) {
+		r.fail("CORS_ALLOW_CREDENTIALS", "needs CORS_ALLOWED_ORIGINS to name the allowed origins instead of *")
+	}
+
+	if cfg.PasswordBlocklistFile != "" && !cfg.BlockCommonPasswords {
+		r.fail("PASSWORD_BLOCKLIST_FILE", "has no effect unless BLOCK_COMMON_PASSWORDS is true")
+	}
+
+	// Each tenant has its own users collection, while these work on a single one
+	if len(cfg.Tenants) > 0 {
+		if cfg.Storage == StorageMemory {
+			r.fail("TENANTS", "needs STORAGE=mongo")
+		}
+		if cfg.UserEventsEnabled {
+			r.fail("TENANTS", "cannot be combined with USER_EVENTS_ENABLED")
+		}
+		if cfg.SeedFile != "" {
+			r.fail("TENANTS", "cannot be combined with SEED_FILE")
+		}
+	}
+
+	if len(r.errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration: %w", errors.Join(r.errs...))
+	}
+	return cfg, nil
+}
+
+// databaseName returns DATABASE_NAME. The older MONGODB_DB_NAME is still read when
+// DATABASE_NAME is unset, but DATABASE_NAME always wins when both are present.
+func databaseName(getenv func(string) string) string {
+	if name := getenv("DATABASE_NAME"); name != "" {
+		return name
+	}
+	if name := getenv("MONGODB_DB_NAME"); name != "" {
+		slog.Warn("MONGODB_DB_NAME is deprecated; set DATABASE_NAME instead")
+		return name
+	}
+	return DefaultDatabaseName
+}
+
+// reader parses environment values, collecting an error for each invalid one
+type reader struct {
+	getenv func(string) string
+	errs   []error
+}
+
+func (r *reader) fail(key, format string, args ...any) {
+	r.errs = append(r.errs, fmt.Errorf("%s %s", key, fmt.Sprintf(format, args...)))
+}
+
+func (r *reader) required(key string) string {
+	value := r.getenv(key)
+	if value == "" {
+		r.fail(key, "is required")
+	}
+	return value
+}
+
+// encryptionKey decodes a base64 AES-256 key, as printed by "openssl rand -base64 32"
+func (r *reader) encryptionKey(key string) string {
+	value := r.getenv(key)
+	if value == "" {
+		return ""
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(decoded) != 32 {
+		r.fail(key, "must be 32 bytes encoded as base64")
+		return ""
+	}
+	return string(decoded)
+}
+
+func (r *reader) port(key string) string {
+	value := r.getenv(key)
+	if value == "" {
+		return DefaultPort
+	}
+
+	if port, err := strconv.Atoi(value); err != nil || port < 1 || port > 65535 {
+		r.fail(key, "must be a port number between 1 and 65535, got %q", value)
+	}
+	return value
+}
+
+func (r *reader) mongoURI(key string) string {
+	value := r.getenv(key)
+	if value == "" {
+		return DefaultMongoURI
+	}
+
+	if !strings.HasPrefix(value, "mongodb://") && !strings.HasPrefix(value, "mongodb+srv://") {
+		r.fail(key, "must start with mongodb:// or mongodb+srv://")
+	}
+	return value
+}
+
+func (r *reader) storage(key string) string {
+	value := r.getenv(key)
+	if value == "" {
+		return StorageMongo
+	}
+	if !slices.Contains(Storages, value) {
+		r.fail(key, "must be one of %s, got %q", strings.Join(Storages, ", "), value)
+	}
+	return value
+}
+
+func (r *reader) passwordHashAlgo(key string) string {
+	value := r.getenv(key)
+	if value == "" {
+		return DefaultPasswordHashAlgo
+	}
+	if !slices.Contains(PasswordHashAlgos, value) {
+		r.fail(key, "must be one of %s, got %q", strings.Join(PasswordHashAlgos, ", "), value)
+	}
+	return value
+}
+
+func (r *reader) readPreference(key string) string {
+	value := r.getenv(key)
+	if value != "" && !slices.Contains(ReadPreferenceModes, value) {
+		r.fail(key, "must be one of %s, got %q", strings.Join(ReadPreferenceModes, ", "), value)
+		return ""
+	}
+	return value
+}
+
+// writeConcern accepts "majority" or how many members must acknowledge each write.
+// Unacknowledged writes (w=0) are refused, since the service reads back write results.
+func (r *reader) writeConcern(key string) string {
+	value := r.getenv(key)
+	if value == "" || value == "majority" {
+		return value
+	}
+
+	if nodes, err := strconv.Atoi(value); err != nil || nodes < 1 {
+		r.fail(key, "must be majority or a positive number of members, got %q", value)
+		return ""
+	}
+	return value
+}
+
+// list parses a comma-separated value, dropping empty entries
+func (r *reader) list(key string) []string {
+	var items []string
+	for _, item := range strings.Split(r.getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// corsMethods parses a comma-separated list of HTTP methods, in any case
+func (r *reader) corsMethods(key string) []string {
+	var methods []string
+	for _, method := range r.list(key) {
+		method = strings.ToUpper(method)
+		if !slices.Contains(CORSMethods, method) {
+			r.fail(key, "entries must be one of %s, got %q", strings.Join(CORSMethods, ", "), method)
+			continue
+		}
+		methods = append(methods, method)
+	}
+	return methods
+}
+
+// networks parses a comma-separated list of CIDRs; a bare IP stands for that one address
+func (r *reader) networks(key string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(r.getenv(key), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if ip := net.ParseIP(entry); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			r.fail(key, "must be a comma-separated list of IPs or CIDRs such as 10.0.0.0/8, got %q", entry)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// apiKeys parses a comma-separated list of name:scope:sha256 entries, the hash
+// being the hex SHA-256 of the key itself
+func (r *reader) apiKeys(key string) []APIKey {
+	var keys []APIKey
+	for _, entry := range strings.Split(r.getenv(key), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 || parts[0] == "" {
+			r.fail(key, "must be a comma-separated list of name:scope:sha256 entries, got %q", entry)
+			continue
+		}
+		name, scope := parts[0], parts[1]
+		if !slices.Contains(APIKeyScopes, scope) {
+			r.fail(key, "scope of %q must be one of %s, got %q", name, strings.Join(APIKeyScopes, ", "), scope)
+			continue
+		}
+		hash, err := hex.DecodeString(parts[2])
+		if err != nil || len(hash) != sha256.Size {
+			r.fail(key, "hash of %q must be a hex SHA-256, got %q", name, parts[2])
+			continue
+		}
+		if slices.ContainsFunc(keys, func(k APIKey) bool { return k.Name == name }) {
+			r.fail(key, "names %q more than once", name)
+			continue
+		}
+
+		apiKey := APIKey{Name: name, Scope: scope}
+		copy(apiKey.Hash[:], hash)
+		keys = append(keys, apiKey)
+	}
+	return keys
+}
+
+// tenants parses comma-separated "id" or "id:database" entries; a tenant without a
+// database of its own is stored in the database named after it
+func (r *reader) tenants(key string) []Tenant {
+	var tenants []Tenant
+	for _, entry := range strings.Split(r.getenv(key), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, database, found := strings.Cut(entry, ":")
+		if !found {
+			database = id
+		}
+		if !tenantNamePattern.MatchString(id) || !tenantNamePattern.MatchString(database) {
+			r.fail(key, "must be a comma-separated list of id or id:database entries of letters, digits, _ and -, got %q", entry)
+			continue
+		}
+		if slices.ContainsFunc(tenants, func(t Tenant) bool { return t.ID == id }) {
+			r.fail(key, "names %q more than once", id)
+			continue
+		}
+		if slices.ContainsFunc(tenants, func(t Tenant) bool { return t.Database == database }) {
+			r.fail(key, "stores more than one tenant in %q", database)
+			continue
+		}
+
+		tenants = append(tenants, Tenant{ID: id, Database: database})
+	}
+	return tenants
+}
+
+func (r *reader) boolean(key string) bool {
+	value := r.getenv(key)
+	if value == "" {
+		return false
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		r.fail(key, "must be true or false, got %q", value)
+	}
+	return parsed
+}
+
+func (r *reader) positiveInt(key string, defaultValue int) int {
+	value := r.getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 1 {
+		r.fail(key, "must be a positive integer, got %q", value)
+		return defaultValue
+	}
+	return parsed
+}
+
+func (r *reader) intBetween(key string, minValue, maxValue, defaultValue int) int {
+	value := r.getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < minValue || parsed > maxValue {
+		r.fail(key, "must be an integer between %d and %d, got %q", minValue, maxValue, value)
+		return defaultValue
+	}
+	return parsed
+}
+
+func (r *reader) nonNegativeInt(key string) uint64 {
+	value := r.getenv(key)
+	if value == "" {
+		return 0
+	}
+
+	parsed, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		r.fail(key, "must be a non-negative integer, got %q", value)
+	}
+	return parsed
+}
+
+func (r *reader) positiveDuration(key string, defaultValue time.Duration) time.Duration {
+	value := r.getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		r.fail(key, "must be a positive duration such as \"5s\", got %q", value)
+		return defaultValue
+	}
+	return parsed
+}
@@ -0,0 +1,405 @@
+package config
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// env returns a getenv replacement backed by values
+func env(values map[string]string) func(string) string {
+	return func(key string) string {
+		return values[key]
+	}
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := load(env(map[string]string{"JWT_SECRET": "secret"}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if cfg.Port != DefaultPort {
+		t.Errorf("Expected port '%s', got '%s'", DefaultPort, cfg.Port)
+	}
+	if cfg.RequestTimeout != DefaultRequestTimeout {
+		t.Errorf("Expected request timeout %v, got %v", DefaultRequestTimeout, cfg.RequestTimeout)
+	}
+	if cfg.RateLimitPerMinute != DefaultRateLimitPerMinute {
+		t.Errorf("Expected rate limit %d, got %d", DefaultRateLimitPerMinute, cfg.RateLimitPerMinute)
+	}
+	if cfg.AvailabilityRateLimitPerMinute != DefaultAvailabilityRateLimitPerMinute {
+		t.Errorf("Expected availability rate limit %d, got %d", DefaultAvailabilityRateLimitPerMinute, cfg.AvailabilityRateLimitPerMinute)
+	}
+	if cfg.AllowDestructive {
+		t.Error("Expected destructive routes to be off by default")
+	}
+	if cfg.UserEventsEnabled {
+		t.Error("Expected the user event stream to be off by default")
+	}
+	if cfg.UserCacheSize != 0 {
+		t.Errorf("Expected the user cache to be off by default, got size %d", cfg.UserCacheSize)
+	}
+	if cfg.UserCacheTTL != DefaultUserCacheTTL {
+		t.Errorf("Expected user cache TTL %v, got %v", DefaultUserCacheTTL, cfg.UserCacheTTL)
+	}
+	if cfg.Mongo.URI != DefaultMongoURI {
+		t.Errorf("Expected URI '%s', got '%s'", DefaultMongoURI, cfg.Mongo.URI)
+	}
+	if cfg.Mongo.Database != DefaultDatabaseName {
+		t.Errorf("Expected database '%s', got '%s'", DefaultDatabaseName, cfg.Mongo.Database)
+	}
+	if cfg.Mongo.ConnectRetries != DefaultConnectRetries {
+		t.Errorf("Expected %d connect retries, got %d", DefaultConnectRetries, cfg.Mongo.ConnectRetries)
+	}
+	if cfg.Mongo.ConnectBackoff != DefaultConnectBackoff {
+		t.Errorf("Expected connect backoff %v, got %v", DefaultConnectBackoff, cfg.Mongo.ConnectBackoff)
+	}
+	if cfg.BlockCommonPasswords {
+		t.Error("Expected the password blocklist to be off")
+	}
+	if cfg.TOTPEncryptionKey != "" {
+		t.Error("Expected no TOTP encryption key")
+	}
+	if cfg.LoginMaxFailures != DefaultLoginMaxFailures || cfg.LoginLockoutDuration != DefaultLoginLockoutDuration {
+		t.Errorf("Expected the default lockout policy, got %d failures for %v", cfg.LoginMaxFailures, cfg.LoginLockoutDuration)
+	}
+	if cfg.PasswordResetTokenTTL != DefaultPasswordResetTokenTTL || cfg.RefreshTokenTTL != DefaultRefreshTokenTTL {
+		t.Errorf("Expected the default token lifetimes, got %v and %v", cfg.PasswordResetTokenTTL, cfg.RefreshTokenTTL)
+	}
+	if cfg.MaintenanceMode {
+		t.Error("Expected maintenance mode to be off")
+	}
+	if cfg.ResponseEnvelope {
+		t.Error("Expected bare response bodies")
+	}
+	if cfg.BcryptCost != DefaultBcryptCost {
+		t.Errorf("Expected bcrypt cost %d, got %d", DefaultBcryptCost, cfg.BcryptCost)
+	}
+	if cfg.RequireEmailVerification {
+		t.Error("Expected email verification to be optional")
+	}
+	if len(cfg.CORS.AllowedOrigins) != 0 || len(cfg.CORS.AllowedMethods) != 0 || cfg.CORS.AllowCredentials {
+		t.Errorf("Expected the CORS policy to be left to its defaults, got %+v", cfg.CORS)
+	}
+	if cfg.PasswordHashAlgo != DefaultPasswordHashAlgo {
+		t.Errorf("Expected password hash algorithm %q, got %q", DefaultPasswordHashAlgo, cfg.PasswordHashAlgo)
+	}
+	if cfg.Storage != StorageMongo {
+		t.Errorf("Expected storage %q, got %q", StorageMongo, cfg.Storage)
+	}
+	if len(cfg.APIKeys) != 0 {
+		t.Errorf("Expected no API keys, got %v", cfg.APIKeys)
+	}
+	if len(cfg.Tenants) != 0 {
+		t.Errorf("Expected no tenants, got %v", cfg.Tenants)
+	}
+	if len(cfg.TrustedProxies) != 0 {
+		t.Errorf("Expected no trusted proxies, got %v", cfg.TrustedProxies)
+	}
+	if cfg.Mongo.OperationTimeout != DefaultOperationTimeout {
+		t.Errorf("Expected operation timeout %v, got %v", DefaultOperationTimeout, cfg.Mongo.OperationTimeout)
+	}
+	if cfg.Mongo.MaxPoolSize != 0 || cfg.Mongo.MinPoolSize != 0 || cfg.Mongo.MaxConnIdleTime != 0 {
+		t.Errorf("Expected pool settings to be unset, got %+v", cfg.Mongo)
+	}
+	if cfg.Mongo.ReadPreference != "" || cfg.Mongo.WriteConcern != "" || cfg.Mongo.WriteJournal || cfg.Mongo.ListReadPreference != "" {
+		t.Errorf("Expected read preference and write concern to be left to the URI, got %+v", cfg.Mongo)
+	}
+}
+
+func TestLoad_Values(t *testing.T) {
+	cfg, err := load(env(map[string]string{
+		"PORT":                         "9000",
+		"API_PREFIX":                   "/api/v2",
+		"REQUEST_TIMEOUT":              "5s",
+		"RATE_LIMIT_PER_MINUTE":        "30",
+		"ALLOW_DESTRUCTIVE":            "true",
+		"MAINTENANCE_MODE":             "true",
+		"RESPONSE_ENVELOPE":            "true",
+		"JWT_SECRET":                   "secret",
+		"TOTP_ENCRYPTION_KEY":          "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=",
+		"BLOCK_COMMON_PASSWORDS":       "true",
+		"PASSWORD_BLOCKLIST_FILE":      "/etc/app/pwned.txt",
+		"PASSWORD_HASH_ALGO":           "argon2id",
+		"BCRYPT_COST":                  "12",
+		"REQUIRE_EMAIL_VERIFICATION":   "true",
+		"LOGIN_MAX_FAILURES":           "3",
+		"LOGIN_LOCKOUT_DURATION":       "1h",
+		"MAX_CONCURRENT_REQUESTS":      "200",
+		"USER_EVENTS_ENABLED":          "true",
+		"USER_CACHE_SIZE":              "1000",
+		"USER_CACHE_TTL":               "30s",
+		"PASSWORD_RESET_TOKEN_TTL":     "15m",
+		"REFRESH_TOKEN_TTL":            "168h",
+		"STORAGE":                      "memory",
+		"SEED_FILE":                    "/etc/app/seed.json",
+		"TRUSTED_PROXIES":              "10.0.0.0/8, 192.168.1.10,,fd00::/8",
+		"API_KEYS":                     "reporting:read:" + strings.Repeat("ab", 32) + ", sync:write:" + strings.Repeat("0F", 32),
+		"CORS_ALLOWED_ORIGINS":         "https://app.example.com, https://admin.example.com",
+		"CORS_ALLOWED_METHODS":         "get,POST",
+		"CORS_ALLOW_CREDENTIALS":       "true",
+		"MONGODB_URI":                  "mongodb+srv://cluster.example.com",
+		"DATABASE_NAME":                "custom_db",
+		"MONGODB_USER":                 "admin",
+		"MONGODB_PASSWORD":             "password",
+		"MONGODB_TLS":                  "true",
+		"MONGODB_CA_FILE":              "/etc/ssl/ca.pem",
+		"MONGODB_MAX_POOL_SIZE":        "50",
+		"MONGODB_MIN_POOL_SIZE":        "5",
+		"MONGODB_MAX_CONN_IDLE_TIME":   "2m",
+		"MONGODB_CONNECT_RETRIES":      "5",
+		"MONGODB_CONNECT_BACKOFF":      "250ms",
+		"MONGODB_OPERATION_TIMEOUT":    "3s",
+		"MONGODB_READ_PREFERENCE":      "primaryPreferred",
+		"MONGODB_WRITE_CONCERN":        "majority",
+		"MONGODB_WRITE_JOURNAL":        "true",
+		"MONGODB_LIST_READ_PREFERENCE": "secondaryPreferred",
+		// Budgeted apart from RATE_LIMIT_PER_MINUTE
+		"AVAILABILITY_RATE_LIMIT_PER_MINUTE": "60",
+	}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := Config{
+		Port:                  "9000",
+		APIPrefix:             "/api/v2",
+		RequestTimeout:        5 * time.Second,
+		RateLimitPerMinute:    30,
+		AllowDestructive:      true,
+		MaintenanceMode:       true,
+		ResponseEnvelope:      true,
+		JWTSecret:             "secret",
+		TOTPEncryptionKey:     "0123456789abcdef0123456789abcdef",
+		BlockCommonPasswords:  true,
+		PasswordBlocklistFile: "/etc/app/pwned.txt",
+		PasswordHashAlgo:      "argon2id",
+		BcryptCost:            12,
+		LoginMaxFailures:      3,
+		LoginLockoutDuration:  time.Hour,
+		PasswordResetTokenTTL: 15 * time.Minute,
+		RefreshTokenTTL:       7 * 24 * time.Hour,
+		MaxConcurrentRequests: 200,
+		UserEventsEnabled:     true,
+		UserCacheSize:         1000,
+		UserCacheTTL:          30 * time.Second,
+		Storage:               StorageMemory,
+		SeedFile:              "/etc/app/seed.json",
+		TrustedProxies: []*net.IPNet{
+			{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 32)},
+			{IP: net.IP{192, 168, 1, 10}, Mask: net.CIDRMask(32, 32)},
+			{IP: net.ParseIP("fd00::"), Mask: net.CIDRMask(8, 128)},
+		},
+		APIKeys: []APIKey{
+			{Name: "reporting", Scope: "read", Hash: [32]byte(bytes.Repeat([]byte{0xab}, 32))},
+			{Name: "sync", Scope: "write", Hash: [32]byte(bytes.Repeat([]byte{0x0f}, 32))},
+		},
+		RequireEmailVerification:       true,
+		AvailabilityRateLimitPerMinute: 60,
+		CORS: CORS{
+			AllowedOrigins:   []string{"https://app.example.com", "https://admin.example.com"},
+			AllowedMethods:   []string{"GET", "POST"},
+			AllowCredentials: true,
+		},
+		Mongo: Mongo{
+			URI:                "mongodb+srv://cluster.example.com",
+			Database:           "custom_db",
+			User:               "admin",
+			Password:           "password",
+			TLS:                true,
+			CAFile:             "/etc/ssl/ca.pem",
+			MaxPoolSize:        50,
+			MinPoolSize:        5,
+			MaxConnIdleTime:    2 * time.Minute,
+			ConnectRetries:     5,
+			ConnectBackoff:     250 * time.Millisecond,
+			OperationTimeout:   3 * time.Second,
+			ReadPreference:     "primaryPreferred",
+			WriteConcern:       "majority",
+			WriteJournal:       true,
+			ListReadPreference: "secondaryPreferred",
+		},
+	}
+	if !reflect.DeepEqual(*cfg, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, *cfg)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+	}{
+		{"PORT", "http"},
+		{"PORT", "70000"},
+		{"REQUEST_TIMEOUT", "soon"},
+		{"REQUEST_TIMEOUT", "0s"},
+		{"RATE_LIMIT_PER_MINUTE", "-5"},
+		{"AVAILABILITY_RATE_LIMIT_PER_MINUTE", "0"},
+		{"TOTP_ENCRYPTION_KEY", "not base64!"},
+		{"TOTP_ENCRYPTION_KEY", "c2hvcnQ="},
+		{"BLOCK_COMMON_PASSWORDS", "sometimes"},
+		{"USER_EVENTS_ENABLED", "always"},
+		{"MAINTENANCE_MODE", "on"},
+		{"RESPONSE_ENVELOPE", "yes"},
+		{"API_KEYS", "reporting:" + strings.Repeat("ab", 32)},
+		{"API_KEYS", "reporting:admin:" + strings.Repeat("ab", 32)},
+		{"API_KEYS", "reporting:read:secret"},
+		{"API_KEYS", "a:read:" + strings.Repeat("ab", 32) + ",a:write:" + strings.Repeat("cd", 32)},
+		{"TENANTS", "acme.corp"},
+		{"TENANTS", "acme:"},
+		{"TENANTS", "acme,acme:acme_users"},
+		{"TENANTS", "acme:shared,globex:shared"},
+		{"PASSWORD_BLOCKLIST_FILE", "/etc/app/pwned.txt"},
+		{"PASSWORD_HASH_ALGO", "scrypt"},
+		{"BCRYPT_COST", "3"},
+		{"BCRYPT_COST", "32"},
+		{"BCRYPT_COST", "abc"},
+		{"REQUIRE_EMAIL_VERIFICATION", "yes"},
+		{"CORS_ALLOWED_METHODS", "GET,FETCH"},
+		{"CORS_ALLOW_CREDENTIALS", "sometimes"},
+		// Credentials need named origins
+		{"CORS_ALLOW_CREDENTIALS", "true"},
+		{"LOGIN_MAX_FAILURES", "0"},
+		{"LOGIN_LOCKOUT_DURATION", "a while"},
+		{"PASSWORD_RESET_TOKEN_TTL", "0s"},
+		{"REFRESH_TOKEN_TTL", "forever"},
+		{"MAX_CONCURRENT_REQUESTS", "many"},
+		{"USER_CACHE_SIZE", "-1"},
+		{"USER_CACHE_TTL", "forever"},
+		{"STORAGE", "postgres"},
+		{"TRUSTED_PROXIES", "10.0.0.0/8,proxy.internal"},
+		{"TRUSTED_PROXIES", "10.0.0.0/33"},
+		{"MONGODB_URI", "localhost:27017"},
+		{"MONGODB_TLS", "yes please"},
+		{"MONGODB_MAX_POOL_SIZE", "abc"},
+		{"MONGODB_MAX_CONN_IDLE_TIME", "-1m"},
+		{"MONGODB_CONNECT_RETRIES", "0"},
+		{"MONGODB_CONNECT_BACKOFF", "later"},
+		{"MONGODB_OPERATION_TIMEOUT", "0s"},
+		{"MONGODB_READ_PREFERENCE", "secondary_preferred"},
+		{"MONGODB_LIST_READ_PREFERENCE", "replica"},
+		{"MONGODB_WRITE_CONCERN", "0"},
+		{"MONGODB_WRITE_CONCERN", "all"},
+		{"MONGODB_WRITE_JOURNAL", "always"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			_, err := load(env(map[string]string{"JWT_SECRET": "secret", tt.key: tt.value}))
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.key) {
+				t.Errorf("Expected error to name %s, got %v", tt.key, err)
+			}
+		})
+	}
+}
+
+func TestLoad_ReportsEveryProblem(t *testing.T) {
+	_, err := load(env(map[string]string{
+		"PORT":                  "0",
+		"MONGODB_MAX_POOL_SIZE": "10",
+		"MONGODB_MIN_POOL_SIZE": "20",
+	}))
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	for _, key := range []string{"PORT", "JWT_SECRET", "MONGODB_MIN_POOL_SIZE"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("Expected error to name %s, got %v", key, err)
+		}
+	}
+}
+
+func TestLoad_Tenants(t *testing.T) {
+	cfg, err := load(env(map[string]string{"JWT_SECRET": "secret", "TENANTS": "acme, globex:globex_users"}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := []Tenant{{ID: "acme", Database: "acme"}, {ID: "globex", Database: "globex_users"}}
+	if !reflect.DeepEqual(cfg.Tenants, expected) {
+		t.Errorf("Expected %v, got %v", expected, cfg.Tenants)
+	}
+
+	// Settings that assume a single users collection are rejected alongside tenants
+	for key, value := range map[string]string{
+		"STORAGE":             StorageMemory,
+		"USER_EVENTS_ENABLED": "true",
+		"SEED_FILE":           "/etc/app/seed.json",
+	} {
+		t.Run(key, func(t *testing.T) {
+			_, err := load(env(map[string]string{"JWT_SECRET": "secret", "TENANTS": "acme", key: value}))
+			if err == nil || !strings.Contains(err.Error(), "TENANTS") || !strings.Contains(err.Error(), key) {
+				t.Errorf("Expected an error naming TENANTS and %s, got %v", key, err)
+			}
+		})
+	}
+}
+
+func TestLoad_AllowDestructive(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected bool
+	}{
+		{"", false},
+		{"true", true},
+		{"1", false},
+		{"TRUE", false},
+		{"false", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			cfg, err := load(env(map[string]string{"JWT_SECRET": "secret", "ALLOW_DESTRUCTIVE": tt.value}))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if cfg.AllowDestructive != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, cfg.AllowDestructive)
+			}
+		})
+	}
+}
+
+func TestDatabaseName(t *testing.T) {
+	tests := []struct {
+		name       string
+		database   string
+		legacyName string
+		expected   string
+	}{
+		{"Default", "", "", DefaultDatabaseName},
+		{"DATABASE_NAME", "custom_db", "", "custom_db"},
+		{"Legacy MONGODB_DB_NAME", "", "legacy_db", "legacy_db"},
+		{"DATABASE_NAME wins", "custom_db", "legacy_db", "custom_db"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := env(map[string]string{"DATABASE_NAME": tt.database, "MONGODB_DB_NAME": tt.legacyName})
+			if name := databaseName(getenv); name != tt.expected {
+				t.Errorf("Expected '%s', got '%s'", tt.expected, name)
+			}
+		})
+	}
+}
+
+func TestLoad_Environment(t *testing.T) {
+	t.Setenv("JWT_SECRET", "secret")
+	t.Setenv("PORT", "3000")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.Port != "3000" {
+		t.Errorf("Expected port '3000', got '%s'", cfg.Port)
+	}
+}
@@ -2,79 +2,220 @@ package database
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"strconv"
 	"time"
 
+	"go-mongodb-test/config"
+
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 )
 
 const AuthSource = "admin"
 
+// connectAttemptTimeout bounds each individual connect and ping
+const connectAttemptTimeout = 30 * time.Second
+
 type Database struct {
 	Client *mongo.Client
 	DB     *mongo.Database
 }
 
-func NewConnection() (*Database, error) {
-	mongoURI := os.Getenv("MONGODB_URI")
-	if mongoURI == "" {
-		mongoURI = "mongodb://localhost:27017"
+// buildTLSConfig returns the TLS settings for MongoDB, trusting the CA bundle
+// at caFile in addition to the system roots when a path is given
+func buildTLSConfig(caFile string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile == "" {
+		return tlsConfig, nil
+	}
+
+	caCert, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, errors.New("failed to parse CA file: no certificates found")
+	}
+
+	tlsConfig.RootCAs = pool
+	return tlsConfig, nil
+}
+
+// applyPoolOptions sets the connection pool limits that were configured,
+// leaving the driver defaults in place for zero values
+func applyPoolOptions(clientOptions *options.ClientOptions, cfg config.Mongo) {
+	if cfg.MaxPoolSize > 0 {
+		clientOptions.SetMaxPoolSize(cfg.MaxPoolSize)
+	}
+	if cfg.MinPoolSize > 0 {
+		clientOptions.SetMinPoolSize(cfg.MinPoolSize)
+	}
+	if cfg.MaxConnIdleTime > 0 {
+		clientOptions.SetMaxConnIdleTime(cfg.MaxConnIdleTime)
 	}
+}
 
-	dbName := os.Getenv("DATABASE_NAME")
-	if dbName == "" {
-		dbName = "user_management"
+// ReadPreference converts a config.ReadPreferenceModes name into the driver's read preference
+func ReadPreference(mode string) (*readpref.ReadPref, error) {
+	parsed, err := readpref.ModeFromString(mode)
+	if err != nil {
+		return nil, err
 	}
+	return readpref.New(parsed)
+}
 
-	dbUser := os.Getenv("MONGODB_USER")
-	if dbUser == "" {
-		dbUser = "admin"
+// applyConsistencyOptions sets the configured read preference and write concern.
+// Empty values keep what the URI specifies, and the journal flag is added to the
+// URI's write concern rather than replacing it.
+func applyConsistencyOptions(clientOptions *options.ClientOptions, cfg config.Mongo) error {
+	if cfg.ReadPreference != "" {
+		rp, err := ReadPreference(cfg.ReadPreference)
+		if err != nil {
+			return err
+		}
+		clientOptions.SetReadPreference(rp)
 	}
 
-	dbPassword := os.Getenv("MONGODB_PASSWORD")
-	if dbPassword == "" {
-		dbPassword = "password"
+	if cfg.WriteConcern == "" && !cfg.WriteJournal {
+		return nil
 	}
 
-	credential := options.Credential{
-		Username:   dbUser,
-		Password:   dbPassword,
-		AuthSource: AuthSource,
+	wc := &writeconcern.WriteConcern{}
+	if clientOptions.WriteConcern != nil {
+		*wc = *clientOptions.WriteConcern
 	}
+	if cfg.WriteConcern == "majority" {
+		wc.W = "majority"
+	} else if cfg.WriteConcern != "" {
+		nodes, err := strconv.Atoi(cfg.WriteConcern)
+		if err != nil {
+			return fmt.Errorf("invalid write concern %q: %w", cfg.WriteConcern, err)
+		}
+		wc.W = nodes
+	}
+	if cfg.WriteJournal {
+		journal := true
+		wc.Journal = &journal
+	}
+	clientOptions.SetWriteConcern(wc)
+	return nil
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+// connect opens a client and pings it, disconnecting again if the ping fails
+func connect(clientOptions *options.ClientOptions) (*mongo.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), connectAttemptTimeout)
 	defer cancel()
-  
-	client, err := mongo.Connect(
-		ctx,
-		options.Client().
-			ApplyURI(mongoURI).
-			SetAuth(credential),
-	)
-  
+
+	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrConnectFailed, err)
 	}
 
 	if err := client.Ping(ctx, nil); err != nil {
-		return nil, fmt.Errorf("failed to ping MongoDB: %w", err)
+		_ = client.Disconnect(context.Background())
+		return nil, fmt.Errorf("%w: %w", ErrPingFailed, err)
+	}
+
+	return client, nil
+}
+
+// connectWithRetry makes up to attempts connection attempts, waiting backoff
+// before the first retry and doubling the wait after each failure
+func connectWithRetry(clientOptions *options.ClientOptions, attempts int, backoff time.Duration) (*mongo.Client, error) {
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		client, err := connect(clientOptions)
+		if err == nil {
+			return client, nil
+		}
+		lastErr = err
+
+		if attempt < attempts {
+			slog.Warn("MongoDB connection attempt failed, retrying",
+				"attempt", attempt, "attempts", attempts, "retry_in", backoff, "error", err)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	if attempts > 1 {
+		return nil, fmt.Errorf("giving up after %d attempts: %w", attempts, lastErr)
+	}
+	return nil, lastErr
+}
+
+// NewConnection connects to the MongoDB deployment at cfg.URI and selects cfg.Database
+func NewConnection(cfg config.Mongo) (*Database, error) {
+	clientOptions := options.Client().ApplyURI(cfg.URI)
+
+	// Only set credentials explicitly when provided; the URI may already embed them
+	if cfg.User != "" {
+		clientOptions.SetAuth(options.Credential{
+			Username:   cfg.User,
+			Password:   cfg.Password,
+			AuthSource: AuthSource,
+		})
+	}
+
+	applyPoolOptions(clientOptions, cfg)
+	if err := applyConsistencyOptions(clientOptions, cfg); err != nil {
+		return nil, err
 	}
 
-	log.Printf("Connected to MongoDB at %s", mongoURI)
+	if cfg.TLS {
+		tlsConfig, err := buildTLSConfig(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		clientOptions.SetTLSConfig(tlsConfig)
+	}
+
+	retries := cfg.ConnectRetries
+	if retries < 1 {
+		retries = config.DefaultConnectRetries
+	}
+	backoff := cfg.ConnectBackoff
+	if backoff <= 0 {
+		backoff = config.DefaultConnectBackoff
+	}
+
+	client, err := connectWithRetry(clientOptions, retries, backoff)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Connected to MongoDB", "uri", cfg.URI, "database", cfg.Database)
 
 	return &Database{
 		Client: client,
-		DB:     client.Database(dbName),
+		DB:     client.Database(cfg.Database),
 	}, nil
 }
 
+// Ping verifies that MongoDB is reachable
+func (d *Database) Ping(ctx context.Context) error {
+	if d.Client == nil {
+		return ErrNilClient
+	}
+	return d.Client.Ping(ctx, nil)
+}
+
 func (d *Database) Close() error {
 	if d.Client == nil {
-		return errors.New("client is nil")
+		return ErrNilClient
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
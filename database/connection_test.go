@@ -1,9 +1,12 @@
 package database
 
 import (
-	"os"
+	"context"
+	"errors"
 	"testing"
 	"time"
+
+	"go-mongodb-test/config"
 )
 
 func TestDatabase_Struct(t *testing.T) {
@@ -18,50 +21,18 @@ func TestDatabase_Struct(t *testing.T) {
 	}
 }
 
-func TestNewConnection_Environment(t *testing.T) {
-	// Test with default values (no environment variables set)
-	originalURI := os.Getenv("MONGODB_URI")
-	originalDBName := os.Getenv("DATABASE_NAME")
-
-	// Clear environment variables
-	os.Unsetenv("MONGODB_URI")
-	os.Unsetenv("DATABASE_NAME")
-
-	// Note: This test will fail if MongoDB is not running locally
-	// but we can test the environment variable handling
-	defer func() {
-		// Restore original environment variables
-		if originalURI != "" {
-			os.Setenv("MONGODB_URI", originalURI)
-		}
-		if originalDBName != "" {
-			os.Setenv("DATABASE_NAME", originalDBName)
-		}
-	}()
-
-	// Test environment variable handling by mocking the connection creation
-	// We can't actually connect without a MongoDB instance, but we can test
-	// that the function properly reads environment variables
-
-	// Set custom environment variables
-	customURI := "mongodb://testhost:27017"
-	customDBName := "testdb"
-
-	os.Setenv("MONGODB_URI", customURI)
-	os.Setenv("DATABASE_NAME", customDBName)
-
-	// The actual connection will fail, but we can test that it reads env vars correctly
-	// by checking if the function attempts to use the custom URI
-	_, err := NewConnection()
+func TestNewConnection_Config(t *testing.T) {
+	// We can't actually connect without a MongoDB instance, but we can check
+	// that the configured URI is the one used
+	_, err := NewConnection(config.Mongo{URI: "mongodb://testhost:27017", Database: "testdb"})
 	if err == nil {
 		t.Error("Expected connection to fail without MongoDB instance")
 	}
 
 	// Test with invalid URI format
-	os.Setenv("MONGODB_URI", "invalid-uri")
-	_, err = NewConnection()
-	if err == nil {
-		t.Error("Expected connection to fail with invalid URI")
+	_, err = NewConnection(config.Mongo{URI: "invalid-uri", Database: "testdb"})
+	if !errors.Is(err, ErrConnectFailed) {
+		t.Errorf("Expected ErrConnectFailed for an invalid URI, got %v", err)
 	}
 }
 
@@ -74,8 +45,16 @@ func TestDatabase_Close(t *testing.T) {
 		t.Error("Expected error when closing database with nil client, but got nil")
 	}
 
-	if err != nil && err.Error() != "client is nil" {
-		t.Errorf("Expected error message 'client is nil', got '%s'", err.Error())
+	if !errors.Is(err, ErrNilClient) {
+		t.Errorf("Expected ErrNilClient, got %v", err)
+	}
+}
+
+func TestDatabase_Ping(t *testing.T) {
+	db := &Database{}
+
+	if err := db.Ping(context.Background()); !errors.Is(err, ErrNilClient) {
+		t.Errorf("Expected ErrNilClient, got %v", err)
 	}
 }
 
@@ -84,17 +63,15 @@ func TestNewConnection_Timeout(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping timeout test in short mode")
 	}
-	
-	// Temporarily change environment to point to non-existent MongoDB
-	oldURI := os.Getenv("MONGODB_URI")
-	defer os.Setenv("MONGODB_URI", oldURI)
-	os.Setenv("MONGODB_URI", "mongodb://nonexistent:27017")
+
+	// Point at a MongoDB instance that does not exist
+	cfg := config.Mongo{URI: "mongodb://nonexistent:27017", Database: "testdb"}
 
 	// Test that the function uses proper timeout
 	start := time.Now()
 
 	// This will timeout since we don't have a MongoDB instance at nonexistent host
-	_, err := NewConnection()
+	_, err := NewConnection(cfg)
 
 	elapsed := time.Since(start)
 
@@ -113,11 +90,9 @@ func TestNewConnection_ContextHandling(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping context handling test in short mode")
 	}
-	
-	// Temporarily change environment to point to non-existent MongoDB
-	oldURI := os.Getenv("MONGODB_URI")
-	defer os.Setenv("MONGODB_URI", oldURI)
-	os.Setenv("MONGODB_URI", "mongodb://nonexistent:27017")
+
+	// Point at a MongoDB instance that does not exist
+	cfg := config.Mongo{URI: "mongodb://nonexistent:27017", Database: "testdb"}
 
 	// Test that context cancellation works properly
 
@@ -126,7 +101,7 @@ func TestNewConnection_ContextHandling(t *testing.T) {
 
 	done := make(chan bool, 1)
 	go func() {
-		_, err := NewConnection()
+		_, err := NewConnection(cfg)
 		if err == nil {
 			t.Error("Expected connection to fail to nonexistent MongoDB instance")
 		}
@@ -140,39 +115,3 @@ func TestNewConnection_ContextHandling(t *testing.T) {
 		t.Error("NewConnection took too long, context timeout might not be working")
 	}
 }
-
-func TestNewConnection_DefaultValues(t *testing.T) {
-	// Clear environment variables to test defaults
-	originalURI := os.Getenv("MONGODB_URI")
-	originalDBName := os.Getenv("DATABASE_NAME")
-
-	os.Unsetenv("MONGODB_URI")
-	os.Unsetenv("DATABASE_NAME")
-
-	defer func() {
-		// Restore original environment variables
-		if originalURI != "" {
-			os.Setenv("MONGODB_URI", originalURI)
-		} else {
-			os.Unsetenv("MONGODB_URI")
-		}
-		if originalDBName != "" {
-			os.Setenv("DATABASE_NAME", originalDBName)
-		} else {
-			os.Unsetenv("DATABASE_NAME")
-		}
-	}()
-	t.Setenv("MONGODB_URI", "mongodb://test:27017")
-	// The function should use default values when env vars are not set
-	// We can't test the actual connection, but we can verify the function
-	// attempts to use the default values by checking the error message
-	_, err := NewConnection()
-	if err == nil {
-		t.Error("Expected connection to fail without MongoDB instance")
-	}
-
-	// The error should indicate it tried to connect to localhost:27017 (default)
-	if err != nil && err.Error() == "" {
-		t.Error("Expected non-empty error message")
-	}
-}
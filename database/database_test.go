@@ -2,147 +2,234 @@ package database
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"go-mongodb-test/config"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
-func TestConnectAndDisconnect(t *testing.T) {
-	// Only run if MONGODB_URI is set
+func TestNewConnection_DatabaseName(t *testing.T) {
 	mongoURI := os.Getenv("MONGODB_URI")
 	if mongoURI == "" {
-		mongoURI = "mongodb://localhost:27017"
+		mongoURI = config.DefaultMongoURI
 	}
 
-	// Set environment variables for testing
-	os.Setenv("MONGODB_URI", mongoURI)
-	os.Setenv("MONGODB_DB_NAME", "testdb")
-
-	// Test successful connection
-	db, err := Connect()
+	db, err := NewConnection(config.Mongo{URI: mongoURI, Database: "testdb"})
 	if err != nil {
 		t.Skipf("Skipping test due to MongoDB connection error: %v", err)
-		return
 	}
+	defer db.Close()
 
-	// Check that the database is not nil
-	if db == nil {
-		t.Error("Expected database to be non-nil")
+	if db.DB.Name() != "testdb" {
+		t.Errorf("Expected database name to be 'testdb', got '%s'", db.DB.Name())
 	}
+}
 
-	// Test the DB name
-	if db.Name() != "testdb" {
-		t.Errorf("Expected database name to be 'testdb', got '%s'", db.Name())
-	}
+func TestBuildTLSConfig(t *testing.T) {
+	t.Run("Without CA file", func(t *testing.T) {
+		tlsConfig, err := buildTLSConfig("")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if tlsConfig.RootCAs != nil {
+			t.Error("Expected system roots to be used when no CA file is given")
+		}
+
+		if tlsConfig.MinVersion != tls.VersionTLS12 {
+			t.Errorf("Expected minimum TLS 1.2, got %x", tlsConfig.MinVersion)
+		}
+	})
+
+	t.Run("Missing CA file", func(t *testing.T) {
+		_, err := buildTLSConfig(filepath.Join(t.TempDir(), "missing.pem"))
+		if err == nil {
+			t.Error("Expected error for missing CA file")
+		}
+	})
+
+	t.Run("Invalid CA file", func(t *testing.T) {
+		caFile := filepath.Join(t.TempDir(), "invalid.pem")
+		if err := os.WriteFile(caFile, []byte("not a certificate"), 0o600); err != nil {
+			t.Fatalf("Failed to write CA file: %v", err)
+		}
+
+		_, err := buildTLSConfig(caFile)
+		if err == nil {
+			t.Error("Expected error for CA file without certificates")
+		}
+	})
+
+	t.Run("Valid CA file", func(t *testing.T) {
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		if err := os.WriteFile(caFile, generateTestCACert(t), 0o600); err != nil {
+			t.Fatalf("Failed to write CA file: %v", err)
+		}
+
+		tlsConfig, err := buildTLSConfig(caFile)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if tlsConfig.RootCAs == nil {
+			t.Error("Expected RootCAs to include the CA file")
+		}
+	})
+}
 
-	// Test collection method
-	coll := db.Collection("users")
-	if coll == nil {
-		t.Error("Expected collection to be non-nil")
-	}
+// generateTestCACert returns a PEM encoded self-signed certificate
+func generateTestCACert(t *testing.T) []byte {
+	t.Helper()
 
-	// Test close function
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	
-	err = Close(ctx)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
-		t.Errorf("Expected no error when closing connection, got %v", err)
+		t.Fatalf("Failed to generate key: %v", err)
 	}
 
-	// Test GetDB function
-	dbInstance := GetDB()
-	if dbInstance == nil {
-		t.Error("Expected database instance to be available")
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
 	}
 
-	// Test closing when no client is connected
-	client = nil // Reset client
-	err = Close(ctx)
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
 	if err != nil {
-		t.Errorf("Expected no error when closing with nil client, got %v", err)
+		t.Fatalf("Failed to create certificate: %v", err)
 	}
-}
 
-func TestGetEnvWithDefault(t *testing.T) {
-	// Test with existing environment variable
-	testVarName := "TEST_ENV_VAR"
-	os.Setenv(testVarName, "test_value")
-	
-	value := getEnvWithDefault(testVarName, "default_value")
-	if value != "test_value" {
-		t.Errorf("Expected '%s', got '%s'", "test_value", value)
-	}
-	
-	// Test with non-existing environment variable
-	nonExistingVarName := "NON_EXISTING_TEST_ENV_VAR"
-	os.Unsetenv(nonExistingVarName)
-	
-	value = getEnvWithDefault(nonExistingVarName, "default_value")
-	if value != "default_value" {
-		t.Errorf("Expected '%s', got '%s'", "default_value", value)
-	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
 }
 
-func TestGetMongoURI(t *testing.T) {
-	// Test with MONGODB_URI set
-	os.Setenv("MONGODB_URI", "mongodb://customhost:27017")
-	
-	uri := getMongoURI()
-	if uri != "mongodb://customhost:27017" {
-		t.Errorf("Expected '%s', got '%s'", "mongodb://customhost:27017", uri)
-	}
-	
-	// Test with MONGODB_URI unset
-	os.Unsetenv("MONGODB_URI")
-	
-	uri = getMongoURI()
-	if uri != "mongodb://localhost:27017" {
-		t.Errorf("Expected '%s', got '%s'", "mongodb://localhost:27017", uri)
-	}
+func TestApplyPoolOptions(t *testing.T) {
+	t.Run("Configured values", func(t *testing.T) {
+		clientOptions := options.Client()
+		applyPoolOptions(clientOptions, config.Mongo{
+			MaxPoolSize:     50,
+			MinPoolSize:     5,
+			MaxConnIdleTime: 2 * time.Minute,
+		})
+
+		if clientOptions.MaxPoolSize == nil || *clientOptions.MaxPoolSize != 50 {
+			t.Errorf("Expected MaxPoolSize 50, got %v", clientOptions.MaxPoolSize)
+		}
+		if clientOptions.MinPoolSize == nil || *clientOptions.MinPoolSize != 5 {
+			t.Errorf("Expected MinPoolSize 5, got %v", clientOptions.MinPoolSize)
+		}
+		if clientOptions.MaxConnIdleTime == nil || *clientOptions.MaxConnIdleTime != 2*time.Minute {
+			t.Errorf("Expected MaxConnIdleTime 2m, got %v", clientOptions.MaxConnIdleTime)
+		}
+	})
+
+	t.Run("Zero values keep driver defaults", func(t *testing.T) {
+		clientOptions := options.Client()
+		applyPoolOptions(clientOptions, config.Mongo{})
+
+		if clientOptions.MaxPoolSize != nil {
+			t.Errorf("Expected MaxPoolSize to be unset, got %d", *clientOptions.MaxPoolSize)
+		}
+		if clientOptions.MinPoolSize != nil {
+			t.Errorf("Expected MinPoolSize to be unset, got %d", *clientOptions.MinPoolSize)
+		}
+		if clientOptions.MaxConnIdleTime != nil {
+			t.Errorf("Expected MaxConnIdleTime to be unset, got %v", *clientOptions.MaxConnIdleTime)
+		}
+	})
 }
 
-func TestGetDatabaseName(t *testing.T) {
-	// Test with MONGODB_DB_NAME set
-	os.Setenv("MONGODB_DB_NAME", "custom_db")
-	
-	name := getDatabaseName()
-	if name != "custom_db" {
-		t.Errorf("Expected '%s', got '%s'", "custom_db", name)
-	}
-	
-	// Test with MONGODB_DB_NAME unset
-	os.Unsetenv("MONGODB_DB_NAME")
-	
-	name = getDatabaseName()
-	if name != "godb" {
-		t.Errorf("Expected '%s', got '%s'", "godb", name)
-	}
+func TestApplyConsistencyOptions(t *testing.T) {
+	t.Run("Configured values", func(t *testing.T) {
+		clientOptions := options.Client()
+		err := applyConsistencyOptions(clientOptions, config.Mongo{
+			ReadPreference: "secondaryPreferred",
+			WriteConcern:   "majority",
+			WriteJournal:   true,
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if clientOptions.ReadPreference == nil || clientOptions.ReadPreference.Mode() != readpref.SecondaryPreferredMode {
+			t.Errorf("Expected secondaryPreferred, got %v", clientOptions.ReadPreference)
+		}
+		wc := clientOptions.WriteConcern
+		if wc == nil || wc.W != "majority" || wc.Journal == nil || !*wc.Journal {
+			t.Errorf("Expected w=majority with journaling, got %+v", wc)
+		}
+	})
+
+	t.Run("Journal keeps the URI write concern", func(t *testing.T) {
+		clientOptions := options.Client().ApplyURI("mongodb://localhost:27017/?w=2")
+		if err := applyConsistencyOptions(clientOptions, config.Mongo{WriteJournal: true}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		wc := clientOptions.WriteConcern
+		if wc == nil || wc.W != 2 || wc.Journal == nil || !*wc.Journal {
+			t.Errorf("Expected w=2 with journaling, got %+v", wc)
+		}
+	})
+
+	t.Run("Empty values keep the URI settings", func(t *testing.T) {
+		clientOptions := options.Client().ApplyURI("mongodb://localhost:27017/?readPreference=nearest")
+		if err := applyConsistencyOptions(clientOptions, config.Mongo{}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if clientOptions.ReadPreference == nil || clientOptions.ReadPreference.Mode() != readpref.NearestMode {
+			t.Errorf("Expected the URI read preference, got %v", clientOptions.ReadPreference)
+		}
+		if clientOptions.WriteConcern != nil {
+			t.Errorf("Expected no write concern, got %+v", clientOptions.WriteConcern)
+		}
+	})
+
+	t.Run("Unknown read preference", func(t *testing.T) {
+		if err := applyConsistencyOptions(options.Client(), config.Mongo{ReadPreference: "fastest"}); err == nil {
+			t.Error("Expected an error")
+		}
+	})
 }
 
-// TestCloseWithoutConnection tests closing when no connection exists
-func TestCloseWithoutConnection(t *testing.T) {
-	// Reset the global client to nil
-	originalClient := client
-	client = nil
-	defer func() { client = originalClient }()
+func TestConnectWithRetry_GivesUp(t *testing.T) {
+	clientOptions := options.Client().
+		ApplyURI("mongodb://127.0.0.1:1").
+		SetServerSelectionTimeout(50 * time.Millisecond)
 
-	ctx := context.Background()
-	err := Close(ctx)
-	if err != nil {
-		t.Errorf("Expected no error when closing with nil client, got %v", err)
+	start := time.Now()
+	client, err := connectWithRetry(clientOptions, 3, 10*time.Millisecond)
+	if err == nil {
+		client.Disconnect(context.Background())
+		t.Fatal("Expected error connecting to unreachable server")
 	}
-}
 
-// TestGetDBWithoutConnection tests GetDB when no connection exists  
-func TestGetDBWithoutConnection(t *testing.T) {
-	// Reset the global db to nil
-	originalDB := db
-	db = nil
-	defer func() { db = originalDB }()
+	if !strings.Contains(err.Error(), "giving up after 3 attempts") {
+		t.Errorf("Expected retry count in error, got %v", err)
+	}
+	if !errors.Is(err, ErrPingFailed) {
+		t.Errorf("Expected the last attempt's ErrPingFailed, got %v", err)
+	}
 
-	result := GetDB()
-	if result != nil {
-		t.Error("Expected nil database when no connection exists")
+	// Two backoffs of 10ms and 20ms must have elapsed between the three attempts
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("Expected backoff between attempts, finished in %v", elapsed)
 	}
-}
\ No newline at end of file
+}
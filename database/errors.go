@@ -0,0 +1,12 @@
+package database
+
+import "errors"
+
+var (
+	// ErrNilClient is returned by methods called on a Database without a client
+	ErrNilClient = errors.New("client is nil")
+	// ErrConnectFailed wraps the driver error when a client cannot be created
+	ErrConnectFailed = errors.New("failed to connect to MongoDB")
+	// ErrPingFailed wraps the driver error when a new client cannot reach the deployment
+	ErrPingFailed = errors.New("failed to ping MongoDB")
+)
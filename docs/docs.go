@@ -0,0 +1,32 @@
+// Code generated by swaggo/swag. DO NOT EDIT.
+
+package docs
+
+import "github.com/swaggo/swag/v2"
+
+const docTemplate = `{
+    "schemes": {{ marshal .Schemes }},
+    "components": {"schemas":{"handlers.AdminResetPasswordResponse":{"properties":{"message":{"example":"Password has been reset","type":"string"},"password":{"example":"Zk9yZ290UGFzc3dvcmRUb2tlbkV4YW1wbGUxMjM0NTY","type":"string"}},"type":"object"},"handlers.AuditLogResponse":{"properties":{"count":{"example":1,"type":"integer"},"events":{"items":{"$ref":"#/components/schemas/models.AuditEvent"},"type":"array","uniqueItems":false},"has_more":{"example":false,"type":"boolean"},"limit":{"example":50,"type":"integer"},"offset":{"example":0,"type":"integer"}},"type":"object"},"handlers.AvailabilityResponse":{"properties":{"available":{"example":true,"type":"boolean"}},"type":"object"},"handlers.CountResponse":{"properties":{"count":{"example":42,"type":"integer"}},"type":"object"},"handlers.DeleteAllResponse":{"properties":{"deleted_count":{"example":42,"type":"integer"}},"type":"object"},"handlers.ErrorResponse":{"properties":{"error":{"example":"User not found","type":"string"},"request_id":{"example":"3f2a8c1e-9d4b-4f6a-8e2d-1b7c5a9e0f13","type":"string"}},"type":"object"},"handlers.ImportRowResult":{"properties":{"error":{"example":"user with this user_id already exists","type":"string"},"id":{"example":"665f1c2e8b3a4d2f9c1e7a10","type":"string"},"row":{"example":2,"type":"integer"},"user_id":{"example":"alice","type":"string"}},"type":"object"},"handlers.ImportUsersResponse":{"properties":{"created":{"items":{"$ref":"#/components/schemas/handlers.ImportRowResult"},"type":"array","uniqueItems":false},"failed":{"items":{"$ref":"#/components/schemas/handlers.ImportRowResult"},"type":"array","uniqueItems":false},"skipped":{"items":{"$ref":"#/components/schemas/handlers.ImportRowResult"},"type":"array","uniqueItems":false}},"type":"object"},"handlers.InactiveUsersResponse":{"properties":{"count":{"example":1,"type":"integer"},"cutoff":{"example":"2024-05-05T12:00:00Z","type":"string"},"has_more":{"example":false,"type":"boolean"},"limit":{"example":50,"type":"integer"},"offset":{"example":0,"type":"integer"},"users":{"items":{"$ref":"#/components/schemas/models.UserResponse"},"type":"array","uniqueItems":false}},"type":"object"},"handlers.MaintenanceResponse":{"properties":{"enabled":{"example":true,"type":"boolean"}},"type":"object"},"handlers.MessageResponse":{"properties":{"message":{"example":"User deleted successfully","type":"string"}},"type":"object"},"handlers.RevokeSessionsResponse":{"properties":{"revoked_count":{"example":2,"type":"integer"}},"type":"object"},"handlers.SessionListResponse":{"properties":{"count":{"example":1,"type":"integer"},"sessions":{"items":{"$ref":"#/components/schemas/models.Session"},"type":"array","uniqueItems":false}},"type":"object"},"handlers.UserListResponse":{"properties":{"count":{"example":1,"type":"integer"},"users":{"items":{"$ref":"#/components/schemas/models.UserResponse"},"type":"array","uniqueItems":false}},"type":"object"},"handlers.UserPageResponse":{"properties":{"count":{"example":1,"type":"integer"},"has_more":{"example":true,"type":"boolean"},"limit":{"example":50,"type":"integer"},"offset":{"example":0,"type":"integer"},"total":{"example":120,"type":"integer"},"users":{"items":{"$ref":"#/components/schemas/models.UserResponse"},"type":"array","uniqueItems":false}},"type":"object"},"handlers.UserWithAgeResponse":{"properties":{"age":{"description":"Age is the whole number of seconds since created_at","example":86400,"type":"integer"},"avatar_url":{"example":"https://example.com/avatars/alice.png","type":"string"},"created_at":{"example":"2024-06-04T12:00:00Z","type":"string"},"deleted_at":{"example":"2024-06-05T08:30:00Z","type":"string"},"email":{"example":"alice@example.com","type":"string"},"email_verified":{"example":false,"type":"boolean"},"first_name":{"example":"Alice","type":"string"},"id":{"example":"665f1c2e8b3a4d2f9c1e7a10","type":"string"},"last_login_at":{"example":"2024-06-04T12:00:00Z","type":"string"},"last_name":{"example":"Liddell","type":"string"},"roles":{"example":["user"],"items":{"type":"string"},"type":"array","uniqueItems":false},"status":{"enum":["active","disabled"],"example":"active","type":"string"},"two_factor_enabled":{"example":false,"type":"boolean"},"updated_at":{"example":"2024-06-04T12:00:00Z","type":"string"},"user_id":{"example":"alice","type":"string"}},"type":"object"},"handlers.ValidationErrorResponse":{"properties":{"details":{"items":{"$ref":"#/components/schemas/models.FieldError"},"type":"array","uniqueItems":false},"error":{"example":"Validation failed","type":"string"},"errors":{"additionalProperties":{"type":"string"},"example":{"email":"must be a valid email address","password":"must be at least 6 characters"},"type":"object"},"request_id":{"example":"3f2a8c1e-9d4b-4f6a-8e2d-1b7c5a9e0f13","type":"string"}},"type":"object"},"models.AdminResetPasswordRequest":{"properties":{"new_password":{"example":"n3wpassword","minLength":6,"type":"string"}},"type":"object"},"models.AuditEvent":{"properties":{"action":{"enum":["create","update","delete"],"example":"update","type":"string"},"actor_id":{"description":"ActorID and ActorUserID name the authenticated user who made the change; they\nare empty for signups and other unauthenticated requests","example":"665f1c2e8b3a4d2f9c1e7a0f","type":"string"},"actor_user_id":{"example":"admin","type":"string"},"created_at":{"example":"2024-06-01T12:00:00Z","type":"string"},"fields":{"example":["email","password"],"items":{"type":"string"},"type":"array","uniqueItems":false},"id":{"example":"665f1c2e8b3a4d2f9c1e7a11","type":"string"},"target_id":{"description":"TargetID is the MongoDB ID of the user that changed","example":"665f1c2e8b3a4d2f9c1e7a10","type":"string"}},"type":"object"},"models.CreateUserRequest":{"properties":{"avatar_url":{"example":"https://example.com/avatars/alice.png","maxLength":2048,"type":"string"},"email":{"example":"alice@example.com","type":"string"},"first_name":{"example":"Alice","maxLength":100,"type":"string"},"last_name":{"example":"Liddell","maxLength":100,"type":"string"},"password":{"example":"s3cretpass","minLength":6,"type":"string"},"user_id":{"example":"alice","type":"string"}},"required":["email","password","user_id"],"type":"object"},"models.DomainCount":{"properties":{"count":{"example":30,"type":"integer"},"domain":{"example":"example.com","type":"string"}},"type":"object"},"models.FieldError":{"properties":{"field":{"example":"email","type":"string"},"message":{"example":"must be a valid email address","type":"string"}},"type":"object"},"models.ForgotPasswordRequest":{"properties":{"email":{"example":"alice@example.com","type":"string"}},"required":["email"],"type":"object"},"models.IndexInfo":{"properties":{"keys":{"items":{"$ref":"#/components/schemas/models.IndexKey"},"type":"array","uniqueItems":false},"name":{"example":"user_id_1","type":"string"},"sparse":{"example":false,"type":"boolean"},"unique":{"example":true,"type":"boolean"}},"type":"object"},"models.IndexKey":{"properties":{"field":{"example":"user_id","type":"string"},"order":{"example":1,"type":"integer"}},"type":"object"},"models.LoginRequest":{"properties":{"email":{"example":"alice@example.com","type":"string"},"identifier":{"description":"Identifier is matched against both user_id and email, for clients that let\nusers type either into one field","example":"alice@example.com","type":"string"},"password":{"example":"s3cretpass","type":"string"},"totp_code":{"description":"TOTPCode is required once the account has two-factor authentication enabled","example":"123456","type":"string"},"user_id":{"example":"alice","type":"string"}},"required":["password"],"type":"object"},"models.LoginResponse":{"properties":{"refresh_token":{"example":"cmVmcmVzaFRva2VuRXhhbXBsZUFiY2RlZmdoaWprbG1u","type":"string"},"token":{"example":"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...","type":"string"},"user":{"$ref":"#/components/schemas/models.UserResponse"}},"type":"object"},"models.MaintenanceRequest":{"properties":{"enabled":{"example":true,"type":"boolean"}},"required":["enabled"],"type":"object"},"models.RefreshTokenRequest":{"properties":{"refresh_token":{"example":"cmVmcmVzaFRva2VuRXhhbXBsZUFiY2RlZmdoaWprbG1u","type":"string"}},"required":["refresh_token"],"type":"object"},"models.ReplaceUserRequest":{"properties":{"avatar_url":{"example":"https://example.com/avatars/alice.png","maxLength":2048,"type":"string"},"email":{"example":"alice@example.com","type":"string"},"first_name":{"example":"Alice","maxLength":100,"type":"string"},"last_name":{"example":"Liddell","maxLength":100,"type":"string"},"password":{"example":"s3cretpass","minLength":6,"type":"string"},"user_id":{"example":"alice","type":"string"}},"required":["email","password","user_id"],"type":"object"},"models.ResetPasswordRequest":{"properties":{"new_password":{"example":"n3wpassword","minLength":6,"type":"string"},"token":{"example":"Zk9yZ290UGFzc3dvcmRUb2tlbkV4YW1wbGUxMjM0NTY","type":"string"}},"required":["new_password","token"],"type":"object"},"models.Session":{"properties":{"created_at":{"example":"2024-06-01T12:00:00Z","type":"string"},"current":{"description":"Current marks the session of the access token making the request","example":true,"type":"boolean"},"expires_at":{"example":"2024-07-03T08:30:00Z","type":"string"},"id":{"example":"665f1c2e8b3a4d2f9c1e7a10","type":"string"},"ip":{"example":"203.0.113.7","type":"string"},"last_used_at":{"example":"2024-06-03T08:30:00Z","type":"string"},"user_agent":{"example":"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_5)","type":"string"}},"type":"object"},"models.SetRolesRequest":{"properties":{"roles":{"example":["user","admin"],"items":{"type":"string"},"minItems":1,"type":"array","uniqueItems":false}},"required":["roles"],"type":"object"},"models.TwoFactorCodeRequest":{"properties":{"code":{"example":"123456","type":"string"}},"required":["code"],"type":"object"},"models.TwoFactorSetup":{"properties":{"provisioning_uri":{"example":"otpauth://totp/User%20Management%20API:alice?issuer=User%20Management%20API\u0026secret=JBSWY3DPEHPK3PXP","type":"string"},"secret":{"example":"JBSWY3DPEHPK3PXP","type":"string"}},"type":"object"},"models.UpdateUserRequest":{"properties":{"avatar_url":{"example":"https://example.com/avatars/alice.png","maxLength":2048,"type":"string"},"email":{"example":"alice@example.com","type":"string"},"first_name":{"description":"An empty string clears a profile field, which is why avatar_url also accepts \"\"","example":"Alice","maxLength":100,"type":"string"},"last_name":{"example":"Liddell","maxLength":100,"type":"string"},"password":{"example":"n3wpassword","minLength":6,"type":"string"},"user_id":{"example":"alice","minLength":1,"type":"string"}},"type":"object"},"models.UserResponse":{"properties":{"avatar_url":{"example":"https://example.com/avatars/alice.png","type":"string"},"created_at":{"example":"2024-06-04T12:00:00Z","type":"string"},"deleted_at":{"example":"2024-06-05T08:30:00Z","type":"string"},"email":{"example":"alice@example.com","type":"string"},"email_verified":{"example":false,"type":"boolean"},"first_name":{"example":"Alice","type":"string"},"id":{"example":"665f1c2e8b3a4d2f9c1e7a10","type":"string"},"last_login_at":{"example":"2024-06-04T12:00:00Z","type":"string"},"last_name":{"example":"Liddell","type":"string"},"roles":{"example":["user"],"items":{"type":"string"},"type":"array","uniqueItems":false},"status":{"enum":["active","disabled"],"example":"active","type":"string"},"two_factor_enabled":{"example":false,"type":"boolean"},"updated_at":{"example":"2024-06-04T12:00:00Z","type":"string"},"user_id":{"example":"alice","type":"string"}},"type":"object"},"models.UserStats":{"properties":{"created_this_week":{"example":12,"type":"integer"},"created_today":{"description":"CreatedToday and CreatedThisWeek count from midnight UTC, and from Monday for the week","example":3,"type":"integer"},"domains":{"description":"Domains lists the email domains with the most users, largest first","items":{"$ref":"#/components/schemas/models.DomainCount"},"type":"array","uniqueItems":false},"generated_at":{"description":"GeneratedAt is when the numbers were computed; they are cached for a short while","example":"2024-06-04T12:00:00Z","type":"string"},"total":{"example":42,"type":"integer"}},"type":"object"}},"securitySchemes":{"apikeyauth":{"description":"Service key from API_KEYS, accepted by the admin user routes; read keys may only GET","in":"header","name":"X-API-Key","type":"apiKey"},"bearerauth":{"bearerFormat":"JWT","scheme":"bearer","type":"http"}}},
+    "info": {"description":"{{escape .Description}}","title":"{{.Title}}","version":"{{.Version}}"},
+    "externalDocs": {"description":"","url":""},
+    "paths": {"/admin/indexes":{"get":{"description":"Returns each index with its name, key spec in field order and unique and sparse options, as the server reports them.\nUse it to confirm that the unique user_id and email indexes exist.","responses":{"200":{"content":{"application/json":{"schema":{"items":{"$ref":"#/components/schemas/models.IndexInfo"},"type":"array"}}},"description":"OK"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Forbidden"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not available with STORAGE=memory"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"security":[{"bearerauth":[]}],"summary":"List users collection indexes","tags":["admin"]}},"/admin/maintenance":{"post":{"description":"While maintenance mode is on, every request other than GET, HEAD and OPTIONS is answered with 503, so reads and health checks keep working while writes are held off.\nLogging in and this endpoint stay available so that an admin can always turn it off again. The switch starts at MAINTENANCE_MODE and is kept per instance, so set it on each one.","requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.MaintenanceRequest"}}},"description":"Whether maintenance mode is on","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.MaintenanceResponse"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ValidationErrorResponse"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Forbidden"}},"security":[{"bearerauth":[]}],"summary":"Turn maintenance mode on or off (admin)","tags":["admin"]}},"/admin/users/{id}/reset-password":{"post":{"description":"Sets new_password, or generates a random password when the body leaves it out and returns it in this response only.\nThe user's refresh tokens are revoked and any login lockout is cleared.","parameters":[{"description":"MongoDB ObjectID","example":"665f1c2e8b3a4d2f9c1e7a10","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.AdminResetPasswordRequest"}}},"description":"New password; omit to generate one"},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.AdminResetPasswordResponse"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ValidationErrorResponse"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not available with STORAGE=memory"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"security":[{"bearerauth":[]}],"summary":"Reset a user's password (admin)","tags":["admin"]}},"/auth/forgot-password":{"post":{"description":"Sends a single-use reset token to the address if it belongs to an account.\nThe response is the same whether or not the account exists.","requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.ForgotPasswordRequest"}}},"description":"Account email","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.MessageResponse"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ValidationErrorResponse"}}},"description":"Bad Request"},"429":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Too Many Requests"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not available with STORAGE=memory"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"summary":"Request a password reset","tags":["auth"]}},"/auth/login":{"post":{"description":"Authenticates with either user_id or email plus password and returns a signed access token and a refresh token.\nidentifier accepts either one, so a single login field works for both; failures look the same whichever field matched.\nExchange the refresh token at /auth/refresh for new tokens once the access token expires.\nAccounts with two-factor authentication enabled also need totp_code; without it the response is 401 \"Two-factor code is required\".\nToo many consecutive failures lock the account for a while (LOGIN_MAX_FAILURES, LOGIN_LOCKOUT_DURATION).","requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.LoginRequest"}}},"description":"Login credentials","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.LoginResponse"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Account disabled, or email not verified (when REQUIRE_EMAIL_VERIFICATION is on)"},"423":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Account locked after too many failed logins","headers":{"Retry-After":{"description":"Seconds until the account unlocks","schema":{"type":"string"}}}},"429":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Too Many Requests"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not available with STORAGE=memory"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"summary":"Log in","tags":["auth"]}},"/auth/logout":{"post":{"description":"Revokes the refresh token so it can no longer be exchanged. Access tokens already issued stay valid until they expire.","requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.RefreshTokenRequest"}}},"description":"Refresh token to revoke","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.MessageResponse"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ValidationErrorResponse"}}},"description":"Bad Request"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not available with STORAGE=memory"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"summary":"Log out","tags":["auth"]}},"/auth/refresh":{"post":{"description":"Issues a new access token for the owner of the refresh token. The refresh token is rotated:\nthe one sent is invalidated and the response carries its replacement.","requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.RefreshTokenRequest"}}},"description":"Refresh token from login or an earlier refresh","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.LoginResponse"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ValidationErrorResponse"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"429":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Too Many Requests"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not available with STORAGE=memory"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"summary":"Refresh tokens","tags":["auth"]}},"/auth/reset-password":{"post":{"requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.ResetPasswordRequest"}}},"description":"Reset token and new password","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.MessageResponse"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ValidationErrorResponse"}}},"description":"Bad Request"},"429":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Too Many Requests"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not available with STORAGE=memory"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"summary":"Reset a password","tags":["auth"]}},"/auth/verify":{"get":{"parameters":[{"description":"Verification token from the signup email","example":"Zk9yZ290UGFzc3dvcmRUb2tlbkV4YW1wbGUxMjM0NTY","in":"query","name":"token","required":true,"schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.MessageResponse"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Bad Request"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not available with STORAGE=memory"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"summary":"Verify an email address","tags":["auth"]}},"/users":{"delete":{"description":"Permanently removes every user, including soft-deleted ones. Intended for test teardown:\nthe route only exists when the server runs with ALLOW_DESTRUCTIVE=true.","responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.DeleteAllResponse"}}},"description":"OK"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Forbidden"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not available with STORAGE=memory"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"security":[{"bearerauth":[]}],"summary":"Delete all users","tags":["users"]},"get":{"description":"Returns every matching user unless limit or offset is given. Then only that page is returned, with the total number of matches and a Link header (RFC 8288) whose first, prev, next and last URLs repeat the request for the other pages.","parameters":[{"description":"Include soft-deleted users","example":false,"in":"query","name":"include_deleted","schema":{"type":"boolean"}},{"description":"Only users with this account status","example":"active","in":"query","name":"status","schema":{"enum":["active","disabled"],"type":"string"}},{"description":"Sort field, prefix with - for descending","example":"-created_at","in":"query","name":"sort","schema":{"enum":["created_at","-created_at","updated_at","-updated_at","user_id","-user_id","email","-email"],"type":"string"}},{"description":"Only users created at or after this RFC3339 time","example":"2024-01-01T00:00:00Z","in":"query","name":"created_after","schema":{"format":"date-time","type":"string"}},{"description":"Only users created at or before this RFC3339 time","example":"2024-12-31T23:59:59Z","in":"query","name":"created_before","schema":{"format":"date-time","type":"string"}},{"description":"Comma-separated user_id values to return, at most 200","example":"alice,bob","in":"query","name":"user_ids","schema":{"type":"string"}},{"description":"Only users whose email is in this domain","example":"example.com","in":"query","name":"email_domain","schema":{"type":"string"}},{"description":"Users per page, at most 200","example":50,"in":"query","name":"limit","schema":{"default":50,"type":"integer"}},{"description":"Users to skip","example":0,"in":"query","name":"offset","schema":{"default":0,"type":"integer"}}],"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.UserPageResponse"}}},"description":"Without limit and offset, only users and count are set","headers":{"Link":{"description":"First, prev, next and last pages, when paging","schema":{"type":"string"}}}},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Forbidden"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"security":[{"bearerauth":[]},{"apikeyauth":[]}],"summary":"List users","tags":["users"]},"post":{"description":"Creates a user with a unique user_id and email. The password is stored as a bcrypt or argon2id hash, per PASSWORD_HASH_ALGO.\nA verification token is emailed to the new address.\nWith an Idempotency-Key header, a retry with the same key and body within 24 hours returns the\nuser the first request created instead of creating another. With STORAGE=memory there is nowhere to keep keys, so the header is ignored.","parameters":[{"description":"Unique key for safely retrying the signup","example":"5f0c8a4e-3b1d-4c2a-9e7f-6d1b2a3c4d5e","in":"header","name":"Idempotency-Key","schema":{"type":"string"}}],"requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.CreateUserRequest"}}},"description":"User to create","required":true},"responses":{"201":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.UserResponse"}}},"description":"Created","headers":{"Idempotent-Replayed":{"description":"true when the response repeats an earlier signup with the same key","schema":{"type":"string"}},"Location":{"description":"Path of the created user","schema":{"type":"string"}}}},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ValidationErrorResponse"}}},"description":"Bad Request"},"409":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Conflict"},"422":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unprocessable Entity"},"429":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Too Many Requests"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"summary":"Create a user","tags":["users"]}},"/users/available":{"get":{"description":"Pass exactly one of user_id or email. Every answer takes at least 200ms, whether or not a user is found.\nThe endpoint is rate limited like signup, since it reveals which accounts exist.","parameters":[{"description":"user_id to check","example":"alice","in":"query","name":"user_id","schema":{"type":"string"}},{"description":"Email to check","example":"alice@example.com","in":"query","name":"email","schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.AvailabilityResponse"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Bad Request"},"429":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Too Many Requests"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"summary":"Check user_id or email availability","tags":["users"]}},"/users/count":{"get":{"parameters":[{"description":"Only count emails in this domain","example":"example.com","in":"query","name":"email_domain","schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.CountResponse"}}},"description":"OK"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not available with STORAGE=memory"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"summary":"Count users","tags":["users"]}},"/users/events":{"get":{"description":"Holds the connection open and sends one server-sent event per user created, updated or deleted, from the moment of connecting.\nOn a replica set the events follow the users change stream, so they cover every instance and any other client, but carry no actor; otherwise only changes made through this instance are sent.\nEach event is named after the action and carries the same JSON as an audit log entry, with its ID as the event ID. Comments are sent every 15 seconds while idle.\nChanges made while disconnected are not replayed. Only routed when USER_EVENTS_ENABLED is true.","responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.AuditEvent"}},"text/event-stream":{"schema":{"type":"string"}}},"description":"One data payload per event"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not Found"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not available with STORAGE=memory"}},"security":[{"bearerauth":[]}],"summary":"Stream user events","tags":["users"]}},"/users/export":{"get":{"description":"Streams every user straight from the database cursor, so memory use stays flat for large collections.\nThe body is a JSON array, or one user per line with Accept: application/x-ndjson.\nErrors after the first user has been sent cannot change the status code and end the body early.","parameters":[{"description":"Include soft-deleted users","example":false,"in":"query","name":"include_deleted","schema":{"type":"boolean"}}],"responses":{"200":{"content":{"application/json":{"schema":{"items":{"$ref":"#/components/schemas/models.UserResponse"},"type":"array"}},"application/x-ndjson":{"schema":{"type":"string"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Forbidden"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not available with STORAGE=memory"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"security":[{"bearerauth":[]},{"apikeyauth":[]}],"summary":"Export users","tags":["users"]}},"/users/export.csv":{"get":{"description":"Streams id, user_id, email, created_at and updated_at for every user as a CSV attachment.\nuser_id and email values starting with =, +, -, @, a tab or a carriage return are prefixed with ' so that spreadsheets do not run them as formulas.\nErrors after the first row has been sent cannot change the status code and end the body early.","parameters":[{"description":"Include soft-deleted users","example":false,"in":"query","name":"include_deleted","schema":{"type":"boolean"}}],"responses":{"200":{"content":{"application/json":{"schema":{"type":"string"}},"text/csv":{"schema":{"type":"string"}}},"description":"CSV with a header row","headers":{"Content-Disposition":{"description":"Marks the body as the attachment users.csv","schema":{"type":"string"}}}},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Forbidden"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not available with STORAGE=memory"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"security":[{"bearerauth":[]},{"apikeyauth":[]}],"summary":"Export users as CSV","tags":["users"]}},"/users/import":{"post":{"description":"Creates a user for every row of the uploaded CSV. The header must name user_id and email columns; a password column is optional.\nRows without a password get a random one, so those users set their own through forgot-password. Verification emails are sent as on signup.\nRows whose user_id or email already exists are skipped, and malformed or invalid rows are reported as failed without stopping the import.","requestBody":{"content":{"multipart/form-data":{"schema":{"type":"file"}}},"description":"CSV file with a header row (at most 1000 rows)","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ImportUsersResponse"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Forbidden"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"security":[{"bearerauth":[]},{"apikeyauth":[]}],"summary":"Import users from CSV","tags":["users"]}},"/users/inactive":{"get":{"description":"Returns users whose last login is older than since, including users who have never logged in, for account cleanup.\nUsers are ordered oldest account first; page through them with limit and offset until has_more is false.","parameters":[{"description":"How long without a login, in days (30d), weeks (2w) or a Go duration (36h)","example":"90d","in":"query","name":"since","schema":{"default":"30d","type":"string"}},{"description":"Users per page, at most 200","example":50,"in":"query","name":"limit","schema":{"default":50,"type":"integer"}},{"description":"Users to skip","example":0,"in":"query","name":"offset","schema":{"default":0,"type":"integer"}}],"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.InactiveUsersResponse"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Forbidden"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not available with STORAGE=memory"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"security":[{"bearerauth":[]},{"apikeyauth":[]}],"summary":"List inactive users","tags":["users"]}},"/users/me":{"get":{"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.UserResponse"}}},"description":"OK","headers":{"ETag":{"description":"Version of the user, for If-Match on PUT and PATCH","schema":{"type":"string"}}}},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"security":[{"bearerauth":[]}],"summary":"Get the authenticated user","tags":["users"]}},"/users/me/2fa/enable":{"post":{"description":"Generates a TOTP secret and returns it with an otpauth:// provisioning URI for authenticator apps.\nLogin keeps working without a code until the enrollment is confirmed at /users/me/2fa/verify.","responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.TwoFactorSetup"}}},"description":"OK"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"409":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Conflict"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"TOTP_ENCRYPTION_KEY is not set"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"security":[{"bearerauth":[]}],"summary":"Start two-factor enrollment","tags":["users"]}},"/users/me/2fa/verify":{"post":{"description":"Checks a code from the authenticator app against the secret from /users/me/2fa/enable.\nOnce it matches, every login needs a totp_code as well as the password.","requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.TwoFactorCodeRequest"}}},"description":"Current code from the authenticator app","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.MessageResponse"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ValidationErrorResponse"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"409":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Conflict"},"429":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Too Many Requests"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"TOTP_ENCRYPTION_KEY is not set"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"security":[{"bearerauth":[]}],"summary":"Confirm two-factor enrollment","tags":["users"]}},"/users/me/sessions":{"delete":{"description":"Deletes the refresh tokens of every session except the one making the request, such as after losing a device.\nAccess tokens already issued to them stay valid until they expire. Tokens from before sessions were tracked name no session and get 409; log in again first.","responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.RevokeSessionsResponse"}}},"description":"OK"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"409":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Conflict"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not available with STORAGE=memory"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"security":[{"bearerauth":[]}],"summary":"Revoke my other sessions","tags":["users"]},"get":{"description":"Each login starts a session, which lasts for as long as its refresh token is outstanding and keeps its ID across refreshes.\nSessions are listed most recently used first, with the User-Agent and IP they logged in from; current marks the one making the request.","responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.SessionListResponse"}}},"description":"OK"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not available with STORAGE=memory"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"security":[{"bearerauth":[]}],"summary":"List my sessions","tags":["users"]}},"/users/me/sessions/{sid}":{"delete":{"description":"Deletes the session's refresh token, so that it can no longer be refreshed. Access tokens already issued to it stay valid until they expire.\nRevoking the current session logs it out.","parameters":[{"description":"Session ID from GET /users/me/sessions","example":"665f1c2e8b3a4d2f9c1e7a10","in":"path","name":"sid","required":true,"schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.MessageResponse"}}},"description":"OK"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"404":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not available with STORAGE=memory"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"security":[{"bearerauth":[]}],"summary":"Revoke a session","tags":["users"]}},"/users/search":{"get":{"description":"With q, returns users whose user_id or email contains q (case-insensitive).\nWithout q, user_id is matched exactly and the single matching user is returned instead of a list.","parameters":[{"description":"Partial user_id or email","example":"ali","in":"query","name":"q","schema":{"type":"string"}},{"description":"Exact user_id, used when q is absent","example":"alice","in":"query","name":"user_id","schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.UserListResponse"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Bad Request"},"404":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Searching with q is not available with STORAGE=memory"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"summary":"Search users","tags":["users"]},"head":{"description":"Cheaper than GET: the user is counted rather than read, and nothing about it is returned.","parameters":[{"description":"Exact user_id","example":"alice","in":"query","name":"user_id","required":true,"schema":{"type":"string"}}],"responses":{"200":{"description":"OK"},"400":{"description":"Bad Request"},"404":{"description":"Not Found"},"500":{"description":"Internal Server Error"},"501":{"description":"Not Implemented"},"504":{"description":"Gateway Timeout"}},"summary":"Check whether a user_id is taken","tags":["users"]}},"/users/search/email":{"get":{"parameters":[{"description":"Email address","example":"alice@example.com","in":"query","name":"email","required":true,"schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.UserResponse"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Bad Request"},"404":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"summary":"Find a user by email","tags":["users"]}},"/users/stats":{"get":{"description":"Counts users that are not deleted: in total, created today and this week (from midnight UTC, weeks starting Monday), and in the 20 most common email domains.\nThe numbers are computed by one aggregation and cached for 30 seconds, so they can lag behind recent writes.","responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.UserStats"}}},"description":"OK"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Forbidden"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not available with STORAGE=memory"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"security":[{"bearerauth":[]},{"apikeyauth":[]}],"summary":"User statistics","tags":["users"]}},"/users/{id}":{"delete":{"description":"Soft-deletes the user and records who deleted whom in the server log.\nWith Prefer: return=representation the deleted user is returned instead of a message.","parameters":[{"description":"MongoDB ObjectID","example":"665f1c2e8b3a4d2f9c1e7a10","in":"path","name":"id","required":true,"schema":{"type":"string"}},{"description":"return=representation to receive the deleted user","in":"header","name":"Prefer","schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.MessageResponse"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"security":[{"bearerauth":[]},{"apikeyauth":[]}],"summary":"Delete a user","tags":["users"]},"get":{"description":"With include_age=true the body also carries age, the whole seconds since created_at.\nWithout include_age the response carries Last-Modified, and If-Modified-Since answers 304 while the user is unchanged since that time.","parameters":[{"description":"MongoDB ObjectID","example":"665f1c2e8b3a4d2f9c1e7a10","in":"path","name":"id","required":true,"schema":{"type":"string"}},{"description":"Add the computed age in seconds","example":true,"in":"query","name":"include_age","schema":{"type":"boolean"}},{"description":"Last-Modified from a previous read","example":"Tue, 04 Jun 2024 12:00:00 GMT","in":"header","name":"If-Modified-Since","schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.UserWithAgeResponse"}}},"description":"OK","headers":{"ETag":{"description":"Version of the user, for If-Match on PUT and PATCH","schema":{"type":"string"}},"Last-Modified":{"description":"updated_at of the user, unless include_age is set","schema":{"type":"string"}}}},"304":{"description":"The user has not changed since If-Modified-Since"},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Bad Request"},"404":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"summary":"Get a user by ID","tags":["users"]},"head":{"description":"Cheaper than GET: the user is counted rather than read, and nothing about it is returned.","parameters":[{"description":"MongoDB ObjectID","example":"665f1c2e8b3a4d2f9c1e7a10","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"responses":{"200":{"description":"OK"},"400":{"description":"Bad Request"},"404":{"description":"Not Found"},"500":{"description":"Internal Server Error"},"501":{"description":"Not Implemented"},"504":{"description":"Gateway Timeout"}},"summary":"Check that a user exists","tags":["users"]},"patch":{"description":"A JSON body sets the fields it contains; an empty string clears a profile field.\nAn application/json-patch+json body is applied to user_id, email, first_name, last_name and avatar_url, and may add password.\nRemoving a profile field clears it. A failed test operation answers 409, and a patch that cannot be applied 422.\nChanging the password revokes every refresh token of the user, logging out all of their sessions.","parameters":[{"description":"MongoDB ObjectID","example":"665f1c2e8b3a4d2f9c1e7a10","in":"path","name":"id","required":true,"schema":{"type":"string"}},{"description":"ETag from a previous read; the update is rejected if the user changed since","example":"\"1717502400000\"","in":"header","name":"If-Match","schema":{"type":"string"}}],"requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.UpdateUserRequest"}},"application/json-patch+json":{"schema":{"$ref":"#/components/schemas/models.UpdateUserRequest"}}},"description":"Fields to change, or a JSON Patch document","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.UserResponse"}}},"description":"OK","headers":{"ETag":{"description":"New version of the user","schema":{"type":"string"}}}},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ValidationErrorResponse"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Neither the user nor an admin"},"404":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not Found"},"409":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Conflict"},"412":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"If-Match does not match the current version"},"422":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"JSON Patch could not be applied"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"security":[{"bearerauth":[]}],"summary":"Partially update a user","tags":["users"]},"put":{"description":"The password is replaced too, so every refresh token of the user is revoked.","parameters":[{"description":"MongoDB ObjectID","example":"665f1c2e8b3a4d2f9c1e7a10","in":"path","name":"id","required":true,"schema":{"type":"string"}},{"description":"ETag from a previous read; the update is rejected if the user changed since","example":"\"1717502400000\"","in":"header","name":"If-Match","schema":{"type":"string"}}],"requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.ReplaceUserRequest"}}},"description":"Complete user","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.UserResponse"}}},"description":"OK","headers":{"ETag":{"description":"New version of the user","schema":{"type":"string"}}}},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ValidationErrorResponse"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Neither the user nor an admin"},"404":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not Found"},"409":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Conflict"},"412":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"If-Match does not match the current version"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"security":[{"bearerauth":[]}],"summary":"Replace a user","tags":["users"]}},"/users/{id}/activate":{"post":{"parameters":[{"description":"MongoDB ObjectID","example":"665f1c2e8b3a4d2f9c1e7a10","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.UserResponse"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not available with STORAGE=memory"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"security":[{"bearerauth":[]},{"apikeyauth":[]}],"summary":"Activate a user","tags":["users"]}},"/users/{id}/audit-log":{"get":{"description":"Lists the creates, updates and deletes recorded for the user, most recent first, with the names of the fields each one changed and the authenticated user who made it.\nField values, such as passwords, are never recorded. Deleted users keep their history. Page through it with limit and offset until has_more is false.","parameters":[{"description":"MongoDB ObjectID","example":"665f1c2e8b3a4d2f9c1e7a10","in":"path","name":"id","required":true,"schema":{"type":"string"}},{"description":"Events per page, at most 200","example":50,"in":"query","name":"limit","schema":{"default":50,"type":"integer"}},{"description":"Events to skip","example":0,"in":"query","name":"offset","schema":{"default":0,"type":"integer"}}],"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.AuditLogResponse"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Forbidden"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not Implemented"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"security":[{"bearerauth":[]},{"apikeyauth":[]}],"summary":"User audit log","tags":["users"]}},"/users/{id}/deactivate":{"post":{"description":"Disabled users are refused at login with 403 and their refresh tokens are revoked.\nAccess tokens already issued stay valid until they expire. Admins cannot deactivate themselves.","parameters":[{"description":"MongoDB ObjectID","example":"665f1c2e8b3a4d2f9c1e7a10","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.UserResponse"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not available with STORAGE=memory"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"security":[{"bearerauth":[]},{"apikeyauth":[]}],"summary":"Deactivate a user","tags":["users"]}},"/users/{id}/restore":{"post":{"parameters":[{"description":"MongoDB ObjectID","example":"665f1c2e8b3a4d2f9c1e7a10","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.UserResponse"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not available with STORAGE=memory"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"security":[{"bearerauth":[]},{"apikeyauth":[]}],"summary":"Restore a deleted user","tags":["users"]}},"/users/{id}/roles":{"put":{"parameters":[{"description":"MongoDB ObjectID","example":"665f1c2e8b3a4d2f9c1e7a10","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.SetRolesRequest"}}},"description":"Roles to grant","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/models.UserResponse"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ValidationErrorResponse"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Internal Server Error"},"501":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Not available with STORAGE=memory"},"504":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/handlers.ErrorResponse"}}},"description":"Gateway Timeout"}},"security":[{"bearerauth":[]},{"apikeyauth":[]}],"summary":"Set a user's roles","tags":["users"]}}},
+    "openapi": "3.1.0",
+    "servers": [
+        {"url":"/api/v1"}
+    ]
+}`
+
+// SwaggerInfo holds exported Swagger Info so clients can modify it
+var SwaggerInfo = &swag.Spec{
+	Version:          "1.0",
+	Title:            "User Management API",
+	Description:      "CRUD API for users stored in MongoDB.",
+	InfoInstanceName: "swagger",
+	SwaggerTemplate:  docTemplate,
+	LeftDelim:        "{{",
+	RightDelim:       "}}",
+}
+
+func init() {
+	swag.Register(SwaggerInfo.InstanceName(), SwaggerInfo)
+}
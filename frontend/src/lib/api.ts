@@ -75,7 +75,7 @@ export const userApi = {
   // Update user
   async updateUser(id: string, userData: UpdateUserRequest): Promise<User> {
     return apiRequest<User>(`/users/${id}`, {
-      method: 'PUT',
+      method: 'PATCH',
       body: JSON.stringify(userData),
     });
   },
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"go-mongodb-test/auth"
+	"go-mongodb-test/models"
+	"go-mongodb-test/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AdminResetPassword sets a user's password without the old one
+//
+//	@Summary		Reset a user's password (admin)
+//	@Description	Sets new_password, or generates a random password when the body leaves it out and returns it in this response only.
+//	@Description	The user's refresh tokens are revoked and any login lockout is cleared.
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerauth
+//	@Param			id		path		string								true	"MongoDB ObjectID"	example(665f1c2e8b3a4d2f9c1e7a10)
+//	@Param			request	body		models.AdminResetPasswordRequest	false	"New password; omit to generate one"
+//	@Success		200		{object}	AdminResetPasswordResponse
+//	@Failure		400		{object}	ValidationErrorResponse
+//	@Failure		401		{object}	ErrorResponse
+//	@Failure		403		{object}	ErrorResponse
+//	@Failure		404		{object}	ErrorResponse
+//	@Failure		500		{object}	ErrorResponse
+//	@Failure		501		{object}	ErrorResponse	"Not available with STORAGE=memory"
+//	@Failure		504		{object}	ErrorResponse
+//	@Router			/admin/users/{id}/reset-password [post]
+func (h *UserHandler) AdminResetPassword(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return errorResponse(c, http.StatusBadRequest, "User ID is required")
+	}
+
+	var req models.AdminResetPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return errorResponse(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return validationError(c, err)
+	}
+
+	password, generated := req.NewPassword, req.NewPassword == ""
+	if generated {
+		var err error
+		if password, err = auth.GenerateRandomToken(); err != nil {
+			return serverError(c, err)
+		}
+	}
+
+	ctx := c.Request().Context()
+	if err := h.service(c).SetPassword(ctx, id, password); err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			return errorResponse(c, http.StatusNotFound, "User not found")
+		}
+		if errors.Is(err, services.ErrInvalidUserID) {
+			return errorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		}
+		return serverError(c, err)
+	}
+
+	// As with DeleteUser, the route requires an admin token but the handler works without one
+	resetBy := ""
+	if claims, ok := auth.ClaimsFromContext(c); ok {
+		resetBy = claims.UserID
+	}
+	slog.InfoContext(ctx, "Password reset by admin", "id", id, "reset_by", resetBy, "generated", generated)
+
+	response := AdminResetPasswordResponse{Message: "Password has been reset"}
+	if generated {
+		response.Password = password
+	}
+	return respond(c, http.StatusOK, response, nil)
+}
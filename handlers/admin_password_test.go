@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-mongodb-test/services"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestUserHandler_AdminResetPassword(t *testing.T) {
+	userID := bson.NewObjectID()
+
+	tests := []struct {
+		name              string
+		reqBody           string
+		serviceErr        error
+		expectedStatus    int
+		expectedPassword  string
+		expectedGenerated bool
+	}{
+		{"Given password", `{"new_password":"n3wpassword"}`, nil, http.StatusOK, "n3wpassword", false},
+		{"Generated password", ``, nil, http.StatusOK, "", true},
+		{"Generated for empty object", `{}`, nil, http.StatusOK, "", true},
+		{"Too short", `{"new_password":"abc"}`, nil, http.StatusBadRequest, "", false},
+		{"Malformed body", `{"new_password":`, nil, http.StatusBadRequest, "", false},
+		{"User not found", `{"new_password":"n3wpassword"}`, services.ErrUserNotFound, http.StatusNotFound, "", false},
+		{"Invalid ID", `{"new_password":"n3wpassword"}`, services.ErrInvalidUserID, http.StatusBadRequest, "", false},
+		{"Service error", `{"new_password":"n3wpassword"}`, errors.New("database error"), http.StatusInternalServerError, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var setPassword string
+			mockService := &mockUserService{
+				setPasswordFunc: func(ctx context.Context, id, newPassword string) error {
+					setPassword = newPassword
+					return tt.serviceErr
+				},
+			}
+			handler := NewUserHandler(mockService, &mockMailer{})
+			e := echo.New()
+			e.Validator = NewValidator()
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/users/"+userID.Hex()+"/reset-password", strings.NewReader(tt.reqBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues(userID.Hex())
+
+			if err := handler.AdminResetPassword(c); err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			var response AdminResetPasswordResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if tt.expectedGenerated {
+				if response.Password == "" || response.Password != setPassword {
+					t.Errorf("Expected the generated password '%s' in the response, got '%s'", setPassword, response.Password)
+				}
+				return
+			}
+			if setPassword != tt.expectedPassword {
+				t.Errorf("Expected password '%s' to be set, got '%s'", tt.expectedPassword, setPassword)
+			}
+			if response.Password != "" {
+				t.Errorf("Expected a given password not to be echoed, got '%s'", response.Password)
+			}
+		})
+	}
+}
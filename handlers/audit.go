@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"go-mongodb-test/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetUserAuditLog returns the history of changes to a user
+//
+//	@Summary		User audit log
+//	@Description	Lists the creates, updates and deletes recorded for the user, most recent first, with the names of the fields each one changed and the authenticated user who made it.
+//	@Description	Field values, such as passwords, are never recorded. Deleted users keep their history. Page through it with limit and offset until has_more is false.
+//	@Tags			users
+//	@Produce		json
+//	@Security		bearerauth
+//	@Security		apikeyauth
+//	@Param			id		path		string	true	"MongoDB ObjectID"	example(665f1c2e8b3a4d2f9c1e7a10)
+//	@Param			limit	query		int		false	"Events per page, at most 200"	default(50)	example(50)
+//	@Param			offset	query		int		false	"Events to skip"				default(0)	example(0)
+//	@Success		200		{object}	AuditLogResponse
+//	@Failure		400		{object}	ErrorResponse
+//	@Failure		401		{object}	ErrorResponse
+//	@Failure		403		{object}	ErrorResponse
+//	@Failure		500		{object}	ErrorResponse
+//	@Failure		501		{object}	ErrorResponse
+//	@Failure		504		{object}	ErrorResponse
+//	@Router			/users/{id}/audit-log [get]
+func (h *UserHandler) GetUserAuditLog(c echo.Context) error {
+	limit, offset, err := pageQueryParams(c)
+	if err != nil {
+		return errorResponse(c, http.StatusBadRequest, err.Error())
+	}
+
+	// One extra event tells whether another page follows without a separate count
+	events, err := h.service(c).ListAuditEvents(c.Request().Context(), c.Param("id"), limit+1, offset)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidUserID) {
+			return errorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		}
+		return serverError(c, err)
+	}
+
+	hasMore := len(events) > limit
+	if hasMore {
+		events = events[:limit]
+	}
+
+	return respond(c, http.StatusOK, AuditLog{Events: events}, PageMeta{
+		Count:   len(events),
+		Limit:   limit,
+		Offset:  offset,
+		HasMore: hasMore,
+	})
+}
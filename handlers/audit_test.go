@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-mongodb-test/models"
+	"go-mongodb-test/services"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestUserHandler_GetUserAuditLog(t *testing.T) {
+	userID := bson.NewObjectID()
+	events := func(n int) []*models.AuditEvent {
+		page := make([]*models.AuditEvent, n)
+		for i := range page {
+			page[i] = &models.AuditEvent{ID: bson.NewObjectID(), TargetID: userID, Action: models.AuditActionUpdate, Fields: []string{"email"}}
+		}
+		return page
+	}
+
+	tests := []struct {
+		name            string
+		query           string
+		stored          int
+		serviceErr      error
+		expectedStatus  int
+		expectedLimit   int
+		expectedOffset  int
+		expectedCount   int
+		expectedHasMore bool
+	}{
+		{"First page", "", 2, nil, http.StatusOK, 50, 0, 2, false},
+		{"More to come", "?limit=2&offset=4", 3, nil, http.StatusOK, 2, 4, 2, true},
+		{"Invalid limit", "?limit=0", 0, nil, http.StatusBadRequest, 0, 0, 0, false},
+		{"Invalid ID", "", 0, services.ErrInvalidUserID, http.StatusBadRequest, 0, 0, 0, false},
+		{"Memory storage", "", 0, services.ErrStorageUnsupported, http.StatusNotImplemented, 0, 0, 0, false},
+		{"Service error", "", 0, errors.New("database error"), http.StatusInternalServerError, 0, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotLimit, gotOffset int
+			handler := NewUserHandler(&mockUserService{
+				listAuditEventsFunc: func(ctx context.Context, id string, limit, offset int) ([]*models.AuditEvent, error) {
+					if id != userID.Hex() {
+						t.Errorf("Expected events of %s, got %s", userID.Hex(), id)
+					}
+					gotLimit, gotOffset = limit, offset
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					return events(tt.stored), nil
+				},
+			}, &mockMailer{})
+			e := echo.New()
+
+			req := httptest.NewRequest(http.MethodGet, "/users/"+userID.Hex()+"/audit-log"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues(userID.Hex())
+
+			if err := handler.GetUserAuditLog(c); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			// One more event than the page holds is asked for to detect a next page
+			if gotLimit != tt.expectedLimit+1 || gotOffset != tt.expectedOffset {
+				t.Errorf("Expected limit %d and offset %d, got %d and %d", tt.expectedLimit+1, tt.expectedOffset, gotLimit, gotOffset)
+			}
+
+			var response AuditLogResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if response.Count != tt.expectedCount || len(response.Events) != tt.expectedCount || response.HasMore != tt.expectedHasMore {
+				t.Errorf("Expected %d events (has_more %v), got %+v", tt.expectedCount, tt.expectedHasMore, response)
+			}
+			if response.Limit != tt.expectedLimit || response.Offset != tt.expectedOffset {
+				t.Errorf("Expected limit %d and offset %d, got %d and %d", tt.expectedLimit, tt.expectedOffset, response.Limit, response.Offset)
+			}
+		})
+	}
+}
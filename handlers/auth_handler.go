@@ -0,0 +1,390 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-mongodb-test/auth"
+	"go-mongodb-test/mailer"
+	"go-mongodb-test/models"
+	"go-mongodb-test/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AuthUserService is the subset of user operations needed for authentication
+type AuthUserService interface {
+	GetUserByUserIDWithPassword(ctx context.Context, userID string) (*models.User, error)
+	GetUserByEmailWithPassword(ctx context.Context, email string) (*models.User, error)
+	GetUserByIdentifier(ctx context.Context, identifier string) (*models.User, error)
+	CreatePasswordResetToken(ctx context.Context, email string) (string, error)
+	ResetPassword(ctx context.Context, token, newPassword string) error
+	VerifyEmail(ctx context.Context, token string) error
+	RecordFailedLogin(ctx context.Context, id string) (*time.Time, error)
+	ResetFailedLogins(ctx context.Context, id string) error
+	RecordLogin(ctx context.Context, id string) error
+	RehashPassword(ctx context.Context, user *models.User, password string) error
+	IssueRefreshToken(ctx context.Context, id string, client models.SessionClient) (*models.IssuedRefreshToken, error)
+	RotateRefreshToken(ctx context.Context, token string) (*models.User, *models.IssuedRefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, token string) error
+}
+
+type AuthHandler struct {
+	userService AuthUserService
+	mailer      mailer.Mailer
+}
+
+func NewAuthHandler(userService AuthUserService, m mailer.Mailer) *AuthHandler {
+	return &AuthHandler{
+		userService: userService,
+		mailer:      m,
+	}
+}
+
+// Login checks credentials and issues a JWT along with a refresh token
+//
+//	@Summary		Log in
+//	@Description	Authenticates with either user_id or email plus password and returns a signed access token and a refresh token.
+//	@Description	identifier accepts either one, so a single login field works for both; failures look the same whichever field matched.
+//	@Description	Exchange the refresh token at /auth/refresh for new tokens once the access token expires.
+//	@Description	Accounts with two-factor authentication enabled also need totp_code; without it the response is 401 "Two-factor code is required".
+//	@Description	Too many consecutive failures lock the account for a while (LOGIN_MAX_FAILURES, LOGIN_LOCKOUT_DURATION).
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			credentials	body		models.LoginRequest	true	"Login credentials"
+//	@Success		200			{object}	models.LoginResponse
+//	@Failure		400			{object}	ErrorResponse
+//	@Failure		401			{object}	ErrorResponse
+//	@Failure		403			{object}	ErrorResponse	"Account disabled, or email not verified (when REQUIRE_EMAIL_VERIFICATION is on)"
+//	@Failure		423			{object}	ErrorResponse	"Account locked after too many failed logins"
+//	@Header			423			{string}	Retry-After		"Seconds until the account unlocks"
+//	@Failure		429			{object}	ErrorResponse
+//	@Failure		500			{object}	ErrorResponse
+//	@Failure		501			{object}	ErrorResponse	"Not available with STORAGE=memory"
+//	@Failure		504			{object}	ErrorResponse
+//	@Router			/auth/login [post]
+func (h *AuthHandler) Login(c echo.Context) error {
+	var req models.LoginRequest
+	if err := c.Bind(&req); err != nil {
+		return errorResponse(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	if (req.Identifier == "" && req.UserID == "" && req.Email == "") || req.Password == "" {
+		return errorResponse(c, http.StatusBadRequest, "identifier, user_id or email, and password are required")
+	}
+
+	var (
+		user *models.User
+		err  error
+	)
+	switch {
+	case req.Identifier != "":
+		user, err = h.service(c).GetUserByIdentifier(c.Request().Context(), req.Identifier)
+	case req.UserID != "":
+		user, err = h.service(c).GetUserByUserIDWithPassword(c.Request().Context(), req.UserID)
+	default:
+		user, err = h.service(c).GetUserByEmailWithPassword(c.Request().Context(), req.Email)
+	}
+	if err != nil {
+		return serverError(c, err)
+	}
+
+	if user == nil {
+		return errorResponse(c, http.StatusUnauthorized, "Invalid credentials")
+	}
+
+	// A locked account is refused before the password is checked, so guessing
+	// cannot continue while the lock holds
+	if user.IsLocked(time.Now()) {
+		return lockedResponse(c, *user.LockedUntil)
+	}
+
+	if !user.CheckPassword(req.Password) {
+		return h.failedLogin(c, user, "Invalid credentials")
+	}
+
+	if user.TwoFactorEnabled {
+		// A missing code is not a failed attempt: clients send the password alone
+		// first and only then learn that a code is needed
+		if req.TOTPCode == "" {
+			return errorResponse(c, http.StatusUnauthorized, "Two-factor code is required")
+		}
+		valid, err := auth.ValidateTOTP(req.TOTPCode, user.TwoFactorSecret)
+		if err != nil {
+			return serverError(c, err)
+		}
+		// Wrong codes count towards the lockout, which keeps the code space from
+		// being searched
+		if !valid {
+			return h.failedLogin(c, user, "Invalid two-factor code")
+		}
+	}
+
+	if user.FailedLogins > 0 || user.LockedUntil != nil {
+		if err := h.service(c).ResetFailedLogins(c.Request().Context(), user.ID.Hex()); err != nil {
+			slog.ErrorContext(c.Request().Context(), "Failed to reset failed logins", "error", err)
+		}
+	}
+
+	// Checked only once the credentials are good, so the response does not reveal
+	// which accounts exist
+	if user.IsDisabled() {
+		return errorResponse(c, http.StatusForbidden, "Account is disabled")
+	}
+
+	if auth.RequireEmailVerification() && !user.EmailVerified {
+		return errorResponse(c, http.StatusForbidden, "Email address has not been verified")
+	}
+
+	if err := h.service(c).RecordLogin(c.Request().Context(), user.ID.Hex()); err != nil {
+		slog.ErrorContext(c.Request().Context(), "Failed to record login", "error", err)
+	}
+
+	// A hash made by another algorithm than PASSWORD_HASH_ALGO, or below the current
+	// BCRYPT_COST, is replaced while the password is at hand; the login goes ahead
+	// even if that fails
+	if user.NeedsRehash() {
+		if err := h.service(c).RehashPassword(c.Request().Context(), user, req.Password); err != nil {
+			slog.ErrorContext(c.Request().Context(), "Failed to rehash password", "error", err)
+		}
+	}
+
+	// The session is started first so that the access token can name it
+	refreshToken, err := h.service(c).IssueRefreshToken(c.Request().Context(), user.ID.Hex(), sessionClient(c))
+	if err != nil {
+		return serverError(c, err)
+	}
+
+	token, err := auth.GenerateSessionToken(user, auth.TenantFrom(c.Request().Context()), refreshToken.SessionID)
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to generate token")
+	}
+
+	return respond(c, http.StatusOK, models.LoginResponse{
+		Token:        token,
+		RefreshToken: refreshToken.Token,
+		User:         models.NewUserResponse(user),
+	}, nil)
+}
+
+// Refresh exchanges a refresh token for a new access token and refresh token
+//
+//	@Summary		Refresh tokens
+//	@Description	Issues a new access token for the owner of the refresh token. The refresh token is rotated:
+//	@Description	the one sent is invalidated and the response carries its replacement.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		models.RefreshTokenRequest	true	"Refresh token from login or an earlier refresh"
+//	@Success		200		{object}	models.LoginResponse
+//	@Failure		400		{object}	ValidationErrorResponse
+//	@Failure		401		{object}	ErrorResponse
+//	@Failure		429		{object}	ErrorResponse
+//	@Failure		500		{object}	ErrorResponse
+//	@Failure		501		{object}	ErrorResponse	"Not available with STORAGE=memory"
+//	@Failure		504		{object}	ErrorResponse
+//	@Router			/auth/refresh [post]
+func (h *AuthHandler) Refresh(c echo.Context) error {
+	var req models.RefreshTokenRequest
+	if err := c.Bind(&req); err != nil {
+		return errorResponse(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return validationError(c, err)
+	}
+
+	user, refreshToken, err := h.service(c).RotateRefreshToken(c.Request().Context(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRefreshToken) {
+			return errorResponse(c, http.StatusUnauthorized, "Invalid or expired refresh token")
+		}
+		return serverError(c, err)
+	}
+
+	token, err := auth.GenerateSessionToken(user, auth.TenantFrom(c.Request().Context()), refreshToken.SessionID)
+	if err != nil {
+		return errorResponse(c, http.StatusInternalServerError, "Failed to generate token")
+	}
+
+	return respond(c, http.StatusOK, models.LoginResponse{
+		Token:        token,
+		RefreshToken: refreshToken.Token,
+		User:         models.NewUserResponse(user),
+	}, nil)
+}
+
+// sessionClient describes the client making the request, for the session it starts
+func sessionClient(c echo.Context) models.SessionClient {
+	return models.SessionClient{UserAgent: c.Request().UserAgent(), IP: c.RealIP()}
+}
+
+// Logout revokes a refresh token
+//
+//	@Summary		Log out
+//	@Description	Revokes the refresh token so it can no longer be exchanged. Access tokens already issued stay valid until they expire.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		models.RefreshTokenRequest	true	"Refresh token to revoke"
+//	@Success		200		{object}	MessageResponse
+//	@Failure		400		{object}	ValidationErrorResponse
+//	@Failure		500		{object}	ErrorResponse
+//	@Failure		501		{object}	ErrorResponse	"Not available with STORAGE=memory"
+//	@Failure		504		{object}	ErrorResponse
+//	@Router			/auth/logout [post]
+func (h *AuthHandler) Logout(c echo.Context) error {
+	var req models.RefreshTokenRequest
+	if err := c.Bind(&req); err != nil {
+		return errorResponse(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return validationError(c, err)
+	}
+
+	if err := h.service(c).RevokeRefreshToken(c.Request().Context(), req.RefreshToken); err != nil {
+		return serverError(c, err)
+	}
+
+	return respond(c, http.StatusOK, MessageResponse{
+		Message: "Logged out",
+	}, nil)
+}
+
+// failedLogin records a failed attempt for user, answering 423 if it locked the
+// account and 401 with message otherwise
+func (h *AuthHandler) failedLogin(c echo.Context, user *models.User, message string) error {
+	lockedUntil, err := h.service(c).RecordFailedLogin(c.Request().Context(), user.ID.Hex())
+	if err != nil {
+		slog.ErrorContext(c.Request().Context(), "Failed to record failed login", "error", err)
+	}
+	if lockedUntil != nil {
+		return lockedResponse(c, *lockedUntil)
+	}
+	return errorResponse(c, http.StatusUnauthorized, message)
+}
+
+// lockedResponse answers 423 with the whole seconds until lockedUntil in Retry-After
+func lockedResponse(c echo.Context, lockedUntil time.Time) error {
+	retryAfter := int(time.Until(lockedUntil).Round(time.Second) / time.Second)
+	c.Response().Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+	return errorResponse(c, http.StatusLocked, "Account is locked after too many failed login attempts")
+}
+
+// ForgotPassword emails a reset token to the account with the given address
+//
+//	@Summary		Request a password reset
+//	@Description	Sends a single-use reset token to the address if it belongs to an account.
+//	@Description	The response is the same whether or not the account exists.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		models.ForgotPasswordRequest	true	"Account email"
+//	@Success		200		{object}	MessageResponse
+//	@Failure		400		{object}	ValidationErrorResponse
+//	@Failure		429		{object}	ErrorResponse
+//	@Failure		500		{object}	ErrorResponse
+//	@Failure		501		{object}	ErrorResponse	"Not available with STORAGE=memory"
+//	@Failure		504		{object}	ErrorResponse
+//	@Router			/auth/forgot-password [post]
+func (h *AuthHandler) ForgotPassword(c echo.Context) error {
+	var req models.ForgotPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return errorResponse(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return validationError(c, err)
+	}
+
+	ctx := c.Request().Context()
+	token, err := h.service(c).CreatePasswordResetToken(ctx, req.Email)
+	if err != nil && !errors.Is(err, services.ErrUserNotFound) {
+		return serverError(c, err)
+	}
+
+	if err == nil {
+		// A delivery failure must not reveal that the account exists
+		if err := h.mailer.SendPasswordReset(ctx, req.Email, token); err != nil {
+			slog.ErrorContext(ctx, "Failed to send password reset email", "error", err)
+		}
+	}
+
+	return respond(c, http.StatusOK, MessageResponse{
+		Message: "If the email is registered, a password reset link has been sent",
+	}, nil)
+}
+
+// ResetPassword sets a new password using a token from ForgotPassword
+//
+//	@Summary	Reset a password
+//	@Tags		auth
+//	@Accept		json
+//	@Produce	json
+//	@Param		request	body		models.ResetPasswordRequest	true	"Reset token and new password"
+//	@Success	200		{object}	MessageResponse
+//	@Failure	400		{object}	ValidationErrorResponse
+//	@Failure	429		{object}	ErrorResponse
+//	@Failure	500		{object}	ErrorResponse
+//	@Failure	501		{object}	ErrorResponse	"Not available with STORAGE=memory"
+//	@Failure	504		{object}	ErrorResponse
+//	@Router		/auth/reset-password [post]
+func (h *AuthHandler) ResetPassword(c echo.Context) error {
+	var req models.ResetPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return errorResponse(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return validationError(c, err)
+	}
+
+	err := h.service(c).ResetPassword(c.Request().Context(), req.Token, req.NewPassword)
+	if err != nil {
+		// A token whose account was deleted is as unusable as an expired one
+		if errors.Is(err, services.ErrInvalidResetToken) || errors.Is(err, services.ErrUserNotFound) {
+			return errorResponse(c, http.StatusBadRequest, "Invalid or expired reset token")
+		}
+		return serverError(c, err)
+	}
+
+	return respond(c, http.StatusOK, MessageResponse{
+		Message: "Password has been reset",
+	}, nil)
+}
+
+// VerifyEmail confirms an email address using the token sent at signup
+//
+//	@Summary	Verify an email address
+//	@Tags		auth
+//	@Produce	json
+//	@Param		token	query		string	true	"Verification token from the signup email"	example(Zk9yZ290UGFzc3dvcmRUb2tlbkV4YW1wbGUxMjM0NTY)
+//	@Success	200		{object}	MessageResponse
+//	@Failure	400		{object}	ErrorResponse
+//	@Failure	500		{object}	ErrorResponse
+//	@Failure	501		{object}	ErrorResponse	"Not available with STORAGE=memory"
+//	@Failure	504		{object}	ErrorResponse
+//	@Router		/auth/verify [get]
+func (h *AuthHandler) VerifyEmail(c echo.Context) error {
+	token := c.QueryParam("token")
+	if token == "" {
+		return errorResponse(c, http.StatusBadRequest, "token query parameter is required")
+	}
+
+	if err := h.service(c).VerifyEmail(c.Request().Context(), token); err != nil {
+		if errors.Is(err, services.ErrInvalidVerificationToken) {
+			return errorResponse(c, http.StatusBadRequest, "Invalid verification token")
+		}
+		return serverError(c, err)
+	}
+
+	return respond(c, http.StatusOK, MessageResponse{
+		Message: "Email address verified",
+	}, nil)
+}
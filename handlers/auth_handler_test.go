@@ -0,0 +1,890 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-mongodb-test/auth"
+	"go-mongodb-test/models"
+	"go-mongodb-test/services"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pquerna/otp/totp"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// mockMailer records the emails it is asked to send
+type mockMailer struct {
+	resetEmails        []string
+	resetTokens        []string
+	verificationEmails []string
+	verificationTokens []string
+	err                error
+}
+
+func (m *mockMailer) SendPasswordReset(ctx context.Context, email, token string) error {
+	m.resetEmails = append(m.resetEmails, email)
+	m.resetTokens = append(m.resetTokens, token)
+	return m.err
+}
+
+func (m *mockMailer) SendVerification(ctx context.Context, email, token string) error {
+	m.verificationEmails = append(m.verificationEmails, email)
+	m.verificationTokens = append(m.verificationTokens, token)
+	return m.err
+}
+
+// fixedRefreshToken is an IssueRefreshToken that always succeeds
+func fixedRefreshToken(ctx context.Context, id string, client models.SessionClient) (*models.IssuedRefreshToken, error) {
+	return &models.IssuedRefreshToken{Token: "refresh-token", SessionID: "665f1c2e8b3a4d2f9c1e7a10"}, nil
+}
+
+func newLoginUser(t *testing.T, password string) *models.User {
+	t.Helper()
+	user := &models.User{
+		ID:     bson.NewObjectID(),
+		UserID: "testuser",
+		Email:  "test@example.com",
+	}
+	if err := user.HashPassword(password); err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	return user
+}
+
+// setTestSecret signs and checks the test's tokens with a fixed secret
+func setTestSecret(t *testing.T) {
+	t.Helper()
+	auth.SetSecret("test-secret")
+	t.Cleanup(func() { auth.SetSecret("") })
+}
+
+func TestAuthHandler_Login_Success(t *testing.T) {
+	setTestSecret(t)
+	user := newLoginUser(t, "password123")
+
+	var recordedLogin string
+	var gotClient models.SessionClient
+	mockService := &mockUserService{
+		getUserByUserIDFunc: func(ctx context.Context, userID string) (*models.User, error) {
+			return user, nil
+		},
+		issueRefreshTokenFunc: func(ctx context.Context, id string, client models.SessionClient) (*models.IssuedRefreshToken, error) {
+			gotClient = client
+			return fixedRefreshToken(ctx, id, client)
+		},
+		recordLoginFunc: func(ctx context.Context, id string) error {
+			recordedLogin = id
+			return nil
+		},
+	}
+	handler := NewAuthHandler(mockService, &mockMailer{})
+	e := echo.New()
+
+	reqBody := `{"user_id":"testuser","password":"password123"}`
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(reqBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("User-Agent", "curl/8.5.0")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := handler.Login(c); err != nil {
+		t.Fatalf("Expected no error from handler, got %v", err)
+	}
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	if gotClient.UserAgent != "curl/8.5.0" || gotClient.IP != "192.0.2.1" {
+		t.Errorf("Expected the session to record the client, got %+v", gotClient)
+	}
+
+	if strings.Contains(rec.Body.String(), user.Password) || strings.Contains(rec.Body.String(), "password") {
+		t.Error("Expected response not to contain the password hash")
+	}
+
+	if recordedLogin != user.ID.Hex() {
+		t.Errorf("Expected the login to be recorded for '%s', got '%s'", user.ID.Hex(), recordedLogin)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	token, ok := response["token"].(string)
+	if !ok || token == "" {
+		t.Fatal("Expected response to contain a token")
+	}
+	if claims, err := auth.ParseToken(token); err != nil || claims.SessionID != "665f1c2e8b3a4d2f9c1e7a10" {
+		t.Errorf("Expected the access token to name the session, got %+v (%v)", claims, err)
+	}
+
+	if response["refresh_token"] != "refresh-token" {
+		t.Errorf("Expected response to contain the refresh token, got %v", response["refresh_token"])
+	}
+}
+
+func TestAuthHandler_Login_Rehash(t *testing.T) {
+	setTestSecret(t)
+	defer models.SetPasswordHashAlgorithm(models.PasswordHashBcrypt)
+
+	tests := []struct {
+		name      string
+		algorithm string
+		cost      int
+		rehashErr error
+		rehashed  bool
+	}{
+		{"Same algorithm", models.PasswordHashBcrypt, 4, nil, false},
+		{"Other algorithm", models.PasswordHashArgon2id, 4, nil, true},
+		{"Raised cost", models.PasswordHashBcrypt, 5, nil, true},
+		// A failed rehash is retried on the next login rather than failing this one
+		{"Rehash fails", models.PasswordHashArgon2id, 4, errors.New("connection refused"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			models.SetPasswordHashAlgorithm(models.PasswordHashBcrypt)
+			models.SetBcryptCost(4)
+			t.Cleanup(func() { models.SetBcryptCost(bcrypt.DefaultCost) })
+			user := newLoginUser(t, "password123")
+			models.SetPasswordHashAlgorithm(tt.algorithm)
+			models.SetBcryptCost(tt.cost)
+
+			var rehashed string
+			mockService := &mockUserService{
+				getUserByUserIDFunc: func(ctx context.Context, userID string) (*models.User, error) {
+					return user, nil
+				},
+				issueRefreshTokenFunc: fixedRefreshToken,
+				recordLoginFunc: func(ctx context.Context, id string) error {
+					return nil
+				},
+				rehashPasswordFunc: func(ctx context.Context, u *models.User, password string) error {
+					rehashed = password
+					return tt.rehashErr
+				},
+			}
+			handler := NewAuthHandler(mockService, &mockMailer{})
+			e := echo.New()
+
+			req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"user_id":"testuser","password":"password123"}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			if err := handler.Login(e.NewContext(req, rec)); err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != http.StatusOK {
+				t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+			}
+			if (rehashed == "password123") != tt.rehashed {
+				t.Errorf("Expected rehash %v, got password %q", tt.rehashed, rehashed)
+			}
+		})
+	}
+}
+
+func TestAuthHandler_Login_ByEmail(t *testing.T) {
+	setTestSecret(t)
+	user := newLoginUser(t, "password123")
+
+	mockService := &mockUserService{
+		getUserByEmailFunc: func(ctx context.Context, email string) (*models.User, error) {
+			return user, nil
+		},
+		issueRefreshTokenFunc: fixedRefreshToken,
+	}
+	handler := NewAuthHandler(mockService, &mockMailer{})
+	e := echo.New()
+
+	reqBody := `{"email":"test@example.com","password":"password123"}`
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(reqBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := handler.Login(c); err != nil {
+		t.Fatalf("Expected no error from handler, got %v", err)
+	}
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestAuthHandler_Login_ByIdentifier(t *testing.T) {
+	setTestSecret(t)
+	user := newLoginUser(t, "password123")
+
+	var gotIdentifier string
+	mockService := &mockUserService{
+		getUserByIdentifierFunc: func(ctx context.Context, identifier string) (*models.User, error) {
+			gotIdentifier = identifier
+			if identifier == "test@example.com" {
+				return user, nil
+			}
+			return nil, nil
+		},
+		recordFailedLoginFunc: func(ctx context.Context, id string) (*time.Time, error) {
+			return nil, nil
+		},
+		issueRefreshTokenFunc: fixedRefreshToken,
+	}
+	handler := NewAuthHandler(mockService, &mockMailer{})
+	e := echo.New()
+
+	login := func(body string) (int, string) {
+		req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		if err := handler.Login(c); err != nil {
+			t.Fatalf("Expected no error from handler, got %v", err)
+		}
+		return rec.Code, rec.Body.String()
+	}
+
+	if code, _ := login(`{"identifier":"test@example.com","password":"password123"}`); code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, code)
+	}
+	if gotIdentifier != "test@example.com" {
+		t.Errorf("Expected the identifier to be passed through, got '%s'", gotIdentifier)
+	}
+
+	// An unknown identifier and a wrong password must not be told apart
+	unknownCode, unknownBody := login(`{"identifier":"nobody","password":"password123"}`)
+	wrongCode, wrongBody := login(`{"identifier":"test@example.com","password":"wrong"}`)
+	if unknownCode != http.StatusUnauthorized || wrongCode != http.StatusUnauthorized || unknownBody != wrongBody {
+		t.Errorf("Expected identical 401 responses, got %d %s and %d %s", unknownCode, unknownBody, wrongCode, wrongBody)
+	}
+}
+
+func TestAuthHandler_Login_InvalidCredentials(t *testing.T) {
+	setTestSecret(t)
+	user := newLoginUser(t, "password123")
+
+	tests := []struct {
+		name string
+		user *models.User
+		body string
+	}{
+		{"Wrong password", user, `{"user_id":"testuser","password":"wrongpassword"}`},
+		{"Unknown user", nil, `{"user_id":"nobody","password":"password123"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &mockUserService{
+				getUserByUserIDFunc: func(ctx context.Context, userID string) (*models.User, error) {
+					return tt.user, nil
+				},
+			}
+			handler := NewAuthHandler(mockService, &mockMailer{})
+			e := echo.New()
+
+			req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if err := handler.Login(c); err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+			}
+		})
+	}
+}
+
+func TestAuthHandler_Login_Disabled(t *testing.T) {
+	setTestSecret(t)
+	user := newLoginUser(t, "password123")
+	user.Status = models.StatusDisabled
+
+	tests := []struct {
+		name       string
+		password   string
+		statusCode int
+	}{
+		{"Correct password", "password123", http.StatusForbidden},
+		// A wrong password gets the usual answer, so the status is only revealed to
+		// someone who already holds the credentials
+		{"Wrong password", "wrongpassword", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &mockUserService{
+				getUserByUserIDFunc: func(ctx context.Context, userID string) (*models.User, error) {
+					return user, nil
+				},
+			}
+			handler := NewAuthHandler(mockService, &mockMailer{})
+			e := echo.New()
+
+			reqBody := `{"user_id":"testuser","password":"` + tt.password + `"}`
+			req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(reqBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if err := handler.Login(c); err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != tt.statusCode {
+				t.Errorf("Expected status %d, got %d", tt.statusCode, rec.Code)
+			}
+		})
+	}
+}
+
+func TestAuthHandler_Login_Lockout(t *testing.T) {
+	setTestSecret(t)
+
+	doLogin := func(t *testing.T, mockService *mockUserService, password string) *httptest.ResponseRecorder {
+		t.Helper()
+		handler := NewAuthHandler(mockService, &mockMailer{})
+		e := echo.New()
+
+		body := `{"user_id":"testuser","password":"` + password + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		if err := handler.Login(c); err != nil {
+			t.Fatalf("Expected no error from handler, got %v", err)
+		}
+		return rec
+	}
+
+	t.Run("Locked account is refused even with the right password", func(t *testing.T) {
+		user := newLoginUser(t, "password123")
+		lockedUntil := time.Now().Add(10 * time.Minute)
+		user.LockedUntil = &lockedUntil
+
+		rec := doLogin(t, &mockUserService{
+			getUserByUserIDFunc: func(ctx context.Context, userID string) (*models.User, error) {
+				return user, nil
+			},
+		}, "password123")
+
+		if rec.Code != http.StatusLocked {
+			t.Fatalf("Expected status %d, got %d", http.StatusLocked, rec.Code)
+		}
+		if retryAfter := rec.Header().Get("Retry-After"); retryAfter != "600" {
+			t.Errorf("Expected Retry-After '600', got '%s'", retryAfter)
+		}
+	})
+
+	t.Run("Failure that reaches the threshold locks", func(t *testing.T) {
+		user := newLoginUser(t, "password123")
+		var recorded string
+
+		rec := doLogin(t, &mockUserService{
+			getUserByUserIDFunc: func(ctx context.Context, userID string) (*models.User, error) {
+				return user, nil
+			},
+			recordFailedLoginFunc: func(ctx context.Context, id string) (*time.Time, error) {
+				recorded = id
+				lockedUntil := time.Now().Add(time.Minute)
+				return &lockedUntil, nil
+			},
+		}, "wrongpassword")
+
+		if rec.Code != http.StatusLocked {
+			t.Errorf("Expected status %d, got %d", http.StatusLocked, rec.Code)
+		}
+		if recorded != user.ID.Hex() {
+			t.Errorf("Expected the failure to be recorded for %s, got '%s'", user.ID.Hex(), recorded)
+		}
+	})
+
+	t.Run("Expired lock and counter are reset on success", func(t *testing.T) {
+		user := newLoginUser(t, "password123")
+		lockedUntil := time.Now().Add(-time.Minute)
+		user.LockedUntil = &lockedUntil
+		user.FailedLogins = 2
+		reset := false
+
+		rec := doLogin(t, &mockUserService{
+			getUserByUserIDFunc: func(ctx context.Context, userID string) (*models.User, error) {
+				return user, nil
+			},
+			resetFailedLoginsFunc: func(ctx context.Context, id string) error {
+				reset = true
+				return nil
+			},
+			issueRefreshTokenFunc: fixedRefreshToken,
+		}, "password123")
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+		if !reset {
+			t.Error("Expected failed logins to be reset")
+		}
+	})
+}
+
+func TestAuthHandler_Login_TwoFactor(t *testing.T) {
+	setTestSecret(t)
+	auth.SetEncryptionKey(bytes.Repeat([]byte{1}, auth.EncryptionKeySize))
+	t.Cleanup(func() { auth.SetEncryptionKey(nil) })
+
+	key, err := auth.GenerateTOTPKey("testuser")
+	if err != nil {
+		t.Fatalf("Failed to generate TOTP key: %v", err)
+	}
+	encrypted, err := auth.EncryptSecret(key.Secret())
+	if err != nil {
+		t.Fatalf("Failed to encrypt TOTP secret: %v", err)
+	}
+	code, err := totp.GenerateCode(key.Secret(), time.Now())
+	if err != nil {
+		t.Fatalf("Failed to generate TOTP code: %v", err)
+	}
+	wrongCode := "000000"
+	if code == wrongCode {
+		wrongCode = "111111"
+	}
+
+	tests := []struct {
+		name           string
+		code           string
+		expectedStatus int
+		recorded       bool
+	}{
+		{"Missing code", "", http.StatusUnauthorized, false},
+		{"Wrong code", wrongCode, http.StatusUnauthorized, true},
+		{"Valid code", code, http.StatusOK, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := newLoginUser(t, "password123")
+			user.TwoFactorEnabled = true
+			user.TwoFactorSecret = encrypted
+			recorded := false
+
+			mockService := &mockUserService{
+				getUserByUserIDFunc: func(ctx context.Context, userID string) (*models.User, error) {
+					return user, nil
+				},
+				recordFailedLoginFunc: func(ctx context.Context, id string) (*time.Time, error) {
+					recorded = true
+					return nil, nil
+				},
+				issueRefreshTokenFunc: fixedRefreshToken,
+			}
+			handler := NewAuthHandler(mockService, &mockMailer{})
+			e := echo.New()
+
+			body := `{"user_id":"testuser","password":"password123","totp_code":"` + tt.code + `"}`
+			req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if err := handler.Login(c); err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			if recorded != tt.recorded {
+				t.Errorf("Expected failure recorded to be %v, got %v", tt.recorded, recorded)
+			}
+		})
+	}
+}
+
+func TestAuthHandler_Login_MissingFields(t *testing.T) {
+	handler := NewAuthHandler(&mockUserService{}, &mockMailer{})
+	e := echo.New()
+
+	bodies := []string{
+		`{"password":"password123"}`,
+		`{"user_id":"testuser"}`,
+	}
+
+	for _, body := range bodies {
+		req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		if err := handler.Login(c); err != nil {
+			t.Fatalf("Expected no error from handler, got %v", err)
+		}
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Body %s: expected status %d, got %d", body, http.StatusBadRequest, rec.Code)
+		}
+	}
+}
+
+func TestAuthHandler_Login_ServiceError(t *testing.T) {
+	mockService := &mockUserService{
+		getUserByUserIDFunc: func(ctx context.Context, userID string) (*models.User, error) {
+			return nil, errors.New("database error")
+		},
+	}
+	handler := NewAuthHandler(mockService, &mockMailer{})
+	e := echo.New()
+
+	reqBody := `{"user_id":"testuser","password":"password123"}`
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(reqBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := handler.Login(c); err != nil {
+		t.Fatalf("Expected no error from handler, got %v", err)
+	}
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+	}
+}
+
+func TestAuthHandler_ForgotPassword(t *testing.T) {
+	tests := []struct {
+		name           string
+		tokenErr       error
+		mailErr        error
+		expectedStatus int
+		expectedSent   int
+	}{
+		{"Registered email", nil, nil, http.StatusOK, 1},
+		{"Unknown email", services.ErrUserNotFound, nil, http.StatusOK, 0},
+		{"Mail failure", nil, errors.New("smtp unavailable"), http.StatusOK, 1},
+		{"Service error", errors.New("database error"), nil, http.StatusInternalServerError, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &mockUserService{
+				createPasswordResetTokenFunc: func(ctx context.Context, email string) (string, error) {
+					if tt.tokenErr != nil {
+						return "", tt.tokenErr
+					}
+					return "reset-token", nil
+				},
+			}
+			m := &mockMailer{err: tt.mailErr}
+			handler := NewAuthHandler(mockService, m)
+			e := echo.New()
+			e.Validator = NewValidator()
+
+			reqBody := `{"email":"test@example.com"}`
+			req := httptest.NewRequest(http.MethodPost, "/auth/forgot-password", strings.NewReader(reqBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if err := handler.ForgotPassword(c); err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+
+			if len(m.resetTokens) != tt.expectedSent {
+				t.Fatalf("Expected %d emails sent, got %d", tt.expectedSent, len(m.resetTokens))
+			}
+			if tt.expectedSent > 0 && m.resetTokens[0] != "reset-token" {
+				t.Errorf("Expected token 'reset-token' to be sent, got '%s'", m.resetTokens[0])
+			}
+		})
+	}
+}
+
+func TestAuthHandler_ForgotPassword_InvalidEmail(t *testing.T) {
+	handler := NewAuthHandler(&mockUserService{}, &mockMailer{})
+	e := echo.New()
+	e.Validator = NewValidator()
+
+	reqBody := `{"email":"not-an-email"}`
+	req := httptest.NewRequest(http.MethodPost, "/auth/forgot-password", strings.NewReader(reqBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := handler.ForgotPassword(c); err != nil {
+		t.Fatalf("Expected no error from handler, got %v", err)
+	}
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestAuthHandler_ResetPassword(t *testing.T) {
+	tests := []struct {
+		name           string
+		reqBody        string
+		serviceErr     error
+		expectedStatus int
+	}{
+		{"Valid token", `{"token":"reset-token","new_password":"newpassword123"}`, nil, http.StatusOK},
+		{"Invalid token", `{"token":"reset-token","new_password":"newpassword123"}`, services.ErrInvalidResetToken, http.StatusBadRequest},
+		{"Deleted user", `{"token":"reset-token","new_password":"newpassword123"}`, services.ErrUserNotFound, http.StatusBadRequest},
+		{"Service error", `{"token":"reset-token","new_password":"newpassword123"}`, errors.New("database error"), http.StatusInternalServerError},
+		{"Short password", `{"token":"reset-token","new_password":"123"}`, nil, http.StatusBadRequest},
+		{"Missing token", `{"new_password":"newpassword123"}`, nil, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotToken, gotPassword string
+			mockService := &mockUserService{
+				resetPasswordFunc: func(ctx context.Context, token, newPassword string) error {
+					gotToken, gotPassword = token, newPassword
+					return tt.serviceErr
+				},
+			}
+			handler := NewAuthHandler(mockService, &mockMailer{})
+			e := echo.New()
+			e.Validator = NewValidator()
+
+			req := httptest.NewRequest(http.MethodPost, "/auth/reset-password", strings.NewReader(tt.reqBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if err := handler.ResetPassword(c); err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+
+			if tt.expectedStatus == http.StatusOK && (gotToken != "reset-token" || gotPassword != "newpassword123") {
+				t.Errorf("Expected token and password to reach the service, got '%s' and '%s'", gotToken, gotPassword)
+			}
+		})
+	}
+}
+
+func TestAuthHandler_Refresh(t *testing.T) {
+	tests := []struct {
+		name           string
+		reqBody        string
+		serviceErr     error
+		expectedStatus int
+	}{
+		{"Valid token", `{"refresh_token":"old-token"}`, nil, http.StatusOK},
+		{"Invalid token", `{"refresh_token":"old-token"}`, services.ErrInvalidRefreshToken, http.StatusUnauthorized},
+		{"Service error", `{"refresh_token":"old-token"}`, errors.New("database error"), http.StatusInternalServerError},
+		{"Missing token", `{}`, nil, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestSecret(t)
+			user := newLoginUser(t, "password123")
+
+			var gotToken string
+			mockService := &mockUserService{
+				rotateRefreshTokenFunc: func(ctx context.Context, token string) (*models.User, *models.IssuedRefreshToken, error) {
+					gotToken = token
+					if tt.serviceErr != nil {
+						return nil, nil, tt.serviceErr
+					}
+					return user, &models.IssuedRefreshToken{Token: "new-token", SessionID: "665f1c2e8b3a4d2f9c1e7a10"}, nil
+				},
+			}
+			handler := NewAuthHandler(mockService, &mockMailer{})
+			e := echo.New()
+			e.Validator = NewValidator()
+
+			req := httptest.NewRequest(http.MethodPost, "/auth/refresh", strings.NewReader(tt.reqBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if err := handler.Refresh(c); err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			if gotToken != "old-token" {
+				t.Errorf("Expected the refresh token to reach the service, got '%s'", gotToken)
+			}
+
+			var response models.LoginResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if response.Token == "" || response.RefreshToken != "new-token" {
+				t.Errorf("Expected a new access token and the rotated refresh token, got %+v", response)
+			}
+			if response.User == nil || response.User.UserID != user.UserID {
+				t.Errorf("Expected the token owner, got %+v", response.User)
+			}
+			if claims, err := auth.ParseToken(response.Token); err != nil || claims.SessionID != "665f1c2e8b3a4d2f9c1e7a10" {
+				t.Errorf("Expected the access token to name the session, got %+v (%v)", claims, err)
+			}
+		})
+	}
+}
+
+func TestAuthHandler_Logout(t *testing.T) {
+	tests := []struct {
+		name           string
+		reqBody        string
+		serviceErr     error
+		expectedStatus int
+	}{
+		{"Valid token", `{"refresh_token":"old-token"}`, nil, http.StatusOK},
+		{"Service error", `{"refresh_token":"old-token"}`, errors.New("database error"), http.StatusInternalServerError},
+		{"Missing token", `{}`, nil, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var revoked string
+			mockService := &mockUserService{
+				revokeRefreshTokenFunc: func(ctx context.Context, token string) error {
+					revoked = token
+					return tt.serviceErr
+				},
+			}
+			handler := NewAuthHandler(mockService, &mockMailer{})
+			e := echo.New()
+			e.Validator = NewValidator()
+
+			req := httptest.NewRequest(http.MethodPost, "/auth/logout", strings.NewReader(tt.reqBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if err := handler.Logout(c); err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+
+			if tt.expectedStatus == http.StatusOK && revoked != "old-token" {
+				t.Errorf("Expected the refresh token to be revoked, got '%s'", revoked)
+			}
+		})
+	}
+}
+
+func TestAuthHandler_Login_EmailVerification(t *testing.T) {
+	tests := []struct {
+		name           string
+		required       bool
+		verified       bool
+		expectedStatus int
+	}{
+		{"Not required, unverified", false, false, http.StatusOK},
+		{"Required, unverified", true, false, http.StatusForbidden},
+		{"Required, verified", true, true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestSecret(t)
+			auth.SetRequireEmailVerification(tt.required)
+			t.Cleanup(func() { auth.SetRequireEmailVerification(false) })
+			user := newLoginUser(t, "password123")
+			user.EmailVerified = tt.verified
+
+			mockService := &mockUserService{
+				getUserByUserIDFunc: func(ctx context.Context, userID string) (*models.User, error) {
+					return user, nil
+				},
+				issueRefreshTokenFunc: fixedRefreshToken,
+			}
+			handler := NewAuthHandler(mockService, &mockMailer{})
+			e := echo.New()
+
+			reqBody := `{"user_id":"testuser","password":"password123"}`
+			req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(reqBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if err := handler.Login(c); err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestAuthHandler_VerifyEmail(t *testing.T) {
+	tests := []struct {
+		name           string
+		token          string
+		serviceErr     error
+		expectedStatus int
+	}{
+		{"Valid token", "verify-token", nil, http.StatusOK},
+		{"Invalid token", "verify-token", services.ErrInvalidVerificationToken, http.StatusBadRequest},
+		{"Service error", "verify-token", errors.New("database error"), http.StatusInternalServerError},
+		{"Missing token", "", nil, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotToken string
+			mockService := &mockUserService{
+				verifyEmailFunc: func(ctx context.Context, token string) error {
+					gotToken = token
+					return tt.serviceErr
+				},
+			}
+			handler := NewAuthHandler(mockService, &mockMailer{})
+			e := echo.New()
+
+			req := httptest.NewRequest(http.MethodGet, "/auth/verify?token="+tt.token, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if err := handler.VerifyEmail(c); err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+
+			if tt.token != "" && gotToken != tt.token {
+				t.Errorf("Expected token '%s' to reach the service, got '%s'", tt.token, gotToken)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go-mongodb-test/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// availabilityMinDuration is how long every availability answer takes at least. A
+// found user, a missing one and a cache-warm index all respond alike, so timing
+// does not add to what the rate-limited answer already reveals.
+var availabilityMinDuration = 200 * time.Millisecond
+
+// AvailabilityResponse tells a signup form whether a user_id or email is still free
+type AvailabilityResponse struct {
+	Available bool `json:"available" example:"true"`
+}
+
+// CheckAvailability reports whether a user_id or email can still be registered
+//
+//	@Summary		Check user_id or email availability
+//	@Description	Pass exactly one of user_id or email. Every answer takes at least 200ms, whether or not a user is found.
+//	@Description	The endpoint is rate limited like signup, since it reveals which accounts exist.
+//	@Tags			users
+//	@Produce		json
+//	@Param			user_id	query		string	false	"user_id to check"	example(alice)
+//	@Param			email	query		string	false	"Email to check"	example(alice@example.com)
+//	@Success		200		{object}	AvailabilityResponse
+//	@Failure		400		{object}	ErrorResponse
+//	@Failure		429		{object}	ErrorResponse
+//	@Failure		500		{object}	ErrorResponse
+//	@Failure		504		{object}	ErrorResponse
+//	@Router			/users/available [get]
+func (h *UserHandler) CheckAvailability(c echo.Context) error {
+	userID := c.QueryParam("user_id")
+	email := c.QueryParam("email")
+	if (userID == "") == (email == "") {
+		return errorResponse(c, http.StatusBadRequest, "Exactly one of user_id or email is required")
+	}
+
+	if email != "" {
+		if _, err := models.NormalizeEmail(email); err != nil {
+			return invalidEmailResponse(c)
+		}
+	}
+
+	ctx := c.Request().Context()
+	start := time.Now()
+
+	var (
+		user *models.User
+		err  error
+	)
+	if userID != "" {
+		user, err = h.service(c).GetUserByUserID(ctx, userID)
+	} else {
+		user, err = h.service(c).GetUserByEmail(ctx, email)
+	}
+
+	waitUntil(ctx, start.Add(availabilityMinDuration))
+
+	if err != nil {
+		return serverError(c, err)
+	}
+
+	return respond(c, http.StatusOK, AvailabilityResponse{Available: user == nil}, nil)
+}
+
+// waitUntil sleeps until deadline, returning early if ctx ends first
+func waitUntil(ctx context.Context, deadline time.Time) {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-mongodb-test/models"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestUserHandler_CheckAvailability(t *testing.T) {
+	previous := availabilityMinDuration
+	availabilityMinDuration = 0
+	t.Cleanup(func() { availabilityMinDuration = previous })
+
+	taken := &models.User{ID: bson.NewObjectID(), UserID: "alice", Email: "alice@example.com"}
+
+	tests := []struct {
+		name       string
+		query      string
+		found      *models.User
+		serviceErr error
+		statusCode int
+		available  bool
+	}{
+		{"Free user_id", "?user_id=bob", nil, nil, http.StatusOK, true},
+		{"Taken user_id", "?user_id=alice", taken, nil, http.StatusOK, false},
+		{"Free email", "?email=bob@example.com", nil, nil, http.StatusOK, true},
+		{"Taken email", "?email=alice@example.com", taken, nil, http.StatusOK, false},
+		{"Invalid email", "?email=not-an-email", nil, nil, http.StatusBadRequest, false},
+		{"Neither", "", nil, nil, http.StatusBadRequest, false},
+		{"Both", "?user_id=alice&email=alice@example.com", nil, nil, http.StatusBadRequest, false},
+		{"Server error", "?user_id=alice", nil, errors.New("database error"), http.StatusInternalServerError, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookup := func(ctx context.Context, value string) (*models.User, error) {
+				return tt.found, tt.serviceErr
+			}
+			handler := NewUserHandler(&mockUserService{
+				getUserByUserIDFunc: lookup,
+				getUserByEmailFunc:  lookup,
+			}, &mockMailer{})
+			e := echo.New()
+
+			req := httptest.NewRequest(http.MethodGet, "/users/available"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if err := handler.CheckAvailability(c); err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != tt.statusCode {
+				t.Fatalf("Expected status %d, got %d", tt.statusCode, rec.Code)
+			}
+
+			if tt.statusCode == http.StatusOK {
+				var response AvailabilityResponse
+				if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+					t.Fatalf("Failed to unmarshal response: %v", err)
+				}
+				if response.Available != tt.available {
+					t.Errorf("Expected available %v, got %v", tt.available, response.Available)
+				}
+			}
+		})
+	}
+}
+
+func TestUserHandler_CheckAvailability_MinDuration(t *testing.T) {
+	previous := availabilityMinDuration
+	availabilityMinDuration = 50 * time.Millisecond
+	t.Cleanup(func() { availabilityMinDuration = previous })
+
+	handler := NewUserHandler(&mockUserService{
+		getUserByUserIDFunc: func(ctx context.Context, userID string) (*models.User, error) {
+			return nil, nil
+		},
+	}, &mockMailer{})
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/users/available?user_id=bob", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	start := time.Now()
+	if err := handler.CheckAvailability(c); err != nil {
+		t.Fatalf("Expected no error from handler, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < availabilityMinDuration {
+		t.Errorf("Expected the answer to take at least %v, took %v", availabilityMinDuration, elapsed)
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-mongodb-test/models"
+	"go-mongodb-test/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+var errMultipleETags = errors.New("If-Match must contain a single ETag")
+
+// userETag returns the strong entity tag for a user, derived from updated_at at the
+// millisecond precision MongoDB stores
+func userETag(user *models.User) string {
+	return `"` + strconv.FormatInt(user.UpdatedAt.UnixMilli(), 10) + `"`
+}
+
+// setUserETag adds the user's ETag to the response
+func setUserETag(c echo.Context, user *models.User) {
+	c.Response().Header().Set("ETag", userETag(user))
+}
+
+// setUserLastModified adds the user's updated_at to the response as Last-Modified
+func setUserLastModified(c echo.Context, user *models.User) {
+	c.Response().Header().Set(echo.HeaderLastModified, user.UpdatedAt.UTC().Format(http.TimeFormat))
+}
+
+// notModifiedSince reports whether the If-Modified-Since header names a time no
+// earlier than the user's updated_at, so that the caller's copy is current. HTTP
+// dates have whole seconds, so the comparison is too; a second update within the
+// same second only shows in the ETag. An If-None-Match header or a malformed date
+// leaves the header unused, as HTTP requires.
+func notModifiedSince(c echo.Context, user *models.User) bool {
+	header := c.Request().Header
+	if header.Get("If-None-Match") != "" {
+		return false
+	}
+	since, err := http.ParseTime(header.Get(echo.HeaderIfModifiedSince))
+	if err != nil {
+		return false
+	}
+	return !user.UpdatedAt.Truncate(time.Second).After(since)
+}
+
+// ifMatchVersion reads the If-Match header as the updated_at the caller expects.
+// It returns nil when the header is absent or "*", errMultipleETags for a list,
+// and services.ErrVersionMismatch for a tag that can never match.
+func ifMatchVersion(c echo.Context) (*time.Time, error) {
+	header := strings.TrimSpace(c.Request().Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	if strings.Contains(header, ",") {
+		return nil, errMultipleETags
+	}
+
+	// If-Match uses strong comparison, so weak or malformed tags never match
+	if len(header) < 2 || !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) {
+		return nil, services.ErrVersionMismatch
+	}
+	millis, err := strconv.ParseInt(header[1:len(header)-1], 10, 64)
+	if err != nil {
+		return nil, services.ErrVersionMismatch
+	}
+
+	version := time.UnixMilli(millis)
+	return &version, nil
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-mongodb-test/models"
+	"go-mongodb-test/services"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestIfMatchVersion(t *testing.T) {
+	version := time.UnixMilli(1717502400123)
+
+	tests := []struct {
+		name     string
+		header   string
+		expected *time.Time
+		err      error
+	}{
+		{"Absent", "", nil, nil},
+		{"Wildcard", "*", nil, nil},
+		{"Strong tag", `"1717502400123"`, &version, nil},
+		{"Weak tag", `W/"1717502400123"`, nil, services.ErrVersionMismatch},
+		{"Unquoted", "1717502400123", nil, services.ErrVersionMismatch},
+		{"Not a version", `"abc"`, nil, services.ErrVersionMismatch},
+		{"List", `"1", "2"`, nil, errMultipleETags},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPatch, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("If-Match", tt.header)
+			}
+			c := e.NewContext(req, httptest.NewRecorder())
+
+			got, err := ifMatchVersion(c)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if (got == nil) != (tt.expected == nil) || (got != nil && !got.Equal(*tt.expected)) {
+				t.Errorf("Expected version %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestUserETag_RoundTrip(t *testing.T) {
+	user := &models.User{UpdatedAt: time.Date(2024, 6, 4, 12, 0, 0, 123456789, time.UTC)}
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPatch, "/", nil)
+	req.Header.Set("If-Match", userETag(user))
+	c := e.NewContext(req, httptest.NewRecorder())
+
+	got, err := ifMatchVersion(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !got.Equal(user.UpdatedAt.Truncate(time.Millisecond)) {
+		t.Errorf("Expected version %v, got %v", user.UpdatedAt.Truncate(time.Millisecond), got)
+	}
+}
+
+func TestUserHandler_GetUser_ETag(t *testing.T) {
+	user := &models.User{ID: bson.NewObjectID(), UpdatedAt: time.UnixMilli(1717502400123)}
+	mockService := &mockUserService{
+		getUserByIDFunc: func(ctx context.Context, id string) (*models.User, error) {
+			return user, nil
+		},
+	}
+
+	handler := NewUserHandler(mockService, &mockMailer{})
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/users/"+user.ID.Hex(), nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(user.ID.Hex())
+
+	if err := handler.GetUser(c); err != nil {
+		t.Fatalf("Expected no error from handler, got %v", err)
+	}
+
+	if got := rec.Header().Get("ETag"); got != `"1717502400123"` {
+		t.Errorf("Expected ETag '\"1717502400123\"', got '%s'", got)
+	}
+}
+
+func TestUserHandler_GetUser_IfModifiedSince(t *testing.T) {
+	// updated_at is 2024-06-04 12:00:00.123 UTC
+	user := &models.User{ID: bson.NewObjectID(), UserID: "alice", UpdatedAt: time.UnixMilli(1717502400123)}
+	mockService := &mockUserService{
+		getUserByIDFunc: func(ctx context.Context, id string) (*models.User, error) {
+			return user, nil
+		},
+	}
+
+	tests := []struct {
+		name                 string
+		query                string
+		headers              map[string]string
+		expectedStatus       int
+		expectedLastModified string
+	}{
+		{"No precondition", "", nil, http.StatusOK, "Tue, 04 Jun 2024 12:00:00 GMT"},
+		{"Unchanged", "", map[string]string{"If-Modified-Since": "Tue, 04 Jun 2024 12:00:00 GMT"}, http.StatusNotModified, "Tue, 04 Jun 2024 12:00:00 GMT"},
+		{"Later date", "", map[string]string{"If-Modified-Since": "Wed, 05 Jun 2024 08:00:00 GMT"}, http.StatusNotModified, "Tue, 04 Jun 2024 12:00:00 GMT"},
+		{"Changed since", "", map[string]string{"If-Modified-Since": "Tue, 04 Jun 2024 11:59:59 GMT"}, http.StatusOK, "Tue, 04 Jun 2024 12:00:00 GMT"},
+		{"Malformed date", "", map[string]string{"If-Modified-Since": "yesterday"}, http.StatusOK, "Tue, 04 Jun 2024 12:00:00 GMT"},
+		{"With If-None-Match", "", map[string]string{"If-Modified-Since": "Tue, 04 Jun 2024 12:00:00 GMT", "If-None-Match": `"1"`}, http.StatusOK, "Tue, 04 Jun 2024 12:00:00 GMT"},
+		{"With age", "?include_age=true", map[string]string{"If-Modified-Since": "Tue, 04 Jun 2024 12:00:00 GMT"}, http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewUserHandler(mockService, &mockMailer{})
+			e := echo.New()
+
+			req := httptest.NewRequest(http.MethodGet, "/users/"+user.ID.Hex()+tt.query, nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues(user.ID.Hex())
+
+			if err := handler.GetUser(c); err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			if got := rec.Header().Get(echo.HeaderLastModified); got != tt.expectedLastModified {
+				t.Errorf("Expected Last-Modified '%s', got '%s'", tt.expectedLastModified, got)
+			}
+			if got := rec.Header().Get("ETag"); got != `"1717502400123"` {
+				t.Errorf("Expected ETag '\"1717502400123\"', got '%s'", got)
+			}
+			if tt.expectedStatus == http.StatusNotModified && rec.Body.Len() != 0 {
+				t.Errorf("Expected no body, got %s", rec.Body.String())
+			}
+			if tt.expectedStatus == http.StatusOK && !strings.Contains(rec.Body.String(), `"user_id":"alice"`) {
+				t.Errorf("Expected the user in the body, got %s", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestUserHandler_UpdateUser_IfMatch(t *testing.T) {
+	userID := bson.NewObjectID()
+
+	tests := []struct {
+		name           string
+		ifMatch        string
+		serviceErr     error
+		expectedStatus int
+		expectVersion  bool
+	}{
+		{"No precondition", "", nil, http.StatusOK, false},
+		{"Matching version", `"1717502400123"`, nil, http.StatusOK, true},
+		{"Stale version", `"1717502400123"`, services.ErrVersionMismatch, http.StatusPreconditionFailed, true},
+		{"Weak tag", `W/"1717502400123"`, nil, http.StatusPreconditionFailed, false},
+		{"Multiple tags", `"1", "2"`, nil, http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotVersion *time.Time
+			called := false
+			mockService := &mockUserService{
+				updateUserFunc: func(ctx context.Context, id string, req *models.UpdateUserRequest, expectedUpdatedAt *time.Time) (*models.User, error) {
+					called = true
+					gotVersion = expectedUpdatedAt
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					return &models.User{ID: userID, UpdatedAt: time.UnixMilli(1717502400999)}, nil
+				},
+			}
+
+			handler := NewUserHandler(mockService, &mockMailer{})
+			e := echo.New()
+			e.Validator = NewValidator()
+
+			req := httptest.NewRequest(http.MethodPatch, "/users/"+userID.Hex(), strings.NewReader(`{"user_id":"renamed"}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues(userID.Hex())
+
+			if err := handler.UpdateUser(c); err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+
+			if tt.expectVersion && (gotVersion == nil || gotVersion.UnixMilli() != 1717502400123) {
+				t.Errorf("Expected expected version 1717502400123, got %v", gotVersion)
+			}
+			if !tt.expectVersion && gotVersion != nil {
+				t.Errorf("Expected no version, got %v", gotVersion)
+			}
+			if !called && tt.expectedStatus == http.StatusOK {
+				t.Error("Expected service to be called")
+			}
+
+			if rec.Code == http.StatusOK && rec.Header().Get("ETag") != `"1717502400999"` {
+				t.Errorf("Expected new ETag in response, got '%s'", rec.Header().Get("ETag"))
+			}
+		})
+	}
+}
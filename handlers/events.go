@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go-mongodb-test/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MIMETextEventStream is the media type for server-sent events
+const MIMETextEventStream = "text/event-stream"
+
+// eventHeartbeat is how often an idle stream sends a comment, so that proxies do
+// not close it and a client that has gone away is noticed on the next write
+var eventHeartbeat = 15 * time.Second
+
+// StreamUserEvents streams user changes as server-sent events
+//
+//	@Summary		Stream user events
+//	@Description	Holds the connection open and sends one server-sent event per user created, updated or deleted, from the moment of connecting.
+//	@Description	On a replica set the events follow the users change stream, so they cover every instance and any other client, but carry no actor; otherwise only changes made through this instance are sent.
+//	@Description	Each event is named after the action and carries the same JSON as an audit log entry, with its ID as the event ID. Comments are sent every 15 seconds while idle.
+//	@Description	Changes made while disconnected are not replayed. Only routed when USER_EVENTS_ENABLED is true.
+//	@Tags			users
+//	@Produce		text/event-stream
+//	@Security		bearerauth
+//	@Success		200	{object}	models.AuditEvent	"One data payload per event"
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		403	{object}	ErrorResponse
+//	@Failure		404	{object}	ErrorResponse
+//	@Failure		501	{object}	ErrorResponse	"Not available with STORAGE=memory"
+//	@Router			/users/events [get]
+func (h *UserHandler) StreamUserEvents(c echo.Context) error {
+	ctx := c.Request().Context()
+	events, err := h.service(c).SubscribeUserEvents(ctx)
+	if err != nil {
+		if errors.Is(err, services.ErrEventsDisabled) {
+			return errorResponse(c, http.StatusNotFound, "User events are disabled")
+		}
+		return serverError(c, err)
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, MIMETextEventStream)
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	// Stops nginx from buffering the stream
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+
+	// The subscription ends with the request context, which closes events, so
+	// returning on either path leaves nothing running
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(res, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				return nil
+			}
+			if _, err := fmt.Fprintf(res, "id: %s\nevent: %s\ndata: %s\n\n", event.ID.Hex(), event.Action, data); err != nil {
+				return nil
+			}
+		}
+		res.Flush()
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-mongodb-test/models"
+	"go-mongodb-test/services"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestUserHandler_StreamUserEvents(t *testing.T) {
+	event := &models.AuditEvent{ID: bson.NewObjectID(), TargetID: bson.NewObjectID(), Action: models.AuditActionUpdate, Fields: []string{"email"}}
+
+	events := make(chan *models.AuditEvent, 1)
+	events <- event
+	close(events)
+	handler := NewUserHandler(&mockUserService{
+		subscribeUserEventsFunc: func(ctx context.Context) (<-chan *models.AuditEvent, error) {
+			return events, nil
+		},
+	}, nil)
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/users/events", nil), rec)
+	if err := handler.StreamUserEvents(c); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if rec.Code != http.StatusOK || rec.Header().Get(echo.HeaderContentType) != MIMETextEventStream {
+		t.Fatalf("Expected a 200 event stream, got %d with %q", rec.Code, rec.Header().Get(echo.HeaderContentType))
+	}
+	expected := "id: " + event.ID.Hex() + "\nevent: update\ndata: {\"id\":\"" + event.ID.Hex() + "\""
+	if !strings.HasPrefix(rec.Body.String(), expected) || !strings.HasSuffix(rec.Body.String(), "\n\n") {
+		t.Errorf("Expected one update event, got %q", rec.Body.String())
+	}
+}
+
+func TestUserHandler_StreamUserEvents_Disconnect(t *testing.T) {
+	original := eventHeartbeat
+	eventHeartbeat = time.Millisecond
+	defer func() { eventHeartbeat = original }()
+
+	hub := services.NewEventHub()
+	handler := NewUserHandler(&mockUserService{
+		subscribeUserEventsFunc: func(ctx context.Context) (<-chan *models.AuditEvent, error) {
+			return services.NewUserServiceWithRepository(services.NewInMemoryUserRepository(), services.WithEventHub(hub)).SubscribeUserEvents(ctx)
+		},
+	}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/users/events", nil).WithContext(ctx), rec)
+
+	done := make(chan error, 1)
+	go func() { done <- handler.StreamUserEvents(c) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the stream to end once the client disconnected")
+	}
+
+	if !strings.Contains(rec.Body.String(), ": heartbeat\n\n") {
+		t.Errorf("Expected heartbeats while idle, got %q", rec.Body.String())
+	}
+}
+
+func TestUserHandler_StreamUserEvents_Disabled(t *testing.T) {
+	handler := NewUserHandler(&mockUserService{
+		subscribeUserEventsFunc: func(ctx context.Context) (<-chan *models.AuditEvent, error) {
+			return nil, services.ErrEventsDisabled
+		},
+	}, nil)
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/users/events", nil), rec)
+	if err := handler.StreamUserEvents(c); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
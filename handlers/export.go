@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-mongodb-test/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MIMEApplicationNDJSON is the media type for newline-delimited JSON
+const MIMEApplicationNDJSON = "application/x-ndjson"
+
+// csvExportColumns is the header row of the CSV export
+var csvExportColumns = []string{"id", "user_id", "email", "created_at", "updated_at"}
+
+// csvCell defuses a value that spreadsheets would run as a formula, prefixing it
+// with a single quote when it starts with =, +, -, @, a tab or a carriage return
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// acceptsNDJSON reports whether the Accept header names NDJSON
+func acceptsNDJSON(accept string) bool {
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(mediaRange)
+		if err == nil && mediaType == MIMEApplicationNDJSON {
+			return true
+		}
+	}
+	return false
+}
+
+// ExportUsers streams every user for backups
+//
+//	@Summary		Export users
+//	@Description	Streams every user straight from the database cursor, so memory use stays flat for large collections.
+//	@Description	The body is a JSON array, or one user per line with Accept: application/x-ndjson.
+//	@Description	Errors after the first user has been sent cannot change the status code and end the body early.
+//	@Tags			users
+//	@Produce		json
+//	@Produce		application/x-ndjson
+//	@Security		bearerauth
+//	@Security		apikeyauth
+//	@Param			include_deleted	query		bool	false	"Include soft-deleted users"	example(false)
+//	@Success		200				{array}		models.UserResponse
+//	@Failure		400				{object}	ErrorResponse
+//	@Failure		401				{object}	ErrorResponse
+//	@Failure		403				{object}	ErrorResponse
+//	@Failure		500				{object}	ErrorResponse
+//	@Failure		501				{object}	ErrorResponse	"Not available with STORAGE=memory"
+//	@Failure		504				{object}	ErrorResponse
+//	@Router			/users/export [get]
+func (h *UserHandler) ExportUsers(c echo.Context) error {
+	includeDeleted, err := boolQueryParam(c, "include_deleted")
+	if err != nil {
+		return errorResponse(c, http.StatusBadRequest, err.Error())
+	}
+
+	ndjson := acceptsNDJSON(c.Request().Header.Get(echo.HeaderAccept))
+	contentType := echo.MIMEApplicationJSON
+	if ndjson {
+		contentType = MIMEApplicationNDJSON
+	}
+
+	res := c.Response()
+	encoder := json.NewEncoder(res)
+	exported := 0
+
+	// The status is only committed once the first user is ready, so that a failed
+	// query can still be answered with an error
+	start := func() {
+		res.Header().Set(echo.HeaderContentType, contentType)
+		res.WriteHeader(http.StatusOK)
+		if !ndjson {
+			_, _ = res.Write([]byte("["))
+		}
+	}
+
+	ctx := c.Request().Context()
+	err = h.service(c).ExportUsers(ctx, includeDeleted, func(user *models.User) error {
+		if exported == 0 {
+			start()
+		} else if !ndjson {
+			if _, err := res.Write([]byte(",")); err != nil {
+				return err
+			}
+		}
+		exported++
+		return encoder.Encode(models.NewUserResponse(user))
+	})
+	if err != nil {
+		if exported == 0 {
+			return serverError(c, err)
+		}
+		slog.ErrorContext(ctx, "User export ended early", "error", err, "exported", exported)
+		return nil
+	}
+
+	if exported == 0 {
+		start()
+	}
+	if !ndjson {
+		_, _ = res.Write([]byte("]"))
+	}
+	return nil
+}
+
+// ExportUsersCSV streams every user as CSV for spreadsheets
+//
+//	@Summary		Export users as CSV
+//	@Description	Streams id, user_id, email, created_at and updated_at for every user as a CSV attachment.
+//	@Description	user_id and email values starting with =, +, -, @, a tab or a carriage return are prefixed with ' so that spreadsheets do not run them as formulas.
+//	@Description	Errors after the first row has been sent cannot change the status code and end the body early.
+//	@Tags			users
+//	@Produce		text/csv
+//	@Security		bearerauth
+//	@Security		apikeyauth
+//	@Param			include_deleted	query		bool	false	"Include soft-deleted users"	example(false)
+//	@Success		200				{string}	string	"CSV with a header row"
+//	@Header			200				{string}	Content-Disposition	"Marks the body as the attachment users.csv"
+//	@Failure		400				{object}	ErrorResponse
+//	@Failure		401				{object}	ErrorResponse
+//	@Failure		403				{object}	ErrorResponse
+//	@Failure		500				{object}	ErrorResponse
+//	@Failure		501				{object}	ErrorResponse	"Not available with STORAGE=memory"
+//	@Failure		504				{object}	ErrorResponse
+//	@Router			/users/export.csv [get]
+func (h *UserHandler) ExportUsersCSV(c echo.Context) error {
+	includeDeleted, err := boolQueryParam(c, "include_deleted")
+	if err != nil {
+		return errorResponse(c, http.StatusBadRequest, err.Error())
+	}
+
+	res := c.Response()
+	// csv.Writer quotes fields containing commas, quotes or newlines; csvCell keeps
+	// user-supplied text from being opened as a formula
+	writer := csv.NewWriter(res)
+	exported := 0
+
+	// As with ExportUsers, nothing is committed until the first row is ready
+	start := func() error {
+		res.Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+		res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="users.csv"`)
+		res.WriteHeader(http.StatusOK)
+		return writer.Write(csvExportColumns)
+	}
+
+	ctx := c.Request().Context()
+	err = h.service(c).ExportUsers(ctx, includeDeleted, func(user *models.User) error {
+		if exported == 0 {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		exported++
+		return writer.Write([]string{
+			user.ID.Hex(),
+			csvCell(user.UserID),
+			csvCell(user.Email),
+			user.CreatedAt.UTC().Format(time.RFC3339),
+			user.UpdatedAt.UTC().Format(time.RFC3339),
+		})
+	})
+	if err == nil && exported == 0 {
+		err = start()
+	}
+	if err == nil {
+		writer.Flush()
+		err = writer.Error()
+	}
+	if err != nil {
+		if exported == 0 && !res.Committed {
+			return serverError(c, err)
+		}
+		slog.ErrorContext(ctx, "User CSV export ended early", "error", err, "exported", exported)
+	}
+	return nil
+}
@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-mongodb-test/models"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// exportOf returns an ExportUsers implementation that yields users, then err
+func exportOf(users []*models.User, err error) func(ctx context.Context, includeDeleted bool, fn func(*models.User) error) error {
+	return func(ctx context.Context, includeDeleted bool, fn func(*models.User) error) error {
+		for _, user := range users {
+			if err := fn(user); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+}
+
+func TestAcceptsNDJSON(t *testing.T) {
+	tests := []struct {
+		accept   string
+		expected bool
+	}{
+		{"", false},
+		{"application/json", false},
+		{"application/x-ndjson", true},
+		{"application/json;q=0.5, application/x-ndjson", true},
+		{"*/*", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			if got := acceptsNDJSON(tt.accept); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestUserHandler_ExportUsers(t *testing.T) {
+	users := []*models.User{
+		{ID: bson.NewObjectID(), UserID: "alice", Email: "alice@example.com", Password: "hash"},
+		{ID: bson.NewObjectID(), UserID: "bob", Email: "bob@example.com", Password: "hash"},
+	}
+
+	t.Run("JSON array", func(t *testing.T) {
+		var gotIncludeDeleted bool
+		handler := NewUserHandler(&mockUserService{
+			exportUsersFunc: func(ctx context.Context, includeDeleted bool, fn func(*models.User) error) error {
+				gotIncludeDeleted = includeDeleted
+				return exportOf(users, nil)(ctx, includeDeleted, fn)
+			},
+		}, &mockMailer{})
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, "/users/export?include_deleted=true", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		if err := handler.ExportUsers(c); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+		if contentType := rec.Header().Get(echo.HeaderContentType); contentType != echo.MIMEApplicationJSON {
+			t.Errorf("Expected JSON content type, got '%s'", contentType)
+		}
+		if !gotIncludeDeleted {
+			t.Error("Expected include_deleted to be passed to the service")
+		}
+
+		var exported []map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &exported); err != nil {
+			t.Fatalf("Expected a JSON array, got %q: %v", rec.Body.String(), err)
+		}
+		if len(exported) != 2 || exported[0]["user_id"] != "alice" || exported[1]["user_id"] != "bob" {
+			t.Errorf("Expected alice and bob, got %v", exported)
+		}
+		if _, ok := exported[0]["password"]; ok {
+			t.Error("Expected password to be omitted")
+		}
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		handler := NewUserHandler(&mockUserService{exportUsersFunc: exportOf(users, nil)}, &mockMailer{})
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, "/users/export", nil)
+		req.Header.Set(echo.HeaderAccept, MIMEApplicationNDJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		if err := handler.ExportUsers(c); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if contentType := rec.Header().Get(echo.HeaderContentType); contentType != MIMEApplicationNDJSON {
+			t.Errorf("Expected NDJSON content type, got '%s'", contentType)
+		}
+
+		lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("Expected 2 lines, got %q", rec.Body.String())
+		}
+		for i, line := range lines {
+			var user models.User
+			if err := json.Unmarshal([]byte(line), &user); err != nil {
+				t.Fatalf("Line %d is not a JSON object: %v", i+1, err)
+			}
+			if user.UserID != users[i].UserID {
+				t.Errorf("Line %d: expected user_id '%s', got '%s'", i+1, users[i].UserID, user.UserID)
+			}
+		}
+	})
+
+	t.Run("Empty collection", func(t *testing.T) {
+		handler := NewUserHandler(&mockUserService{exportUsersFunc: exportOf(nil, nil)}, &mockMailer{})
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, "/users/export", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		if err := handler.ExportUsers(c); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if rec.Code != http.StatusOK || rec.Body.String() != "[]" {
+			t.Errorf("Expected 200 with an empty array, got %d %q", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("Failure before the first user", func(t *testing.T) {
+		handler := NewUserHandler(&mockUserService{exportUsersFunc: exportOf(nil, errors.New("database error"))}, &mockMailer{})
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, "/users/export", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		if err := handler.ExportUsers(c); err != nil {
+			t.Fatalf("Expected no error from handler, got %v", err)
+		}
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+		}
+	})
+
+	t.Run("Failure mid-stream leaves the array unterminated", func(t *testing.T) {
+		handler := NewUserHandler(&mockUserService{exportUsersFunc: exportOf(users[:1], errors.New("cursor error"))}, &mockMailer{})
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, "/users/export", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		if err := handler.ExportUsers(c); err != nil {
+			t.Fatalf("Expected no error from handler, got %v", err)
+		}
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected the committed status %d, got %d", http.StatusOK, rec.Code)
+		}
+		if json.Valid(rec.Body.Bytes()) {
+			t.Errorf("Expected a truncated body, got %q", rec.Body.String())
+		}
+	})
+
+	t.Run("Invalid include_deleted", func(t *testing.T) {
+		handler := NewUserHandler(&mockUserService{}, &mockMailer{})
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, "/users/export?include_deleted=maybe", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		if err := handler.ExportUsers(c); err != nil {
+			t.Fatalf("Expected no error from handler, got %v", err)
+		}
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+		}
+	})
+}
+
+func TestUserHandler_ExportUsersCSV(t *testing.T) {
+	created := time.Date(2024, 6, 4, 12, 0, 0, 0, time.UTC)
+	users := []*models.User{
+		{ID: bson.NewObjectID(), UserID: "alice", Email: "alice@example.com", CreatedAt: created, UpdatedAt: created},
+		{ID: bson.NewObjectID(), UserID: `bob, "the builder"`, Email: "bob@example.com", CreatedAt: created, UpdatedAt: created},
+	}
+
+	t.Run("Rows", func(t *testing.T) {
+		handler := NewUserHandler(&mockUserService{exportUsersFunc: exportOf(users, nil)}, &mockMailer{})
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, "/users/export.csv", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		if err := handler.ExportUsersCSV(c); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+		if contentType := rec.Header().Get(echo.HeaderContentType); !strings.HasPrefix(contentType, "text/csv") {
+			t.Errorf("Expected CSV content type, got '%s'", contentType)
+		}
+		if disposition := rec.Header().Get(echo.HeaderContentDisposition); !strings.HasPrefix(disposition, "attachment;") || !strings.Contains(disposition, "users.csv") {
+			t.Errorf("Expected an attachment disposition, got '%s'", disposition)
+		}
+
+		records, err := csv.NewReader(rec.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("Expected valid CSV, got %v", err)
+		}
+		if len(records) != 3 {
+			t.Fatalf("Expected a header and 2 rows, got %v", records)
+		}
+		if strings.Join(records[0], ",") != "id,user_id,email,created_at,updated_at" {
+			t.Errorf("Unexpected header row %v", records[0])
+		}
+		expected := []string{users[1].ID.Hex(), `bob, "the builder"`, "bob@example.com", "2024-06-04T12:00:00Z", "2024-06-04T12:00:00Z"}
+		if strings.Join(records[2], "|") != strings.Join(expected, "|") {
+			t.Errorf("Expected row %v, got %v", expected, records[2])
+		}
+	})
+
+	t.Run("Formulas", func(t *testing.T) {
+		formulas := []*models.User{
+			{ID: bson.NewObjectID(), UserID: `=HYPERLINK("https://evil.example.com","x")`, Email: "+1@example.com", CreatedAt: created, UpdatedAt: created},
+		}
+		handler := NewUserHandler(&mockUserService{exportUsersFunc: exportOf(formulas, nil)}, &mockMailer{})
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, "/users/export.csv", nil)
+		rec := httptest.NewRecorder()
+		if err := handler.ExportUsersCSV(e.NewContext(req, rec)); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		records, err := csv.NewReader(rec.Body).ReadAll()
+		if err != nil || len(records) != 2 {
+			t.Fatalf("Expected a header and 1 row, got %v (%v)", records, err)
+		}
+		if records[1][1] != `'=HYPERLINK("https://evil.example.com","x")` || records[1][2] != "'+1@example.com" {
+			t.Errorf("Expected formula cells to be prefixed with a quote, got %v", records[1])
+		}
+	})
+
+	t.Run("Formulas", func(t *testing.T) {
+		formulas := []*models.User{
+			{ID: bson.NewObjectID(), UserID: `=HYPERLINK("https://evil.example.com","x")`, Email: "+1@example.com", CreatedAt: created, UpdatedAt: created},
+		}
+		handler := NewUserHandler(&mockUserService{exportUsersFunc: exportOf(formulas, nil)}, &mockMailer{})
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, "/users/export.csv", nil)
+		rec := httptest.NewRecorder()
+		if err := handler.ExportUsersCSV(e.NewContext(req, rec)); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		records, err := csv.NewReader(rec.Body).ReadAll()
+		if err != nil || len(records) != 2 {
+			t.Fatalf("Expected a header and 1 row, got %v (%v)", records, err)
+		}
+		if records[1][1] != `'=HYPERLINK("https://evil.example.com","x")` || records[1][2] != "'+1@example.com" {
+			t.Errorf("Expected formula cells to be prefixed with a quote, got %v", records[1])
+		}
+	})
+
+	t.Run("Empty collection", func(t *testing.T) {
+		handler := NewUserHandler(&mockUserService{exportUsersFunc: exportOf(nil, nil)}, &mockMailer{})
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, "/users/export.csv", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		if err := handler.ExportUsersCSV(c); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if rec.Code != http.StatusOK || rec.Body.String() != "id,user_id,email,created_at,updated_at\n" {
+			t.Errorf("Expected 200 with only the header row, got %d %q", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("Failure before the first row", func(t *testing.T) {
+		handler := NewUserHandler(&mockUserService{exportUsersFunc: exportOf(nil, errors.New("database error"))}, &mockMailer{})
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, "/users/export.csv", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		if err := handler.ExportUsersCSV(c); err != nil {
+			t.Fatalf("Expected no error from handler, got %v", err)
+		}
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+		}
+		if rec.Header().Get(echo.HeaderContentDisposition) != "" {
+			t.Error("Expected no attachment header on an error response")
+		}
+	})
+}
+
+func TestCSVCell(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"alice", "alice"},
+		{"", ""},
+		{"=1+1", "'=1+1"},
+		{"+1", "'+1"},
+		{"-1", "'-1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\tcmd", "'\tcmd"},
+		{"\rcmd", "'\rcmd"},
+		{"a=b", "a=b"},
+	}
+
+	for _, tt := range tests {
+		if got := csvCell(tt.value); got != tt.expected {
+			t.Errorf("csvCell(%q) = %q, expected %q", tt.value, got, tt.expected)
+		}
+	}
+}
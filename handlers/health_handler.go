@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"go-mongodb-test/version"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HealthCheckTimeout bounds how long a readiness probe waits on the database
+const HealthCheckTimeout = 2 * time.Second
+
+// Pinger is implemented by dependencies that can report their reachability
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+type HealthHandler struct {
+	db Pinger
+
+	// started is set once startup work such as index creation has finished; until
+	// then Ready fails so that load balancers hold traffic back
+	started atomic.Bool
+}
+
+func NewHealthHandler(db Pinger) *HealthHandler {
+	return &HealthHandler{
+		db: db,
+	}
+}
+
+// Live reports that the process is up without touching any dependency
+func (h *HealthHandler) Live(c echo.Context) error {
+	return respond(c, http.StatusOK, map[string]string{
+		"status":  "healthy",
+		"message": "User management service is running",
+	}, nil)
+}
+
+// MarkStarted lets Ready report healthy; call it once the database is connected and
+// its indexes exist, so that no request can race the unique constraints
+func (h *HealthHandler) MarkStarted() {
+	h.started.Store(true)
+}
+
+// Ready reports whether startup has finished and MongoDB is reachable
+func (h *HealthHandler) Ready(c echo.Context) error {
+	if !h.started.Load() {
+		return respond(c, http.StatusServiceUnavailable, map[string]string{
+			"status": "starting",
+			"error":  "startup has not finished",
+		}, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), HealthCheckTimeout)
+	defer cancel()
+
+	if err := h.db.Ping(ctx); err != nil {
+		return respond(c, http.StatusServiceUnavailable, map[string]string{
+			"status": "unhealthy",
+			"error":  err.Error(),
+		}, nil)
+	}
+
+	return respond(c, http.StatusOK, map[string]string{
+		"status": "healthy",
+	}, nil)
+}
+
+// Version reports the deployed build and the MongoDB driver it was built with
+func (h *HealthHandler) Version(c echo.Context) error {
+	return respond(c, http.StatusOK, version.Get(), nil)
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-mongodb-test/version"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Mock Pinger for testing
+type mockPinger struct {
+	err error
+}
+
+func (m *mockPinger) Ping(ctx context.Context) error {
+	return m.err
+}
+
+func TestHealthHandler_Live(t *testing.T) {
+	handler := NewHealthHandler(&mockPinger{err: errors.New("connection refused")})
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/health/live", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := handler.Live(c); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestHealthHandler_Ready(t *testing.T) {
+	tests := []struct {
+		name       string
+		started    bool
+		pingErr    error
+		statusCode int
+		status     string
+	}{
+		{"Database reachable", true, nil, http.StatusOK, "healthy"},
+		{"Database unreachable", true, errors.New("connection refused"), http.StatusServiceUnavailable, "unhealthy"},
+		{"Startup not finished", false, nil, http.StatusServiceUnavailable, "starting"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHealthHandler(&mockPinger{err: tt.pingErr})
+			if tt.started {
+				handler.MarkStarted()
+			}
+			e := echo.New()
+
+			req := httptest.NewRequest(http.MethodGet, "/health/ready", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if err := handler.Ready(c); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if rec.Code != tt.statusCode {
+				t.Errorf("Expected status %d, got %d", tt.statusCode, rec.Code)
+			}
+
+			var response map[string]string
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+
+			if response["status"] != tt.status {
+				t.Errorf("Expected status '%s', got '%s'", tt.status, response["status"])
+			}
+		})
+	}
+}
+
+func TestHealthHandler_Version(t *testing.T) {
+	handler := NewHealthHandler(&mockPinger{err: errors.New("connection refused")})
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/version", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := handler.Version(c); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	var info version.Info
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if info.Version != version.Version || info.GoVersion == "" || info.MongoDBDriver == "" {
+		t.Errorf("Expected build info, got %+v", info)
+	}
+}
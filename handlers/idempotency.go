@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"go-mongodb-test/models"
+	"go-mongodb-test/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// HeaderIdempotencyKey lets a client retry a signup without creating a second user
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed marks a response repeated for an Idempotency-Key
+	// instead of produced by a new signup
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+	// maxIdempotencyKeyLength bounds the keys stored, which a UUID fits many times over
+	maxIdempotencyKeyLength = 255
+)
+
+// createUserRequestHash identifies a signup body, so that reusing its key for a
+// different one can be refused. The password is left out, which keeps even a digest
+// of it from being stored; a retry that only changes the password counts as the same.
+func createUserRequestHash(req *models.CreateUserRequest) string {
+	hashed := *req
+	hashed.Password = ""
+	body, _ := json.Marshal(hashed)
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// replayCreateUser answers a signup whose Idempotency-Key has already created a
+// user with that user, as the first response did, rather than creating another
+func (h *UserHandler) replayCreateUser(c echo.Context, record *models.IdempotencyKey, requestHash string) error {
+	if record.RequestHash != requestHash {
+		return errorResponse(c, http.StatusUnprocessableEntity, "Idempotency-Key has already been used with a different request")
+	}
+
+	user, err := h.service(c).GetUserByID(c.Request().Context(), record.UserID.Hex())
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			return errorResponse(c, http.StatusConflict, "The user created with this Idempotency-Key no longer exists")
+		}
+		return serverError(c, err)
+	}
+
+	c.Response().Header().Set(HeaderIdempotentReplayed, "true")
+	setUserLocation(c, user)
+	return respond(c, http.StatusCreated, models.NewUserResponse(user), nil)
+}
+
+// setUserLocation points the Location header at user, building it from the request
+// path so that it follows the configured API prefix
+func setUserLocation(c echo.Context, user *models.User) {
+	location := strings.TrimSuffix(c.Request().URL.Path, "/") + "/" + user.ID.Hex()
+	c.Response().Header().Set(echo.HeaderLocation, location)
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-mongodb-test/models"
+	"go-mongodb-test/services"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestUserHandler_CreateUser_IdempotencyKey(t *testing.T) {
+	const body = `{"user_id":"alice","email":"alice@example.com","password":"s3cretpass"}`
+
+	// newService keeps keys in a map and counts the users it creates
+	newService := func(created *int) *mockUserService {
+		keys := map[string]*models.IdempotencyKey{}
+		users := map[bson.ObjectID]*models.User{}
+		return &mockUserService{
+			createUserFunc: func(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
+				*created++
+				user := &models.User{ID: bson.NewObjectID(), UserID: req.UserID, Email: req.Email}
+				users[user.ID] = user
+				return user, nil
+			},
+			getUserByIDFunc: func(ctx context.Context, id string) (*models.User, error) {
+				objectID, _ := bson.ObjectIDFromHex(id)
+				if user, ok := users[objectID]; ok {
+					return user, nil
+				}
+				return nil, services.ErrUserNotFound
+			},
+			getIdempotencyKeyFunc: func(ctx context.Context, key string) (*models.IdempotencyKey, error) {
+				return keys[key], nil
+			},
+			saveIdempotencyKeyFunc: func(ctx context.Context, key, requestHash string, userID bson.ObjectID) error {
+				keys[key] = &models.IdempotencyKey{Key: key, RequestHash: requestHash, UserID: userID}
+				return nil
+			},
+		}
+	}
+
+	send := func(t *testing.T, handler *UserHandler, key, reqBody string) *httptest.ResponseRecorder {
+		t.Helper()
+		e := echo.New()
+		e.Validator = NewValidator()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/users", strings.NewReader(reqBody))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if key != "" {
+			req.Header.Set(HeaderIdempotencyKey, key)
+		}
+		rec := httptest.NewRecorder()
+		if err := handler.CreateUser(e.NewContext(req, rec)); err != nil {
+			t.Fatalf("Expected no error from handler, got %v", err)
+		}
+		return rec
+	}
+
+	t.Run("Retry returns the original user", func(t *testing.T) {
+		var created int
+		handler := NewUserHandler(newService(&created), &mockMailer{})
+
+		first := send(t, handler, "key-1", body)
+		retry := send(t, handler, "key-1", body)
+
+		if first.Code != http.StatusCreated || retry.Code != http.StatusCreated {
+			t.Fatalf("Expected both responses to be 201, got %d and %d", first.Code, retry.Code)
+		}
+		if created != 1 {
+			t.Errorf("Expected one user to be created, got %d", created)
+		}
+		if retry.Body.String() != first.Body.String() || retry.Header().Get(echo.HeaderLocation) != first.Header().Get(echo.HeaderLocation) {
+			t.Errorf("Expected the retry to repeat the first response, got %s and %s", first.Body.String(), retry.Body.String())
+		}
+		if first.Header().Get(HeaderIdempotentReplayed) != "" || retry.Header().Get(HeaderIdempotentReplayed) != "true" {
+			t.Error("Expected only the retry to be marked as replayed")
+		}
+	})
+
+	t.Run("Different keys create separately", func(t *testing.T) {
+		var created int
+		handler := NewUserHandler(newService(&created), &mockMailer{})
+
+		send(t, handler, "key-1", body)
+		send(t, handler, "key-2", body)
+		send(t, handler, "", body)
+
+		if created != 3 {
+			t.Errorf("Expected three users to be created, got %d", created)
+		}
+	})
+
+	t.Run("Key reused with a different body", func(t *testing.T) {
+		var created int
+		handler := NewUserHandler(newService(&created), &mockMailer{})
+
+		send(t, handler, "key-1", body)
+		rec := send(t, handler, "key-1", `{"user_id":"bob","email":"bob@example.com","password":"s3cretpass"}`)
+
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, rec.Code)
+		}
+		if created != 1 {
+			t.Errorf("Expected no second user, got %d", created)
+		}
+	})
+
+	t.Run("Original user deleted", func(t *testing.T) {
+		var created int
+		service := newService(&created)
+		handler := NewUserHandler(service, &mockMailer{})
+
+		send(t, handler, "key-1", body)
+		service.getUserByIDFunc = func(ctx context.Context, id string) (*models.User, error) {
+			return nil, services.ErrUserNotFound
+		}
+		rec := send(t, handler, "key-1", body)
+
+		if rec.Code != http.StatusConflict {
+			t.Errorf("Expected status %d, got %d", http.StatusConflict, rec.Code)
+		}
+	})
+
+	t.Run("Storage without keys ignores the header", func(t *testing.T) {
+		var created int
+		service := newService(&created)
+		service.getIdempotencyKeyFunc = func(ctx context.Context, key string) (*models.IdempotencyKey, error) {
+			return nil, services.ErrStorageUnsupported
+		}
+		service.saveIdempotencyKeyFunc = nil
+		handler := NewUserHandler(service, &mockMailer{})
+
+		if rec := send(t, handler, "key-1", body); rec.Code != http.StatusCreated {
+			t.Errorf("Expected status %d, got %d", http.StatusCreated, rec.Code)
+		}
+		if created != 1 {
+			t.Errorf("Expected the user to be created, got %d", created)
+		}
+	})
+
+	t.Run("Key too long", func(t *testing.T) {
+		var created int
+		handler := NewUserHandler(newService(&created), &mockMailer{})
+
+		rec := send(t, handler, strings.Repeat("k", maxIdempotencyKeyLength+1), body)
+		if rec.Code != http.StatusBadRequest || created != 0 {
+			t.Errorf("Expected a 400 without creating a user, got %d (created %d)", rec.Code, created)
+		}
+	})
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"go-mongodb-test/auth"
+	"go-mongodb-test/models"
+	"go-mongodb-test/services"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// MaxImportRows caps how many users one CSV import may create
+const MaxImportRows = 1000
+
+// importRow is a parsed CSV data row, or the reason it could not be parsed
+type importRow struct {
+	line int
+	req  models.CreateUserRequest
+	err  error
+}
+
+// readImportRows parses an uploaded CSV whose header names the user_id, email and
+// optionally password columns, in any order; other columns, such as those of the
+// CSV export, are ignored. Malformed rows are returned with their error so that
+// the rest of the file can still be imported.
+func readImportRows(r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("CSV file is empty")
+		}
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		// Spreadsheets often save UTF-8 with a byte order mark
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if _, ok := columns[name]; !ok {
+			columns[name] = i
+		}
+	}
+	for _, required := range []string{"user_id", "email"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV header must include a %s column", required)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rows = append(rows, importRow{line: parseErr.StartLine, err: parseErr.Err})
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		} else {
+			line, _ := reader.FieldPos(0)
+			rows = append(rows, importRow{line: line, req: models.CreateUserRequest{
+				UserID:   field(record, "user_id"),
+				Email:    field(record, "email"),
+				Password: field(record, "password"),
+			}})
+		}
+
+		if len(rows) > MaxImportRows {
+			return nil, fmt.Errorf("CSV file must not contain more than %d rows", MaxImportRows)
+		}
+	}
+}
+
+// rowValidationMessage flattens a validation failure into one line for the import summary
+func rowValidationMessage(err error) string {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return err.Error()
+	}
+
+	messages := make([]string, 0, len(validationErrs))
+	for _, fieldErr := range validationErrs {
+		messages = append(messages, fieldErr.Field()+" "+fieldErrorMessage(fieldErr))
+	}
+	return strings.Join(messages, "; ")
+}
+
+// ImportUsers creates users in bulk from an uploaded CSV file
+//
+//	@Summary		Import users from CSV
+//	@Description	Creates a user for every row of the uploaded CSV. The header must name user_id and email columns; a password column is optional.
+//	@Description	Rows without a password get a random one, so those users set their own through forgot-password. Verification emails are sent as on signup.
+//	@Description	Rows whose user_id or email already exists are skipped, and malformed or invalid rows are reported as failed without stopping the import.
+//	@Tags			users
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Security		bearerauth
+//	@Security		apikeyauth
+//	@Param			file	formData	file	true	"CSV file with a header row (at most 1000 rows)"
+//	@Success		200		{object}	ImportUsersResponse
+//	@Failure		400		{object}	ErrorResponse
+//	@Failure		401		{object}	ErrorResponse
+//	@Failure		403		{object}	ErrorResponse
+//	@Failure		504		{object}	ErrorResponse
+//	@Router			/users/import [post]
+func (h *UserHandler) ImportUsers(c echo.Context) error {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return errorResponse(c, http.StatusBadRequest, "A CSV file is required in the file form field")
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return serverError(c, err)
+	}
+	defer file.Close()
+
+	rows, err := readImportRows(file)
+	if err != nil {
+		return errorResponse(c, http.StatusBadRequest, err.Error())
+	}
+
+	ctx := c.Request().Context()
+	summary := ImportUsersResponse{
+		Created: []ImportRowResult{},
+		Skipped: []ImportRowResult{},
+		Failed:  []ImportRowResult{},
+	}
+
+	for _, row := range rows {
+		result := ImportRowResult{Row: row.line, UserID: row.req.UserID}
+		if row.err != nil {
+			result.Error = row.err.Error()
+			summary.Failed = append(summary.Failed, result)
+			continue
+		}
+
+		if row.req.Password == "" {
+			password, err := auth.GenerateRandomToken()
+			if err != nil {
+				return serverError(c, err)
+			}
+			row.req.Password = password
+		}
+
+		if err := c.Validate(&row.req); err != nil {
+			result.Error = rowValidationMessage(err)
+			summary.Failed = append(summary.Failed, result)
+			continue
+		}
+
+		user, err := h.service(c).CreateUser(ctx, &row.req)
+		switch {
+		case err == nil:
+			result.ID = user.ID.Hex()
+			summary.Created = append(summary.Created, result)
+			if err := h.mailer.SendVerification(ctx, user.Email, user.VerificationToken); err != nil {
+				slog.ErrorContext(ctx, "Failed to send verification email", "error", err, "row", row.line)
+			}
+		case errors.Is(err, services.ErrUserExists) || errors.Is(err, services.ErrEmailExists):
+			result.Error = err.Error()
+			summary.Skipped = append(summary.Skipped, result)
+		case errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil:
+			// Created rows are skipped on a rerun, so the client can retry the whole file
+			return serverError(c, err)
+		case errors.Is(err, models.ErrInvalidEmail):
+			result.Error = "email must be a valid email address"
+			summary.Failed = append(summary.Failed, result)
+		default:
+			result.Error = err.Error()
+			summary.Failed = append(summary.Failed, result)
+		}
+	}
+
+	return respond(c, http.StatusOK, summary, nil)
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-mongodb-test/models"
+	"go-mongodb-test/services"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// newImportRequest builds a multipart upload carrying body as the file field
+func newImportRequest(t *testing.T, body string) *http.Request {
+	t.Helper()
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	part, err := writer.CreateFormFile("file", "users.csv")
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	if _, err := part.Write([]byte(body)); err != nil {
+		t.Fatalf("Failed to write form file: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close multipart writer: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/users/import", &buf)
+	req.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+	return req
+}
+
+func TestReadImportRows(t *testing.T) {
+	t.Run("Columns in any order", func(t *testing.T) {
+		rows, err := readImportRows(strings.NewReader("\ufeffEmail, user_id ,id\nalice@example.com,alice,123\n"))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(rows) != 1 || rows[0].line != 2 || rows[0].req.UserID != "alice" || rows[0].req.Email != "alice@example.com" {
+			t.Errorf("Unexpected rows %+v", rows)
+		}
+	})
+
+	t.Run("Malformed row", func(t *testing.T) {
+		rows, err := readImportRows(strings.NewReader("user_id,email\nal\"ice,alice@example.com\nbob,bob@example.com\n"))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(rows) != 2 || rows[0].err == nil || rows[1].err != nil || rows[1].line != 3 {
+			t.Errorf("Expected a failed row followed by bob on line 3, got %+v", rows)
+		}
+	})
+
+	headerErrors := map[string]string{
+		"Empty file":     "",
+		"Missing column": "user_id,password\n",
+	}
+	for name, body := range headerErrors {
+		t.Run(name, func(t *testing.T) {
+			if _, err := readImportRows(strings.NewReader(body)); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+
+	t.Run("Too many rows", func(t *testing.T) {
+		var body strings.Builder
+		body.WriteString("user_id,email\n")
+		for i := 0; i <= MaxImportRows; i++ {
+			fmt.Fprintf(&body, "user%d,user%d@example.com\n", i, i)
+		}
+		if _, err := readImportRows(strings.NewReader(body.String())); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+}
+
+func TestUserHandler_ImportUsers(t *testing.T) {
+	t.Run("Summary", func(t *testing.T) {
+		var passwords []string
+		mockService := &mockUserService{
+			createUserFunc: func(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
+				passwords = append(passwords, req.Password)
+				if req.UserID == "taken" {
+					return nil, services.ErrUserExists
+				}
+				return &models.User{ID: bson.NewObjectID(), UserID: req.UserID, Email: req.Email}, nil
+			},
+		}
+		m := &mockMailer{}
+		handler := NewUserHandler(mockService, m)
+		e := echo.New()
+		e.Validator = NewValidator()
+
+		body := "user_id,email,password\n" +
+			"alice,alice@example.com,s3cretpass\n" +
+			"bob,bob@example.com,\n" +
+			"taken,taken@example.com,s3cretpass\n" +
+			"carol,not-an-email,s3cretpass\n" +
+			"dave,\"dave@example.com\n"
+		rec := httptest.NewRecorder()
+		c := e.NewContext(newImportRequest(t, body), rec)
+
+		if err := handler.ImportUsers(c); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+
+		var summary ImportUsersResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if len(summary.Created) != 2 || summary.Created[0].Row != 2 || summary.Created[1].UserID != "bob" || summary.Created[1].ID == "" {
+			t.Errorf("Expected alice and bob to be created, got %+v", summary.Created)
+		}
+		if len(summary.Skipped) != 1 || summary.Skipped[0].Row != 4 {
+			t.Errorf("Expected row 4 to be skipped, got %+v", summary.Skipped)
+		}
+		if len(summary.Failed) != 2 || summary.Failed[0].Row != 5 || summary.Failed[1].Row != 6 {
+			t.Errorf("Expected rows 5 and 6 to fail, got %+v", summary.Failed)
+		}
+		if !strings.Contains(summary.Failed[0].Error, "email") {
+			t.Errorf("Expected an email validation message, got '%s'", summary.Failed[0].Error)
+		}
+
+		if len(passwords) != 3 || passwords[0] != "s3cretpass" || len(passwords[1]) < 6 {
+			t.Errorf("Expected a generated password for bob, got %v", passwords)
+		}
+		if len(m.verificationEmails) != 2 {
+			t.Errorf("Expected 2 verification emails, got %v", m.verificationEmails)
+		}
+	})
+
+	t.Run("Password over 72 bytes", func(t *testing.T) {
+		mockService := &mockUserService{
+			createUserFunc: func(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
+				t.Error("Expected the row to fail validation before reaching the service")
+				return nil, errors.New("unexpected call")
+			},
+		}
+		handler := NewUserHandler(mockService, &mockMailer{})
+		e := echo.New()
+		e.Validator = NewValidator()
+
+		body := "user_id,email,password\nalice,alice@example.com," + strings.Repeat("a", 73) + "\n"
+		rec := httptest.NewRecorder()
+		c := e.NewContext(newImportRequest(t, body), rec)
+
+		if err := handler.ImportUsers(c); err != nil {
+			t.Fatalf("Expected no error from handler, got %v", err)
+		}
+
+		var summary ImportUsersResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if len(summary.Failed) != 1 || !strings.Contains(summary.Failed[0].Error, "at most 72 bytes") {
+			t.Errorf("Expected a byte limit message for the row, got %+v", summary.Failed)
+		}
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		handler := NewUserHandler(&mockUserService{}, &mockMailer{})
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodPost, "/users/import", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		if err := handler.ImportUsers(c); err != nil {
+			t.Fatalf("Expected no error from handler, got %v", err)
+		}
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("Missing column", func(t *testing.T) {
+		handler := NewUserHandler(&mockUserService{}, &mockMailer{})
+		e := echo.New()
+
+		rec := httptest.NewRecorder()
+		c := e.NewContext(newImportRequest(t, "user_id\nalice\n"), rec)
+
+		if err := handler.ImportUsers(c); err != nil {
+			t.Fatalf("Expected no error from handler, got %v", err)
+		}
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		mockService := &mockUserService{
+			createUserFunc: func(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
+				return nil, fmt.Errorf("failed to create user: %w", context.DeadlineExceeded)
+			},
+		}
+		handler := NewUserHandler(mockService, &mockMailer{})
+		e := echo.New()
+		e.Validator = NewValidator()
+
+		rec := httptest.NewRecorder()
+		c := e.NewContext(newImportRequest(t, "user_id,email\nalice,alice@example.com\n"), rec)
+
+		if err := handler.ImportUsers(c); err != nil {
+			t.Fatalf("Expected no error from handler, got %v", err)
+		}
+
+		if rec.Code != http.StatusGatewayTimeout {
+			t.Errorf("Expected status %d, got %d", http.StatusGatewayTimeout, rec.Code)
+		}
+	})
+
+	t.Run("Service error fails the row", func(t *testing.T) {
+		mockService := &mockUserService{
+			createUserFunc: func(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
+				return nil, errors.New("database error")
+			},
+		}
+		handler := NewUserHandler(mockService, &mockMailer{})
+		e := echo.New()
+		e.Validator = NewValidator()
+
+		rec := httptest.NewRecorder()
+		c := e.NewContext(newImportRequest(t, "user_id,email\nalice,alice@example.com\n"), rec)
+
+		if err := handler.ImportUsers(c); err != nil {
+			t.Fatalf("Expected no error from handler, got %v", err)
+		}
+
+		var summary ImportUsersResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if rec.Code != http.StatusOK || len(summary.Failed) != 1 || summary.Failed[0].Error != "database error" {
+			t.Errorf("Expected the row to be reported as failed, got %d %+v", rec.Code, summary)
+		}
+	})
+}
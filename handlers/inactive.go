@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-mongodb-test/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// DefaultInactivePeriod is used when ListInactiveUsers is given no since
+	DefaultInactivePeriod = "30d"
+	// DefaultPageLimit and MaxPageLimit bound the limit query parameter
+	DefaultPageLimit = 50
+	MaxPageLimit     = 200
+)
+
+// pageQueryParams parses the optional limit and offset query parameters
+func pageQueryParams(c echo.Context) (limit, offset int, err error) {
+	limit = DefaultPageLimit
+	if raw := c.QueryParam("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > MaxPageLimit {
+			return 0, 0, fmt.Errorf("limit must be an integer between 1 and %d", MaxPageLimit)
+		}
+	}
+
+	if raw := c.QueryParam("offset"); raw != "" {
+		offset, err = strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("offset must be a non-negative integer")
+		}
+	}
+
+	return limit, offset, nil
+}
+
+// ListInactiveUsers returns users who have not logged in for a while
+//
+//	@Summary		List inactive users
+//	@Description	Returns users whose last login is older than since, including users who have never logged in, for account cleanup.
+//	@Description	Users are ordered oldest account first; page through them with limit and offset until has_more is false.
+//	@Tags			users
+//	@Produce		json
+//	@Security		bearerauth
+//	@Security		apikeyauth
+//	@Param			since	query		string	false	"How long without a login, in days (30d), weeks (2w) or a Go duration (36h)"	default(30d)	example(90d)
+//	@Param			limit	query		int		false	"Users per page, at most 200"	default(50)	example(50)
+//	@Param			offset	query		int		false	"Users to skip"	default(0)	example(0)
+//	@Success		200		{object}	InactiveUsersResponse
+//	@Failure		400		{object}	ErrorResponse
+//	@Failure		401		{object}	ErrorResponse
+//	@Failure		403		{object}	ErrorResponse
+//	@Failure		500		{object}	ErrorResponse
+//	@Failure		501		{object}	ErrorResponse	"Not available with STORAGE=memory"
+//	@Failure		504		{object}	ErrorResponse
+//	@Router			/users/inactive [get]
+func (h *UserHandler) ListInactiveUsers(c echo.Context) error {
+	since := c.QueryParam("since")
+	if since == "" {
+		since = DefaultInactivePeriod
+	}
+	period, err := models.ParsePeriod(since)
+	if err != nil {
+		return errorResponse(c, http.StatusBadRequest, "since must be a positive period such as 30d, 2w or 36h")
+	}
+
+	limit, offset, err := pageQueryParams(c)
+	if err != nil {
+		return errorResponse(c, http.StatusBadRequest, err.Error())
+	}
+
+	cutoff := time.Now().Add(-period).UTC()
+	// One extra user tells whether another page follows without a separate count
+	users, err := h.service(c).ListInactiveUsers(c.Request().Context(), cutoff, limit+1, offset)
+	if err != nil {
+		return serverError(c, err)
+	}
+
+	hasMore := len(users) > limit
+	if hasMore {
+		users = users[:limit]
+	}
+
+	return respond(c, http.StatusOK, UserList{Users: models.NewUserResponses(users)}, InactiveUsersMeta{
+		Count:   len(users),
+		Cutoff:  cutoff,
+		Limit:   limit,
+		Offset:  offset,
+		HasMore: hasMore,
+	})
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-mongodb-test/models"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestUserHandler_ListInactiveUsers(t *testing.T) {
+	inactive := func(n int) []*models.User {
+		users := make([]*models.User, n)
+		for i := range users {
+			users[i] = &models.User{ID: bson.NewObjectID(), UserID: fmt.Sprintf("user%d", i)}
+		}
+		return users
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		found          int
+		expectedStatus int
+		expectedPeriod time.Duration
+		expectedLimit  int
+		expectedOffset int
+		expectedCount  int
+		expectedMore   bool
+	}{
+		{"Defaults", "", 3, http.StatusOK, 30 * 24 * time.Hour, DefaultPageLimit, 0, 3, false},
+		{"Days", "?since=90d", 0, http.StatusOK, 90 * 24 * time.Hour, DefaultPageLimit, 0, 0, false},
+		{"Hours", "?since=36h", 0, http.StatusOK, 36 * time.Hour, DefaultPageLimit, 0, 0, false},
+		{"More pages", "?limit=2&offset=4", 3, http.StatusOK, 30 * 24 * time.Hour, 2, 4, 2, true},
+		{"Last page", "?limit=2&offset=4", 2, http.StatusOK, 30 * 24 * time.Hour, 2, 4, 2, false},
+		{"Invalid since", "?since=soon", 0, http.StatusBadRequest, 0, 0, 0, 0, false},
+		{"Negative since", "?since=-30d", 0, http.StatusBadRequest, 0, 0, 0, 0, false},
+		{"Limit too large", "?limit=201", 0, http.StatusBadRequest, 0, 0, 0, 0, false},
+		{"Negative offset", "?offset=-1", 0, http.StatusBadRequest, 0, 0, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotCutoff time.Time
+			var gotLimit, gotOffset int
+			mockService := &mockUserService{
+				listInactiveUsersFunc: func(ctx context.Context, cutoff time.Time, limit, offset int) ([]*models.User, error) {
+					gotCutoff, gotLimit, gotOffset = cutoff, limit, offset
+					return inactive(tt.found), nil
+				},
+			}
+			handler := NewUserHandler(mockService, &mockMailer{})
+			e := echo.New()
+
+			req := httptest.NewRequest(http.MethodGet, "/users/inactive"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if err := handler.ListInactiveUsers(c); err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			if period := time.Since(gotCutoff); period < tt.expectedPeriod || period > tt.expectedPeriod+time.Minute {
+				t.Errorf("Expected a cutoff %v ago, got %v", tt.expectedPeriod, period)
+			}
+			if gotLimit != tt.expectedLimit+1 || gotOffset != tt.expectedOffset {
+				t.Errorf("Expected limit %d and offset %d, got %d and %d", tt.expectedLimit+1, tt.expectedOffset, gotLimit, gotOffset)
+			}
+
+			var response InactiveUsersResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if response.Count != tt.expectedCount || len(response.Users) != tt.expectedCount || response.HasMore != tt.expectedMore {
+				t.Errorf("Expected %d users and has_more %v, got %+v", tt.expectedCount, tt.expectedMore, response)
+			}
+			if response.Limit != tt.expectedLimit || response.Offset != tt.expectedOffset {
+				t.Errorf("Expected limit %d and offset %d echoed, got %+v", tt.expectedLimit, tt.expectedOffset, response)
+			}
+		})
+	}
+
+	t.Run("Service error", func(t *testing.T) {
+		mockService := &mockUserService{
+			listInactiveUsersFunc: func(ctx context.Context, cutoff time.Time, limit, offset int) ([]*models.User, error) {
+				return nil, errors.New("database error")
+			},
+		}
+		handler := NewUserHandler(mockService, &mockMailer{})
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, "/users/inactive", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		if err := handler.ListInactiveUsers(c); err != nil {
+			t.Fatalf("Expected no error from handler, got %v", err)
+		}
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+		}
+	})
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ListIndexes lists the indexes on the users collection
+//
+//	@Summary		List users collection indexes
+//	@Description	Returns each index with its name, key spec in field order and unique and sparse options, as the server reports them.
+//	@Description	Use it to confirm that the unique user_id and email indexes exist.
+//	@Tags			admin
+//	@Produce		json
+//	@Security		bearerauth
+//	@Success		200	{array}		models.IndexInfo
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		403	{object}	ErrorResponse
+//	@Failure		500	{object}	ErrorResponse
+//	@Failure		501	{object}	ErrorResponse	"Not available with STORAGE=memory"
+//	@Failure		504	{object}	ErrorResponse
+//	@Router			/admin/indexes [get]
+func (h *UserHandler) ListIndexes(c echo.Context) error {
+	indexes, err := h.service(c).ListIndexes(c.Request().Context())
+	if err != nil {
+		return serverError(c, err)
+	}
+
+	return respond(c, http.StatusOK, indexes, nil)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-mongodb-test/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestUserHandler_ListIndexes(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		handler := NewUserHandler(&mockUserService{
+			listIndexesFunc: func(ctx context.Context) ([]models.IndexInfo, error) {
+				return []models.IndexInfo{
+					{Name: "user_id_1", Keys: []models.IndexKey{{Field: "user_id", Order: int64(1)}}, Unique: true},
+				}, nil
+			},
+		}, &mockMailer{})
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, "/admin/indexes", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		if err := handler.ListIndexes(c); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+
+		var indexes []map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &indexes); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if len(indexes) != 1 || indexes[0]["name"] != "user_id_1" || indexes[0]["unique"] != true {
+			t.Errorf("Unexpected indexes %v", indexes)
+		}
+		keys, _ := indexes[0]["keys"].([]interface{})
+		if len(keys) != 1 || keys[0].(map[string]interface{})["order"] != float64(1) {
+			t.Errorf("Expected the key spec, got %v", indexes[0]["keys"])
+		}
+	})
+
+	t.Run("Server error", func(t *testing.T) {
+		handler := NewUserHandler(&mockUserService{
+			listIndexesFunc: func(ctx context.Context) ([]models.IndexInfo, error) {
+				return nil, errors.New("database error")
+			},
+		}, &mockMailer{})
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, "/admin/indexes", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		if err := handler.ListIndexes(c); err != nil {
+			t.Fatalf("Expected no error from handler, got %v", err)
+		}
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+		}
+	})
+}
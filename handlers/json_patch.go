@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"go-mongodb-test/models"
+	"go-mongodb-test/services"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// MIMEApplicationJSONPatch is the media type of an RFC 6902 JSON Patch document
+const MIMEApplicationJSONPatch = "application/json-patch+json"
+
+// isJSONPatch reports whether contentType names a JSON Patch document
+func isJSONPatch(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == MIMEApplicationJSONPatch
+}
+
+// patchableUser is the document a JSON Patch is applied to. Its members are the
+// only paths a patch may change. The password is never read back, so it can only
+// be set with add.
+type patchableUser struct {
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	Password  string `json:"password,omitempty"`
+	FirstName string `json:"first_name,omitempty"`
+	LastName  string `json:"last_name,omitempty"`
+	AvatarURL string `json:"avatar_url,omitempty"`
+}
+
+// updateFromPatch turns the difference between the document before and after a
+// patch into a partial update. A removed profile field becomes "", which clears it.
+func updateFromPatch(before, after patchableUser) *models.UpdateUserRequest {
+	var req models.UpdateUserRequest
+	changed := func(from, to string) *string {
+		if from == to {
+			return nil
+		}
+		return &to
+	}
+
+	req.UserID = changed(before.UserID, after.UserID)
+	req.Email = changed(before.Email, after.Email)
+	req.Password = changed(before.Password, after.Password)
+	req.FirstName = changed(before.FirstName, after.FirstName)
+	req.LastName = changed(before.LastName, after.LastName)
+	req.AvatarURL = changed(before.AvatarURL, after.AvatarURL)
+	return &req
+}
+
+// patchUser applies a JSON Patch body to the user with the given ID. The patch is
+// applied to the stored user and the result saved only if the user is unchanged
+// since it was read, so concurrent writes are never overwritten.
+func (h *UserHandler) patchUser(c echo.Context, id string) error {
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return errorResponse(c, http.StatusBadRequest, "Invalid request body")
+	}
+	patch, err := jsonpatch.DecodePatch(body)
+	if err != nil {
+		return errorResponse(c, http.StatusBadRequest, "Invalid JSON Patch document")
+	}
+
+	ifMatch, err := ifMatchVersion(c)
+	if err != nil {
+		return updateErrorResponse(c, err)
+	}
+
+	ctx := c.Request().Context()
+	user, err := h.service(c).GetUserByID(ctx, id)
+	if err != nil {
+		return updateErrorResponse(c, err)
+	}
+
+	before := patchableUser{
+		UserID:    user.UserID,
+		Email:     user.Email,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		AvatarURL: user.AvatarURL,
+	}
+	original, err := json.Marshal(before)
+	if err != nil {
+		return serverError(c, err)
+	}
+
+	patched, err := patch.Apply(original)
+	if err != nil {
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			return errorResponse(c, http.StatusConflict, "JSON Patch test operation failed")
+		}
+		return errorResponse(c, http.StatusUnprocessableEntity, "JSON Patch could not be applied: "+err.Error())
+	}
+
+	var after patchableUser
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&after); err != nil {
+		return errorResponse(c, http.StatusUnprocessableEntity,
+			"JSON Patch may only change user_id, email, password, first_name, last_name and avatar_url as strings")
+	}
+
+	req := updateFromPatch(before, after)
+	if err := c.Validate(req); err != nil {
+		return validationError(c, err)
+	}
+
+	// Without If-Match, the version read above still guards against a concurrent write
+	expectedUpdatedAt := ifMatch
+	if expectedUpdatedAt == nil {
+		expectedUpdatedAt = &user.UpdatedAt
+	}
+
+	updated, err := h.service(c).UpdateUser(ctx, id, req, expectedUpdatedAt)
+	if err != nil {
+		if ifMatch == nil && errors.Is(err, services.ErrVersionMismatch) {
+			return errorResponse(c, http.StatusConflict, "User was modified while the patch was applied; retry")
+		}
+		return updateErrorResponse(c, err)
+	}
+
+	setUserETag(c, updated)
+	return respond(c, http.StatusOK, models.NewUserResponse(updated), nil)
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-mongodb-test/models"
+	"go-mongodb-test/services"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestIsJSONPatch(t *testing.T) {
+	tests := map[string]bool{
+		"application/json-patch+json":                true,
+		"application/json-patch+json; charset=utf-8": true,
+		"application/json":                           false,
+		"application/merge-patch+json":               false,
+		"":                                           false,
+	}
+	for contentType, expected := range tests {
+		if got := isJSONPatch(contentType); got != expected {
+			t.Errorf("isJSONPatch(%q): expected %v, got %v", contentType, expected, got)
+		}
+	}
+}
+
+func TestUpdateFromPatch(t *testing.T) {
+	before := patchableUser{UserID: "alice", Email: "alice@example.com", FirstName: "Alice", AvatarURL: "https://example.com/a.png"}
+	after := patchableUser{UserID: "alice", Email: "alice@example.org", FirstName: "Alice", Password: "n3wpassword"}
+
+	req := updateFromPatch(before, after)
+	if req.UserID != nil || req.FirstName != nil || req.LastName != nil {
+		t.Errorf("Expected unchanged fields to be left out, got %+v", req)
+	}
+	if req.Email == nil || *req.Email != "alice@example.org" {
+		t.Errorf("Expected the new email, got %v", req.Email)
+	}
+	if req.Password == nil || *req.Password != "n3wpassword" {
+		t.Errorf("Expected the added password, got %v", req.Password)
+	}
+	if req.AvatarURL == nil || *req.AvatarURL != "" {
+		t.Errorf("Expected the removed avatar_url to be cleared, got %v", req.AvatarURL)
+	}
+}
+
+func TestUserHandler_UpdateUser_JSONPatch(t *testing.T) {
+	userID := bson.NewObjectID()
+	updatedAt := time.UnixMilli(1717502400000)
+	stored := func() *models.User {
+		return &models.User{
+			ID:        userID,
+			UserID:    "alice",
+			Email:     "alice@example.com",
+			FirstName: "Alice",
+			AvatarURL: "https://example.com/alice.png",
+			UpdatedAt: updatedAt,
+		}
+	}
+
+	tests := []struct {
+		name           string
+		patch          string
+		ifMatch        string
+		updateErr      error
+		expectedStatus int
+		check          func(t *testing.T, req *models.UpdateUserRequest, expectedUpdatedAt *time.Time)
+	}{
+		{
+			name:           "Remove and replace",
+			patch:          `[{"op":"remove","path":"/avatar_url"},{"op":"replace","path":"/first_name","value":"Alicia"}]`,
+			expectedStatus: http.StatusOK,
+			check: func(t *testing.T, req *models.UpdateUserRequest, expectedUpdatedAt *time.Time) {
+				if req.AvatarURL == nil || *req.AvatarURL != "" {
+					t.Errorf("Expected avatar_url to be cleared, got %v", req.AvatarURL)
+				}
+				if req.FirstName == nil || *req.FirstName != "Alicia" {
+					t.Errorf("Expected first_name Alicia, got %v", req.FirstName)
+				}
+				if req.UserID != nil || req.Email != nil {
+					t.Errorf("Expected untouched fields to be left out, got %+v", req)
+				}
+				if expectedUpdatedAt == nil || !expectedUpdatedAt.Equal(updatedAt) {
+					t.Errorf("Expected the update to be conditional on the version read, got %v", expectedUpdatedAt)
+				}
+			},
+		},
+		{
+			name:           "Passing test operation",
+			patch:          `[{"op":"test","path":"/email","value":"alice@example.com"},{"op":"replace","path":"/email","value":"alice@example.org"}]`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Failing test operation",
+			patch:          `[{"op":"test","path":"/email","value":"bob@example.com"}]`,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "Missing path",
+			patch:          `[{"op":"remove","path":"/last_name"}]`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Unpatchable field",
+			patch:          `[{"op":"add","path":"/roles","value":["admin"]}]`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Wrong type",
+			patch:          `[{"op":"replace","path":"/user_id","value":42}]`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Removing a required field",
+			patch:          `[{"op":"remove","path":"/email"}]`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid avatar",
+			patch:          `[{"op":"replace","path":"/avatar_url","value":"not-a-url"}]`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Not a patch document",
+			patch:          `{"email":"alice@example.org"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "If-Match is used as the version",
+			patch:          `[{"op":"replace","path":"/first_name","value":"Alicia"}]`,
+			ifMatch:        `"1717502300000"`,
+			updateErr:      services.ErrVersionMismatch,
+			expectedStatus: http.StatusPreconditionFailed,
+			check: func(t *testing.T, req *models.UpdateUserRequest, expectedUpdatedAt *time.Time) {
+				if expectedUpdatedAt == nil || expectedUpdatedAt.UnixMilli() != 1717502300000 {
+					t.Errorf("Expected the If-Match version, got %v", expectedUpdatedAt)
+				}
+			},
+		},
+		{
+			name:           "Concurrent write",
+			patch:          `[{"op":"replace","path":"/first_name","value":"Alicia"}]`,
+			updateErr:      services.ErrVersionMismatch,
+			expectedStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &mockUserService{
+				getUserByIDFunc: func(ctx context.Context, id string) (*models.User, error) {
+					return stored(), nil
+				},
+				updateUserFunc: func(ctx context.Context, id string, req *models.UpdateUserRequest, expectedUpdatedAt *time.Time) (*models.User, error) {
+					if tt.check != nil {
+						tt.check(t, req, expectedUpdatedAt)
+					}
+					if tt.updateErr != nil {
+						return nil, tt.updateErr
+					}
+					user := stored()
+					user.UpdatedAt = updatedAt.Add(time.Second)
+					return user, nil
+				},
+			}
+			handler := NewUserHandler(mockService, &mockMailer{})
+			e := echo.New()
+			e.Validator = NewValidator()
+
+			req := httptest.NewRequest(http.MethodPatch, "/users/"+userID.Hex(), strings.NewReader(tt.patch))
+			req.Header.Set(echo.HeaderContentType, MIMEApplicationJSONPatch)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues(userID.Hex())
+
+			if err := handler.UpdateUser(c); err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, rec.Code, rec.Body.String())
+			}
+			if rec.Code == http.StatusOK {
+				var response models.UserResponse
+				if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+					t.Fatalf("Failed to unmarshal response: %v", err)
+				}
+				if rec.Header().Get("ETag") == "" {
+					t.Error("Expected an ETag header")
+				}
+			}
+		})
+	}
+
+	t.Run("User not found", func(t *testing.T) {
+		mockService := &mockUserService{
+			getUserByIDFunc: func(ctx context.Context, id string) (*models.User, error) {
+				return nil, services.ErrUserNotFound
+			},
+		}
+		handler := NewUserHandler(mockService, &mockMailer{})
+		e := echo.New()
+		e.Validator = NewValidator()
+
+		req := httptest.NewRequest(http.MethodPatch, "/users/"+userID.Hex(), strings.NewReader(`[]`))
+		req.Header.Set(echo.HeaderContentType, MIMEApplicationJSONPatch)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(userID.Hex())
+
+		if err := handler.UpdateUser(c); err != nil {
+			t.Fatalf("Expected no error from handler, got %v", err)
+		}
+
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d, got %d", http.StatusNotFound, rec.Code)
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HeaderLink carries the pagination links of a paged list
+const HeaderLink = "Link"
+
+// setPageLinks sets a Link header (RFC 8288) with the first, prev, next and last
+// pages of a list of total items. Each URL is the current request with its limit
+// and offset replaced; prev and next are left out at either end. Pages are counted
+// from offset zero, so last is the final multiple of limit.
+func setPageLinks(c echo.Context, total int64, limit, offset int) {
+	url := c.Request().URL
+	link := func(rel string, offset int) string {
+		query := url.Query()
+		query.Set("limit", strconv.Itoa(limit))
+		query.Set("offset", strconv.Itoa(offset))
+		return fmt.Sprintf(`<%s?%s>; rel="%s"`, url.Path, query.Encode(), rel)
+	}
+
+	last := 0
+	if total > 0 {
+		last = int((total-1)/int64(limit)) * limit
+	}
+
+	links := []string{link("first", 0)}
+	if offset > 0 {
+		links = append(links, link("prev", max(offset-limit, 0)))
+	}
+	if int64(offset+limit) < total {
+		links = append(links, link("next", offset+limit))
+	}
+	links = append(links, link("last", last))
+	c.Response().Header().Set(HeaderLink, strings.Join(links, ", "))
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"go-mongodb-test/auth"
+	"go-mongodb-test/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MaintenanceSwitch turns maintenance mode on and off, as middlewares.Maintenance does
+type MaintenanceSwitch interface {
+	Enabled() bool
+	SetEnabled(enabled bool)
+}
+
+type MaintenanceHandler struct {
+	maintenance MaintenanceSwitch
+}
+
+func NewMaintenanceHandler(maintenance MaintenanceSwitch) *MaintenanceHandler {
+	return &MaintenanceHandler{
+		maintenance: maintenance,
+	}
+}
+
+// SetMaintenance turns maintenance mode on or off without a restart
+//
+//	@Summary		Turn maintenance mode on or off (admin)
+//	@Description	While maintenance mode is on, every request other than GET, HEAD and OPTIONS is answered with 503, so reads and health checks keep working while writes are held off.
+//	@Description	Logging in and this endpoint stay available so that an admin can always turn it off again. The switch starts at MAINTENANCE_MODE and is kept per instance, so set it on each one.
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerauth
+//	@Param			request	body		models.MaintenanceRequest	true	"Whether maintenance mode is on"
+//	@Success		200		{object}	MaintenanceResponse
+//	@Failure		400		{object}	ValidationErrorResponse
+//	@Failure		401		{object}	ErrorResponse
+//	@Failure		403		{object}	ErrorResponse
+//	@Router			/admin/maintenance [post]
+func (h *MaintenanceHandler) SetMaintenance(c echo.Context) error {
+	var req models.MaintenanceRequest
+	if err := c.Bind(&req); err != nil {
+		return errorResponse(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return validationError(c, err)
+	}
+
+	h.maintenance.SetEnabled(*req.Enabled)
+
+	setBy := ""
+	if claims, ok := auth.ClaimsFromContext(c); ok {
+		setBy = claims.UserID
+	}
+	slog.WarnContext(c.Request().Context(), "Maintenance mode changed", "enabled", *req.Enabled, "set_by", setBy)
+
+	return respond(c, http.StatusOK, MaintenanceResponse{Enabled: h.maintenance.Enabled()}, nil)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maintenanceSwitch is a MaintenanceSwitch kept in a plain field
+type maintenanceSwitch struct {
+	enabled bool
+}
+
+func (m *maintenanceSwitch) Enabled() bool           { return m.enabled }
+func (m *maintenanceSwitch) SetEnabled(enabled bool) { m.enabled = enabled }
+
+func TestMaintenanceHandler_SetMaintenance(t *testing.T) {
+	tests := []struct {
+		name           string
+		initial        bool
+		reqBody        string
+		expectedStatus int
+		expected       bool
+	}{
+		{"Turn on", false, `{"enabled":true}`, http.StatusOK, true},
+		{"Turn off", true, `{"enabled":false}`, http.StatusOK, false},
+		// Leaving enabled out must not turn maintenance off by accident
+		{"Missing enabled", true, `{}`, http.StatusBadRequest, true},
+		{"Malformed body", false, `{"enabled":`, http.StatusBadRequest, false},
+		{"Not a boolean", false, `{"enabled":"yes"}`, http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maintenance := &maintenanceSwitch{enabled: tt.initial}
+			handler := NewMaintenanceHandler(maintenance)
+			e := echo.New()
+			e.Validator = NewValidator()
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/maintenance", strings.NewReader(tt.reqBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+
+			if err := handler.SetMaintenance(e.NewContext(req, rec)); err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			if maintenance.enabled != tt.expected {
+				t.Errorf("Expected maintenance mode %v, got %v", tt.expected, maintenance.enabled)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			var response MaintenanceResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if response.Enabled != tt.expected {
+				t.Errorf("Expected enabled %v in the response, got %v", tt.expected, response.Enabled)
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-mongodb-test/models"
+	"go-mongodb-test/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ErrorResponse is the body returned for every handled error
+type ErrorResponse struct {
+	Error     string `json:"error" example:"User not found"`
+	RequestID string `json:"request_id,omitempty" example:"3f2a8c1e-9d4b-4f6a-8e2d-1b7c5a9e0f13"`
+}
+
+// ValidationErrorResponse is the body returned when request validation fails.
+// Errors maps each failing field to its first message for form highlighting;
+// Details lists every failure in order.
+type ValidationErrorResponse struct {
+	Error     string              `json:"error" example:"Validation failed"`
+	Errors    map[string]string   `json:"errors" example:"email:must be a valid email address,password:must be at least 6 characters"`
+	Details   []models.FieldError `json:"details"`
+	RequestID string              `json:"request_id,omitempty" example:"3f2a8c1e-9d4b-4f6a-8e2d-1b7c5a9e0f13"`
+}
+
+// UserWithAgeResponse is a user plus its computed age, returned by GetUser with
+// include_age=true. Age is never stored; it is worked out on the server so that
+// clients need not deal with clocks or time zones.
+type UserWithAgeResponse struct {
+	*models.UserResponse
+	// Age is the whole number of seconds since created_at
+	Age int64 `json:"age" example:"86400"`
+}
+
+// newUserWithAge computes the age of user as of now, never negative even when the
+// server clock is behind the one that stamped created_at
+func newUserWithAge(user *models.User, now time.Time) UserWithAgeResponse {
+	age := now.Sub(user.CreatedAt)
+	if age < 0 {
+		age = 0
+	}
+	return UserWithAgeResponse{UserResponse: models.NewUserResponse(user), Age: int64(age / time.Second)}
+}
+
+// UserList is the data of the list and search responses
+type UserList struct {
+	Users []*models.UserResponse `json:"users"`
+}
+
+// ListMeta counts the items of a list
+type ListMeta struct {
+	Count int `json:"count" example:"1"`
+}
+
+// UserListResponse is the body returned by list and search endpoints
+type UserListResponse struct {
+	UserList
+	ListMeta
+}
+
+// UserPageMeta describes one page of users. Total counts every match, and the
+// Link header points at the neighbouring pages.
+type UserPageMeta struct {
+	Count   int   `json:"count" example:"1"`
+	Total   int64 `json:"total" example:"120"`
+	Limit   int   `json:"limit" example:"50"`
+	Offset  int   `json:"offset" example:"0"`
+	HasMore bool  `json:"has_more" example:"true"`
+}
+
+// UserPageResponse is one page of users
+type UserPageResponse struct {
+	UserList
+	UserPageMeta
+}
+
+// InactiveUsersMeta describes one page of users who have not logged in since Cutoff
+type InactiveUsersMeta struct {
+	Count   int       `json:"count" example:"1"`
+	Cutoff  time.Time `json:"cutoff" example:"2024-05-05T12:00:00Z"`
+	Limit   int       `json:"limit" example:"50"`
+	Offset  int       `json:"offset" example:"0"`
+	HasMore bool      `json:"has_more" example:"false"`
+}
+
+// InactiveUsersResponse is one page of inactive users
+type InactiveUsersResponse struct {
+	UserList
+	InactiveUsersMeta
+}
+
+// AuditLog is the data of an audit log response, most recent first
+type AuditLog struct {
+	Events []*models.AuditEvent `json:"events"`
+}
+
+// PageMeta describes one page of a list that is not counted in full
+type PageMeta struct {
+	Count   int  `json:"count" example:"1"`
+	Limit   int  `json:"limit" example:"50"`
+	Offset  int  `json:"offset" example:"0"`
+	HasMore bool `json:"has_more" example:"false"`
+}
+
+// AuditLogResponse is one page of a user's audit trail
+type AuditLogResponse struct {
+	AuditLog
+	PageMeta
+}
+
+// Envelope is the body of every successful response when RESPONSE_ENVELOPE is on.
+// Meta holds the counts and paging of lists, and is empty for everything else.
+type Envelope struct {
+	Data interface{} `json:"data"`
+	Meta interface{} `json:"meta"`
+}
+
+// SessionList is the data of the session list
+type SessionList struct {
+	Sessions []*models.Session `json:"sessions"`
+}
+
+// SessionListResponse is the body returned by the session list
+type SessionListResponse struct {
+	SessionList
+	ListMeta
+}
+
+// RevokeSessionsResponse is the body returned when the other sessions are revoked
+type RevokeSessionsResponse struct {
+	RevokedCount int64 `json:"revoked_count" example:"2"`
+}
+
+// CountResponse is the body returned by the count endpoint
+type CountResponse struct {
+	Count int64 `json:"count" example:"42"`
+}
+
+// DeleteAllResponse is the body returned when every user is removed
+type DeleteAllResponse struct {
+	DeletedCount int64 `json:"deleted_count" example:"42"`
+}
+
+// ImportRowResult is the outcome of one row of a CSV import. Row is the line
+// number in the uploaded file, counting the header as line 1.
+type ImportRowResult struct {
+	Row    int    `json:"row" example:"2"`
+	UserID string `json:"user_id,omitempty" example:"alice"`
+	ID     string `json:"id,omitempty" example:"665f1c2e8b3a4d2f9c1e7a10"`
+	Error  string `json:"error,omitempty" example:"user with this user_id already exists"`
+}
+
+// ImportUsersResponse is the body returned by the CSV import. Rows whose user_id or
+// email is already taken are skipped, so an import can safely be run again.
+type ImportUsersResponse struct {
+	Created []ImportRowResult `json:"created"`
+	Skipped []ImportRowResult `json:"skipped"`
+	Failed  []ImportRowResult `json:"failed"`
+}
+
+// AdminResetPasswordResponse is the body returned by an admin password reset.
+// Password is only set when the server generated it, and is not shown again.
+type AdminResetPasswordResponse struct {
+	Message  string `json:"message" example:"Password has been reset"`
+	Password string `json:"password,omitempty" example:"Zk9yZ290UGFzc3dvcmRUb2tlbkV4YW1wbGUxMjM0NTY"`
+}
+
+// MaintenanceResponse reports whether maintenance mode is on
+type MaintenanceResponse struct {
+	Enabled bool `json:"enabled" example:"true"`
+}
+
+// MessageResponse is the body returned by endpoints without a resource to return
+type MessageResponse struct {
+	Message string `json:"message" example:"User deleted successfully"`
+}
+
+// requestID returns the correlation ID assigned to the current request
+func requestID(c echo.Context) string {
+	if id := c.Response().Header().Get(echo.HeaderXRequestID); id != "" {
+		return id
+	}
+	return c.Request().Header.Get(echo.HeaderXRequestID)
+}
+
+// jsonIndent indents the bodies of requests that ask for ?pretty=true
+const jsonIndent = "  "
+
+// respondJSON renders v as the JSON body of every handler response. It is compact
+// unless the request has ?pretty=true, for reading with curl; unlike echo's own
+// check, ?pretty=false and other values that are not true stay compact.
+func respondJSON(c echo.Context, status int, v interface{}) error {
+	indent := ""
+	if pretty, _ := strconv.ParseBool(c.QueryParam("pretty")); pretty {
+		indent = jsonIndent
+	}
+	return c.JSONPretty(status, v, indent)
+}
+
+// envelopeResponses is set once at startup by SetResponseEnvelope
+var envelopeResponses bool
+
+// SetResponseEnvelope sets whether respond wraps bodies in an Envelope
+func SetResponseEnvelope(enabled bool) {
+	envelopeResponses = enabled
+}
+
+// respond renders a successful response. With envelopes on, data and meta become
+// the two halves of an Envelope; otherwise the fields of meta follow those of data
+// in one object, which is the bare body clients had before envelopes. meta may be
+// nil, and when set both must encode to JSON objects.
+func respond(c echo.Context, status int, data, meta interface{}) error {
+	if envelopeResponses {
+		if meta == nil {
+			meta = struct{}{}
+		}
+		return respondJSON(c, status, Envelope{Data: data, Meta: meta})
+	}
+	if meta == nil {
+		return respondJSON(c, status, data)
+	}
+
+	body, err := mergeJSONObjects(data, meta)
+	if err != nil {
+		return serverError(c, err)
+	}
+	return respondJSON(c, status, body)
+}
+
+// mergeJSONObjects encodes a and b, which must be objects, as a single object
+// holding the fields of a followed by those of b
+func mergeJSONObjects(a, b interface{}) (json.RawMessage, error) {
+	first, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	second, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	if !isJSONObject(first) || !isJSONObject(second) {
+		return nil, fmt.Errorf("cannot merge %s and %s into one object", first, second)
+	}
+
+	switch {
+	case len(second) == 2:
+		return first, nil
+	case len(first) == 2:
+		return second, nil
+	}
+	merged := append(first[:len(first)-1:len(first)-1], ',')
+	return append(merged, second[1:]...), nil
+}
+
+// isJSONObject reports whether compact JSON encodes an object
+func isJSONObject(data []byte) bool {
+	return len(data) >= 2 && data[0] == '{' && data[len(data)-1] == '}'
+}
+
+// errorResponse renders an error body tagged with the request's correlation ID
+func errorResponse(c echo.Context, status int, message string) error {
+	return respondJSON(c, status, ErrorResponse{
+		Error:     message,
+		RequestID: requestID(c),
+	})
+}
+
+// serverError renders an unexpected service error, answering 504 when the request
+// deadline set by the timeout middleware has passed and 501 for operations that
+// the configured storage cannot back
+func serverError(c echo.Context, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(c.Request().Context().Err(), context.DeadlineExceeded) {
+		return errorResponse(c, http.StatusGatewayTimeout, "Request timed out")
+	}
+	if errors.Is(err, services.ErrStorageUnsupported) {
+		return errorResponse(c, http.StatusNotImplemented, err.Error())
+	}
+	return errorResponse(c, http.StatusInternalServerError, err.Error())
+}
+
+// HTTPErrorHandler renders errors that reach Echo, such as unknown routes, wrong
+// methods or binding failures, in the same ErrorResponse shape as the handlers.
+// A 400 wrapping models.FieldErrors, as the JSON Schema middleware returns, is
+// rendered as a ValidationErrorResponse instead. Errors that are not an
+// *echo.HTTPError become a generic 500 and are logged.
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	status := http.StatusInternalServerError
+	message := "Internal server error"
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		status = httpErr.Code
+		if text, ok := httpErr.Message.(string); ok && text != "" {
+			message = text
+		} else {
+			message = http.StatusText(status)
+		}
+	} else {
+		slog.ErrorContext(c.Request().Context(), "Unhandled error", "error", err, "request_id", requestID(c))
+	}
+
+	var renderErr error
+	var fieldErrs models.FieldErrors
+	switch {
+	case c.Request().Method == http.MethodHead:
+		renderErr = c.NoContent(status)
+	case status == http.StatusBadRequest && errors.As(err, &fieldErrs):
+		renderErr = fieldErrorsResponse(c, fieldErrs)
+	default:
+		renderErr = errorResponse(c, status, message)
+	}
+	if renderErr != nil {
+		slog.ErrorContext(c.Request().Context(), "Failed to render error response", "error", renderErr)
+	}
+}
@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-mongodb-test/models"
+	"go-mongodb-test/services"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestErrorResponse_IncludesRequestID(t *testing.T) {
+	handler := NewUserHandler(&mockUserService{}, &mockMailer{})
+	e := echo.New()
+	e.Use(middleware.RequestID())
+	e.GET("/users/search", handler.GetUserByUserID)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/search", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	headerID := rec.Header().Get(echo.HeaderXRequestID)
+	if headerID == "" {
+		t.Fatal("Expected X-Request-Id response header to be set")
+	}
+
+	var response map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if response["request_id"] != headerID {
+		t.Errorf("Expected request_id '%s' in body, got '%s'", headerID, response["request_id"])
+	}
+}
+
+func TestErrorResponse_WithoutRequestID(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := errorResponse(c, http.StatusNotFound, "User not found"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var response map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if response["error"] != "User not found" {
+		t.Errorf("Expected error 'User not found', got '%s'", response["error"])
+	}
+
+	if _, ok := response["request_id"]; ok {
+		t.Error("Expected no request_id when none was assigned")
+	}
+}
+
+func TestServerError(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+	}{
+		{"Deadline exceeded", fmt.Errorf("find user: %w", context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{"Unsupported by the storage", fmt.Errorf("issue token: %w", services.ErrStorageUnsupported), http.StatusNotImplemented},
+		{"Other error", errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &mockUserService{
+				getUserByIDFunc: func(ctx context.Context, id string) (*models.User, error) {
+					return nil, tt.err
+				},
+			}
+			handler := NewUserHandler(service, &mockMailer{})
+			e := echo.New()
+
+			req := httptest.NewRequest(http.MethodGet, "/users/507f1f77bcf86cd799439011", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("507f1f77bcf86cd799439011")
+
+			if err := handler.GetUser(c); err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestHTTPErrorHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+		expectedError  string
+	}{
+		{"Unknown route", http.MethodGet, "/unknown", http.StatusNotFound, "Not Found"},
+		{"Wrong method", http.MethodPut, "/users", http.StatusMethodNotAllowed, "Method Not Allowed"},
+		{"Plain error", http.MethodGet, "/users", http.StatusInternalServerError, "Internal server error"},
+	}
+
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.Use(middleware.RequestID())
+	e.GET("/users", func(c echo.Context) error {
+		return errors.New("connection refused")
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+
+			var body map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if body["error"] != tt.expectedError {
+				t.Errorf("Expected error '%s', got %v", tt.expectedError, body["error"])
+			}
+			if _, ok := body["message"]; ok {
+				t.Error("Expected no message field")
+			}
+			if body["request_id"] == "" || body["request_id"] == nil {
+				t.Error("Expected the request ID in the body")
+			}
+		})
+	}
+}
+
+func TestHTTPErrorHandler_FieldErrors(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.Use(middleware.RequestID())
+	e.POST("/users", func(c echo.Context) error {
+		// As the JSON Schema middleware reports a body that breaks the schema
+		details := models.FieldErrors{{Field: "email", Message: "is required"}, {Field: "password", Message: "is required"}}
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed").SetInternal(details)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/users", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	var body ValidationErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if body.Error != "Validation failed" || body.Errors["email"] != "is required" || len(body.Details) != 2 {
+		t.Errorf("Expected the field errors, got %+v", body)
+	}
+	if body.RequestID == "" || body.RequestID != rec.Header().Get(echo.HeaderXRequestID) {
+		t.Errorf("Expected the request ID in the body, got %q", body.RequestID)
+	}
+}
+
+func TestRespondJSON_Pretty(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		acceptEncoding string
+		expectedIndent bool
+	}{
+		{"Default", "", "", false},
+		{"Pretty", "?pretty=true", "", true},
+		{"Pretty off", "?pretty=false", "", false},
+		{"Not a boolean", "?pretty=yes", "", false},
+		{"Pretty and gzip", "?pretty=true", "gzip", true},
+		{"Gzip", "", "gzip", false},
+	}
+
+	e := echo.New()
+	e.Use(middleware.Gzip())
+	e.GET("/pretty", func(c echo.Context) error {
+		return respondJSON(c, http.StatusOK, MessageResponse{Message: "User deleted successfully"})
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/pretty"+tt.query, nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set(echo.HeaderAcceptEncoding, tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+			}
+
+			var body io.Reader = rec.Body
+			if tt.acceptEncoding != "" {
+				if rec.Header().Get(echo.HeaderContentEncoding) != tt.acceptEncoding {
+					t.Errorf("Expected Content-Encoding %s, got %q", tt.acceptEncoding, rec.Header().Get(echo.HeaderContentEncoding))
+				}
+				reader, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatalf("Failed to decompress response: %v", err)
+				}
+				body = reader
+			}
+			data, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("Failed to read response: %v", err)
+			}
+
+			var response MessageResponse
+			if err := json.Unmarshal(data, &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if response.Message != "User deleted successfully" {
+				t.Errorf("Expected the message, got %s", response.Message)
+			}
+			if indented := strings.Contains(string(data), "\n"+jsonIndent+`"`); indented != tt.expectedIndent {
+				t.Errorf("Expected indented %v, got %s", tt.expectedIndent, data)
+			}
+		})
+	}
+}
+
+func TestRespond(t *testing.T) {
+	tests := []struct {
+		name           string
+		envelope       bool
+		data           interface{}
+		meta           interface{}
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"Bare", false, MessageResponse{Message: "Logged out"}, nil, http.StatusOK, `{"message":"Logged out"}`},
+		{"Bare with meta", false, UserList{Users: []*models.UserResponse{}}, ListMeta{Count: 0}, http.StatusOK, `{"users":[],"count":0}`},
+		{"Bare with empty meta", false, MessageResponse{Message: "Logged out"}, struct{}{}, http.StatusOK, `{"message":"Logged out"}`},
+		{"Bare with a meta that is not an object", false, MessageResponse{Message: "Logged out"}, 1, http.StatusInternalServerError, ""},
+		{"Envelope", true, MessageResponse{Message: "Logged out"}, nil, http.StatusOK, `{"data":{"message":"Logged out"},"meta":{}}`},
+		{"Envelope with meta", true, UserList{Users: []*models.UserResponse{}}, ListMeta{Count: 0}, http.StatusOK, `{"data":{"users":[]},"meta":{"count":0}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetResponseEnvelope(tt.envelope)
+			defer SetResponseEnvelope(false)
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if err := respond(c, http.StatusOK, tt.data, tt.meta); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			if body := strings.TrimSpace(rec.Body.String()); tt.expectedBody != "" && body != tt.expectedBody {
+				t.Errorf("Expected body %s, got %s", tt.expectedBody, body)
+			}
+		})
+	}
+}
+
+func TestRespond_EnvelopedPage(t *testing.T) {
+	SetResponseEnvelope(true)
+	defer SetResponseEnvelope(false)
+
+	mockService := &mockUserService{
+		listUsersFunc: func(ctx context.Context, opts models.ListUsersOptions) ([]*models.User, error) {
+			return []*models.User{{ID: bson.NewObjectID(), UserID: "alice"}}, nil
+		},
+		countListedUsersFunc: func(ctx context.Context, opts models.ListUsersOptions) (int64, error) {
+			return 3, nil
+		},
+	}
+	handler := NewUserHandler(mockService, &mockMailer{})
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users?limit=1", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := handler.ListUsers(c); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var response struct {
+		Data UserList     `json:"data"`
+		Meta UserPageMeta `json:"meta"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(response.Data.Users) != 1 || response.Data.Users[0].UserID != "alice" {
+		t.Errorf("Expected alice in the data, got %+v", response.Data)
+	}
+	if response.Meta.Count != 1 || response.Meta.Total != 3 || response.Meta.Limit != 1 || !response.Meta.HasMore {
+		t.Errorf("Expected 1 of 3 users with more to come in the meta, got %+v", response.Meta)
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"go-mongodb-test/auth"
+	"go-mongodb-test/models"
+	"go-mongodb-test/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// sessionError maps the errors shared by the session endpoints
+func sessionError(c echo.Context, err error) error {
+	switch {
+	// A token for an account that has since been deleted no longer identifies anyone
+	case errors.Is(err, services.ErrInvalidUserID):
+		return errorResponse(c, http.StatusUnauthorized, "Authentication required")
+	case errors.Is(err, services.ErrSessionNotFound):
+		return errorResponse(c, http.StatusNotFound, "Session not found")
+	}
+	return serverError(c, err)
+}
+
+// ListSessions lists the active sessions of the authenticated user
+//
+//	@Summary		List my sessions
+//	@Description	Each login starts a session, which lasts for as long as its refresh token is outstanding and keeps its ID across refreshes.
+//	@Description	Sessions are listed most recently used first, with the User-Agent and IP they logged in from; current marks the one making the request.
+//	@Tags			users
+//	@Produce		json
+//	@Security		bearerauth
+//	@Success		200	{object}	SessionListResponse
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		500	{object}	ErrorResponse
+//	@Failure		501	{object}	ErrorResponse	"Not available with STORAGE=memory"
+//	@Failure		504	{object}	ErrorResponse
+//	@Router			/users/me/sessions [get]
+func (h *UserHandler) ListSessions(c echo.Context) error {
+	claims, ok := auth.ClaimsFromContext(c)
+	if !ok {
+		return errorResponse(c, http.StatusUnauthorized, "Authentication required")
+	}
+
+	tokens, err := h.service(c).ListSessions(c.Request().Context(), claims.ID)
+	if err != nil {
+		return sessionError(c, err)
+	}
+
+	sessions := make([]*models.Session, 0, len(tokens))
+	for _, token := range tokens {
+		sessions = append(sessions, models.NewSession(token, claims.SessionID))
+	}
+	return respond(c, http.StatusOK, SessionList{Sessions: sessions}, ListMeta{Count: len(sessions)})
+}
+
+// RevokeSession ends one session of the authenticated user
+//
+//	@Summary		Revoke a session
+//	@Description	Deletes the session's refresh token, so that it can no longer be refreshed. Access tokens already issued to it stay valid until they expire.
+//	@Description	Revoking the current session logs it out.
+//	@Tags			users
+//	@Produce		json
+//	@Security		bearerauth
+//	@Param			sid	path		string	true	"Session ID from GET /users/me/sessions"	example(665f1c2e8b3a4d2f9c1e7a10)
+//	@Success		200	{object}	MessageResponse
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		404	{object}	ErrorResponse
+//	@Failure		500	{object}	ErrorResponse
+//	@Failure		501	{object}	ErrorResponse	"Not available with STORAGE=memory"
+//	@Failure		504	{object}	ErrorResponse
+//	@Router			/users/me/sessions/{sid} [delete]
+func (h *UserHandler) RevokeSession(c echo.Context) error {
+	claims, ok := auth.ClaimsFromContext(c)
+	if !ok {
+		return errorResponse(c, http.StatusUnauthorized, "Authentication required")
+	}
+
+	if err := h.service(c).RevokeSession(c.Request().Context(), claims.ID, c.Param("sid")); err != nil {
+		return sessionError(c, err)
+	}
+
+	return respond(c, http.StatusOK, MessageResponse{
+		Message: "Session revoked",
+	}, nil)
+}
+
+// RevokeOtherSessions ends every session of the authenticated user but the current one
+//
+//	@Summary		Revoke my other sessions
+//	@Description	Deletes the refresh tokens of every session except the one making the request, such as after losing a device.
+//	@Description	Access tokens already issued to them stay valid until they expire. Tokens from before sessions were tracked name no session and get 409; log in again first.
+//	@Tags			users
+//	@Produce		json
+//	@Security		bearerauth
+//	@Success		200	{object}	RevokeSessionsResponse
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		409	{object}	ErrorResponse
+//	@Failure		500	{object}	ErrorResponse
+//	@Failure		501	{object}	ErrorResponse	"Not available with STORAGE=memory"
+//	@Failure		504	{object}	ErrorResponse
+//	@Router			/users/me/sessions [delete]
+func (h *UserHandler) RevokeOtherSessions(c echo.Context) error {
+	claims, ok := auth.ClaimsFromContext(c)
+	if !ok {
+		return errorResponse(c, http.StatusUnauthorized, "Authentication required")
+	}
+	// Without the current session every session would count as another one
+	if claims.SessionID == "" {
+		return errorResponse(c, http.StatusConflict, "Access token names no session; log in again")
+	}
+
+	revoked, err := h.service(c).RevokeOtherSessions(c.Request().Context(), claims.ID, claims.SessionID)
+	if err != nil {
+		return sessionError(c, err)
+	}
+
+	return respond(c, http.StatusOK, RevokeSessionsResponse{RevokedCount: revoked}, nil)
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-mongodb-test/auth"
+	"go-mongodb-test/models"
+	"go-mongodb-test/services"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// newSessionToken returns an access token for userID issued within session sessionID
+func newSessionToken(t *testing.T, userID bson.ObjectID, sessionID string) string {
+	t.Helper()
+	token, err := auth.GenerateSessionToken(&models.User{ID: userID, UserID: "testuser"}, "", sessionID)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	return token
+}
+
+func TestUserHandler_ListSessions(t *testing.T) {
+	setTestSecret(t)
+	userID := bson.NewObjectID()
+	current, other := bson.NewObjectID(), bson.NewObjectID()
+
+	var gotID string
+	handler := NewUserHandler(&mockUserService{
+		listSessionsFunc: func(ctx context.Context, id string) ([]*models.RefreshToken, error) {
+			gotID = id
+			return []*models.RefreshToken{
+				{ID: bson.NewObjectID(), SessionID: current, SessionClient: models.SessionClient{UserAgent: "curl/8.5.0"}},
+				{ID: bson.NewObjectID(), SessionID: other},
+			}, nil
+		},
+	}, &mockMailer{})
+	e := echo.New()
+	e.GET("/users/me/sessions", handler.ListSessions, auth.Authenticate())
+
+	req := httptest.NewRequest(http.MethodGet, "/users/me/sessions", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+newSessionToken(t, userID, current.Hex()))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if gotID != userID.Hex() {
+		t.Errorf("Expected the sessions of %s, got %s", userID.Hex(), gotID)
+	}
+
+	var response SessionListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.Count != 2 || len(response.Sessions) != 2 {
+		t.Fatalf("Expected 2 sessions, got %+v", response)
+	}
+	if !response.Sessions[0].Current || response.Sessions[0].ID != current.Hex() || response.Sessions[0].UserAgent != "curl/8.5.0" {
+		t.Errorf("Expected the current session first, got %+v", response.Sessions[0])
+	}
+	if response.Sessions[1].Current {
+		t.Errorf("Expected only one current session, got %+v", response.Sessions[1])
+	}
+}
+
+func TestUserHandler_RevokeSession(t *testing.T) {
+	setTestSecret(t)
+	userID := bson.NewObjectID()
+	sessionID := bson.NewObjectID().Hex()
+
+	tests := []struct {
+		name           string
+		serviceErr     error
+		expectedStatus int
+	}{
+		{"Revoked", nil, http.StatusOK},
+		{"Unknown session", services.ErrSessionNotFound, http.StatusNotFound},
+		{"Server error", errors.New("database error"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotID, gotSession string
+			handler := NewUserHandler(&mockUserService{
+				revokeSessionFunc: func(ctx context.Context, id, sessionID string) error {
+					gotID, gotSession = id, sessionID
+					return tt.serviceErr
+				},
+			}, &mockMailer{})
+			e := echo.New()
+			e.DELETE("/users/me/sessions/:sid", handler.RevokeSession, auth.Authenticate())
+
+			req := httptest.NewRequest(http.MethodDelete, "/users/me/sessions/"+sessionID, nil)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+newSessionToken(t, userID, bson.NewObjectID().Hex()))
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			if gotID != userID.Hex() || gotSession != sessionID {
+				t.Errorf("Expected session %s of %s, got %s of %s", sessionID, userID.Hex(), gotSession, gotID)
+			}
+		})
+	}
+}
+
+func TestUserHandler_RevokeOtherSessions(t *testing.T) {
+	setTestSecret(t)
+	userID := bson.NewObjectID()
+	current := bson.NewObjectID().Hex()
+
+	tests := []struct {
+		name           string
+		sessionID      string
+		serviceErr     error
+		expectedStatus int
+	}{
+		{"Revoked", current, nil, http.StatusOK},
+		{"Token without a session", "", nil, http.StatusConflict},
+		{"Server error", current, errors.New("database error"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotKeep string
+			handler := NewUserHandler(&mockUserService{
+				revokeOtherSessionsFunc: func(ctx context.Context, id, keepSessionID string) (int64, error) {
+					gotKeep = keepSessionID
+					return 2, tt.serviceErr
+				},
+			}, &mockMailer{})
+			e := echo.New()
+			e.DELETE("/users/me/sessions", handler.RevokeOtherSessions, auth.Authenticate())
+
+			req := httptest.NewRequest(http.MethodDelete, "/users/me/sessions", nil)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+newSessionToken(t, userID, tt.sessionID))
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			if gotKeep != current {
+				t.Errorf("Expected the current session %s to be kept, got %s", current, gotKeep)
+			}
+			var response RevokeSessionsResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if response.RevokedCount != 2 {
+				t.Errorf("Expected 2 sessions revoked, got %d", response.RevokedCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetUserStats summarizes the users for dashboards
+//
+//	@Summary		User statistics
+//	@Description	Counts users that are not deleted: in total, created today and this week (from midnight UTC, weeks starting Monday), and in the 20 most common email domains.
+//	@Description	The numbers are computed by one aggregation and cached for 30 seconds, so they can lag behind recent writes.
+//	@Tags			users
+//	@Produce		json
+//	@Security		bearerauth
+//	@Security		apikeyauth
+//	@Success		200	{object}	models.UserStats
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		403	{object}	ErrorResponse
+//	@Failure		500	{object}	ErrorResponse
+//	@Failure		501	{object}	ErrorResponse	"Not available with STORAGE=memory"
+//	@Failure		504	{object}	ErrorResponse
+//	@Router			/users/stats [get]
+func (h *UserHandler) GetUserStats(c echo.Context) error {
+	stats, err := h.service(c).Stats(c.Request().Context())
+	if err != nil {
+		return serverError(c, err)
+	}
+
+	return respond(c, http.StatusOK, stats, nil)
+}
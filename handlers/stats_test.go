@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-mongodb-test/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestUserHandler_GetUserStats(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		handler := NewUserHandler(&mockUserService{
+			statsFunc: func(ctx context.Context) (*models.UserStats, error) {
+				return &models.UserStats{
+					Total:           42,
+					CreatedToday:    3,
+					CreatedThisWeek: 12,
+					Domains:         []models.DomainCount{{Domain: "example.com", Count: 30}},
+				}, nil
+			},
+		}, &mockMailer{})
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, "/users/stats", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		if err := handler.GetUserStats(c); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+
+		var stats models.UserStats
+		if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if stats.Total != 42 || stats.CreatedToday != 3 || stats.CreatedThisWeek != 12 {
+			t.Errorf("Unexpected counts %+v", stats)
+		}
+		if len(stats.Domains) != 1 || stats.Domains[0].Domain != "example.com" || stats.Domains[0].Count != 30 {
+			t.Errorf("Expected the domain breakdown, got %v", stats.Domains)
+		}
+	})
+
+	t.Run("Server error", func(t *testing.T) {
+		handler := NewUserHandler(&mockUserService{
+			statsFunc: func(ctx context.Context) (*models.UserStats, error) {
+				return nil, errors.New("database error")
+			},
+		}, &mockMailer{})
+		e := echo.New()
+
+		req := httptest.NewRequest(http.MethodGet, "/users/stats", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		if err := handler.GetUserStats(c); err != nil {
+			t.Fatalf("Expected no error from handler, got %v", err)
+		}
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"go-mongodb-test/auth"
+	"go-mongodb-test/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// TenantResolver returns the service holding a tenant's users, or
+// services.ErrUnknownTenant for a tenant outside the allowlist
+type TenantResolver interface {
+	For(tenant string) (*services.UserService, error)
+}
+
+// tenantServiceContextKey is where ResolveTenant stores the service of the request's tenant
+const tenantServiceContextKey = "tenant_user_service"
+
+// ResolveTenant serves each request from the service of the tenant named by its
+// X-Tenant-ID header, in place of the service the handlers were built with, and
+// records the tenant in the request context for auth.Authenticate. Requests
+// without the header or for an unknown tenant are answered with 400.
+func ResolveTenant(tenants TenantResolver) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			tenant := c.Request().Header.Get(auth.HeaderTenantID)
+			if tenant == "" {
+				return errorResponse(c, http.StatusBadRequest, "Missing "+auth.HeaderTenantID+" header")
+			}
+
+			service, err := tenants.For(tenant)
+			if err != nil {
+				if errors.Is(err, services.ErrUnknownTenant) {
+					return errorResponse(c, http.StatusBadRequest, "Unknown tenant")
+				}
+				return serverError(c, err)
+			}
+
+			c.Set(tenantServiceContextKey, service)
+			c.SetRequest(c.Request().WithContext(auth.WithTenant(c.Request().Context(), tenant)))
+			return next(c)
+		}
+	}
+}
+
+// service returns the service of the request's tenant, or the handler's own
+// service when the API is not split into tenants
+func (h *UserHandler) service(c echo.Context) UserServiceInterface {
+	if service, ok := c.Get(tenantServiceContextKey).(*services.UserService); ok {
+		return service
+	}
+	return h.userService
+}
+
+// service returns the service of the request's tenant, or the handler's own
+// service when the API is not split into tenants
+func (h *AuthHandler) service(c echo.Context) AuthUserService {
+	if service, ok := c.Get(tenantServiceContextKey).(*services.UserService); ok {
+		return service
+	}
+	return h.userService
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-mongodb-test/auth"
+	"go-mongodb-test/models"
+	"go-mongodb-test/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// tenantMap resolves tenants from a fixed map, standing in for services.TenantServices
+type tenantMap map[string]*services.UserService
+
+func (m tenantMap) For(tenant string) (*services.UserService, error) {
+	service, ok := m[tenant]
+	if !ok {
+		return nil, services.ErrUnknownTenant
+	}
+	return service, nil
+}
+
+func TestResolveTenant(t *testing.T) {
+	tenants := tenantMap{
+		"acme":   services.NewUserServiceWithRepository(services.NewInMemoryUserRepository()),
+		"globex": services.NewUserServiceWithRepository(services.NewInMemoryUserRepository()),
+	}
+	alice, err := tenants["acme"].CreateUser(context.Background(), &models.CreateUserRequest{
+		UserID:   "alice",
+		Email:    "alice@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	// The handler's own service must never be reached once tenants are resolved
+	handler := NewUserHandler(&mockUserService{}, &mockMailer{})
+	e := echo.New()
+	e.GET("/users/:id", func(c echo.Context) error {
+		if tenant := auth.TenantFrom(c.Request().Context()); tenant != c.Request().Header.Get(auth.HeaderTenantID) {
+			t.Errorf("Expected the tenant in the request context, got %q", tenant)
+		}
+		return handler.GetUser(c)
+	}, ResolveTenant(tenants))
+
+	tests := []struct {
+		name           string
+		tenant         string
+		expectedStatus int
+		expectedError  string
+	}{
+		{"Tenant of the user", "acme", http.StatusOK, ""},
+		{"Other tenant", "globex", http.StatusNotFound, "User not found"},
+		{"Unknown tenant", "initech", http.StatusBadRequest, "Unknown tenant"},
+		{"Missing header", "", http.StatusBadRequest, "Missing X-Tenant-ID header"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users/"+alice.ID.Hex(), nil)
+			if tt.tenant != "" {
+				req.Header.Set(auth.HeaderTenantID, tt.tenant)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, rec.Code, rec.Body.String())
+			}
+			if tt.expectedError == "" {
+				return
+			}
+			var body map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if body["error"] != tt.expectedError {
+				t.Errorf("Expected error '%s', got %v", tt.expectedError, body["error"])
+			}
+		})
+	}
+}
+
+func TestResolveTenant_ResolverError(t *testing.T) {
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, ResolveTenant(failingResolver{}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(auth.HeaderTenantID, "acme")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+	}
+}
+
+type failingResolver struct{}
+
+func (failingResolver) For(string) (*services.UserService, error) {
+	return nil, errors.New("resolver failed")
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"go-mongodb-test/auth"
+	"go-mongodb-test/models"
+	"go-mongodb-test/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// twoFactorError maps the errors shared by the two-factor endpoints
+func twoFactorError(c echo.Context, err error) error {
+	switch {
+	// A token for an account that has since been deleted no longer identifies anyone
+	case errors.Is(err, services.ErrUserNotFound) || errors.Is(err, services.ErrInvalidUserID):
+		return errorResponse(c, http.StatusUnauthorized, "Authentication required")
+	case errors.Is(err, services.ErrTwoFactorEnabled):
+		return errorResponse(c, http.StatusConflict, "Two-factor authentication is already enabled")
+	case errors.Is(err, auth.ErrMissingEncryptionKey):
+		return errorResponse(c, http.StatusNotImplemented, "Two-factor authentication is not configured")
+	}
+	return serverError(c, err)
+}
+
+// EnableTwoFactor starts two-factor enrollment for the authenticated user
+//
+//	@Summary		Start two-factor enrollment
+//	@Description	Generates a TOTP secret and returns it with an otpauth:// provisioning URI for authenticator apps.
+//	@Description	Login keeps working without a code until the enrollment is confirmed at /users/me/2fa/verify.
+//	@Tags			users
+//	@Produce		json
+//	@Security		bearerauth
+//	@Success		200	{object}	models.TwoFactorSetup
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		409	{object}	ErrorResponse
+//	@Failure		500	{object}	ErrorResponse
+//	@Failure		501	{object}	ErrorResponse	"TOTP_ENCRYPTION_KEY is not set"
+//	@Failure		504	{object}	ErrorResponse
+//	@Router			/users/me/2fa/enable [post]
+func (h *UserHandler) EnableTwoFactor(c echo.Context) error {
+	claims, ok := auth.ClaimsFromContext(c)
+	if !ok {
+		return errorResponse(c, http.StatusUnauthorized, "Authentication required")
+	}
+
+	setup, err := h.service(c).StartTwoFactor(c.Request().Context(), claims.ID)
+	if err != nil {
+		return twoFactorError(c, err)
+	}
+
+	return respond(c, http.StatusOK, setup, nil)
+}
+
+// VerifyTwoFactor confirms two-factor enrollment for the authenticated user
+//
+//	@Summary		Confirm two-factor enrollment
+//	@Description	Checks a code from the authenticator app against the secret from /users/me/2fa/enable.
+//	@Description	Once it matches, every login needs a totp_code as well as the password.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerauth
+//	@Param			request	body		models.TwoFactorCodeRequest	true	"Current code from the authenticator app"
+//	@Success		200		{object}	MessageResponse
+//	@Failure		400		{object}	ValidationErrorResponse
+//	@Failure		401		{object}	ErrorResponse
+//	@Failure		409		{object}	ErrorResponse
+//	@Failure		429		{object}	ErrorResponse
+//	@Failure		500		{object}	ErrorResponse
+//	@Failure		501		{object}	ErrorResponse	"TOTP_ENCRYPTION_KEY is not set"
+//	@Failure		504		{object}	ErrorResponse
+//	@Router			/users/me/2fa/verify [post]
+func (h *UserHandler) VerifyTwoFactor(c echo.Context) error {
+	claims, ok := auth.ClaimsFromContext(c)
+	if !ok {
+		return errorResponse(c, http.StatusUnauthorized, "Authentication required")
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := c.Bind(&req); err != nil {
+		return errorResponse(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return validationError(c, err)
+	}
+
+	err := h.service(c).ConfirmTwoFactor(c.Request().Context(), claims.ID, req.Code)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrTwoFactorNotStarted):
+		return errorResponse(c, http.StatusBadRequest, "Start two-factor enrollment before verifying a code")
+	case errors.Is(err, services.ErrInvalidTwoFactorCode):
+		return errorResponse(c, http.StatusBadRequest, "Invalid two-factor code")
+	default:
+		return twoFactorError(c, err)
+	}
+
+	return respond(c, http.StatusOK, MessageResponse{
+		Message: "Two-factor authentication enabled",
+	}, nil)
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-mongodb-test/auth"
+	"go-mongodb-test/models"
+	"go-mongodb-test/services"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestUserHandler_EnableTwoFactor(t *testing.T) {
+	setTestSecret(t)
+	userID := bson.NewObjectID()
+	token, err := auth.GenerateToken(&models.User{ID: userID, UserID: "testuser"})
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		serviceErr error
+		statusCode int
+	}{
+		{"Started", nil, http.StatusOK},
+		{"Already enabled", services.ErrTwoFactorEnabled, http.StatusConflict},
+		{"Not configured", auth.ErrMissingEncryptionKey, http.StatusNotImplemented},
+		{"Deleted account", services.ErrUserNotFound, http.StatusUnauthorized},
+		{"Server error", errors.New("database error"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotID string
+			handler := NewUserHandler(&mockUserService{
+				startTwoFactorFunc: func(ctx context.Context, id string) (*models.TwoFactorSetup, error) {
+					gotID = id
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					return &models.TwoFactorSetup{Secret: "JBSWY3DPEHPK3PXP", ProvisioningURI: "otpauth://totp/test"}, nil
+				},
+			}, &mockMailer{})
+			e := echo.New()
+			e.POST("/users/me/2fa/enable", handler.EnableTwoFactor, auth.Authenticate())
+
+			req := httptest.NewRequest(http.MethodPost, "/users/me/2fa/enable", nil)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.statusCode {
+				t.Fatalf("Expected status %d, got %d", tt.statusCode, rec.Code)
+			}
+			if gotID != userID.Hex() {
+				t.Errorf("Expected enrollment for '%s', got '%s'", userID.Hex(), gotID)
+			}
+
+			if tt.statusCode == http.StatusOK {
+				var setup models.TwoFactorSetup
+				if err := json.Unmarshal(rec.Body.Bytes(), &setup); err != nil {
+					t.Fatalf("Failed to unmarshal response: %v", err)
+				}
+				if setup.Secret == "" || setup.ProvisioningURI == "" {
+					t.Errorf("Expected a secret and provisioning URI, got %+v", setup)
+				}
+			}
+		})
+	}
+}
+
+func TestUserHandler_VerifyTwoFactor(t *testing.T) {
+	setTestSecret(t)
+	userID := bson.NewObjectID()
+	token, err := auth.GenerateToken(&models.User{ID: userID, UserID: "testuser"})
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		serviceErr error
+		statusCode int
+	}{
+		{"Confirmed", `{"code":"123456"}`, nil, http.StatusOK},
+		{"Wrong code", `{"code":"123456"}`, services.ErrInvalidTwoFactorCode, http.StatusBadRequest},
+		{"Not started", `{"code":"123456"}`, services.ErrTwoFactorNotStarted, http.StatusBadRequest},
+		{"Already enabled", `{"code":"123456"}`, services.ErrTwoFactorEnabled, http.StatusConflict},
+		{"Malformed code", `{"code":"12ab"}`, nil, http.StatusBadRequest},
+		{"Missing code", `{}`, nil, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotCode string
+			handler := NewUserHandler(&mockUserService{
+				confirmTwoFactorFunc: func(ctx context.Context, id, code string) error {
+					gotCode = code
+					return tt.serviceErr
+				},
+			}, &mockMailer{})
+			e := echo.New()
+			e.Validator = NewValidator()
+			e.POST("/users/me/2fa/verify", handler.VerifyTwoFactor, auth.Authenticate())
+
+			req := httptest.NewRequest(http.MethodPost, "/users/me/2fa/verify", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.statusCode {
+				t.Errorf("Expected status %d, got %d", tt.statusCode, rec.Code)
+			}
+			if tt.statusCode == http.StatusOK && gotCode != "123456" {
+				t.Errorf("Expected the code to reach the service, got '%s'", gotCode)
+			}
+		})
+	}
+}
@@ -2,11 +2,21 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"go-mongodb-test/auth"
+	"go-mongodb-test/mailer"
 	"go-mongodb-test/models"
+	"go-mongodb-test/services"
 
 	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
 // UserServiceProvider interface for user operations
@@ -15,13 +25,38 @@ type UserServiceProvider interface {
 	GetUserByID(ctx context.Context, id string) (*models.User, error)
 	GetUserByUserID(ctx context.Context, userID string) (*models.User, error)
 	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
-	UpdateUser(ctx context.Context, id string, req *models.UpdateUserRequest) (*models.User, error)
-	DeleteUser(ctx context.Context, id string) error
-	ListUsers(ctx context.Context) ([]*models.User, error)
+	UserExists(ctx context.Context, id string) (bool, error)
+	UserIDExists(ctx context.Context, userID string) (bool, error)
+	SearchUsers(ctx context.Context, query string) ([]*models.User, error)
+	UpdateUser(ctx context.Context, id string, req *models.UpdateUserRequest, expectedUpdatedAt *time.Time) (*models.User, error)
+	ReplaceUser(ctx context.Context, id string, req *models.ReplaceUserRequest, expectedUpdatedAt *time.Time) (*models.User, error)
+	DeleteUser(ctx context.Context, id string) (*models.User, error)
+	DeleteAllUsers(ctx context.Context) (int64, error)
+	RestoreUser(ctx context.Context, id string) (*models.User, error)
+	ListUsers(ctx context.Context, opts models.ListUsersOptions) ([]*models.User, error)
+	CountListedUsers(ctx context.Context, opts models.ListUsersOptions) (int64, error)
+	ListInactiveUsers(ctx context.Context, cutoff time.Time, limit, offset int) ([]*models.User, error)
+	ExportUsers(ctx context.Context, includeDeleted bool, fn func(*models.User) error) error
+	CountUsers(ctx context.Context, emailDomain string) (int64, error)
+	Stats(ctx context.Context) (*models.UserStats, error)
+	SetRoles(ctx context.Context, id string, roles []string) (*models.User, error)
+	SetStatus(ctx context.Context, id, status string) (*models.User, error)
+	SetPassword(ctx context.Context, id, newPassword string) error
+	GetIdempotencyKey(ctx context.Context, key string) (*models.IdempotencyKey, error)
+	ListAuditEvents(ctx context.Context, id string, limit, offset int) ([]*models.AuditEvent, error)
+	SubscribeUserEvents(ctx context.Context) (<-chan *models.AuditEvent, error)
+	SaveIdempotencyKey(ctx context.Context, key, requestHash string, userID bson.ObjectID) error
+	StartTwoFactor(ctx context.Context, id string) (*models.TwoFactorSetup, error)
+	ConfirmTwoFactor(ctx context.Context, id, code string) error
+	ListIndexes(ctx context.Context) ([]models.IndexInfo, error)
+	ListSessions(ctx context.Context, id string) ([]*models.RefreshToken, error)
+	RevokeSession(ctx context.Context, id, sessionID string) error
+	RevokeOtherSessions(ctx context.Context, id, keepSessionID string) (int64, error)
 }
 
 type UserHandler struct {
 	userService UserServiceInterface
+	mailer      mailer.Mailer
 }
 
 // Define the interface based on the methods we need
@@ -30,177 +65,905 @@ type UserServiceInterface interface {
 	GetUserByID(ctx context.Context, id string) (*models.User, error)
 	GetUserByUserID(ctx context.Context, userID string) (*models.User, error)
 	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
-	UpdateUser(ctx context.Context, id string, req *models.UpdateUserRequest) (*models.User, error)
-	DeleteUser(ctx context.Context, id string) error
-	ListUsers(ctx context.Context) ([]*models.User, error)
+	UserExists(ctx context.Context, id string) (bool, error)
+	UserIDExists(ctx context.Context, userID string) (bool, error)
+	SearchUsers(ctx context.Context, query string) ([]*models.User, error)
+	UpdateUser(ctx context.Context, id string, req *models.UpdateUserRequest, expectedUpdatedAt *time.Time) (*models.User, error)
+	ReplaceUser(ctx context.Context, id string, req *models.ReplaceUserRequest, expectedUpdatedAt *time.Time) (*models.User, error)
+	DeleteUser(ctx context.Context, id string) (*models.User, error)
+	DeleteAllUsers(ctx context.Context) (int64, error)
+	RestoreUser(ctx context.Context, id string) (*models.User, error)
+	ListUsers(ctx context.Context, opts models.ListUsersOptions) ([]*models.User, error)
+	CountListedUsers(ctx context.Context, opts models.ListUsersOptions) (int64, error)
+	ListInactiveUsers(ctx context.Context, cutoff time.Time, limit, offset int) ([]*models.User, error)
+	ExportUsers(ctx context.Context, includeDeleted bool, fn func(*models.User) error) error
+	CountUsers(ctx context.Context, emailDomain string) (int64, error)
+	Stats(ctx context.Context) (*models.UserStats, error)
+	SetRoles(ctx context.Context, id string, roles []string) (*models.User, error)
+	SetStatus(ctx context.Context, id, status string) (*models.User, error)
+	SetPassword(ctx context.Context, id, newPassword string) error
+	GetIdempotencyKey(ctx context.Context, key string) (*models.IdempotencyKey, error)
+	ListAuditEvents(ctx context.Context, id string, limit, offset int) ([]*models.AuditEvent, error)
+	SubscribeUserEvents(ctx context.Context) (<-chan *models.AuditEvent, error)
+	SaveIdempotencyKey(ctx context.Context, key, requestHash string, userID bson.ObjectID) error
+	StartTwoFactor(ctx context.Context, id string) (*models.TwoFactorSetup, error)
+	ConfirmTwoFactor(ctx context.Context, id, code string) error
+	ListIndexes(ctx context.Context) ([]models.IndexInfo, error)
+	ListSessions(ctx context.Context, id string) ([]*models.RefreshToken, error)
+	RevokeSession(ctx context.Context, id, sessionID string) error
+	RevokeOtherSessions(ctx context.Context, id, keepSessionID string) (int64, error)
 }
 
-func NewUserHandler(userService UserServiceInterface) *UserHandler {
+func NewUserHandler(userService UserServiceInterface, m mailer.Mailer) *UserHandler {
 	return &UserHandler{
 		userService: userService,
+		mailer:      m,
 	}
 }
 
+// CreateUser registers a new user
+//
+//	@Summary		Create a user
+//	@Description	Creates a user with a unique user_id and email. The password is stored as a bcrypt or argon2id hash, per PASSWORD_HASH_ALGO.
+//	@Description	A verification token is emailed to the new address.
+//	@Description	With an Idempotency-Key header, a retry with the same key and body within 24 hours returns the
+//	@Description	user the first request created instead of creating another. With STORAGE=memory there is nowhere to keep keys, so the header is ignored.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			user			body		models.CreateUserRequest	true	"User to create"
+//	@Param			Idempotency-Key	header		string						false	"Unique key for safely retrying the signup"	example(5f0c8a4e-3b1d-4c2a-9e7f-6d1b2a3c4d5e)
+//	@Success		201				{object}	models.UserResponse
+//	@Header			201				{string}	Location			"Path of the created user"
+//	@Header			201				{string}	Idempotent-Replayed	"true when the response repeats an earlier signup with the same key"
+//	@Failure		400				{object}	ValidationErrorResponse
+//	@Failure		409				{object}	ErrorResponse
+//	@Failure		422				{object}	ErrorResponse
+//	@Failure		429				{object}	ErrorResponse
+//	@Failure		500				{object}	ErrorResponse
+//	@Failure		504				{object}	ErrorResponse
+//	@Router			/users [post]
 func (h *UserHandler) CreateUser(c echo.Context) error {
+	idempotencyKey := strings.TrimSpace(c.Request().Header.Get(HeaderIdempotencyKey))
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		return errorResponse(c, http.StatusBadRequest, fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLength))
+	}
+
 	var req models.CreateUserRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid request body",
-		})
+		return errorResponse(c, http.StatusBadRequest, "Invalid request body")
 	}
 
-	if req.UserID == "" || req.Email == "" || req.Password == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "user_id, email, and password are required",
-		})
+	if err := c.Validate(&req); err != nil {
+		return validationError(c, err)
 	}
 
-	user, err := h.userService.CreateUser(c.Request().Context(), &req)
+	ctx := c.Request().Context()
+	var requestHash string
+	if idempotencyKey != "" {
+		requestHash = createUserRequestHash(&req)
+		record, err := h.service(c).GetIdempotencyKey(ctx, idempotencyKey)
+		switch {
+		case errors.Is(err, services.ErrStorageUnsupported):
+			// Without MongoDB there is nowhere to keep keys, so the header is ignored
+			idempotencyKey = ""
+		case err != nil:
+			return serverError(c, err)
+		case record != nil:
+			return h.replayCreateUser(c, record, requestHash)
+		}
+	}
+
+	// Two concurrent requests with the same key both get here, but the unique
+	// user_id and email indexes still let only one of them create the user
+	user, err := h.service(c).CreateUser(ctx, &req)
 	if err != nil {
-		return c.JSON(http.StatusConflict, map[string]string{
-			"error": err.Error(),
-		})
+		if errors.Is(err, models.ErrInvalidEmail) {
+			return invalidEmailResponse(c)
+		}
+		if errors.Is(err, services.ErrUserExists) || errors.Is(err, services.ErrEmailExists) {
+			return errorResponse(c, http.StatusConflict, err.Error())
+		}
+		return serverError(c, err)
 	}
 
-	return c.JSON(http.StatusCreated, user)
+	// The user exists either way, so a key that cannot be stored only costs the
+	// retry its replay
+	if idempotencyKey != "" {
+		if err := h.service(c).SaveIdempotencyKey(ctx, idempotencyKey, requestHash, user.ID); err != nil {
+			slog.ErrorContext(ctx, "Failed to store idempotency key", "error", err)
+		}
+	}
+
+	// Email delivery problems must not fail a signup that has already been stored
+	if err := h.mailer.SendVerification(ctx, user.Email, user.VerificationToken); err != nil {
+		slog.ErrorContext(ctx, "Failed to send verification email", "error", err)
+	}
+
+	setUserLocation(c, user)
+	return respond(c, http.StatusCreated, models.NewUserResponse(user), nil)
 }
 
+// GetUser looks a user up by MongoDB ID
+//
+//	@Summary		Get a user by ID
+//	@Description	With include_age=true the body also carries age, the whole seconds since created_at.
+//	@Description	Without include_age the response carries Last-Modified, and If-Modified-Since answers 304 while the user is unchanged since that time.
+//	@Tags			users
+//	@Produce		json
+//	@Param			id					path		string	true	"MongoDB ObjectID"					example(665f1c2e8b3a4d2f9c1e7a10)
+//	@Param			include_age			query		bool	false	"Add the computed age in seconds"	example(true)
+//	@Param			If-Modified-Since	header		string	false	"Last-Modified from a previous read"	example(Tue, 04 Jun 2024 12:00:00 GMT)
+//	@Success		200					{object}	UserWithAgeResponse
+//	@Header			200					{string}	ETag			"Version of the user, for If-Match on PUT and PATCH"
+//	@Header			200					{string}	Last-Modified	"updated_at of the user, unless include_age is set"
+//	@Success		304					"The user has not changed since If-Modified-Since"
+//	@Failure		400					{object}	ErrorResponse
+//	@Failure		404					{object}	ErrorResponse
+//	@Failure		500					{object}	ErrorResponse
+//	@Failure		504					{object}	ErrorResponse
+//	@Router			/users/{id} [get]
 func (h *UserHandler) GetUser(c echo.Context) error {
 	id := c.Param("id")
 	if id == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "User ID is required",
-		})
+		return errorResponse(c, http.StatusBadRequest, "User ID is required")
 	}
 
-	user, err := h.userService.GetUserByID(c.Request().Context(), id)
+	includeAge, err := boolQueryParam(c, "include_age")
 	if err != nil {
-		if err.Error() == "user not found" {
-			return c.JSON(http.StatusNotFound, map[string]string{
-				"error": "User not found",
-			})
+		return errorResponse(c, http.StatusBadRequest, err.Error())
+	}
+
+	user, err := h.service(c).GetUserByID(c.Request().Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			return errorResponse(c, http.StatusNotFound, "User not found")
+		}
+		if errors.Is(err, services.ErrInvalidUserID) {
+			return errorResponse(c, http.StatusBadRequest, "Invalid user ID")
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": err.Error(),
-		})
+		return serverError(c, err)
 	}
 
-	return c.JSON(http.StatusOK, user)
+	setUserETag(c, user)
+	if includeAge {
+		// The age grows by the second, so these bodies are never the same twice
+		return respond(c, http.StatusOK, newUserWithAge(user, time.Now()), nil)
+	}
+	setUserLastModified(c, user)
+	if notModifiedSince(c, user) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return respond(c, http.StatusOK, models.NewUserResponse(user), nil)
 }
 
+// GetMe returns the user identified by the bearer token
+//
+//	@Summary	Get the authenticated user
+//	@Tags		users
+//	@Produce	json
+//	@Security	bearerauth
+//	@Success	200	{object}	models.UserResponse
+//	@Header		200	{string}	ETag	"Version of the user, for If-Match on PUT and PATCH"
+//	@Failure	401	{object}	ErrorResponse
+//	@Failure	500	{object}	ErrorResponse
+//	@Failure	504	{object}	ErrorResponse
+//	@Router		/users/me [get]
+func (h *UserHandler) GetMe(c echo.Context) error {
+	claims, ok := auth.ClaimsFromContext(c)
+	if !ok {
+		return errorResponse(c, http.StatusUnauthorized, "Authentication required")
+	}
+
+	user, err := h.service(c).GetUserByID(c.Request().Context(), claims.ID)
+	if err != nil {
+		// A token for an account that has since been deleted no longer identifies anyone
+		if errors.Is(err, services.ErrUserNotFound) || errors.Is(err, services.ErrInvalidUserID) {
+			return errorResponse(c, http.StatusUnauthorized, "Authentication required")
+		}
+		return serverError(c, err)
+	}
+
+	setUserETag(c, user)
+	return respond(c, http.StatusOK, models.NewUserResponse(user), nil)
+}
+
+// GetUserByUserID looks a user up by exact user_id; it is served from GET /users/search
 func (h *UserHandler) GetUserByUserID(c echo.Context) error {
 	userID := c.QueryParam("user_id")
 	if userID == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "user_id query parameter is required",
-		})
+		return errorResponse(c, http.StatusBadRequest, "user_id query parameter is required")
 	}
 
-	user, err := h.userService.GetUserByUserID(c.Request().Context(), userID)
+	user, err := h.service(c).GetUserByUserID(c.Request().Context(), userID)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": err.Error(),
-		})
+		return serverError(c, err)
 	}
 
 	if user == nil {
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": "User not found",
-		})
+		return errorResponse(c, http.StatusNotFound, "User not found")
+	}
+
+	return respond(c, http.StatusOK, models.NewUserResponse(user), nil)
+}
+
+// UserExists answers HEAD /users/:id with 200 or 404 and no body
+//
+//	@Summary		Check that a user exists
+//	@Description	Cheaper than GET: the user is counted rather than read, and nothing about it is returned.
+//	@Tags			users
+//	@Param			id	path	string	true	"MongoDB ObjectID"	example(665f1c2e8b3a4d2f9c1e7a10)
+//	@Success		200
+//	@Failure		400
+//	@Failure		404
+//	@Failure		500
+//	@Failure		501
+//	@Failure		504
+//	@Router			/users/{id} [head]
+func (h *UserHandler) UserExists(c echo.Context) error {
+	exists, err := h.service(c).UserExists(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidUserID) {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		return serverError(c, err)
+	}
+
+	return existenceResponse(c, exists)
+}
+
+// UserIDExists answers HEAD /users/search?user_id= with 200 or 404 and no body,
+// so that signup forms can check whether a user_id is taken
+//
+//	@Summary		Check whether a user_id is taken
+//	@Description	Cheaper than GET: the user is counted rather than read, and nothing about it is returned.
+//	@Tags			users
+//	@Param			user_id	query	string	true	"Exact user_id"	example(alice)
+//	@Success		200
+//	@Failure		400
+//	@Failure		404
+//	@Failure		500
+//	@Failure		501
+//	@Failure		504
+//	@Router			/users/search [head]
+func (h *UserHandler) UserIDExists(c echo.Context) error {
+	userID := c.QueryParam("user_id")
+	if userID == "" {
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	exists, err := h.service(c).UserIDExists(c.Request().Context(), userID)
+	if err != nil {
+		return serverError(c, err)
 	}
 
-	return c.JSON(http.StatusOK, user)
+	return existenceResponse(c, exists)
+}
+
+// existenceResponse answers a HEAD existence check
+func existenceResponse(c echo.Context, exists bool) error {
+	if !exists {
+		return c.NoContent(http.StatusNotFound)
+	}
+	return c.NoContent(http.StatusOK)
 }
 
+// GetUserByEmail looks a user up by exact email address
+//
+//	@Summary	Find a user by email
+//	@Tags		users
+//	@Produce	json
+//	@Param		email	query		string	true	"Email address"	example(alice@example.com)
+//	@Success	200		{object}	models.UserResponse
+//	@Failure	400		{object}	ErrorResponse
+//	@Failure	404		{object}	ErrorResponse
+//	@Failure	500		{object}	ErrorResponse
+//	@Failure	504		{object}	ErrorResponse
+//	@Router		/users/search/email [get]
 func (h *UserHandler) GetUserByEmail(c echo.Context) error {
 	email := c.QueryParam("email")
 	if email == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "email query parameter is required",
-		})
+		return errorResponse(c, http.StatusBadRequest, "email query parameter is required")
 	}
 
-	user, err := h.userService.GetUserByEmail(c.Request().Context(), email)
+	user, err := h.service(c).GetUserByEmail(c.Request().Context(), email)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": err.Error(),
-		})
+		return serverError(c, err)
 	}
 
 	if user == nil {
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": "User not found",
-		})
+		return errorResponse(c, http.StatusNotFound, "User not found")
+	}
+
+	return respond(c, http.StatusOK, models.NewUserResponse(user), nil)
+}
+
+// SearchUsers finds users whose user_id or email contains the query
+//
+//	@Summary		Search users
+//	@Description	With q, returns users whose user_id or email contains q (case-insensitive).
+//	@Description	Without q, user_id is matched exactly and the single matching user is returned instead of a list.
+//	@Tags			users
+//	@Produce		json
+//	@Param			q		query		string	false	"Partial user_id or email"	example(ali)
+//	@Param			user_id	query		string	false	"Exact user_id, used when q is absent"	example(alice)
+//	@Success		200		{object}	UserListResponse
+//	@Failure		400		{object}	ErrorResponse
+//	@Failure		404		{object}	ErrorResponse
+//	@Failure		500		{object}	ErrorResponse
+//	@Failure		501		{object}	ErrorResponse	"Searching with q is not available with STORAGE=memory"
+//	@Failure		504		{object}	ErrorResponse
+//	@Router			/users/search [get]
+func (h *UserHandler) SearchUsers(c echo.Context) error {
+	query := c.QueryParam("q")
+	if query == "" {
+		return errorResponse(c, http.StatusBadRequest, "q query parameter is required")
+	}
+
+	users, err := h.service(c).SearchUsers(c.Request().Context(), query)
+	if err != nil {
+		return serverError(c, err)
 	}
 
-	return c.JSON(http.StatusOK, user)
+	return respond(c, http.StatusOK, UserList{Users: models.NewUserResponses(users)}, ListMeta{Count: len(users)})
 }
 
+// UpdateUser handles PATCH, applying only the fields present in the body, or an RFC
+// 6902 JSON Patch sent as application/json-patch+json. An If-Match header turns it
+// into a compare-and-set against the user's ETag.
+//
+//	@Summary		Partially update a user
+//	@Description	A JSON body sets the fields it contains; an empty string clears a profile field.
+//	@Description	An application/json-patch+json body is applied to user_id, email, first_name, last_name and avatar_url, and may add password.
+//	@Description	Removing a profile field clears it. A failed test operation answers 409, and a patch that cannot be applied 422.
+//	@Description	Changing the password revokes every refresh token of the user, logging out all of their sessions.
+//	@Tags			users
+//	@Accept			json
+//	@Accept			application/json-patch+json
+//	@Produce		json
+//	@Security		bearerauth
+//	@Param			id			path		string						true	"MongoDB ObjectID"	example(665f1c2e8b3a4d2f9c1e7a10)
+//	@Param			user		body		models.UpdateUserRequest	true	"Fields to change, or a JSON Patch document"
+//	@Param			If-Match	header		string						false	"ETag from a previous read; the update is rejected if the user changed since"	example("1717502400000")
+//	@Success		200			{object}	models.UserResponse
+//	@Header			200			{string}	ETag	"New version of the user"
+//	@Failure		400			{object}	ValidationErrorResponse
+//	@Failure		401			{object}	ErrorResponse
+//	@Failure		403			{object}	ErrorResponse	"Neither the user nor an admin"
+//	@Failure		404			{object}	ErrorResponse
+//	@Failure		409			{object}	ErrorResponse
+//	@Failure		412			{object}	ErrorResponse	"If-Match does not match the current version"
+//	@Failure		422			{object}	ErrorResponse	"JSON Patch could not be applied"
+//	@Failure		500			{object}	ErrorResponse
+//	@Failure		504			{object}	ErrorResponse
+//	@Router			/users/{id} [patch]
 func (h *UserHandler) UpdateUser(c echo.Context) error {
 	id := c.Param("id")
 	if id == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "User ID is required",
-		})
+		return errorResponse(c, http.StatusBadRequest, "User ID is required")
+	}
+
+	if isJSONPatch(c.Request().Header.Get(echo.HeaderContentType)) {
+		return h.patchUser(c, id)
 	}
 
 	var req models.UpdateUserRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid request body",
-		})
+		return errorResponse(c, http.StatusBadRequest, "Invalid request body")
 	}
 
-	user, err := h.userService.UpdateUser(c.Request().Context(), id, &req)
+	if err := c.Validate(&req); err != nil {
+		return validationError(c, err)
+	}
+
+	expectedUpdatedAt, err := ifMatchVersion(c)
 	if err != nil {
-		if err.Error() == "user not found" {
-			return c.JSON(http.StatusNotFound, map[string]string{
-				"error": "User not found",
-			})
-		}
-		return c.JSON(http.StatusConflict, map[string]string{
-			"error": err.Error(),
-		})
+		return updateErrorResponse(c, err)
 	}
 
-	return c.JSON(http.StatusOK, user)
+	user, err := h.service(c).UpdateUser(c.Request().Context(), id, &req, expectedUpdatedAt)
+	if err != nil {
+		return updateErrorResponse(c, err)
+	}
+
+	setUserETag(c, user)
+	return respond(c, http.StatusOK, models.NewUserResponse(user), nil)
 }
 
+// ReplaceUser handles PUT, which requires every field and replaces them all
+//
+//	@Summary		Replace a user
+//	@Description	The password is replaced too, so every refresh token of the user is revoked.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Security		bearerauth
+//	@Param			id			path		string						true	"MongoDB ObjectID"	example(665f1c2e8b3a4d2f9c1e7a10)
+//	@Param			user		body		models.ReplaceUserRequest	true	"Complete user"
+//	@Param			If-Match	header		string						false	"ETag from a previous read; the update is rejected if the user changed since"	example("1717502400000")
+//	@Success		200			{object}	models.UserResponse
+//	@Header			200			{string}	ETag	"New version of the user"
+//	@Failure		400			{object}	ValidationErrorResponse
+//	@Failure		401			{object}	ErrorResponse
+//	@Failure		403			{object}	ErrorResponse	"Neither the user nor an admin"
+//	@Failure		404			{object}	ErrorResponse
+//	@Failure		409			{object}	ErrorResponse
+//	@Failure		412			{object}	ErrorResponse	"If-Match does not match the current version"
+//	@Failure		500			{object}	ErrorResponse
+//	@Failure		504			{object}	ErrorResponse
+//	@Router			/users/{id} [put]
+func (h *UserHandler) ReplaceUser(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return errorResponse(c, http.StatusBadRequest, "User ID is required")
+	}
+
+	var req models.ReplaceUserRequest
+	if err := c.Bind(&req); err != nil {
+		return errorResponse(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return validationError(c, err)
+	}
+
+	expectedUpdatedAt, err := ifMatchVersion(c)
+	if err != nil {
+		return updateErrorResponse(c, err)
+	}
+
+	user, err := h.service(c).ReplaceUser(c.Request().Context(), id, &req, expectedUpdatedAt)
+	if err != nil {
+		return updateErrorResponse(c, err)
+	}
+
+	setUserETag(c, user)
+	return respond(c, http.StatusOK, models.NewUserResponse(user), nil)
+}
+
+// invalidEmailResponse reports an address the service rejected in the same shape as
+// validator failures, so forms can highlight the field
+func invalidEmailResponse(c echo.Context) error {
+	var fieldErrs models.FieldErrors
+	fieldErrs.Add("email", "must be a valid email address")
+	return fieldErrorsResponse(c, fieldErrs)
+}
+
+// updateErrorResponse maps errors from update and replace to status codes
+func updateErrorResponse(c echo.Context, err error) error {
+	if errors.Is(err, services.ErrUserNotFound) {
+		return errorResponse(c, http.StatusNotFound, "User not found")
+	}
+	if errors.Is(err, services.ErrInvalidUserID) {
+		return errorResponse(c, http.StatusBadRequest, "Invalid user ID")
+	}
+	if errors.Is(err, models.ErrInvalidEmail) {
+		return invalidEmailResponse(c)
+	}
+	if errors.Is(err, services.ErrUserExists) || errors.Is(err, services.ErrEmailExists) {
+		return errorResponse(c, http.StatusConflict, err.Error())
+	}
+	if errors.Is(err, services.ErrVersionMismatch) {
+		return errorResponse(c, http.StatusPreconditionFailed, "User has been modified; fetch it again and retry")
+	}
+	if errors.Is(err, errMultipleETags) {
+		return errorResponse(c, http.StatusBadRequest, err.Error())
+	}
+	return serverError(c, err)
+}
+
+// DeleteUser soft-deletes a user
+//
+//	@Summary		Delete a user
+//	@Description	Soft-deletes the user and records who deleted whom in the server log.
+//	@Description	With Prefer: return=representation the deleted user is returned instead of a message.
+//	@Tags			users
+//	@Produce		json
+//	@Security		bearerauth
+//	@Security		apikeyauth
+//	@Param			id		path		string	true	"MongoDB ObjectID"	example(665f1c2e8b3a4d2f9c1e7a10)
+//	@Param			Prefer	header		string	false	"return=representation to receive the deleted user"
+//	@Success		200		{object}	MessageResponse
+//	@Failure		400		{object}	ErrorResponse
+//	@Failure		401		{object}	ErrorResponse
+//	@Failure		403		{object}	ErrorResponse
+//	@Failure		404		{object}	ErrorResponse
+//	@Failure		500		{object}	ErrorResponse
+//	@Failure		504		{object}	ErrorResponse
+//	@Router			/users/{id} [delete]
 func (h *UserHandler) DeleteUser(c echo.Context) error {
 	id := c.Param("id")
 	if id == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "User ID is required",
-		})
+		return errorResponse(c, http.StatusBadRequest, "User ID is required")
 	}
 
-	err := h.userService.DeleteUser(c.Request().Context(), id)
+	ctx := c.Request().Context()
+	user, err := h.service(c).DeleteUser(ctx, id)
 	if err != nil {
-		if err.Error() == "user not found" {
-			return c.JSON(http.StatusNotFound, map[string]string{
-				"error": "User not found",
-			})
+		if errors.Is(err, services.ErrUserNotFound) {
+			return errorResponse(c, http.StatusNotFound, "User not found")
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": err.Error(),
-		})
+		if errors.Is(err, services.ErrInvalidUserID) {
+			return errorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		}
+		return serverError(c, err)
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{
-		"message": "User deleted successfully",
-	})
+	// The route requires an admin token, but the handler works without one too
+	deletedBy := ""
+	if claims, ok := auth.ClaimsFromContext(c); ok {
+		deletedBy = claims.UserID
+	}
+	slog.InfoContext(ctx, "User deleted", "id", user.ID.Hex(), "user_id", user.UserID, "deleted_by", deletedBy)
+
+	if prefersRepresentation(c.Request().Header.Get("Prefer")) {
+		return respond(c, http.StatusOK, models.NewUserResponse(user), nil)
+	}
+
+	return respond(c, http.StatusOK, MessageResponse{
+		Message: "User deleted successfully",
+	}, nil)
+}
+
+// prefersRepresentation reports whether a Prefer header (RFC 7240) asks for the
+// affected resource in the response body
+func prefersRepresentation(prefer string) bool {
+	for _, preference := range strings.Split(prefer, ",") {
+		// Parameters after ";" do not change the preference itself
+		name, _, _ := strings.Cut(preference, ";")
+		if strings.EqualFold(strings.ReplaceAll(strings.TrimSpace(name), " ", ""), "return=representation") {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteAllUsers permanently removes every user; it is only routed when ALLOW_DESTRUCTIVE is set
+//
+//	@Summary		Delete all users
+//	@Description	Permanently removes every user, including soft-deleted ones. Intended for test teardown:
+//	@Description	the route only exists when the server runs with ALLOW_DESTRUCTIVE=true.
+//	@Tags			users
+//	@Produce		json
+//	@Security		bearerauth
+//	@Success		200	{object}	DeleteAllResponse
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		403	{object}	ErrorResponse
+//	@Failure		500	{object}	ErrorResponse
+//	@Failure		501	{object}	ErrorResponse	"Not available with STORAGE=memory"
+//	@Failure		504	{object}	ErrorResponse
+//	@Router			/users [delete]
+func (h *UserHandler) DeleteAllUsers(c echo.Context) error {
+	deleted, err := h.service(c).DeleteAllUsers(c.Request().Context())
+	if err != nil {
+		return serverError(c, err)
+	}
+
+	slog.WarnContext(c.Request().Context(), "Deleted all users", "deleted_count", deleted)
+
+	return respond(c, http.StatusOK, DeleteAllResponse{
+		DeletedCount: deleted,
+	}, nil)
 }
 
+// RestoreUser clears the deletion mark on a soft-deleted user
+//
+//	@Summary	Restore a deleted user
+//	@Tags		users
+//	@Produce	json
+//	@Security	bearerauth
+//	@Security	apikeyauth
+//	@Param		id	path		string	true	"MongoDB ObjectID"	example(665f1c2e8b3a4d2f9c1e7a10)
+//	@Success	200	{object}	models.UserResponse
+//	@Failure	400	{object}	ErrorResponse
+//	@Failure	401	{object}	ErrorResponse
+//	@Failure	403	{object}	ErrorResponse
+//	@Failure	404	{object}	ErrorResponse
+//	@Failure	500	{object}	ErrorResponse
+//	@Failure	501	{object}	ErrorResponse	"Not available with STORAGE=memory"
+//	@Failure	504	{object}	ErrorResponse
+//	@Router		/users/{id}/restore [post]
+func (h *UserHandler) RestoreUser(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return errorResponse(c, http.StatusBadRequest, "User ID is required")
+	}
+
+	user, err := h.service(c).RestoreUser(c.Request().Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			return errorResponse(c, http.StatusNotFound, "User not found")
+		}
+		if errors.Is(err, services.ErrInvalidUserID) {
+			return errorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		}
+		return serverError(c, err)
+	}
+
+	return respond(c, http.StatusOK, models.NewUserResponse(user), nil)
+}
+
+// ListUsers returns all users, newest first unless sort is given, optionally
+// limited to a created_at window or to one page
+//
+//	@Summary		List users
+//	@Description	Returns every matching user unless limit or offset is given. Then only that page is returned, with the total number of matches and a Link header (RFC 8288) whose first, prev, next and last URLs repeat the request for the other pages.
+//	@Tags			users
+//	@Produce		json
+//	@Security		bearerauth
+//	@Security		apikeyauth
+//	@Param			include_deleted	query		bool				false	"Include soft-deleted users"							example(false)
+//	@Param			status			query		string				false	"Only users with this account status"					Enums(active, disabled)																		example(active)
+//	@Param			sort			query		string				false	"Sort field, prefix with - for descending"				Enums(created_at, -created_at, updated_at, -updated_at, user_id, -user_id, email, -email)	example(-created_at)
+//	@Param			created_after	query		string				false	"Only users created at or after this RFC3339 time"		format(date-time)																			example(2024-01-01T00:00:00Z)
+//	@Param			created_before	query		string				false	"Only users created at or before this RFC3339 time"		format(date-time)																			example(2024-12-31T23:59:59Z)
+//	@Param			user_ids		query		string				false	"Comma-separated user_id values to return, at most 200"	example(alice,bob)
+//	@Param			email_domain	query		string				false	"Only users whose email is in this domain"				example(example.com)
+//	@Param			limit			query		int					false	"Users per page, at most 200"							default(50)	example(50)
+//	@Param			offset			query		int					false	"Users to skip"											default(0)	example(0)
+//	@Success		200				{object}	UserPageResponse	"Without limit and offset, only users and count are set"
+//	@Header			200				{string}	Link				"First, prev, next and last pages, when paging"
+//	@Failure		400				{object}	ErrorResponse
+//	@Failure		401				{object}	ErrorResponse
+//	@Failure		403				{object}	ErrorResponse
+//	@Failure		500				{object}	ErrorResponse
+//	@Failure		504				{object}	ErrorResponse
+//	@Router			/users [get]
 func (h *UserHandler) ListUsers(c echo.Context) error {
-	users, err := h.userService.ListUsers(c.Request().Context())
+	includeDeleted, err := boolQueryParam(c, "include_deleted")
+	if err != nil {
+		return errorResponse(c, http.StatusBadRequest, err.Error())
+	}
+	opts := models.ListUsersOptions{IncludeDeleted: includeDeleted}
+
+	if opts.Status = c.QueryParam("status"); opts.Status != "" {
+		if err := models.ValidateStatus(opts.Status); err != nil {
+			return errorResponse(c, http.StatusBadRequest, "status must be one of: "+strings.Join(models.ValidStatuses, ", "))
+		}
+	}
+
+	sortField, sortDescending, err := models.ParseSort(c.QueryParam("sort"))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": err.Error(),
-		})
+		return errorResponse(c, http.StatusBadRequest, "sort must be one of: "+strings.Join(models.SortableUserFields, ", "))
+	}
+	opts.SortField = sortField
+	opts.SortDescending = sortDescending
+
+	if opts.CreatedAfter, err = timeQueryParam(c, "created_after"); err != nil {
+		return errorResponse(c, http.StatusBadRequest, err.Error())
+	}
+	if opts.CreatedBefore, err = timeQueryParam(c, "created_before"); err != nil {
+		return errorResponse(c, http.StatusBadRequest, err.Error())
+	}
+	if opts.CreatedAfter != nil && opts.CreatedBefore != nil && opts.CreatedAfter.After(*opts.CreatedBefore) {
+		return errorResponse(c, http.StatusBadRequest, "created_after must not be later than created_before")
 	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"users": users,
-		"count": len(users),
+	opts.UserIDs = splitQueryList(c.QueryParam("user_ids"))
+	if len(opts.UserIDs) > models.MaxListUserIDs {
+		return errorResponse(c, http.StatusBadRequest, fmt.Sprintf("user_ids accepts at most %d ids", models.MaxListUserIDs))
+	}
+
+	opts.EmailDomain = c.QueryParam("email_domain")
+
+	// Listing everything stays the default, so paging only applies when asked for
+	if c.QueryParam("limit") != "" || c.QueryParam("offset") != "" {
+		return h.listUsersPage(c, opts)
+	}
+
+	users, err := h.service(c).ListUsers(c.Request().Context(), opts)
+	if err != nil {
+		return serverError(c, err)
+	}
+
+	return respond(c, http.StatusOK, UserList{Users: models.NewUserResponses(users)}, ListMeta{Count: len(users)})
+}
+
+// listUsersPage serves ListUsers when limit or offset is given
+func (h *UserHandler) listUsersPage(c echo.Context, opts models.ListUsersOptions) error {
+	limit, offset, err := pageQueryParams(c)
+	if err != nil {
+		return errorResponse(c, http.StatusBadRequest, err.Error())
+	}
+	opts.Limit, opts.Offset = limit, offset
+
+	ctx := c.Request().Context()
+	total, err := h.service(c).CountListedUsers(ctx, opts)
+	if err != nil {
+		return serverError(c, err)
+	}
+	users, err := h.service(c).ListUsers(ctx, opts)
+	if err != nil {
+		return serverError(c, err)
+	}
+
+	setPageLinks(c, total, limit, offset)
+	return respond(c, http.StatusOK, UserList{Users: models.NewUserResponses(users)}, UserPageMeta{
+		Count:   len(users),
+		Total:   total,
+		Limit:   limit,
+		Offset:  offset,
+		HasMore: int64(offset+len(users)) < total,
 	})
-}
\ No newline at end of file
+}
+
+// splitQueryList splits a comma-separated query value, dropping empty entries
+func splitQueryList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// boolQueryParam parses an optional boolean query parameter, defaulting to false
+func boolQueryParam(c echo.Context, name string) (bool, error) {
+	raw := c.QueryParam(name)
+	if raw == "" {
+		return false, nil
+	}
+
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("%s must be a boolean", name)
+	}
+	return value, nil
+}
+
+// timeQueryParam parses an optional RFC3339 query parameter
+func timeQueryParam(c echo.Context, name string) (*time.Time, error) {
+	raw := c.QueryParam(name)
+	if raw == "" {
+		return nil, nil
+	}
+
+	value, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC3339 timestamp", name)
+	}
+	return &value, nil
+}
+
+// CountUsers returns the number of users, optionally within one email domain
+//
+//	@Summary	Count users
+//	@Tags		users
+//	@Produce	json
+//	@Param		email_domain	query		string	false	"Only count emails in this domain"	example(example.com)
+//	@Success	200				{object}	CountResponse
+//	@Failure	500				{object}	ErrorResponse
+//	@Failure	501				{object}	ErrorResponse	"Not available with STORAGE=memory"
+//	@Failure	504				{object}	ErrorResponse
+//	@Router		/users/count [get]
+func (h *UserHandler) CountUsers(c echo.Context) error {
+	count, err := h.service(c).CountUsers(c.Request().Context(), c.QueryParam("email_domain"))
+	if err != nil {
+		return serverError(c, err)
+	}
+
+	return respond(c, http.StatusOK, CountResponse{
+		Count: count,
+	}, nil)
+}
+
+// SetUserRoles replaces the roles granted to a user
+//
+//	@Summary	Set a user's roles
+//	@Tags		users
+//	@Accept		json
+//	@Produce	json
+//	@Security	bearerauth
+//	@Security	apikeyauth
+//	@Param		id		path		string					true	"MongoDB ObjectID"	example(665f1c2e8b3a4d2f9c1e7a10)
+//	@Param		roles	body		models.SetRolesRequest	true	"Roles to grant"
+//	@Success	200		{object}	models.UserResponse
+//	@Failure	400		{object}	ValidationErrorResponse
+//	@Failure	401		{object}	ErrorResponse
+//	@Failure	403		{object}	ErrorResponse
+//	@Failure	404		{object}	ErrorResponse
+//	@Failure	500		{object}	ErrorResponse
+//	@Failure	501		{object}	ErrorResponse	"Not available with STORAGE=memory"
+//	@Failure	504		{object}	ErrorResponse
+//	@Router		/users/{id}/roles [put]
+func (h *UserHandler) SetUserRoles(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return errorResponse(c, http.StatusBadRequest, "User ID is required")
+	}
+
+	var req models.SetRolesRequest
+	if err := c.Bind(&req); err != nil {
+		return errorResponse(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return validationError(c, err)
+	}
+
+	user, err := h.service(c).SetRoles(c.Request().Context(), id, req.Roles)
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			return errorResponse(c, http.StatusNotFound, "User not found")
+		}
+		if errors.Is(err, services.ErrInvalidUserID) {
+			return errorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		}
+		if errors.Is(err, models.ErrInvalidRole) {
+			return errorResponse(c, http.StatusBadRequest, "roles must be one of: "+strings.Join(models.ValidRoles, ", "))
+		}
+		return serverError(c, err)
+	}
+
+	return respond(c, http.StatusOK, models.NewUserResponse(user), nil)
+}
+
+// ActivateUser re-enables login for a deactivated user
+//
+//	@Summary	Activate a user
+//	@Tags		users
+//	@Produce	json
+//	@Security	bearerauth
+//	@Security	apikeyauth
+//	@Param		id	path		string	true	"MongoDB ObjectID"	example(665f1c2e8b3a4d2f9c1e7a10)
+//	@Success	200	{object}	models.UserResponse
+//	@Failure	400	{object}	ErrorResponse
+//	@Failure	401	{object}	ErrorResponse
+//	@Failure	403	{object}	ErrorResponse
+//	@Failure	404	{object}	ErrorResponse
+//	@Failure	500	{object}	ErrorResponse
+//	@Failure	501	{object}	ErrorResponse	"Not available with STORAGE=memory"
+//	@Failure	504	{object}	ErrorResponse
+//	@Router		/users/{id}/activate [post]
+func (h *UserHandler) ActivateUser(c echo.Context) error {
+	return h.setUserStatus(c, models.StatusActive)
+}
+
+// DeactivateUser suspends a user without deleting it
+//
+//	@Summary		Deactivate a user
+//	@Description	Disabled users are refused at login with 403 and their refresh tokens are revoked.
+//	@Description	Access tokens already issued stay valid until they expire. Admins cannot deactivate themselves.
+//	@Tags			users
+//	@Produce		json
+//	@Security		bearerauth
+//	@Security		apikeyauth
+//	@Param			id	path		string	true	"MongoDB ObjectID"	example(665f1c2e8b3a4d2f9c1e7a10)
+//	@Success		200	{object}	models.UserResponse
+//	@Failure		400	{object}	ErrorResponse
+//	@Failure		401	{object}	ErrorResponse
+//	@Failure		403	{object}	ErrorResponse
+//	@Failure		404	{object}	ErrorResponse
+//	@Failure		500	{object}	ErrorResponse
+//	@Failure		501	{object}	ErrorResponse	"Not available with STORAGE=memory"
+//	@Failure		504	{object}	ErrorResponse
+//	@Router			/users/{id}/deactivate [post]
+func (h *UserHandler) DeactivateUser(c echo.Context) error {
+	// An admin deactivating themselves may leave no one able to undo it
+	if claims, ok := auth.ClaimsFromContext(c); ok && claims.ID == c.Param("id") {
+		return errorResponse(c, http.StatusBadRequest, "You cannot deactivate your own account")
+	}
+	return h.setUserStatus(c, models.StatusDisabled)
+}
+
+// setUserStatus gives the user in the id path parameter the given status
+func (h *UserHandler) setUserStatus(c echo.Context, status string) error {
+	id := c.Param("id")
+	if id == "" {
+		return errorResponse(c, http.StatusBadRequest, "User ID is required")
+	}
+
+	user, err := h.service(c).SetStatus(c.Request().Context(), id, status)
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			return errorResponse(c, http.StatusNotFound, "User not found")
+		}
+		if errors.Is(err, services.ErrInvalidUserID) {
+			return errorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		}
+		return serverError(c, err)
+	}
+
+	return respond(c, http.StatusOK, models.NewUserResponse(user), nil)
+}
@@ -5,13 +5,17 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
+	"go-mongodb-test/auth"
 	"go-mongodb-test/models"
+	"go-mongodb-test/services"
 
 	"github.com/labstack/echo/v4"
 	"go.mongodb.org/mongo-driver/v2/bson"
@@ -19,13 +23,48 @@ import (
 
 // Mock UserService for testing
 type mockUserService struct {
-	createUserFunc     func(ctx context.Context, req *models.CreateUserRequest) (*models.User, error)
-	getUserByIDFunc    func(ctx context.Context, id string) (*models.User, error)
-	getUserByUserIDFunc func(ctx context.Context, userID string) (*models.User, error)
-	getUserByEmailFunc func(ctx context.Context, email string) (*models.User, error)
-	updateUserFunc     func(ctx context.Context, id string, req *models.UpdateUserRequest) (*models.User, error)
-	deleteUserFunc     func(ctx context.Context, id string) error
-	listUsersFunc      func(ctx context.Context) ([]*models.User, error)
+	createUserFunc               func(ctx context.Context, req *models.CreateUserRequest) (*models.User, error)
+	getUserByIDFunc              func(ctx context.Context, id string) (*models.User, error)
+	getUserByUserIDFunc          func(ctx context.Context, userID string) (*models.User, error)
+	getUserByEmailFunc           func(ctx context.Context, email string) (*models.User, error)
+	getUserByIdentifierFunc      func(ctx context.Context, identifier string) (*models.User, error)
+	searchUsersFunc              func(ctx context.Context, query string) ([]*models.User, error)
+	updateUserFunc               func(ctx context.Context, id string, req *models.UpdateUserRequest, expectedUpdatedAt *time.Time) (*models.User, error)
+	replaceUserFunc              func(ctx context.Context, id string, req *models.ReplaceUserRequest, expectedUpdatedAt *time.Time) (*models.User, error)
+	deleteUserFunc               func(ctx context.Context, id string) (*models.User, error)
+	deleteAllUsersFunc           func(ctx context.Context) (int64, error)
+	restoreUserFunc              func(ctx context.Context, id string) (*models.User, error)
+	listUsersFunc                func(ctx context.Context, opts models.ListUsersOptions) ([]*models.User, error)
+	countListedUsersFunc         func(ctx context.Context, opts models.ListUsersOptions) (int64, error)
+	listInactiveUsersFunc        func(ctx context.Context, cutoff time.Time, limit, offset int) ([]*models.User, error)
+	exportUsersFunc              func(ctx context.Context, includeDeleted bool, fn func(*models.User) error) error
+	countUsersFunc               func(ctx context.Context, emailDomain string) (int64, error)
+	statsFunc                    func(ctx context.Context) (*models.UserStats, error)
+	createPasswordResetTokenFunc func(ctx context.Context, email string) (string, error)
+	resetPasswordFunc            func(ctx context.Context, token, newPassword string) error
+	verifyEmailFunc              func(ctx context.Context, token string) error
+	setRolesFunc                 func(ctx context.Context, id string, roles []string) (*models.User, error)
+	setStatusFunc                func(ctx context.Context, id, status string) (*models.User, error)
+	setPasswordFunc              func(ctx context.Context, id, newPassword string) error
+	getIdempotencyKeyFunc        func(ctx context.Context, key string) (*models.IdempotencyKey, error)
+	saveIdempotencyKeyFunc       func(ctx context.Context, key, requestHash string, userID bson.ObjectID) error
+	listAuditEventsFunc          func(ctx context.Context, id string, limit, offset int) ([]*models.AuditEvent, error)
+	subscribeUserEventsFunc      func(ctx context.Context) (<-chan *models.AuditEvent, error)
+	recordFailedLoginFunc        func(ctx context.Context, id string) (*time.Time, error)
+	resetFailedLoginsFunc        func(ctx context.Context, id string) error
+	recordLoginFunc              func(ctx context.Context, id string) error
+	rehashPasswordFunc           func(ctx context.Context, user *models.User, password string) error
+	issueRefreshTokenFunc        func(ctx context.Context, id string, client models.SessionClient) (*models.IssuedRefreshToken, error)
+	rotateRefreshTokenFunc       func(ctx context.Context, token string) (*models.User, *models.IssuedRefreshToken, error)
+	revokeRefreshTokenFunc       func(ctx context.Context, token string) error
+	startTwoFactorFunc           func(ctx context.Context, id string) (*models.TwoFactorSetup, error)
+	confirmTwoFactorFunc         func(ctx context.Context, id, code string) error
+	userExistsFunc               func(ctx context.Context, id string) (bool, error)
+	userIDExistsFunc             func(ctx context.Context, userID string) (bool, error)
+	listIndexesFunc              func(ctx context.Context) ([]models.IndexInfo, error)
+	listSessionsFunc             func(ctx context.Context, id string) ([]*models.RefreshToken, error)
+	revokeSessionFunc            func(ctx context.Context, id, sessionID string) error
+	revokeOtherSessionsFunc      func(ctx context.Context, id, keepSessionID string) (int64, error)
 }
 
 // Implement UserServiceInterface
@@ -57,30 +96,284 @@ func (m *mockUserService) GetUserByEmail(ctx context.Context, email string) (*mo
 	return nil, errors.New("GetUserByEmail not implemented")
 }
 
-func (m *mockUserService) UpdateUser(ctx context.Context, id string, req *models.UpdateUserRequest) (*models.User, error) {
+// The WithPassword variants only differ in the projection, which the mock doesn't model
+func (m *mockUserService) GetUserByUserIDWithPassword(ctx context.Context, userID string) (*models.User, error) {
+	return m.GetUserByUserID(ctx, userID)
+}
+
+func (m *mockUserService) GetUserByEmailWithPassword(ctx context.Context, email string) (*models.User, error) {
+	return m.GetUserByEmail(ctx, email)
+}
+
+func (m *mockUserService) GetUserByIdentifier(ctx context.Context, identifier string) (*models.User, error) {
+	if m.getUserByIdentifierFunc != nil {
+		return m.getUserByIdentifierFunc(ctx, identifier)
+	}
+	return nil, errors.New("GetUserByIdentifier not implemented")
+}
+
+func (m *mockUserService) SearchUsers(ctx context.Context, query string) ([]*models.User, error) {
+	if m.searchUsersFunc != nil {
+		return m.searchUsersFunc(ctx, query)
+	}
+	return nil, errors.New("SearchUsers not implemented")
+}
+
+func (m *mockUserService) UpdateUser(ctx context.Context, id string, req *models.UpdateUserRequest, expectedUpdatedAt *time.Time) (*models.User, error) {
 	if m.updateUserFunc != nil {
-		return m.updateUserFunc(ctx, id, req)
+		return m.updateUserFunc(ctx, id, req, expectedUpdatedAt)
 	}
 	return nil, errors.New("UpdateUser not implemented")
 }
 
-func (m *mockUserService) DeleteUser(ctx context.Context, id string) error {
+func (m *mockUserService) ReplaceUser(ctx context.Context, id string, req *models.ReplaceUserRequest, expectedUpdatedAt *time.Time) (*models.User, error) {
+	if m.replaceUserFunc != nil {
+		return m.replaceUserFunc(ctx, id, req, expectedUpdatedAt)
+	}
+	return nil, errors.New("ReplaceUser not implemented")
+}
+
+func (m *mockUserService) ExportUsers(ctx context.Context, includeDeleted bool, fn func(*models.User) error) error {
+	if m.exportUsersFunc != nil {
+		return m.exportUsersFunc(ctx, includeDeleted, fn)
+	}
+	return errors.New("ExportUsers not implemented")
+}
+
+func (m *mockUserService) DeleteUser(ctx context.Context, id string) (*models.User, error) {
 	if m.deleteUserFunc != nil {
 		return m.deleteUserFunc(ctx, id)
 	}
-	return errors.New("DeleteUser not implemented")
+	return nil, errors.New("DeleteUser not implemented")
 }
 
-func (m *mockUserService) ListUsers(ctx context.Context) ([]*models.User, error) {
+func (m *mockUserService) DeleteAllUsers(ctx context.Context) (int64, error) {
+	if m.deleteAllUsersFunc != nil {
+		return m.deleteAllUsersFunc(ctx)
+	}
+	return 0, errors.New("DeleteAllUsers not implemented")
+}
+
+func (m *mockUserService) RestoreUser(ctx context.Context, id string) (*models.User, error) {
+	if m.restoreUserFunc != nil {
+		return m.restoreUserFunc(ctx, id)
+	}
+	return nil, errors.New("RestoreUser not implemented")
+}
+
+func (m *mockUserService) ListUsers(ctx context.Context, opts models.ListUsersOptions) ([]*models.User, error) {
 	if m.listUsersFunc != nil {
-		return m.listUsersFunc(ctx)
+		return m.listUsersFunc(ctx, opts)
 	}
 	return nil, errors.New("ListUsers not implemented")
 }
 
+func (m *mockUserService) ListInactiveUsers(ctx context.Context, cutoff time.Time, limit, offset int) ([]*models.User, error) {
+	if m.listInactiveUsersFunc != nil {
+		return m.listInactiveUsersFunc(ctx, cutoff, limit, offset)
+	}
+	return nil, errors.New("ListInactiveUsers not implemented")
+}
+
+func (m *mockUserService) Stats(ctx context.Context) (*models.UserStats, error) {
+	if m.statsFunc != nil {
+		return m.statsFunc(ctx)
+	}
+	return nil, errors.New("Stats not implemented")
+}
+
+func (m *mockUserService) CountListedUsers(ctx context.Context, opts models.ListUsersOptions) (int64, error) {
+	if m.countListedUsersFunc != nil {
+		return m.countListedUsersFunc(ctx, opts)
+	}
+	return 0, errors.New("CountListedUsers not implemented")
+}
+
+func (m *mockUserService) CountUsers(ctx context.Context, emailDomain string) (int64, error) {
+	if m.countUsersFunc != nil {
+		return m.countUsersFunc(ctx, emailDomain)
+	}
+	return 0, errors.New("CountUsers not implemented")
+}
+
+func (m *mockUserService) ListIndexes(ctx context.Context) ([]models.IndexInfo, error) {
+	if m.listIndexesFunc != nil {
+		return m.listIndexesFunc(ctx)
+	}
+	return nil, errors.New("ListIndexes not implemented")
+}
+
+func (m *mockUserService) ListSessions(ctx context.Context, id string) ([]*models.RefreshToken, error) {
+	if m.listSessionsFunc != nil {
+		return m.listSessionsFunc(ctx, id)
+	}
+	return nil, errors.New("ListSessions not implemented")
+}
+
+func (m *mockUserService) RevokeSession(ctx context.Context, id, sessionID string) error {
+	if m.revokeSessionFunc != nil {
+		return m.revokeSessionFunc(ctx, id, sessionID)
+	}
+	return errors.New("RevokeSession not implemented")
+}
+
+func (m *mockUserService) RevokeOtherSessions(ctx context.Context, id, keepSessionID string) (int64, error) {
+	if m.revokeOtherSessionsFunc != nil {
+		return m.revokeOtherSessionsFunc(ctx, id, keepSessionID)
+	}
+	return 0, errors.New("RevokeOtherSessions not implemented")
+}
+
+func (m *mockUserService) CreatePasswordResetToken(ctx context.Context, email string) (string, error) {
+	if m.createPasswordResetTokenFunc != nil {
+		return m.createPasswordResetTokenFunc(ctx, email)
+	}
+	return "", errors.New("CreatePasswordResetToken not implemented")
+}
+
+func (m *mockUserService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	if m.resetPasswordFunc != nil {
+		return m.resetPasswordFunc(ctx, token, newPassword)
+	}
+	return errors.New("ResetPassword not implemented")
+}
+
+func (m *mockUserService) VerifyEmail(ctx context.Context, token string) error {
+	if m.verifyEmailFunc != nil {
+		return m.verifyEmailFunc(ctx, token)
+	}
+	return errors.New("VerifyEmail not implemented")
+}
+
+func (m *mockUserService) SetRoles(ctx context.Context, id string, roles []string) (*models.User, error) {
+	if m.setRolesFunc != nil {
+		return m.setRolesFunc(ctx, id, roles)
+	}
+	return nil, errors.New("SetRoles not implemented")
+}
+
+func (m *mockUserService) SetStatus(ctx context.Context, id, status string) (*models.User, error) {
+	if m.setStatusFunc != nil {
+		return m.setStatusFunc(ctx, id, status)
+	}
+	return nil, errors.New("SetStatus not implemented")
+}
+
+func (m *mockUserService) SetPassword(ctx context.Context, id, newPassword string) error {
+	if m.setPasswordFunc != nil {
+		return m.setPasswordFunc(ctx, id, newPassword)
+	}
+	return errors.New("SetPassword not implemented")
+}
+
+func (m *mockUserService) GetIdempotencyKey(ctx context.Context, key string) (*models.IdempotencyKey, error) {
+	if m.getIdempotencyKeyFunc != nil {
+		return m.getIdempotencyKeyFunc(ctx, key)
+	}
+	return nil, errors.New("GetIdempotencyKey not implemented")
+}
+
+func (m *mockUserService) SaveIdempotencyKey(ctx context.Context, key, requestHash string, userID bson.ObjectID) error {
+	if m.saveIdempotencyKeyFunc != nil {
+		return m.saveIdempotencyKeyFunc(ctx, key, requestHash, userID)
+	}
+	return errors.New("SaveIdempotencyKey not implemented")
+}
+
+func (m *mockUserService) ListAuditEvents(ctx context.Context, id string, limit, offset int) ([]*models.AuditEvent, error) {
+	if m.listAuditEventsFunc != nil {
+		return m.listAuditEventsFunc(ctx, id, limit, offset)
+	}
+	return nil, errors.New("ListAuditEvents not implemented")
+}
+
+func (m *mockUserService) SubscribeUserEvents(ctx context.Context) (<-chan *models.AuditEvent, error) {
+	if m.subscribeUserEventsFunc != nil {
+		return m.subscribeUserEventsFunc(ctx)
+	}
+	return nil, errors.New("SubscribeUserEvents not implemented")
+}
+
+func (m *mockUserService) RecordFailedLogin(ctx context.Context, id string) (*time.Time, error) {
+	if m.recordFailedLoginFunc != nil {
+		return m.recordFailedLoginFunc(ctx, id)
+	}
+	return nil, errors.New("RecordFailedLogin not implemented")
+}
+
+func (m *mockUserService) ResetFailedLogins(ctx context.Context, id string) error {
+	if m.resetFailedLoginsFunc != nil {
+		return m.resetFailedLoginsFunc(ctx, id)
+	}
+	return errors.New("ResetFailedLogins not implemented")
+}
+
+func (m *mockUserService) RecordLogin(ctx context.Context, id string) error {
+	if m.recordLoginFunc != nil {
+		return m.recordLoginFunc(ctx, id)
+	}
+	return errors.New("RecordLogin not implemented")
+}
+
+func (m *mockUserService) RehashPassword(ctx context.Context, user *models.User, password string) error {
+	if m.rehashPasswordFunc != nil {
+		return m.rehashPasswordFunc(ctx, user, password)
+	}
+	return errors.New("RehashPassword not implemented")
+}
+
+func (m *mockUserService) IssueRefreshToken(ctx context.Context, id string, client models.SessionClient) (*models.IssuedRefreshToken, error) {
+	if m.issueRefreshTokenFunc != nil {
+		return m.issueRefreshTokenFunc(ctx, id, client)
+	}
+	return nil, errors.New("IssueRefreshToken not implemented")
+}
+
+func (m *mockUserService) RotateRefreshToken(ctx context.Context, token string) (*models.User, *models.IssuedRefreshToken, error) {
+	if m.rotateRefreshTokenFunc != nil {
+		return m.rotateRefreshTokenFunc(ctx, token)
+	}
+	return nil, nil, errors.New("RotateRefreshToken not implemented")
+}
+
+func (m *mockUserService) RevokeRefreshToken(ctx context.Context, token string) error {
+	if m.revokeRefreshTokenFunc != nil {
+		return m.revokeRefreshTokenFunc(ctx, token)
+	}
+	return errors.New("RevokeRefreshToken not implemented")
+}
+
+func (m *mockUserService) StartTwoFactor(ctx context.Context, id string) (*models.TwoFactorSetup, error) {
+	if m.startTwoFactorFunc != nil {
+		return m.startTwoFactorFunc(ctx, id)
+	}
+	return nil, errors.New("StartTwoFactor not implemented")
+}
+
+func (m *mockUserService) ConfirmTwoFactor(ctx context.Context, id, code string) error {
+	if m.confirmTwoFactorFunc != nil {
+		return m.confirmTwoFactorFunc(ctx, id, code)
+	}
+	return errors.New("ConfirmTwoFactor not implemented")
+}
+
+func (m *mockUserService) UserExists(ctx context.Context, id string) (bool, error) {
+	if m.userExistsFunc != nil {
+		return m.userExistsFunc(ctx, id)
+	}
+	return false, errors.New("UserExists not implemented")
+}
+
+func (m *mockUserService) UserIDExists(ctx context.Context, userID string) (bool, error) {
+	if m.userIDExistsFunc != nil {
+		return m.userIDExistsFunc(ctx, userID)
+	}
+	return false, errors.New("UserIDExists not implemented")
+}
+
 func TestNewUserHandler(t *testing.T) {
 	mockService := &mockUserService{}
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 
 	if handler == nil {
 		t.Fatal("Expected NewUserHandler to return a non-nil UserHandler")
@@ -106,10 +399,11 @@ func TestUserHandler_CreateUser_Success(t *testing.T) {
 	}
 
 	// Create handler with mock service
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 
 	// Create Echo instance
 	e := echo.New()
+	e.Validator = NewValidator()
 
 	// Create request
 	reqBody := `{"user_id":"test123","email":"test@example.com","password":"password123"}`
@@ -128,10 +422,102 @@ func TestUserHandler_CreateUser_Success(t *testing.T) {
 	}
 }
 
+func TestUserHandler_CreateUser_SendsVerification(t *testing.T) {
+	mockService := &mockUserService{
+		createUserFunc: func(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
+			return &models.User{
+				ID:                bson.NewObjectID(),
+				UserID:            req.UserID,
+				Email:             req.Email,
+				VerificationToken: "verify-token",
+			}, nil
+		},
+	}
+	m := &mockMailer{}
+	handler := NewUserHandler(mockService, m)
+	e := echo.New()
+	e.Validator = NewValidator()
+
+	reqBody := `{"user_id":"test123","email":"test@example.com","password":"password123"}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(reqBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := handler.CreateUser(c); err != nil {
+		t.Fatalf("Expected no error from handler, got %v", err)
+	}
+
+	if len(m.verificationTokens) != 1 || m.verificationTokens[0] != "verify-token" {
+		t.Errorf("Expected verification token to be sent once, got %v", m.verificationTokens)
+	}
+	if len(m.verificationEmails) != 1 || m.verificationEmails[0] != "test@example.com" {
+		t.Errorf("Expected verification email to test@example.com, got %v", m.verificationEmails)
+	}
+
+	if strings.Contains(rec.Body.String(), "verify-token") {
+		t.Error("Expected response not to contain the verification token")
+	}
+}
+
+func TestUserHandler_CreateUser_LocationHeader(t *testing.T) {
+	userID := bson.NewObjectID()
+	mockService := &mockUserService{
+		createUserFunc: func(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
+			return &models.User{ID: userID, UserID: req.UserID, Email: req.Email}, nil
+		},
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{"Default prefix", "/api/v1/users", "/api/v1/users/" + userID.Hex()},
+		{"Trailing slash", "/api/v1/users/", "/api/v1/users/" + userID.Hex()},
+		{"Custom prefix", "/internal/users", "/internal/users/" + userID.Hex()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewUserHandler(mockService, &mockMailer{})
+			e := echo.New()
+			e.Validator = NewValidator()
+
+			reqBody := `{"user_id":"test123","email":"test@example.com","password":"password123"}`
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(reqBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if err := handler.CreateUser(c); err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("Expected status %d, got %d", http.StatusCreated, rec.Code)
+			}
+
+			if got := rec.Header().Get(echo.HeaderLocation); got != tt.expected {
+				t.Errorf("Expected Location '%s', got '%s'", tt.expected, got)
+			}
+
+			var user models.User
+			if err := json.Unmarshal(rec.Body.Bytes(), &user); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if user.ID != userID {
+				t.Errorf("Expected body id %s to match Location, got %s", userID.Hex(), user.ID.Hex())
+			}
+		})
+	}
+}
+
 func TestUserHandler_CreateUser_MissingFields(t *testing.T) {
 	mockService := &mockUserService{}
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 	e := echo.New()
+	e.Validator = NewValidator()
 
 	tests := []struct {
 		name string
@@ -183,11 +569,12 @@ func TestUserHandler_CreateUser_MissingFields(t *testing.T) {
 func TestUserHandler_CreateUser_ServiceError(t *testing.T) {
 	mockService := &mockUserService{
 		createUserFunc: func(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
-			return nil, errors.New("user with this user_id already exists")
+			return nil, services.ErrUserExists
 		},
 	}
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 	e := echo.New()
+	e.Validator = NewValidator()
 
 	reqBody := `{"user_id":"test123","email":"test@example.com","password":"password123"}`
 	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(reqBody))
@@ -205,10 +592,53 @@ func TestUserHandler_CreateUser_ServiceError(t *testing.T) {
 	}
 }
 
+func TestUserHandler_CreateUser_ErrorStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		statusCode int
+	}{
+		{"Duplicate user_id", services.ErrUserExists, http.StatusConflict},
+		{"Duplicate email", services.ErrEmailExists, http.StatusConflict},
+		{"Wrapped duplicate", fmt.Errorf("insert: %w", services.ErrEmailExists), http.StatusConflict},
+		{"Malformed email", models.ErrInvalidEmail, http.StatusBadRequest},
+		{"Internal failure", errors.New("failed to hash password: boom"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &mockUserService{
+				createUserFunc: func(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
+					return nil, tt.err
+				},
+			}
+			handler := NewUserHandler(mockService, &mockMailer{})
+			e := echo.New()
+			e.Validator = NewValidator()
+
+			reqBody := `{"user_id":"test123","email":"test@example.com","password":"password123"}`
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(reqBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			err := handler.CreateUser(c)
+			if err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != tt.statusCode {
+				t.Errorf("Expected status %d, got %d", tt.statusCode, rec.Code)
+			}
+		})
+	}
+}
+
 func TestUserHandler_CreateUser_InvalidJSON(t *testing.T) {
 	mockService := &mockUserService{}
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 	e := echo.New()
+	e.Validator = NewValidator()
 
 	reqBody := `{"user_id":"test123",email:"test@example.com","password":"password123"}` // Invalid JSON
 	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(reqBody))
@@ -240,7 +670,7 @@ func TestUserHandler_GetUser_Success(t *testing.T) {
 		},
 	}
 
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 	e := echo.New()
 
 	req := httptest.NewRequest(http.MethodGet, "/users/"+userID.Hex(), nil)
@@ -259,9 +689,75 @@ func TestUserHandler_GetUser_Success(t *testing.T) {
 	}
 }
 
+func TestUserHandler_GetUser_IncludeAge(t *testing.T) {
+	userID := bson.NewObjectID()
+	mockService := &mockUserService{
+		getUserByIDFunc: func(ctx context.Context, id string) (*models.User, error) {
+			return &models.User{
+				ID:        userID,
+				UserID:    "testuser",
+				CreatedAt: time.Now().Add(-2 * time.Hour),
+			}, nil
+		},
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectAge      bool
+	}{
+		{"Requested", "?include_age=true", http.StatusOK, true},
+		{"Not requested", "", http.StatusOK, false},
+		{"Invalid", "?include_age=maybe", http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewUserHandler(mockService, &mockMailer{})
+			e := echo.New()
+
+			req := httptest.NewRequest(http.MethodGet, "/users/"+userID.Hex()+tt.query, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues(userID.Hex())
+
+			if err := handler.GetUser(c); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+
+			var response map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			age, ok := response["age"].(float64)
+			if ok != tt.expectAge {
+				t.Fatalf("Expected age present: %v, got %v", tt.expectAge, response)
+			}
+			if tt.expectAge && (age < 7200 || age > 7260 || response["user_id"] != "testuser") {
+				t.Errorf("Expected the user with an age of about 7200 seconds, got %v", response)
+			}
+		})
+	}
+}
+
+func TestNewUserWithAge_ClockSkew(t *testing.T) {
+	now := time.Now()
+	user := &models.User{CreatedAt: now.Add(time.Minute)}
+
+	if age := newUserWithAge(user, now).Age; age != 0 {
+		t.Errorf("Expected a future created_at to give age 0, got %d", age)
+	}
+}
+
 func TestUserHandler_GetUser_MissingID(t *testing.T) {
 	mockService := &mockUserService{}
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 	e := echo.New()
 
 	req := httptest.NewRequest(http.MethodGet, "/users/", nil)
@@ -281,11 +777,11 @@ func TestUserHandler_GetUser_MissingID(t *testing.T) {
 func TestUserHandler_GetUser_NotFound(t *testing.T) {
 	mockService := &mockUserService{
 		getUserByIDFunc: func(ctx context.Context, id string) (*models.User, error) {
-			return nil, errors.New("user not found")
+			return nil, services.ErrUserNotFound
 		},
 	}
 
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 	e := echo.New()
 
 	userID := bson.NewObjectID()
@@ -312,7 +808,7 @@ func TestUserHandler_GetUser_ServerError(t *testing.T) {
 		},
 	}
 
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 	e := echo.New()
 
 	userID := bson.NewObjectID()
@@ -332,6 +828,75 @@ func TestUserHandler_GetUser_ServerError(t *testing.T) {
 	}
 }
 
+func TestUserHandler_GetMe(t *testing.T) {
+	setTestSecret(t)
+	userID := bson.NewObjectID()
+	token, err := auth.GenerateToken(&models.User{ID: userID, UserID: "testuser"})
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		lookup     func(ctx context.Context, id string) (*models.User, error)
+		statusCode int
+	}{
+		{"Authenticated", token, func(ctx context.Context, id string) (*models.User, error) {
+			if id != userID.Hex() {
+				t.Errorf("Expected lookup of '%s', got '%s'", userID.Hex(), id)
+			}
+			return &models.User{ID: userID, UserID: "testuser"}, nil
+		}, http.StatusOK},
+		{"Missing token", "", nil, http.StatusUnauthorized},
+		{"Deleted account", token, func(ctx context.Context, id string) (*models.User, error) {
+			return nil, services.ErrUserNotFound
+		}, http.StatusUnauthorized},
+		{"Server error", token, func(ctx context.Context, id string) (*models.User, error) {
+			return nil, errors.New("database error")
+		}, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewUserHandler(&mockUserService{getUserByIDFunc: tt.lookup}, &mockMailer{})
+			e := echo.New()
+			e.GET("/users/me", handler.GetMe, auth.Authenticate())
+
+			req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+			if tt.token != "" {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.statusCode {
+				t.Errorf("Expected status %d, got %d", tt.statusCode, rec.Code)
+			}
+			if tt.statusCode == http.StatusOK && rec.Header().Get("ETag") == "" {
+				t.Error("Expected an ETag header")
+			}
+		})
+	}
+}
+
+func TestUserHandler_GetMe_WithoutAuthenticate(t *testing.T) {
+	handler := NewUserHandler(&mockUserService{}, &mockMailer{})
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := handler.GetMe(c); err != nil {
+		t.Fatalf("Expected no error from handler, got %v", err)
+	}
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
+
 func TestUserHandler_GetUserByUserID_Success(t *testing.T) {
 	mockService := &mockUserService{
 		getUserByUserIDFunc: func(ctx context.Context, userID string) (*models.User, error) {
@@ -345,7 +910,7 @@ func TestUserHandler_GetUserByUserID_Success(t *testing.T) {
 		},
 	}
 
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 	e := echo.New()
 
 	req := httptest.NewRequest(http.MethodGet, "/users/search?user_id=testuser", nil)
@@ -366,7 +931,7 @@ func TestUserHandler_GetUserByUserID_Success(t *testing.T) {
 
 func TestUserHandler_GetUserByUserID_MissingQuery(t *testing.T) {
 	mockService := &mockUserService{}
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 	e := echo.New()
 
 	req := httptest.NewRequest(http.MethodGet, "/users/search", nil)
@@ -390,7 +955,7 @@ func TestUserHandler_GetUserByUserID_NotFound(t *testing.T) {
 		},
 	}
 
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 	e := echo.New()
 
 	req := httptest.NewRequest(http.MethodGet, "/users/search?user_id=nonexistent", nil)
@@ -416,7 +981,7 @@ func TestUserHandler_GetUserByUserID_ServerError(t *testing.T) {
 		},
 	}
 
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 	e := echo.New()
 
 	req := httptest.NewRequest(http.MethodGet, "/users/search?user_id=testuser", nil)
@@ -448,7 +1013,7 @@ func TestUserHandler_GetUserByEmail_Success(t *testing.T) {
 		},
 	}
 
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 	e := echo.New()
 
 	req := httptest.NewRequest(http.MethodGet, "/users/search?email=test@example.com", nil)
@@ -469,7 +1034,7 @@ func TestUserHandler_GetUserByEmail_Success(t *testing.T) {
 
 func TestUserHandler_GetUserByEmail_MissingQuery(t *testing.T) {
 	mockService := &mockUserService{}
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 	e := echo.New()
 
 	req := httptest.NewRequest(http.MethodGet, "/users/search", nil)
@@ -493,7 +1058,7 @@ func TestUserHandler_GetUserByEmail_NotFound(t *testing.T) {
 		},
 	}
 
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 	e := echo.New()
 
 	req := httptest.NewRequest(http.MethodGet, "/users/search?email=nonexistent@example.com", nil)
@@ -519,7 +1084,7 @@ func TestUserHandler_GetUserByEmail_ServerError(t *testing.T) {
 		},
 	}
 
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 	e := echo.New()
 
 	req := httptest.NewRequest(http.MethodGet, "/users/search?email=test@example.com", nil)
@@ -557,12 +1122,12 @@ func TestUserHandler_ListUsers_Success(t *testing.T) {
 	}
 
 	mockService := &mockUserService{
-		listUsersFunc: func(ctx context.Context) ([]*models.User, error) {
+		listUsersFunc: func(ctx context.Context, opts models.ListUsersOptions) ([]*models.User, error) {
 			return users, nil
 		},
 	}
 
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 	e := echo.New()
 
 	req := httptest.NewRequest(http.MethodGet, "/users", nil)
@@ -590,12 +1155,12 @@ func TestUserHandler_ListUsers_Success(t *testing.T) {
 
 func TestUserHandler_ListUsers_ServerError(t *testing.T) {
 	mockService := &mockUserService{
-		listUsersFunc: func(ctx context.Context) ([]*models.User, error) {
+		listUsersFunc: func(ctx context.Context, opts models.ListUsersOptions) ([]*models.User, error) {
 			return nil, errors.New("database error")
 		},
 	}
 
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 	e := echo.New()
 
 	req := httptest.NewRequest(http.MethodGet, "/users", nil)
@@ -614,12 +1179,13 @@ func TestUserHandler_ListUsers_ServerError(t *testing.T) {
 
 func TestUserHandler_DeleteUser_Success(t *testing.T) {
 	mockService := &mockUserService{
-		deleteUserFunc: func(ctx context.Context, id string) error {
-			return nil
+		deleteUserFunc: func(ctx context.Context, id string) (*models.User, error) {
+			objectID, _ := bson.ObjectIDFromHex(id)
+			return &models.User{ID: objectID, UserID: "alice", Email: "alice@example.com"}, nil
 		},
 	}
 
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 	e := echo.New()
 
 	userID := bson.NewObjectID()
@@ -639,11 +1205,126 @@ func TestUserHandler_DeleteUser_Success(t *testing.T) {
 	}
 }
 
-func TestUserHandler_DeleteUser_MissingID(t *testing.T) {
-	mockService := &mockUserService{}
-	handler := NewUserHandler(mockService)
-	e := echo.New()
-
+func TestUserHandler_DeleteAllUsers(t *testing.T) {
+	tests := []struct {
+		name           string
+		deleteAllFunc  func(ctx context.Context) (int64, error)
+		expectedStatus int
+		expectedCount  int64
+	}{
+		{
+			name: "Success",
+			deleteAllFunc: func(ctx context.Context) (int64, error) {
+				return 3, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedCount:  3,
+		},
+		{
+			name: "Server error",
+			deleteAllFunc: func(ctx context.Context) (int64, error) {
+				return 0, errors.New("database error")
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewUserHandler(&mockUserService{deleteAllUsersFunc: tt.deleteAllFunc}, &mockMailer{})
+			e := echo.New()
+
+			req := httptest.NewRequest(http.MethodDelete, "/users", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if err := handler.DeleteAllUsers(c); err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+
+			if tt.expectedStatus == http.StatusOK {
+				var response DeleteAllResponse
+				if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+					t.Fatalf("Failed to unmarshal response: %v", err)
+				}
+				if response.DeletedCount != tt.expectedCount {
+					t.Errorf("Expected deleted_count %d, got %d", tt.expectedCount, response.DeletedCount)
+				}
+			}
+		})
+	}
+}
+
+func TestUserHandler_InvalidUserID(t *testing.T) {
+	invalidID := fmt.Errorf("%w: the provided hex string is not a valid ObjectID", services.ErrInvalidUserID)
+	mockService := &mockUserService{
+		getUserByIDFunc: func(ctx context.Context, id string) (*models.User, error) {
+			return nil, invalidID
+		},
+		updateUserFunc: func(ctx context.Context, id string, req *models.UpdateUserRequest, expectedUpdatedAt *time.Time) (*models.User, error) {
+			return nil, invalidID
+		},
+		replaceUserFunc: func(ctx context.Context, id string, req *models.ReplaceUserRequest, expectedUpdatedAt *time.Time) (*models.User, error) {
+			return nil, invalidID
+		},
+		deleteUserFunc: func(ctx context.Context, id string) (*models.User, error) {
+			return nil, invalidID
+		},
+		restoreUserFunc: func(ctx context.Context, id string) (*models.User, error) {
+			return nil, invalidID
+		},
+		setRolesFunc: func(ctx context.Context, id string, roles []string) (*models.User, error) {
+			return nil, invalidID
+		},
+	}
+	handler := NewUserHandler(mockService, &mockMailer{})
+
+	tests := []struct {
+		name    string
+		method  string
+		body    string
+		handler echo.HandlerFunc
+	}{
+		{"GetUser", http.MethodGet, "", handler.GetUser},
+		{"UpdateUser", http.MethodPatch, `{"user_id":"renamed"}`, handler.UpdateUser},
+		{"ReplaceUser", http.MethodPut, `{"user_id":"alice","email":"alice@example.com","password":"password123"}`, handler.ReplaceUser},
+		{"DeleteUser", http.MethodDelete, "", handler.DeleteUser},
+		{"RestoreUser", http.MethodPost, "", handler.RestoreUser},
+		{"SetUserRoles", http.MethodPut, `{"roles":["user"]}`, handler.SetUserRoles},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.Validator = NewValidator()
+
+			req := httptest.NewRequest(tt.method, "/users/not-an-id", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("not-an-id")
+
+			if err := tt.handler(c); err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+			}
+		})
+	}
+}
+
+func TestUserHandler_DeleteUser_MissingID(t *testing.T) {
+	mockService := &mockUserService{}
+	handler := NewUserHandler(mockService, &mockMailer{})
+	e := echo.New()
+
 	req := httptest.NewRequest(http.MethodDelete, "/users/", nil)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
@@ -658,14 +1339,71 @@ func TestUserHandler_DeleteUser_MissingID(t *testing.T) {
 	}
 }
 
+func TestUserHandler_DeleteUser_ReturnRepresentation(t *testing.T) {
+	mockService := &mockUserService{
+		deleteUserFunc: func(ctx context.Context, id string) (*models.User, error) {
+			objectID, _ := bson.ObjectIDFromHex(id)
+			return &models.User{ID: objectID, UserID: "alice", Email: "alice@example.com", Password: "hash"}, nil
+		},
+	}
+
+	handler := NewUserHandler(mockService, &mockMailer{})
+	e := echo.New()
+
+	userID := bson.NewObjectID()
+	req := httptest.NewRequest(http.MethodDelete, "/users/"+userID.Hex(), nil)
+	req.Header.Set("Prefer", "return=representation")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(userID.Hex())
+
+	if err := handler.DeleteUser(c); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var deleted map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &deleted); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if rec.Code != http.StatusOK || deleted["id"] != userID.Hex() || deleted["user_id"] != "alice" {
+		t.Errorf("Expected the deleted user, got %d %v", rec.Code, deleted)
+	}
+	if _, ok := deleted["password"]; ok {
+		t.Error("Expected password to be omitted")
+	}
+}
+
+func TestPrefersRepresentation(t *testing.T) {
+	tests := []struct {
+		prefer   string
+		expected bool
+	}{
+		{"", false},
+		{"return=minimal", false},
+		{"return=representation", true},
+		{"respond-async, Return = Representation", true},
+		{"return=representation; charset=utf-8", true},
+		{"return=representations", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefer, func(t *testing.T) {
+			if got := prefersRepresentation(tt.prefer); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
 func TestUserHandler_DeleteUser_NotFound(t *testing.T) {
 	mockService := &mockUserService{
-		deleteUserFunc: func(ctx context.Context, id string) error {
-			return errors.New("user not found")
+		deleteUserFunc: func(ctx context.Context, id string) (*models.User, error) {
+			return nil, services.ErrUserNotFound
 		},
 	}
 
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 	e := echo.New()
 
 	userID := bson.NewObjectID()
@@ -687,12 +1425,12 @@ func TestUserHandler_DeleteUser_NotFound(t *testing.T) {
 
 func TestUserHandler_DeleteUser_ServerError(t *testing.T) {
 	mockService := &mockUserService{
-		deleteUserFunc: func(ctx context.Context, id string) error {
-			return errors.New("database error")
+		deleteUserFunc: func(ctx context.Context, id string) (*models.User, error) {
+			return nil, errors.New("database error")
 		},
 	}
 
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 	e := echo.New()
 
 	userID := bson.NewObjectID()
@@ -723,13 +1461,14 @@ func TestUserHandler_UpdateUser_Success(t *testing.T) {
 	}
 
 	mockService := &mockUserService{
-		updateUserFunc: func(ctx context.Context, id string, req *models.UpdateUserRequest) (*models.User, error) {
+		updateUserFunc: func(ctx context.Context, id string, req *models.UpdateUserRequest, expectedUpdatedAt *time.Time) (*models.User, error) {
 			return updatedUser, nil
 		},
 	}
 
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 	e := echo.New()
+	e.Validator = NewValidator()
 
 	reqBody := `{"user_id":"updateduser","email":"updated@example.com"}`
 	req := httptest.NewRequest(http.MethodPut, "/users/"+userID.Hex(), strings.NewReader(reqBody))
@@ -751,8 +1490,9 @@ func TestUserHandler_UpdateUser_Success(t *testing.T) {
 
 func TestUserHandler_UpdateUser_MissingID(t *testing.T) {
 	mockService := &mockUserService{}
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 	e := echo.New()
+	e.Validator = NewValidator()
 
 	reqBody := `{"user_id":"updateduser","email":"updated@example.com"}`
 	req := httptest.NewRequest(http.MethodPut, "/users/", strings.NewReader(reqBody))
@@ -773,8 +1513,9 @@ func TestUserHandler_UpdateUser_MissingID(t *testing.T) {
 func TestUserHandler_UpdateUser_InvalidJSON(t *testing.T) {
 	userID := bson.NewObjectID()
 	mockService := &mockUserService{}
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 	e := echo.New()
+	e.Validator = NewValidator()
 
 	reqBody := `{"user_id":updateduser,"email":"updated@example.com"}` // Invalid JSON
 	req := httptest.NewRequest(http.MethodPut, "/users/"+userID.Hex(), strings.NewReader(reqBody))
@@ -797,13 +1538,14 @@ func TestUserHandler_UpdateUser_InvalidJSON(t *testing.T) {
 func TestUserHandler_UpdateUser_NotFound(t *testing.T) {
 	userID := bson.NewObjectID()
 	mockService := &mockUserService{
-		updateUserFunc: func(ctx context.Context, id string, req *models.UpdateUserRequest) (*models.User, error) {
-			return nil, errors.New("user not found")
+		updateUserFunc: func(ctx context.Context, id string, req *models.UpdateUserRequest, expectedUpdatedAt *time.Time) (*models.User, error) {
+			return nil, services.ErrUserNotFound
 		},
 	}
 
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 	e := echo.New()
+	e.Validator = NewValidator()
 
 	reqBody := `{"user_id":"updateduser","email":"updated@example.com"}`
 	req := httptest.NewRequest(http.MethodPut, "/users/"+userID.Hex(), strings.NewReader(reqBody))
@@ -826,13 +1568,14 @@ func TestUserHandler_UpdateUser_NotFound(t *testing.T) {
 func TestUserHandler_UpdateUser_ConflictError(t *testing.T) {
 	userID := bson.NewObjectID()
 	mockService := &mockUserService{
-		updateUserFunc: func(ctx context.Context, id string, req *models.UpdateUserRequest) (*models.User, error) {
-			return nil, errors.New("user with this email already exists")
+		updateUserFunc: func(ctx context.Context, id string, req *models.UpdateUserRequest, expectedUpdatedAt *time.Time) (*models.User, error) {
+			return nil, services.ErrEmailExists
 		},
 	}
 
-	handler := NewUserHandler(mockService)
+	handler := NewUserHandler(mockService, &mockMailer{})
 	e := echo.New()
+	e.Validator = NewValidator()
 
 	reqBody := `{"email":"existing@example.com"}`
 	req := httptest.NewRequest(http.MethodPut, "/users/"+userID.Hex(), strings.NewReader(reqBody))
@@ -850,4 +1593,829 @@ func TestUserHandler_UpdateUser_ConflictError(t *testing.T) {
 	if rec.Code != http.StatusConflict {
 		t.Errorf("Expected status %d, got %d", http.StatusConflict, rec.Code)
 	}
-}
\ No newline at end of file
+}
+func TestUserHandler_ListUsers_IncludeDeleted(t *testing.T) {
+	var gotOpts models.ListUsersOptions
+	mockService := &mockUserService{
+		listUsersFunc: func(ctx context.Context, opts models.ListUsersOptions) ([]*models.User, error) {
+			gotOpts = opts
+			return []*models.User{}, nil
+		},
+	}
+
+	handler := NewUserHandler(mockService, &mockMailer{})
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/users?include_deleted=true", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := handler.ListUsers(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	if !gotOpts.IncludeDeleted {
+		t.Error("Expected IncludeDeleted to be passed to the service")
+	}
+}
+
+func TestUserHandler_ListUsers_InvalidIncludeDeleted(t *testing.T) {
+	handler := NewUserHandler(&mockUserService{}, &mockMailer{})
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/users?include_deleted=maybe", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := handler.ListUsers(c)
+	if err != nil {
+		t.Fatalf("Expected no error from handler, got %v", err)
+	}
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestUserHandler_RestoreUser_Success(t *testing.T) {
+	userID := bson.NewObjectID()
+	mockService := &mockUserService{
+		restoreUserFunc: func(ctx context.Context, id string) (*models.User, error) {
+			return &models.User{
+				ID:        userID,
+				UserID:    "testuser",
+				Email:     "test@example.com",
+				CreatedAt: time.Now(),
+				UpdatedAt: time.Now(),
+			}, nil
+		},
+	}
+
+	handler := NewUserHandler(mockService, &mockMailer{})
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodPost, "/users/"+userID.Hex()+"/restore", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(userID.Hex())
+
+	err := handler.RestoreUser(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestUserHandler_RestoreUser_NotFound(t *testing.T) {
+	mockService := &mockUserService{
+		restoreUserFunc: func(ctx context.Context, id string) (*models.User, error) {
+			return nil, services.ErrUserNotFound
+		},
+	}
+
+	handler := NewUserHandler(mockService, &mockMailer{})
+	e := echo.New()
+
+	userID := bson.NewObjectID()
+	req := httptest.NewRequest(http.MethodPost, "/users/"+userID.Hex()+"/restore", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(userID.Hex())
+
+	err := handler.RestoreUser(c)
+	if err != nil {
+		t.Fatalf("Expected no error from handler, got %v", err)
+	}
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestUserHandler_ListUsers_Sort(t *testing.T) {
+	var gotOpts models.ListUsersOptions
+	mockService := &mockUserService{
+		listUsersFunc: func(ctx context.Context, opts models.ListUsersOptions) ([]*models.User, error) {
+			gotOpts = opts
+			return []*models.User{}, nil
+		},
+	}
+
+	handler := NewUserHandler(mockService, &mockMailer{})
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/users?sort=-email", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := handler.ListUsers(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	if gotOpts.SortField != "email" || !gotOpts.SortDescending {
+		t.Errorf("Expected sort by email descending, got %s (descending: %v)", gotOpts.SortField, gotOpts.SortDescending)
+	}
+}
+
+func TestUserHandler_ListUsers_InvalidSort(t *testing.T) {
+	handler := NewUserHandler(&mockUserService{}, &mockMailer{})
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/users?sort=password", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := handler.ListUsers(c)
+	if err != nil {
+		t.Fatalf("Expected no error from handler, got %v", err)
+	}
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestUserHandler_ListUsers_CreatedRange(t *testing.T) {
+	var gotOpts models.ListUsersOptions
+	mockService := &mockUserService{
+		listUsersFunc: func(ctx context.Context, opts models.ListUsersOptions) ([]*models.User, error) {
+			gotOpts = opts
+			return []*models.User{}, nil
+		},
+	}
+
+	handler := NewUserHandler(mockService, &mockMailer{})
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/users?created_after=2024-01-01T00:00:00Z&created_before=2024-06-30T12:00:00%2B09:00&sort=email", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := handler.ListUsers(c); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2024, 6, 30, 3, 0, 0, 0, time.UTC)
+	if gotOpts.CreatedAfter == nil || !gotOpts.CreatedAfter.Equal(after) {
+		t.Errorf("Expected created_after %v, got %v", after, gotOpts.CreatedAfter)
+	}
+	if gotOpts.CreatedBefore == nil || !gotOpts.CreatedBefore.Equal(before) {
+		t.Errorf("Expected created_before %v, got %v", before, gotOpts.CreatedBefore)
+	}
+	if gotOpts.SortField != "email" {
+		t.Errorf("Expected sort by email, got %s", gotOpts.SortField)
+	}
+}
+
+func TestUserHandler_ListUsers_InvalidCreatedRange(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"Invalid created_after", "created_after=yesterday"},
+		{"Date without time", "created_before=2024-01-01"},
+		{"Inverted range", "created_after=2024-06-01T00:00:00Z&created_before=2024-01-01T00:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewUserHandler(&mockUserService{}, &mockMailer{})
+			e := echo.New()
+
+			req := httptest.NewRequest(http.MethodGet, "/users?"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if err := handler.ListUsers(c); err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+			}
+		})
+	}
+}
+
+func TestUserHandler_ListUsers_UserIDs(t *testing.T) {
+	var gotOpts models.ListUsersOptions
+	mockService := &mockUserService{
+		listUsersFunc: func(ctx context.Context, opts models.ListUsersOptions) ([]*models.User, error) {
+			gotOpts = opts
+			return []*models.User{}, nil
+		},
+	}
+
+	handler := NewUserHandler(mockService, &mockMailer{})
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/users?user_ids=alice,,%20bob%20,", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := handler.ListUsers(c); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	if !reflect.DeepEqual(gotOpts.UserIDs, []string{"alice", "bob"}) {
+		t.Errorf("Expected user_ids [alice bob], got %v", gotOpts.UserIDs)
+	}
+}
+
+func TestUserHandler_ListUsers_EmailDomain(t *testing.T) {
+	var gotOpts models.ListUsersOptions
+	mockService := &mockUserService{
+		listUsersFunc: func(ctx context.Context, opts models.ListUsersOptions) ([]*models.User, error) {
+			gotOpts = opts
+			return []*models.User{}, nil
+		},
+	}
+
+	handler := NewUserHandler(mockService, &mockMailer{})
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/users?email_domain=example.com&sort=email", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := handler.ListUsers(c); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	if gotOpts.EmailDomain != "example.com" || gotOpts.SortField != "email" {
+		t.Errorf("Expected email_domain 'example.com' sorted by email, got %+v", gotOpts)
+	}
+}
+
+func TestUserHandler_ListUsers_Page(t *testing.T) {
+	var listedOpts, countedOpts models.ListUsersOptions
+	mockService := &mockUserService{
+		listUsersFunc: func(ctx context.Context, opts models.ListUsersOptions) ([]*models.User, error) {
+			listedOpts = opts
+			return []*models.User{{ID: bson.NewObjectID(), UserID: "alice"}, {ID: bson.NewObjectID(), UserID: "bob"}}, nil
+		},
+		countListedUsersFunc: func(ctx context.Context, opts models.ListUsersOptions) (int64, error) {
+			countedOpts = opts
+			return 7, nil
+		},
+	}
+
+	handler := NewUserHandler(mockService, &mockMailer{})
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users?email_domain=example.com&limit=2&offset=2", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := handler.ListUsers(c); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	if listedOpts.Limit != 2 || listedOpts.Offset != 2 || listedOpts.EmailDomain != "example.com" || countedOpts.EmailDomain != "example.com" {
+		t.Errorf("Expected the second page of example.com users, listed %+v and counted %+v", listedOpts, countedOpts)
+	}
+
+	// Other query parameters are kept, and the final page starts at the last multiple of limit
+	expected := `</api/v1/users?email_domain=example.com&limit=2&offset=0>; rel="first", ` +
+		`</api/v1/users?email_domain=example.com&limit=2&offset=0>; rel="prev", ` +
+		`</api/v1/users?email_domain=example.com&limit=2&offset=4>; rel="next", ` +
+		`</api/v1/users?email_domain=example.com&limit=2&offset=6>; rel="last"`
+	if link := rec.Header().Get(HeaderLink); link != expected {
+		t.Errorf("Expected Link header\n%s\ngot\n%s", expected, link)
+	}
+
+	var response UserPageResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.Count != 2 || response.Total != 7 || response.Limit != 2 || response.Offset != 2 || !response.HasMore {
+		t.Errorf("Expected 2 of 7 users with more to come, got %+v", response)
+	}
+}
+
+func TestUserHandler_ListUsers_PageEnds(t *testing.T) {
+	mockService := &mockUserService{
+		listUsersFunc: func(ctx context.Context, opts models.ListUsersOptions) ([]*models.User, error) {
+			return []*models.User{{ID: bson.NewObjectID(), UserID: "alice"}}, nil
+		},
+		countListedUsersFunc: func(ctx context.Context, opts models.ListUsersOptions) (int64, error) {
+			return 1, nil
+		},
+	}
+
+	handler := NewUserHandler(mockService, &mockMailer{})
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/api/v1/users?limit=10", nil), rec)
+
+	if err := handler.ListUsers(c); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// A single page has neither prev nor next
+	expected := `</api/v1/users?limit=10&offset=0>; rel="first", </api/v1/users?limit=10&offset=0>; rel="last"`
+	if link := rec.Header().Get(HeaderLink); link != expected {
+		t.Errorf("Expected Link header %s, got %s", expected, link)
+	}
+}
+
+func TestUserHandler_ListUsers_NoPage(t *testing.T) {
+	mockService := &mockUserService{
+		listUsersFunc: func(ctx context.Context, opts models.ListUsersOptions) ([]*models.User, error) {
+			if opts.Limit != 0 || opts.Offset != 0 {
+				t.Errorf("Expected every user without limit or offset, got %+v", opts)
+			}
+			return []*models.User{}, nil
+		},
+	}
+
+	handler := NewUserHandler(mockService, &mockMailer{})
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/api/v1/users", nil), rec)
+
+	if err := handler.ListUsers(c); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if link := rec.Header().Get(HeaderLink); link != "" || strings.Contains(rec.Body.String(), "total") {
+		t.Errorf("Expected no paging without limit or offset, got Link %q and body %s", link, rec.Body.String())
+	}
+}
+
+func TestUserHandler_ListUsers_InvalidPage(t *testing.T) {
+	handler := NewUserHandler(&mockUserService{}, &mockMailer{})
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/api/v1/users?limit=500", nil), rec)
+
+	if err := handler.ListUsers(c); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestUserHandler_ListUsers_TooManyUserIDs(t *testing.T) {
+	handler := NewUserHandler(&mockUserService{}, &mockMailer{})
+	e := echo.New()
+
+	ids := make([]string, models.MaxListUserIDs+1)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("user%d", i)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/users?user_ids="+strings.Join(ids, ","), nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := handler.ListUsers(c); err != nil {
+		t.Fatalf("Expected no error from handler, got %v", err)
+	}
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestUserHandler_CountUsers_Success(t *testing.T) {
+	var gotDomain string
+	mockService := &mockUserService{
+		countUsersFunc: func(ctx context.Context, emailDomain string) (int64, error) {
+			gotDomain = emailDomain
+			return 42, nil
+		},
+	}
+
+	handler := NewUserHandler(mockService, &mockMailer{})
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/users/count?email_domain=example.com", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := handler.CountUsers(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	if gotDomain != "example.com" {
+		t.Errorf("Expected email_domain 'example.com', got '%s'", gotDomain)
+	}
+
+	var response map[string]int64
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if response["count"] != 42 {
+		t.Errorf("Expected count 42, got %d", response["count"])
+	}
+}
+
+func TestUserHandler_CountUsers_ServerError(t *testing.T) {
+	mockService := &mockUserService{
+		countUsersFunc: func(ctx context.Context, emailDomain string) (int64, error) {
+			return 0, errors.New("database error")
+		},
+	}
+
+	handler := NewUserHandler(mockService, &mockMailer{})
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/users/count", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := handler.CountUsers(c)
+	if err != nil {
+		t.Fatalf("Expected no error from handler, got %v", err)
+	}
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+	}
+}
+
+func TestUserHandler_SearchUsers_Success(t *testing.T) {
+	var gotQuery string
+	mockService := &mockUserService{
+		searchUsersFunc: func(ctx context.Context, query string) ([]*models.User, error) {
+			gotQuery = query
+			return []*models.User{}, nil
+		},
+	}
+
+	handler := NewUserHandler(mockService, &mockMailer{})
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/users/search?q=Test", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := handler.SearchUsers(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	if gotQuery != "Test" {
+		t.Errorf("Expected query 'Test', got '%s'", gotQuery)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if users, ok := response["users"].([]interface{}); !ok || len(users) != 0 {
+		t.Errorf("Expected an empty users list, got %v", response["users"])
+	}
+}
+
+func TestUserHandler_SearchUsers_MissingQuery(t *testing.T) {
+	handler := NewUserHandler(&mockUserService{}, &mockMailer{})
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/users/search", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := handler.SearchUsers(c)
+	if err != nil {
+		t.Fatalf("Expected no error from handler, got %v", err)
+	}
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestUserHandler_ReplaceUser_Success(t *testing.T) {
+	userID := bson.NewObjectID()
+	mockService := &mockUserService{
+		replaceUserFunc: func(ctx context.Context, id string, req *models.ReplaceUserRequest, expectedUpdatedAt *time.Time) (*models.User, error) {
+			return &models.User{
+				ID:        userID,
+				UserID:    req.UserID,
+				Email:     req.Email,
+				CreatedAt: time.Now(),
+				UpdatedAt: time.Now(),
+			}, nil
+		},
+	}
+
+	handler := NewUserHandler(mockService, &mockMailer{})
+	e := echo.New()
+	e.Validator = NewValidator()
+
+	reqBody := `{"user_id":"replaced","email":"replaced@example.com","password":"password123"}`
+	req := httptest.NewRequest(http.MethodPut, "/users/"+userID.Hex(), strings.NewReader(reqBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(userID.Hex())
+
+	err := handler.ReplaceUser(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestUserHandler_ReplaceUser_MissingFields(t *testing.T) {
+	handler := NewUserHandler(&mockUserService{}, &mockMailer{})
+	e := echo.New()
+	e.Validator = NewValidator()
+
+	userID := bson.NewObjectID()
+	reqBody := `{"email":"partial@example.com"}`
+	req := httptest.NewRequest(http.MethodPut, "/users/"+userID.Hex(), strings.NewReader(reqBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(userID.Hex())
+
+	err := handler.ReplaceUser(c)
+	if err != nil {
+		t.Fatalf("Expected no error from handler, got %v", err)
+	}
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestUserHandler_SetUserRoles(t *testing.T) {
+	userID := bson.NewObjectID()
+
+	tests := []struct {
+		name           string
+		reqBody        string
+		serviceErr     error
+		expectedStatus int
+	}{
+		{"Valid roles", `{"roles":["user","admin"]}`, nil, http.StatusOK},
+		{"Unknown role", `{"roles":["superuser"]}`, nil, http.StatusBadRequest},
+		{"Empty roles", `{"roles":[]}`, nil, http.StatusBadRequest},
+		{"User not found", `{"roles":["user"]}`, services.ErrUserNotFound, http.StatusNotFound},
+		{"Service error", `{"roles":["user"]}`, errors.New("database error"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &mockUserService{
+				setRolesFunc: func(ctx context.Context, id string, roles []string) (*models.User, error) {
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					return &models.User{ID: userID, Roles: roles}, nil
+				},
+			}
+			handler := NewUserHandler(mockService, &mockMailer{})
+			e := echo.New()
+			e.Validator = NewValidator()
+
+			req := httptest.NewRequest(http.MethodPut, "/users/"+userID.Hex()+"/roles", strings.NewReader(tt.reqBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues(userID.Hex())
+
+			if err := handler.SetUserRoles(c); err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestUserHandler_SetUserStatus(t *testing.T) {
+	setTestSecret(t)
+	userID := bson.NewObjectID()
+	adminID := bson.NewObjectID()
+	token, err := auth.GenerateToken(&models.User{ID: adminID, UserID: "admin", Roles: []string{models.RoleAdmin}})
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		action         string
+		id             string
+		serviceErr     error
+		expectedStatus int
+		expectedUser   string
+	}{
+		{"Deactivate", "deactivate", userID.Hex(), nil, http.StatusOK, models.StatusDisabled},
+		{"Activate", "activate", userID.Hex(), nil, http.StatusOK, models.StatusActive},
+		{"Deactivate self", "deactivate", adminID.Hex(), nil, http.StatusBadRequest, ""},
+		{"Activate self", "activate", adminID.Hex(), nil, http.StatusOK, models.StatusActive},
+		{"User not found", "deactivate", userID.Hex(), services.ErrUserNotFound, http.StatusNotFound, ""},
+		{"Invalid ID", "activate", "bad", services.ErrInvalidUserID, http.StatusBadRequest, ""},
+		{"Service error", "deactivate", userID.Hex(), errors.New("database error"), http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &mockUserService{
+				setStatusFunc: func(ctx context.Context, id, status string) (*models.User, error) {
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					return &models.User{ID: userID, Status: status}, nil
+				},
+			}
+			handler := NewUserHandler(mockService, &mockMailer{})
+			e := echo.New()
+			e.POST("/users/:id/activate", handler.ActivateUser, auth.Authenticate())
+			e.POST("/users/:id/deactivate", handler.DeactivateUser, auth.Authenticate())
+
+			req := httptest.NewRequest(http.MethodPost, "/users/"+tt.id+"/"+tt.action, nil)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			if tt.expectedUser != "" {
+				var response models.UserResponse
+				if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+					t.Fatalf("Failed to unmarshal response: %v", err)
+				}
+				if response.Status != tt.expectedUser {
+					t.Errorf("Expected status '%s', got '%s'", tt.expectedUser, response.Status)
+				}
+			}
+		})
+	}
+}
+
+func TestUserHandler_ListUsers_Status(t *testing.T) {
+	tests := []struct {
+		query          string
+		expectedStatus int
+		expectedFilter string
+	}{
+		{"", http.StatusOK, ""},
+		{"active", http.StatusOK, models.StatusActive},
+		{"disabled", http.StatusOK, models.StatusDisabled},
+		{"suspended", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			var gotOpts models.ListUsersOptions
+			mockService := &mockUserService{
+				listUsersFunc: func(ctx context.Context, opts models.ListUsersOptions) ([]*models.User, error) {
+					gotOpts = opts
+					return []*models.User{}, nil
+				},
+			}
+			handler := NewUserHandler(mockService, &mockMailer{})
+			e := echo.New()
+
+			req := httptest.NewRequest(http.MethodGet, "/users?status="+tt.query, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if err := handler.ListUsers(c); err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			if gotOpts.Status != tt.expectedFilter {
+				t.Errorf("Expected status filter '%s', got '%s'", tt.expectedFilter, gotOpts.Status)
+			}
+		})
+	}
+}
+
+func TestUserHandler_UserExists(t *testing.T) {
+	tests := []struct {
+		name       string
+		exists     bool
+		serviceErr error
+		statusCode int
+	}{
+		{"Exists", true, nil, http.StatusOK},
+		{"Missing", false, nil, http.StatusNotFound},
+		{"Invalid ID", false, services.ErrInvalidUserID, http.StatusBadRequest},
+		{"Server error", false, errors.New("database error"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewUserHandler(&mockUserService{
+				userExistsFunc: func(ctx context.Context, id string) (bool, error) {
+					if id != "123" {
+						t.Errorf("Expected ID '123', got '%s'", id)
+					}
+					return tt.exists, tt.serviceErr
+				},
+			}, &mockMailer{})
+			e := echo.New()
+			e.HEAD("/users/:id", handler.UserExists)
+
+			req := httptest.NewRequest(http.MethodHead, "/users/123", nil)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.statusCode {
+				t.Errorf("Expected status %d, got %d", tt.statusCode, rec.Code)
+			}
+			if tt.serviceErr == nil && rec.Body.Len() != 0 {
+				t.Errorf("Expected no body, got %q", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestUserHandler_UserIDExists(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		exists     bool
+		statusCode int
+	}{
+		{"Taken", "?user_id=alice", true, http.StatusOK},
+		{"Free", "?user_id=alice", false, http.StatusNotFound},
+		{"Missing user_id", "", false, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewUserHandler(&mockUserService{
+				userIDExistsFunc: func(ctx context.Context, userID string) (bool, error) {
+					if userID != "alice" {
+						t.Errorf("Expected user_id 'alice', got '%s'", userID)
+					}
+					return tt.exists, nil
+				},
+			}, &mockMailer{})
+			e := echo.New()
+
+			req := httptest.NewRequest(http.MethodHead, "/users/search"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if err := handler.UserIDExists(c); err != nil {
+				t.Fatalf("Expected no error from handler, got %v", err)
+			}
+
+			if rec.Code != tt.statusCode {
+				t.Errorf("Expected status %d, got %d", tt.statusCode, rec.Code)
+			}
+			if rec.Body.Len() != 0 {
+				t.Errorf("Expected no body, got %q", rec.Body.String())
+			}
+		})
+	}
+}
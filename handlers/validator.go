@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"go-mongodb-test/auth"
+	"go-mongodb-test/models"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// CustomValidator adapts go-playground/validator to Echo's Validator interface
+type CustomValidator struct {
+	validator *validator.Validate
+	// blocklist backs the notcommon rule; without one every password passes it
+	blocklist *auth.PasswordBlocklist
+}
+
+// ValidatorOption configures optional validation rules
+type ValidatorOption func(*CustomValidator)
+
+// WithPasswordBlocklist makes the notcommon rule reject passwords on blocklist
+func WithPasswordBlocklist(blocklist *auth.PasswordBlocklist) ValidatorOption {
+	return func(cv *CustomValidator) {
+		cv.blocklist = blocklist
+	}
+}
+
+func NewValidator(opts ...ValidatorOption) *CustomValidator {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// Report JSON field names instead of Go struct field names
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	cv := &CustomValidator{validator: v}
+	for _, opt := range opts {
+		opt(cv)
+	}
+
+	// The rule is always registered so that models can carry the tag whether or
+	// not a blocklist is configured
+	_ = v.RegisterValidation("notcommon", func(fl validator.FieldLevel) bool {
+		return cv.blocklist == nil || !cv.blocklist.Contains(fl.Field().String())
+	})
+	// maxbytes caps the UTF-8 length, which max does not: max counts runes, and
+	// bcrypt refuses passwords over 72 bytes
+	_ = v.RegisterValidation("maxbytes", func(fl validator.FieldLevel) bool {
+		limit, err := strconv.Atoi(fl.Param())
+		return err == nil && len(fl.Field().String()) <= limit
+	})
+
+	return cv
+}
+
+func (cv *CustomValidator) Validate(i interface{}) error {
+	return cv.validator.Struct(i)
+}
+
+// fieldErrorsResponse renders accumulated field errors as a 400
+func fieldErrorsResponse(c echo.Context, fe models.FieldErrors) error {
+	return respondJSON(c, http.StatusBadRequest, ValidationErrorResponse{
+		Error:     "Validation failed",
+		Errors:    fe.Map(),
+		Details:   fe,
+		RequestID: requestID(c),
+	})
+}
+
+// validationError renders a validation failure as a 400 with field-level details
+func validationError(c echo.Context, err error) error {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return errorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+
+	fieldErrs := make(models.FieldErrors, 0, len(validationErrs))
+	for _, fieldErr := range validationErrs {
+		fieldErrs.Add(fieldErr.Field(), fieldErrorMessage(fieldErr))
+	}
+
+	return fieldErrorsResponse(c, fieldErrs)
+}
+
+// fieldErrorMessage returns a human readable message for a failed rule
+func fieldErrorMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min":
+		if fieldErr.Kind() == reflect.Slice {
+			return fmt.Sprintf("must contain at least %s items", fieldErr.Param())
+		}
+		return fmt.Sprintf("must be at least %s characters", fieldErr.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s characters", fieldErr.Param())
+	case "maxbytes":
+		return fmt.Sprintf("must be at most %s bytes", fieldErr.Param())
+	case "http_url":
+		return "must be an http or https URL"
+	case "eq=|http_url":
+		// Updates accept an empty string to clear the URL
+		return "must be an http or https URL, or empty to clear it"
+	case "oneof":
+		return "must be one of: " + strings.Join(strings.Fields(fieldErr.Param()), ", ")
+	case "notcommon":
+		return "is too common; choose a password that is harder to guess"
+	default:
+		return fmt.Sprintf("failed %s validation", fieldErr.Tag())
+	}
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-mongodb-test/auth"
+	"go-mongodb-test/models"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestCustomValidator_CreateUserRequest(t *testing.T) {
+	v := NewValidator()
+
+	tests := []struct {
+		name    string
+		req     models.CreateUserRequest
+		wantErr bool
+	}{
+		{"Valid request", models.CreateUserRequest{UserID: "testuser", Email: "test@example.com", Password: "password123"}, false},
+		{"Invalid email", models.CreateUserRequest{UserID: "testuser", Email: "not-an-email", Password: "password123"}, true},
+		{"Short password", models.CreateUserRequest{UserID: "testuser", Email: "test@example.com", Password: "123"}, true},
+		{"Missing user_id", models.CreateUserRequest{Email: "test@example.com", Password: "password123"}, true},
+		{"Profile fields", models.CreateUserRequest{UserID: "testuser", Email: "test@example.com", Password: "password123", FirstName: "Alice", LastName: "Liddell", AvatarURL: "https://example.com/a.png"}, false},
+		{"Invalid avatar_url", models.CreateUserRequest{UserID: "testuser", Email: "test@example.com", Password: "password123", AvatarURL: "not a url"}, true},
+		{"Non-HTTP avatar_url", models.CreateUserRequest{UserID: "testuser", Email: "test@example.com", Password: "password123", AvatarURL: "javascript:alert(1)"}, true},
+		{"Long first_name", models.CreateUserRequest{UserID: "testuser", Email: "test@example.com", Password: "password123", FirstName: strings.Repeat("a", 101)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Validate(&tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error: %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCustomValidator_UpdateUserRequest(t *testing.T) {
+	v := NewValidator()
+	invalidEmail := "not-an-email"
+	shortPassword := "123"
+	emptyUserID := ""
+	invalidAvatarURL := "ftp://example.com/a.png"
+	clearedAvatarURL := ""
+
+	tests := []struct {
+		name    string
+		req     models.UpdateUserRequest
+		wantErr bool
+	}{
+		{"Empty update", models.UpdateUserRequest{}, false},
+		{"Invalid email", models.UpdateUserRequest{Email: &invalidEmail}, true},
+		{"Short password", models.UpdateUserRequest{Password: &shortPassword}, true},
+		{"Empty user_id", models.UpdateUserRequest{UserID: &emptyUserID}, true},
+		{"Invalid avatar_url", models.UpdateUserRequest{AvatarURL: &invalidAvatarURL}, true},
+		{"Cleared avatar_url", models.UpdateUserRequest{AvatarURL: &clearedAvatarURL}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Validate(&tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error: %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCustomValidator_PasswordBlocklist(t *testing.T) {
+	common := "Password123"
+	uncommon := "correct-horse-battery"
+
+	t.Run("Off by default", func(t *testing.T) {
+		req := models.CreateUserRequest{UserID: "testuser", Email: "test@example.com", Password: common}
+		if err := NewValidator().Validate(&req); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	})
+
+	v := NewValidator(WithPasswordBlocklist(auth.DefaultPasswordBlocklist()))
+	tests := []struct {
+		name    string
+		req     interface{}
+		wantErr bool
+	}{
+		{"Create with a common password", &models.CreateUserRequest{UserID: "testuser", Email: "test@example.com", Password: common}, true},
+		{"Create with an uncommon password", &models.CreateUserRequest{UserID: "testuser", Email: "test@example.com", Password: uncommon}, false},
+		{"Update with a common password", &models.UpdateUserRequest{Password: &common}, true},
+		{"Update without a password", &models.UpdateUserRequest{}, false},
+		{"Reset to a common password", &models.ResetPasswordRequest{Token: "token", NewPassword: common}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Validate(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error: %v, got %v", tt.wantErr, err)
+			}
+
+			var validationErrs validator.ValidationErrors
+			if tt.wantErr && (!errors.As(err, &validationErrs) || !strings.Contains(fieldErrorMessage(validationErrs[0]), "too common")) {
+				t.Errorf("Expected a too common message, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCustomValidator_PasswordBytes(t *testing.T) {
+	v := NewValidator()
+	atLimit := strings.Repeat("a", 72)
+	overLimit := strings.Repeat("a", 73)
+	// 37 runes, so max would pass it, but 74 bytes
+	multibyte := strings.Repeat("é", 37)
+
+	tests := []struct {
+		name    string
+		req     interface{}
+		wantErr bool
+	}{
+		{"Create at 72 bytes", &models.CreateUserRequest{UserID: "testuser", Email: "test@example.com", Password: atLimit}, false},
+		{"Create at 73 bytes", &models.CreateUserRequest{UserID: "testuser", Email: "test@example.com", Password: overLimit}, true},
+		{"Create with multibyte runes", &models.CreateUserRequest{UserID: "testuser", Email: "test@example.com", Password: multibyte}, true},
+		{"Replace at 73 bytes", &models.ReplaceUserRequest{UserID: "testuser", Email: "test@example.com", Password: overLimit}, true},
+		{"Update at 73 bytes", &models.UpdateUserRequest{Password: &overLimit}, true},
+		{"Reset at 73 bytes", &models.ResetPasswordRequest{Token: "token", NewPassword: overLimit}, true},
+		{"Admin reset at 73 bytes", &models.AdminResetPasswordRequest{NewPassword: overLimit}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Validate(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error: %v, got %v", tt.wantErr, err)
+			}
+
+			var validationErrs validator.ValidationErrors
+			if tt.wantErr && (!errors.As(err, &validationErrs) || fieldErrorMessage(validationErrs[0]) != "must be at most 72 bytes") {
+				t.Errorf("Expected a byte limit message, got %v", err)
+			}
+		})
+	}
+}
+
+func TestUserHandler_CreateUser_ValidationDetails(t *testing.T) {
+	handler := NewUserHandler(&mockUserService{}, &mockMailer{})
+	e := echo.New()
+	e.Validator = NewValidator()
+
+	reqBody := `{"user_id":"test123","email":"not-an-email","password":"123"}`
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(reqBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := handler.CreateUser(c); err != nil {
+		t.Fatalf("Expected no error from handler, got %v", err)
+	}
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	var response ValidationErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	fields := map[string]bool{}
+	for _, detail := range response.Details {
+		fields[detail.Field] = true
+	}
+
+	if !fields["email"] || !fields["password"] {
+		t.Errorf("Expected email and password field errors, got %+v", response.Details)
+	}
+
+	if response.Errors["email"] != "must be a valid email address" {
+		t.Errorf("Expected email message in errors map, got %v", response.Errors)
+	}
+	if response.Errors["password"] != "must be at least 6 characters" {
+		t.Errorf("Expected password message in errors map, got %v", response.Errors)
+	}
+}
+
+func TestFieldErrors_Map(t *testing.T) {
+	var fieldErrs models.FieldErrors
+	fieldErrs.Add("email", "is required")
+	fieldErrs.Add("password", "must be at least 6 characters")
+	fieldErrs.Add("email", "must be a valid email address")
+
+	if len(fieldErrs) != 3 {
+		t.Errorf("Expected 3 accumulated errors, got %d", len(fieldErrs))
+	}
+
+	fields := fieldErrs.Map()
+	if len(fields) != 2 {
+		t.Errorf("Expected 2 fields, got %v", fields)
+	}
+	if fields["email"] != "is required" {
+		t.Errorf("Expected first email message to win, got '%s'", fields["email"])
+	}
+}
+
+func TestUserHandler_CreateUser_ServiceRejectsEmail(t *testing.T) {
+	mockService := &mockUserService{
+		createUserFunc: func(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
+			return nil, models.ErrInvalidEmail
+		},
+	}
+	handler := NewUserHandler(mockService, &mockMailer{})
+	e := echo.New()
+	e.Validator = NewValidator()
+
+	reqBody := `{"user_id":"test123","email":"test@example.com","password":"password123"}`
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(reqBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := handler.CreateUser(c); err != nil {
+		t.Fatalf("Expected no error from handler, got %v", err)
+	}
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	var response ValidationErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if _, ok := response.Errors["email"]; !ok {
+		t.Errorf("Expected an email field error, got %v", response.Errors)
+	}
+}
+
+func TestUserHandler_UpdateUser_InvalidEmail(t *testing.T) {
+	handler := NewUserHandler(&mockUserService{}, &mockMailer{})
+	e := echo.New()
+	e.Validator = NewValidator()
+
+	userID := bson.NewObjectID()
+	reqBody := `{"email":"not-an-email"}`
+	req := httptest.NewRequest(http.MethodPut, "/users/"+userID.Hex(), strings.NewReader(reqBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(userID.Hex())
+
+	if err := handler.UpdateUser(c); err != nil {
+		t.Fatalf("Expected no error from handler, got %v", err)
+	}
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	var response ValidationErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.Errors["email"] != "must be a valid email address" {
+		t.Errorf("Expected email message in errors map, got %v", response.Errors)
+	}
+}
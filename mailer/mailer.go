@@ -0,0 +1,34 @@
+package mailer
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Mailer delivers account emails to users
+type Mailer interface {
+	SendPasswordReset(ctx context.Context, email, token string) error
+	SendVerification(ctx context.Context, email, token string) error
+}
+
+// LogMailer writes emails to the structured log instead of sending them.
+// It is intended for local development, where no mail server is available.
+type LogMailer struct {
+	logger *slog.Logger
+}
+
+func NewLogMailer(logger *slog.Logger) *LogMailer {
+	return &LogMailer{
+		logger: logger,
+	}
+}
+
+func (m *LogMailer) SendPasswordReset(ctx context.Context, email, token string) error {
+	m.logger.InfoContext(ctx, "Password reset requested", "email", email, "token", token)
+	return nil
+}
+
+func (m *LogMailer) SendVerification(ctx context.Context, email, token string) error {
+	m.logger.InfoContext(ctx, "Email verification requested", "email", email, "token", token)
+	return nil
+}
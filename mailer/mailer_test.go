@@ -0,0 +1,37 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLogMailer_SendPasswordReset(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewLogMailer(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	if err := m.SendPasswordReset(context.Background(), "test@example.com", "reset-token"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, "test@example.com") || !strings.Contains(output, "reset-token") {
+		t.Errorf("Expected log to contain email and token, got %s", output)
+	}
+}
+
+func TestLogMailer_SendVerification(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewLogMailer(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	if err := m.SendVerification(context.Background(), "test@example.com", "verify-token"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, "test@example.com") || !strings.Contains(output, "verify-token") {
+		t.Errorf("Expected log to contain email and token, got %s", output)
+	}
+}
@@ -1,86 +1,315 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
 	"os"
+	"time"
 
+	"go-mongodb-test/auth"
+	"go-mongodb-test/config"
 	"go-mongodb-test/database"
 	"go-mongodb-test/handlers"
+	"go-mongodb-test/mailer"
+	"go-mongodb-test/middlewares"
+	"go-mongodb-test/migrations"
+	"go-mongodb-test/models"
+	"go-mongodb-test/routes"
 	"go-mongodb-test/services"
+	"go-mongodb-test/telemetry"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
 )
 
+//	@title			User Management API
+//	@version		1.0
+//	@description	CRUD API for users stored in MongoDB.
+//	@BasePath		/api/v1
+
+// swag only reads the scheme name when @securitydefinitions starts the line
+//@securitydefinitions.apikey apikeyauth
+//	@in							header
+//	@name						X-API-Key
+//	@description				Service key from API_KEYS, accepted by the admin user routes; read keys may only GET
+
+//	@securitydefinitions.bearerauth
+
 func main() {
-	// Initialize database connection
-	db, err := database.NewConnection()
+	migrateOnly := flag.Bool("migrate", false, "apply pending database migrations and exit")
+	flag.Parse()
+
+	// Structured JSON logging for the whole process
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	slog.SetDefault(logger)
+
+	// Read and validate every setting before touching the network
+	cfg, err := config.Load()
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		slog.Error("Failed to load configuration", "error", err)
+		os.Exit(1)
 	}
-	defer func(db *database.Database) {
-		err := db.Close()
-		if err != nil {
-			log.Fatalln("Failed to close database:", err)
+	auth.SetSecret(cfg.JWTSecret)
+	auth.SetEncryptionKey([]byte(cfg.TOTPEncryptionKey))
+	auth.SetRequireEmailVerification(cfg.RequireEmailVerification)
+	apiKeys := make([]auth.APIKey, 0, len(cfg.APIKeys))
+	for _, key := range cfg.APIKeys {
+		apiKeys = append(apiKeys, auth.APIKey{Name: key.Name, Scope: key.Scope, Hash: key.Hash})
+	}
+	auth.SetAPIKeys(apiKeys)
+	if err := models.SetPasswordHashAlgorithm(cfg.PasswordHashAlgo); err != nil {
+		slog.Error("Failed to select password hash algorithm", "error", err)
+		os.Exit(1)
+	}
+	if err := models.SetBcryptCost(cfg.BcryptCost); err != nil {
+		slog.Error("Failed to set bcrypt cost", "error", err)
+		os.Exit(1)
+	}
+	handlers.SetResponseEnvelope(cfg.ResponseEnvelope)
+
+	// The password blocklist is read before touching the network too, so a bad path fails fast
+	var validatorOpts []handlers.ValidatorOption
+	if cfg.BlockCommonPasswords {
+		blocklist := auth.DefaultPasswordBlocklist()
+		if cfg.PasswordBlocklistFile != "" {
+			blocklist, err = auth.LoadPasswordBlocklist(cfg.PasswordBlocklistFile)
+			if err != nil {
+				slog.Error("Failed to load password blocklist", "error", err)
+				os.Exit(1)
+			}
+		}
+		slog.Info("Blocking common passwords", "entries", blocklist.Len())
+		validatorOpts = append(validatorOpts, handlers.WithPasswordBlocklist(blocklist))
+	}
+
+	// Tracing is exported over OTLP when OTEL_EXPORTER_OTLP_ENDPOINT is set
+	shutdownTracing, err := telemetry.SetupTracing(context.Background())
+	if err != nil {
+		slog.Error("Failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Failed to flush traces", "error", err)
 		}
-	}(db)
+	}()
 
 	// Initialize services
-	userService := services.NewUserService(db.DB)
+	serviceOpts := []services.Option{
+		services.WithCache(cfg.UserCacheSize, cfg.UserCacheTTL),
+		services.WithOperationTimeout(cfg.Mongo.OperationTimeout),
+		services.WithLockout(cfg.LoginMaxFailures, cfg.LoginLockoutDuration),
+		services.WithTokenTTLs(cfg.PasswordResetTokenTTL, cfg.RefreshTokenTTL),
+	}
+	if cfg.UserEventsEnabled {
+		serviceOpts = append(serviceOpts, services.WithEventHub(services.NewEventHub()))
+	}
+
+	var (
+		userService *services.UserService
+		// tenants is set instead of userService when TENANTS splits the API by tenant
+		tenants *services.TenantServices
+		storage handlers.Pinger
+		db      *database.Database
+	)
+	if cfg.Storage == config.StorageMemory {
+		if *migrateOnly {
+			slog.Error("Migrations need STORAGE=mongo")
+			os.Exit(1)
+		}
+		slog.Warn("Storing users in memory; they are lost on exit and login is unavailable")
+		repo := services.NewInMemoryUserRepository()
+		userService = services.NewUserServiceWithRepository(repo, serviceOpts...)
+		storage = repo
+	} else {
+		// Initialize database connection
+		db, err = database.NewConnection(cfg.Mongo)
+		if err != nil {
+			slog.Error("Failed to connect to database", "error", err)
+			os.Exit(1)
+		}
+		defer func(db *database.Database) {
+			err := db.Close()
+			if err != nil {
+				slog.Error("Failed to close database", "error", err)
+			}
+		}(db)
+
+		if *migrateOnly {
+			if err := runMigrations(migrationDatabases(cfg, db)); err != nil {
+				slog.Error("Failed to apply migrations", "error", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		if cfg.Mongo.ListReadPreference != "" {
+			rp, err := database.ReadPreference(cfg.Mongo.ListReadPreference)
+			if err != nil {
+				slog.Error("Invalid list read preference", "error", err)
+				os.Exit(1)
+			}
+			serviceOpts = append(serviceOpts, services.WithListReadPreference(rp))
+		}
+		if len(cfg.Tenants) > 0 {
+			databases := make(map[string]string, len(cfg.Tenants))
+			for _, tenant := range cfg.Tenants {
+				databases[tenant.ID] = tenant.Database
+			}
+			slog.Info("Serving users by tenant", "tenants", len(databases))
+			tenants = services.NewTenantServices(db.Client, databases, serviceOpts...)
+		} else {
+			userService = services.NewUserService(db.DB, serviceOpts...)
+		}
+		storage = db
+	}
+
+	// With a replica set, the event stream follows the users change stream and so
+	// covers changes made through every instance. Opening it waits for server
+	// selection, so it happens in the background; until then local writes are published.
+	if cfg.UserEventsEnabled && db != nil {
+		go func() {
+			if err := userService.WatchUserEvents(context.Background()); err != nil {
+				slog.Warn("Change streams are unavailable; user events only cover changes made by this instance", "error", err)
+			}
+		}()
+	}
 
-	// Initialize handlers
-	userHandler := handlers.NewUserHandler(userService)
+	// Initialize handlers. With tenants the handlers have no service of their own:
+	// every API route runs behind ResolveTenant, which picks the tenant's service.
+	m := mailer.NewLogMailer(logger)
+	userHandler := handlers.NewUserHandler(userService, m)
+	authHandler := handlers.NewAuthHandler(userService, m)
+	healthHandler := handlers.NewHealthHandler(storage)
+	maintenance := middlewares.NewMaintenance(cfg.MaintenanceMode)
+	if cfg.MaintenanceMode {
+		slog.Warn("Starting in maintenance mode; writes are refused until an admin turns it off")
+	}
 
 	// Initialize Echo
 	e := echo.New()
+	e.Validator = handlers.NewValidator(validatorOpts...)
+	e.HTTPErrorHandler = handlers.HTTPErrorHandler
+	// c.RealIP, which rate limits and request logs use, only believes forwarding
+	// headers from the configured proxies
+	e.IPExtractor = middlewares.IPExtractor(cfg.TrustedProxies)
 
 	// Middleware
-	e.Use(middleware.Logger())
-	e.Use(middleware.Recover())
-	e.Use(middleware.CORS())
-
-	// Add JSON content type validation middleware
-	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
-		return func(c echo.Context) error {
-			if c.Request().Method == "POST" || c.Request().Method == "PUT" {
-				contentType := c.Request().Header.Get("Content-Type")
-				if contentType != "" && contentType != "application/json" {
-					return c.JSON(400, map[string]string{
-						"error": "Content-Type must be application/json",
-					})
-				}
-			}
-			return next(c)
-		}
-	})
+	// Route /users/ like /users; this runs before routing, so it must be Pre rather than Use
+	e.Pre(middleware.RemoveTrailingSlash())
+	e.Use(middleware.RequestID())
+	e.Use(otelecho.Middleware(services.TracerName))
+	e.Use(middlewares.RequestLogger(logger))
+	e.Use(middlewares.Recover(logger))
+	e.Use(middlewares.Timeout(cfg.RequestTimeout, routes.StreamingPaths(cfg.APIPrefix)...))
+	e.Use(middlewares.CORS(cfg.CORS.AllowedOrigins, cfg.CORS.AllowedMethods, cfg.CORS.AllowCredentials))
+
+	e.Use(middlewares.RequireJSONContentType(routes.UploadPaths(cfg.APIPrefix)...))
+	e.Use(middlewares.MaintenanceMode(maintenance, routes.MaintenancePaths(cfg.APIPrefix)...))
 
 	// Routes
-	api := e.Group("/api/v1")
-
-	// User routes
-	users := api.Group("/users")
-	users.POST("", userHandler.CreateUser)                 // Create user
-	users.GET("", userHandler.ListUsers)                   // List all users
-	users.GET("/search", userHandler.GetUserByUserID)      // Search by user_id (query param)
-	users.GET("/search/email", userHandler.GetUserByEmail) // Search by email (query param)
-	users.GET("/:id", userHandler.GetUser)                 // Get user by MongoDB ID
-	users.PUT("/:id", userHandler.UpdateUser)              // Update user
-	users.DELETE("/:id", userHandler.DeleteUser)           // Delete user
-
-	// Health check
-	e.GET("/health", func(c echo.Context) error {
-		return c.JSON(200, map[string]string{
-			"status":  "healthy",
-			"message": "User management service is running",
-		})
+	var tenant echo.MiddlewareFunc
+	if tenants != nil {
+		tenant = handlers.ResolveTenant(tenants)
+	}
+	routes.SetupRoutes(e, routes.Config{
+		Users:                 userHandler,
+		Auth:                  authHandler,
+		Health:                healthHandler,
+		Maintenance:           handlers.NewMaintenanceHandler(maintenance),
+		Prefix:                cfg.APIPrefix,
+		RateLimitPerMinute:    cfg.RateLimitPerMinute,
+		MaxConcurrentRequests: cfg.MaxConcurrentRequests,
+		AllowDestructive:      cfg.AllowDestructive,
+		UserEvents:            cfg.UserEventsEnabled,
+		Tenant:                tenant,
+		// The availability check is budgeted apart from the credential endpoints
+		AvailabilityRateLimitPerMinute: cfg.AvailabilityRateLimitPerMinute,
 	})
 
-	// Get port from environment or default to 8080
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// Build indexes and migrate existing users while the server is already answering
+	// liveness probes; readiness only passes once the unique constraints are in place
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		for _, service := range indexedServices(userService, tenants) {
+			if err := service.EnsureIndexes(ctx); err != nil {
+				slog.Error("Failed to create indexes", "error", err)
+				os.Exit(1)
+			}
+		}
+		if db != nil {
+			if err := runMigrations(migrationDatabases(cfg, db)); err != nil {
+				slog.Error("Failed to apply migrations", "error", err)
+				os.Exit(1)
+			}
+		}
+		// Seeding after the indexes lets the unique constraints catch a user that
+		// another instance seeded at the same time
+		if cfg.SeedFile != "" {
+			if _, err := userService.SeedUsers(ctx, cfg.SeedFile); err != nil {
+				slog.Error("Failed to seed users", "error", err)
+				os.Exit(1)
+			}
+		}
+		healthHandler.MarkStarted()
+		slog.Info("Indexes are in place; reporting ready")
+	}()
+
+	slog.Info("Starting server", "port", cfg.Port)
+	if err := e.Start(":" + cfg.Port); err != nil {
+		slog.Error("Server stopped", "error", err)
+		os.Exit(1)
 	}
+}
+
+// migrationTimeout bounds a migration run, which may rewrite every user
+const migrationTimeout = 10 * time.Minute
+
+// runMigrations applies the pending migrations to each of databases
+func runMigrations(databases []*mongo.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), migrationTimeout)
+	defer cancel()
 
-	log.Printf("Starting server on port %s", port)
-	log.Fatal(e.Start(":" + port))
+	for _, database := range databases {
+		applied, err := migrations.Run(ctx, database, migrations.All)
+		if err != nil {
+			return fmt.Errorf("database %s: %w", database.Name(), err)
+		}
+		slog.Info("Migrations are up to date", "database", database.Name(), "applied", applied)
+	}
+	return nil
+}
+
+// migrationDatabases returns the database of every tenant, or the configured
+// database when the API is not split into tenants
+func migrationDatabases(cfg *config.Config, db *database.Database) []*mongo.Database {
+	if len(cfg.Tenants) == 0 {
+		return []*mongo.Database{db.DB}
+	}
+	databases := make([]*mongo.Database, 0, len(cfg.Tenants))
+	for _, tenant := range cfg.Tenants {
+		databases = append(databases, db.Client.Database(tenant.Database))
+	}
+	return databases
+}
+
+// indexedServices returns the service of every tenant, or userService when the
+// API is not split into tenants
+func indexedServices(userService *services.UserService, tenants *services.TenantServices) []*services.UserService {
+	if tenants == nil {
+		return []*services.UserService{userService}
+	}
+	var all []*services.UserService
+	for _, id := range tenants.Tenants() {
+		service, _ := tenants.For(id)
+		all = append(all, service)
+	}
+	return all
 }
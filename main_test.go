@@ -1,8 +1,17 @@
 package main
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+
+	_ "go-mongodb-test/docs"
+
+	"github.com/labstack/echo/v4"
+	echoSwagger "github.com/swaggo/echo-swagger"
 )
 
 // TestEnvironmentVariables tests environment variable handling
@@ -67,12 +76,12 @@ func TestMainLogic(t *testing.T) {
 			contentType string
 			shouldPass  bool
 		}{
-			{"GET", "", true},                           // GET requests don't need content type
-			{"POST", "application/json", true},          // Valid content type
-			{"POST", "", true},                          // Empty content type is allowed
-			{"POST", "text/plain", false},               // Invalid content type
-			{"PUT", "application/json", true},           // Valid content type for PUT
-			{"PUT", "text/xml", false},                  // Invalid content type for PUT
+			{"GET", "", true},                  // GET requests don't need content type
+			{"POST", "application/json", true}, // Valid content type
+			{"POST", "", true},                 // Empty content type is allowed
+			{"POST", "text/plain", false},      // Invalid content type
+			{"PUT", "application/json", true},  // Valid content type for PUT
+			{"PUT", "text/xml", false},         // Invalid content type for PUT
 		}
 
 		for _, tc := range testCases {
@@ -82,7 +91,7 @@ func TestMainLogic(t *testing.T) {
 
 			passed := !hasInvalidContentType
 			if passed != tc.shouldPass {
-				t.Errorf("Method: %s, ContentType: %s - Expected pass: %v, got: %v", 
+				t.Errorf("Method: %s, ContentType: %s - Expected pass: %v, got: %v",
 					tc.method, tc.contentType, tc.shouldPass, passed)
 			}
 		}
@@ -146,7 +155,7 @@ func TestMiddlewareConfiguration(t *testing.T) {
 		// Test that we have the expected middleware types
 		middlewareTypes := []string{
 			"Logger",
-			"Recover", 
+			"Recover",
 			"CORS",
 			"ContentTypeValidation",
 		}
@@ -158,4 +167,36 @@ func TestMiddlewareConfiguration(t *testing.T) {
 			}
 		}
 	})
-}
\ No newline at end of file
+}
+
+// TestSwaggerSpec checks the generated OpenAPI spec is served and covers the user routes
+func TestSwaggerSpec(t *testing.T) {
+	e := echo.New()
+	e.GET("/swagger/*", echoSwagger.EchoWrapHandlerV3())
+
+	req := httptest.NewRequest(http.MethodGet, "/swagger/doc.json", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	var spec struct {
+		OpenAPI string                     `json:"openapi"`
+		Paths   map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("Failed to unmarshal spec: %v", err)
+	}
+
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Errorf("Expected an OpenAPI 3 spec, got version '%s'", spec.OpenAPI)
+	}
+
+	for _, path := range []string{"/users", "/users/{id}", "/users/search", "/users/count", "/auth/login"} {
+		if _, ok := spec.Paths[path]; !ok {
+			t.Errorf("Expected spec to document %s", path)
+		}
+	}
+}
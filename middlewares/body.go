@@ -0,0 +1,38 @@
+package middlewares
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RequireBody rejects requests that arrive without a body with a 400, instead of
+// letting handlers bind an empty one. Bodies of unknown length (chunked) are
+// checked by peeking at the first byte.
+func RequireBody() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			empty := req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0
+
+			if !empty && req.ContentLength < 0 {
+				first := make([]byte, 1)
+				if n, _ := io.ReadFull(req.Body, first); n == 0 {
+					empty = true
+				} else {
+					req.Body = struct {
+						io.Reader
+						io.Closer
+					}{io.MultiReader(bytes.NewReader(first), req.Body), req.Body}
+				}
+			}
+
+			if empty {
+				return echo.NewHTTPError(http.StatusBadRequest, "Request body is required")
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package middlewares
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestRequireBody(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           io.Reader
+		chunked        bool
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"Empty body", nil, false, http.StatusBadRequest, ""},
+		{"JSON body", strings.NewReader(`{"user_id":"alice"}`), false, http.StatusOK, `{"user_id":"alice"}`},
+		{"Empty chunked body", strings.NewReader(""), true, http.StatusBadRequest, ""},
+		{"Chunked body", strings.NewReader(`{"user_id":"alice"}`), true, http.StatusOK, `{"user_id":"alice"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.POST("/users", func(c echo.Context) error {
+				body, err := io.ReadAll(c.Request().Body)
+				if err != nil {
+					return err
+				}
+				return c.String(http.StatusOK, string(body))
+			}, RequireBody())
+
+			req := httptest.NewRequest(http.MethodPost, "/users", tt.body)
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+
+			if tt.expectedStatus == http.StatusOK && rec.Body.String() != tt.expectedBody {
+				t.Errorf("Expected handler to read '%s', got '%s'", tt.expectedBody, rec.Body.String())
+			}
+			if tt.expectedStatus == http.StatusBadRequest && !strings.Contains(rec.Body.String(), "Request body is required") {
+				t.Errorf("Expected 'Request body is required' error, got %s", rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// ConcurrencyLimit lets at most limit requests run at once. Requests beyond that are
+// answered straight away with 503 and a Retry-After header instead of queueing, so
+// a burst cannot pile up work for MongoDB. Requests for which skipper returns true
+// are neither counted nor limited; a nil skipper limits everything. A limit of zero
+// or less disables the middleware.
+func ConcurrencyLimit(limit int, skipper middleware.Skipper) echo.MiddlewareFunc {
+	if limit <= 0 {
+		return func(next echo.HandlerFunc) echo.HandlerFunc {
+			return next
+		}
+	}
+
+	if skipper == nil {
+		skipper = middleware.DefaultSkipper
+	}
+	slots := make(chan struct{}, limit)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if skipper(c) {
+				return next(c)
+			}
+
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+				return next(c)
+			default:
+				c.Response().Header().Set("Retry-After", "1")
+				return echo.NewHTTPError(http.StatusServiceUnavailable, "Server is busy, try again shortly")
+			}
+		}
+	}
+}
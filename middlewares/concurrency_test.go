@@ -0,0 +1,110 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	e := echo.New()
+	e.GET("/slow", func(c echo.Context) error {
+		started <- struct{}{}
+		<-release
+		return c.NoContent(http.StatusOK)
+	}, ConcurrencyLimit(2, nil))
+
+	doRequest := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		return rec
+	}
+
+	// Occupy both slots
+	var wg sync.WaitGroup
+	codes := make([]int, 2)
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = doRequest().Code
+		}()
+		<-started
+	}
+
+	rec := doRequest()
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("Expected a Retry-After header")
+	}
+
+	close(release)
+	wg.Wait()
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("Request %d: expected status %d, got %d", i+1, http.StatusOK, code)
+		}
+	}
+
+	// Slots are freed once requests finish
+	go func() { <-started }()
+	if rec := doRequest(); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d after release, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestConcurrencyLimit_Disabled(t *testing.T) {
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, ConcurrencyLimit(0, nil))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestConcurrencyLimit_Skipper(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	e := echo.New()
+	e.Use(ConcurrencyLimit(1, func(c echo.Context) bool {
+		return c.Path() == "/health"
+	}))
+	e.GET("/slow", func(c echo.Context) error {
+		started <- struct{}{}
+		<-release
+		return c.NoContent(http.StatusOK)
+	})
+	e.GET("/health", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	// Hold the only slot
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected skipped route to get status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	close(release)
+	<-done
+}
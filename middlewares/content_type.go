@@ -0,0 +1,46 @@
+package middlewares
+
+import (
+	"mime"
+	"net/http"
+	"slices"
+
+	"github.com/labstack/echo/v4"
+)
+
+// jsonMediaTypes are the request bodies the API understands. JSON Patch is only
+// handled by PATCH /users/:id; elsewhere binding it fails with 400.
+var jsonMediaTypes = []string{echo.MIMEApplicationJSON, "application/json-patch+json"}
+
+// RequireJSONContentType rejects POST, PUT and PATCH requests whose Content-Type is
+// set to anything other than application/json, or application/json-patch+json.
+// Parameters such as charset are ignored, and a missing header is allowed. Routes whose registered path is listed
+// in except, such as file uploads, are left to check their own content type, and
+// requests for a method the path does not support are left to the router's 405.
+func RequireJSONContentType(except ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if slices.Contains(except, c.Path()) {
+				return next(c)
+			}
+			// The router only sets the allowed methods when the path exists but the
+			// method does not, so the 405 is not masked by a content type error
+			if _, ok := c.Get(echo.ContextKeyHeaderAllow).(string); ok {
+				return next(c)
+			}
+
+			switch c.Request().Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch:
+				contentType := c.Request().Header.Get(echo.HeaderContentType)
+				if contentType == "" {
+					break
+				}
+				mediaType, _, err := mime.ParseMediaType(contentType)
+				if err != nil || !slices.Contains(jsonMediaTypes, mediaType) {
+					return echo.NewHTTPError(http.StatusBadRequest, "Content-Type must be application/json")
+				}
+			}
+			return next(c)
+		}
+	}
+}
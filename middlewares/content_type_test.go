@@ -0,0 +1,93 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestRequireJSONContentType(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		contentType    string
+		expectedStatus int
+	}{
+		{"JSON", http.MethodPost, "application/json", http.StatusOK},
+		{"JSON with charset", http.MethodPost, "application/json; charset=utf-8", http.StatusOK},
+		{"Mixed case", http.MethodPut, "Application/JSON", http.StatusOK},
+		{"Missing header", http.MethodPatch, "", http.StatusOK},
+		{"JSON Patch", http.MethodPatch, "application/json-patch+json", http.StatusOK},
+		{"Merge patch", http.MethodPatch, "application/merge-patch+json", http.StatusBadRequest},
+		{"Plain text", http.MethodPost, "text/plain", http.StatusBadRequest},
+		{"Form", http.MethodPatch, "application/x-www-form-urlencoded", http.StatusBadRequest},
+		{"Malformed", http.MethodPost, "application/json; charset", http.StatusBadRequest},
+		{"GET is not checked", http.MethodGet, "text/plain", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.Use(RequireJSONContentType())
+			e.Any("/users", func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(tt.method, "/users", strings.NewReader("{}"))
+			if tt.contentType != "" {
+				req.Header.Set(echo.HeaderContentType, tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestRequireJSONContentType_Except(t *testing.T) {
+	e := echo.New()
+	e.Use(RequireJSONContentType("/users/import"))
+	e.POST("/users/import", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	e.POST("/users", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	for path, expected := range map[string]int{
+		"/users/import": http.StatusOK,
+		"/users":        http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader("user_id,email"))
+		req.Header.Set(echo.HeaderContentType, "multipart/form-data; boundary=x")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		if rec.Code != expected {
+			t.Errorf("%s: expected status %d, got %d", path, expected, rec.Code)
+		}
+	}
+}
+
+func TestRequireJSONContentType_MethodNotAllowed(t *testing.T) {
+	e := echo.New()
+	e.Use(RequireJSONContentType())
+	e.GET("/users/:id", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/users/123", strings.NewReader("user_id,email"))
+	req.Header.Set(echo.HeaderContentType, "text/plain")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// DefaultCORSAllowMethods are allowed when no methods are configured
+var DefaultCORSAllowMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+// CORSConfig builds the CORS policy from the CORS_* settings:
+//
+//   - origins, e.g. "https://app.example.com". When empty every origin is allowed
+//     ("*"), which is only appropriate for local development: any website can then
+//     call the API from a browser.
+//   - methods, defaulting to DefaultCORSAllowMethods.
+//   - allowCredentials lets browsers send cookies and auth headers. It is ignored
+//     together with "*", since that would expose credentialed responses to every
+//     origin.
+func CORSConfig(origins, methods []string, allowCredentials bool) middleware.CORSConfig {
+	if len(origins) == 0 {
+		origins = []string{"*"}
+	}
+	if len(methods) == 0 {
+		methods = DefaultCORSAllowMethods
+	}
+
+	if allowCredentials && slices.Contains(origins, "*") {
+		slog.Warn("Ignoring CORS_ALLOW_CREDENTIALS because all origins are allowed; set CORS_ALLOWED_ORIGINS")
+		allowCredentials = false
+	}
+
+	return middleware.CORSConfig{
+		AllowOrigins:     origins,
+		AllowMethods:     methods,
+		AllowCredentials: allowCredentials,
+		// Let browser clients read the headers used for follow-up requests
+		ExposeHeaders: []string{"ETag", echo.HeaderLocation, "Idempotent-Replayed", "Link"},
+	}
+}
+
+// CORS applies the policy from CORSConfig
+func CORS(origins, methods []string, allowCredentials bool) echo.MiddlewareFunc {
+	return middleware.CORSWithConfig(CORSConfig(origins, methods, allowCredentials))
+}
@@ -0,0 +1,74 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestCORSConfig(t *testing.T) {
+	t.Run("Defaults allow all origins", func(t *testing.T) {
+		config := CORSConfig(nil, nil, false)
+		if !slices.Equal(config.AllowOrigins, []string{"*"}) {
+			t.Errorf("Expected origins [*], got %v", config.AllowOrigins)
+		}
+		if !slices.Equal(config.AllowMethods, DefaultCORSAllowMethods) {
+			t.Errorf("Expected default methods, got %v", config.AllowMethods)
+		}
+		if config.AllowCredentials {
+			t.Error("Expected credentials to be disabled by default")
+		}
+	})
+
+	t.Run("Configured values", func(t *testing.T) {
+		config := CORSConfig([]string{"https://app.example.com", "https://admin.example.com"}, []string{"GET", "POST"}, true)
+		if !slices.Equal(config.AllowOrigins, []string{"https://app.example.com", "https://admin.example.com"}) {
+			t.Errorf("Expected configured origins, got %v", config.AllowOrigins)
+		}
+		if !slices.Equal(config.AllowMethods, []string{"GET", "POST"}) {
+			t.Errorf("Expected [GET POST], got %v", config.AllowMethods)
+		}
+		if !config.AllowCredentials {
+			t.Error("Expected credentials to be allowed")
+		}
+	})
+
+	t.Run("Credentials ignored with wildcard origin", func(t *testing.T) {
+		if CORSConfig(nil, nil, true).AllowCredentials {
+			t.Error("Expected credentials to be disabled when all origins are allowed")
+		}
+	})
+}
+
+func TestCORS(t *testing.T) {
+	e := echo.New()
+	e.Use(CORS([]string{"https://app.example.com"}, nil, false))
+	e.GET("/", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	tests := []struct {
+		name           string
+		origin         string
+		expectedHeader string
+	}{
+		{"Allowed origin", "https://app.example.com", "https://app.example.com"},
+		{"Other origin", "https://evil.example.com", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(echo.HeaderOrigin, tt.origin)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get(echo.HeaderAccessControlAllowOrigin); got != tt.expectedHeader {
+				t.Errorf("Expected Access-Control-Allow-Origin '%s', got '%s'", tt.expectedHeader, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package middlewares
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"go-mongodb-test/models"
+
+	"github.com/labstack/echo/v4"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// JSONSchema validates JSON request bodies against schema, a JSON Schema
+// document, before the handler binds them. A body that breaks the schema gets a
+// 400 wrapping models.FieldErrors, which handlers.HTTPErrorHandler renders like
+// the handler validator's failures; one that passes is handed on unchanged.
+// Empty bodies and other media types, such as JSON Patch, are left to the
+// handler. The schema is compiled once, and an invalid one panics at startup.
+func JSONSchema(schema []byte) echo.MiddlewareFunc {
+	compiled := compileSchema(schema)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if req.Body == nil || req.Body == http.NoBody {
+				return next(c)
+			}
+			if contentType := req.Header.Get(echo.HeaderContentType); contentType != "" {
+				mediaType, _, err := mime.ParseMediaType(contentType)
+				if err != nil || mediaType != echo.MIMEApplicationJSON {
+					return next(c)
+				}
+			}
+
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			if len(bytes.TrimSpace(body)) == 0 {
+				return next(c)
+			}
+
+			instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+			}
+
+			if err := compiled.Validate(instance); err != nil {
+				validationErr, ok := err.(*jsonschema.ValidationError)
+				if !ok {
+					return err
+				}
+				return schemaError(schemaFieldErrors(validationErr))
+			}
+			return next(c)
+		}
+	}
+}
+
+// compileSchema compiles a JSON Schema document with format assertions on, so
+// that "format": "email" rejects bad addresses instead of only annotating them
+func compileSchema(schema []byte) *jsonschema.Schema {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(schema))
+	if err != nil {
+		panic(fmt.Sprintf("invalid JSON schema: %v", err))
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.AssertFormat()
+	if err := compiler.AddResource("request.json", doc); err != nil {
+		panic(fmt.Sprintf("invalid JSON schema: %v", err))
+	}
+	compiled, err := compiler.Compile("request.json")
+	if err != nil {
+		panic(fmt.Sprintf("invalid JSON schema: %v", err))
+	}
+	return compiled
+}
+
+// schemaError returns the field errors, ordered by field, as a 400
+func schemaError(details models.FieldErrors) error {
+	sort.SliceStable(details, func(i, j int) bool { return details[i].Field < details[j].Field })
+	return echo.NewHTTPError(http.StatusBadRequest, "Validation failed").SetInternal(details)
+}
+
+// schemaFieldErrors flattens the tree of schema failures into one error per
+// failed rule. Only the leaves say what is wrong; the nodes above them just
+// group their causes.
+func schemaFieldErrors(err *jsonschema.ValidationError) models.FieldErrors {
+	if len(err.Causes) > 0 {
+		var details models.FieldErrors
+		for _, cause := range err.Causes {
+			details = append(details, schemaFieldErrors(cause)...)
+		}
+		return details
+	}
+
+	field := strings.Join(err.InstanceLocation, ".")
+	switch k := err.ErrorKind.(type) {
+	case *kind.Required:
+		details := make(models.FieldErrors, 0, len(k.Missing))
+		for _, missing := range k.Missing {
+			details.Add(joinField(field, missing), "is required")
+		}
+		return details
+	case *kind.AdditionalProperties:
+		details := make(models.FieldErrors, 0, len(k.Properties))
+		for _, property := range k.Properties {
+			details.Add(joinField(field, property), "is not allowed")
+		}
+		return details
+	}
+
+	if field == "" {
+		field = "body"
+	}
+	return models.FieldErrors{{Field: field, Message: schemaErrorMessage(err.ErrorKind)}}
+}
+
+func joinField(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// schemaErrorPrinter renders the library's own messages for rules without one below
+var schemaErrorPrinter = message.NewPrinter(language.English)
+
+// schemaErrorMessage returns a human readable message for a failed rule, worded
+// like the handler validator's
+func schemaErrorMessage(errorKind jsonschema.ErrorKind) string {
+	switch k := errorKind.(type) {
+	case *kind.Type:
+		return "must be " + strings.Join(k.Want, " or ")
+	case *kind.MinLength:
+		return fmt.Sprintf("must be at least %d characters", k.Want)
+	case *kind.MaxLength:
+		return fmt.Sprintf("must be at most %d characters", k.Want)
+	case *kind.Format:
+		if k.Want == "email" {
+			return "must be a valid email address"
+		}
+		return "must be a valid " + k.Want
+	case *kind.Pattern:
+		return "must match " + k.Want
+	default:
+		return errorKind.LocalizedString(schemaErrorPrinter)
+	}
+}
@@ -0,0 +1,154 @@
+package middlewares
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-mongodb-test/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestJSONSchema(t *testing.T) {
+	tests := []struct {
+		name           string
+		schema         []byte
+		contentType    string
+		body           string
+		expectedStatus int
+		expectedErrors map[string]string
+	}{
+		{
+			name:           "Valid create",
+			schema:         models.CreateUserSchema,
+			body:           `{"user_id":"alice","email":"alice@example.com","password":"s3cretpass"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Missing required fields",
+			schema:         models.CreateUserSchema,
+			body:           `{"user_id":"alice"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedErrors: map[string]string{"email": "is required", "password": "is required"},
+		},
+		{
+			name:           "Invalid fields",
+			schema:         models.CreateUserSchema,
+			body:           `{"user_id":"alice","email":"not-an-email","password":"short","first_name":7,"avatar_url":"ftp://example.com/a.png"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedErrors: map[string]string{
+				"email":      "must be a valid email address",
+				"password":   "must be at least 6 characters",
+				"first_name": "must be string",
+				"avatar_url": `must match ^https?://\S+$`,
+			},
+		},
+		{
+			name:           "Replace without every required field",
+			schema:         models.ReplaceUserSchema,
+			body:           `{"user_id":"alice","email":"alice@example.com"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedErrors: map[string]string{"password": "is required"},
+		},
+		{
+			name:           "Valid update clearing a field",
+			schema:         models.UpdateUserSchema,
+			body:           `{"first_name":"Alice","avatar_url":"","last_name":null}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Invalid update",
+			schema:         models.UpdateUserSchema,
+			body:           `{"user_id":"","last_name":"` + strings.Repeat("x", 101) + `"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedErrors: map[string]string{"user_id": "must be at least 1 characters", "last_name": "must be at most 100 characters"},
+		},
+		{
+			name:           "Not an object",
+			schema:         models.UpdateUserSchema,
+			body:           `["alice"]`,
+			expectedStatus: http.StatusBadRequest,
+			expectedErrors: map[string]string{"body": "must be object"},
+		},
+		{
+			name:           "Malformed JSON",
+			schema:         models.UpdateUserSchema,
+			body:           `{"user_id":`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "JSON Patch is left to the handler",
+			schema:         models.UpdateUserSchema,
+			contentType:    "application/json-patch+json",
+			body:           `[{"op":"replace","path":"/first_name","value":"Alice"}]`,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := JSONSchema(tt.schema)(func(c echo.Context) error {
+				body, err := io.ReadAll(c.Request().Body)
+				if err != nil {
+					return err
+				}
+				return c.String(http.StatusOK, string(body))
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(tt.body))
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = echo.MIMEApplicationJSON
+			}
+			req.Header.Set(echo.HeaderContentType, contentType)
+			rec := httptest.NewRecorder()
+			err := handler(echo.New().NewContext(req, rec))
+
+			if tt.expectedStatus == http.StatusOK {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				if rec.Body.String() != tt.body {
+					t.Errorf("Expected handler to read '%s', got '%s'", tt.body, rec.Body.String())
+				}
+				return
+			}
+
+			var httpErr *echo.HTTPError
+			if !errors.As(err, &httpErr) || httpErr.Code != tt.expectedStatus {
+				t.Fatalf("Expected a %d error, got %v", tt.expectedStatus, err)
+			}
+			if tt.expectedErrors == nil {
+				return
+			}
+
+			// handlers.HTTPErrorHandler renders these as a ValidationErrorResponse
+			var fieldErrs models.FieldErrors
+			if !errors.As(err, &fieldErrs) {
+				t.Fatalf("Expected field errors, got %v", err)
+			}
+			fields := fieldErrs.Map()
+			if len(fields) != len(tt.expectedErrors) || len(fieldErrs) != len(tt.expectedErrors) {
+				t.Errorf("Expected errors %v, got %v", tt.expectedErrors, fields)
+			}
+			for field, expected := range tt.expectedErrors {
+				if fields[field] != expected {
+					t.Errorf("Expected %s to fail with '%s', got '%s'", field, expected, fields[field])
+				}
+			}
+		})
+	}
+}
+
+func TestJSONSchema_InvalidSchema(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected an invalid schema to panic")
+		}
+	}()
+	JSONSchema([]byte(`{"type":"nonsense"}`))
+}
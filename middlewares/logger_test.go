@@ -0,0 +1,53 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestRequestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	e := echo.New()
+	e.Use(RequestLogger(logger))
+	e.GET("/users", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set(echo.HeaderXRequestID, "test-request-id")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected a JSON log line, got %q: %v", buf.String(), err)
+	}
+
+	expected := map[string]interface{}{
+		"msg":        "request",
+		"request_id": "test-request-id",
+		"method":     http.MethodGet,
+		"path":       "/users",
+		"status":     float64(http.StatusOK),
+	}
+
+	for key, value := range expected {
+		if entry[key] != value {
+			t.Errorf("Expected %s to be %v, got %v", key, value, entry[key])
+		}
+	}
+
+	for _, key := range []string{"latency", "remote_ip"} {
+		if _, ok := entry[key]; !ok {
+			t.Errorf("Expected log entry to contain %s", key)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"net/http"
+	"slices"
+	"sync/atomic"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Maintenance is the maintenance mode switch. It may be flipped while requests are
+// being served, such as by POST /admin/maintenance.
+type Maintenance struct {
+	enabled atomic.Bool
+}
+
+// NewMaintenance returns a switch that starts out on when enabled is true
+func NewMaintenance(enabled bool) *Maintenance {
+	m := &Maintenance{}
+	m.enabled.Store(enabled)
+	return m
+}
+
+// Enabled reports whether maintenance mode is on
+func (m *Maintenance) Enabled() bool {
+	return m.enabled.Load()
+}
+
+// SetEnabled turns maintenance mode on or off
+func (m *Maintenance) SetEnabled(enabled bool) {
+	m.enabled.Store(enabled)
+}
+
+// MaintenanceMode answers every request other than GET, HEAD and OPTIONS with 503
+// while m is enabled, so that reads and health checks keep working while writes
+// are held off, such as during a migration. Routes whose registered path is listed
+// in except, such as the switch itself, are always let through.
+func MaintenanceMode(m *Maintenance, except ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !m.Enabled() || slices.Contains(except, c.Path()) {
+				return next(c)
+			}
+			// As in RequireJSONContentType, a method the path does not support is
+			// left to the router's 405
+			if _, ok := c.Get(echo.ContextKeyHeaderAllow).(string); ok {
+				return next(c)
+			}
+
+			switch c.Request().Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				return next(c)
+			}
+			return echo.NewHTTPError(http.StatusServiceUnavailable, "Service is in maintenance mode; writes are disabled")
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	maintenance := NewMaintenance(true)
+
+	e := echo.New()
+	e.Use(MaintenanceMode(maintenance, "/admin/maintenance"))
+	handler := func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}
+	e.GET("/users", handler)
+	e.POST("/users", handler)
+	e.DELETE("/users/:id", handler)
+	e.POST("/admin/maintenance", handler)
+
+	tests := []struct {
+		name           string
+		enabled        bool
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{"Read", true, http.MethodGet, "/users", http.StatusOK},
+		{"Write", true, http.MethodPost, "/users", http.StatusServiceUnavailable},
+		{"Delete", true, http.MethodDelete, "/users/1", http.StatusServiceUnavailable},
+		{"Excepted path", true, http.MethodPost, "/admin/maintenance", http.StatusOK},
+		{"Unsupported method", true, http.MethodPut, "/users", http.StatusMethodNotAllowed},
+		{"Write when off", false, http.MethodPost, "/users", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maintenance.SetEnabled(tt.enabled)
+			if maintenance.Enabled() != tt.enabled {
+				t.Fatalf("Expected the switch to be %v", tt.enabled)
+			}
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+		})
+	}
+}
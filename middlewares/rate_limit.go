@@ -0,0 +1,39 @@
+package middlewares
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"golang.org/x/time/rate"
+)
+
+// RateLimit limits each client IP to requestsPerMinute requests, answering 429 with
+// a Retry-After header once the budget is spent
+func RateLimit(requestsPerMinute int) echo.MiddlewareFunc {
+	// Seconds until the next token is refilled
+	retryAfter := strconv.Itoa(int(math.Ceil(60 / float64(requestsPerMinute))))
+
+	store := middleware.NewRateLimiterMemoryStoreWithConfig(middleware.RateLimiterMemoryStoreConfig{
+		Rate:      rate.Limit(float64(requestsPerMinute) / 60),
+		Burst:     requestsPerMinute,
+		ExpiresIn: 3 * time.Minute,
+	})
+
+	return middleware.RateLimiterWithConfig(middleware.RateLimiterConfig{
+		Store: store,
+		IdentifierExtractor: func(c echo.Context) (string, error) {
+			return c.RealIP(), nil
+		},
+		ErrorHandler: func(c echo.Context, err error) error {
+			return echo.NewHTTPError(http.StatusForbidden, "Unable to identify client")
+		},
+		DenyHandler: func(c echo.Context, identifier string, err error) error {
+			c.Response().Header().Set("Retry-After", retryAfter)
+			return echo.NewHTTPError(http.StatusTooManyRequests, "Rate limit exceeded")
+		},
+	})
+}
@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestRateLimit(t *testing.T) {
+	e := echo.New()
+	e.POST("/limited", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, RateLimit(2))
+
+	doRequest := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/limited", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := doRequest("10.0.0.1:1234"); rec.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected status %d, got %d", i+1, http.StatusOK, rec.Code)
+		}
+	}
+
+	rec := doRequest("10.0.0.1:1234")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status %d, got %d", http.StatusTooManyRequests, rec.Code)
+	}
+
+	if rec.Header().Get("Retry-After") != "30" {
+		t.Errorf("Expected Retry-After '30', got '%s'", rec.Header().Get("Retry-After"))
+	}
+
+	// A different client has its own budget
+	if rec := doRequest("10.0.0.2:1234"); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d for another IP, got %d", http.StatusOK, rec.Code)
+	}
+}
@@ -0,0 +1,39 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// IPExtractor decides which address c.RealIP reports, and so which client rate
+// limits and request logs see. Without trusted proxies it is always the direct
+// peer, since anyone can send a forwarding header. With them, X-Forwarded-For is
+// read from the right, skipping trusted hops, and X-Real-IP is used when a trusted
+// proxy sends only that; a header from any other peer is ignored.
+func IPExtractor(trustedProxies []*net.IPNet) echo.IPExtractor {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	// Echo trusts loopback, link-local and private addresses by default, which
+	// would let any host on the same network spoof its address
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, network := range trustedProxies {
+		options = append(options, echo.TrustIPRange(network))
+	}
+
+	fromXFF := echo.ExtractIPFromXFFHeader(options...)
+	fromRealIP := echo.ExtractIPFromRealIPHeader(options...)
+	return func(req *http.Request) string {
+		if req.Header.Get(echo.HeaderXForwardedFor) == "" && req.Header.Get(echo.HeaderXRealIP) != "" {
+			return fromRealIP(req)
+		}
+		return fromXFF(req)
+	}
+}
@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestIPExtractor(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+
+	tests := []struct {
+		name       string
+		trusted    []*net.IPNet
+		remoteAddr string
+		headers    map[string]string
+		expected   string
+	}{
+		{"No proxies ignores X-Forwarded-For", nil, "10.1.2.3:4567", map[string]string{echo.HeaderXForwardedFor: "203.0.113.7"}, "10.1.2.3"},
+		{"No proxies ignores X-Real-IP", nil, "10.1.2.3:4567", map[string]string{echo.HeaderXRealIP: "203.0.113.7"}, "10.1.2.3"},
+		{"Trusted proxy", []*net.IPNet{proxies}, "10.1.2.3:4567", map[string]string{echo.HeaderXForwardedFor: "203.0.113.7"}, "203.0.113.7"},
+		{"Trusted proxy chain", []*net.IPNet{proxies}, "10.1.2.3:4567", map[string]string{echo.HeaderXForwardedFor: "198.51.100.1, 203.0.113.7, 10.9.9.9"}, "203.0.113.7"},
+		{"Trusted proxy with X-Real-IP", []*net.IPNet{proxies}, "10.1.2.3:4567", map[string]string{echo.HeaderXRealIP: "203.0.113.7"}, "203.0.113.7"},
+		{"Untrusted peer", []*net.IPNet{proxies}, "192.168.0.5:4567", map[string]string{echo.HeaderXForwardedFor: "203.0.113.7"}, "192.168.0.5"},
+		{"Loopback is not trusted implicitly", []*net.IPNet{proxies}, "127.0.0.1:4567", map[string]string{echo.HeaderXForwardedFor: "203.0.113.7"}, "127.0.0.1"},
+		{"Trusted proxy without headers", []*net.IPNet{proxies}, "10.1.2.3:4567", nil, "10.1.2.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+
+			if got := IPExtractor(tt.trusted)(req); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
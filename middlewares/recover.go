@@ -0,0 +1,36 @@
+package middlewares
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// Recover turns a panic in a handler into a 500 with the same JSON error body the
+// handlers use, tagged with the request ID. The panic and its stack are logged, but
+// never sent to the client.
+func Recover(logger *slog.Logger) echo.MiddlewareFunc {
+	return middleware.RecoverWithConfig(middleware.RecoverConfig{
+		LogErrorFunc: func(c echo.Context, err error, stack []byte) error {
+			requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+			logger.ErrorContext(c.Request().Context(), "Recovered from panic",
+				"error", err,
+				"request_id", requestID,
+				"stack", string(stack),
+			)
+
+			// A handler that panicked after writing its response cannot be answered again
+			if c.Response().Committed {
+				return nil
+			}
+
+			body := map[string]string{"error": "Internal server error"}
+			if requestID != "" {
+				body["request_id"] = requestID
+			}
+			return c.JSON(http.StatusInternalServerError, body)
+		},
+	})
+}
@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+func TestRecover(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	e := echo.New()
+	e.Use(middleware.RequestID())
+	e.Use(Recover(logger))
+	e.GET("/panic", func(c echo.Context) error {
+		panic("secret detail")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	req.Header.Set(echo.HeaderXRequestID, "test-request-id")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+	}
+
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Expected a JSON body, got %q: %v", rec.Body.String(), err)
+	}
+	if body["error"] != "Internal server error" || body["request_id"] != "test-request-id" {
+		t.Errorf("Unexpected body %v", body)
+	}
+	if strings.Contains(rec.Body.String(), "secret detail") {
+		t.Error("Expected the panic value to stay out of the response")
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected a JSON log line, got %q: %v", buf.String(), err)
+	}
+	if entry["request_id"] != "test-request-id" || !strings.Contains(entry["error"].(string), "secret detail") {
+		t.Errorf("Unexpected log entry %v", entry)
+	}
+	if stack, _ := entry["stack"].(string); !strings.Contains(stack, "goroutine") {
+		t.Errorf("Expected the stack to be logged, got %q", stack)
+	}
+}
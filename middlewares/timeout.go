@@ -0,0 +1,35 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Timeout attaches a deadline to each request's context so that database calls made
+// with c.Request().Context() are cancelled once it passes. A handler that returns
+// the deadline error before writing a response is answered with 504. Routes whose
+// registered path is listed in except, such as event streams, get no deadline.
+func Timeout(timeout time.Duration, except ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if slices.Contains(except, c.Path()) {
+				return next(c)
+			}
+
+			ctx, cancel := context.WithTimeout(c.Request().Context(), timeout)
+			defer cancel()
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			err := next(c)
+			if err != nil && errors.Is(err, context.DeadlineExceeded) && !c.Response().Committed {
+				return echo.NewHTTPError(http.StatusGatewayTimeout, "Request timed out")
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestTimeout(t *testing.T) {
+	e := echo.New()
+	e.Use(Timeout(10 * time.Millisecond))
+	e.GET("/slow", func(c echo.Context) error {
+		<-c.Request().Context().Done()
+		return c.Request().Context().Err()
+	})
+	e.GET("/fast", func(c echo.Context) error {
+		if _, ok := c.Request().Context().Deadline(); !ok {
+			t.Error("Expected request context to have a deadline")
+		}
+		return c.NoContent(http.StatusOK)
+	})
+
+	tests := []struct {
+		path           string
+		expectedStatus int
+	}{
+		{"/slow", http.StatusGatewayTimeout},
+		{"/fast", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestTimeout_PassesOtherErrors(t *testing.T) {
+	e := echo.New()
+	handler := Timeout(time.Second)(func(c echo.Context) error {
+		return context.Canceled
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	c := e.NewContext(req, httptest.NewRecorder())
+	if err := handler(c); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestTimeout_Except(t *testing.T) {
+	e := echo.New()
+	e.Use(Timeout(10*time.Millisecond, "/stream"))
+	e.GET("/stream", func(c echo.Context) error {
+		if _, ok := c.Request().Context().Deadline(); ok {
+			t.Error("Expected an exempt route to have no deadline")
+		}
+		return c.NoContent(http.StatusOK)
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+}
@@ -0,0 +1,73 @@
+// Package migrations brings existing documents up to date with the current schema.
+// Each migration runs once per database; the IDs of applied ones are kept in the
+// schema_migrations collection.
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Collection records the migrations applied to a database
+const Collection = "schema_migrations"
+
+// Migration is one change to existing documents. Two instances starting together
+// may both run a pending migration, so Up must be safe to run more than once.
+type Migration struct {
+	// ID orders the migrations and marks one as applied; it must never change
+	ID string
+	Up func(ctx context.Context, db *mongo.Database) error
+}
+
+// appliedMigration is the schema_migrations document recording a migration
+type appliedMigration struct {
+	ID        string    `bson:"_id"`
+	AppliedAt time.Time `bson:"applied_at"`
+}
+
+// Run applies, in order, the migrations that schema_migrations does not list yet,
+// and returns the IDs of those it applied. It stops at the first failure, leaving
+// that migration and the ones after it pending.
+func Run(ctx context.Context, db *mongo.Database, migrations []Migration) ([]string, error) {
+	collection := db.Collection(Collection)
+
+	cursor, err := collection.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	var records []appliedMigration
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	applied := make(map[string]bool, len(records))
+	for _, record := range records {
+		applied[record.ID] = true
+	}
+
+	var ran []string
+	for _, migration := range migrations {
+		if applied[migration.ID] {
+			continue
+		}
+
+		slog.InfoContext(ctx, "Applying migration", "id", migration.ID)
+		if err := migration.Up(ctx, db); err != nil {
+			return ran, fmt.Errorf("migration %s failed: %w", migration.ID, err)
+		}
+
+		// Another instance that ran the same migration may have recorded it first
+		_, err := collection.InsertOne(ctx, appliedMigration{ID: migration.ID, AppliedAt: time.Now().UTC()})
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return ran, fmt.Errorf("failed to record migration %s: %w", migration.ID, err)
+		}
+		ran = append(ran, migration.ID)
+	}
+
+	return ran, nil
+}
@@ -0,0 +1,145 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go-mongodb-test/models"
+
+	v1bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// recording returns migrations that only note that they ran
+func recording(ran *[]string, ids ...string) []Migration {
+	var migrations []Migration
+	for _, id := range ids {
+		migrations = append(migrations, Migration{ID: id, Up: func(ctx context.Context, db *mongo.Database) error {
+			*ran = append(*ran, id)
+			return nil
+		}})
+	}
+	return migrations
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	inserted := mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 1})
+	applied := func(ids ...string) v1bson.D {
+		var docs []v1bson.D
+		for _, id := range ids {
+			docs = append(docs, v1bson.D{{Key: "_id", Value: id}})
+		}
+		return mtest.CreateCursorResponse(0, "test."+Collection, mtest.FirstBatch, docs...)
+	}
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("applies pending migrations in order", func(mt *mtest.T) {
+		mt.AddMockResponses(applied("0001_first"), inserted, inserted)
+
+		var ran []string
+		result, err := Run(ctx, mt.DB, recording(&ran, "0001_first", "0002_second", "0003_third"))
+		if err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		if expected := []string{"0002_second", "0003_third"}; !reflect.DeepEqual(ran, expected) || !reflect.DeepEqual(result, expected) {
+			mt.Errorf("Expected %v to run, got %v (returned %v)", expected, ran, result)
+		}
+
+		mt.GetStartedEvent() // find
+		record := mt.GetStartedEvent()
+		if record == nil || record.CommandName != "insert" {
+			mt.Fatalf("Expected the migration to be recorded, got %v", record)
+		}
+		if id := record.Command.Lookup("documents").Array().Index(0).Value().Document().Lookup("_id").StringValue(); id != "0002_second" {
+			mt.Errorf("Expected 0002_second to be recorded, got %s", id)
+		}
+	})
+
+	mt.Run("nothing pending", func(mt *mtest.T) {
+		mt.AddMockResponses(applied("0001_first"))
+
+		var ran []string
+		result, err := Run(ctx, mt.DB, recording(&ran, "0001_first"))
+		if err != nil || len(ran) != 0 || len(result) != 0 {
+			mt.Errorf("Expected nothing to run, got %v (error: %v)", ran, err)
+		}
+	})
+
+	mt.Run("stops at a failure", func(mt *mtest.T) {
+		mt.AddMockResponses(applied())
+
+		var ran []string
+		migrations := append([]Migration{{ID: "0001_broken", Up: func(ctx context.Context, db *mongo.Database) error {
+			return errors.New("boom")
+		}}}, recording(&ran, "0002_second")...)
+
+		if _, err := Run(ctx, mt.DB, migrations); err == nil {
+			mt.Error("Expected the failure to be returned")
+		}
+		if len(ran) != 0 {
+			mt.Errorf("Expected later migrations to stay pending, got %v", ran)
+		}
+		mt.GetStartedEvent() // find
+		if event := mt.GetStartedEvent(); event != nil {
+			mt.Errorf("Expected the failed migration not to be recorded, got %s", event.CommandName)
+		}
+	})
+
+	mt.Run("recorded by another instance", func(mt *mtest.T) {
+		mt.AddMockResponses(applied(), mtest.CreateWriteErrorsResponse(mtest.WriteError{
+			Index:   0,
+			Code:    11000,
+			Message: "E11000 duplicate key error collection: test.schema_migrations index: _id_",
+		}))
+
+		var ran []string
+		if _, err := Run(ctx, mt.DB, recording(&ran, "0001_first")); err != nil {
+			mt.Errorf("Expected a duplicate record to be ignored, got %v", err)
+		}
+	})
+}
+
+func TestSetDefaultStatusAndRoles(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("sets status and roles where missing", func(mt *mtest.T) {
+		updated := mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 2}, v1bson.E{Key: "nModified", Value: 2})
+		mt.AddMockResponses(updated, updated)
+
+		if err := setDefaultStatusAndRoles(context.Background(), mt.DB); err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+
+		status := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		if exists, ok := status.Lookup("q", "status", "$exists").BooleanOK(); !ok || exists {
+			mt.Errorf("Expected only users without a status to match, got %v", status)
+		}
+		if value := status.Lookup("u", "$set", "status").StringValue(); value != models.StatusActive {
+			mt.Errorf("Expected status %s, got %s", models.StatusActive, value)
+		}
+
+		roles := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		values, err := roles.Lookup("u", "$set", "roles").Array().Values()
+		if err != nil || len(values) != 1 || values[0].StringValue() != models.RoleUser {
+			mt.Errorf("Expected the default roles, got %v", roles)
+		}
+		if multi, _ := roles.Lookup("multi").BooleanOK(); !multi {
+			mt.Errorf("Expected every matching user to be updated, got %v", roles)
+		}
+	})
+}
+
+func TestAll_UniqueIDs(t *testing.T) {
+	seen := map[string]bool{}
+	for _, migration := range All {
+		if migration.ID == "" || seen[migration.ID] {
+			t.Errorf("Expected a unique, non-empty ID, got %q", migration.ID)
+		}
+		seen[migration.ID] = true
+	}
+}
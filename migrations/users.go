@@ -0,0 +1,59 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"go-mongodb-test/models"
+	"go-mongodb-test/services"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// usersCollection is the collection the user migrations work on
+const usersCollection = "users"
+
+// All lists every migration in the order they are applied. New migrations go at
+// the end, and applied ones are never edited or removed.
+var All = []Migration{
+	{ID: "0001_user_status_and_roles", Up: setDefaultStatusAndRoles},
+	{ID: "0002_user_email_domain", Up: backfillEmailDomains},
+}
+
+// setDefaultStatusAndRoles gives users stored before status and roles existed the
+// values a new signup gets. Reads already treat a missing status as active; storing
+// it lets queries match on status directly.
+func setDefaultStatusAndRoles(ctx context.Context, db *mongo.Database) error {
+	users := db.Collection(usersCollection)
+
+	_, err := users.UpdateMany(
+		ctx,
+		bson.M{"status": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"status": models.StatusActive}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set default status: %w", err)
+	}
+
+	// Matching null also matches a missing field; an empty array grants nothing either
+	_, err = users.UpdateMany(
+		ctx,
+		bson.M{"$or": bson.A{
+			bson.M{"roles": nil},
+			bson.M{"roles": bson.M{"$size": 0}},
+		}},
+		bson.M{"$set": bson.M{"roles": models.DefaultRoles()}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set default roles: %w", err)
+	}
+
+	return nil
+}
+
+// backfillEmailDomains stores email_domain on users created before the field existed
+func backfillEmailDomains(ctx context.Context, db *mongo.Database) error {
+	_, err := services.NewUserService(db).BackfillEmailDomains(ctx)
+	return err
+}
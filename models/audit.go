@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Audit actions recorded for a user
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+// AuditEvent records one change to a user: what was done, which fields it touched
+// and, when the request was authenticated, who did it. Only field names are kept,
+// never their values.
+type AuditEvent struct {
+	ID bson.ObjectID `json:"id" bson:"_id,omitempty" swaggertype:"string" example:"665f1c2e8b3a4d2f9c1e7a11"`
+	// TargetID is the MongoDB ID of the user that changed
+	TargetID bson.ObjectID `json:"target_id" bson:"target_id" swaggertype:"string" example:"665f1c2e8b3a4d2f9c1e7a10"`
+	Action   string        `json:"action" bson:"action" enums:"create,update,delete" example:"update"`
+	Fields   []string      `json:"fields,omitempty" bson:"fields,omitempty" example:"email,password"`
+	// ActorID and ActorUserID name the authenticated user who made the change; they
+	// are empty for signups and other unauthenticated requests
+	ActorID     string    `json:"actor_id,omitempty" bson:"actor_id,omitempty" example:"665f1c2e8b3a4d2f9c1e7a0f"`
+	ActorUserID string    `json:"actor_user_id,omitempty" bson:"actor_user_id,omitempty" example:"admin"`
+	CreatedAt   time.Time `json:"created_at" bson:"created_at" example:"2024-06-01T12:00:00Z"`
+}
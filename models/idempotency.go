@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// IdempotencyKey records the user a signup with an Idempotency-Key header created,
+// so that a retry with the same key gets that user back instead of a second signup
+type IdempotencyKey struct {
+	Key string `bson:"_id"`
+	// RequestHash identifies the body the key was first used with
+	RequestHash string        `bson:"request_hash"`
+	UserID      bson.ObjectID `bson:"user_id"`
+	CreatedAt   time.Time     `bson:"created_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+// IndexInfo describes one index on the users collection
+type IndexInfo struct {
+	Name   string     `json:"name" example:"user_id_1"`
+	Keys   []IndexKey `json:"keys"`
+	Unique bool       `json:"unique" example:"true"`
+	Sparse bool       `json:"sparse" example:"false"`
+}
+
+// IndexKey is one field of an index key spec, in index order. Order is 1 or -1
+// for ascending and descending keys, or the index type such as "text".
+type IndexKey struct {
+	Field string      `json:"field" example:"user_id"`
+	Order interface{} `json:"order" swaggertype:"integer" example:"1"`
+}
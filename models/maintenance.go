@@ -0,0 +1,6 @@
+package models
+
+// MaintenanceRequest is the body of POST /admin/maintenance
+type MaintenanceRequest struct {
+	Enabled *bool `json:"enabled" validate:"required" example:"true"`
+}
@@ -0,0 +1,202 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hashing algorithms, as named by PASSWORD_HASH_ALGO
+const (
+	PasswordHashBcrypt   = "bcrypt"
+	PasswordHashArgon2id = "argon2id"
+)
+
+// PasswordHashAlgorithms are the accepted PASSWORD_HASH_ALGO values
+var PasswordHashAlgorithms = []string{PasswordHashBcrypt, PasswordHashArgon2id}
+
+// PasswordHasher hashes passwords into a self-describing string and verifies
+// passwords against the hashes it produced
+type PasswordHasher interface {
+	// Algorithm is the PasswordHashAlgorithms name of the hasher
+	Algorithm() string
+	Hash(password string) (string, error)
+	// Verify reports whether password matches hash
+	Verify(hash, password string) bool
+	// Owns reports whether hash was produced by this hasher, judged by its prefix
+	Owns(hash string) bool
+	// Outdated reports whether hash, which this hasher owns, was made with weaker
+	// parameters than new hashes get
+	Outdated(hash string) bool
+}
+
+// bcryptHasher hashes with bcrypt at GetBcryptCost. Its hashes start with $2a$,
+// $2b$ or $2y$. It rejects passwords over 72 bytes with bcrypt.ErrPasswordTooLong,
+// so request validation caps passwords at that length.
+type bcryptHasher struct{}
+
+func (bcryptHasher) Algorithm() string { return PasswordHashBcrypt }
+
+func (bcryptHasher) Hash(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), GetBcryptCost())
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+func (bcryptHasher) Verify(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+func (bcryptHasher) Owns(hash string) bool {
+	return strings.HasPrefix(hash, "$2")
+}
+
+// Outdated reports hashes below the current BCRYPT_COST. Hashes above it are kept,
+// so lowering the cost does not weaken existing hashes.
+func (bcryptHasher) Outdated(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err == nil && cost < GetBcryptCost()
+}
+
+// argon2id parameters for new hashes, following the second recommendation of
+// RFC 9106 for memory-constrained servers. Hashes carry their own parameters, so
+// changing these later leaves existing hashes verifiable.
+const (
+	argon2idTime    = 3
+	argon2idMemory  = 64 * 1024
+	argon2idThreads = 4
+	argon2idSaltLen = 16
+	argon2idKeyLen  = 32
+)
+
+// argon2idPrefix starts every argon2id hash, in the PHC string format
+const argon2idPrefix = "$argon2id$"
+
+// argon2idHasher hashes with argon2id into $argon2id$v=19$m=...,t=...,p=...$salt$key
+type argon2idHasher struct{}
+
+func (argon2idHasher) Algorithm() string { return PasswordHashArgon2id }
+
+func (argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2idSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, argon2idTime, argon2idMemory, argon2idThreads, argon2idKeyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version,
+		argon2idMemory, argon2idTime, argon2idThreads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// argon2idHash is a parsed argon2id hash
+type argon2idHash struct {
+	memory, iterations uint32
+	threads            uint8
+	salt, key          []byte
+}
+
+// parseArgon2idHash splits hash into its parameters, salt and key
+func parseArgon2idHash(hash string) (*argon2idHash, bool) {
+	// "", "argon2id", "v=19", "m=65536,t=3,p=4", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return nil, false
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, false
+	}
+	var parsed argon2idHash
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &parsed.memory, &parsed.iterations, &parsed.threads); err != nil || parsed.iterations == 0 || parsed.threads == 0 {
+		return nil, false
+	}
+	var err error
+	if parsed.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, false
+	}
+	if parsed.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(parsed.key) == 0 {
+		return nil, false
+	}
+	return &parsed, true
+}
+
+func (argon2idHasher) Verify(hash, password string) bool {
+	parsed, ok := parseArgon2idHash(hash)
+	if !ok {
+		return false
+	}
+	computed := argon2.IDKey([]byte(password), parsed.salt, parsed.iterations, parsed.memory, parsed.threads, uint32(len(parsed.key)))
+	return subtle.ConstantTimeCompare(computed, parsed.key) == 1
+}
+
+func (argon2idHasher) Owns(hash string) bool {
+	return strings.HasPrefix(hash, argon2idPrefix)
+}
+
+// Outdated reports hashes made with less memory or fewer iterations than new ones
+func (argon2idHasher) Outdated(hash string) bool {
+	parsed, ok := parseArgon2idHash(hash)
+	return ok && (parsed.memory < argon2idMemory || parsed.iterations < argon2idTime)
+}
+
+// passwordHashers are the known hashers, tried in order to find the owner of a hash
+var passwordHashers = []PasswordHasher{bcryptHasher{}, argon2idHasher{}}
+
+// configuredPasswordHasher hashes new passwords; it is set once at startup by
+// SetPasswordHashAlgorithm
+var configuredPasswordHasher PasswordHasher = bcryptHasher{}
+
+// SetPasswordHashAlgorithm selects the hasher for new passwords by its
+// PasswordHashAlgorithms name. Passwords hashed by the others keep verifying.
+func SetPasswordHashAlgorithm(algorithm string) error {
+	i := slices.IndexFunc(passwordHashers, func(h PasswordHasher) bool { return h.Algorithm() == algorithm })
+	if i < 0 {
+		return fmt.Errorf("unknown password hash algorithm %q", algorithm)
+	}
+	configuredPasswordHasher = passwordHashers[i]
+	return nil
+}
+
+// passwordHasherOf returns the hasher that produced hash, or nil when none did
+func passwordHasherOf(hash string) PasswordHasher {
+	for _, hasher := range passwordHashers {
+		if hasher.Owns(hash) {
+			return hasher
+		}
+	}
+	return nil
+}
+
+func (u *User) HashPassword(password string) error {
+	hashedPassword, err := configuredPasswordHasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	u.Password = hashedPassword
+	return nil
+}
+
+// CheckPassword verifies password with whichever hasher produced the stored hash
+func (u *User) CheckPassword(password string) bool {
+	hasher := passwordHasherOf(u.Password)
+	return hasher != nil && hasher.Verify(u.Password, password)
+}
+
+// NeedsRehash reports whether the stored hash was made by another algorithm than
+// the configured one, or with weaker parameters such as a lower bcrypt cost, so
+// that it can be replaced once the password is known again
+func (u *User) NeedsRehash() bool {
+	hasher := passwordHasherOf(u.Password)
+	if hasher == nil {
+		return false
+	}
+	return hasher.Algorithm() != configuredPasswordHasher.Algorithm() || hasher.Outdated(u.Password)
+}
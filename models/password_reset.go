@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// PasswordResetToken is a pending reset; only the SHA-256 hash of the token is stored
+type PasswordResetToken struct {
+	ID        bson.ObjectID `bson:"_id,omitempty"`
+	TokenHash string        `bson:"token_hash"`
+	UserID    bson.ObjectID `bson:"user_id"`
+	ExpiresAt time.Time     `bson:"expires_at"`
+	CreatedAt time.Time     `bson:"created_at"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email" example:"alice@example.com"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required" example:"Zk9yZ290UGFzc3dvcmRUb2tlbkV4YW1wbGUxMjM0NTY"`
+	NewPassword string `json:"new_password" validate:"required,min=6,maxbytes=72,notcommon" example:"n3wpassword"`
+}
+
+// AdminResetPasswordRequest is the optional body of an admin password reset; without
+// a new password the server generates one
+type AdminResetPasswordRequest struct {
+	NewPassword string `json:"new_password,omitempty" validate:"omitempty,min=6,maxbytes=72,notcommon" example:"n3wpassword"`
+}
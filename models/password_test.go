@@ -0,0 +1,111 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSetPasswordHashAlgorithm(t *testing.T) {
+	defer SetPasswordHashAlgorithm(PasswordHashBcrypt)
+
+	if err := SetPasswordHashAlgorithm("md5"); err == nil {
+		t.Error("Expected an error for an unknown algorithm")
+	}
+
+	tests := []struct {
+		algorithm string
+		prefix    string
+	}{
+		{PasswordHashBcrypt, "$2a$"},
+		{PasswordHashArgon2id, "$argon2id$v=19$m=65536,t=3,p=4$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			if err := SetPasswordHashAlgorithm(tt.algorithm); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			user := &User{}
+			if err := user.HashPassword("testpassword123"); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !strings.HasPrefix(user.Password, tt.prefix) {
+				t.Errorf("Expected a hash starting with %s, got %s", tt.prefix, user.Password)
+			}
+			if !user.CheckPassword("testpassword123") || user.CheckPassword("wrongpassword") {
+				t.Error("Expected only the hashed password to verify")
+			}
+			if user.NeedsRehash() {
+				t.Error("Expected a hash of the configured algorithm to need no rehash")
+			}
+		})
+	}
+}
+
+func TestCheckPassword_AcrossAlgorithms(t *testing.T) {
+	defer SetPasswordHashAlgorithm(PasswordHashBcrypt)
+
+	// Longer than the 72 bytes bcrypt reads
+	password := strings.Repeat("long passphrase ", 6)
+	bcryptUser, argon2idUser := &User{}, &User{}
+	if err := bcryptUser.HashPassword("testpassword123"); err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	SetPasswordHashAlgorithm(PasswordHashArgon2id)
+	if err := argon2idUser.HashPassword(password); err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+
+	if !bcryptUser.CheckPassword("testpassword123") || !bcryptUser.NeedsRehash() {
+		t.Error("Expected a bcrypt hash to keep verifying and to need a rehash under argon2id")
+	}
+	if !argon2idUser.CheckPassword(password) || argon2idUser.CheckPassword(password[:72]) {
+		t.Error("Expected argon2id to verify the whole password")
+	}
+
+	for _, hash := range []string{"", "plaintext", "$argon2id$v=19$m=65536,t=3,p=4$bad", "$argon2id$v=18$m=65536,t=3,p=4$c2FsdA$a2V5"} {
+		user := &User{Password: hash}
+		if user.CheckPassword("plaintext") || user.NeedsRehash() {
+			t.Errorf("Expected %q to neither verify nor need a rehash", hash)
+		}
+	}
+}
+
+func TestNeedsRehash_Parameters(t *testing.T) {
+	setBcryptCost(t, 5)
+	user := &User{}
+	if err := user.HashPassword("testpassword123"); err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+
+	tests := []struct {
+		cost     int
+		expected bool
+	}{
+		{5, false},
+		{6, true},
+		// A lower target leaves the stronger hash in place
+		{4, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("cost %d", tt.cost), func(t *testing.T) {
+			setBcryptCost(t, tt.cost)
+			if got := user.NeedsRehash(); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	t.Run("argon2id", func(t *testing.T) {
+		weaker := &User{Password: "$argon2id$v=19$m=19456,t=2,p=1$c2FsdHNhbHRzYWx0$a2V5a2V5a2V5a2V5"}
+		current := &User{Password: "$argon2id$v=19$m=65536,t=3,p=4$c2FsdHNhbHRzYWx0$a2V5a2V5a2V5a2V5"}
+		SetPasswordHashAlgorithm(PasswordHashArgon2id)
+		defer SetPasswordHashAlgorithm(PasswordHashBcrypt)
+		if !weaker.NeedsRehash() || current.NeedsRehash() {
+			t.Error("Expected only the hash with weaker parameters to need a rehash")
+		}
+	})
+}
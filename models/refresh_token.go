@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// RefreshToken is an outstanding refresh token; only the SHA-256 hash of the token is stored.
+// Every token rotated from one login carries the same session, which lives for as
+// long as one of them is outstanding.
+type RefreshToken struct {
+	ID        bson.ObjectID `bson:"_id,omitempty"`
+	TokenHash string        `bson:"token_hash"`
+	UserID    bson.ObjectID `bson:"user_id"`
+	ExpiresAt time.Time     `bson:"expires_at"`
+	CreatedAt time.Time     `bson:"created_at"`
+	// SessionID and StartedAt are unset on tokens issued before sessions were
+	// tracked; SessionKey and SessionStart fall back to the token's own
+	SessionID     bson.ObjectID `bson:"session_id,omitempty"`
+	StartedAt     time.Time     `bson:"started_at,omitempty"`
+	SessionClient `bson:",inline"`
+}
+
+// SessionKey returns the ID of the session the token belongs to
+func (t *RefreshToken) SessionKey() bson.ObjectID {
+	if t.SessionID.IsZero() {
+		return t.ID
+	}
+	return t.SessionID
+}
+
+// SessionStart returns when the token's session logged in
+func (t *RefreshToken) SessionStart() time.Time {
+	if t.StartedAt.IsZero() {
+		return t.CreatedAt
+	}
+	return t.StartedAt
+}
+
+// SessionClient describes the client a session was started from, as reported by
+// its own headers
+type SessionClient struct {
+	UserAgent string `bson:"user_agent,omitempty" json:"user_agent,omitempty" example:"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_5)"`
+	IP        string `bson:"ip,omitempty" json:"ip,omitempty" example:"203.0.113.7"`
+}
+
+// IssuedRefreshToken is a newly issued refresh token and the session it belongs to
+type IssuedRefreshToken struct {
+	Token     string
+	SessionID string
+}
+
+// Session is an active login as shown to its user. LastUsedAt is when its current
+// refresh token was issued, at login or the latest refresh.
+type Session struct {
+	ID string `json:"id" example:"665f1c2e8b3a4d2f9c1e7a10"`
+	SessionClient
+	CreatedAt  time.Time `json:"created_at" example:"2024-06-01T12:00:00Z"`
+	LastUsedAt time.Time `json:"last_used_at" example:"2024-06-03T08:30:00Z"`
+	ExpiresAt  time.Time `json:"expires_at" example:"2024-07-03T08:30:00Z"`
+	// Current marks the session of the access token making the request
+	Current bool `json:"current" example:"true"`
+}
+
+// NewSession describes the session of token, marking it current when its ID is currentID
+func NewSession(token *RefreshToken, currentID string) *Session {
+	id := token.SessionKey().Hex()
+	return &Session{
+		ID:            id,
+		SessionClient: token.SessionClient,
+		CreatedAt:     token.SessionStart(),
+		LastUsedAt:    token.CreatedAt,
+		ExpiresAt:     token.ExpiresAt,
+		Current:       currentID != "" && id == currentID,
+	}
+}
+
+// RefreshTokenRequest is the body of a refresh or logout
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required" example:"cmVmcmVzaFRva2VuRXhhbXBsZUFiY2RlZmdoaWprbG1u"`
+}
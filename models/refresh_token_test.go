@@ -0,0 +1,49 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestNewSession(t *testing.T) {
+	sessionID := bson.NewObjectID()
+	started := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	issued := time.Date(2024, 6, 3, 8, 30, 0, 0, time.UTC)
+
+	tracked := &RefreshToken{
+		ID:            bson.NewObjectID(),
+		CreatedAt:     issued,
+		SessionID:     sessionID,
+		StartedAt:     started,
+		SessionClient: SessionClient{UserAgent: "curl/8.5.0", IP: "203.0.113.7"},
+	}
+	// Issued before sessions were tracked
+	untracked := &RefreshToken{ID: bson.NewObjectID(), CreatedAt: issued}
+
+	tests := []struct {
+		name            string
+		token           *RefreshToken
+		currentID       string
+		expectedID      string
+		expectedCreated time.Time
+		expectedCurrent bool
+	}{
+		{"Current", tracked, sessionID.Hex(), sessionID.Hex(), started, true},
+		{"Another", tracked, bson.NewObjectID().Hex(), sessionID.Hex(), started, false},
+		{"Untracked", untracked, "", untracked.ID.Hex(), issued, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := NewSession(tt.token, tt.currentID)
+			if session.ID != tt.expectedID || !session.CreatedAt.Equal(tt.expectedCreated) || session.Current != tt.expectedCurrent {
+				t.Errorf("Expected session %s created at %v (current %v), got %+v", tt.expectedID, tt.expectedCreated, tt.expectedCurrent, session)
+			}
+			if !session.LastUsedAt.Equal(issued) || session.SessionClient != tt.token.SessionClient {
+				t.Errorf("Expected the token's issue time and client, got %+v", session)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package models
+
+import _ "embed"
+
+// JSON Schemas for request bodies, checked by middlewares.JSONSchema before the
+// handler binds them. They mirror the validate tags of the matching request
+// types, except for rules that need the server's configuration, such as the
+// common password blocklist, which stay with the handler's validator.
+var (
+	//go:embed schemas/create_user.json
+	CreateUserSchema []byte
+	//go:embed schemas/update_user.json
+	UpdateUserSchema []byte
+	//go:embed schemas/replace_user.json
+	ReplaceUserSchema []byte
+)
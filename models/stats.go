@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// UserStats summarizes the users that are not deleted, for dashboards
+type UserStats struct {
+	Total int64 `json:"total" example:"42"`
+	// CreatedToday and CreatedThisWeek count from midnight UTC, and from Monday for the week
+	CreatedToday    int64 `json:"created_today" example:"3"`
+	CreatedThisWeek int64 `json:"created_this_week" example:"12"`
+	// Domains lists the email domains with the most users, largest first
+	Domains []DomainCount `json:"domains"`
+	// GeneratedAt is when the numbers were computed; they are cached for a short while
+	GeneratedAt time.Time `json:"generated_at" example:"2024-06-04T12:00:00Z"`
+}
+
+// DomainCount is the number of users with an email in Domain
+type DomainCount struct {
+	Domain string `json:"domain" example:"example.com"`
+	Count  int64  `json:"count" example:"30"`
+}
@@ -0,0 +1,13 @@
+package models
+
+// TwoFactorSetup is returned when two-factor enrollment starts. The secret is
+// shown once for manual entry; the URI is usually rendered as a QR code.
+type TwoFactorSetup struct {
+	Secret          string `json:"secret" example:"JBSWY3DPEHPK3PXP"`
+	ProvisioningURI string `json:"provisioning_uri" example:"otpauth://totp/User%20Management%20API:alice?issuer=User%20Management%20API&secret=JBSWY3DPEHPK3PXP"`
+}
+
+// TwoFactorCodeRequest carries a code from the user's authenticator app
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric" example:"123456"`
+}
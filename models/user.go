@@ -1,6 +1,12 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
@@ -8,36 +14,326 @@ import (
 )
 
 type User struct {
-	ID       bson.ObjectID `json:"id" bson:"_id,omitempty"`
-	UserID   string        `json:"user_id" bson:"user_id"`
-	Email    string        `json:"email" bson:"email"`
-	Password string        `json:"-" bson:"password"`
-	CreatedAt time.Time         `json:"created_at" bson:"created_at"`
-	UpdatedAt time.Time         `json:"updated_at" bson:"updated_at"`
+	ID     bson.ObjectID `json:"id" bson:"_id,omitempty" swaggertype:"string" example:"665f1c2e8b3a4d2f9c1e7a10"`
+	UserID string        `json:"user_id" bson:"user_id" example:"alice"`
+	Email  string        `json:"email" bson:"email" example:"alice@example.com"`
+	// EmailDomain is the lowercased part of Email after the @, stored so that
+	// domain queries can use an index instead of a regex scan
+	EmailDomain       string   `json:"-" bson:"email_domain,omitempty"`
+	Password          string   `json:"-" bson:"password"`
+	EmailVerified     bool     `json:"email_verified" bson:"email_verified" example:"false"`
+	VerificationToken string   `json:"-" bson:"verification_token,omitempty"`
+	Roles             []string `json:"roles" bson:"roles" example:"user"`
+	// Status is empty on users stored before it existed, which counts as active
+	Status    string     `json:"status" bson:"status,omitempty" example:"active"`
+	CreatedAt time.Time  `json:"created_at" bson:"created_at" example:"2024-06-04T12:00:00Z"`
+	UpdatedAt time.Time  `json:"updated_at" bson:"updated_at" example:"2024-06-04T12:00:00Z"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" bson:"deleted_at,omitempty" example:"2024-06-05T08:30:00Z"`
+	// FailedLogins counts consecutive failed logins; LockedUntil is set once they
+	// reach the lockout threshold
+	FailedLogins int        `json:"-" bson:"failed_logins,omitempty"`
+	LockedUntil  *time.Time `json:"-" bson:"locked_until,omitempty"`
+	// LastLoginAt is when the user last logged in; nil means never
+	LastLoginAt *time.Time `json:"last_login_at,omitempty" bson:"last_login_at,omitempty" example:"2024-06-04T12:00:00Z"`
+	// TwoFactorSecret is the AES-GCM sealed TOTP secret. It is stored at enrollment,
+	// while TwoFactorEnabled only becomes true once a code from it has been verified.
+	TwoFactorEnabled bool   `json:"two_factor_enabled" bson:"two_factor_enabled,omitempty"`
+	TwoFactorSecret  string `json:"-" bson:"two_factor_secret,omitempty"`
+	// Optional profile fields; an empty value means the user has not set one
+	FirstName string `json:"first_name,omitempty" bson:"first_name,omitempty" example:"Alice"`
+	LastName  string `json:"last_name,omitempty" bson:"last_name,omitempty" example:"Liddell"`
+	AvatarURL string `json:"avatar_url,omitempty" bson:"avatar_url,omitempty" example:"https://example.com/avatars/alice.png"`
+}
+
+// IsLocked reports whether login is refused for the user at now
+func (u *User) IsLocked(now time.Time) bool {
+	return u.LockedUntil != nil && now.Before(*u.LockedUntil)
+}
+
+// IsDisabled reports whether an admin has deactivated the account
+func (u *User) IsDisabled() bool {
+	return u.Status == StatusDisabled
+}
+
+// UserResponse is the API representation of a user. Handlers map every User to it
+// with NewUserResponse, so fields added to User for storage stay internal until
+// they are deliberately exposed here.
+type UserResponse struct {
+	ID               string     `json:"id" example:"665f1c2e8b3a4d2f9c1e7a10"`
+	UserID           string     `json:"user_id" example:"alice"`
+	Email            string     `json:"email" example:"alice@example.com"`
+	EmailVerified    bool       `json:"email_verified" example:"false"`
+	TwoFactorEnabled bool       `json:"two_factor_enabled" example:"false"`
+	FirstName        string     `json:"first_name,omitempty" example:"Alice"`
+	LastName         string     `json:"last_name,omitempty" example:"Liddell"`
+	AvatarURL        string     `json:"avatar_url,omitempty" example:"https://example.com/avatars/alice.png"`
+	Roles            []string   `json:"roles" example:"user"`
+	Status           string     `json:"status" enums:"active,disabled" example:"active"`
+	CreatedAt        time.Time  `json:"created_at" example:"2024-06-04T12:00:00Z"`
+	UpdatedAt        time.Time  `json:"updated_at" example:"2024-06-04T12:00:00Z"`
+	DeletedAt        *time.Time `json:"deleted_at,omitempty" example:"2024-06-05T08:30:00Z"`
+	LastLoginAt      *time.Time `json:"last_login_at,omitempty" example:"2024-06-04T12:00:00Z"`
+}
+
+// NewUserResponse maps a stored user to its API representation
+func NewUserResponse(user *User) *UserResponse {
+	status := user.Status
+	if status == "" {
+		status = StatusActive
+	}
+
+	return &UserResponse{
+		ID:               user.ID.Hex(),
+		UserID:           user.UserID,
+		Email:            user.Email,
+		EmailVerified:    user.EmailVerified,
+		TwoFactorEnabled: user.TwoFactorEnabled,
+		FirstName:        user.FirstName,
+		LastName:         user.LastName,
+		AvatarURL:        user.AvatarURL,
+		Roles:            slices.Clone(user.Roles),
+		Status:           status,
+		CreatedAt:        user.CreatedAt,
+		UpdatedAt:        user.UpdatedAt,
+		DeletedAt:        user.DeletedAt,
+		LastLoginAt:      user.LastLoginAt,
+	}
+}
+
+// NewUserResponses maps a list of users, returning an empty rather than nil slice
+func NewUserResponses(users []*User) []*UserResponse {
+	responses := make([]*UserResponse, 0, len(users))
+	for _, user := range users {
+		responses = append(responses, NewUserResponse(user))
+	}
+	return responses
 }
 
 type CreateUserRequest struct {
-	UserID   string `json:"user_id" validate:"required"`
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
+	UserID    string `json:"user_id" validate:"required" example:"alice"`
+	Email     string `json:"email" validate:"required,email" example:"alice@example.com"`
+	Password  string `json:"password" validate:"required,min=6,maxbytes=72,notcommon" example:"s3cretpass"`
+	FirstName string `json:"first_name,omitempty" validate:"omitempty,max=100" example:"Alice"`
+	LastName  string `json:"last_name,omitempty" validate:"omitempty,max=100" example:"Liddell"`
+	AvatarURL string `json:"avatar_url,omitempty" validate:"omitempty,http_url,max=2048" example:"https://example.com/avatars/alice.png"`
+}
+
+// ReplaceUserRequest is the body of a full replacement (PUT). The account fields are
+// required; profile fields left out are cleared.
+type ReplaceUserRequest struct {
+	UserID    string `json:"user_id" validate:"required" example:"alice"`
+	Email     string `json:"email" validate:"required,email" example:"alice@example.com"`
+	Password  string `json:"password" validate:"required,min=6,maxbytes=72,notcommon" example:"s3cretpass"`
+	FirstName string `json:"first_name,omitempty" validate:"omitempty,max=100" example:"Alice"`
+	LastName  string `json:"last_name,omitempty" validate:"omitempty,max=100" example:"Liddell"`
+	AvatarURL string `json:"avatar_url,omitempty" validate:"omitempty,http_url,max=2048" example:"https://example.com/avatars/alice.png"`
+}
+
+type LoginRequest struct {
+	// Identifier is matched against both user_id and email, for clients that let
+	// users type either into one field
+	Identifier string `json:"identifier,omitempty" example:"alice@example.com"`
+	UserID     string `json:"user_id,omitempty" example:"alice"`
+	Email      string `json:"email,omitempty" example:"alice@example.com"`
+	Password   string `json:"password" validate:"required" example:"s3cretpass"`
+	// TOTPCode is required once the account has two-factor authentication enabled
+	TOTPCode string `json:"totp_code,omitempty" example:"123456"`
+}
+
+type LoginResponse struct {
+	Token        string        `json:"token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	RefreshToken string        `json:"refresh_token" example:"cmVmcmVzaFRva2VuRXhhbXBsZUFiY2RlZmdoaWprbG1u"`
+	User         *UserResponse `json:"user"`
+}
+
+// SetRolesRequest is the body of a role assignment; it replaces all roles
+type SetRolesRequest struct {
+	Roles []string `json:"roles" validate:"required,min=1,dive,oneof=user admin" example:"user,admin"`
 }
 
 type UpdateUserRequest struct {
-	UserID   *string `json:"user_id,omitempty"`
-	Email    *string `json:"email,omitempty"`
-	Password *string `json:"password,omitempty"`
+	UserID   *string `json:"user_id,omitempty" validate:"omitnil,min=1" example:"alice"`
+	Email    *string `json:"email,omitempty" validate:"omitnil,email" example:"alice@example.com"`
+	Password *string `json:"password,omitempty" validate:"omitnil,min=6,maxbytes=72,notcommon" example:"n3wpassword"`
+	// An empty string clears a profile field, which is why avatar_url also accepts ""
+	FirstName *string `json:"first_name,omitempty" validate:"omitempty,max=100" example:"Alice"`
+	LastName  *string `json:"last_name,omitempty" validate:"omitempty,max=100" example:"Liddell"`
+	AvatarURL *string `json:"avatar_url,omitempty" validate:"omitnil,max=2048,eq=|http_url" example:"https://example.com/avatars/alice.png"`
+}
+
+// ListUsersOptions controls which users ListUsers returns
+type ListUsersOptions struct {
+	IncludeDeleted bool
+	SortField      string
+	SortDescending bool
+	// CreatedAfter and CreatedBefore bound created_at inclusively when set
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// UserIDs limits the result to these user_id values when non-empty
+	UserIDs []string
+	// Status limits the result to active or disabled users when set
+	Status string
+	// EmailDomain limits the result to emails in this domain, ignoring case, when set
+	EmailDomain string
+	// Offset skips that many matching users, and a positive Limit caps how many are
+	// returned; both zero return every match
+	Offset int
+	Limit  int
+}
+
+// MaxListUserIDs caps how many user_ids one ListUsers call may filter on
+const MaxListUserIDs = 200
+
+// SortableUserFields are the fields ListUsers accepts in the sort parameter
+var SortableUserFields = []string{"created_at", "updated_at", "user_id", "email"}
+
+var ErrInvalidSortField = errors.New("invalid sort field")
+
+// ParseSort parses a sort parameter such as "email" or "-created_at".
+// An empty value sorts by created_at descending.
+func ParseSort(raw string) (field string, descending bool, err error) {
+	if raw == "" {
+		return "created_at", true, nil
+	}
+
+	field = raw
+	if strings.HasPrefix(raw, "-") {
+		field = raw[1:]
+		descending = true
+	}
+
+	for _, allowed := range SortableUserFields {
+		if field == allowed {
+			return field, descending, nil
+		}
+	}
+
+	return "", false, ErrInvalidSortField
+}
+
+var ErrInvalidPeriod = errors.New("invalid period")
+
+// ParsePeriod parses a human-friendly length of time such as "30d", "2w" or "36h".
+// Days and weeks are whole multiples of 24 hours; anything else is handed to
+// time.ParseDuration. The period must be positive.
+func ParsePeriod(raw string) (time.Duration, error) {
+	raw = strings.TrimSpace(raw)
+
+	var period time.Duration
+	if unit := strings.TrimLeft(raw, "0123456789"); unit == "d" || unit == "w" {
+		count, err := strconv.Atoi(strings.TrimSuffix(raw, unit))
+		if err != nil {
+			return 0, ErrInvalidPeriod
+		}
+		days := count
+		if unit == "w" {
+			days = count * 7
+		}
+		period = time.Duration(days) * 24 * time.Hour
+		// Guard against counts so large that the multiplication wrapped around
+		if period/(24*time.Hour) != time.Duration(days) {
+			return 0, ErrInvalidPeriod
+		}
+	} else {
+		var err error
+		if period, err = time.ParseDuration(raw); err != nil {
+			return 0, ErrInvalidPeriod
+		}
+	}
+
+	if period <= 0 {
+		return 0, ErrInvalidPeriod
+	}
+	return period, nil
 }
 
-func (u *User) HashPassword(password string) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return err
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// ValidRoles are the roles that can be assigned to a user
+var ValidRoles = []string{RoleUser, RoleAdmin}
+
+var ErrInvalidRole = errors.New("invalid role")
+
+// DefaultRoles returns the roles given to newly created users
+func DefaultRoles() []string {
+	return []string{RoleUser}
+}
+
+// ValidateRoles checks that roles is non-empty and contains only ValidRoles
+func ValidateRoles(roles []string) error {
+	if len(roles) == 0 {
+		return ErrInvalidRole
+	}
+	for _, role := range roles {
+		if !slices.Contains(ValidRoles, role) {
+			return ErrInvalidRole
+		}
 	}
-	u.Password = string(hashedPassword)
 	return nil
 }
 
-func (u *User) CheckPassword(password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
-	return err == nil
-}
\ No newline at end of file
+// HasRole reports whether the user has been granted role
+func (u *User) HasRole(role string) bool {
+	return slices.Contains(u.Roles, role)
+}
+
+const (
+	StatusActive   = "active"
+	StatusDisabled = "disabled"
+)
+
+// ValidStatuses are the account statuses a user can be given
+var ValidStatuses = []string{StatusActive, StatusDisabled}
+
+var ErrInvalidStatus = errors.New("invalid status")
+
+// ValidateStatus checks that status is one of ValidStatuses
+func ValidateStatus(status string) error {
+	if !slices.Contains(ValidStatuses, status) {
+		return ErrInvalidStatus
+	}
+	return nil
+}
+
+var ErrInvalidEmail = errors.New("invalid email address")
+
+// NormalizeEmail trims and lowercases an email address and checks that it is a bare address
+func NormalizeEmail(email string) (string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(email))
+
+	address, err := mail.ParseAddress(normalized)
+	if err != nil || address.Address != normalized {
+		return "", ErrInvalidEmail
+	}
+
+	return normalized, nil
+}
+
+// EmailDomain returns the lowercased domain of an email address, or "" when it has no @
+func EmailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(email[at+1:])
+}
+
+// bcryptCost is set once at startup by SetBcryptCost
+var bcryptCost = bcrypt.DefaultCost
+
+// SetBcryptCost sets the cost of new bcrypt hashes, BCRYPT_COST in the configuration
+func SetBcryptCost(cost int) error {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt cost must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, cost)
+	}
+	bcryptCost = cost
+	return nil
+}
+
+// GetBcryptCost returns the cost passed to SetBcryptCost, bcrypt.DefaultCost until then
+func GetBcryptCost() int {
+	return bcryptCost
+}
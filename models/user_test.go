@@ -1,6 +1,9 @@
 package models
 
 import (
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -192,4 +195,284 @@ func TestUser_StructFields(t *testing.T) {
 	if !user.UpdatedAt.Equal(now) {
 		t.Errorf("Expected UpdatedAt %v, got %v", now, user.UpdatedAt)
 	}
-}
\ No newline at end of file
+}
+func TestNewUserResponse(t *testing.T) {
+	deletedAt := time.Now()
+	user := &User{
+		ID:                bson.NewObjectID(),
+		UserID:            "alice",
+		Email:             "alice@example.com",
+		Password:          "$2a$10$hash",
+		VerificationToken: "token",
+		Roles:             []string{RoleUser},
+		DeletedAt:         &deletedAt,
+		FirstName:         "Alice",
+		AvatarURL:         "https://example.com/alice.png",
+	}
+
+	response := NewUserResponse(user)
+	if response.ID != user.ID.Hex() || response.UserID != "alice" || response.DeletedAt != &deletedAt {
+		t.Errorf("Unexpected mapping %+v", response)
+	}
+	if response.FirstName != "Alice" || response.LastName != "" || response.AvatarURL != user.AvatarURL {
+		t.Errorf("Expected the profile fields to be mapped, got %+v", response)
+	}
+	if response.Status != StatusActive {
+		t.Errorf("Expected a user without a status to be active, got '%s'", response.Status)
+	}
+
+	// The response must not share the roles slice with the stored user
+	response.Roles[0] = RoleAdmin
+	if user.Roles[0] != RoleUser {
+		t.Error("Expected roles to be copied")
+	}
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, secret := range []string{"password", "$2a$10$hash", "verification_token"} {
+		if strings.Contains(string(body), secret) {
+			t.Errorf("Expected %q to be absent from %s", secret, body)
+		}
+	}
+	// Unset profile fields are left out rather than sent empty
+	if strings.Contains(string(body), "last_name") {
+		t.Errorf("Expected last_name to be omitted from %s", body)
+	}
+}
+
+func TestNewUserResponses(t *testing.T) {
+	if responses := NewUserResponses(nil); responses == nil || len(responses) != 0 {
+		t.Errorf("Expected an empty slice, got %v", responses)
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		raw        string
+		field      string
+		descending bool
+		wantErr    bool
+	}{
+		{"", "created_at", true, false},
+		{"created_at", "created_at", false, false},
+		{"-created_at", "created_at", true, false},
+		{"updated_at", "updated_at", false, false},
+		{"-user_id", "user_id", true, false},
+		{"email", "email", false, false},
+		{"password", "", false, true},
+		{"-", "", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			field, descending, err := ParseSort(tt.raw)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSortField) {
+					t.Errorf("Expected ErrInvalidSortField for '%s', got %v", tt.raw, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error for '%s', got %v", tt.raw, err)
+			}
+
+			if field != tt.field || descending != tt.descending {
+				t.Errorf("Expected (%s, %v), got (%s, %v)", tt.field, tt.descending, field, descending)
+			}
+		})
+	}
+}
+
+// setBcryptCost hashes the test's passwords at cost
+func setBcryptCost(t *testing.T, cost int) {
+	t.Helper()
+	if err := SetBcryptCost(cost); err != nil {
+		t.Fatalf("Failed to set bcrypt cost: %v", err)
+	}
+	t.Cleanup(func() { SetBcryptCost(bcrypt.DefaultCost) })
+}
+
+func TestSetBcryptCost(t *testing.T) {
+	if got := GetBcryptCost(); got != bcrypt.DefaultCost {
+		t.Errorf("Expected cost %d by default, got %d", bcrypt.DefaultCost, got)
+	}
+
+	setBcryptCost(t, 12)
+	if got := GetBcryptCost(); got != 12 {
+		t.Errorf("Expected cost 12, got %d", got)
+	}
+
+	for _, cost := range []int{bcrypt.MinCost - 1, bcrypt.MaxCost + 1} {
+		if err := SetBcryptCost(cost); err == nil {
+			t.Errorf("Expected cost %d to be rejected", cost)
+		}
+	}
+	if got := GetBcryptCost(); got != 12 {
+		t.Errorf("Expected a rejected cost to leave 12 in place, got %d", got)
+	}
+}
+
+func TestUser_HashPassword_CustomCost(t *testing.T) {
+	setBcryptCost(t, 5)
+
+	user := &User{}
+	if err := user.HashPassword("testpassword123"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	cost, err := bcrypt.Cost([]byte(user.Password))
+	if err != nil {
+		t.Fatalf("Expected hash to be decodable, got %v", err)
+	}
+
+	if cost != 5 {
+		t.Errorf("Expected cost 5, got %d", cost)
+	}
+
+	if !user.CheckPassword("testpassword123") {
+		t.Error("Expected password check to succeed with custom cost")
+	}
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{"test@example.com", "test@example.com", false},
+		{"Test@Example.COM", "test@example.com", false},
+		{"  user@example.com \n", "user@example.com", false},
+		{"not-an-email", "", true},
+		{"", "", true},
+		{"Test User <test@example.com>", "", true},
+		{"a@b@example.com", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := NormalizeEmail(tt.input)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidEmail) {
+					t.Errorf("Expected ErrInvalidEmail for '%s', got %v", tt.input, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error for '%s', got %v", tt.input, err)
+			}
+
+			if got != tt.expected {
+				t.Errorf("Expected '%s', got '%s'", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestEmailDomain(t *testing.T) {
+	tests := map[string]string{
+		"alice@example.com":      "example.com",
+		"alice@Mail.Example.COM": "mail.example.com",
+		`"a@b"@example.com`:      "example.com",
+		"no-at-sign":             "",
+	}
+
+	for email, expected := range tests {
+		if got := EmailDomain(email); got != expected {
+			t.Errorf("Expected '%s' for '%s', got '%s'", expected, email, got)
+		}
+	}
+}
+
+func TestValidateRoles(t *testing.T) {
+	tests := []struct {
+		name    string
+		roles   []string
+		wantErr bool
+	}{
+		{"Default roles", DefaultRoles(), false},
+		{"User and admin", []string{RoleUser, RoleAdmin}, false},
+		{"Empty", []string{}, true},
+		{"Unknown role", []string{RoleUser, "superuser"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRoles(tt.roles)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error: %v, got %v", tt.wantErr, err)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidRole) {
+				t.Errorf("Expected ErrInvalidRole, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateStatus(t *testing.T) {
+	for _, status := range ValidStatuses {
+		if err := ValidateStatus(status); err != nil {
+			t.Errorf("Expected '%s' to be valid, got %v", status, err)
+		}
+	}
+	for _, status := range []string{"", "suspended", "Active"} {
+		if err := ValidateStatus(status); !errors.Is(err, ErrInvalidStatus) {
+			t.Errorf("Expected ErrInvalidStatus for '%s', got %v", status, err)
+		}
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{"30d", 30 * 24 * time.Hour, false},
+		{" 90d ", 90 * 24 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"36h", 36 * time.Hour, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"", 0, true},
+		{"d", 0, true},
+		{"0d", 0, true},
+		{"-5d", 0, true},
+		{"-1h", 0, true},
+		{"30 days", 0, true},
+		{"99999999999999999d", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			period, err := ParsePeriod(tt.raw)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidPeriod) {
+					t.Errorf("Expected ErrInvalidPeriod, got %v (%v)", err, period)
+				}
+				return
+			}
+			if err != nil || period != tt.expected {
+				t.Errorf("Expected %v, got %v (%v)", tt.expected, period, err)
+			}
+		})
+	}
+}
+
+func TestUser_HasRole(t *testing.T) {
+	user := &User{Roles: []string{RoleUser}}
+
+	if !user.HasRole(RoleUser) {
+		t.Error("Expected user to have the user role")
+	}
+	if user.HasRole(RoleAdmin) {
+		t.Error("Expected user not to have the admin role")
+	}
+
+	if (&User{}).HasRole(RoleUser) {
+		t.Error("Expected a user without roles to have no role")
+	}
+}
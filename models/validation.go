@@ -0,0 +1,39 @@
+package models
+
+import "strings"
+
+// FieldError describes a single failed validation rule
+type FieldError struct {
+	Field   string `json:"field" example:"email"`
+	Message string `json:"message" example:"must be a valid email address"`
+}
+
+// FieldErrors accumulates field-level failures in the order they were found. It
+// is shared by the handler validator and the JSON Schema middleware, whose
+// failures handlers.HTTPErrorHandler renders in the same ValidationErrorResponse.
+type FieldErrors []FieldError
+
+// Add records a failure for field
+func (fe *FieldErrors) Add(field, message string) {
+	*fe = append(*fe, FieldError{Field: field, Message: message})
+}
+
+// Map returns the first message for each field
+func (fe FieldErrors) Map() map[string]string {
+	fields := make(map[string]string, len(fe))
+	for _, fieldErr := range fe {
+		if _, ok := fields[fieldErr.Field]; !ok {
+			fields[fieldErr.Field] = fieldErr.Message
+		}
+	}
+	return fields
+}
+
+// Error lists every failure, so that the field errors can be returned as an error
+func (fe FieldErrors) Error() string {
+	failures := make([]string, 0, len(fe))
+	for _, fieldErr := range fe {
+		failures = append(failures, fieldErr.Field+" "+fieldErr.Message)
+	}
+	return "validation failed: " + strings.Join(failures, ", ")
+}
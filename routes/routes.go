@@ -1,57 +1,297 @@
 package routes
 
 import (
+	"log/slog"
 	"net/http"
+	"slices"
+	"strings"
+
+	"go-mongodb-test/auth"
+	"go-mongodb-test/config"
+	_ "go-mongodb-test/docs"
+	"go-mongodb-test/middlewares"
+	"go-mongodb-test/models"
 
 	"github.com/labstack/echo/v4"
+	echoSwagger "github.com/swaggo/echo-swagger"
 )
 
 // UserHandlerInterface defines the methods that need to be implemented by a handler
 type UserHandlerInterface interface {
 	CreateUser(c echo.Context) error
 	GetUser(c echo.Context) error
+	GetMe(c echo.Context) error
 	GetUserByUserID(c echo.Context) error
 	GetUserByEmail(c echo.Context) error
+	UserExists(c echo.Context) error
+	UserIDExists(c echo.Context) error
+	CheckAvailability(c echo.Context) error
+	SearchUsers(c echo.Context) error
 	UpdateUser(c echo.Context) error
+	ReplaceUser(c echo.Context) error
 	DeleteUser(c echo.Context) error
+	DeleteAllUsers(c echo.Context) error
+	RestoreUser(c echo.Context) error
 	ListUsers(c echo.Context) error
+	ListInactiveUsers(c echo.Context) error
+	ExportUsers(c echo.Context) error
+	ExportUsersCSV(c echo.Context) error
+	ImportUsers(c echo.Context) error
+	CountUsers(c echo.Context) error
+	GetUserStats(c echo.Context) error
+	GetUserAuditLog(c echo.Context) error
+	StreamUserEvents(c echo.Context) error
+	SetUserRoles(c echo.Context) error
+	ActivateUser(c echo.Context) error
+	DeactivateUser(c echo.Context) error
+	EnableTwoFactor(c echo.Context) error
+	VerifyTwoFactor(c echo.Context) error
+	ListSessions(c echo.Context) error
+	RevokeSession(c echo.Context) error
+	RevokeOtherSessions(c echo.Context) error
+	ListIndexes(c echo.Context) error
+	AdminResetPassword(c echo.Context) error
+}
+
+// AuthHandlerInterface defines the authentication endpoints
+type AuthHandlerInterface interface {
+	Login(c echo.Context) error
+	Refresh(c echo.Context) error
+	Logout(c echo.Context) error
+	ForgotPassword(c echo.Context) error
+	ResetPassword(c echo.Context) error
+	VerifyEmail(c echo.Context) error
+}
+
+// HealthHandlerInterface defines the probe and build info endpoints
+type HealthHandlerInterface interface {
+	Live(c echo.Context) error
+	Ready(c echo.Context) error
+	Version(c echo.Context) error
+}
+
+// MaintenanceHandlerInterface defines the maintenance mode switch endpoint
+type MaintenanceHandlerInterface interface {
+	SetMaintenance(c echo.Context) error
+}
+
+// Config groups the handlers that SetupRoutes wires up and where the API is mounted
+type Config struct {
+	Users       UserHandlerInterface
+	Auth        AuthHandlerInterface
+	Health      HealthHandlerInterface
+	Maintenance MaintenanceHandlerInterface
+
+	// Prefix is the path every API route is registered under; empty means DefaultAPIPrefix
+	Prefix string
+
+	// RateLimitPerMinute is the per-IP budget for credential and signup endpoints;
+	// zero means config.DefaultRateLimitPerMinute
+	RateLimitPerMinute int
+
+	// AvailabilityRateLimitPerMinute is the separate per-IP budget of the availability
+	// check; zero means config.DefaultAvailabilityRateLimitPerMinute
+	AvailabilityRateLimitPerMinute int
+
+	// MaxConcurrentRequests caps how many API requests run at once, answering 503
+	// beyond it; zero means no cap. Health and docs routes are never limited, so
+	// probes keep working under load.
+	MaxConcurrentRequests int
+
+	// AllowDestructive registers DELETE /users, which wipes the users collection.
+	// Leave it off outside of disposable test environments.
+	AllowDestructive bool
+
+	// UserEvents registers GET /users/events, a server-sent event stream that holds
+	// a connection open for as long as each subscriber stays
+	UserEvents bool
+
+	// Tenant, when set, runs on every API route ahead of the route's own middlewares,
+	// such as handlers.ResolveTenant to serve each request from its tenant's database
+	Tenant echo.MiddlewareFunc
+}
+
+// DefaultAPIPrefix is used when no prefix is configured
+const DefaultAPIPrefix = "/api/v1"
+
+// normalizePrefix cleans up a configured prefix, falling back to DefaultAPIPrefix
+func normalizePrefix(prefix string) string {
+	prefix = strings.Trim(strings.TrimSpace(prefix), "/")
+	if prefix == "" {
+		return DefaultAPIPrefix
+	}
+	return "/" + prefix
+}
+
+// UploadPaths returns the routes that take multipart uploads instead of JSON bodies,
+// for exempting them from middlewares.RequireJSONContentType
+func UploadPaths(prefix string) []string {
+	return []string{normalizePrefix(prefix) + "/users/import"}
+}
+
+// StreamingPaths returns the routes that hold their response open, for exempting
+// them from middlewares.Timeout
+func StreamingPaths(prefix string) []string {
+	return []string{normalizePrefix(prefix) + "/users/events"}
+}
+
+// MaintenancePaths returns the write routes that stay open in maintenance mode, for
+// exempting them from middlewares.MaintenanceMode: the switch itself, and logging
+// in, so that an admin can always get the token to turn maintenance off
+func MaintenancePaths(prefix string) []string {
+	prefix = normalizePrefix(prefix)
+	return []string{prefix + "/admin/maintenance", prefix + "/auth/login"}
 }
 
 // SetupRoutes configures all the routes for the API
-func SetupRoutes(e *echo.Echo, handler UserHandlerInterface) {
+func SetupRoutes(e *echo.Echo, h Config) {
+	// Per-IP rate limit for credential and signup endpoints
+	rateLimitPerMinute := h.RateLimitPerMinute
+	if rateLimitPerMinute <= 0 {
+		rateLimitPerMinute = config.DefaultRateLimitPerMinute
+	}
+	rateLimit := middlewares.RateLimit(rateLimitPerMinute)
+
+	// The availability check has a budget of its own, so that signup forms checking
+	// as the user types neither spend nor are blocked by the login budget
+	availabilityRateLimitPerMinute := h.AvailabilityRateLimitPerMinute
+	if availabilityRateLimitPerMinute <= 0 {
+		availabilityRateLimitPerMinute = config.DefaultAvailabilityRateLimitPerMinute
+	}
+	availabilityRateLimit := middlewares.RateLimit(availabilityRateLimitPerMinute)
+
+	// Admin-only routes need a valid JWT carrying the admin role
+	requireAdmin := []echo.MiddlewareFunc{auth.Authenticate(), auth.RequireRole(models.RoleAdmin)}
+	// Admin routes that services call also take an X-API-Key in place of the JWT;
+	// read-only keys are limited to GET and HEAD
+	requireAdminOrKey := auth.APIKeyOr(requireAdmin...)
+	// Changing a user is left to that user and to admins; it runs after Authenticate
+	requireSelfOrAdmin := auth.RequireSelfOrRole("id", models.RoleAdmin)
+
+	// Routes that bind a JSON body reject empty requests up front
+	requireBody := middlewares.RequireBody()
+
+	// Bodies with a JSON Schema are checked against it before the handler binds them
+	createUserSchema := middlewares.JSONSchema(models.CreateUserSchema)
+	updateUserSchema := middlewares.JSONSchema(models.UpdateUserSchema)
+	replaceUserSchema := middlewares.JSONSchema(models.ReplaceUserSchema)
+
+	// Cap requests in flight across the API. This is registered with Use rather than on
+	// the group, since group middleware turns unmatched methods into 404 instead of 405.
+	prefix := normalizePrefix(h.Prefix)
+	// Event streams are not counted either, since each would hold a slot until it disconnects.
+	streaming := StreamingPaths(prefix)
+	e.Use(middlewares.ConcurrencyLimit(h.MaxConcurrentRequests, func(c echo.Context) bool {
+		return !strings.HasPrefix(c.Path(), prefix+"/") || slices.Contains(streaming, c.Path())
+	}))
+
+	// Tenant resolution is registered with Use for the same reason, and so runs before
+	// authentication, which checks tokens against the tenant of the request
+	if h.Tenant != nil {
+		e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			withTenant := h.Tenant(next)
+			return func(c echo.Context) error {
+				if !strings.HasPrefix(c.Path(), prefix+"/") {
+					return next(c)
+				}
+				return withTenant(c)
+			}
+		})
+	}
+
 	// Create API group
-	api := e.Group("/api")
+	api := e.Group(prefix)
+
+	// Auth routes
+	authGroup := api.Group("/auth")
+	authGroup.POST("/login", h.Auth.Login, rateLimit, requireBody)                    // Login and issue JWT
+	authGroup.POST("/refresh", h.Auth.Refresh, rateLimit, requireBody)                // Exchange a refresh token for new tokens
+	authGroup.POST("/logout", h.Auth.Logout, requireBody)                             // Revoke a refresh token
+	authGroup.POST("/forgot-password", h.Auth.ForgotPassword, rateLimit, requireBody) // Email a password reset token
+	authGroup.POST("/reset-password", h.Auth.ResetPassword, rateLimit, requireBody)   // Reset password with a token
+	authGroup.GET("/verify", h.Auth.VerifyEmail)                                      // Confirm email with a token
 
 	// User routes
 	users := api.Group("/users")
-	users.POST("", handler.CreateUser)
-	users.GET("", handler.ListUsers)
-	users.GET("/:id", handler.GetUser)
-	users.PUT("/:id", handler.UpdateUser)
-	users.DELETE("/:id", handler.DeleteUser)
+	users.POST("", h.Users.CreateUser, rateLimit, requireBody, createUserSchema)                                    // Create user
+	users.GET("", h.Users.ListUsers, requireAdminOrKey)                                                             // List all users (admin)
+	users.GET("/count", h.Users.CountUsers)                                                                         // Count users
+	users.GET("/stats", h.Users.GetUserStats, requireAdminOrKey)                                                    // Totals, recent signups and top email domains (admin)
+	users.GET("/inactive", h.Users.ListInactiveUsers, requireAdminOrKey)                                            // Users without a recent login (admin)
+	users.GET("/available", h.Users.CheckAvailability, availabilityRateLimit)                                       // Check whether a user_id or email is free
+	users.GET("/export", h.Users.ExportUsers, requireAdminOrKey)                                                    // Stream all users as JSON or NDJSON (admin)
+	users.GET("/export.csv", h.Users.ExportUsersCSV, requireAdminOrKey)                                             // Stream all users as CSV (admin)
+	users.POST("/import", h.Users.ImportUsers, requireAdminOrKey)                                                   // Create users from an uploaded CSV (admin)
+	users.GET("/me", h.Users.GetMe, auth.Authenticate())                                                            // Get the authenticated user
+	users.POST("/me/2fa/enable", h.Users.EnableTwoFactor, auth.Authenticate())                                      // Start TOTP enrollment
+	users.POST("/me/2fa/verify", h.Users.VerifyTwoFactor, auth.Authenticate(), rateLimit, requireBody)              // Confirm TOTP enrollment
+	users.GET("/me/sessions", h.Users.ListSessions, auth.Authenticate())                                            // List the authenticated user's sessions
+	users.DELETE("/me/sessions", h.Users.RevokeOtherSessions, auth.Authenticate())                                  // Revoke every session but the current one
+	users.DELETE("/me/sessions/:sid", h.Users.RevokeSession, auth.Authenticate())                                   // Revoke one session
+	users.GET("/:id", h.Users.GetUser)                                                                              // Get user by MongoDB ID
+	users.HEAD("/:id", h.Users.UserExists)                                                                          // Check a user exists without reading it
+	users.PUT("/:id", h.Users.ReplaceUser, auth.Authenticate(), requireSelfOrAdmin, requireBody, replaceUserSchema) // Replace user (all fields required; self or admin)
+	users.PATCH("/:id", h.Users.UpdateUser, auth.Authenticate(), requireSelfOrAdmin, requireBody, updateUserSchema) // Partially update user (self or admin)
+	users.DELETE("/:id", h.Users.DeleteUser, requireAdminOrKey)                                                     // Soft-delete user (admin)
+	users.POST("/:id/restore", h.Users.RestoreUser, requireAdminOrKey)                                              // Restore soft-deleted user (admin)
+	users.PUT("/:id/roles", h.Users.SetUserRoles, requireAdminOrKey, requireBody)                                   // Replace user roles (admin)
+	users.POST("/:id/activate", h.Users.ActivateUser, requireAdminOrKey)                                            // Re-enable login (admin)
+	users.POST("/:id/deactivate", h.Users.DeactivateUser, requireAdminOrKey)                                        // Suspend without deleting (admin)
+	users.GET("/:id/audit-log", h.Users.GetUserAuditLog, requireAdminOrKey)                                         // History of changes to the user (admin)
+
+	// Bulk deletion is never routed unless explicitly enabled, and even then needs an admin token
+	if h.AllowDestructive {
+		slog.Warn("ALLOW_DESTRUCTIVE is enabled; DELETE " + normalizePrefix(h.Prefix) + "/users will remove every user")
+		users.DELETE("", h.Users.DeleteAllUsers, requireAdmin...) // Delete all users (admin, test only)
+	}
 
-	// Search routes
+	// The event stream keeps a connection per subscriber, so it is only routed when enabled
+	if h.UserEvents {
+		users.GET("/events", h.Users.StreamUserEvents, requireAdmin...) // Stream user changes as server-sent events (admin)
+	}
+
+	// Search routes: partial match (q) or exact user_id on /search, exact email on /search/email
 	users.GET("/search", func(c echo.Context) error {
-		return getUserSearchHandler(c, handler)
+		return getUserSearchHandler(c, h.Users)
+	})
+	users.HEAD("/search", h.Users.UserIDExists) // Check whether a user_id is taken
+	users.GET("/search/email", h.Users.GetUserByEmail)
+
+	// Admin routes for operating the deployment
+	admin := api.Group("/admin")
+	admin.GET("/indexes", h.Users.ListIndexes, requireAdmin...)                          // List users collection indexes (admin)
+	admin.POST("/users/:id/reset-password", h.Users.AdminResetPassword, requireAdmin...) // Set or generate a user's password (admin)
+	admin.POST("/maintenance", h.Maintenance.SetMaintenance, requireAdmin...)            // Turn maintenance mode on or off (admin)
+
+	// Health checks
+	e.GET("/health", h.Health.Ready)       // Readiness (kept for existing probes)
+	e.GET("/health/live", h.Health.Live)   // Liveness: process is up
+	e.GET("/health/ready", h.Health.Ready) // Readiness: MongoDB is reachable
+	e.GET("/version", h.Health.Version)    // Build and driver versions
+
+	// OpenAPI spec and Swagger UI (regenerate with: swag init --v3.1); the spec
+	// documents DefaultAPIPrefix as its server URL
+	e.GET("/swagger/*", echoSwagger.EchoWrapHandlerV3())
+	// With trailing slashes stripped, /swagger/ arrives here instead of the wildcard
+	e.GET("/swagger", func(c echo.Context) error {
+		return c.Redirect(http.StatusMovedPermanently, "/swagger/index.html")
 	})
 }
 
-// getUserSearchHandler handles requests to search for users by user_id or email
+// getUserSearchHandler handles requests to search for users by partial match or user_id
 func getUserSearchHandler(c echo.Context, handler UserHandlerInterface) error {
+	// Check if a partial-match query is present
+	query := c.QueryParam("q")
+	if query != "" {
+		return handler.SearchUsers(c)
+	}
+
 	// Check if user_id parameter is present
 	userID := c.QueryParam("user_id")
 	if userID != "" {
 		return handler.GetUserByUserID(c)
 	}
 
-	// Check if email parameter is present
-	email := c.QueryParam("email")
-	if email != "" {
-		return handler.GetUserByEmail(c)
-	}
-
 	// If neither parameter is present, return bad request
-	return c.JSON(http.StatusBadRequest, map[string]string{
-		"error": "Missing search parameter: user_id or email is required",
-	})
-}
\ No newline at end of file
+	return echo.NewHTTPError(http.StatusBadRequest, "Missing search parameter: q or user_id is required (use /search/email for email)")
+}
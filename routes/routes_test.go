@@ -1,12 +1,21 @@
 package routes
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"go-mongodb-test/auth"
 	"go-mongodb-test/handlers"
+	"go-mongodb-test/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
 // MockUserService is a mock implementation of the UserServiceInterface
@@ -25,6 +34,10 @@ func (m *MockUserHandler) GetUser(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]string{"status": "user found"})
 }
 
+func (m *MockUserHandler) GetMe(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "current user found"})
+}
+
 func (m *MockUserHandler) GetUserByUserID(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]string{"status": "user found by user_id"})
 }
@@ -33,50 +46,305 @@ func (m *MockUserHandler) GetUserByEmail(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]string{"status": "user found by email"})
 }
 
+func (m *MockUserHandler) SearchUsers(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "users searched"})
+}
+
 func (m *MockUserHandler) UpdateUser(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]string{"status": "user updated"})
 }
 
+func (m *MockUserHandler) ReplaceUser(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "user replaced"})
+}
+
 func (m *MockUserHandler) DeleteUser(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]string{"status": "user deleted"})
 }
 
+func (m *MockUserHandler) DeleteAllUsers(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]int{"deleted_count": 0})
+}
+
 func (m *MockUserHandler) ListUsers(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]interface{}{"status": "users listed", "count": 0, "users": []string{}})
 }
 
+func (m *MockUserHandler) ExportUsers(c echo.Context) error {
+	return c.JSON(http.StatusOK, []string{})
+}
+
+func (m *MockUserHandler) ExportUsersCSV(c echo.Context) error {
+	return c.String(http.StatusOK, "id,user_id,email,created_at,updated_at\n")
+}
+
+func (m *MockUserHandler) ImportUsers(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"message": "ImportUsers called"})
+}
+
+func (m *MockUserHandler) RestoreUser(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "user restored"})
+}
+
+func (m *MockUserHandler) ListInactiveUsers(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "inactive users listed"})
+}
+
+func (m *MockUserHandler) GetUserStats(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]int{"total": 0})
+}
+
+func (m *MockUserHandler) GetUserAuditLog(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "audit log listed"})
+}
+
+func (m *MockUserHandler) StreamUserEvents(c echo.Context) error {
+	return c.String(http.StatusOK, ": events streamed\n\n")
+}
+
+func (m *MockUserHandler) CountUsers(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]int{"count": 0})
+}
+
+func (m *MockUserHandler) SetUserRoles(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "roles set"})
+}
+
+func (m *MockUserHandler) ActivateUser(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "user activated"})
+}
+
+func (m *MockUserHandler) DeactivateUser(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "user deactivated"})
+}
+
+func (m *MockUserHandler) UserExists(c echo.Context) error {
+	return c.NoContent(http.StatusOK)
+}
+
+func (m *MockUserHandler) UserIDExists(c echo.Context) error {
+	return c.NoContent(http.StatusNotFound)
+}
+
+func (m *MockUserHandler) ListIndexes(c echo.Context) error {
+	return c.JSON(http.StatusOK, []string{})
+}
+
+func (m *MockUserHandler) AdminResetPassword(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"message": "Password has been reset"})
+}
+
+func (m *MockUserHandler) CheckAvailability(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]bool{"available": true})
+}
+
+func (m *MockUserHandler) EnableTwoFactor(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "2fa started"})
+}
+
+func (m *MockUserHandler) VerifyTwoFactor(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "2fa verified"})
+}
+
+func (m *MockUserHandler) ListSessions(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "sessions listed"})
+}
+
+func (m *MockUserHandler) RevokeSession(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "session " + c.Param("sid") + " revoked"})
+}
+
+func (m *MockUserHandler) RevokeOtherSessions(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "other sessions revoked"})
+}
+
+// MockAuthHandler is a simplified auth handler for testing routes
+type MockAuthHandler struct{}
+
+func (m *MockAuthHandler) Login(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "logged in"})
+}
+
+func (m *MockAuthHandler) Refresh(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "refreshed"})
+}
+
+func (m *MockAuthHandler) Logout(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "logged out"})
+}
+
+func (m *MockAuthHandler) ForgotPassword(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "reset requested"})
+}
+
+func (m *MockAuthHandler) ResetPassword(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "password reset"})
+}
+
+func (m *MockAuthHandler) VerifyEmail(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "email verified"})
+}
+
+// MockHealthHandler is a simplified health handler for testing routes
+type MockHealthHandler struct{}
+
+func (m *MockHealthHandler) Live(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "healthy"})
+}
+
+func (m *MockHealthHandler) Ready(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "healthy"})
+}
+
+func (m *MockHealthHandler) Version(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"version": "dev"})
+}
+
+type MockMaintenanceHandler struct{}
+
+func (m *MockMaintenanceHandler) SetMaintenance(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]bool{"enabled": true})
+}
+
+func newMockHandlers() Config {
+	return Config{
+		Users:       &MockUserHandler{},
+		Auth:        &MockAuthHandler{},
+		Health:      &MockHealthHandler{},
+		Maintenance: &MockMaintenanceHandler{},
+	}
+}
+
+// setTestSecret signs and checks the test's tokens with a fixed secret
+func setTestSecret(t *testing.T) {
+	t.Helper()
+	auth.SetSecret("test-secret")
+	t.Cleanup(func() { auth.SetSecret("") })
+}
+
+func newBearerToken(t *testing.T, roles ...string) string {
+	t.Helper()
+	token, err := auth.GenerateToken(&models.User{ID: bson.NewObjectID(), UserID: "testuser", Roles: roles})
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	return "Bearer " + token
+}
+
+// validCreateUserBody passes the CreateUser JSON Schema
+const validCreateUserBody = `{"user_id":"alice","email":"alice@example.com","password":"s3cretpass"}`
+
 func TestSetupRoutes(t *testing.T) {
+	setTestSecret(t)
+	adminToken := newBearerToken(t, models.RoleUser, models.RoleAdmin)
+	userToken := newBearerToken(t, models.RoleUser)
+	self := bson.NewObjectID()
+	selfToken, err := auth.GenerateToken(&models.User{ID: self, UserID: "self", Roles: []string{models.RoleUser}})
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
 	// Create echo instance
 	e := echo.New()
-	
-	// Create a mock handler
-	mockHandler := &MockUserHandler{}
-	
-	// Setup routes with mock handler
-	SetupRoutes(e, mockHandler)
-	
+
+	// Setup routes with mock handlers
+	SetupRoutes(e, newMockHandlers())
+
 	// Test all routes
 	testRoutes := []struct {
 		name       string
 		method     string
 		path       string
+		token      string
 		statusCode int
 	}{
-		{"CreateUser", http.MethodPost, "/api/users", http.StatusCreated},
-		{"GetUser", http.MethodGet, "/api/users/123", http.StatusOK},
-		{"UpdateUser", http.MethodPut, "/api/users/123", http.StatusOK},
-		{"DeleteUser", http.MethodDelete, "/api/users/123", http.StatusOK},
-		{"ListUsers", http.MethodGet, "/api/users", http.StatusOK},
-		{"GetUserByUserID", http.MethodGet, "/api/users/search?user_id=testuser", http.StatusOK},
-		{"GetUserByEmail", http.MethodGet, "/api/users/search?email=test@example.com", http.StatusOK},
-	}
-	
+		{"CreateUser", http.MethodPost, "/api/v1/users", "", http.StatusCreated},
+		{"GetUser", http.MethodGet, "/api/v1/users/123", "", http.StatusOK},
+		{"GetMe", http.MethodGet, "/api/v1/users/me", userToken, http.StatusOK},
+		{"CheckAvailability", http.MethodGet, "/api/v1/users/available?user_id=alice", "", http.StatusOK},
+		{"UserExists", http.MethodHead, "/api/v1/users/123", "", http.StatusOK},
+		{"UserIDExists", http.MethodHead, "/api/v1/users/search?user_id=alice", "", http.StatusNotFound},
+		{"EnableTwoFactor", http.MethodPost, "/api/v1/users/me/2fa/enable", userToken, http.StatusOK},
+		{"EnableTwoFactor without token", http.MethodPost, "/api/v1/users/me/2fa/enable", "", http.StatusUnauthorized},
+		{"VerifyTwoFactor", http.MethodPost, "/api/v1/users/me/2fa/verify", userToken, http.StatusOK},
+		{"ListSessions", http.MethodGet, "/api/v1/users/me/sessions", userToken, http.StatusOK},
+		{"ListSessions without token", http.MethodGet, "/api/v1/users/me/sessions", "", http.StatusUnauthorized},
+		{"RevokeOtherSessions", http.MethodDelete, "/api/v1/users/me/sessions", userToken, http.StatusOK},
+		{"RevokeSession", http.MethodDelete, "/api/v1/users/me/sessions/665f1c2e8b3a4d2f9c1e7a10", userToken, http.StatusOK},
+		{"RevokeSession without token", http.MethodDelete, "/api/v1/users/me/sessions/665f1c2e8b3a4d2f9c1e7a10", "", http.StatusUnauthorized},
+		{"GetMe without token", http.MethodGet, "/api/v1/users/me", "", http.StatusUnauthorized},
+		{"ReplaceUser", http.MethodPut, "/api/v1/users/123", adminToken, http.StatusOK},
+		{"ReplaceUser without token", http.MethodPut, "/api/v1/users/123", "", http.StatusUnauthorized},
+		{"ReplaceUser as another user", http.MethodPut, "/api/v1/users/123", userToken, http.StatusForbidden},
+		{"UpdateUser", http.MethodPatch, "/api/v1/users/123", adminToken, http.StatusOK},
+		{"UpdateUser without token", http.MethodPatch, "/api/v1/users/123", "", http.StatusUnauthorized},
+		{"UpdateUser as another user", http.MethodPatch, "/api/v1/users/123", userToken, http.StatusForbidden},
+		{"UpdateUser as the user", http.MethodPatch, "/api/v1/users/" + self.Hex(), "Bearer " + selfToken, http.StatusOK},
+		{"DeleteUser", http.MethodDelete, "/api/v1/users/123", adminToken, http.StatusOK},
+		{"DeleteUser without token", http.MethodDelete, "/api/v1/users/123", "", http.StatusUnauthorized},
+		{"DeleteUser as regular user", http.MethodDelete, "/api/v1/users/123", userToken, http.StatusForbidden},
+		{"RestoreUser", http.MethodPost, "/api/v1/users/123/restore", adminToken, http.StatusOK},
+		{"RestoreUser without token", http.MethodPost, "/api/v1/users/123/restore", "", http.StatusUnauthorized},
+		{"RestoreUser as regular user", http.MethodPost, "/api/v1/users/123/restore", userToken, http.StatusForbidden},
+		{"SetUserRoles", http.MethodPut, "/api/v1/users/123/roles", adminToken, http.StatusOK},
+		{"ActivateUser", http.MethodPost, "/api/v1/users/123/activate", adminToken, http.StatusOK},
+		{"DeactivateUser", http.MethodPost, "/api/v1/users/123/deactivate", adminToken, http.StatusOK},
+		{"DeactivateUser as regular user", http.MethodPost, "/api/v1/users/123/deactivate", userToken, http.StatusForbidden},
+		{"GetUserAuditLog", http.MethodGet, "/api/v1/users/123/audit-log", adminToken, http.StatusOK},
+		{"GetUserAuditLog as regular user", http.MethodGet, "/api/v1/users/123/audit-log", userToken, http.StatusForbidden},
+		{"ListUsers", http.MethodGet, "/api/v1/users", adminToken, http.StatusOK},
+		{"ListUsers without token", http.MethodGet, "/api/v1/users", "", http.StatusUnauthorized},
+		{"ListInactiveUsers", http.MethodGet, "/api/v1/users/inactive?since=90d", adminToken, http.StatusOK},
+		{"ListInactiveUsers as regular user", http.MethodGet, "/api/v1/users/inactive", userToken, http.StatusForbidden},
+		{"ExportUsers", http.MethodGet, "/api/v1/users/export", adminToken, http.StatusOK},
+		{"ExportUsers as regular user", http.MethodGet, "/api/v1/users/export", userToken, http.StatusForbidden},
+		{"ExportUsersCSV", http.MethodGet, "/api/v1/users/export.csv", adminToken, http.StatusOK},
+		{"ExportUsersCSV without token", http.MethodGet, "/api/v1/users/export.csv", "", http.StatusUnauthorized},
+		{"ImportUsers", http.MethodPost, "/api/v1/users/import", adminToken, http.StatusOK},
+		{"ImportUsers as regular user", http.MethodPost, "/api/v1/users/import", userToken, http.StatusForbidden},
+		{"ListIndexes", http.MethodGet, "/api/v1/admin/indexes", adminToken, http.StatusOK},
+		{"ListIndexes as regular user", http.MethodGet, "/api/v1/admin/indexes", userToken, http.StatusForbidden},
+		{"ListIndexes without token", http.MethodGet, "/api/v1/admin/indexes", "", http.StatusUnauthorized},
+		{"AdminResetPassword", http.MethodPost, "/api/v1/admin/users/123/reset-password", adminToken, http.StatusOK},
+		{"AdminResetPassword as regular user", http.MethodPost, "/api/v1/admin/users/123/reset-password", userToken, http.StatusForbidden},
+		{"SetMaintenance", http.MethodPost, "/api/v1/admin/maintenance", adminToken, http.StatusOK},
+		{"SetMaintenance as regular user", http.MethodPost, "/api/v1/admin/maintenance", userToken, http.StatusForbidden},
+		{"CountUsers", http.MethodGet, "/api/v1/users/count", "", http.StatusOK},
+		{"GetUserStats", http.MethodGet, "/api/v1/users/stats", adminToken, http.StatusOK},
+		{"GetUserStats as regular user", http.MethodGet, "/api/v1/users/stats", userToken, http.StatusForbidden},
+		{"GetUserByUserID", http.MethodGet, "/api/v1/users/search?user_id=testuser", "", http.StatusOK},
+		{"GetUserByEmail", http.MethodGet, "/api/v1/users/search/email?email=test@example.com", "", http.StatusOK},
+		{"SearchUsers", http.MethodGet, "/api/v1/users/search?q=test", "", http.StatusOK},
+		{"Login", http.MethodPost, "/api/v1/auth/login", "", http.StatusOK},
+		{"Refresh", http.MethodPost, "/api/v1/auth/refresh", "", http.StatusOK},
+		{"Logout", http.MethodPost, "/api/v1/auth/logout", "", http.StatusOK},
+		{"ForgotPassword", http.MethodPost, "/api/v1/auth/forgot-password", "", http.StatusOK},
+		{"ResetPassword", http.MethodPost, "/api/v1/auth/reset-password", "", http.StatusOK},
+		{"VerifyEmail", http.MethodGet, "/api/v1/auth/verify?token=abc", "", http.StatusOK},
+		{"Health", http.MethodGet, "/health", "", http.StatusOK},
+		{"Liveness", http.MethodGet, "/health/live", "", http.StatusOK},
+		{"Readiness", http.MethodGet, "/health/ready", "", http.StatusOK},
+		{"Version", http.MethodGet, "/version", "", http.StatusOK},
+		{"Unversioned prefix", http.MethodGet, "/api/users/123", "", http.StatusNotFound},
+	}
+
 	for _, tc := range testRoutes {
 		t.Run(tc.name, func(t *testing.T) {
-			req := httptest.NewRequest(tc.method, tc.path, nil)
+			var body io.Reader
+			if tc.method != http.MethodGet && tc.method != http.MethodDelete {
+				body = strings.NewReader("{}")
+			}
+			if tc.name == "CreateUser" || strings.HasPrefix(tc.name, "ReplaceUser") {
+				// Create and replace are checked against their JSON Schema before the handler runs
+				body = strings.NewReader(validCreateUserBody)
+			}
+			req := httptest.NewRequest(tc.method, tc.path, body)
+			if tc.token != "" {
+				req.Header.Set(echo.HeaderAuthorization, tc.token)
+			}
 			rec := httptest.NewRecorder()
 			e.ServeHTTP(rec, req)
-			
+
 			if rec.Code != tc.statusCode {
 				t.Errorf("Expected status code %d, got %d", tc.statusCode, rec.Code)
 			}
@@ -87,76 +355,599 @@ func TestSetupRoutes(t *testing.T) {
 func TestGetUserSearchHandler(t *testing.T) {
 	// Create echo instance
 	e := echo.New()
-	
+
 	// Create a mock handler
 	mockHandler := &MockUserHandler{}
-	
+
 	// Test search handler with user_id
 	t.Run("Search by user_id", func(t *testing.T) {
-		req := httptest.NewRequest(http.MethodGet, "/api/users/search?user_id=testuser", nil)
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users/search?user_id=testuser", nil)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
-		
+
 		// Call the search handler
 		err := getUserSearchHandler(c, mockHandler)
 		if err != nil {
 			t.Errorf("Expected no error, got %v", err)
 		}
-		
+
 		if rec.Code != http.StatusOK {
 			t.Errorf("Expected status code %d, got %d", http.StatusOK, rec.Code)
 		}
 	})
-	
-	// Test search handler with email
+
+	// Email lookups live on /search/email, not /search
 	t.Run("Search by email", func(t *testing.T) {
-		req := httptest.NewRequest(http.MethodGet, "/api/users/search?email=test@example.com", nil)
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users/search?email=test@example.com", nil)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
-		
+
 		// Call the search handler
 		err := getUserSearchHandler(c, mockHandler)
-		if err != nil {
-			t.Errorf("Expected no error, got %v", err)
-		}
-		
-		if rec.Code != http.StatusOK {
-			t.Errorf("Expected status code %d, got %d", http.StatusOK, rec.Code)
+		var httpErr *echo.HTTPError
+		if !errors.As(err, &httpErr) || httpErr.Code != http.StatusBadRequest {
+			t.Errorf("Expected a %d error, got %v", http.StatusBadRequest, err)
 		}
 	})
-	
+
 	// Test search handler with no parameters
 	t.Run("Search with no parameters", func(t *testing.T) {
-		req := httptest.NewRequest(http.MethodGet, "/api/users/search", nil)
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users/search", nil)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
-		
+
 		// Call the search handler
 		err := getUserSearchHandler(c, mockHandler)
-		if err != nil {
-			t.Errorf("Expected no error, got %v", err)
-		}
-		
-		if rec.Code != http.StatusBadRequest {
-			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rec.Code)
+		var httpErr *echo.HTTPError
+		if !errors.As(err, &httpErr) || httpErr.Code != http.StatusBadRequest {
+			t.Errorf("Expected a %d error, got %v", http.StatusBadRequest, err)
 		}
 	})
-	
+
 	// Test search handler with both parameters
 	t.Run("Search with both parameters", func(t *testing.T) {
-		req := httptest.NewRequest(http.MethodGet, "/api/users/search?user_id=testuser&email=test@example.com", nil)
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users/search?user_id=testuser&email=test@example.com", nil)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
-		
+
 		// Call the search handler
 		err := getUserSearchHandler(c, mockHandler)
 		if err != nil {
 			t.Errorf("Expected no error, got %v", err)
 		}
-		
+
 		// First parameter takes precedence (user_id)
 		if rec.Code != http.StatusOK {
 			t.Errorf("Expected status code %d, got %d", http.StatusOK, rec.Code)
 		}
 	})
-}
\ No newline at end of file
+}
+func TestSetupRoutes_CustomPrefix(t *testing.T) {
+	e := echo.New()
+	cfg := newMockHandlers()
+	cfg.Prefix = "/internal/api/"
+	SetupRoutes(e, cfg)
+
+	tests := []struct {
+		name       string
+		path       string
+		statusCode int
+	}{
+		{"Custom prefix", "/internal/api/users/123", http.StatusOK},
+		{"Default prefix", "/api/v1/users/123", http.StatusNotFound},
+		{"Health stays at root", "/health", http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tc.statusCode {
+				t.Errorf("Expected status code %d, got %d", tc.statusCode, rec.Code)
+			}
+		})
+	}
+}
+
+func TestSetupRoutes_RequireBody(t *testing.T) {
+	setTestSecret(t)
+	adminToken := newBearerToken(t, models.RoleUser, models.RoleAdmin)
+
+	e := echo.New()
+	SetupRoutes(e, newMockHandlers())
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		statusCode int
+	}{
+		{"CreateUser", http.MethodPost, "/api/v1/users", "", http.StatusBadRequest},
+		{"ReplaceUser", http.MethodPut, "/api/v1/users/123", adminToken, http.StatusBadRequest},
+		{"UpdateUser", http.MethodPatch, "/api/v1/users/123", adminToken, http.StatusBadRequest},
+		{"SetUserRoles", http.MethodPut, "/api/v1/users/123/roles", adminToken, http.StatusBadRequest},
+		{"Login", http.MethodPost, "/api/v1/auth/login", "", http.StatusBadRequest},
+		{"Logout", http.MethodPost, "/api/v1/auth/logout", "", http.StatusBadRequest},
+		{"RestoreUser takes no body", http.MethodPost, "/api/v1/users/123/restore", adminToken, http.StatusOK},
+		{"DeactivateUser takes no body", http.MethodPost, "/api/v1/users/123/deactivate", adminToken, http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			if tc.token != "" {
+				req.Header.Set(echo.HeaderAuthorization, tc.token)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tc.statusCode {
+				t.Errorf("Expected status code %d, got %d", tc.statusCode, rec.Code)
+			}
+		})
+	}
+}
+
+func TestSetupRoutes_JSONSchema(t *testing.T) {
+	setTestSecret(t)
+	adminToken := newBearerToken(t, models.RoleUser, models.RoleAdmin)
+
+	e := echo.New()
+	SetupRoutes(e, newMockHandlers())
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		statusCode  int
+	}{
+		{"CreateUser missing fields", http.MethodPost, "/api/v1/users", echo.MIMEApplicationJSON, `{"user_id":"alice"}`, http.StatusBadRequest},
+		{"UpdateUser invalid email", http.MethodPatch, "/api/v1/users/123", echo.MIMEApplicationJSON, `{"email":"nope"}`, http.StatusBadRequest},
+		{"UpdateUser valid", http.MethodPatch, "/api/v1/users/123", echo.MIMEApplicationJSON, `{"first_name":"Alice"}`, http.StatusOK},
+		{"ReplaceUser missing fields", http.MethodPut, "/api/v1/users/123", echo.MIMEApplicationJSON, `{"user_id":"alice","email":"alice@example.com"}`, http.StatusBadRequest},
+		{"ReplaceUser valid", http.MethodPut, "/api/v1/users/123", echo.MIMEApplicationJSON, validCreateUserBody, http.StatusOK},
+		{"UpdateUser JSON Patch", http.MethodPatch, "/api/v1/users/123", "application/json-patch+json", `[{"op":"remove","path":"/last_name"}]`, http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set(echo.HeaderContentType, tc.contentType)
+			req.Header.Set(echo.HeaderAuthorization, adminToken)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tc.statusCode {
+				t.Errorf("Expected status code %d, got %d", tc.statusCode, rec.Code)
+			}
+		})
+	}
+}
+
+func TestSetupRoutes_AllowDestructive(t *testing.T) {
+	setTestSecret(t)
+	adminToken := newBearerToken(t, models.RoleUser, models.RoleAdmin)
+	userToken := newBearerToken(t, models.RoleUser)
+
+	tests := []struct {
+		name             string
+		allowDestructive bool
+		token            string
+		statusCode       int
+	}{
+		{"Disabled", false, adminToken, http.StatusMethodNotAllowed},
+		{"Enabled as admin", true, adminToken, http.StatusOK},
+		{"Enabled without token", true, "", http.StatusUnauthorized},
+		{"Enabled as regular user", true, userToken, http.StatusForbidden},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			cfg := newMockHandlers()
+			cfg.AllowDestructive = tc.allowDestructive
+			SetupRoutes(e, cfg)
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/v1/users", nil)
+			if tc.token != "" {
+				req.Header.Set(echo.HeaderAuthorization, tc.token)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tc.statusCode {
+				t.Errorf("Expected status code %d, got %d", tc.statusCode, rec.Code)
+			}
+		})
+	}
+}
+
+func TestSetupRoutes_AvailabilityRateLimit(t *testing.T) {
+	e := echo.New()
+	cfg := newMockHandlers()
+	cfg.RateLimitPerMinute = 1
+	cfg.AvailabilityRateLimitPerMinute = 2
+	SetupRoutes(e, cfg)
+
+	do := func(method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Spend the login budget first
+	login := `{"user_id":"alice","password":"s3cretpass"}`
+	if code := do(http.MethodPost, "/api/v1/auth/login", login); code != http.StatusOK {
+		t.Fatalf("Expected the first login to pass, got %d", code)
+	}
+	if code := do(http.MethodPost, "/api/v1/auth/login", login); code != http.StatusTooManyRequests {
+		t.Fatalf("Expected the second login to be limited, got %d", code)
+	}
+
+	// The availability check still has its own budget, and spending it leaves login as it was
+	for i := range 2 {
+		if code := do(http.MethodGet, "/api/v1/users/available?user_id=alice", ""); code != http.StatusOK {
+			t.Fatalf("Expected availability check %d to pass, got %d", i+1, code)
+		}
+	}
+	if code := do(http.MethodGet, "/api/v1/users/available?user_id=alice", ""); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the third availability check to be limited, got %d", code)
+	}
+}
+
+func TestSetupRoutes_APIKeys(t *testing.T) {
+	auth.SetAPIKeys([]auth.APIKey{
+		{Name: "reporting", Scope: auth.APIKeyScopeRead, Hash: auth.HashAPIKey("read-key")},
+		{Name: "sync", Scope: auth.APIKeyScopeWrite, Hash: auth.HashAPIKey("write-key")},
+	})
+	defer auth.SetAPIKeys(nil)
+
+	e := echo.New()
+	SetupRoutes(e, newMockHandlers())
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		apiKey     string
+		statusCode int
+	}{
+		{"ListUsers with a read key", http.MethodGet, "/api/v1/users", "read-key", http.StatusOK},
+		{"ExportUsers with a read key", http.MethodGet, "/api/v1/users/export", "read-key", http.StatusOK},
+		{"GetUserAuditLog with a read key", http.MethodGet, "/api/v1/users/123/audit-log", "read-key", http.StatusOK},
+		{"DeactivateUser with a read key", http.MethodPost, "/api/v1/users/123/deactivate", "read-key", http.StatusForbidden},
+		{"DeactivateUser with a write key", http.MethodPost, "/api/v1/users/123/deactivate", "write-key", http.StatusOK},
+		{"DeleteUser with a write key", http.MethodDelete, "/api/v1/users/123", "write-key", http.StatusOK},
+		{"RestoreUser with a write key", http.MethodPost, "/api/v1/users/123/restore", "write-key", http.StatusOK},
+		{"ListUsers with an unknown key", http.MethodGet, "/api/v1/users", "guess", http.StatusUnauthorized},
+		// Routes that act as the caller's own user still need a JWT
+		{"GetMe with a write key", http.MethodGet, "/api/v1/users/me", "write-key", http.StatusUnauthorized},
+		{"ListIndexes with a write key", http.MethodGet, "/api/v1/admin/indexes", "write-key", http.StatusUnauthorized},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			req.Header.Set(auth.HeaderAPIKey, tc.apiKey)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tc.statusCode {
+				t.Errorf("Expected status code %d, got %d", tc.statusCode, rec.Code)
+			}
+		})
+	}
+}
+
+func TestSetupRoutes_UserEvents(t *testing.T) {
+	setTestSecret(t)
+	adminToken := newBearerToken(t, models.RoleUser, models.RoleAdmin)
+	userToken := newBearerToken(t, models.RoleUser)
+
+	tests := []struct {
+		name       string
+		enabled    bool
+		token      string
+		statusCode int
+	}{
+		// Without the stream, /users/events is taken for a user ID
+		{"Disabled", false, adminToken, http.StatusOK},
+		{"Enabled as admin", true, adminToken, http.StatusOK},
+		{"Enabled without token", true, "", http.StatusUnauthorized},
+		{"Enabled as regular user", true, userToken, http.StatusForbidden},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			cfg := newMockHandlers()
+			cfg.UserEvents = tc.enabled
+			SetupRoutes(e, cfg)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/users/events", nil)
+			if tc.token != "" {
+				req.Header.Set(echo.HeaderAuthorization, tc.token)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tc.statusCode {
+				t.Errorf("Expected status code %d, got %d", tc.statusCode, rec.Code)
+			}
+			if streamed := strings.Contains(rec.Body.String(), "events streamed"); streamed != (tc.enabled && rec.Code == http.StatusOK) {
+				t.Errorf("Expected the stream to be served only when enabled, got %q", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestSetupRoutes_Tenant(t *testing.T) {
+	setTestSecret(t)
+	acmeAdmin, err := auth.GenerateTenantToken(&models.User{ID: bson.NewObjectID(), UserID: "admin", Roles: []string{models.RoleAdmin}}, "acme")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	// Stands in for handlers.ResolveTenant with a single allowed tenant
+	tenant := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.Request().Header.Get(auth.HeaderTenantID) != "acme" {
+				return c.NoContent(http.StatusBadRequest)
+			}
+			c.SetRequest(c.Request().WithContext(auth.WithTenant(c.Request().Context(), "acme")))
+			return next(c)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		tenant     string
+		token      string
+		statusCode int
+	}{
+		{"API route", http.MethodGet, "/api/v1/users/count", "acme", "", http.StatusOK},
+		{"API route without tenant", http.MethodGet, "/api/v1/users/count", "", "", http.StatusBadRequest},
+		{"Admin route with a tenant token", http.MethodGet, "/api/v1/users", "acme", "Bearer " + acmeAdmin, http.StatusOK},
+		{"Admin route with a token of no tenant", http.MethodGet, "/api/v1/users", "acme", newBearerToken(t, models.RoleAdmin), http.StatusUnauthorized},
+		{"Health without tenant", http.MethodGet, "/health/live", "", "", http.StatusOK},
+		// Tenant resolution does not turn a wrong method into 404
+		{"Wrong method", http.MethodPost, "/api/v1/users/count", "acme", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			cfg := newMockHandlers()
+			cfg.Tenant = tenant
+			SetupRoutes(e, cfg)
+
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.tenant != "" {
+				req.Header.Set(auth.HeaderTenantID, tc.tenant)
+			}
+			if tc.token != "" {
+				req.Header.Set(echo.HeaderAuthorization, tc.token)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tc.statusCode {
+				t.Errorf("Expected status code %d, got %d", tc.statusCode, rec.Code)
+			}
+		})
+	}
+}
+
+func TestNormalizePrefix(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"", DefaultAPIPrefix},
+		{"/", DefaultAPIPrefix},
+		{"/api/v2", "/api/v2"},
+		{"api/v2/", "/api/v2"},
+		{" /v1 ", "/v1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := normalizePrefix(tt.value); got != tt.expected {
+				t.Errorf("Expected '%s', got '%s'", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestUploadPaths(t *testing.T) {
+	e := echo.New()
+	cfg := newMockHandlers()
+	cfg.Prefix = "/api/v2"
+	SetupRoutes(e, cfg)
+
+	registered := make(map[string]bool)
+	for _, route := range e.Routes() {
+		registered[route.Path] = true
+	}
+	for _, path := range UploadPaths("/api/v2") {
+		if !registered[path] {
+			t.Errorf("Expected upload path '%s' to be a registered route", path)
+		}
+	}
+}
+
+func TestStreamingPaths(t *testing.T) {
+	e := echo.New()
+	cfg := newMockHandlers()
+	cfg.Prefix = "/api/v2"
+	cfg.UserEvents = true
+	SetupRoutes(e, cfg)
+
+	registered := make(map[string]bool)
+	for _, route := range e.Routes() {
+		registered[route.Path] = true
+	}
+	for _, path := range StreamingPaths("/api/v2") {
+		if !registered[path] {
+			t.Errorf("Expected streaming path '%s' to be a registered route", path)
+		}
+	}
+}
+
+func TestMaintenancePaths(t *testing.T) {
+	e := echo.New()
+	cfg := newMockHandlers()
+	cfg.Prefix = "/api/v2"
+	SetupRoutes(e, cfg)
+
+	registered := make(map[string]bool)
+	for _, route := range e.Routes() {
+		registered[route.Method+" "+route.Path] = true
+	}
+	for _, path := range MaintenancePaths("/api/v2") {
+		if !registered[http.MethodPost+" "+path] {
+			t.Errorf("Expected maintenance path '%s' to be a registered POST route", path)
+		}
+	}
+}
+
+func TestSetupRoutes_TrailingSlash(t *testing.T) {
+	setTestSecret(t)
+	adminToken := newBearerToken(t, models.RoleUser, models.RoleAdmin)
+
+	// main.go strips trailing slashes before routing
+	e := echo.New()
+	e.Pre(middleware.RemoveTrailingSlash())
+	SetupRoutes(e, newMockHandlers())
+
+	tests := []struct {
+		path       string
+		statusCode int
+	}{
+		{"/api/v1/users", http.StatusOK},
+		{"/api/v1/users/", http.StatusOK},
+		{"/api/v1/users/123", http.StatusOK},
+		{"/api/v1/users/123/", http.StatusOK},
+		{"/health/", http.StatusOK},
+		{"/swagger/", http.StatusMovedPermanently},
+		{"/swagger/index.html", http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.Header.Set(echo.HeaderAuthorization, adminToken)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tc.statusCode {
+				t.Errorf("Expected status code %d, got %d", tc.statusCode, rec.Code)
+			}
+		})
+	}
+
+	// A POST with a trailing slash keeps its body
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users/", strings.NewReader(validCreateUserBody))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Errorf("Expected status code %d, got %d", http.StatusCreated, rec.Code)
+	}
+}
+
+func TestSetupRoutes_MethodNotAllowed(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = handlers.HTTPErrorHandler
+	SetupRoutes(e, newMockHandlers())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users/123", strings.NewReader("{}"))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected status code %d, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+
+	allow := strings.Split(rec.Header().Get(echo.HeaderAllow), ", ")
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		if !slices.Contains(allow, method) {
+			t.Errorf("Expected Allow to list %s, got %v", method, allow)
+		}
+	}
+
+	var body handlers.ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Expected a JSON body, got %q: %v", rec.Body.String(), err)
+	}
+	if body.Error != "Method Not Allowed" {
+		t.Errorf("Expected error 'Method Not Allowed', got '%s'", body.Error)
+	}
+}
+
+func TestSetupRoutes_MiddlewareErrors(t *testing.T) {
+	setTestSecret(t)
+	userToken := newBearerToken(t, models.RoleUser)
+
+	e := echo.New()
+	e.HTTPErrorHandler = handlers.HTTPErrorHandler
+	e.Use(middleware.RequestID())
+	cfg := newMockHandlers()
+	cfg.AvailabilityRateLimitPerMinute = 1
+	SetupRoutes(e, cfg)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		token      string
+		statusCode int
+		message    string
+	}{
+		{"Missing body", http.MethodPost, "/api/v1/users", "", "", http.StatusBadRequest, "Request body is required"},
+		{"Rate limited", http.MethodGet, "/api/v1/users/available?user_id=alice", "", "", http.StatusTooManyRequests, "Rate limit exceeded"},
+		{"Missing token", http.MethodGet, "/api/v1/users/me", "", "", http.StatusUnauthorized, "Missing bearer token"},
+		{"Wrong role", http.MethodGet, "/api/v1/users/stats", "", userToken, http.StatusForbidden, "Insufficient permissions"},
+		{"Another user", http.MethodPatch, "/api/v1/users/" + bson.NewObjectID().Hex(), "{}", userToken, http.StatusForbidden, "Insufficient permissions"},
+		{"Missing search parameter", http.MethodGet, "/api/v1/users/search", "", "", http.StatusBadRequest, "Missing search parameter: q or user_id is required (use /search/email for email)"},
+	}
+
+	// Spend the availability budget, so that the next check is limited
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/users/available?user_id=alice", nil))
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			if tc.token != "" {
+				req.Header.Set(echo.HeaderAuthorization, tc.token)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tc.statusCode {
+				t.Fatalf("Expected status code %d, got %d", tc.statusCode, rec.Code)
+			}
+			var body handlers.ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Expected a JSON body, got %q: %v", rec.Body.String(), err)
+			}
+			if body.Error != tc.message {
+				t.Errorf("Expected error '%s', got '%s'", tc.message, body.Error)
+			}
+			if body.RequestID == "" || body.RequestID != rec.Header().Get(echo.HeaderXRequestID) {
+				t.Errorf("Expected the request ID in the body, got %+v", body)
+			}
+		})
+	}
+}
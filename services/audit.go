@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"go-mongodb-test/auth"
+	"go-mongodb-test/models"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// auditCollection stores the audit trail of changes to users
+const auditCollection = "audit_events"
+
+// recordAudit appends an event for a change that has already been written, and
+// publishes it to the EventHub unless WatchUserEvents does that. The actor comes
+// from the claims in ctx. A failure is logged rather than returned, since the change
+// itself stands either way; without MongoDB nothing is recorded, though events are
+// still published.
+func (s *UserService) recordAudit(ctx context.Context, target bson.ObjectID, action string, fields []string) {
+	publish := s.publishLocally()
+	if s.auditEvents == nil && !publish {
+		return
+	}
+
+	ctx, span := startSpanOn(ctx, "RecordAudit", auditCollection, "insertOne")
+	defer span.End()
+
+	// As with users, the ID is assigned here so that it decodes as a v2 bson.ObjectID
+	event := &models.AuditEvent{
+		ID:        bson.NewObjectID(),
+		TargetID:  target,
+		Action:    action,
+		Fields:    fields,
+		CreatedAt: s.now(),
+	}
+	if claims, ok := auth.ClaimsFrom(ctx); ok {
+		event.ActorID = claims.ID
+		event.ActorUserID = claims.UserID
+	}
+
+	if s.auditEvents != nil {
+		if _, err := s.auditEvents.InsertOne(ctx, event); err != nil {
+			slog.ErrorContext(ctx, "Failed to record audit event",
+				"error", spanError(span, err), "user", target.Hex(), "action", action)
+		}
+	}
+	if publish {
+		s.events.Publish(event)
+	}
+}
+
+// auditFields lists the stored fields an update sets or removes, in a stable
+// order. Derived fields such as email_domain follow from the ones listed.
+func auditFields(update UserUpdate) []string {
+	fields := make([]string, 0, len(update.Set)+len(update.Remove))
+	for field := range update.Set {
+		if field != "email_domain" {
+			fields = append(fields, field)
+		}
+	}
+	fields = append(fields, update.Remove...)
+	slices.Sort(fields)
+	return fields
+}
+
+// ListAuditEvents returns a page of the audit trail of the user with the given ID,
+// most recent first. Deleted users keep their trail.
+func (s *UserService) ListAuditEvents(ctx context.Context, id string, limit, offset int) ([]*models.AuditEvent, error) {
+	ctx, span := startSpanOn(ctx, "ListAuditEvents", auditCollection, "find")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidUserID, err)
+	}
+
+	if err := s.requireCollections(); err != nil {
+		return nil, err
+	}
+
+	// IDs grow with insertion, so they order the events without a compound index
+	findOptions := options.Find().
+		SetSort(bson.M{"_id": -1}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+
+	cursor, err := s.auditEvents.Find(ctx, bson.M{"target_id": objectID}, findOptions)
+	if err != nil {
+		return nil, spanError(span, fmt.Errorf("failed to get audit events: %w", err))
+	}
+	defer cursor.Close(ctx)
+
+	events := []*models.AuditEvent{}
+	for cursor.Next(ctx) {
+		var event models.AuditEvent
+		if err := cursor.Decode(&event); err != nil {
+			return nil, spanError(span, fmt.Errorf("failed to decode audit event: %w", err))
+		}
+		events = append(events, &event)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, spanError(span, fmt.Errorf("failed to read audit events: %w", err))
+	}
+
+	return events, nil
+}
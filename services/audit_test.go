@@ -0,0 +1,173 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go-mongodb-test/auth"
+	"go-mongodb-test/models"
+
+	v1bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// auditRecorded answers the insert of an audit event that follows each change
+var auditRecorded = mtest.CreateSuccessResponse()
+
+func TestAuditFields(t *testing.T) {
+	fields := auditFields(UserUpdate{
+		Set:    map[string]interface{}{"password": "hash", "email": "a@example.com", "email_domain": "example.com"},
+		Remove: []string{"avatar_url"},
+	})
+	if expected := []string{"avatar_url", "email", "password"}; !reflect.DeepEqual(fields, expected) {
+		t.Errorf("Expected %v, got %v", expected, fields)
+	}
+}
+
+func TestUpdateUser_RecordsAudit(t *testing.T) {
+	ctx := auth.WithClaims(context.Background(), &auth.Claims{ID: "665f1c2e8b3a4d2f9c1e7a0f", UserID: "admin"})
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	id := bson.NewObjectID()
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("records the changed fields and the actor", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch), // email uniqueness
+			mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 1}, v1bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 1}), // refresh token revocation
+			auditRecorded,
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, v1bson.D{{Key: "_id", Value: id}}),
+		)
+
+		email, password := "alice@example.com", "n3wpassword"
+		service := NewUserService(mt.DB, WithClock(func() time.Time { return now }))
+		if _, err := service.UpdateUser(ctx, id.Hex(), &models.UpdateUserRequest{Email: &email, Password: &password}, nil); err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+
+		mt.GetStartedEvent() // find
+		mt.GetStartedEvent() // update
+		mt.GetStartedEvent() // delete
+		insert := mt.GetStartedEvent()
+		if insert == nil || insert.CommandName != "insert" || insert.Command.Lookup("insert").StringValue() != auditCollection {
+			mt.Fatalf("Expected an insert into %s, got %v", auditCollection, insert)
+		}
+
+		var event models.AuditEvent
+		if err := v1bson.Unmarshal(insert.Command.Lookup("documents").Array().Index(0).Value().Document(), &event); err != nil {
+			mt.Fatalf("Failed to decode audit event: %v", err)
+		}
+		expected := models.AuditEvent{
+			TargetID:    id,
+			Action:      models.AuditActionUpdate,
+			Fields:      []string{"email", "password"},
+			ActorID:     "665f1c2e8b3a4d2f9c1e7a0f",
+			ActorUserID: "admin",
+			CreatedAt:   now,
+		}
+		if event.ID.IsZero() {
+			mt.Error("Expected the event to be given an ID")
+		}
+		event.ID = bson.NilObjectID
+		event.CreatedAt = event.CreatedAt.UTC()
+		if !reflect.DeepEqual(event, expected) {
+			mt.Errorf("Expected %+v, got %+v", expected, event)
+		}
+
+		raw := insert.Command.Lookup("documents").Array().Index(0).Value().Document()
+		if _, err := raw.LookupErr("password"); err == nil {
+			mt.Error("Expected the audit event to leave out the password value")
+		}
+	})
+}
+
+func TestDeleteUser_RecordsAudit(t *testing.T) {
+	ctx := context.Background()
+	id := bson.NewObjectID()
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("records the delete without an actor", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(v1bson.E{Key: "value", Value: v1bson.D{{Key: "_id", Value: id}, {Key: "user_id", Value: "alice"}}}),
+			auditRecorded,
+		)
+
+		if _, err := NewUserService(mt.DB).DeleteUser(ctx, id.Hex()); err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+
+		mt.GetStartedEvent() // findAndModify
+		stored := mt.GetStartedEvent().Command.Lookup("documents").Array().Index(0).Value().Document()
+		if action := stored.Lookup("action").StringValue(); action != models.AuditActionDelete {
+			mt.Errorf("Expected a delete event, got '%s'", action)
+		}
+		if _, err := stored.LookupErr("actor_id"); err == nil {
+			mt.Errorf("Expected no actor for an unauthenticated request, got %v", stored)
+		}
+	})
+}
+
+func TestListAuditEvents(t *testing.T) {
+	ctx := context.Background()
+	id := bson.NewObjectID()
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("returns a page, most recent first", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.audit_events", mtest.FirstBatch,
+			v1bson.D{{Key: "_id", Value: bson.NewObjectID()}, {Key: "target_id", Value: id}, {Key: "action", Value: "update"}, {Key: "fields", Value: v1bson.A{"email"}}},
+			v1bson.D{{Key: "_id", Value: bson.NewObjectID()}, {Key: "target_id", Value: id}, {Key: "action", Value: "create"}},
+		))
+
+		events, err := NewUserService(mt.DB).ListAuditEvents(ctx, id.Hex(), 2, 4)
+		if err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		if len(events) != 2 || events[0].Action != models.AuditActionUpdate || events[0].Fields[0] != "email" {
+			mt.Errorf("Expected both events in order, got %+v", events)
+		}
+
+		started := mt.GetStartedEvent()
+		if started == nil || started.Command.Lookup("find").StringValue() != auditCollection {
+			mt.Fatalf("Expected a find on %s, got %v", auditCollection, started)
+		}
+		if target := started.Command.Lookup("filter", "target_id"); !bytes.HasSuffix(target.Value, id[:]) {
+			mt.Errorf("Expected events of %s, got %v", id.Hex(), target)
+		}
+		if order := started.Command.Lookup("sort", "_id").AsInt64(); order != -1 {
+			mt.Errorf("Expected newest first, got %d", order)
+		}
+		if skip, limit := started.Command.Lookup("skip").AsInt64(), started.Command.Lookup("limit").AsInt64(); skip != 4 || limit != 2 {
+			mt.Errorf("Expected skip 4 and limit 2, got %d and %d", skip, limit)
+		}
+	})
+
+	mt.Run("no events", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.audit_events", mtest.FirstBatch))
+
+		events, err := NewUserService(mt.DB).ListAuditEvents(ctx, id.Hex(), 50, 0)
+		if err != nil || events == nil || len(events) != 0 {
+			mt.Errorf("Expected an empty, non-nil list, got %v (error: %v)", events, err)
+		}
+	})
+
+	t.Run("invalid ID", func(t *testing.T) {
+		if _, err := NewUserService(&MockDatabase{}).ListAuditEvents(ctx, "invalid", 50, 0); !errors.Is(err, ErrInvalidUserID) {
+			t.Errorf("Expected ErrInvalidUserID, got %v", err)
+		}
+	})
+
+	t.Run("without collections", func(t *testing.T) {
+		service := NewUserServiceWithRepository(NewInMemoryUserRepository())
+		if _, err := service.ListAuditEvents(ctx, id.Hex(), 50, 0); !errors.Is(err, ErrStorageUnsupported) {
+			t.Errorf("Expected ErrStorageUnsupported, got %v", err)
+		}
+	})
+}
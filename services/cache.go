@@ -0,0 +1,60 @@
+package services
+
+import (
+	"slices"
+	"time"
+
+	"go-mongodb-test/models"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+)
+
+// userCache keeps recently read users keyed by their hex ID. A nil cache is
+// valid and caches nothing, so callers never need to check whether it is enabled.
+type userCache struct {
+	users *expirable.LRU[string, models.User]
+}
+
+// newUserCache holds up to size users, each for at most ttl
+func newUserCache(size int, ttl time.Duration) *userCache {
+	return &userCache{users: expirable.NewLRU[string, models.User](size, nil, ttl)}
+}
+
+// get returns a copy of the cached user so callers cannot modify the cached entry
+func (c *userCache) get(id string) (*models.User, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	user, ok := c.users.Get(id)
+	if !ok {
+		return nil, false
+	}
+	user.Roles = slices.Clone(user.Roles)
+	return &user, true
+}
+
+func (c *userCache) add(user *models.User) {
+	if c == nil {
+		return
+	}
+
+	cached := *user
+	cached.Roles = slices.Clone(user.Roles)
+	c.users.Add(user.ID.Hex(), cached)
+}
+
+func (c *userCache) remove(id string) {
+	if c == nil {
+		return
+	}
+	c.users.Remove(id)
+}
+
+// purge drops every entry, for writes that cannot tell which users they touched
+func (c *userCache) purge() {
+	if c == nil {
+		return
+	}
+	c.users.Purge()
+}
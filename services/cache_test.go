@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-mongodb-test/models"
+
+	v1bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestUserCache(t *testing.T) {
+	cache := newUserCache(2, time.Minute)
+	user := &models.User{ID: bson.NewObjectID(), UserID: "alice", Roles: []string{models.RoleUser}}
+	cache.add(user)
+
+	cached, ok := cache.get(user.ID.Hex())
+	if !ok || cached.UserID != "alice" {
+		t.Fatalf("Expected cached user, got %v", cached)
+	}
+
+	// Changes to a returned copy must not leak into the cache
+	cached.UserID = "mallory"
+	cached.Roles[0] = models.RoleAdmin
+	again, _ := cache.get(user.ID.Hex())
+	if again.UserID != "alice" || again.Roles[0] != models.RoleUser {
+		t.Errorf("Expected cached entry to be unchanged, got %+v", again)
+	}
+
+	cache.remove(user.ID.Hex())
+	if _, ok := cache.get(user.ID.Hex()); ok {
+		t.Error("Expected entry to be removed")
+	}
+}
+
+func TestUserCache_Nil(t *testing.T) {
+	var cache *userCache
+	cache.add(&models.User{ID: bson.NewObjectID()})
+	cache.remove("anything")
+	cache.purge()
+
+	if _, ok := cache.get("anything"); ok {
+		t.Error("Expected a nil cache to hold nothing")
+	}
+}
+
+func TestWithCache_Disabled(t *testing.T) {
+	if service := NewUserService(&MockDatabase{}, WithCache(0, time.Minute)); service.cache != nil {
+		t.Error("Expected a zero size to leave caching off")
+	}
+}
+
+func TestGetUserByID_Cache(t *testing.T) {
+	ctx := context.Background()
+	id := bson.NewObjectID()
+	doc := v1bson.D{
+		{Key: "_id", Value: id},
+		{Key: "user_id", Value: "alice"},
+		{Key: "email", Value: "alice@example.com"},
+	}
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("repeated reads hit the cache", func(mt *mtest.T) {
+		service := NewUserService(mt.DB, WithCache(10, time.Minute))
+
+		// Only one response is queued, so a second query would fail
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, doc))
+		for i := 0; i < 2; i++ {
+			if _, err := service.GetUserByID(ctx, id.Hex()); err != nil {
+				mt.Fatalf("Read %d: expected no error, got %v", i+1, err)
+			}
+		}
+	})
+
+	mt.Run("delete invalidates the entry", func(mt *mtest.T) {
+		service := NewUserService(mt.DB, WithCache(10, time.Minute))
+
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, doc),
+			mtest.CreateSuccessResponse(v1bson.E{Key: "value", Value: doc}),
+			auditRecorded,
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch),
+		)
+
+		if _, err := service.GetUserByID(ctx, id.Hex()); err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		if _, err := service.DeleteUser(ctx, id.Hex()); err != nil {
+			mt.Fatalf("Expected no error deleting, got %v", err)
+		}
+		if _, err := service.GetUserByID(ctx, id.Hex()); !errors.Is(err, ErrUserNotFound) {
+			mt.Errorf("Expected ErrUserNotFound after delete, got %v", err)
+		}
+	})
+
+	mt.Run("update invalidates the entry", func(mt *mtest.T) {
+		service := NewUserService(mt.DB, WithCache(10, time.Minute))
+		updated := append(v1bson.D{}, doc...)
+		updated[1] = v1bson.E{Key: "user_id", Value: "alice2"}
+
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, doc),
+			// user_id uniqueness lookup finds nobody
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch),
+			mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 1}, v1bson.E{Key: "nModified", Value: 1}),
+			auditRecorded,
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, updated),
+		)
+
+		if _, err := service.GetUserByID(ctx, id.Hex()); err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+
+		newUserID := "alice2"
+		user, err := service.UpdateUser(ctx, id.Hex(), &models.UpdateUserRequest{UserID: &newUserID}, nil)
+		if err != nil {
+			mt.Fatalf("Expected no error updating, got %v", err)
+		}
+		if user.UserID != "alice2" {
+			mt.Errorf("Expected the updated user to be read from MongoDB, got '%s'", user.UserID)
+		}
+
+		cached, err := service.GetUserByID(ctx, id.Hex())
+		if err != nil || cached.UserID != "alice2" {
+			mt.Errorf("Expected the refreshed entry to be cached, got %v (error: %v)", cached, err)
+		}
+	})
+}
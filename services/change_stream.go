@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"go-mongodb-test/models"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// unwatchedFields change as a side effect of logins and updates, so a change to
+// them alone is not reported, just as the audit trail leaves such writes out
+var unwatchedFields = []string{"updated_at", "email_domain", "failed_logins", "locked_until", "last_login_at"}
+
+// userChange is the part of a change stream event on the users collection that
+// WatchUsers reads
+type userChange struct {
+	OperationType string `bson:"operationType"`
+	DocumentKey   struct {
+		ID bson.ObjectID `bson:"_id"`
+	} `bson:"documentKey"`
+	FullDocument      *models.User `bson:"fullDocument"`
+	UpdateDescription struct {
+		UpdatedFields bson.M   `bson:"updatedFields"`
+		RemovedFields []string `bson:"removedFields"`
+	} `bson:"updateDescription"`
+	// WallTime is only set by MongoDB 6.0 and later
+	WallTime time.Time `bson:"wallTime"`
+}
+
+// WatchUsers opens a change stream on the users collection and returns the changes
+// made to it by any client, this and other instances included, as audit events
+// without an actor. The channel is closed once ctx is done or the stream fails.
+// Change streams need a replica set or sharded cluster; on a standalone server the
+// error is returned straight away.
+func (s *UserService) WatchUsers(ctx context.Context) (<-chan *models.AuditEvent, error) {
+	if err := s.requireCollections(); err != nil {
+		return nil, err
+	}
+
+	pipeline := bson.A{bson.M{"$match": bson.M{
+		"operationType": bson.M{"$in": bson.A{"insert", "update", "replace", "delete"}},
+	}}}
+	stream, err := s.collection.Watch(ctx, pipeline, options.ChangeStream().SetFullDocument(options.Default))
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch users: %w", err)
+	}
+
+	events := make(chan *models.AuditEvent)
+	go func() {
+		defer close(events)
+		defer stream.Close(context.Background())
+
+		for stream.Next(ctx) {
+			var change userChange
+			if err := stream.Decode(&change); err != nil {
+				slog.ErrorContext(ctx, "Failed to decode user change", "error", err)
+				continue
+			}
+			event := s.changeEvent(&change)
+			if event == nil {
+				continue
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := stream.Err(); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "User change stream failed", "error", err)
+		}
+	}()
+	return events, nil
+}
+
+// changeEvent turns a change into the audit event the service would have recorded
+// for it, or nil when only unwatchedFields changed
+func (s *UserService) changeEvent(change *userChange) *models.AuditEvent {
+	event := &models.AuditEvent{
+		ID:        bson.NewObjectID(),
+		TargetID:  change.DocumentKey.ID,
+		CreatedAt: change.WallTime,
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = s.now()
+	}
+
+	switch change.OperationType {
+	case "insert":
+		event.Action = models.AuditActionCreate
+		if change.FullDocument != nil {
+			event.Fields = createdFields(change.FullDocument)
+		}
+	case "delete":
+		event.Action = models.AuditActionDelete
+	case "replace":
+		event.Action = models.AuditActionUpdate
+	default:
+		// Soft deletes are updates that set deleted_at; restores remove it again
+		if deletedAt, ok := change.UpdateDescription.UpdatedFields["deleted_at"]; ok && deletedAt != nil {
+			event.Action = models.AuditActionDelete
+			return event
+		}
+		event.Action = models.AuditActionUpdate
+		for field := range change.UpdateDescription.UpdatedFields {
+			event.Fields = append(event.Fields, field)
+		}
+		event.Fields = append(event.Fields, change.UpdateDescription.RemovedFields...)
+		event.Fields = slices.DeleteFunc(event.Fields, func(field string) bool {
+			return slices.Contains(unwatchedFields, field)
+		})
+		if len(event.Fields) == 0 {
+			return nil
+		}
+		slices.Sort(event.Fields)
+	}
+	return event
+}
+
+// WatchUserEvents feeds the EventHub from WatchUsers, so that subscribers of every
+// instance see every change, in place of publishing this instance's own writes.
+// When the stream cannot be opened, such as on a standalone server, the error is
+// returned and the hub keeps receiving local writes only. Should the stream fail
+// later, publishing falls back to local writes in the same way.
+func (s *UserService) WatchUserEvents(ctx context.Context) error {
+	if s.events == nil {
+		return ErrEventsDisabled
+	}
+
+	changes, err := s.WatchUsers(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.eventsWatched.Store(true)
+	go func() {
+		defer s.eventsWatched.Store(false)
+		for event := range changes {
+			s.events.Publish(event)
+		}
+	}()
+	return nil
+}
+
+// publishLocally reports whether recordAudit should publish changes itself, which
+// it does unless WatchUserEvents already sees them
+func (s *UserService) publishLocally() bool {
+	return s.events != nil && !s.eventsWatched.Load()
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"go-mongodb-test/models"
+
+	v1bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestChangeEvent(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	service := NewUserServiceWithRepository(NewInMemoryUserRepository(), WithClock(func() time.Time { return now }))
+
+	change := func(operation string, updated bson.M, removed ...string) *userChange {
+		c := &userChange{OperationType: operation}
+		c.UpdateDescription.UpdatedFields = updated
+		c.UpdateDescription.RemovedFields = removed
+		return c
+	}
+	inserted := change("insert", nil)
+	inserted.FullDocument = &models.User{UserID: "alice", Email: "alice@example.com", FirstName: "Alice"}
+
+	tests := []struct {
+		name           string
+		change         *userChange
+		expectedAction string
+		expectedFields []string
+	}{
+		{"Insert", inserted, models.AuditActionCreate, []string{"user_id", "email", "password", "first_name"}},
+		{"Update", change("update", bson.M{"email": "a@corp.example.com", "email_domain": "corp.example.com", "updated_at": now}, "avatar_url"), models.AuditActionUpdate, []string{"avatar_url", "email"}},
+		{"Soft delete", change("update", bson.M{"deleted_at": now, "updated_at": now}), models.AuditActionDelete, nil},
+		{"Restore", change("update", bson.M{"updated_at": now}, "deleted_at"), models.AuditActionUpdate, []string{"deleted_at"}},
+		{"Replace", change("replace", nil), models.AuditActionUpdate, nil},
+		{"Hard delete", change("delete", nil), models.AuditActionDelete, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := service.changeEvent(tt.change)
+			if event == nil {
+				t.Fatal("Expected an event")
+			}
+			if event.Action != tt.expectedAction || !reflect.DeepEqual(event.Fields, tt.expectedFields) {
+				t.Errorf("Expected %s of %v, got %s of %v", tt.expectedAction, tt.expectedFields, event.Action, event.Fields)
+			}
+			if !event.CreatedAt.Equal(now) || event.ID.IsZero() {
+				t.Errorf("Expected an ID and the current time without a wall time, got %+v", event)
+			}
+		})
+	}
+
+	t.Run("Login bookkeeping", func(t *testing.T) {
+		if event := service.changeEvent(change("update", bson.M{"last_login_at": now}, "failed_logins", "locked_until")); event != nil {
+			t.Errorf("Expected no event, got %+v", event)
+		}
+	})
+}
+
+func TestWatchUserEvents(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("publishes the changes of every client", func(mt *mtest.T) {
+		id := bson.NewObjectID()
+		mt.AddMockResponses(mtest.CreateCursorResponse(1, "test.users", mtest.FirstBatch, v1bson.D{
+			{Key: "_id", Value: v1bson.D{{Key: "_data", Value: "token"}}},
+			{Key: "operationType", Value: "update"},
+			{Key: "documentKey", Value: v1bson.D{{Key: "_id", Value: id}}},
+			{Key: "updateDescription", Value: v1bson.D{
+				{Key: "updatedFields", Value: v1bson.D{{Key: "first_name", Value: "Alice"}}},
+				{Key: "removedFields", Value: v1bson.A{}},
+			}},
+		}))
+
+		service := NewUserService(mt.DB, WithEventHub(NewEventHub()))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events, err := service.SubscribeUserEvents(ctx)
+		if err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		if err := service.WatchUserEvents(ctx); err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		if service.publishLocally() {
+			mt.Error("Expected local publishing to stop while the change stream is watched")
+		}
+
+		select {
+		case event := <-events:
+			if event.Action != models.AuditActionUpdate || event.TargetID != id || !reflect.DeepEqual(event.Fields, []string{"first_name"}) {
+				mt.Errorf("Expected the first_name update, got %+v", event)
+			}
+		case <-time.After(time.Second):
+			mt.Fatal("Expected an event from the change stream")
+		}
+
+		// Once the stream ends, local writes are published again
+		cancel()
+		for deadline := time.Now().Add(time.Second); !service.publishLocally(); time.Sleep(time.Millisecond) {
+			if time.Now().After(deadline) {
+				mt.Fatal("Expected local publishing to resume after the change stream ended")
+			}
+		}
+
+		watch := mt.GetStartedEvent()
+		if watch == nil || watch.CommandName != "aggregate" || watch.Command.Lookup("aggregate").StringValue() != usersCollection {
+			mt.Errorf("Expected an aggregate on %s, got %v", usersCollection, watch)
+		}
+	})
+
+	mt.Run("falls back without change streams", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{
+			Code:    40573,
+			Name:    "Location40573",
+			Message: "The $changeStream stage is only supported on replica sets",
+		}))
+
+		service := NewUserService(mt.DB, WithEventHub(NewEventHub()))
+		if err := service.WatchUserEvents(context.Background()); err == nil {
+			mt.Fatal("Expected an error on a standalone server")
+		}
+		if !service.publishLocally() {
+			mt.Error("Expected local writes to keep being published")
+		}
+	})
+}
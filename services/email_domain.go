@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go-mongodb-test/models"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// GetUsersByDomain returns the users whose email is in domain, compared without
+// case. It matches the stored email_domain with equality, so the query is served by
+// its index; users stored before the field existed only match once
+// BackfillEmailDomains has run.
+func (s *UserService) GetUsersByDomain(ctx context.Context, domain string) ([]*models.User, error) {
+	ctx, span := startSpan(ctx, "GetUsersByDomain", "find")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	filter := notDeleted(bson.M{"email_domain": strings.ToLower(strings.TrimSpace(domain))})
+
+	if err := s.requireCollections(); err != nil {
+		return nil, err
+	}
+
+	cursor, err := s.listCollection.Find(ctx, filter, options.Find().SetProjection(withoutPassword))
+	if err != nil {
+		return nil, spanError(span, fmt.Errorf("failed to get users by domain: %w", err))
+	}
+	defer cursor.Close(ctx)
+
+	users := []*models.User{}
+	for cursor.Next(ctx) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			return nil, spanError(span, fmt.Errorf("failed to decode user: %w", err))
+		}
+		users = append(users, &user)
+	}
+	// Next also stops when ctx is cancelled or times out, which only Err reports
+	if err := cursor.Err(); err != nil {
+		return nil, spanError(span, fmt.Errorf("failed to read users: %w", err))
+	}
+
+	return users, nil
+}
+
+// BackfillEmailDomains derives email_domain for users stored before the field
+// existed, including soft-deleted ones, and returns how many it updated. Users that
+// already have the field are left alone, so running it again is a cheap no-op.
+func (s *UserService) BackfillEmailDomains(ctx context.Context) (int64, error) {
+	ctx, span := startSpan(ctx, "BackfillEmailDomains", "updateMany")
+	defer span.End()
+
+	if s.collection == nil {
+		return 0, nil
+	}
+
+	// The server splits each email itself, so no document has to be read first. The
+	// last part is taken, as models.EmailDomain does.
+	domain := bson.M{"$toLower": bson.M{"$arrayElemAt": bson.A{bson.M{"$split": bson.A{"$email", "@"}}, -1}}}
+	result, err := s.collection.UpdateMany(
+		ctx,
+		bson.M{"email_domain": bson.M{"$exists": false}, "email": bson.M{"$type": "string"}},
+		bson.A{bson.M{"$set": bson.M{"email_domain": domain}}},
+	)
+	if err != nil {
+		return 0, spanError(span, fmt.Errorf("failed to backfill email domains: %w", err))
+	}
+
+	return result.ModifiedCount, nil
+}
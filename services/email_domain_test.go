@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	v1bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestGetUsersByDomain(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("matches the stored domain", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, v1bson.D{
+			{Key: "_id", Value: bson.NewObjectID()},
+			{Key: "user_id", Value: "alice"},
+			{Key: "email", Value: "alice@example.com"},
+			{Key: "email_domain", Value: "example.com"},
+		}))
+
+		users, err := NewUserService(mt.DB).GetUsersByDomain(context.Background(), " Example.COM ")
+		if err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		if len(users) != 1 || users[0].EmailDomain != "example.com" {
+			mt.Errorf("Expected alice, got %v", users)
+		}
+
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		if domain, ok := filter.Lookup("email_domain").StringValueOK(); !ok || domain != "example.com" {
+			mt.Errorf("Expected an equality match on the normalized domain, got %v", filter)
+		}
+		if _, err := filter.LookupErr("deleted_at"); err != nil {
+			mt.Errorf("Expected deleted users to be excluded, got %v", filter)
+		}
+	})
+
+	mt.Run("no users", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch))
+
+		users, err := NewUserService(mt.DB).GetUsersByDomain(context.Background(), "example.com")
+		if err != nil || users == nil || len(users) != 0 {
+			mt.Errorf("Expected an empty list, got %v (error: %v)", users, err)
+		}
+	})
+}
+
+func TestBackfillEmailDomains(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("sets the domain on users without one", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 3}, v1bson.E{Key: "nModified", Value: 3}))
+
+		updated, err := NewUserService(mt.DB).BackfillEmailDomains(context.Background())
+		if err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		if updated != 3 {
+			mt.Errorf("Expected 3 users to be updated, got %d", updated)
+		}
+
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		if exists, ok := update.Lookup("q", "email_domain", "$exists").BooleanOK(); !ok || exists {
+			mt.Errorf("Expected only users without email_domain to match, got %v", update)
+		}
+		if multi, _ := update.Lookup("multi").BooleanOK(); !multi {
+			mt.Errorf("Expected an update of every matching user, got %v", update)
+		}
+		if _, err := update.Lookup("u").Array().Index(0).Value().Document().LookupErr("$set", "email_domain"); err != nil {
+			mt.Errorf("Expected email_domain to be derived in a pipeline, got %v", update)
+		}
+	})
+}
+
+func TestBackfillEmailDomains_WithoutCollection(t *testing.T) {
+	service := NewUserServiceWithRepository(NewInMemoryUserRepository())
+
+	if updated, err := service.BackfillEmailDomains(context.Background()); err != nil || updated != 0 {
+		t.Errorf("Expected nothing to backfill, got %d (error: %v)", updated, err)
+	}
+}
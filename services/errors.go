@@ -0,0 +1,29 @@
+package services
+
+import "errors"
+
+var (
+	ErrUserNotFound  = errors.New("user not found")
+	ErrInvalidUserID = errors.New("invalid user ID")
+	ErrUserExists    = errors.New("user with this user_id already exists")
+	ErrEmailExists   = errors.New("user with this email already exists")
+	// ErrVersionMismatch means the user changed since the version the caller expected
+	ErrVersionMismatch = errors.New("user has been modified since it was read")
+
+	ErrInvalidResetToken        = errors.New("invalid or expired reset token")
+	ErrInvalidVerificationToken = errors.New("invalid verification token")
+	ErrInvalidRefreshToken      = errors.New("invalid or expired refresh token")
+	// ErrSessionNotFound means the user has no active session with the given ID
+	ErrSessionNotFound = errors.New("session not found")
+
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotStarted  = errors.New("two-factor enrollment has not been started")
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+
+	// ErrStorageUnsupported is returned by operations that need MongoDB when the
+	// service stores users in a repository alone, such as InMemoryUserRepository
+	ErrStorageUnsupported = errors.New("operation is not supported by the configured storage")
+
+	// ErrUnknownTenant is returned by TenantServices for a tenant outside its allowlist
+	ErrUnknownTenant = errors.New("unknown tenant")
+)
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go-mongodb-test/models"
+)
+
+// ErrEventsDisabled is returned by SubscribeUserEvents when no EventHub is configured
+var ErrEventsDisabled = errors.New("user events are disabled")
+
+// eventBuffer is how many events a subscriber can fall behind by before it misses some
+const eventBuffer = 64
+
+// EventHub fans user change events out to the subscribers in this process. It never
+// blocks a write: a subscriber whose buffer is full misses the event instead.
+// Subscribers connected to other instances do not see the change.
+type EventHub struct {
+	mu          sync.Mutex
+	subscribers map[chan *models.AuditEvent]struct{}
+}
+
+func NewEventHub() *EventHub {
+	return &EventHub{subscribers: map[chan *models.AuditEvent]struct{}{}}
+}
+
+// Subscribe returns a channel of the events published from now on and a function
+// that unsubscribes and closes it. The function may be called more than once.
+func (h *EventHub) Subscribe() (<-chan *models.AuditEvent, func()) {
+	events := make(chan *models.AuditEvent, eventBuffer)
+
+	h.mu.Lock()
+	h.subscribers[events] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return events, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers, events)
+			h.mu.Unlock()
+			close(events)
+		})
+	}
+}
+
+// Publish hands event to every subscriber with room for it. The lock is held while
+// sending, so no subscriber is closed halfway through.
+func (h *EventHub) Publish(event *models.AuditEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for events := range h.subscribers {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+// WithEventHub publishes every change recorded in the audit trail to hub, which
+// SubscribeUserEvents then serves
+func WithEventHub(hub *EventHub) Option {
+	return func(s *UserService) {
+		s.events = hub
+	}
+}
+
+// SubscribeUserEvents returns the user changes made through this service from now
+// on. The subscription ends, and the channel is closed, once ctx is done.
+func (s *UserService) SubscribeUserEvents(ctx context.Context) (<-chan *models.AuditEvent, error) {
+	if s.events == nil {
+		return nil, ErrEventsDisabled
+	}
+
+	events, unsubscribe := s.events.Subscribe()
+	context.AfterFunc(ctx, unsubscribe)
+	return events, nil
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-mongodb-test/models"
+)
+
+func TestSubscribeUserEvents(t *testing.T) {
+	service := NewUserServiceWithRepository(NewInMemoryUserRepository(), WithEventHub(NewEventHub()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := service.SubscribeUserEvents(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	alice, err := service.CreateUser(context.Background(), &models.CreateUserRequest{UserID: "alice", Email: "alice@example.com", Password: "s3cretpass"})
+	if err != nil {
+		t.Fatalf("Failed to create alice: %v", err)
+	}
+	if _, err := service.DeleteUser(context.Background(), alice.ID.Hex()); err != nil {
+		t.Fatalf("Failed to delete alice: %v", err)
+	}
+
+	for _, action := range []string{models.AuditActionCreate, models.AuditActionDelete} {
+		select {
+		case event := <-events:
+			if event.Action != action || event.TargetID != alice.ID {
+				t.Errorf("Expected a %s event for alice, got %+v", action, event)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected a %s event", action)
+		}
+	}
+
+	// Cancelling the context ends the subscription and closes the channel
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("Expected no more events after cancelling")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the channel to be closed after cancelling")
+	}
+}
+
+func TestSubscribeUserEvents_Disabled(t *testing.T) {
+	service := NewUserServiceWithRepository(NewInMemoryUserRepository())
+	if _, err := service.SubscribeUserEvents(context.Background()); !errors.Is(err, ErrEventsDisabled) {
+		t.Errorf("Expected ErrEventsDisabled, got %v", err)
+	}
+}
+
+func TestEventHub_SlowSubscriber(t *testing.T) {
+	hub := NewEventHub()
+	events, unsubscribe := hub.Subscribe()
+	defer unsubscribe()
+
+	// Publishing never blocks; events past the buffer are dropped for this subscriber
+	for range eventBuffer + 10 {
+		hub.Publish(&models.AuditEvent{Action: models.AuditActionUpdate})
+	}
+	if len(events) != eventBuffer {
+		t.Errorf("Expected %d buffered events, got %d", eventBuffer, len(events))
+	}
+
+	unsubscribe()
+	hub.Publish(&models.AuditEvent{Action: models.AuditActionUpdate})
+	if len(hub.subscribers) != 0 {
+		t.Errorf("Expected no subscribers left, got %d", len(hub.subscribers))
+	}
+}
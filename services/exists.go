@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// UserExists reports whether an active user has the given MongoDB ID
+func (s *UserService) UserExists(ctx context.Context, id string) (bool, error) {
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrInvalidUserID, err)
+	}
+	return s.exists(ctx, "UserExists", bson.M{"_id": objectID})
+}
+
+// UserIDExists reports whether an active user has the given user_id
+func (s *UserService) UserIDExists(ctx context.Context, userID string) (bool, error) {
+	return s.exists(ctx, "UserIDExists", bson.M{"user_id": userID})
+}
+
+// exists counts at most one active user matching filter, so nothing is decoded
+// and the server can stop at the first index hit
+func (s *UserService) exists(ctx context.Context, method string, filter bson.M) (bool, error) {
+	ctx, span := startSpan(ctx, method, "countDocuments")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	if err := s.requireCollections(); err != nil {
+		return false, err
+	}
+
+	count, err := s.collection.CountDocuments(ctx, notDeleted(filter), options.Count().SetLimit(1))
+	if err != nil {
+		return false, spanError(span, fmt.Errorf("failed to check user existence: %w", err))
+	}
+
+	return count > 0, nil
+}
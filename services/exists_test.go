@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	v1bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestUserIDExists(t *testing.T) {
+	ctx := context.Background()
+	counted := func(n int32) v1bson.D {
+		return mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, v1bson.D{{Key: "n", Value: n}})
+	}
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("taken", func(mt *mtest.T) {
+		mt.AddMockResponses(counted(1))
+
+		exists, err := NewUserService(mt.DB).UserIDExists(ctx, "alice")
+		if err != nil || !exists {
+			mt.Fatalf("Expected alice to exist, got %v, %v", exists, err)
+		}
+
+		started := mt.GetStartedEvent()
+		if started == nil || started.CommandName != "aggregate" {
+			mt.Fatalf("Expected an aggregate command, got %v", started)
+		}
+		stages, err := started.Command.Lookup("pipeline").Array().Values()
+		if err != nil || len(stages) < 2 {
+			mt.Fatalf("Expected a match and a limit stage, got %v", started.Command)
+		}
+		match := stages[0].Document().Lookup("$match").Document()
+		if match.Lookup("user_id").StringValue() != "alice" {
+			mt.Errorf("Expected a user_id filter, got %v", match)
+		}
+		if _, err := match.LookupErr("deleted_at"); err != nil {
+			mt.Errorf("Expected deleted users to be excluded, got %v", match)
+		}
+		if limit, ok := stages[1].Document().Lookup("$limit").AsInt64OK(); !ok || limit != 1 {
+			mt.Errorf("Expected a limit of 1, got %v", stages[1])
+		}
+	})
+
+	mt.Run("free", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch))
+
+		exists, err := NewUserService(mt.DB).UserIDExists(ctx, "alice")
+		if err != nil || exists {
+			mt.Errorf("Expected alice to be free, got %v, %v", exists, err)
+		}
+	})
+}
+
+func TestUserExists_InvalidID(t *testing.T) {
+	service := NewUserService(&MockDatabase{})
+
+	if _, err := service.UserExists(context.Background(), "invalid-id"); !errors.Is(err, ErrInvalidUserID) {
+		t.Errorf("Expected ErrInvalidUserID, got %v", err)
+	}
+}
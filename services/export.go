@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"go-mongodb-test/models"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ExportUsers calls fn for every user, ordered by _id, decoding one document at a
+// time so that memory use does not grow with the collection. Iteration stops at
+// the first error from fn, which is returned as is.
+func (s *UserService) ExportUsers(ctx context.Context, includeDeleted bool, fn func(*models.User) error) error {
+	ctx, span := startSpan(ctx, "ExportUsers", "find")
+	defer span.End()
+
+	filter := listUsersFilter(models.ListUsersOptions{IncludeDeleted: includeDeleted})
+	findOptions := options.Find().
+		SetSort(bson.M{"_id": 1}).
+		SetProjection(withoutPassword)
+
+	if err := s.requireCollections(); err != nil {
+		return err
+	}
+
+	cursor, err := s.listCollection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return spanError(span, fmt.Errorf("failed to export users: %w", err))
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			return spanError(span, fmt.Errorf("failed to decode user: %w", err))
+		}
+		if err := fn(&user); err != nil {
+			return err
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return spanError(span, fmt.Errorf("failed to export users: %w", err))
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go-mongodb-test/models"
+
+	v1bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestExportUsers(t *testing.T) {
+	ctx := context.Background()
+	userDoc := func(userID string) v1bson.D {
+		return v1bson.D{{Key: "_id", Value: bson.NewObjectID()}, {Key: "user_id", Value: userID}}
+	}
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("iterates every batch", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(1, "test.users", mtest.FirstBatch, userDoc("alice"), userDoc("bob")),
+			mtest.CreateCursorResponse(0, "test.users", mtest.NextBatch, userDoc("carol")),
+		)
+
+		var exported []string
+		err := NewUserService(mt.DB).ExportUsers(ctx, false, func(user *models.User) error {
+			exported = append(exported, user.UserID)
+			return nil
+		})
+		if err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+
+		if len(exported) != 3 || exported[2] != "carol" {
+			mt.Errorf("Expected alice, bob and carol, got %v", exported)
+		}
+	})
+
+	mt.Run("excludes passwords", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch))
+
+		if err := NewUserService(mt.DB).ExportUsers(ctx, false, func(*models.User) error { return nil }); err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+
+		assertPasswordProjection(mt, false)
+	})
+
+	mt.Run("stops at the first callback error", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, userDoc("alice"), userDoc("bob")))
+
+		stop := errors.New("client went away")
+		calls := 0
+		err := NewUserService(mt.DB).ExportUsers(ctx, false, func(*models.User) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) {
+			mt.Errorf("Expected the callback error, got %v", err)
+		}
+		if calls != 1 {
+			mt.Errorf("Expected iteration to stop after 1 call, got %d", calls)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-mongodb-test/models"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// idempotencyCollection stores the Idempotency-Key of each recent signup
+const idempotencyCollection = "idempotency_keys"
+
+// IdempotencyKeyTTL is how long a signup can be replayed by its Idempotency-Key.
+// A TTL index on created_at removes older keys.
+const IdempotencyKeyTTL = 24 * time.Hour
+
+// GetIdempotencyKey returns the record stored for key, or nil when the key is
+// unknown or older than IdempotencyKeyTTL. The TTL monitor only runs once a
+// minute, so expired records are filtered out here as well.
+func (s *UserService) GetIdempotencyKey(ctx context.Context, key string) (*models.IdempotencyKey, error) {
+	ctx, span := startSpanOn(ctx, "GetIdempotencyKey", idempotencyCollection, "findOne")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	if err := s.requireCollections(); err != nil {
+		return nil, err
+	}
+
+	var record models.IdempotencyKey
+	err := s.idempotencyKeys.FindOne(ctx, bson.M{
+		"_id":        key,
+		"created_at": bson.M{"$gt": s.now().Add(-IdempotencyKeyTTL)},
+	}).Decode(&record)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, spanError(span, fmt.Errorf("failed to get idempotency key: %w", err))
+	}
+	return &record, nil
+}
+
+// SaveIdempotencyKey records that the request identified by requestHash created the
+// user with the given ID under key. A key that is already stored keeps the record
+// of the request that got there first.
+func (s *UserService) SaveIdempotencyKey(ctx context.Context, key, requestHash string, userID bson.ObjectID) error {
+	ctx, span := startSpanOn(ctx, "SaveIdempotencyKey", idempotencyCollection, "insertOne")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	if err := s.requireCollections(); err != nil {
+		return err
+	}
+
+	_, err := s.idempotencyKeys.InsertOne(ctx, &models.IdempotencyKey{
+		Key:         key,
+		RequestHash: requestHash,
+		UserID:      userID,
+		CreatedAt:   s.now(),
+	})
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return spanError(span, fmt.Errorf("failed to store idempotency key: %w", err))
+	}
+	return nil
+}
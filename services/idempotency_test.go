@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	v1bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestGetIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	userID := bson.NewObjectID()
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("stored key", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.idempotency_keys", mtest.FirstBatch, v1bson.D{
+			{Key: "_id", Value: "key-1"},
+			{Key: "request_hash", Value: "abc"},
+			{Key: "user_id", Value: userID},
+			{Key: "created_at", Value: now.Add(-time.Hour)},
+		}))
+
+		record, err := NewUserService(mt.DB, WithClock(func() time.Time { return now })).GetIdempotencyKey(ctx, "key-1")
+		if err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		if record == nil || record.RequestHash != "abc" || record.UserID != userID {
+			mt.Errorf("Expected the stored record, got %+v", record)
+		}
+
+		started := mt.GetStartedEvent()
+		if started == nil || started.CommandName != "find" {
+			mt.Fatalf("Expected a find command, got %v", started)
+		}
+		if key := started.Command.Lookup("filter", "_id").StringValue(); key != "key-1" {
+			mt.Errorf("Expected the lookup by key, got '%s'", key)
+		}
+		if after := started.Command.Lookup("filter", "created_at", "$gt").Time(); !after.Equal(now.Add(-IdempotencyKeyTTL)) {
+			mt.Errorf("Expected keys older than the TTL to be skipped, got created_at > %v", after)
+		}
+	})
+
+	mt.Run("unknown key", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.idempotency_keys", mtest.FirstBatch))
+
+		record, err := NewUserService(mt.DB).GetIdempotencyKey(ctx, "key-1")
+		if err != nil || record != nil {
+			mt.Errorf("Expected no record and no error, got %+v (error: %v)", record, err)
+		}
+	})
+
+	t.Run("without collections", func(t *testing.T) {
+		service := NewUserServiceWithRepository(NewInMemoryUserRepository())
+		if _, err := service.GetIdempotencyKey(ctx, "key-1"); !errors.Is(err, ErrStorageUnsupported) {
+			t.Errorf("Expected ErrStorageUnsupported, got %v", err)
+		}
+	})
+}
+
+func TestSaveIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	userID := bson.NewObjectID()
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("stores the key", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		if err := NewUserService(mt.DB).SaveIdempotencyKey(ctx, "key-1", "abc", userID); err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+
+		started := mt.GetStartedEvent()
+		if started == nil || started.CommandName != "insert" || started.Command.Lookup("insert").StringValue() != idempotencyCollection {
+			mt.Fatalf("Expected an insert into %s, got %v", idempotencyCollection, started)
+		}
+		stored := started.Command.Lookup("documents").Array().Index(0).Value().Document()
+		if stored.Lookup("_id").StringValue() != "key-1" || stored.Lookup("request_hash").StringValue() != "abc" {
+			mt.Errorf("Expected the key and request hash to be stored, got %v", stored)
+		}
+	})
+
+	mt.Run("keeps the first record", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{
+			Index: 0, Code: 11000, Message: "E11000 duplicate key error",
+		}))
+
+		if err := NewUserService(mt.DB).SaveIdempotencyKey(ctx, "key-1", "abc", userID); err != nil {
+			mt.Errorf("Expected a duplicate key to be ignored, got %v", err)
+		}
+	})
+
+	mt.Run("write error", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 2, Message: "boom"}))
+
+		err := NewUserService(mt.DB).SaveIdempotencyKey(ctx, "key-1", "abc", userID)
+		var commandErr mongo.CommandError
+		if !errors.As(err, &commandErr) {
+			mt.Errorf("Expected the command error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"go-mongodb-test/models"
+)
+
+// ListIndexes describes the indexes on the users collection as the server reports
+// them, so operators can confirm the unique indexes from EnsureIndexes exist
+func (s *UserService) ListIndexes(ctx context.Context) ([]models.IndexInfo, error) {
+	ctx, span := startSpan(ctx, "ListIndexes", "listIndexes")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	if err := s.requireCollections(); err != nil {
+		return nil, err
+	}
+
+	cursor, err := s.collection.Indexes().List(ctx)
+	if err != nil {
+		return nil, spanError(span, fmt.Errorf("failed to list indexes: %w", err))
+	}
+	defer cursor.Close(ctx)
+
+	indexes := []models.IndexInfo{}
+	for cursor.Next(ctx) {
+		// The OK lookups tolerate options the server leaves out, such as unique on _id_
+		spec := cursor.Current
+		name, _ := spec.Lookup("name").StringValueOK()
+		unique, _ := spec.Lookup("unique").BooleanOK()
+		sparse, _ := spec.Lookup("sparse").BooleanOK()
+		index := models.IndexInfo{Name: name, Keys: []models.IndexKey{}, Unique: unique, Sparse: sparse}
+
+		// The key document stays ordered, since the field order defines the index
+		keys, ok := spec.Lookup("key").DocumentOK()
+		if !ok {
+			return nil, spanError(span, fmt.Errorf("index %q has no key document", name))
+		}
+		elements, err := keys.Elements()
+		if err != nil {
+			return nil, spanError(span, fmt.Errorf("failed to decode index %q: %w", name, err))
+		}
+		for _, element := range elements {
+			key := models.IndexKey{Field: element.Key()}
+			if order, ok := element.Value().AsInt64OK(); ok {
+				key.Order = order
+			} else if kind, ok := element.Value().StringValueOK(); ok {
+				key.Order = kind
+			} else {
+				key.Order = element.Value().String()
+			}
+			index.Keys = append(index.Keys, key)
+		}
+
+		indexes = append(indexes, index)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, spanError(span, fmt.Errorf("failed to read indexes: %w", err))
+	}
+
+	return indexes, nil
+}
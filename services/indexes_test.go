@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	v1bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestListIndexes(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("describes each index", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch,
+			v1bson.D{{Key: "v", Value: 2}, {Key: "key", Value: v1bson.D{{Key: "_id", Value: 1}}}, {Key: "name", Value: "_id_"}},
+			v1bson.D{{Key: "v", Value: 2}, {Key: "key", Value: v1bson.D{{Key: "user_id", Value: 1}}}, {Key: "name", Value: "user_id_1"}, {Key: "unique", Value: true}},
+			v1bson.D{{Key: "v", Value: 2}, {Key: "key", Value: v1bson.D{{Key: "email", Value: -1}, {Key: "bio", Value: "text"}}}, {Key: "name", Value: "email_-1_bio_text"}, {Key: "sparse", Value: true}},
+		))
+
+		indexes, err := NewUserService(mt.DB).ListIndexes(context.Background())
+		if err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+
+		if started := mt.GetStartedEvent(); started == nil || started.CommandName != "listIndexes" {
+			mt.Fatalf("Expected a listIndexes command, got %v", started)
+		}
+
+		if len(indexes) != 3 {
+			mt.Fatalf("Expected 3 indexes, got %+v", indexes)
+		}
+		if indexes[0].Name != "_id_" || indexes[0].Unique {
+			mt.Errorf("Unexpected _id index %+v", indexes[0])
+		}
+		if indexes[1].Name != "user_id_1" || !indexes[1].Unique || indexes[1].Keys[0].Field != "user_id" || indexes[1].Keys[0].Order != int64(1) {
+			mt.Errorf("Unexpected user_id index %+v", indexes[1])
+		}
+		compound := indexes[2]
+		if !compound.Sparse || len(compound.Keys) != 2 || compound.Keys[0].Order != int64(-1) || compound.Keys[1].Field != "bio" || compound.Keys[1].Order != "text" {
+			mt.Errorf("Expected the compound key in order, got %+v", compound)
+		}
+	})
+
+	mt.Run("reports a server error", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 13, Message: "not authorized"}))
+
+		if _, err := NewUserService(mt.DB).ListIndexes(context.Background()); err == nil {
+			mt.Error("Expected an error")
+		}
+	})
+}
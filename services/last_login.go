@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go-mongodb-test/models"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// RecordLogin stamps last_login_at on the user with the given ID after a
+// successful login
+func (s *UserService) RecordLogin(ctx context.Context, id string) error {
+	ctx, span := startSpan(ctx, "RecordLogin", "updateOne")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidUserID, err)
+	}
+
+	if err := s.requireCollections(); err != nil {
+		return err
+	}
+
+	// updated_at is left alone: logging in does not change the user, so it must not
+	// change the ETag either
+	_, err = s.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": objectID},
+		bson.M{"$set": bson.M{"last_login_at": s.now()}},
+	)
+	if err != nil {
+		return spanError(span, fmt.Errorf("failed to record login: %w", err))
+	}
+
+	s.cache.remove(objectID.Hex())
+	return nil
+}
+
+// inactiveUsersFilter matches users who have not logged in since cutoff, including
+// those who never have
+func inactiveUsersFilter(cutoff time.Time) bson.M {
+	return notDeleted(bson.M{
+		"$or": bson.A{
+			bson.M{"last_login_at": bson.M{"$lt": cutoff}},
+			// null also matches users stored before logins were recorded
+			bson.M{"last_login_at": nil},
+		},
+	})
+}
+
+// ListInactiveUsers returns up to limit users, after skipping offset, who have not
+// logged in since cutoff. They are ordered by _id, oldest account first, so that
+// pages stay stable while the cleanup works through them.
+func (s *UserService) ListInactiveUsers(ctx context.Context, cutoff time.Time, limit, offset int) ([]*models.User, error) {
+	ctx, span := startSpan(ctx, "ListInactiveUsers", "find")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	findOptions := options.Find().
+		SetSort(bson.M{"_id": 1}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit)).
+		SetProjection(withoutPassword)
+
+	if err := s.requireCollections(); err != nil {
+		return nil, err
+	}
+
+	cursor, err := s.listCollection.Find(ctx, inactiveUsersFilter(cutoff), findOptions)
+	if err != nil {
+		return nil, spanError(span, fmt.Errorf("failed to get inactive users: %w", err))
+	}
+	defer cursor.Close(ctx)
+
+	var users []*models.User
+	for cursor.Next(ctx) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			return nil, spanError(span, fmt.Errorf("failed to decode user: %w", err))
+		}
+		users = append(users, &user)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, spanError(span, fmt.Errorf("failed to read inactive users: %w", err))
+	}
+
+	return users, nil
+}
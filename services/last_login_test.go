@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestRecordLogin(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("stamps last_login_at only", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 1}))
+
+		if err := NewUserService(mt.DB).RecordLogin(context.Background(), bson.NewObjectID().Hex()); err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+
+		update := mt.GetStartedEvent()
+		set := update.Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u", "$set").Document()
+		if _, err := set.LookupErr("last_login_at"); err != nil {
+			mt.Errorf("Expected last_login_at to be set, got %v", set)
+		}
+		if _, err := set.LookupErr("updated_at"); err == nil {
+			mt.Errorf("Expected updated_at to be left alone, got %v", set)
+		}
+	})
+
+	mt.Run("invalid ID", func(mt *mtest.T) {
+		if err := NewUserService(mt.DB).RecordLogin(context.Background(), "bad"); err == nil {
+			mt.Error("Expected an error")
+		}
+	})
+}
+
+func TestInactiveUsersFilter(t *testing.T) {
+	cutoff := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	filter := inactiveUsersFilter(cutoff)
+
+	if _, ok := filter["deleted_at"]; !ok {
+		t.Errorf("Expected deleted users to be excluded, got %v", filter)
+	}
+	or, ok := filter["$or"].(bson.A)
+	if !ok || len(or) != 2 {
+		t.Fatalf("Expected a two-way $or, got %v", filter["$or"])
+	}
+	if before := or[0].(bson.M)["last_login_at"].(bson.M); before["$lt"] != cutoff {
+		t.Errorf("Expected last_login_at before %v, got %v", cutoff, before)
+	}
+	if never, ok := or[1].(bson.M)["last_login_at"]; !ok || never != nil {
+		t.Errorf("Expected users who never logged in to match, got %v", or[1])
+	}
+}
+
+func TestListInactiveUsers(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("pages by _id", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch,
+			v1bson.D{{Key: "_id", Value: bson.NewObjectID()}, {Key: "user_id", Value: "alice"}},
+		))
+
+		users, err := NewUserService(mt.DB).ListInactiveUsers(context.Background(), time.Now(), 51, 100)
+		if err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		if len(users) != 1 || users[0].UserID != "alice" {
+			mt.Errorf("Expected alice, got %+v", users)
+		}
+
+		find := mt.GetStartedEvent()
+		if limit := find.Command.Lookup("limit").AsInt64(); limit != 51 {
+			mt.Errorf("Expected limit 51, got %d", limit)
+		}
+		if skip := find.Command.Lookup("skip").AsInt64(); skip != 100 {
+			mt.Errorf("Expected skip 100, got %d", skip)
+		}
+		if _, err := find.Command.LookupErr("sort", "_id"); err != nil {
+			mt.Errorf("Expected a sort on _id, got %v", find.Command.Lookup("sort"))
+		}
+		if _, err := find.Command.LookupErr("projection", "password"); err != nil {
+			mt.Error("Expected the password to be projected out")
+		}
+	})
+}
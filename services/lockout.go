@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// WithLockout locks an account for duration once maxFailures consecutive logins
+// have failed. The counter and lock live on the user document, so every instance
+// sees them. A maxFailures of zero or less leaves lockout off.
+func WithLockout(maxFailures int, duration time.Duration) Option {
+	return func(s *UserService) {
+		s.lockoutThreshold = maxFailures
+		s.lockoutDuration = duration
+	}
+}
+
+// RecordFailedLogin counts a failed password check for the user with the given ID.
+// When the failure reaches the lockout threshold the account is locked and the
+// time it unlocks is returned; otherwise the result is nil.
+func (s *UserService) RecordFailedLogin(ctx context.Context, id string) (*time.Time, error) {
+	if s.lockoutThreshold <= 0 {
+		return nil, nil
+	}
+
+	ctx, span := startSpan(ctx, "RecordFailedLogin", "findAndModify")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidUserID, err)
+	}
+
+	if err := s.requireCollections(); err != nil {
+		return nil, err
+	}
+
+	var counted struct {
+		FailedLogins int `bson:"failed_logins"`
+	}
+	err = s.collection.FindOneAndUpdate(
+		ctx,
+		notDeleted(bson.M{"_id": objectID}),
+		bson.M{"$inc": bson.M{"failed_logins": 1}},
+		options.FindOneAndUpdate().
+			SetReturnDocument(options.After).
+			SetProjection(bson.M{"failed_logins": 1}),
+	).Decode(&counted)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrUserNotFound
+		}
+		return nil, spanError(span, fmt.Errorf("failed to record failed login: %w", err))
+	}
+
+	if counted.FailedLogins < s.lockoutThreshold {
+		return nil, nil
+	}
+
+	// The counter starts over so that the account gets a full set of attempts once
+	// the lock expires
+	lockedUntil := s.now().Add(s.lockoutDuration)
+	_, err = s.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": objectID},
+		bson.M{"$set": bson.M{"locked_until": lockedUntil, "failed_logins": 0}},
+	)
+	if err != nil {
+		return nil, spanError(span, fmt.Errorf("failed to lock user: %w", err))
+	}
+
+	return &lockedUntil, nil
+}
+
+// ResetFailedLogins clears the failure counter and any expired lock after a
+// successful login
+func (s *UserService) ResetFailedLogins(ctx context.Context, id string) error {
+	ctx, span := startSpan(ctx, "ResetFailedLogins", "updateOne")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidUserID, err)
+	}
+
+	if err := s.requireCollections(); err != nil {
+		return err
+	}
+
+	_, err = s.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": objectID},
+		bson.M{"$unset": bson.M{"failed_logins": "", "locked_until": ""}},
+	)
+	if err != nil {
+		return spanError(span, fmt.Errorf("failed to reset failed logins: %w", err))
+	}
+	return nil
+}
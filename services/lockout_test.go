@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	v1bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestRecordFailedLogin(t *testing.T) {
+	ctx := context.Background()
+	id := bson.NewObjectID()
+	counted := func(failedLogins int) v1bson.D {
+		return mtest.CreateSuccessResponse(v1bson.E{
+			Key:   "value",
+			Value: v1bson.D{{Key: "_id", Value: id}, {Key: "failed_logins", Value: failedLogins}},
+		})
+	}
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("counts below the threshold", func(mt *mtest.T) {
+		mt.AddMockResponses(counted(2))
+
+		lockedUntil, err := NewUserService(mt.DB, WithLockout(3, time.Minute)).RecordFailedLogin(ctx, id.Hex())
+		if err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		if lockedUntil != nil {
+			mt.Errorf("Expected no lock, got %v", lockedUntil)
+		}
+	})
+
+	mt.Run("locks at the threshold", func(mt *mtest.T) {
+		mt.AddMockResponses(counted(3), mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 1}))
+
+		lockedUntil, err := NewUserService(mt.DB, WithLockout(3, time.Minute)).RecordFailedLogin(ctx, id.Hex())
+		if err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		if lockedUntil == nil || time.Until(*lockedUntil) <= 0 || time.Until(*lockedUntil) > time.Minute {
+			mt.Fatalf("Expected a lock expiring within a minute, got %v", lockedUntil)
+		}
+
+		mt.GetStartedEvent() // findAndModify
+		update := mt.GetStartedEvent()
+		if update == nil || update.CommandName != "update" {
+			mt.Fatalf("Expected an update command, got %v", update)
+		}
+		set := update.Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u", "$set").Document()
+		if _, err := set.LookupErr("locked_until"); err != nil {
+			mt.Errorf("Expected locked_until to be set, got %v", set)
+		}
+	})
+
+	mt.Run("does nothing when disabled", func(mt *mtest.T) {
+		lockedUntil, err := NewUserService(mt.DB).RecordFailedLogin(ctx, id.Hex())
+		if err != nil || lockedUntil != nil {
+			mt.Errorf("Expected no lock and no error, got %v, %v", lockedUntil, err)
+		}
+		if event := mt.GetStartedEvent(); event != nil {
+			mt.Errorf("Expected no command, got %s", event.CommandName)
+		}
+	})
+
+	mt.Run("unknown user", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(v1bson.E{Key: "value", Value: nil}))
+
+		if _, err := NewUserService(mt.DB, WithLockout(3, time.Minute)).RecordFailedLogin(ctx, id.Hex()); !errors.Is(err, ErrUserNotFound) {
+			mt.Errorf("Expected ErrUserNotFound, got %v", err)
+		}
+	})
+}
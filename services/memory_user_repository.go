@@ -0,0 +1,266 @@
+package services
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go-mongodb-test/models"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// InMemoryUserRepository is a UserRepository that keeps users in a map, for demos
+// and tests that should not need MongoDB. Like the unique indexes, its user_id
+// and email constraints also cover soft-deleted users. Nothing survives a restart.
+type InMemoryUserRepository struct {
+	mu    sync.RWMutex
+	users map[bson.ObjectID]models.User
+}
+
+func NewInMemoryUserRepository() *InMemoryUserRepository {
+	return &InMemoryUserRepository{users: map[bson.ObjectID]models.User{}}
+}
+
+// Ping always succeeds, so the repository can stand in for the database in health checks
+func (r *InMemoryUserRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (r *InMemoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.checkUnique(bson.NilObjectID, user.UserID, user.Email); err != nil {
+		return err
+	}
+
+	user.ID = bson.NewObjectID()
+	r.users[user.ID] = cloneUser(user, true)
+	return nil
+}
+
+// checkUnique returns the error a unique index would raise if a user other than
+// self already had userID or email
+func (r *InMemoryUserRepository) checkUnique(self bson.ObjectID, userID, email string) error {
+	for id, stored := range r.users {
+		if id == self {
+			continue
+		}
+		if stored.UserID == userID {
+			return ErrUserExists
+		}
+		if stored.Email == email {
+			return ErrEmailExists
+		}
+	}
+	return nil
+}
+
+func (r *InMemoryUserRepository) FindByID(ctx context.Context, id bson.ObjectID) (*models.User, error) {
+	return r.findOne(func(user *models.User) bool { return user.ID == id }, false), nil
+}
+
+func (r *InMemoryUserRepository) FindByUserID(ctx context.Context, userID string, includePassword bool) (*models.User, error) {
+	return r.findOne(func(user *models.User) bool { return user.UserID == userID }, includePassword), nil
+}
+
+func (r *InMemoryUserRepository) FindByEmail(ctx context.Context, email string, includePassword bool) (*models.User, error) {
+	return r.findOne(func(user *models.User) bool { return user.Email == email }, includePassword), nil
+}
+
+// findOne returns a copy of the non-deleted user matching match, or nil when there is none
+func (r *InMemoryUserRepository) findOne(match func(*models.User) bool, includePassword bool) *models.User {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, stored := range r.users {
+		if stored.DeletedAt == nil && match(&stored) {
+			user := cloneUser(&stored, includePassword)
+			return &user
+		}
+	}
+	return nil
+}
+
+func (r *InMemoryUserRepository) Update(ctx context.Context, id bson.ObjectID, update UserUpdate) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.users[id]
+	if !ok || stored.DeletedAt != nil {
+		return false, nil
+	}
+	if update.ExpectedUpdatedAt != nil && !stored.UpdatedAt.Equal(*update.ExpectedUpdatedAt) {
+		return false, nil
+	}
+
+	for field, value := range update.Set {
+		text, ok := value.(string)
+		if !ok {
+			return false, fmt.Errorf("failed to update user: %s must be a string, got %T", field, value)
+		}
+		if err := setUserField(&stored, field, text); err != nil {
+			return false, err
+		}
+	}
+	for _, field := range update.Remove {
+		if err := setUserField(&stored, field, ""); err != nil {
+			return false, err
+		}
+	}
+
+	if err := r.checkUnique(id, stored.UserID, stored.Email); err != nil {
+		return false, err
+	}
+
+	advanceUpdatedAt(&stored, update.UpdatedAt)
+	r.users[id] = stored
+	return true, nil
+}
+
+// advanceUpdatedAt matches nextUpdatedAt: updated_at strictly increases with every update
+func advanceUpdatedAt(user *models.User, now time.Time) {
+	if now.After(user.UpdatedAt) {
+		user.UpdatedAt = now
+	} else {
+		user.UpdatedAt = user.UpdatedAt.Add(time.Millisecond)
+	}
+}
+
+// setUserField assigns one of the fields UserService updates by its stored name
+func setUserField(user *models.User, field, value string) error {
+	switch field {
+	case "user_id":
+		user.UserID = value
+	case "email":
+		user.Email = value
+	case "email_domain":
+		user.EmailDomain = value
+	case "password":
+		user.Password = value
+	case "first_name":
+		user.FirstName = value
+	case "last_name":
+		user.LastName = value
+	case "avatar_url":
+		user.AvatarURL = value
+	default:
+		return fmt.Errorf("failed to update user: unsupported field %q", field)
+	}
+	return nil
+}
+
+func (r *InMemoryUserRepository) Delete(ctx context.Context, id bson.ObjectID, at time.Time) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.users[id]
+	if !ok || stored.DeletedAt != nil {
+		return nil, nil
+	}
+
+	before := cloneUser(&stored, false)
+	stored.DeletedAt = &at
+	advanceUpdatedAt(&stored, at)
+	r.users[id] = stored
+	return &before, nil
+}
+
+func (r *InMemoryUserRepository) List(ctx context.Context, opts models.ListUsersOptions) ([]*models.User, error) {
+	r.mu.RLock()
+	var users []*models.User
+	for _, stored := range r.users {
+		if listOptionsMatch(&stored, opts) {
+			user := cloneUser(&stored, false)
+			users = append(users, &user)
+		}
+	}
+	r.mu.RUnlock()
+
+	field, descending := opts.SortField, opts.SortDescending
+	if field == "" {
+		field, descending = "created_at", true
+	}
+	slices.SortFunc(users, func(a, b *models.User) int {
+		var order int
+		switch field {
+		case "updated_at":
+			order = a.UpdatedAt.Compare(b.UpdatedAt)
+		case "user_id":
+			order = strings.Compare(a.UserID, b.UserID)
+		case "email":
+			order = strings.Compare(a.Email, b.Email)
+		default:
+			order = a.CreatedAt.Compare(b.CreatedAt)
+		}
+		// As in mongoUserRepository.List, _id breaks ties in the same direction
+		order = cmp.Or(order, bytes.Compare(a.ID[:], b.ID[:]))
+		if descending {
+			order = -order
+		}
+		return order
+	})
+
+	users = users[min(opts.Offset, len(users)):]
+	if opts.Limit > 0 && len(users) > opts.Limit {
+		users = users[:opts.Limit]
+	}
+	return users, nil
+}
+
+func (r *InMemoryUserRepository) Count(ctx context.Context, opts models.ListUsersOptions) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var count int64
+	for _, stored := range r.users {
+		if listOptionsMatch(&stored, opts) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// listOptionsMatch applies the same conditions as listUsersFilter
+func listOptionsMatch(user *models.User, opts models.ListUsersOptions) bool {
+	if !opts.IncludeDeleted && user.DeletedAt != nil {
+		return false
+	}
+	if opts.CreatedAfter != nil && user.CreatedAt.Before(*opts.CreatedAfter) {
+		return false
+	}
+	if opts.CreatedBefore != nil && user.CreatedAt.After(*opts.CreatedBefore) {
+		return false
+	}
+	if len(opts.UserIDs) > 0 && !slices.Contains(opts.UserIDs, user.UserID) {
+		return false
+	}
+	if opts.EmailDomain != "" && user.EmailDomain != strings.ToLower(strings.TrimSpace(opts.EmailDomain)) {
+		return false
+	}
+	switch opts.Status {
+	case models.StatusActive:
+		return user.Status != models.StatusDisabled
+	case models.StatusDisabled:
+		return user.Status == models.StatusDisabled
+	}
+	return true
+}
+
+// cloneUser copies user so that callers cannot change what is stored. Without
+// includePassword the copy drops the credentials, as the withoutPassword projection does.
+func cloneUser(user *models.User, includePassword bool) models.User {
+	clone := *user
+	clone.Roles = slices.Clone(user.Roles)
+	if !includePassword {
+		clone.Password = ""
+		clone.TwoFactorSecret = ""
+	}
+	return clone
+}
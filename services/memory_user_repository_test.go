@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"go-mongodb-test/models"
+)
+
+// createStoredUser stores a user with a password hash and TOTP secret in repo
+func createStoredUser(t *testing.T, repo *InMemoryUserRepository, userID, email string, createdAt time.Time) *models.User {
+	t.Helper()
+	user := &models.User{
+		UserID:          userID,
+		Email:           email,
+		EmailDomain:     models.EmailDomain(email),
+		Password:        "hash",
+		TwoFactorSecret: "secret",
+		Roles:           []string{models.RoleUser},
+		CreatedAt:       createdAt,
+		UpdatedAt:       createdAt,
+	}
+	if err := repo.Create(context.Background(), user); err != nil {
+		t.Fatalf("Failed to create %s: %v", userID, err)
+	}
+	return user
+}
+
+func TestInMemoryUserRepository_Uniqueness(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryUserRepository()
+	alice := createStoredUser(t, repo, "alice", "alice@example.com", time.Now())
+	bob := createStoredUser(t, repo, "bob", "bob@example.com", time.Now())
+
+	if err := repo.Create(ctx, &models.User{UserID: "alice", Email: "new@example.com"}); !errors.Is(err, ErrUserExists) {
+		t.Errorf("Expected ErrUserExists, got %v", err)
+	}
+	if err := repo.Create(ctx, &models.User{UserID: "new", Email: "alice@example.com"}); !errors.Is(err, ErrEmailExists) {
+		t.Errorf("Expected ErrEmailExists, got %v", err)
+	}
+
+	// As with the unique indexes, a soft-deleted user keeps its user_id and email
+	if _, err := repo.Delete(ctx, alice.ID, time.Now()); err != nil {
+		t.Fatalf("Failed to delete alice: %v", err)
+	}
+	if err := repo.Create(ctx, &models.User{UserID: "alice", Email: "new@example.com"}); !errors.Is(err, ErrUserExists) {
+		t.Errorf("Expected a deleted user's user_id to stay taken, got %v", err)
+	}
+
+	_, err := repo.Update(ctx, bob.ID, UserUpdate{Set: map[string]interface{}{"email": "alice@example.com"}, UpdatedAt: time.Now()})
+	if !errors.Is(err, ErrEmailExists) {
+		t.Errorf("Expected ErrEmailExists, got %v", err)
+	}
+	if stored, _ := repo.FindByID(ctx, bob.ID); stored.Email != "bob@example.com" {
+		t.Errorf("Expected a rejected update to change nothing, got '%s'", stored.Email)
+	}
+}
+
+func TestInMemoryUserRepository_Credentials(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryUserRepository()
+	alice := createStoredUser(t, repo, "alice", "alice@example.com", time.Now())
+
+	withoutCredentials := map[string]func() (*models.User, error){
+		"FindByID":     func() (*models.User, error) { return repo.FindByID(ctx, alice.ID) },
+		"FindByUserID": func() (*models.User, error) { return repo.FindByUserID(ctx, "alice", false) },
+		"FindByEmail":  func() (*models.User, error) { return repo.FindByEmail(ctx, "alice@example.com", false) },
+	}
+	for name, find := range withoutCredentials {
+		t.Run(name, func(t *testing.T) {
+			user, err := find()
+			if err != nil || user == nil {
+				t.Fatalf("Expected alice, got %v (error: %v)", user, err)
+			}
+			if user.Password != "" || user.TwoFactorSecret != "" {
+				t.Errorf("Expected credentials to be dropped, got %+v", user)
+			}
+		})
+	}
+
+	user, _ := repo.FindByEmail(ctx, "alice@example.com", true)
+	if user.Password != "hash" || user.TwoFactorSecret != "secret" {
+		t.Errorf("Expected credentials for login lookups, got %+v", user)
+	}
+
+	// Changing a returned user must not change what is stored
+	user.Roles[0] = models.RoleAdmin
+	if stored, _ := repo.FindByID(ctx, alice.ID); stored.Roles[0] != models.RoleUser {
+		t.Errorf("Expected stored roles to be unchanged, got %v", stored.Roles)
+	}
+}
+
+func TestInMemoryUserRepository_Update(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryUserRepository()
+	created := time.Date(2024, 6, 4, 12, 0, 0, 0, time.UTC)
+	alice := createStoredUser(t, repo, "alice", "alice@example.com", created)
+
+	matched, err := repo.Update(ctx, alice.ID, UserUpdate{
+		Set:               map[string]interface{}{"first_name": "Alice"},
+		UpdatedAt:         created,
+		ExpectedUpdatedAt: &created,
+	})
+	if err != nil || !matched {
+		t.Fatalf("Expected the update to apply, got %v (error: %v)", matched, err)
+	}
+	stored, _ := repo.FindByID(ctx, alice.ID)
+	if stored.FirstName != "Alice" || !stored.UpdatedAt.Equal(created.Add(time.Millisecond)) {
+		t.Errorf("Expected first_name and an updated_at past the stored one, got %+v", stored)
+	}
+
+	if matched, _ := repo.Update(ctx, alice.ID, UserUpdate{UpdatedAt: time.Now(), ExpectedUpdatedAt: &created}); matched {
+		t.Error("Expected a stale version not to match")
+	}
+
+	if _, err := repo.Update(ctx, alice.ID, UserUpdate{Remove: []string{"first_name"}, UpdatedAt: time.Now()}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if stored, _ := repo.FindByID(ctx, alice.ID); stored.FirstName != "" {
+		t.Errorf("Expected first_name to be removed, got '%s'", stored.FirstName)
+	}
+}
+
+func TestInMemoryUserRepository_List(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryUserRepository()
+	base := time.Date(2024, 6, 4, 12, 0, 0, 0, time.UTC)
+	createStoredUser(t, repo, "carol", "carol@example.com", base)
+	bob := createStoredUser(t, repo, "bob", "bob@example.com", base.Add(time.Hour))
+	createStoredUser(t, repo, "alice", "alice@example.com", base.Add(2*time.Hour))
+	if _, err := repo.Delete(ctx, bob.ID, base.Add(3*time.Hour)); err != nil {
+		t.Fatalf("Failed to delete bob: %v", err)
+	}
+
+	userIDs := func(users []*models.User) []string {
+		ids := make([]string, 0, len(users))
+		for _, user := range users {
+			ids = append(ids, user.UserID)
+		}
+		return ids
+	}
+
+	after := base.Add(30 * time.Minute)
+	tests := []struct {
+		name     string
+		opts     models.ListUsersOptions
+		expected []string
+	}{
+		{"Newest first by default", models.ListUsersOptions{}, []string{"alice", "carol"}},
+		{"Including deleted", models.ListUsersOptions{IncludeDeleted: true}, []string{"alice", "bob", "carol"}},
+		{"Sorted by user_id", models.ListUsersOptions{IncludeDeleted: true, SortField: "user_id"}, []string{"alice", "bob", "carol"}},
+		{"Sorted by email descending", models.ListUsersOptions{SortField: "email", SortDescending: true}, []string{"carol", "alice"}},
+		{"Created after", models.ListUsersOptions{CreatedAfter: &after}, []string{"alice"}},
+		{"By user_id", models.ListUsersOptions{UserIDs: []string{"carol", "bob"}}, []string{"carol"}},
+		{"Disabled only", models.ListUsersOptions{Status: models.StatusDisabled}, []string{}},
+		{"By email domain", models.ListUsersOptions{EmailDomain: "EXAMPLE.com", SortField: "user_id"}, []string{"alice", "carol"}},
+		{"Other email domain", models.ListUsersOptions{EmailDomain: "ample.com"}, []string{}},
+		{"One page", models.ListUsersOptions{IncludeDeleted: true, Offset: 1, Limit: 1}, []string{"bob"}},
+		{"Past the end", models.ListUsersOptions{Offset: 5, Limit: 1}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, err := repo.List(ctx, tt.opts)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			got := userIDs(users)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("Expected %v, got %v", tt.expected, got)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("Expected %v, got %v", tt.expected, got)
+					break
+				}
+			}
+			for _, user := range users {
+				if user.Password != "" {
+					t.Errorf("Expected no password for %s", user.UserID)
+				}
+			}
+		})
+	}
+}
+
+func TestInMemoryUserRepository_ListTies(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryUserRepository()
+	createdAt := time.Date(2024, 6, 4, 12, 0, 0, 0, time.UTC)
+	var expected []string
+	for _, userID := range []string{"alice", "bob", "carol", "dave"} {
+		createStoredUser(t, repo, userID, userID+"@example.com", createdAt)
+		// Newest first, so the later _id of each new user puts it ahead of the others
+		expected = append([]string{userID}, expected...)
+	}
+
+	// Paging through users created at the same instant sees each of them once
+	var got []string
+	for offset := 0; offset < len(expected); offset += 2 {
+		users, err := repo.List(ctx, models.ListUsersOptions{Offset: offset, Limit: 2})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		for _, user := range users {
+			got = append(got, user.UserID)
+		}
+	}
+	if !slices.Equal(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestInMemoryUserRepository_Count(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryUserRepository()
+	base := time.Date(2024, 6, 4, 12, 0, 0, 0, time.UTC)
+	createStoredUser(t, repo, "alice", "alice@example.com", base)
+	bob := createStoredUser(t, repo, "bob", "bob@example.com", base.Add(time.Hour))
+	if _, err := repo.Delete(ctx, bob.ID, base.Add(2*time.Hour)); err != nil {
+		t.Fatalf("Failed to delete bob: %v", err)
+	}
+
+	// Offset and Limit do not change the count
+	if count, err := repo.Count(ctx, models.ListUsersOptions{IncludeDeleted: true, Offset: 1, Limit: 1}); err != nil || count != 2 {
+		t.Errorf("Expected 2 users including deleted ones, got %d (error: %v)", count, err)
+	}
+	if count, err := repo.Count(ctx, models.ListUsersOptions{}); err != nil || count != 1 {
+		t.Errorf("Expected 1 user, got %d (error: %v)", count, err)
+	}
+}
+
+func TestNewUserServiceWithRepository_Unsupported(t *testing.T) {
+	ctx := context.Background()
+	service := NewUserServiceWithRepository(NewInMemoryUserRepository())
+
+	if err := service.EnsureIndexes(ctx); err != nil {
+		t.Errorf("Expected EnsureIndexes to have nothing to do, got %v", err)
+	}
+	if _, err := service.IssueRefreshToken(ctx, "507f1f77bcf86cd799439011", models.SessionClient{}); !errors.Is(err, ErrStorageUnsupported) {
+		t.Errorf("Expected ErrStorageUnsupported, got %v", err)
+	}
+	if _, err := service.UserIDExists(ctx, "alice"); !errors.Is(err, ErrStorageUnsupported) {
+		t.Errorf("Expected ErrStorageUnsupported, got %v", err)
+	}
+}
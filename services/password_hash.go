@@ -0,0 +1,44 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"go-mongodb-test/models"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// RehashPassword replaces the stored hash of user, whose password was just
+// verified, with one made by the configured algorithm. The update only applies
+// while the stored hash is still the one that was verified, so a password changed
+// in the meantime is not overwritten with the old one.
+func (s *UserService) RehashPassword(ctx context.Context, user *models.User, password string) error {
+	ctx, span := startSpan(ctx, "RehashPassword", "updateOne")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	if err := s.requireCollections(); err != nil {
+		return err
+	}
+
+	verified := user.Password
+	if err := user.HashPassword(password); err != nil {
+		return spanError(span, fmt.Errorf("failed to hash password: %w", err))
+	}
+
+	// updated_at is left alone like on other login bookkeeping: the password the
+	// user logs in with has not changed
+	_, err := s.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": user.ID, "password": verified},
+		bson.M{"$set": bson.M{"password": user.Password}},
+	)
+	if err != nil {
+		return spanError(span, fmt.Errorf("failed to rehash password: %w", err))
+	}
+
+	s.cache.remove(user.ID.Hex())
+	return nil
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go-mongodb-test/models"
+
+	v1bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestRehashPassword(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("replaces the verified hash only", func(mt *mtest.T) {
+		defer models.SetPasswordHashAlgorithm(models.PasswordHashBcrypt)
+
+		user := &models.User{ID: bson.NewObjectID()}
+		if err := user.HashPassword("s3cretpass"); err != nil {
+			mt.Fatalf("Failed to hash password: %v", err)
+		}
+		verified := user.Password
+		models.SetPasswordHashAlgorithm(models.PasswordHashArgon2id)
+
+		mt.AddMockResponses(mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 1}))
+		if err := NewUserService(mt.DB).RehashPassword(context.Background(), user, "s3cretpass"); err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		if !strings.HasPrefix(user.Password, "$argon2id$") || !user.CheckPassword("s3cretpass") {
+			mt.Errorf("Expected an argon2id hash of the password, got %s", user.Password)
+		}
+
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		if hash := update.Lookup("q", "password").StringValue(); hash != verified {
+			mt.Errorf("Expected the update to match the verified hash, got %s", hash)
+		}
+		set := update.Lookup("u", "$set").Document()
+		if hash := set.Lookup("password").StringValue(); hash != user.Password {
+			mt.Errorf("Expected the new hash to be stored, got %s", hash)
+		}
+		if _, err := set.LookupErr("updated_at"); err == nil {
+			mt.Errorf("Expected updated_at to be left alone, got %v", set)
+		}
+	})
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-mongodb-test/auth"
+	"go-mongodb-test/models"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// passwordResetCollection stores pending password reset tokens
+const passwordResetCollection = "password_reset_tokens"
+
+// PasswordResetTokenTTL is how long a password reset token stays valid, unless
+// WithTokenTTLs sets another lifetime
+const PasswordResetTokenTTL = time.Hour
+
+// CreatePasswordResetToken issues a single-use reset token for the user with
+// the given email, replacing any token issued earlier. It returns
+// ErrUserNotFound when no active user has that email.
+func (s *UserService) CreatePasswordResetToken(ctx context.Context, email string) (string, error) {
+	ctx, span := startSpanOn(ctx, "CreatePasswordResetToken", passwordResetCollection, "insertOne")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	user, err := s.GetUserByEmail(ctx, email)
+	if err != nil {
+		return "", spanError(span, err)
+	}
+	if user == nil {
+		return "", ErrUserNotFound
+	}
+
+	token, err := auth.GenerateRandomToken()
+	if err != nil {
+		return "", spanError(span, fmt.Errorf("failed to generate reset token: %w", err))
+	}
+
+	if err := s.requireCollections(); err != nil {
+		return "", err
+	}
+
+	// Only the most recent token is usable
+	if _, err := s.resetTokens.DeleteMany(ctx, bson.M{"user_id": user.ID}); err != nil {
+		return "", spanError(span, fmt.Errorf("failed to clear reset tokens: %w", err))
+	}
+
+	now := s.now()
+	_, err = s.resetTokens.InsertOne(ctx, &models.PasswordResetToken{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: now.Add(s.passwordResetTTL),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", spanError(span, fmt.Errorf("failed to store reset token: %w", err))
+	}
+
+	return token, nil
+}
+
+// ResetPassword consumes a reset token, sets its owner's password and revokes the
+// owner's refresh tokens. Unknown, used and expired tokens all return ErrInvalidResetToken.
+func (s *UserService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	ctx, span := startSpanOn(ctx, "ResetPassword", passwordResetCollection, "findOneAndDelete")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	if token == "" {
+		return ErrInvalidResetToken
+	}
+
+	user := &models.User{}
+	if err := user.HashPassword(newPassword); err != nil {
+		return spanError(span, fmt.Errorf("failed to hash password: %w", err))
+	}
+
+	if err := s.requireCollections(); err != nil {
+		return err
+	}
+
+	// Deleting on lookup keeps the token single-use under concurrent requests
+	var resetToken models.PasswordResetToken
+	err := s.resetTokens.FindOneAndDelete(ctx, bson.M{
+		"token_hash": auth.HashToken(token),
+		"expires_at": bson.M{"$gt": s.now()},
+	}).Decode(&resetToken)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrInvalidResetToken
+		}
+		return spanError(span, fmt.Errorf("failed to consume reset token: %w", err))
+	}
+
+	result, err := s.collection.UpdateOne(
+		ctx,
+		notDeleted(bson.M{"_id": resetToken.UserID}),
+		updatePipeline(s.now(), bson.M{"password": user.Password}),
+	)
+	if err != nil {
+		return spanError(span, fmt.Errorf("failed to reset password: %w", err))
+	}
+
+	s.cache.remove(resetToken.UserID.Hex())
+
+	if result.MatchedCount == 0 {
+		return ErrUserNotFound
+	}
+
+	// Whoever knew the old password may also hold a refresh token, so every
+	// session of the account has to log in again
+	if err := s.revokeRefreshTokens(ctx, resetToken.UserID); err != nil {
+		return spanError(span, err)
+	}
+
+	s.recordAudit(ctx, resetToken.UserID, models.AuditActionUpdate, []string{"password"})
+
+	return nil
+}
+
+// SetPassword replaces the password of the user with the given ID without asking for
+// the old one, for admins helping a locked-out user. Like ResetPassword it revokes
+// the user's refresh tokens, and it also clears any login lockout.
+func (s *UserService) SetPassword(ctx context.Context, id, newPassword string) error {
+	ctx, span := startSpan(ctx, "SetPassword", "updateOne")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidUserID, err)
+	}
+
+	user := &models.User{}
+	if err := user.HashPassword(newPassword); err != nil {
+		return spanError(span, fmt.Errorf("failed to hash password: %w", err))
+	}
+
+	if err := s.requireCollections(); err != nil {
+		return err
+	}
+
+	result, err := s.collection.UpdateOne(
+		ctx,
+		notDeleted(bson.M{"_id": objectID}),
+		updatePipeline(s.now(), bson.M{"password": user.Password}, "failed_logins", "locked_until"),
+	)
+	if err != nil {
+		return spanError(span, fmt.Errorf("failed to set password: %w", err))
+	}
+
+	s.cache.remove(objectID.Hex())
+
+	if result.MatchedCount == 0 {
+		return ErrUserNotFound
+	}
+
+	if err := s.revokeRefreshTokens(ctx, objectID); err != nil {
+		return spanError(span, err)
+	}
+
+	s.recordAudit(ctx, objectID, models.AuditActionUpdate, []string{"password"})
+
+	return nil
+}
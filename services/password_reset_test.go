@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go-mongodb-test/models"
+
+	v1bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// TestResetPassword_EmptyToken tests that an empty token is rejected before any DB call
+func TestResetPassword_EmptyToken(t *testing.T) {
+	service := NewUserService(&MockDatabase{})
+
+	err := service.ResetPassword(context.Background(), "", "newpassword123")
+	if !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("Expected ErrInvalidResetToken, got %v", err)
+	}
+}
+
+func TestSetPassword(t *testing.T) {
+	ctx := context.Background()
+	id := bson.NewObjectID()
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("sets the hash, clears the lockout and revokes refresh tokens", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 1}, v1bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 2}),
+		)
+
+		if err := NewUserService(mt.DB).SetPassword(ctx, id.Hex(), "n3wpassword"); err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+
+		set := pipelineSet(mt, mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u"))
+		user := &models.User{Password: set.Lookup("password", "$literal").StringValue()}
+		if !user.CheckPassword("n3wpassword") {
+			mt.Errorf("Expected a bcrypt hash of the new password, got %v", set)
+		}
+		if removed, _ := set.Lookup("locked_until").StringValueOK(); removed != "$$REMOVE" {
+			mt.Errorf("Expected the lockout to be cleared, got %v", set)
+		}
+
+		revoke := mt.GetStartedEvent()
+		if revoke == nil || revoke.Command.Lookup("delete").StringValue() != refreshTokenCollection {
+			mt.Errorf("Expected refresh tokens to be deleted, got %v", revoke)
+		}
+	})
+
+	mt.Run("not found", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 0}))
+
+		if err := NewUserService(mt.DB).SetPassword(ctx, id.Hex(), "n3wpassword"); !errors.Is(err, ErrUserNotFound) {
+			mt.Errorf("Expected ErrUserNotFound, got %v", err)
+		}
+		mt.GetStartedEvent() // update
+		if event := mt.GetStartedEvent(); event != nil {
+			mt.Errorf("Expected no further command, got %s", event.CommandName)
+		}
+	})
+
+	mt.Run("invalid ID", func(mt *mtest.T) {
+		if err := NewUserService(mt.DB).SetPassword(ctx, "bad", "n3wpassword"); !errors.Is(err, ErrInvalidUserID) {
+			mt.Errorf("Expected ErrInvalidUserID, got %v", err)
+		}
+	})
+}
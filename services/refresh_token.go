@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-mongodb-test/auth"
+	"go-mongodb-test/models"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// refreshTokenCollection stores outstanding refresh tokens
+const refreshTokenCollection = "refresh_tokens"
+
+// RefreshTokenTTL is how long a refresh token stays valid if it is never used,
+// unless WithTokenTTLs sets another lifetime
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+// maxSessionUserAgent caps the User-Agent stored with a session, so that an
+// oversized header cannot bloat every token of the session
+const maxSessionUserAgent = 256
+
+// IssueRefreshToken starts a session for the user with the given ID and returns
+// its first refresh token. Each login gets its own session, so signing in on one
+// device leaves the others alone.
+func (s *UserService) IssueRefreshToken(ctx context.Context, id string, client models.SessionClient) (*models.IssuedRefreshToken, error) {
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidUserID, err)
+	}
+
+	if len(client.UserAgent) > maxSessionUserAgent {
+		// Cutting mid-character would leave invalid UTF-8 behind
+		client.UserAgent = strings.ToValidUTF8(client.UserAgent[:maxSessionUserAgent], "")
+	}
+	return s.issueRefreshToken(ctx, &models.RefreshToken{
+		UserID:        objectID,
+		SessionID:     bson.NewObjectID(),
+		StartedAt:     s.now(),
+		SessionClient: client,
+	})
+}
+
+// issueRefreshToken stores a new token in the session of session, keeping the
+// user, start and client it already has
+func (s *UserService) issueRefreshToken(ctx context.Context, session *models.RefreshToken) (*models.IssuedRefreshToken, error) {
+	ctx, span := startSpanOn(ctx, "IssueRefreshToken", refreshTokenCollection, "insertOne")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	token, err := auth.GenerateRandomToken()
+	if err != nil {
+		return nil, spanError(span, fmt.Errorf("failed to generate refresh token: %w", err))
+	}
+
+	if err := s.requireCollections(); err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	sessionID := session.SessionKey()
+	_, err = s.refreshTokens.InsertOne(ctx, &models.RefreshToken{
+		TokenHash:     auth.HashToken(token),
+		UserID:        session.UserID,
+		ExpiresAt:     now.Add(s.refreshTokenTTL),
+		CreatedAt:     now,
+		SessionID:     sessionID,
+		StartedAt:     session.SessionStart(),
+		SessionClient: session.SessionClient,
+	})
+	if err != nil {
+		return nil, spanError(span, fmt.Errorf("failed to store refresh token: %w", err))
+	}
+
+	return &models.IssuedRefreshToken{Token: token, SessionID: sessionID.Hex()}, nil
+}
+
+// RotateRefreshToken consumes a refresh token and issues its replacement in the
+// same session, returning the token's owner alongside it. Unknown, already used and
+// expired tokens, as well as tokens whose account has been deleted or disabled, all
+// return ErrInvalidRefreshToken.
+func (s *UserService) RotateRefreshToken(ctx context.Context, token string) (*models.User, *models.IssuedRefreshToken, error) {
+	ctx, span := startSpanOn(ctx, "RotateRefreshToken", refreshTokenCollection, "findOneAndDelete")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	if token == "" {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	if err := s.requireCollections(); err != nil {
+		return nil, nil, err
+	}
+
+	// Deleting on lookup means a token can be exchanged only once, even when two
+	// requests race with the same one
+	var stored models.RefreshToken
+	err := s.refreshTokens.FindOneAndDelete(ctx, bson.M{
+		"token_hash": auth.HashToken(token),
+		"expires_at": bson.M{"$gt": s.now()},
+	}).Decode(&stored)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil, ErrInvalidRefreshToken
+		}
+		return nil, nil, spanError(span, fmt.Errorf("failed to consume refresh token: %w", err))
+	}
+
+	user, err := s.GetUserByID(ctx, stored.UserID.Hex())
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, nil, ErrInvalidRefreshToken
+		}
+		return nil, nil, spanError(span, err)
+	}
+	if user.IsDisabled() {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	next, err := s.issueRefreshToken(ctx, &stored)
+	if err != nil {
+		return nil, nil, spanError(span, err)
+	}
+
+	return user, next, nil
+}
+
+// RevokeRefreshToken invalidates a refresh token. Revoking an unknown or already
+// revoked token is not an error, so logging out twice succeeds.
+func (s *UserService) RevokeRefreshToken(ctx context.Context, token string) error {
+	ctx, span := startSpanOn(ctx, "RevokeRefreshToken", refreshTokenCollection, "deleteOne")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	if token == "" {
+		return nil
+	}
+
+	if err := s.requireCollections(); err != nil {
+		return err
+	}
+
+	if _, err := s.refreshTokens.DeleteOne(ctx, bson.M{"token_hash": auth.HashToken(token)}); err != nil {
+		return spanError(span, fmt.Errorf("failed to revoke refresh token: %w", err))
+	}
+
+	return nil
+}
+
+// revokeRefreshTokens deletes every refresh token of the user, so that each of their
+// sessions has to log in again. Without MongoDB no token can have been issued.
+func (s *UserService) revokeRefreshTokens(ctx context.Context, userID bson.ObjectID) error {
+	if s.refreshTokens == nil {
+		return nil
+	}
+
+	if _, err := s.refreshTokens.DeleteMany(ctx, bson.M{"user_id": userID}); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go-mongodb-test/auth"
+	"go-mongodb-test/models"
+
+	v1bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestRotateRefreshToken(t *testing.T) {
+	ctx := context.Background()
+	userID := bson.NewObjectID()
+	sessionID := bson.NewObjectID()
+	startedAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	consumed := mtest.CreateSuccessResponse(v1bson.E{
+		Key: "value",
+		Value: v1bson.D{
+			{Key: "_id", Value: bson.NewObjectID()},
+			{Key: "token_hash", Value: auth.HashToken("old-token")},
+			{Key: "user_id", Value: userID},
+			{Key: "expires_at", Value: time.Now().Add(time.Hour)},
+			{Key: "session_id", Value: sessionID},
+			{Key: "started_at", Value: startedAt},
+			{Key: "user_agent", Value: "curl/8.5.0"},
+		},
+	})
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("replaces the token", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			consumed,
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, v1bson.D{
+				{Key: "_id", Value: userID},
+				{Key: "user_id", Value: "alice"},
+			}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		user, next, err := NewUserService(mt.DB).RotateRefreshToken(ctx, "old-token")
+		if err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		if user == nil || user.UserID != "alice" {
+			mt.Errorf("Expected the token owner, got %+v", user)
+		}
+		if next == nil || next.Token == "" || next.Token == "old-token" {
+			mt.Fatalf("Expected a new token, got %+v", next)
+		}
+		if next.SessionID != sessionID.Hex() {
+			mt.Errorf("Expected session %s to carry on, got %s", sessionID.Hex(), next.SessionID)
+		}
+
+		consume := mt.GetStartedEvent()
+		if consume == nil || consume.CommandName != "findAndModify" {
+			mt.Fatalf("Expected a findAndModify command, got %v", consume)
+		}
+		if remove, ok := consume.Command.Lookup("remove").BooleanOK(); !ok || !remove {
+			mt.Error("Expected the old token to be deleted")
+		}
+		if hash := consume.Command.Lookup("query", "token_hash").StringValue(); hash != auth.HashToken("old-token") {
+			mt.Errorf("Expected the lookup to use the token hash, got '%s'", hash)
+		}
+
+		mt.GetStartedEvent() // find
+		insert := mt.GetStartedEvent()
+		if insert == nil || insert.CommandName != "insert" {
+			mt.Fatalf("Expected an insert command, got %v", insert)
+		}
+		stored := insert.Command.Lookup("documents").Array().Index(0).Value().Document()
+		if hash := stored.Lookup("token_hash").StringValue(); hash != auth.HashToken(next.Token) {
+			mt.Errorf("Expected the new token to be stored hashed, got '%s'", hash)
+		}
+		if !bytes.HasSuffix(stored.Lookup("session_id").Value, sessionID[:]) || !stored.Lookup("started_at").Time().Equal(startedAt) || stored.Lookup("user_agent").StringValue() != "curl/8.5.0" {
+			mt.Errorf("Expected the session to carry over to the new token, got %v", stored)
+		}
+	})
+
+	mt.Run("unknown or used token", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(v1bson.E{Key: "value", Value: nil}))
+
+		if _, _, err := NewUserService(mt.DB).RotateRefreshToken(ctx, "old-token"); !errors.Is(err, ErrInvalidRefreshToken) {
+			mt.Errorf("Expected ErrInvalidRefreshToken, got %v", err)
+		}
+	})
+
+	mt.Run("deleted user", func(mt *mtest.T) {
+		mt.AddMockResponses(consumed, mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch))
+
+		if _, _, err := NewUserService(mt.DB).RotateRefreshToken(ctx, "old-token"); !errors.Is(err, ErrInvalidRefreshToken) {
+			mt.Errorf("Expected ErrInvalidRefreshToken, got %v", err)
+		}
+		if event := mt.GetStartedEvent(); event != nil && event.CommandName == "insert" {
+			mt.Error("Expected no replacement token")
+		}
+	})
+
+	mt.Run("disabled user", func(mt *mtest.T) {
+		mt.AddMockResponses(consumed, mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, v1bson.D{
+			{Key: "_id", Value: userID},
+			{Key: "status", Value: models.StatusDisabled},
+		}))
+
+		if _, _, err := NewUserService(mt.DB).RotateRefreshToken(ctx, "old-token"); !errors.Is(err, ErrInvalidRefreshToken) {
+			mt.Errorf("Expected ErrInvalidRefreshToken, got %v", err)
+		}
+	})
+
+	mt.Run("empty token", func(mt *mtest.T) {
+		if _, _, err := NewUserService(mt.DB).RotateRefreshToken(ctx, ""); !errors.Is(err, ErrInvalidRefreshToken) {
+			mt.Errorf("Expected ErrInvalidRefreshToken, got %v", err)
+		}
+		if event := mt.GetStartedEvent(); event != nil {
+			mt.Errorf("Expected no command, got %s", event.CommandName)
+		}
+	})
+}
+
+func TestIssueRefreshToken_TTL(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	clock := WithClock(func() time.Time { return now })
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected time.Time
+	}{
+		{"default lifetime", []Option{clock}, now.Add(RefreshTokenTTL)},
+		{"configured lifetime", []Option{clock, WithTokenTTLs(0, 2*time.Hour)}, now.Add(2 * time.Hour)},
+	}
+
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+			if _, err := NewUserService(mt.DB, tt.opts...).IssueRefreshToken(ctx, bson.NewObjectID().Hex(), models.SessionClient{}); err != nil {
+				mt.Fatalf("Expected no error, got %v", err)
+			}
+
+			stored := mt.GetStartedEvent().Command.Lookup("documents").Array().Index(0).Value().Document()
+			if expiresAt := stored.Lookup("expires_at").Time(); !expiresAt.Equal(tt.expected) {
+				mt.Errorf("Expected the token to expire at %v, got %v", tt.expected, expiresAt)
+			}
+		})
+	}
+}
+
+func TestIssueRefreshToken_Session(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("starts a session", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		client := models.SessionClient{UserAgent: strings.Repeat("a", maxSessionUserAgent+10), IP: "203.0.113.7"}
+		issued, err := NewUserService(mt.DB, WithClock(func() time.Time { return now })).IssueRefreshToken(context.Background(), bson.NewObjectID().Hex(), client)
+		if err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+
+		stored := mt.GetStartedEvent().Command.Lookup("documents").Array().Index(0).Value().Document()
+		sessionID, err := bson.ObjectIDFromHex(issued.SessionID)
+		if err != nil || !bytes.HasSuffix(stored.Lookup("session_id").Value, sessionID[:]) {
+			mt.Errorf("Expected the returned session %s to be stored, got %v", issued.SessionID, stored)
+		}
+		if !stored.Lookup("started_at").Time().Equal(now) || stored.Lookup("ip").StringValue() != "203.0.113.7" {
+			mt.Errorf("Expected the session's start and IP to be stored, got %v", stored)
+		}
+		if agent := stored.Lookup("user_agent").StringValue(); len(agent) != maxSessionUserAgent {
+			mt.Errorf("Expected the User-Agent to be cut to %d bytes, got %d", maxSessionUserAgent, len(agent))
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"go-mongodb-test/models"
+)
+
+// SeedUser is one entry of a seed file: a signup plus, optionally, the roles to
+// grant instead of the defaults, so that a demo can start with an admin
+type SeedUser struct {
+	models.CreateUserRequest
+	Roles []string `json:"roles,omitempty"`
+}
+
+// SeedSummary counts what SeedUsers did with the entries of a seed file
+type SeedSummary struct {
+	Created int
+	// Skipped entries name a user_id or email that is already taken
+	Skipped int
+}
+
+// SeedUsers creates the users listed in the JSON array at path. Users whose
+// user_id or email already exists are left as they are, so seeding the same file
+// on every startup is harmless. An invalid entry stops seeding with its position.
+func (s *UserService) SeedUsers(ctx context.Context, path string) (SeedSummary, error) {
+	var summary SeedSummary
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return summary, fmt.Errorf("failed to read seed file: %w", err)
+	}
+
+	var seeds []SeedUser
+	if err := json.Unmarshal(data, &seeds); err != nil {
+		return summary, fmt.Errorf("failed to parse seed file: %w", err)
+	}
+
+	for i, seed := range seeds {
+		if seed.UserID == "" || seed.Email == "" || seed.Password == "" {
+			return summary, fmt.Errorf("seed user %d: user_id, email and password are required", i+1)
+		}
+		roles := seed.Roles
+		if roles == nil {
+			roles = models.DefaultRoles()
+		}
+		if err := models.ValidateRoles(roles); err != nil {
+			return summary, fmt.Errorf("seed user %d: %w", i+1, err)
+		}
+
+		_, err := s.createUser(ctx, &seed.CreateUserRequest, roles)
+		switch {
+		case err == nil:
+			summary.Created++
+		case errors.Is(err, ErrUserExists) || errors.Is(err, ErrEmailExists):
+			summary.Skipped++
+		default:
+			return summary, fmt.Errorf("seed user %d (%s): %w", i+1, seed.UserID, err)
+		}
+	}
+
+	slog.InfoContext(ctx, "Seeded users", "file", path, "created", summary.Created, "skipped", summary.Skipped)
+	return summary, nil
+}
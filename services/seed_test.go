@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-mongodb-test/models"
+)
+
+func writeSeedFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "seed.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write seed file: %v", err)
+	}
+	return path
+}
+
+func TestSeedUsers(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryUserRepository()
+	service := NewUserServiceWithRepository(repo)
+
+	path := writeSeedFile(t, `[
+		{"user_id": "admin", "email": "admin@example.com", "password": "s3cretpass", "roles": ["admin"]},
+		{"user_id": "alice", "email": "alice@example.com", "password": "s3cretpass", "first_name": "Alice"}
+	]`)
+
+	summary, err := service.SeedUsers(ctx, path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if summary != (SeedSummary{Created: 2}) {
+		t.Errorf("Expected both users to be created, got %+v", summary)
+	}
+
+	admin, _ := repo.FindByUserID(ctx, "admin", true)
+	if admin == nil || !admin.HasRole(models.RoleAdmin) || !admin.CheckPassword("s3cretpass") {
+		t.Errorf("Expected admin with the admin role and a hashed password, got %+v", admin)
+	}
+	alice, _ := repo.FindByUserID(ctx, "alice", false)
+	if alice == nil || alice.FirstName != "Alice" || len(alice.Roles) == 0 {
+		t.Errorf("Expected alice with the default roles, got %+v", alice)
+	}
+
+	// Seeding again finds every user already there
+	summary, err = service.SeedUsers(ctx, path)
+	if err != nil {
+		t.Fatalf("Expected no error on the second run, got %v", err)
+	}
+	if summary != (SeedSummary{Skipped: 2}) {
+		t.Errorf("Expected both users to be skipped, got %+v", summary)
+	}
+}
+
+func TestSeedUsers_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"Not JSON":         `users: [admin]`,
+		"Missing password": `[{"user_id": "admin", "email": "admin@example.com"}]`,
+		"Unknown role":     `[{"user_id": "admin", "email": "admin@example.com", "password": "s3cretpass", "roles": ["root"]}]`,
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			service := NewUserServiceWithRepository(NewInMemoryUserRepository())
+			if _, err := service.SeedUsers(context.Background(), writeSeedFile(t, content)); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+
+	t.Run("Missing file", func(t *testing.T) {
+		service := NewUserServiceWithRepository(NewInMemoryUserRepository())
+		if _, err := service.SeedUsers(context.Background(), filepath.Join(t.TempDir(), "missing.json")); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+}
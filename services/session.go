@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"go-mongodb-test/models"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// sessionFilter matches the token of session sessionID, which is its own ID for
+// tokens issued before sessions were tracked
+func sessionFilter(sessionID bson.ObjectID) bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{"session_id": sessionID},
+		bson.M{"_id": sessionID},
+	}}
+}
+
+// ListSessions returns the outstanding refresh tokens of the user with the given
+// ID, one per session, most recently used first
+func (s *UserService) ListSessions(ctx context.Context, id string) ([]*models.RefreshToken, error) {
+	ctx, span := startSpanOn(ctx, "ListSessions", refreshTokenCollection, "find")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidUserID, err)
+	}
+
+	if err := s.requireCollections(); err != nil {
+		return nil, err
+	}
+
+	// Rotation replaces a session's token, so the newest IDs are the sessions
+	// refreshed last; expired tokens wait for the TTL monitor and are left out
+	cursor, err := s.refreshTokens.Find(ctx, bson.M{
+		"user_id":    objectID,
+		"expires_at": bson.M{"$gt": s.now()},
+	}, options.Find().SetSort(bson.M{"_id": -1}))
+	if err != nil {
+		return nil, spanError(span, fmt.Errorf("failed to list sessions: %w", err))
+	}
+	defer cursor.Close(ctx)
+
+	tokens := []*models.RefreshToken{}
+	if err := cursor.All(ctx, &tokens); err != nil {
+		return nil, spanError(span, fmt.Errorf("failed to decode sessions: %w", err))
+	}
+
+	return tokens, nil
+}
+
+// RevokeSession ends a session of the user with the given ID by deleting its
+// refresh token. A session ID that is malformed, unknown or another user's
+// returns ErrSessionNotFound.
+func (s *UserService) RevokeSession(ctx context.Context, id, sessionID string) error {
+	ctx, span := startSpanOn(ctx, "RevokeSession", refreshTokenCollection, "deleteOne")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidUserID, err)
+	}
+	sessionObjectID, err := bson.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return ErrSessionNotFound
+	}
+
+	if err := s.requireCollections(); err != nil {
+		return err
+	}
+
+	filter := sessionFilter(sessionObjectID)
+	filter["user_id"] = objectID
+	result, err := s.refreshTokens.DeleteOne(ctx, filter)
+	if err != nil {
+		return spanError(span, fmt.Errorf("failed to revoke session: %w", err))
+	}
+	if result.DeletedCount == 0 {
+		return ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// RevokeOtherSessions ends every session of the user with the given ID except
+// keepSessionID, returning how many were revoked
+func (s *UserService) RevokeOtherSessions(ctx context.Context, id, keepSessionID string) (int64, error) {
+	ctx, span := startSpanOn(ctx, "RevokeOtherSessions", refreshTokenCollection, "deleteMany")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidUserID, err)
+	}
+	keep, err := bson.ObjectIDFromHex(keepSessionID)
+	if err != nil {
+		return 0, ErrSessionNotFound
+	}
+
+	if err := s.requireCollections(); err != nil {
+		return 0, err
+	}
+
+	result, err := s.refreshTokens.DeleteMany(ctx, bson.M{
+		"user_id": objectID,
+		"$nor":    bson.A{sessionFilter(keep)},
+	})
+	if err != nil {
+		return 0, spanError(span, fmt.Errorf("failed to revoke sessions: %w", err))
+	}
+
+	return result.DeletedCount, nil
+}
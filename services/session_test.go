@@ -0,0 +1,138 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	v1bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestListSessions(t *testing.T) {
+	ctx := context.Background()
+	userID := bson.NewObjectID()
+	sessionID := bson.NewObjectID()
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("lists the live tokens", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.refresh_tokens", mtest.FirstBatch,
+			v1bson.D{
+				{Key: "_id", Value: bson.NewObjectID()},
+				{Key: "user_id", Value: userID},
+				{Key: "session_id", Value: sessionID},
+				{Key: "user_agent", Value: "curl/8.5.0"},
+				{Key: "ip", Value: "203.0.113.7"},
+			},
+			// Issued before sessions were tracked
+			v1bson.D{
+				{Key: "_id", Value: bson.NewObjectID()},
+				{Key: "user_id", Value: userID},
+			},
+		))
+
+		tokens, err := NewUserService(mt.DB).ListSessions(ctx, userID.Hex())
+		if err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		if len(tokens) != 2 || tokens[0].SessionKey() != sessionID || tokens[0].UserAgent != "curl/8.5.0" || tokens[0].IP != "203.0.113.7" {
+			mt.Fatalf("Expected both sessions, got %+v", tokens)
+		}
+		if tokens[1].SessionKey() != tokens[1].ID {
+			mt.Errorf("Expected an untracked token to stand for its own session, got %s", tokens[1].SessionKey().Hex())
+		}
+
+		find := mt.GetStartedEvent()
+		if find == nil || find.Command.Lookup("find").StringValue() != refreshTokenCollection {
+			mt.Fatalf("Expected a find on %s, got %v", refreshTokenCollection, find)
+		}
+		if !bytes.HasSuffix(find.Command.Lookup("filter", "user_id").Value, userID[:]) {
+			mt.Errorf("Expected the user's tokens to be looked up, got %v", find.Command)
+		}
+		if _, err := find.Command.LookupErr("filter", "expires_at", "$gt"); err != nil {
+			mt.Errorf("Expected expired tokens to be left out, got %v", find.Command)
+		}
+	})
+
+	mt.Run("invalid user ID", func(mt *mtest.T) {
+		if _, err := NewUserService(mt.DB).ListSessions(ctx, "nope"); !errors.Is(err, ErrInvalidUserID) {
+			mt.Errorf("Expected ErrInvalidUserID, got %v", err)
+		}
+	})
+}
+
+func TestRevokeSession(t *testing.T) {
+	ctx := context.Background()
+	userID := bson.NewObjectID()
+	sessionID := bson.NewObjectID()
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("deletes the session's token", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 1}))
+
+		if err := NewUserService(mt.DB).RevokeSession(ctx, userID.Hex(), sessionID.Hex()); err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+
+		started := mt.GetStartedEvent()
+		if started == nil || started.CommandName != "delete" {
+			mt.Fatalf("Expected a delete command, got %v", started)
+		}
+		filter := started.Command.Lookup("deletes").Array().Index(0).Value().Document().Lookup("q").Document()
+		if !bytes.HasSuffix(filter.Lookup("user_id").Value, userID[:]) {
+			mt.Errorf("Expected only the user's own sessions to match, got %v", filter)
+		}
+		if _, err := filter.LookupErr("$or"); err != nil {
+			mt.Errorf("Expected the session to match by session_id or _id, got %v", filter)
+		}
+	})
+
+	mt.Run("unknown session", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 0}))
+
+		if err := NewUserService(mt.DB).RevokeSession(ctx, userID.Hex(), sessionID.Hex()); !errors.Is(err, ErrSessionNotFound) {
+			mt.Errorf("Expected ErrSessionNotFound, got %v", err)
+		}
+	})
+
+	mt.Run("malformed session ID", func(mt *mtest.T) {
+		if err := NewUserService(mt.DB).RevokeSession(ctx, userID.Hex(), "nope"); !errors.Is(err, ErrSessionNotFound) {
+			mt.Errorf("Expected ErrSessionNotFound, got %v", err)
+		}
+		if event := mt.GetStartedEvent(); event != nil {
+			mt.Errorf("Expected no command, got %s", event.CommandName)
+		}
+	})
+}
+
+func TestRevokeOtherSessions(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("keeps the current session", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 2}))
+
+		revoked, err := NewUserService(mt.DB).RevokeOtherSessions(context.Background(), bson.NewObjectID().Hex(), bson.NewObjectID().Hex())
+		if err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		if revoked != 2 {
+			mt.Errorf("Expected 2 sessions revoked, got %d", revoked)
+		}
+
+		started := mt.GetStartedEvent()
+		if started == nil || started.CommandName != "delete" {
+			mt.Fatalf("Expected a delete command, got %v", started)
+		}
+		deletion := started.Command.Lookup("deletes").Array().Index(0).Value().Document()
+		if _, err := deletion.LookupErr("q", "$nor"); err != nil {
+			mt.Errorf("Expected the current session to be excluded, got %v", deletion)
+		}
+		if limit := deletion.Lookup("limit").AsInt64(); limit != 0 {
+			mt.Errorf("Expected every other session to be deleted, got a limit of %d", limit)
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go-mongodb-test/models"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// StatsCacheTTL is how long Stats reuses the numbers it last computed
+const StatsCacheTTL = 30 * time.Second
+
+// MaxStatsDomains caps the email domains Stats breaks the users down into
+const MaxStatsDomains = 20
+
+// statsCache holds the last Stats result; the zero value is empty
+type statsCache struct {
+	mu        sync.Mutex
+	stats     *models.UserStats
+	expiresAt time.Time
+}
+
+// Stats counts the users that are not deleted: all of them, those created today
+// and this week, and those in the most common email domains. One aggregation
+// computes everything on the server, and the result is cached for StatsCacheTTL,
+// so the numbers can lag behind recent writes.
+func (s *UserService) Stats(ctx context.Context) (*models.UserStats, error) {
+	ctx, span := startSpan(ctx, "Stats", "aggregate")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	now := s.now()
+
+	// The lock is held while computing, so concurrent requests on an expired cache
+	// wait for one aggregation instead of each running their own
+	s.statsCache.mu.Lock()
+	defer s.statsCache.mu.Unlock()
+	if s.statsCache.stats != nil && now.Before(s.statsCache.expiresAt) {
+		return cloneStats(s.statsCache.stats), nil
+	}
+
+	if err := s.requireCollections(); err != nil {
+		return nil, err
+	}
+
+	today := now.UTC().Truncate(24 * time.Hour)
+	// Weekday counts from Sunday; the week starts on Monday
+	weekStart := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+
+	// Users stored before email_domain existed fall back to splitting their email
+	domain := bson.M{"$ifNull": bson.A{
+		"$email_domain",
+		bson.M{"$toLower": bson.M{"$arrayElemAt": bson.A{bson.M{"$split": bson.A{"$email", "@"}}, -1}}},
+	}}
+	count := bson.M{"$count": "n"}
+	pipeline := bson.A{
+		bson.M{"$match": notDeleted(bson.M{})},
+		bson.M{"$facet": bson.M{
+			"total": bson.A{count},
+			"today": bson.A{bson.M{"$match": bson.M{"created_at": bson.M{"$gte": today}}}, count},
+			"week":  bson.A{bson.M{"$match": bson.M{"created_at": bson.M{"$gte": weekStart}}}, count},
+			"domains": bson.A{
+				bson.M{"$group": bson.M{"_id": domain, "count": bson.M{"$sum": 1}}},
+				bson.M{"$sort": bson.M{"count": -1}},
+				bson.M{"$limit": MaxStatsDomains},
+			},
+		}},
+	}
+
+	cursor, err := s.listCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, spanError(span, fmt.Errorf("failed to compute user stats: %w", err))
+	}
+	defer cursor.Close(ctx)
+
+	type counted struct {
+		N int64 `bson:"n"`
+	}
+	var results []struct {
+		Total   []counted `bson:"total"`
+		Today   []counted `bson:"today"`
+		Week    []counted `bson:"week"`
+		Domains []struct {
+			Domain string `bson:"_id"`
+			Count  int64  `bson:"count"`
+		} `bson:"domains"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, spanError(span, fmt.Errorf("failed to read user stats: %w", err))
+	}
+
+	// $count leaves its facet empty rather than reporting zero
+	first := func(c []counted) int64 {
+		if len(c) == 0 {
+			return 0
+		}
+		return c[0].N
+	}
+	stats := &models.UserStats{Domains: []models.DomainCount{}, GeneratedAt: now}
+	if len(results) > 0 {
+		result := results[0]
+		stats.Total = first(result.Total)
+		stats.CreatedToday = first(result.Today)
+		stats.CreatedThisWeek = first(result.Week)
+		for _, d := range result.Domains {
+			stats.Domains = append(stats.Domains, models.DomainCount{Domain: d.Domain, Count: d.Count})
+		}
+	}
+	// The server leaves ties in any order; naming them alphabetically keeps the
+	// response stable between refreshes
+	slices.SortStableFunc(stats.Domains, func(a, b models.DomainCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), strings.Compare(a.Domain, b.Domain))
+	})
+
+	s.statsCache.stats = stats
+	s.statsCache.expiresAt = now.Add(StatsCacheTTL)
+	return cloneStats(stats), nil
+}
+
+// cloneStats copies stats so that callers cannot change the cached result
+func cloneStats(stats *models.UserStats) *models.UserStats {
+	clone := *stats
+	clone.Domains = slices.Clone(stats.Domains)
+	return &clone
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-mongodb-test/models"
+
+	v1bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestStats(t *testing.T) {
+	ctx := context.Background()
+	// A Wednesday, so the week started two days earlier
+	now := time.Date(2024, 6, 5, 15, 30, 0, 0, time.UTC)
+	count := func(n int64) v1bson.A {
+		return v1bson.A{v1bson.D{{Key: "n", Value: n}}}
+	}
+	domain := func(name string, n int64) v1bson.D {
+		return v1bson.D{{Key: "_id", Value: name}, {Key: "count", Value: n}}
+	}
+	facets := mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, v1bson.D{
+		{Key: "total", Value: count(42)},
+		{Key: "today", Value: count(3)},
+		{Key: "week", Value: count(12)},
+		{Key: "domains", Value: v1bson.A{domain("example.org", 6), domain("example.com", 30), domain("corp.example", 6)}},
+	})
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("aggregates and caches", func(mt *mtest.T) {
+		mt.AddMockResponses(facets)
+		clock := now
+		service := NewUserService(mt.DB, WithClock(func() time.Time { return clock }))
+
+		stats, err := service.Stats(ctx)
+		if err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		if stats.Total != 42 || stats.CreatedToday != 3 || stats.CreatedThisWeek != 12 || !stats.GeneratedAt.Equal(now) {
+			mt.Errorf("Unexpected counts %+v", stats)
+		}
+		expected := []models.DomainCount{{Domain: "example.com", Count: 30}, {Domain: "corp.example", Count: 6}, {Domain: "example.org", Count: 6}}
+		if len(stats.Domains) != len(expected) {
+			mt.Fatalf("Expected %v, got %v", expected, stats.Domains)
+		}
+		for i := range expected {
+			if stats.Domains[i] != expected[i] {
+				mt.Errorf("Expected %v, got %v", expected, stats.Domains)
+				break
+			}
+		}
+
+		started := mt.GetStartedEvent()
+		if started == nil || started.CommandName != "aggregate" {
+			mt.Fatalf("Expected an aggregate command, got %v", started)
+		}
+		facet := started.Command.Lookup("pipeline").Array().Index(1).Value().Document().Lookup("$facet").Document()
+		today := facet.Lookup("today").Array().Index(0).Value().Document().Lookup("$match", "created_at", "$gte").Time()
+		week := facet.Lookup("week").Array().Index(0).Value().Document().Lookup("$match", "created_at", "$gte").Time()
+		if !today.Equal(time.Date(2024, 6, 5, 0, 0, 0, 0, time.UTC)) || !week.Equal(time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)) {
+			mt.Errorf("Expected today from midnight and the week from Monday, got %v and %v", today, week)
+		}
+
+		// Changing the returned value must not reach the cache
+		stats.Domains[0].Count = 0
+		cached, err := service.Stats(ctx)
+		if err != nil || cached.Domains[0].Count != 30 {
+			mt.Errorf("Expected the cached stats, got %+v (error: %v)", cached, err)
+		}
+		if event := mt.GetStartedEvent(); event != nil {
+			mt.Errorf("Expected no command while cached, got %s", event.CommandName)
+		}
+
+		clock = now.Add(StatsCacheTTL)
+		mt.AddMockResponses(facets)
+		if _, err := service.Stats(ctx); err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		if event := mt.GetStartedEvent(); event == nil || event.CommandName != "aggregate" {
+			mt.Errorf("Expected the stats to be recomputed once expired, got %v", event)
+		}
+	})
+
+	mt.Run("no users", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, v1bson.D{
+			{Key: "total", Value: v1bson.A{}},
+			{Key: "today", Value: v1bson.A{}},
+			{Key: "week", Value: v1bson.A{}},
+			{Key: "domains", Value: v1bson.A{}},
+		}))
+
+		stats, err := NewUserService(mt.DB).Stats(ctx)
+		if err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		if stats.Total != 0 || stats.Domains == nil || len(stats.Domains) != 0 {
+			mt.Errorf("Expected zero counts and no domains, got %+v", stats)
+		}
+	})
+
+	mt.Run("errors are not cached", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 1, Message: "boom"}), facets)
+		service := NewUserService(mt.DB)
+
+		if _, err := service.Stats(ctx); err == nil {
+			mt.Fatal("Expected an error")
+		}
+		if stats, err := service.Stats(ctx); err != nil || stats.Total != 42 {
+			mt.Errorf("Expected a retry to compute the stats, got %+v (error: %v)", stats, err)
+		}
+	})
+}
+
+func TestStats_WithoutCollection(t *testing.T) {
+	service := NewUserServiceWithRepository(NewInMemoryUserRepository())
+
+	if _, err := service.Stats(context.Background()); !errors.Is(err, ErrStorageUnsupported) {
+		t.Errorf("Expected ErrStorageUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"go-mongodb-test/models"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// SetStatus activates or deactivates a user. Deactivating also revokes the user's
+// refresh tokens, so existing sessions end once their access tokens expire.
+func (s *UserService) SetStatus(ctx context.Context, id, status string) (*models.User, error) {
+	ctx, span := startSpan(ctx, "SetStatus", "updateOne")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidUserID, err)
+	}
+
+	if err := models.ValidateStatus(status); err != nil {
+		return nil, err
+	}
+
+	if err := s.requireCollections(); err != nil {
+		return nil, err
+	}
+
+	result, err := s.collection.UpdateOne(
+		ctx,
+		notDeleted(bson.M{"_id": objectID}),
+		updatePipeline(s.now(), bson.M{"status": status}),
+	)
+	if err != nil {
+		return nil, spanError(span, fmt.Errorf("failed to set status: %w", err))
+	}
+
+	s.cache.remove(objectID.Hex())
+
+	if result.MatchedCount == 0 {
+		return nil, ErrUserNotFound
+	}
+
+	if status == models.StatusDisabled {
+		if _, err := s.refreshTokens.DeleteMany(ctx, bson.M{"user_id": objectID}); err != nil {
+			return nil, spanError(span, fmt.Errorf("failed to revoke refresh tokens: %w", err))
+		}
+	}
+
+	s.recordAudit(ctx, objectID, models.AuditActionUpdate, []string{"status"})
+
+	return s.GetUserByID(ctx, id)
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go-mongodb-test/models"
+
+	v1bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestSetStatus(t *testing.T) {
+	ctx := context.Background()
+	id := bson.NewObjectID()
+	updated := mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 1}, v1bson.E{Key: "nModified", Value: 1})
+	found := func(status string) v1bson.D {
+		return mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, v1bson.D{
+			{Key: "_id", Value: id},
+			{Key: "status", Value: status},
+		})
+	}
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("disables and revokes refresh tokens", func(mt *mtest.T) {
+		mt.AddMockResponses(updated, mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 2}), auditRecorded, found(models.StatusDisabled))
+
+		user, err := NewUserService(mt.DB).SetStatus(ctx, id.Hex(), models.StatusDisabled)
+		if err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		if !user.IsDisabled() {
+			mt.Errorf("Expected a disabled user, got %+v", user)
+		}
+
+		update := mt.GetStartedEvent()
+		set := pipelineSet(mt, update.Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u"))
+		if status := set.Lookup("status", "$literal").StringValue(); status != models.StatusDisabled {
+			mt.Errorf("Expected status disabled to be set, got %v", set)
+		}
+
+		revoke := mt.GetStartedEvent()
+		if revoke == nil || revoke.CommandName != "delete" {
+			mt.Fatalf("Expected a delete command, got %v", revoke)
+		}
+		if collection := revoke.Command.Lookup("delete").StringValue(); collection != refreshTokenCollection {
+			mt.Errorf("Expected refresh tokens to be deleted, got %s", collection)
+		}
+	})
+
+	mt.Run("activates without touching refresh tokens", func(mt *mtest.T) {
+		mt.AddMockResponses(updated, auditRecorded, found(models.StatusActive))
+
+		if _, err := NewUserService(mt.DB).SetStatus(ctx, id.Hex(), models.StatusActive); err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+
+		mt.GetStartedEvent() // update
+		if event := mt.GetStartedEvent(); event == nil || event.Command.Lookup("insert").StringValue() != auditCollection {
+			mt.Errorf("Expected the audit event with no token revocation, got %v", event)
+		}
+		if event := mt.GetStartedEvent(); event == nil || event.CommandName != "find" {
+			mt.Errorf("Expected the user to be read back, got %v", event)
+		}
+	})
+
+	mt.Run("not found", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 0}))
+
+		if _, err := NewUserService(mt.DB).SetStatus(ctx, id.Hex(), models.StatusDisabled); !errors.Is(err, ErrUserNotFound) {
+			mt.Errorf("Expected ErrUserNotFound, got %v", err)
+		}
+	})
+
+	mt.Run("invalid status", func(mt *mtest.T) {
+		if _, err := NewUserService(mt.DB).SetStatus(ctx, id.Hex(), "suspended"); !errors.Is(err, models.ErrInvalidStatus) {
+			mt.Errorf("Expected ErrInvalidStatus, got %v", err)
+		}
+		if event := mt.GetStartedEvent(); event != nil {
+			mt.Errorf("Expected no command, got %s", event.CommandName)
+		}
+	})
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"maps"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// TenantServices holds one UserService per tenant, each working on the collections
+// of the tenant's own database, so that the users of one tenant are never read or
+// written through another's. The tenants are fixed at construction and double as
+// the allowlist of tenants a request may name.
+type TenantServices struct {
+	services map[string]*UserService
+}
+
+// NewTenantServices builds a service for each tenant in databases, which maps the
+// tenant IDs to their database names on client. Every service is built with opts,
+// so options that share state between services, such as WithEventHub, would share
+// it across tenants too.
+func NewTenantServices(client *mongo.Client, databases map[string]string, opts ...Option) *TenantServices {
+	tenants := &TenantServices{services: make(map[string]*UserService, len(databases))}
+	for tenant, database := range databases {
+		tenants.services[tenant] = NewUserService(client.Database(database), opts...)
+	}
+	return tenants
+}
+
+// For returns the service of tenant, or ErrUnknownTenant when it is not configured
+func (t *TenantServices) For(tenant string) (*UserService, error) {
+	service, ok := t.services[tenant]
+	if !ok {
+		return nil, ErrUnknownTenant
+	}
+	return service, nil
+}
+
+// Tenants returns the configured tenant IDs in sorted order
+func (t *TenantServices) Tenants() []string {
+	return slices.Sorted(maps.Keys(t.services))
+}
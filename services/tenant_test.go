@@ -0,0 +1,52 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestTenantServices(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("resolves each tenant to its database", func(mt *mtest.T) {
+		tenants := NewTenantServices(mt.Client, map[string]string{"globex": "globex_users", "acme": "acme"}, WithCache(10, time.Minute))
+
+		if expected := []string{"acme", "globex"}; !reflect.DeepEqual(tenants.Tenants(), expected) {
+			mt.Errorf("Expected tenants %v, got %v", expected, tenants.Tenants())
+		}
+
+		acme, err := tenants.For("acme")
+		if err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		globex, err := tenants.For("globex")
+		if err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		if name := acme.collection.Database().Name(); name != "acme" {
+			mt.Errorf("Expected acme in database acme, got %s", name)
+		}
+		if name := globex.auditEvents.Database().Name(); name != "globex_users" {
+			mt.Errorf("Expected the globex audit trail in database globex_users, got %s", name)
+		}
+		if acme.cache == globex.cache {
+			mt.Error("Expected each tenant to have a cache of its own")
+		}
+		if acme.client != mt.Client {
+			mt.Error("Expected the tenants to share the client for transactions")
+		}
+	})
+
+	mt.Run("rejects other tenants", func(mt *mtest.T) {
+		tenants := NewTenantServices(mt.Client, map[string]string{"acme": "acme"})
+		for _, tenant := range []string{"", "globex", "ACME"} {
+			if _, err := tenants.For(tenant); !errors.Is(err, ErrUnknownTenant) {
+				mt.Errorf("Expected ErrUnknownTenant for %q, got %v", tenant, err)
+			}
+		}
+	})
+}
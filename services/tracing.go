@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName identifies spans created by the service layer
+const TracerName = "user-service"
+
+// usersCollection is the MongoDB collection backing UserService
+const usersCollection = "users"
+
+// startSpan starts a span for a UserService method that performs the given
+// MongoDB operation on the users collection
+func startSpan(ctx context.Context, name, operation string) (context.Context, trace.Span) {
+	return startSpanOn(ctx, name, usersCollection, operation)
+}
+
+// startSpanOn is startSpan for operations on another collection
+func startSpanOn(ctx context.Context, name, collection, operation string) (context.Context, trace.Span) {
+	return otel.Tracer(TracerName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNameMongoDB,
+			semconv.DBCollectionName(collection),
+			semconv.DBOperationName(operation),
+		),
+	)
+}
+
+// spanError marks span as failed with err and returns err unchanged
+func spanError(span trace.Span, err error) error {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	return err
+}
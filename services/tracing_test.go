@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestStartSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	original := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(original)
+
+	_, span := startSpan(context.Background(), "GetUserByID", "findOne")
+	err := spanError(span, errors.New("boom"))
+	span.End()
+
+	if err == nil || err.Error() != "boom" {
+		t.Errorf("Expected spanError to return the original error, got %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+
+	got := spans[0]
+	if got.Name() != "GetUserByID" {
+		t.Errorf("Expected span name 'GetUserByID', got '%s'", got.Name())
+	}
+	if got.Status().Code != codes.Error {
+		t.Errorf("Expected error status, got %v", got.Status().Code)
+	}
+
+	attributes := map[string]string{}
+	for _, attr := range got.Attributes() {
+		attributes[string(attr.Key)] = attr.Value.AsString()
+	}
+
+	expected := map[string]string{
+		"db.system.name":     "mongodb",
+		"db.collection.name": "users",
+		"db.operation.name":  "findOne",
+	}
+	for key, value := range expected {
+		if attributes[key] != value {
+			t.Errorf("Expected attribute %s='%s', got '%s'", key, value, attributes[key])
+		}
+	}
+}
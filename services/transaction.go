@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// ClientProvider is implemented by databases that expose their client, which
+// lets the service start sessions for multi-document transactions
+type ClientProvider interface {
+	Client() *mongo.Client
+}
+
+// illegalOperationCode is returned by standalone servers for transactional commands
+const illegalOperationCode = 20
+
+// isTransactionNotSupported reports whether err means the deployment cannot run
+// transactions (standalone mongod rather than a replica set or mongos)
+func isTransactionNotSupported(err error) bool {
+	var serverErr mongo.ServerError
+	if !errors.As(err, &serverErr) {
+		return false
+	}
+	return serverErr.HasErrorCodeWithMessage(illegalOperationCode, "Transaction numbers are only allowed")
+}
+
+// withTransaction runs fn inside a transaction when the deployment supports it
+// and falls back to running it directly otherwise
+func (s *UserService) withTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.client == nil {
+		return fn(ctx)
+	}
+
+	session, err := s.client.StartSession()
+	if err != nil {
+		return fn(ctx)
+	}
+	defer session.EndSession(ctx)
+
+	// Transactions must read from the primary, whatever the client's read preference
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	}, options.Transaction().SetReadPreference(readpref.Primary()))
+	if isTransactionNotSupported(err) {
+		return fn(ctx)
+	}
+
+	return err
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestIsTransactionNotSupported(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"nil error", nil, false},
+		{"plain error", errors.New("boom"), false},
+		{
+			name: "standalone server",
+			err: mongo.CommandError{
+				Code:    20,
+				Message: "Transaction numbers are only allowed on a replica set member or mongos",
+			},
+			expected: true,
+		},
+		{
+			name: "wrapped standalone error",
+			err: fmt.Errorf("failed to create user: %w", mongo.CommandError{
+				Code:    20,
+				Message: "Transaction numbers are only allowed on a replica set member or mongos",
+			}),
+			expected: true,
+		},
+		{
+			name:     "other illegal operation",
+			err:      mongo.CommandError{Code: 20, Message: "something else"},
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isTransactionNotSupported(tc.err); got != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestWithTransaction_WithoutClient(t *testing.T) {
+	service := NewUserService(&MockDatabase{})
+
+	called := false
+	expectedErr := errors.New("callback error")
+	err := service.withTransaction(context.Background(), func(ctx context.Context) error {
+		called = true
+		return expectedErr
+	})
+
+	if !called {
+		t.Error("Expected callback to run without a client")
+	}
+
+	if !errors.Is(err, expectedErr) {
+		t.Errorf("Expected callback error to be returned, got %v", err)
+	}
+}
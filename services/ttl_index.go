@@ -0,0 +1,27 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ensureTTLIndex has MongoDB delete the documents of collection once the date in
+// field is expireAfter in the past. The server's TTL monitor runs about once a
+// minute, so a document can outlive its expiry briefly; lookups must still check it.
+// An existing index on field with a different expireAfter fails rather than being
+// changed, since that takes collMod.
+func ensureTTLIndex(ctx context.Context, collection *mongo.Collection, field string, expireAfter time.Duration) error {
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{field: 1},
+		Options: options.Index().SetExpireAfterSeconds(int32(expireAfter / time.Second)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create TTL index on %s.%s: %w", collection.Name(), field, err)
+	}
+	return nil
+}
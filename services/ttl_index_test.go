@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestEnsureTTLIndex(t *testing.T) {
+	ctx := context.Background()
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("sets the field and expiry", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		if err := ensureTTLIndex(ctx, mt.Coll, "expires_at", 90*time.Minute); err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+
+		started := mt.GetStartedEvent()
+		if started == nil || started.CommandName != "createIndexes" {
+			mt.Fatalf("Expected a createIndexes command, got %v", started)
+		}
+		indexes, err := started.Command.Lookup("indexes").Array().Values()
+		if err != nil || len(indexes) != 1 {
+			mt.Fatalf("Expected one index, got %v (error: %v)", indexes, err)
+		}
+		index := indexes[0].Document()
+
+		keys, err := index.Lookup("key").Document().Elements()
+		if err != nil || len(keys) != 1 || keys[0].Key() != "expires_at" || keys[0].Value().AsInt64() != 1 {
+			mt.Errorf("Expected an ascending index on expires_at alone, got %v", index.Lookup("key"))
+		}
+		if expire, ok := index.Lookup("expireAfterSeconds").AsInt64OK(); !ok || expire != 5400 {
+			mt.Errorf("Expected expireAfterSeconds 5400, got %v", index.Lookup("expireAfterSeconds"))
+		}
+	})
+
+	mt.Run("expires at the stored time", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		if err := ensureTTLIndex(ctx, mt.Coll, "expires_at", 0); err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+
+		index := mt.GetStartedEvent().Command.Lookup("indexes").Array().Index(0).Value().Document()
+		if expire, ok := index.Lookup("expireAfterSeconds").AsInt64OK(); !ok || expire != 0 {
+			mt.Errorf("Expected expireAfterSeconds 0, got %v", index.Lookup("expireAfterSeconds"))
+		}
+	})
+
+	mt.Run("conflicting index", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{
+			Code: 85, Name: "IndexOptionsConflict", Message: "An equivalent index already exists with different options",
+		}))
+
+		err := ensureTTLIndex(ctx, mt.Coll, "expires_at", time.Hour)
+		if err == nil || !strings.Contains(err.Error(), mt.Coll.Name()+".expires_at") {
+			mt.Errorf("Expected an error naming the collection and field, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go-mongodb-test/auth"
+	"go-mongodb-test/models"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// StartTwoFactor generates a TOTP secret for the user with the given ID and stores
+// it encrypted. Login does not ask for a code until ConfirmTwoFactor succeeds, so
+// starting again before then simply replaces the secret. It returns
+// ErrTwoFactorEnabled once enrollment has been confirmed.
+func (s *UserService) StartTwoFactor(ctx context.Context, id string) (*models.TwoFactorSetup, error) {
+	ctx, span := startSpan(ctx, "StartTwoFactor", "updateOne")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	user, err := s.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, spanError(span, err)
+	}
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	key, err := auth.GenerateTOTPKey(user.UserID)
+	if err != nil {
+		return nil, spanError(span, fmt.Errorf("failed to generate TOTP secret: %w", err))
+	}
+	encrypted, err := auth.EncryptSecret(key.Secret())
+	if err != nil {
+		return nil, spanError(span, fmt.Errorf("failed to encrypt TOTP secret: %w", err))
+	}
+
+	if err := s.requireCollections(); err != nil {
+		return nil, err
+	}
+
+	// The filter repeats the check so a confirmation racing with this call is not undone
+	result, err := s.collection.UpdateOne(
+		ctx,
+		notDeleted(bson.M{"_id": user.ID, "two_factor_enabled": bson.M{"$ne": true}}),
+		updatePipeline(s.now(), bson.M{"two_factor_secret": encrypted}),
+	)
+	if err != nil {
+		return nil, spanError(span, fmt.Errorf("failed to store TOTP secret: %w", err))
+	}
+
+	s.cache.remove(user.ID.Hex())
+
+	if result.MatchedCount == 0 {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	return &models.TwoFactorSetup{
+		Secret:          key.Secret(),
+		ProvisioningURI: key.URL(),
+	}, nil
+}
+
+// ConfirmTwoFactor enables two-factor authentication once code matches the secret
+// from StartTwoFactor, proving the user's authenticator app is set up
+func (s *UserService) ConfirmTwoFactor(ctx context.Context, id, code string) error {
+	ctx, span := startSpan(ctx, "ConfirmTwoFactor", "updateOne")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidUserID, err)
+	}
+
+	if err := s.requireCollections(); err != nil {
+		return err
+	}
+
+	var user models.User
+	err = s.collection.FindOne(
+		ctx,
+		notDeleted(bson.M{"_id": objectID}),
+		options.FindOne().SetProjection(bson.M{"two_factor_enabled": 1, "two_factor_secret": 1}),
+	).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrUserNotFound
+		}
+		return spanError(span, fmt.Errorf("failed to get user: %w", err))
+	}
+
+	if user.TwoFactorEnabled {
+		return ErrTwoFactorEnabled
+	}
+	if user.TwoFactorSecret == "" {
+		return ErrTwoFactorNotStarted
+	}
+
+	valid, err := auth.ValidateTOTP(code, user.TwoFactorSecret)
+	if err != nil {
+		return spanError(span, fmt.Errorf("failed to check TOTP code: %w", err))
+	}
+	if !valid {
+		return ErrInvalidTwoFactorCode
+	}
+
+	// The filter pins the secret the code was checked against, so that a StartTwoFactor
+	// replacing it in the meantime does not enable a secret the user never confirmed
+	result, err := s.collection.UpdateOne(
+		ctx,
+		notDeleted(bson.M{
+			"_id":                objectID,
+			"two_factor_secret":  user.TwoFactorSecret,
+			"two_factor_enabled": bson.M{"$ne": true},
+		}),
+		updatePipeline(s.now(), bson.M{"two_factor_enabled": true}),
+	)
+	if err != nil {
+		return spanError(span, fmt.Errorf("failed to enable two-factor authentication: %w", err))
+	}
+
+	s.cache.remove(objectID.Hex())
+
+	if result.MatchedCount == 0 {
+		return ErrTwoFactorNotStarted
+	}
+
+	return nil
+}
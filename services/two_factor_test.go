@@ -0,0 +1,109 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-mongodb-test/auth"
+
+	"github.com/pquerna/otp/totp"
+	v1bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestConfirmTwoFactor(t *testing.T) {
+	auth.SetEncryptionKey(bytes.Repeat([]byte{1}, auth.EncryptionKeySize))
+	t.Cleanup(func() { auth.SetEncryptionKey(nil) })
+
+	ctx := context.Background()
+	id := bson.NewObjectID()
+	key, err := auth.GenerateTOTPKey("alice")
+	if err != nil {
+		t.Fatalf("Failed to generate TOTP key: %v", err)
+	}
+	encrypted, err := auth.EncryptSecret(key.Secret())
+	if err != nil {
+		t.Fatalf("Failed to encrypt TOTP secret: %v", err)
+	}
+	stored := func(fields ...v1bson.E) v1bson.D {
+		return mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, append(v1bson.D{{Key: "_id", Value: id}}, fields...))
+	}
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("enables with a valid code", func(mt *mtest.T) {
+		code, err := totp.GenerateCode(key.Secret(), time.Now())
+		if err != nil {
+			mt.Fatalf("Failed to generate code: %v", err)
+		}
+		mt.AddMockResponses(
+			stored(v1bson.E{Key: "two_factor_secret", Value: encrypted}),
+			mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 1}),
+		)
+
+		if err := NewUserService(mt.DB).ConfirmTwoFactor(ctx, id.Hex(), code); err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+
+		mt.GetStartedEvent() // find
+		update := mt.GetStartedEvent()
+		if update == nil || update.CommandName != "update" {
+			mt.Fatalf("Expected an update command, got %v", update)
+		}
+		statement := update.Command.Lookup("updates").Array().Index(0).Value().Document()
+		set := pipelineSet(mt, statement.Lookup("u"))
+		if enabled, ok := set.Lookup("two_factor_enabled", "$literal").BooleanOK(); !ok || !enabled {
+			mt.Errorf("Expected two_factor_enabled to be set, got %v", set)
+		}
+		if secret, ok := statement.Lookup("q", "two_factor_secret").StringValueOK(); !ok || secret != encrypted {
+			mt.Errorf("Expected the update to match the confirmed secret, got %v", statement)
+		}
+	})
+
+	mt.Run("secret replaced before the update", func(mt *mtest.T) {
+		code, err := totp.GenerateCode(key.Secret(), time.Now())
+		if err != nil {
+			mt.Fatalf("Failed to generate code: %v", err)
+		}
+		mt.AddMockResponses(
+			stored(v1bson.E{Key: "two_factor_secret", Value: encrypted}),
+			mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 0}),
+		)
+
+		if err := NewUserService(mt.DB).ConfirmTwoFactor(ctx, id.Hex(), code); !errors.Is(err, ErrTwoFactorNotStarted) {
+			mt.Errorf("Expected ErrTwoFactorNotStarted, got %v", err)
+		}
+	})
+
+	mt.Run("rejects a wrong code", func(mt *mtest.T) {
+		mt.AddMockResponses(stored(v1bson.E{Key: "two_factor_secret", Value: encrypted}))
+
+		code, _ := totp.GenerateCode(key.Secret(), time.Now().Add(-time.Hour))
+		if err := NewUserService(mt.DB).ConfirmTwoFactor(ctx, id.Hex(), code); !errors.Is(err, ErrInvalidTwoFactorCode) {
+			mt.Errorf("Expected ErrInvalidTwoFactorCode, got %v", err)
+		}
+	})
+
+	mt.Run("not started", func(mt *mtest.T) {
+		mt.AddMockResponses(stored())
+
+		if err := NewUserService(mt.DB).ConfirmTwoFactor(ctx, id.Hex(), "123456"); !errors.Is(err, ErrTwoFactorNotStarted) {
+			mt.Errorf("Expected ErrTwoFactorNotStarted, got %v", err)
+		}
+	})
+
+	mt.Run("already enabled", func(mt *mtest.T) {
+		mt.AddMockResponses(stored(
+			v1bson.E{Key: "two_factor_enabled", Value: true},
+			v1bson.E{Key: "two_factor_secret", Value: encrypted},
+		))
+
+		if err := NewUserService(mt.DB).ConfirmTwoFactor(ctx, id.Hex(), "123456"); !errors.Is(err, ErrTwoFactorEnabled) {
+			mt.Errorf("Expected ErrTwoFactorEnabled, got %v", err)
+		}
+	})
+}
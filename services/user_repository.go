@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-mongodb-test/models"
+
+	v1bson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// UserRepository stores the users behind UserService's core operations. Lookups
+// skip soft-deleted users and report a missing one as a nil user rather than an
+// error, leaving it to the service to decide what absence means.
+type UserRepository interface {
+	// Create assigns the user an ID and stores it, returning ErrUserExists or
+	// ErrEmailExists when the user_id or email is already taken
+	Create(ctx context.Context, user *models.User) error
+	// FindByID returns the user without its password hash and TOTP secret
+	FindByID(ctx context.Context, id bson.ObjectID) (*models.User, error)
+	// FindByUserID returns the user with the given user_id; includePassword loads
+	// the credentials for login
+	FindByUserID(ctx context.Context, userID string, includePassword bool) (*models.User, error)
+	// FindByEmail is FindByUserID for an already normalized email
+	FindByEmail(ctx context.Context, email string, includePassword bool) (*models.User, error)
+	// Update applies update and reports whether a user matched; a version check that
+	// fails counts as no match
+	Update(ctx context.Context, id bson.ObjectID, update UserUpdate) (bool, error)
+	// Delete soft-deletes the user at the given time and returns it as it was just
+	// before, without credentials
+	Delete(ctx context.Context, id bson.ObjectID, at time.Time) (*models.User, error)
+	// List returns the users matching opts, in the order opts asks for
+	List(ctx context.Context, opts models.ListUsersOptions) ([]*models.User, error)
+	// Count returns how many users List would return for opts without its Offset and Limit
+	Count(ctx context.Context, opts models.ListUsersOptions) (int64, error)
+}
+
+// UserUpdate is a partial update of a user, keyed by stored field name
+type UserUpdate struct {
+	Set    map[string]interface{}
+	Remove []string
+	// UpdatedAt becomes the new updated_at, or one millisecond past the stored value
+	// if that has already reached it
+	UpdatedAt time.Time
+	// ExpectedUpdatedAt, when non-nil, only lets the update apply while the stored
+	// updated_at still equals it
+	ExpectedUpdatedAt *time.Time
+}
+
+// mongoUserRepository is the UserRepository backed by the users collection
+type mongoUserRepository struct {
+	collection *mongo.Collection
+	// listCollection serves List, and may carry a different read preference
+	listCollection *mongo.Collection
+}
+
+func newMongoUserRepository(collection, listCollection *mongo.Collection) *mongoUserRepository {
+	return &mongoUserRepository{collection: collection, listCollection: listCollection}
+}
+
+func (r *mongoUserRepository) Create(ctx context.Context, user *models.User) error {
+	// The ID is assigned here because one generated by the v1 driver comes back as
+	// its own ObjectID type rather than the v2 bson.ObjectID the model uses
+	user.ID = bson.NewObjectID()
+	if _, err := r.collection.InsertOne(ctx, user); err != nil {
+		if existsErr := duplicateKeyError(err); existsErr != nil {
+			return existsErr
+		}
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) FindByID(ctx context.Context, id bson.ObjectID) (*models.User, error) {
+	return r.findOne(ctx, bson.M{"_id": id}, false)
+}
+
+func (r *mongoUserRepository) FindByUserID(ctx context.Context, userID string, includePassword bool) (*models.User, error) {
+	return r.findOne(ctx, bson.M{"user_id": userID}, includePassword)
+}
+
+func (r *mongoUserRepository) FindByEmail(ctx context.Context, email string, includePassword bool) (*models.User, error) {
+	return r.findOne(ctx, bson.M{"email": email}, includePassword)
+}
+
+// findOne returns the non-deleted user matching filter, or nil when there is none
+func (r *mongoUserRepository) findOne(ctx context.Context, filter bson.M, includePassword bool) (*models.User, error) {
+	findOptions := options.FindOne()
+	if !includePassword {
+		findOptions.SetProjection(withoutPassword)
+	}
+
+	var user models.User
+	err := r.collection.FindOne(ctx, notDeleted(filter), findOptions).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return &user, nil
+}
+
+func (r *mongoUserRepository) Update(ctx context.Context, id bson.ObjectID, update UserUpdate) (bool, error) {
+	// Matching on updated_at makes the version check and the write a single atomic step
+	filter := notDeleted(bson.M{"_id": id})
+	if update.ExpectedUpdatedAt != nil {
+		filter["updated_at"] = *update.ExpectedUpdatedAt
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, updatePipeline(update.UpdatedAt, update.Set, update.Remove...))
+	if err != nil {
+		// Another request can take the user_id or email between the check and the write
+		if existsErr := duplicateKeyError(err); existsErr != nil {
+			return false, existsErr
+		}
+		return false, fmt.Errorf("failed to update user: %w", err)
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// updatePipeline is a single-stage update pipeline that sets the given fields,
+// removes the listed ones and moves updated_at forward with nextUpdatedAt. Every
+// write that touches updated_at goes through it, so none can move it backwards.
+// Pipeline stages read strings starting with "$" as field paths, so each new
+// value, such as a bcrypt hash, is wrapped in $literal.
+func updatePipeline(now time.Time, set map[string]interface{}, remove ...string) bson.A {
+	stage := bson.M{"updated_at": nextUpdatedAt(now)}
+	for field, value := range set {
+		stage[field] = bson.M{"$literal": value}
+	}
+	for _, field := range remove {
+		stage[field] = "$$REMOVE"
+	}
+	return bson.A{bson.M{"$set": stage}}
+}
+
+// nextUpdatedAt is the update pipeline expression for updated_at. It is now unless
+// the stored value has already reached it, through clock skew between instances or
+// two updates within a millisecond; then it is one millisecond past the stored
+// value. updated_at therefore strictly increases with every update, which keeps
+// the ETags derived from it distinct.
+func nextUpdatedAt(now time.Time) bson.M {
+	return bson.M{"$cond": bson.A{
+		bson.M{"$gt": bson.A{now, "$updated_at"}},
+		now,
+		bson.M{"$add": bson.A{"$updated_at", 1}},
+	}}
+}
+
+func (r *mongoUserRepository) Delete(ctx context.Context, id bson.ObjectID, at time.Time) (*models.User, error) {
+	// The lookup and the delete are one atomic findAndModify, so the returned user
+	// is exactly the one that was deleted
+	var user models.User
+	err := r.collection.FindOneAndUpdate(
+		ctx,
+		notDeleted(bson.M{"_id": id}),
+		updatePipeline(at, bson.M{"deleted_at": at}),
+		options.FindOneAndUpdate().SetProjection(withoutPassword),
+	).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to delete user: %w", err)
+	}
+	return &user, nil
+}
+
+func (r *mongoUserRepository) List(ctx context.Context, opts models.ListUsersOptions) ([]*models.User, error) {
+	field, descending := opts.SortField, opts.SortDescending
+	if field == "" {
+		field, descending = "created_at", true
+	}
+	direction := 1
+	if descending {
+		direction = -1
+	}
+	// _id breaks ties in the same direction, so that users sharing a sort value keep
+	// one order across pages and none is skipped or repeated between offsets. The
+	// sort is a v1 bson.D, since the v1 driver cannot marshal the v2 type and a map
+	// would not keep the keys in order.
+	findOptions := options.Find().
+		SetSort(v1bson.D{{Key: field, Value: direction}, {Key: "_id", Value: direction}}).
+		SetProjection(withoutPassword)
+	if opts.Offset > 0 {
+		findOptions.SetSkip(int64(opts.Offset))
+	}
+	if opts.Limit > 0 {
+		findOptions.SetLimit(int64(opts.Limit))
+	}
+
+	cursor, err := r.listCollection.Find(ctx, listUsersFilter(opts), findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var users []*models.User
+	for cursor.Next(ctx) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			return nil, fmt.Errorf("failed to decode user: %w", err)
+		}
+		users = append(users, &user)
+	}
+	// Next also stops when ctx is cancelled or times out, which only Err reports
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read users: %w", err)
+	}
+
+	return users, nil
+}
+
+func (r *mongoUserRepository) Count(ctx context.Context, opts models.ListUsersOptions) (int64, error) {
+	count, err := r.listCollection.CountDocuments(ctx, listUsersFilter(opts))
+	if err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+	return count, nil
+}
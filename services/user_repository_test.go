@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-mongodb-test/models"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestUserService_WithRepository_CreateUser(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryUserRepository()
+	service := NewUserServiceWithRepository(repo)
+
+	_, err := service.CreateUser(ctx, &models.CreateUserRequest{
+		UserID:   "alice",
+		Email:    " Alice@Example.com ",
+		Password: "s3cretpass",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	stored, _ := repo.FindByUserID(ctx, "alice", true)
+	if stored.Email != "alice@example.com" || stored.EmailDomain != "example.com" {
+		t.Errorf("Expected the normalized email and its domain, got '%s' and '%s'", stored.Email, stored.EmailDomain)
+	}
+	if stored.Password == "s3cretpass" || !stored.CheckPassword("s3cretpass") {
+		t.Error("Expected a bcrypt hash of the password to be stored")
+	}
+	if stored.Status != models.StatusActive || len(stored.Roles) == 0 || stored.VerificationToken == "" {
+		t.Errorf("Expected status, default roles and a verification token, got %+v", stored)
+	}
+
+	duplicates := map[string]struct {
+		req      models.CreateUserRequest
+		expected error
+	}{
+		"Taken user_id": {models.CreateUserRequest{UserID: "alice", Email: "other@example.com", Password: "s3cretpass"}, ErrUserExists},
+		"Taken email":   {models.CreateUserRequest{UserID: "other", Email: "ALICE@example.com", Password: "s3cretpass"}, ErrEmailExists},
+	}
+	for name, tc := range duplicates {
+		t.Run(name, func(t *testing.T) {
+			if _, err := service.CreateUser(ctx, &tc.req); !errors.Is(err, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, err)
+			}
+		})
+	}
+
+	if users, _ := repo.List(ctx, models.ListUsersOptions{IncludeDeleted: true}); len(users) != 1 {
+		t.Errorf("Expected only alice to be stored, got %d users", len(users))
+	}
+}
+
+func TestUserService_WithRepository_UpdateUser(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryUserRepository()
+	service := NewUserServiceWithRepository(repo)
+
+	alice, err := service.CreateUser(ctx, &models.CreateUserRequest{UserID: "alice", Email: "alice@example.com", Password: "s3cretpass"})
+	if err != nil {
+		t.Fatalf("Failed to create alice: %v", err)
+	}
+	if _, err := service.CreateUser(ctx, &models.CreateUserRequest{UserID: "bob", Email: "bob@example.com", Password: "s3cretpass"}); err != nil {
+		t.Fatalf("Failed to create bob: %v", err)
+	}
+
+	t.Run("Taken by another user", func(t *testing.T) {
+		taken := "bob"
+		if _, err := service.UpdateUser(ctx, alice.ID.Hex(), &models.UpdateUserRequest{UserID: &taken}, nil); !errors.Is(err, ErrUserExists) {
+			t.Errorf("Expected ErrUserExists, got %v", err)
+		}
+	})
+
+	t.Run("Own user_id and a new password", func(t *testing.T) {
+		own, password := "alice", "n3wpassword"
+		updated, err := service.UpdateUser(ctx, alice.ID.Hex(), &models.UpdateUserRequest{UserID: &own, Password: &password}, nil)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		stored, _ := repo.FindByUserID(ctx, "alice", true)
+		if updated.UserID != "alice" || !stored.CheckPassword("n3wpassword") {
+			t.Errorf("Expected the new password to be hashed and stored, got %+v", updated)
+		}
+	})
+
+	t.Run("New email", func(t *testing.T) {
+		email := "Alice@Corp.Example.com"
+		if _, err := service.UpdateUser(ctx, alice.ID.Hex(), &models.UpdateUserRequest{Email: &email}, nil); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		stored, _ := repo.FindByUserID(ctx, "alice", false)
+		if stored.EmailDomain != "corp.example.com" {
+			t.Errorf("Expected the email domain to follow the email, got '%s'", stored.EmailDomain)
+		}
+	})
+
+	t.Run("Stale version", func(t *testing.T) {
+		name := "Alice"
+		stale := alice.UpdatedAt.Add(-time.Hour)
+		if _, err := service.UpdateUser(ctx, alice.ID.Hex(), &models.UpdateUserRequest{FirstName: &name}, &stale); !errors.Is(err, ErrVersionMismatch) {
+			t.Errorf("Expected ErrVersionMismatch, got %v", err)
+		}
+	})
+
+	t.Run("Missing user", func(t *testing.T) {
+		name := "Nobody"
+		if _, err := service.UpdateUser(ctx, bson.NewObjectID().Hex(), &models.UpdateUserRequest{FirstName: &name}, nil); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("Expected ErrUserNotFound, got %v", err)
+		}
+	})
+}
+
+func TestUserService_WithRepository_DeleteUser(t *testing.T) {
+	ctx := context.Background()
+	service := NewUserServiceWithRepository(NewInMemoryUserRepository())
+
+	alice, err := service.CreateUser(ctx, &models.CreateUserRequest{UserID: "alice", Email: "alice@example.com", Password: "s3cretpass"})
+	if err != nil {
+		t.Fatalf("Failed to create alice: %v", err)
+	}
+
+	deleted, err := service.DeleteUser(ctx, alice.ID.Hex())
+	if err != nil || deleted.UserID != "alice" {
+		t.Fatalf("Expected alice to be deleted, got %v (error: %v)", deleted, err)
+	}
+
+	if _, err := service.GetUserByID(ctx, alice.ID.Hex()); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected a deleted user to be not found, got %v", err)
+	}
+	if _, err := service.DeleteUser(ctx, alice.ID.Hex()); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected a second delete to report ErrUserNotFound, got %v", err)
+	}
+
+	users, err := service.ListUsers(ctx, models.ListUsersOptions{IncludeDeleted: true})
+	if err != nil || len(users) != 1 || users[0].DeletedAt == nil {
+		t.Errorf("Expected the deleted user when including deleted ones, got %v (error: %v)", users, err)
+	}
+}
@@ -4,13 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"go-mongodb-test/models"
 
-	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
 // DatabaseCollectionProvider interface for database operations
@@ -19,117 +23,570 @@ type DatabaseCollectionProvider interface {
 }
 
 type UserService struct {
+	// users holds the user documents for the core create, read, update, delete and
+	// list operations; the other methods still work on the collections directly
+	users      UserRepository
 	collection *mongo.Collection
+	// listCollection serves list, search, count and export reads; it is collection
+	// unless WithListReadPreference routes those reads elsewhere
+	listCollection *mongo.Collection
+	resetTokens    *mongo.Collection
+	refreshTokens  *mongo.Collection
+	client         *mongo.Client
+	cache          *userCache
+	// operationTimeout bounds each method call; zero leaves only the caller's deadline
+	operationTimeout time.Duration
+	// lockoutThreshold consecutive failed logins lock an account for lockoutDuration
+	lockoutThreshold int
+	lockoutDuration  time.Duration
+	// now stamps created_at, updated_at and the other times the service writes
+	now func() time.Time
+	// statsCache keeps the last Stats result for StatsCacheTTL
+	statsCache statsCache
+	// idempotencyKeys maps the Idempotency-Key of recent signups to the users they created
+	idempotencyKeys *mongo.Collection
+	// auditEvents records every change to a user; nil leaves auditing off
+	auditEvents *mongo.Collection
+	// events receives the same changes as the audit trail; nil publishes nothing
+	events *EventHub
+	// eventsWatched is set while WatchUserEvents feeds events from the change stream
+	eventsWatched atomic.Bool
+	// passwordResetTTL and refreshTokenTTL are how long newly issued tokens stay valid
+	passwordResetTTL time.Duration
+	refreshTokenTTL  time.Duration
 }
 
-func NewUserService(db DatabaseCollectionProvider) *UserService {
-	return &UserService{
-		collection: db.Collection("users"),
+// Option configures optional UserService behavior
+type Option func(*UserService)
+
+// WithCache serves GetUserByID from an in-memory LRU of up to size users, each kept
+// for at most ttl. Writes through this service invalidate the entries they touch,
+// but writes made by other instances stay invisible until the entry expires.
+// A size of zero or less leaves caching off.
+func WithCache(size int, ttl time.Duration) Option {
+	return func(s *UserService) {
+		if size > 0 {
+			s.cache = newUserCache(size, ttl)
+		}
 	}
 }
 
-func (s *UserService) CreateUser(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
-	// Check if user already exists
-	existingUser, _ := s.GetUserByUserID(ctx, req.UserID)
-	if existingUser != nil {
-		return nil, errors.New("user with this user_id already exists")
+// WithClock replaces time.Now as the source of the times the service writes and
+// compares against, so that tests can assert exact timestamps
+func WithClock(now func() time.Time) Option {
+	return func(s *UserService) {
+		if now != nil {
+			s.now = now
+		}
+	}
+}
+
+// WithTokenTTLs sets how long newly issued password reset and refresh tokens stay
+// valid. Tokens already issued keep the expiry they were stored with. A duration of
+// zero or less keeps the default for that kind of token.
+func WithTokenTTLs(passwordReset, refresh time.Duration) Option {
+	return func(s *UserService) {
+		if passwordReset > 0 {
+			s.passwordResetTTL = passwordReset
+		}
+		if refresh > 0 {
+			s.refreshTokenTTL = refresh
+		}
+	}
+}
+
+// WithOperationTimeout gives every method call at most d on top of the caller's own
+// deadline, so a stalled query fails instead of holding the request. Streaming
+// exports and index builds are exempt, since their duration grows with the data.
+func WithOperationTimeout(d time.Duration) Option {
+	return func(s *UserService) {
+		s.operationTimeout = d
+	}
+}
+
+// WithListReadPreference sends ListUsers, SearchUsers, CountUsers and ExportUsers
+// to the members rp selects, such as secondaryPreferred to take load off the
+// primary. Their results may then lag recent writes. Lookups by ID, user_id or
+// email keep the client's read preference, so a write stays visible to the read
+// that follows it.
+func WithListReadPreference(rp *readpref.ReadPref) Option {
+	return func(s *UserService) {
+		if rp == nil || s.collection == nil {
+			return
+		}
+		if clone, err := s.collection.Clone(options.Collection().SetReadPreference(rp)); err == nil {
+			s.listCollection = clone
+		}
+	}
+}
+
+// withOperationTimeout derives the context for one method call. Cancellation of ctx
+// still propagates, so cursor loops stop as soon as the client goes away.
+func (s *UserService) withOperationTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.operationTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.operationTimeout)
+}
+
+func NewUserService(db DatabaseCollectionProvider, opts ...Option) *UserService {
+	collection := db.Collection(usersCollection)
+	service := &UserService{
+		collection:       collection,
+		listCollection:   collection,
+		resetTokens:      db.Collection(passwordResetCollection),
+		refreshTokens:    db.Collection(refreshTokenCollection),
+		idempotencyKeys:  db.Collection(idempotencyCollection),
+		auditEvents:      db.Collection(auditCollection),
+		passwordResetTTL: PasswordResetTokenTTL,
+		refreshTokenTTL:  RefreshTokenTTL,
+		now:              time.Now,
 	}
 
-	existingUser, _ = s.GetUserByEmail(ctx, req.Email)
-	if existingUser != nil {
-		return nil, errors.New("user with this email already exists")
+	for _, opt := range opts {
+		opt(service)
+	}
+	service.users = newMongoUserRepository(service.collection, service.listCollection)
+
+	// Transactions are only available when the provider exposes its client
+	if provider, ok := db.(ClientProvider); ok {
+		service.client = provider.Client()
+	}
+
+	return service
+}
+
+// NewUserServiceWithRepository returns a service that stores users in repo, without
+// MongoDB. Creating, reading, updating, deleting and listing users work; the
+// operations that still need the collections, such as login tokens, lockout and
+// two-factor enrollment, return ErrStorageUnsupported.
+func NewUserServiceWithRepository(repo UserRepository, opts ...Option) *UserService {
+	service := &UserService{
+		passwordResetTTL: PasswordResetTokenTTL,
+		refreshTokenTTL:  RefreshTokenTTL,
+		now:              time.Now,
+	}
+	for _, opt := range opts {
+		opt(service)
+	}
+	service.users = repo
+	return service
+}
+
+// requireCollections returns ErrStorageUnsupported when the service was built by
+// NewUserServiceWithRepository, for the methods that work on the collections directly
+func (s *UserService) requireCollections() error {
+	if s.collection == nil {
+		return ErrStorageUnsupported
+	}
+	return nil
+}
+
+// EnsureIndexes creates the unique indexes backing user_id and email uniqueness,
+// plus a sparse index for looking up pending email verifications. Without MongoDB
+// there is nothing to create, since the repository enforces its own constraints.
+func (s *UserService) EnsureIndexes(ctx context.Context) error {
+	if s.collection == nil {
+		return nil
+	}
+
+	ctx, span := startSpan(ctx, "EnsureIndexes", "createIndexes")
+	defer span.End()
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.M{"user_id": 1},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.M{"email": 1},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.M{"verification_token": 1},
+			Options: options.Index().SetSparse(true),
+		},
+		{
+			Keys: bson.M{"email_domain": 1},
+		},
+	}
+
+	if _, err := s.collection.Indexes().CreateMany(ctx, indexes); err != nil {
+		return spanError(span, fmt.Errorf("failed to create indexes: %w", err))
+	}
+
+	// Tokens are deleted as soon as they expire. Idempotency keys are only
+	// replayable for IdempotencyKeyTTL, after which they are deleted as well.
+	ttlIndexes := []struct {
+		collection  *mongo.Collection
+		field       string
+		expireAfter time.Duration
+	}{
+		{s.resetTokens, "expires_at", 0},
+		{s.refreshTokens, "expires_at", 0},
+		{s.idempotencyKeys, "created_at", IdempotencyKeyTTL},
+	}
+	for _, index := range ttlIndexes {
+		if err := ensureTTLIndex(ctx, index.collection, index.field, index.expireAfter); err != nil {
+			return spanError(span, err)
+		}
+	}
+
+	if _, err := s.auditEvents.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.M{"target_id": 1}}); err != nil {
+		return spanError(span, fmt.Errorf("failed to create audit event index: %w", err))
+	}
+
+	if _, err := s.refreshTokens.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.M{"user_id": 1}}); err != nil {
+		return spanError(span, fmt.Errorf("failed to create refresh token index: %w", err))
+	}
+
+	return nil
+}
+
+// duplicateKeyIndex picks the index name out of a duplicate-key message, such as
+// "E11000 duplicate key error collection: db.users index: email_1 dup key: {...}"
+var duplicateKeyIndex = regexp.MustCompile(`index: (\S+)`)
+
+// duplicateKeyError translates a duplicate-key write error on the user_id or email
+// index into the matching "already exists" error. It returns nil for any other
+// error, so the caller can report it as it is.
+func duplicateKeyError(err error) error {
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+
+	switch duplicateKeyField(err) {
+	case "user_id":
+		return ErrUserExists
+	case "email":
+		return ErrEmailExists
+	}
+	return nil
+}
+
+// duplicateKeyField names the first field of the unique index behind a
+// duplicate-key error. The keyPattern reported by the server is preferred, since
+// the message also quotes the duplicate value, which may contain any text.
+func duplicateKeyField(err error) string {
+	var writeException mongo.WriteException
+	if errors.As(err, &writeException) {
+		for _, writeErr := range writeException.WriteErrors {
+			if writeErr.Code != 11000 && writeErr.Code != 11001 && writeErr.Code != 12582 {
+				continue
+			}
+			if pattern, ok := writeErr.Raw.Lookup("keyPattern").DocumentOK(); ok {
+				if elements, err := pattern.Elements(); err == nil && len(elements) > 0 {
+					return elements[0].Key()
+				}
+			}
+			if field := indexField(writeErr.Message); field != "" {
+				return field
+			}
+		}
 	}
 
+	// Command errors, such as a failed transaction commit, only carry the message
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) {
+		return indexField(commandErr.Message)
+	}
+	return ""
+}
+
+// indexField returns the field of a single-field index named in a duplicate-key
+// message, so "user_id_1" yields "user_id"
+func indexField(message string) string {
+	match := duplicateKeyIndex.FindStringSubmatch(message)
+	if match == nil {
+		return ""
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(match[1], "_1"), "_-1")
+}
+
+// withoutPassword is the projection for reads that don't verify credentials, so the
+// password hash and the TOTP secret never leave the database
+var withoutPassword = bson.M{"password": 0, "two_factor_secret": 0}
+
+// notDeleted adds the soft-delete exclusion to a filter
+func notDeleted(filter bson.M) bson.M {
+	filter["deleted_at"] = bson.M{"$exists": false}
+	return filter
+}
+
+func (s *UserService) CreateUser(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
+	return s.createUser(ctx, req, models.DefaultRoles())
+}
+
+// createUser is CreateUser granting roles instead of the defaults
+func (s *UserService) createUser(ctx context.Context, req *models.CreateUserRequest, roles []string) (*models.User, error) {
+	ctx, span := startSpan(ctx, "CreateUser", "insertOne")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	email, err := models.NormalizeEmail(req.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
 	user := &models.User{
-		UserID:    req.UserID,
-		Email:     req.Email,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		UserID:      req.UserID,
+		Email:       email,
+		EmailDomain: models.EmailDomain(email),
+		FirstName:   strings.TrimSpace(req.FirstName),
+		LastName:    strings.TrimSpace(req.LastName),
+		AvatarURL:   req.AvatarURL,
+		Roles:       roles,
+		Status:      models.StatusActive,
+		CreatedAt:   now,
+		UpdatedAt:   now,
 	}
 
 	if err := user.HashPassword(req.Password); err != nil {
 		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
 
-	result, err := s.collection.InsertOne(ctx, user)
+	if err := issueVerificationToken(user); err != nil {
+		return nil, err
+	}
+
+	// The uniqueness checks and insert run atomically on replica sets
+	err = s.withTransaction(ctx, func(ctx context.Context) error {
+		// Check if user already exists
+		existingUser, _ := s.GetUserByUserID(ctx, req.UserID)
+		if existingUser != nil {
+			return ErrUserExists
+		}
+
+		existingUser, _ = s.GetUserByEmail(ctx, email)
+		if existingUser != nil {
+			return ErrEmailExists
+		}
+
+		return s.users.Create(ctx, user)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
+		return nil, spanError(span, err)
 	}
 
-	user.ID = result.InsertedID.(bson.ObjectID)
+	s.recordAudit(ctx, user.ID, models.AuditActionCreate, createdFields(user))
+
 	return user, nil
 }
 
+// createdFields lists the fields a new user was given, for its audit event
+func createdFields(user *models.User) []string {
+	fields := []string{"user_id", "email", "password"}
+	profile := []struct {
+		field, value string
+	}{
+		{"first_name", user.FirstName},
+		{"last_name", user.LastName},
+		{"avatar_url", user.AvatarURL},
+	}
+	for _, p := range profile {
+		if p.value != "" {
+			fields = append(fields, p.field)
+		}
+	}
+	return fields
+}
+
 func (s *UserService) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	ctx, span := startSpan(ctx, "GetUserByID", "findOne")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
 	objectID, err := bson.ObjectIDFromHex(id)
 	if err != nil {
-		return nil, fmt.Errorf("invalid user ID: %w", err)
+		return nil, fmt.Errorf("%w: %v", ErrInvalidUserID, err)
+	}
+
+	if user, ok := s.cache.get(objectID.Hex()); ok {
+		return user, nil
 	}
 
-	var user models.User
-	err = s.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&user)
+	user, err := s.users.FindByID(ctx, objectID)
 	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil, errors.New("user not found")
-		}
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		return nil, spanError(span, err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
 	}
 
-	return &user, nil
+	s.cache.add(user)
+	return user, nil
 }
 
 func (s *UserService) GetUserByUserID(ctx context.Context, userID string) (*models.User, error) {
-	var user models.User
-	err := s.collection.FindOne(ctx, bson.M{"user_id": userID}).Decode(&user)
+	return s.findActiveUser(ctx, "GetUserByUserID", func(ctx context.Context) (*models.User, error) {
+		return s.users.FindByUserID(ctx, userID, false)
+	})
+}
+
+// GetUserByUserIDWithPassword is GetUserByUserID including the password hash, for login
+func (s *UserService) GetUserByUserIDWithPassword(ctx context.Context, userID string) (*models.User, error) {
+	return s.findActiveUser(ctx, "GetUserByUserIDWithPassword", func(ctx context.Context) (*models.User, error) {
+		return s.users.FindByUserID(ctx, userID, true)
+	})
+}
+
+func (s *UserService) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return s.findActiveUser(ctx, "GetUserByEmail", func(ctx context.Context) (*models.User, error) {
+		return s.users.FindByEmail(ctx, normalizeEmailLookup(email), false)
+	})
+}
+
+// GetUserByEmailWithPassword is GetUserByEmail including the password hash, for login
+func (s *UserService) GetUserByEmailWithPassword(ctx context.Context, email string) (*models.User, error) {
+	return s.findActiveUser(ctx, "GetUserByEmailWithPassword", func(ctx context.Context) (*models.User, error) {
+		return s.users.FindByEmail(ctx, normalizeEmailLookup(email), true)
+	})
+}
+
+// GetUserByIdentifier finds the user whose user_id or email matches identifier,
+// including the password hash, for login. user_id is not restricted, so one user's
+// user_id may equal another's email; the user_id match wins in that case.
+func (s *UserService) GetUserByIdentifier(ctx context.Context, identifier string) (*models.User, error) {
+	ctx, span := startSpan(ctx, "GetUserByIdentifier", "find")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	if err := s.requireCollections(); err != nil {
+		return nil, err
+	}
+
+	filter := notDeleted(bson.M{"$or": bson.A{
+		bson.M{"user_id": identifier},
+		bson.M{"email": normalizeEmailLookup(identifier)},
+	}})
+	cursor, err := s.collection.Find(ctx, filter, options.Find().SetLimit(2))
 	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil, nil
+		return nil, spanError(span, fmt.Errorf("failed to get user: %w", err))
+	}
+
+	var users []*models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, spanError(span, fmt.Errorf("failed to decode users: %w", err))
+	}
+
+	for _, user := range users {
+		if user.UserID == identifier {
+			return user, nil
 		}
-		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
+	if len(users) == 0 {
+		return nil, nil
+	}
+	return users[0], nil
+}
 
-	return &user, nil
+// normalizeEmailLookup normalizes lookups the same way as stored addresses
+func normalizeEmailLookup(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
 
-func (s *UserService) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
-	var user models.User
-	err := s.collection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+// findActiveUser runs a repository lookup of a single user under its own span and
+// operation timeout. The user is nil when there is none.
+func (s *UserService) findActiveUser(ctx context.Context, spanName string, find func(ctx context.Context) (*models.User, error)) (*models.User, error) {
+	ctx, span := startSpan(ctx, spanName, "findOne")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	user, err := find(ctx)
 	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil, nil
+		return nil, spanError(span, err)
+	}
+	return user, nil
+}
+
+// SearchUsers returns users whose user_id or email contains query, case-insensitively
+func (s *UserService) SearchUsers(ctx context.Context, query string) ([]*models.User, error) {
+	ctx, span := startSpan(ctx, "SearchUsers", "find")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	pattern := bson.M{"$regex": regexp.QuoteMeta(query), "$options": "i"}
+	filter := notDeleted(bson.M{
+		"$or": bson.A{
+			bson.M{"user_id": pattern},
+			bson.M{"email": pattern},
+		},
+	})
+
+	if err := s.requireCollections(); err != nil {
+		return nil, err
+	}
+
+	cursor, err := s.listCollection.Find(ctx, filter, options.Find().SetProjection(withoutPassword))
+	if err != nil {
+		return nil, spanError(span, fmt.Errorf("failed to search users: %w", err))
+	}
+	defer cursor.Close(ctx)
+
+	users := []*models.User{}
+	for cursor.Next(ctx) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			return nil, spanError(span, fmt.Errorf("failed to decode user: %w", err))
 		}
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		users = append(users, &user)
+	}
+	// Next also stops when ctx is cancelled or times out, which only Err reports
+	if err := cursor.Err(); err != nil {
+		return nil, spanError(span, fmt.Errorf("failed to read users: %w", err))
 	}
 
-	return &user, nil
+	return users, nil
 }
 
-func (s *UserService) UpdateUser(ctx context.Context, id string, req *models.UpdateUserRequest) (*models.User, error) {
+// UpdateUser applies the fields set in req, revoking the user's refresh tokens when
+// it sets a password. When expectedUpdatedAt is non-nil the update only happens if
+// the stored updated_at still equals it; otherwise ErrVersionMismatch is returned
+// and nothing is written.
+func (s *UserService) UpdateUser(ctx context.Context, id string, req *models.UpdateUserRequest, expectedUpdatedAt *time.Time) (*models.User, error) {
+	ctx, span := startSpan(ctx, "UpdateUser", "updateOne")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
 	objectID, err := bson.ObjectIDFromHex(id)
 	if err != nil {
-		return nil, fmt.Errorf("invalid user ID: %w", err)
+		return nil, fmt.Errorf("%w: %v", ErrInvalidUserID, err)
 	}
 
-	updateFields := bson.M{
-		"updated_at": time.Now(),
-	}
+	// MongoDB stores dates with millisecond precision; truncating keeps the value
+	// comparable with what a later read returns
+	now := s.now().Truncate(time.Millisecond)
+	updateFields := bson.M{}
 
 	if req.UserID != nil {
 		// Check if the new user_id is already taken
 		existingUser, _ := s.GetUserByUserID(ctx, *req.UserID)
 		if existingUser != nil && existingUser.ID != objectID {
-			return nil, errors.New("user with this user_id already exists")
+			return nil, ErrUserExists
 		}
 		updateFields["user_id"] = *req.UserID
 	}
 
 	if req.Email != nil {
+		email, err := models.NormalizeEmail(*req.Email)
+		if err != nil {
+			return nil, err
+		}
+
 		// Check if the new email is already taken
-		existingUser, _ := s.GetUserByEmail(ctx, *req.Email)
+		existingUser, _ := s.GetUserByEmail(ctx, email)
 		if existingUser != nil && existingUser.ID != objectID {
-			return nil, errors.New("user with this email already exists")
+			return nil, ErrEmailExists
 		}
-		updateFields["email"] = *req.Email
+		updateFields["email"] = email
+		updateFields["email_domain"] = models.EmailDomain(email)
 	}
 
 	if req.Password != nil {
@@ -140,51 +597,314 @@ func (s *UserService) UpdateUser(ctx context.Context, id string, req *models.Upd
 		updateFields["password"] = user.Password
 	}
 
-	_, err = s.collection.UpdateOne(
+	// Profile fields set to an empty string are removed rather than stored empty
+	var removeFields []string
+	profileFields := map[string]*string{
+		"first_name": req.FirstName,
+		"last_name":  req.LastName,
+		"avatar_url": req.AvatarURL,
+	}
+	for field, value := range profileFields {
+		if value == nil {
+			continue
+		}
+		if trimmed := strings.TrimSpace(*value); trimmed != "" {
+			updateFields[field] = trimmed
+		} else {
+			removeFields = append(removeFields, field)
+		}
+	}
+
+	update := UserUpdate{
+		Set:               updateFields,
+		Remove:            removeFields,
+		UpdatedAt:         now,
+		ExpectedUpdatedAt: expectedUpdatedAt,
+	}
+	matched, err := s.users.Update(ctx, objectID, update)
+	if err != nil {
+		if errors.Is(err, ErrUserExists) || errors.Is(err, ErrEmailExists) {
+			return nil, err
+		}
+		return nil, spanError(span, err)
+	}
+
+	// Drop the entry even when nothing matched: a version mismatch means it is stale
+	s.cache.remove(objectID.Hex())
+
+	if !matched {
+		if expectedUpdatedAt == nil {
+			return nil, ErrUserNotFound
+		}
+		// Tell a stale version apart from a missing user
+		if _, err := s.GetUserByID(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrVersionMismatch
+	}
+
+	// As with ResetPassword, a new password logs out every session, including any
+	// opened by whoever knew the old one
+	if req.Password != nil {
+		if err := s.revokeRefreshTokens(ctx, objectID); err != nil {
+			return nil, spanError(span, err)
+		}
+	}
+
+	s.recordAudit(ctx, objectID, models.AuditActionUpdate, auditFields(update))
+
+	return s.GetUserByID(ctx, id)
+}
+
+// ReplaceUser overwrites every mutable field of the user, with the same version
+// check as UpdateUser
+func (s *UserService) ReplaceUser(ctx context.Context, id string, req *models.ReplaceUserRequest, expectedUpdatedAt *time.Time) (*models.User, error) {
+	return s.UpdateUser(ctx, id, &models.UpdateUserRequest{
+		UserID:    &req.UserID,
+		Email:     &req.Email,
+		Password:  &req.Password,
+		FirstName: &req.FirstName,
+		LastName:  &req.LastName,
+		AvatarURL: &req.AvatarURL,
+	}, expectedUpdatedAt)
+}
+
+// DeleteUser soft-deletes the user and returns it as it was just before, so the
+// caller can record who was deleted
+func (s *UserService) DeleteUser(ctx context.Context, id string) (*models.User, error) {
+	ctx, span := startSpan(ctx, "DeleteUser", "findAndModify")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidUserID, err)
+	}
+
+	user, err := s.users.Delete(ctx, objectID, s.now())
+
+	s.cache.remove(objectID.Hex())
+
+	if err != nil {
+		return nil, spanError(span, err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	s.recordAudit(ctx, objectID, models.AuditActionDelete, nil)
+
+	return user, nil
+}
+
+// DeleteAllUsers permanently removes every user, including soft-deleted ones, along
+// with their outstanding password reset and refresh tokens. It returns the number of users removed.
+func (s *UserService) DeleteAllUsers(ctx context.Context) (int64, error) {
+	ctx, span := startSpan(ctx, "DeleteAllUsers", "deleteMany")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	if err := s.requireCollections(); err != nil {
+		return 0, err
+	}
+
+	result, err := s.collection.DeleteMany(ctx, bson.M{})
+	if err != nil {
+		return 0, spanError(span, fmt.Errorf("failed to delete users: %w", err))
+	}
+
+	s.cache.purge()
+
+	if _, err := s.resetTokens.DeleteMany(ctx, bson.M{}); err != nil {
+		return result.DeletedCount, spanError(span, fmt.Errorf("failed to delete password reset tokens: %w", err))
+	}
+
+	if _, err := s.refreshTokens.DeleteMany(ctx, bson.M{}); err != nil {
+		return result.DeletedCount, spanError(span, fmt.Errorf("failed to delete refresh tokens: %w", err))
+	}
+
+	return result.DeletedCount, nil
+}
+
+func (s *UserService) RestoreUser(ctx context.Context, id string) (*models.User, error) {
+	ctx, span := startSpan(ctx, "RestoreUser", "updateOne")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidUserID, err)
+	}
+
+	if err := s.requireCollections(); err != nil {
+		return nil, err
+	}
+
+	result, err := s.collection.UpdateOne(
 		ctx,
-		bson.M{"_id": objectID},
-		bson.M{"$set": updateFields},
+		bson.M{"_id": objectID, "deleted_at": bson.M{"$exists": true}},
+		updatePipeline(s.now(), nil, "deleted_at"),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update user: %w", err)
+		return nil, spanError(span, fmt.Errorf("failed to restore user: %w", err))
+	}
+
+	s.cache.remove(objectID.Hex())
+
+	if result.MatchedCount == 0 {
+		return nil, ErrUserNotFound
 	}
 
+	s.recordAudit(ctx, objectID, models.AuditActionUpdate, []string{"deleted_at"})
+
 	return s.GetUserByID(ctx, id)
 }
 
-func (s *UserService) DeleteUser(ctx context.Context, id string) error {
+// SetRoles replaces the roles granted to a user
+func (s *UserService) SetRoles(ctx context.Context, id string, roles []string) (*models.User, error) {
+	ctx, span := startSpan(ctx, "SetRoles", "updateOne")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
 	objectID, err := bson.ObjectIDFromHex(id)
 	if err != nil {
-		return fmt.Errorf("invalid user ID: %w", err)
+		return nil, fmt.Errorf("%w: %v", ErrInvalidUserID, err)
 	}
 
-	result, err := s.collection.DeleteOne(ctx, bson.M{"_id": objectID})
+	if err := models.ValidateRoles(roles); err != nil {
+		return nil, err
+	}
+
+	if err := s.requireCollections(); err != nil {
+		return nil, err
+	}
+
+	result, err := s.collection.UpdateOne(
+		ctx,
+		notDeleted(bson.M{"_id": objectID}),
+		updatePipeline(s.now(), bson.M{"roles": roles}),
+	)
 	if err != nil {
-		return fmt.Errorf("failed to delete user: %w", err)
+		return nil, spanError(span, fmt.Errorf("failed to set roles: %w", err))
 	}
 
-	if result.DeletedCount == 0 {
-		return errors.New("user not found")
+	s.cache.remove(objectID.Hex())
+
+	if result.MatchedCount == 0 {
+		return nil, ErrUserNotFound
 	}
 
-	return nil
+	s.recordAudit(ctx, objectID, models.AuditActionUpdate, []string{"roles"})
+
+	return s.GetUserByID(ctx, id)
+}
+
+// emailDomainFilter returns the $or clauses matching users in domain, ignoring
+// case. The stored email_domain is compared with equality so that its index serves
+// the query; the regex over email only applies to users stored before the field
+// existed, until BackfillEmailDomains has run.
+func emailDomainFilter(domain string) bson.A {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	return bson.A{
+		bson.M{"email_domain": domain},
+		bson.M{
+			"email_domain": bson.M{"$exists": false},
+			"email": bson.M{
+				"$regex":   "@" + regexp.QuoteMeta(domain) + "$",
+				"$options": "i",
+			},
+		},
+	}
 }
 
-func (s *UserService) ListUsers(ctx context.Context) ([]*models.User, error) {
-	cursor, err := s.collection.Find(ctx, bson.M{})
+func (s *UserService) CountUsers(ctx context.Context, emailDomain string) (int64, error) {
+	ctx, span := startSpan(ctx, "CountUsers", "countDocuments")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	filter := notDeleted(bson.M{})
+	if emailDomain != "" {
+		filter["$or"] = emailDomainFilter(emailDomain)
+	}
+
+	if err := s.requireCollections(); err != nil {
+		return 0, err
+	}
+
+	count, err := s.listCollection.CountDocuments(ctx, filter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get users: %w", err)
+		return 0, spanError(span, fmt.Errorf("failed to count users: %w", err))
 	}
-	defer cursor.Close(ctx)
 
-	var users []*models.User
-	for cursor.Next(ctx) {
-		var user models.User
-		if err := cursor.Decode(&user); err != nil {
-			return nil, fmt.Errorf("failed to decode user: %w", err)
-		}
-		users = append(users, &user)
+	return count, nil
+}
+
+// listUsersFilter builds the find filter for ListUsers
+func listUsersFilter(opts models.ListUsersOptions) bson.M {
+	filter := bson.M{}
+	if !opts.IncludeDeleted {
+		filter = notDeleted(filter)
+	}
+
+	createdAt := bson.M{}
+	if opts.CreatedAfter != nil {
+		createdAt["$gte"] = *opts.CreatedAfter
+	}
+	if opts.CreatedBefore != nil {
+		createdAt["$lte"] = *opts.CreatedBefore
+	}
+	if len(createdAt) > 0 {
+		filter["created_at"] = createdAt
 	}
 
+	if len(opts.UserIDs) > 0 {
+		filter["user_id"] = bson.M{"$in": opts.UserIDs}
+	}
+
+	if opts.EmailDomain != "" {
+		filter["$or"] = emailDomainFilter(opts.EmailDomain)
+	}
+
+	// Users stored before status existed have none and count as active
+	switch opts.Status {
+	case models.StatusActive:
+		filter["status"] = bson.M{"$ne": models.StatusDisabled}
+	case models.StatusDisabled:
+		filter["status"] = models.StatusDisabled
+	}
+
+	return filter
+}
+
+func (s *UserService) ListUsers(ctx context.Context, opts models.ListUsersOptions) ([]*models.User, error) {
+	ctx, span := startSpan(ctx, "ListUsers", "find")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	users, err := s.users.List(ctx, opts)
+	if err != nil {
+		return nil, spanError(span, err)
+	}
 	return users, nil
 }
+
+// CountListedUsers returns how many users match opts, ignoring its Offset and
+// Limit, for paging through ListUsers
+func (s *UserService) CountListedUsers(ctx context.Context, opts models.ListUsersOptions) (int64, error) {
+	ctx, span := startSpan(ctx, "CountListedUsers", "countDocuments")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	count, err := s.users.Count(ctx, opts)
+	if err != nil {
+		return 0, spanError(span, err)
+	}
+	return count, nil
+}
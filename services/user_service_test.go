@@ -1,16 +1,22 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"os"
+	"reflect"
 	"testing"
 	"time"
 
 	"go-mongodb-test/models"
 
-	"go.mongodb.org/mongo-driver/v2/bson"
+	v1bson "go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
 // MockDatabase implements DatabaseCollectionProvider for testing
@@ -25,7 +31,7 @@ func (m *MockDatabase) Collection(name string, opts ...*options.CollectionOption
 func TestNewUserService(t *testing.T) {
 	db := &MockDatabase{}
 	service := NewUserService(db)
-	
+
 	if service == nil {
 		t.Error("Expected service to be non-nil")
 	}
@@ -40,10 +46,10 @@ func TestUserServiceValidation(t *testing.T) {
 	t.Run("GetUserByID with invalid ObjectID", func(t *testing.T) {
 		// Test the ObjectID validation logic that happens before DB operations
 		invalidIDs := []string{
-			"",                           // Empty
-			"123",                        // Too short  
-			"123456789012345678901234z",  // Invalid character
-			"invalid-id",                 // Invalid format
+			"",                          // Empty
+			"123",                       // Too short
+			"123456789012345678901234z", // Invalid character
+			"invalid-id",                // Invalid format
 		}
 
 		for _, id := range invalidIDs {
@@ -51,7 +57,7 @@ func TestUserServiceValidation(t *testing.T) {
 			if err == nil {
 				t.Errorf("Expected error for invalid ObjectID: %s", id)
 			}
-			
+
 			// Check that it contains "invalid user ID" message
 			if err != nil && !contains(err.Error(), "invalid user ID") {
 				t.Errorf("Expected 'invalid user ID' error for %s, got: %v", id, err)
@@ -61,20 +67,20 @@ func TestUserServiceValidation(t *testing.T) {
 
 	t.Run("UpdateUser with invalid ObjectID", func(t *testing.T) {
 		req := &models.UpdateUserRequest{}
-		
+
 		invalidIDs := []string{
-			"",                           
-			"123",                        
-			"123456789012345678901234z",  
-			"invalid-id",                 
+			"",
+			"123",
+			"123456789012345678901234z",
+			"invalid-id",
 		}
 
 		for _, id := range invalidIDs {
-			_, err := service.UpdateUser(ctx, id, req)
+			_, err := service.UpdateUser(ctx, id, req, nil)
 			if err == nil {
 				t.Errorf("Expected error for invalid ObjectID: %s", id)
 			}
-			
+
 			if err != nil && !contains(err.Error(), "invalid user ID") {
 				t.Errorf("Expected 'invalid user ID' error for %s, got: %v", id, err)
 			}
@@ -83,18 +89,38 @@ func TestUserServiceValidation(t *testing.T) {
 
 	t.Run("DeleteUser with invalid ObjectID", func(t *testing.T) {
 		invalidIDs := []string{
-			"",                           
-			"123",                        
-			"123456789012345678901234z",  
-			"invalid-id",                 
+			"",
+			"123",
+			"123456789012345678901234z",
+			"invalid-id",
+		}
+
+		for _, id := range invalidIDs {
+			_, err := service.DeleteUser(ctx, id)
+			if err == nil {
+				t.Errorf("Expected error for invalid ObjectID: %s", id)
+			}
+
+			if err != nil && !contains(err.Error(), "invalid user ID") {
+				t.Errorf("Expected 'invalid user ID' error for %s, got: %v", id, err)
+			}
+		}
+	})
+
+	t.Run("RestoreUser with invalid ObjectID", func(t *testing.T) {
+		invalidIDs := []string{
+			"",
+			"123",
+			"123456789012345678901234z",
+			"invalid-id",
 		}
 
 		for _, id := range invalidIDs {
-			err := service.DeleteUser(ctx, id)
+			_, err := service.RestoreUser(ctx, id)
 			if err == nil {
 				t.Errorf("Expected error for invalid ObjectID: %s", id)
 			}
-			
+
 			if err != nil && !contains(err.Error(), "invalid user ID") {
 				t.Errorf("Expected 'invalid user ID' error for %s, got: %v", id, err)
 			}
@@ -209,6 +235,236 @@ func TestUserServiceValidation(t *testing.T) {
 	})
 }
 
+// TestSentinelErrors tests that invalid IDs surface the typed error
+func TestSentinelErrors(t *testing.T) {
+	ctx := context.Background()
+	service := NewUserService(&MockDatabase{})
+
+	_, err := service.GetUserByID(ctx, "invalid-id")
+	if !errors.Is(err, ErrInvalidUserID) {
+		t.Errorf("Expected ErrInvalidUserID from GetUserByID, got %v", err)
+	}
+
+	_, err = service.UpdateUser(ctx, "invalid-id", &models.UpdateUserRequest{}, nil)
+	if !errors.Is(err, ErrInvalidUserID) {
+		t.Errorf("Expected ErrInvalidUserID from UpdateUser, got %v", err)
+	}
+
+	_, err = service.DeleteUser(ctx, "invalid-id")
+	if !errors.Is(err, ErrInvalidUserID) {
+		t.Errorf("Expected ErrInvalidUserID from DeleteUser, got %v", err)
+	}
+
+	_, err = service.RestoreUser(ctx, "invalid-id")
+	if !errors.Is(err, ErrInvalidUserID) {
+		t.Errorf("Expected ErrInvalidUserID from RestoreUser, got %v", err)
+	}
+}
+
+// TestCreateUser_InvalidEmail tests that malformed emails are rejected before any DB call
+func TestCreateUser_InvalidEmail(t *testing.T) {
+	service := NewUserService(&MockDatabase{})
+
+	_, err := service.CreateUser(context.Background(), &models.CreateUserRequest{
+		UserID:   "testuser",
+		Email:    "Test User <test@example.com>",
+		Password: "password123",
+	})
+	if !errors.Is(err, models.ErrInvalidEmail) {
+		t.Errorf("Expected ErrInvalidEmail, got %v", err)
+	}
+}
+
+// TestNotDeleted tests the soft-delete filter helper
+func TestNotDeleted(t *testing.T) {
+	filter := notDeleted(bson.M{"user_id": "testuser"})
+
+	if filter["user_id"] != "testuser" {
+		t.Errorf("Expected existing filter keys to be kept, got %v", filter)
+	}
+
+	deletedAt, ok := filter["deleted_at"].(bson.M)
+	if !ok {
+		t.Fatalf("Expected deleted_at condition, got %v", filter["deleted_at"])
+	}
+
+	if deletedAt["$exists"] != false {
+		t.Errorf("Expected deleted_at to require $exists false, got %v", deletedAt)
+	}
+}
+
+func TestListUsersFilter(t *testing.T) {
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	filter := listUsersFilter(models.ListUsersOptions{})
+	if _, ok := filter["created_at"]; ok {
+		t.Errorf("Expected no created_at condition without a range, got %v", filter)
+	}
+	if _, ok := filter["deleted_at"]; !ok {
+		t.Errorf("Expected deleted users to be excluded, got %v", filter)
+	}
+
+	filter = listUsersFilter(models.ListUsersOptions{CreatedAfter: &after, CreatedBefore: &before, IncludeDeleted: true})
+	if _, ok := filter["deleted_at"]; ok {
+		t.Errorf("Expected deleted users to be included, got %v", filter)
+	}
+	createdAt, ok := filter["created_at"].(bson.M)
+	if !ok {
+		t.Fatalf("Expected created_at condition, got %v", filter["created_at"])
+	}
+	if createdAt["$gte"] != after || createdAt["$lte"] != before {
+		t.Errorf("Expected created_at between %v and %v, got %v", after, before, createdAt)
+	}
+
+	filter = listUsersFilter(models.ListUsersOptions{CreatedBefore: &before})
+	if createdAt := filter["created_at"].(bson.M); len(createdAt) != 1 || createdAt["$lte"] != before {
+		t.Errorf("Expected only an upper bound, got %v", createdAt)
+	}
+	if _, ok := filter["user_id"]; ok {
+		t.Errorf("Expected no user_id condition without ids, got %v", filter)
+	}
+
+	filter = listUsersFilter(models.ListUsersOptions{UserIDs: []string{"alice", "bob"}})
+	userIDs, ok := filter["user_id"].(bson.M)
+	if !ok || !reflect.DeepEqual(userIDs["$in"], []string{"alice", "bob"}) {
+		t.Errorf("Expected user_id $in [alice bob], got %v", filter["user_id"])
+	}
+	if _, ok := filter["status"]; ok {
+		t.Errorf("Expected no status condition without a status, got %v", filter)
+	}
+
+	filter = listUsersFilter(models.ListUsersOptions{Status: models.StatusActive})
+	if status, ok := filter["status"].(bson.M); !ok || status["$ne"] != models.StatusDisabled {
+		t.Errorf("Expected active to match users without a status, got %v", filter["status"])
+	}
+
+	filter = listUsersFilter(models.ListUsersOptions{Status: models.StatusDisabled})
+	if filter["status"] != models.StatusDisabled {
+		t.Errorf("Expected status disabled, got %v", filter["status"])
+	}
+	if _, ok := filter["$or"]; ok {
+		t.Errorf("Expected no email domain condition without a domain, got %v", filter)
+	}
+
+	filter = listUsersFilter(models.ListUsersOptions{EmailDomain: "Example.com"})
+	if clauses, ok := filter["$or"].(bson.A); !ok || len(clauses) != 2 || clauses[0].(bson.M)["email_domain"] != "example.com" {
+		t.Errorf("Expected an equality match on email_domain, got %v", filter["$or"])
+	}
+}
+
+// TestDuplicateKeyError tests translation of duplicate-key write errors
+func TestDuplicateKeyError(t *testing.T) {
+	keyPattern := func(field string) v1bson.Raw {
+		raw, err := v1bson.Marshal(v1bson.D{{Key: "keyPattern", Value: v1bson.D{{Key: field, Value: 1}}}})
+		if err != nil {
+			t.Fatalf("Failed to marshal keyPattern: %v", err)
+		}
+		return raw
+	}
+
+	testCases := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{
+			name: "user_id index",
+			err: mongo.WriteException{WriteErrors: []mongo.WriteError{{
+				Code:    11000,
+				Message: `E11000 duplicate key error collection: user_management.users index: user_id_1 dup key: { user_id: "testuser" }`,
+			}}},
+			expected: ErrUserExists,
+		},
+		{
+			name: "email index",
+			err: mongo.WriteException{WriteErrors: []mongo.WriteError{{
+				Code:    11000,
+				Message: `E11000 duplicate key error collection: user_management.users index: email_1 dup key: { email: "test@example.com" }`,
+			}}},
+			expected: ErrEmailExists,
+		},
+		{
+			name: "email value mentioning user_id",
+			err: mongo.WriteException{WriteErrors: []mongo.WriteError{{
+				Code:    11000,
+				Message: `E11000 duplicate key error collection: user_management.users index: email_1 dup key: { email: "user_id@example.com" }`,
+			}}},
+			expected: ErrEmailExists,
+		},
+		{
+			name: "keyPattern wins over a renamed index",
+			err: mongo.WriteException{WriteErrors: []mongo.WriteError{{
+				Code:    11000,
+				Message: `E11000 duplicate key error collection: user_management.users index: unique_login dup key: { user_id: "testuser" }`,
+				Raw:     keyPattern("user_id"),
+			}}},
+			expected: ErrUserExists,
+		},
+		{
+			name: "Command error",
+			err: mongo.CommandError{
+				Code:    11000,
+				Message: `E11000 duplicate key error collection: user_management.users index: email_1 dup key: { email: "test@example.com" }`,
+			},
+			expected: ErrEmailExists,
+		},
+		{
+			name: "Other unique index",
+			err: mongo.WriteException{WriteErrors: []mongo.WriteError{{
+				Code:    11000,
+				Message: `E11000 duplicate key error collection: user_management.users index: _id_ dup key: { _id: 1 }`,
+			}}},
+			expected: nil,
+		},
+		{
+			name:     "Not a duplicate key",
+			err:      mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 121, Message: "Document failed validation"}}},
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := duplicateKeyError(tc.err)
+			if tc.expected == nil {
+				if err != nil {
+					t.Errorf("Expected nil, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+// TestEmailDomainFilter tests that the stored domain is matched with equality and
+// that the anchored, escaped regex only applies to users without one
+func TestEmailDomainFilter(t *testing.T) {
+	clauses := emailDomainFilter(" Example.com ")
+	if len(clauses) != 2 {
+		t.Fatalf("Expected 2 clauses, got %v", clauses)
+	}
+
+	if stored := clauses[0].(bson.M); len(stored) != 1 || stored["email_domain"] != "example.com" {
+		t.Errorf("Expected an equality match on the lowercased domain, got %v", stored)
+	}
+
+	fallback := clauses[1].(bson.M)
+	if exists, ok := fallback["email_domain"].(bson.M); !ok || exists["$exists"] != false {
+		t.Errorf("Expected the fallback to be limited to users without email_domain, got %v", fallback)
+	}
+	email, ok := fallback["email"].(bson.M)
+	if !ok || email["$regex"] != `@example\.com$` {
+		t.Errorf("Expected escaped anchored regex, got %v", fallback["email"])
+	}
+	if ok && email["$options"] != "i" {
+		t.Errorf("Expected case-insensitive option, got %v", email["$options"])
+	}
+}
+
 // Helper function to create string pointer
 func stringPtr(s string) *string {
 	return &s
@@ -226,7 +482,7 @@ func findInString(s, substr string) bool {
 	if len(s) < len(substr) {
 		return false
 	}
-	
+
 	for i := 0; i <= len(s)-len(substr); i++ {
 		match := true
 		for j := 0; j < len(substr); j++ {
@@ -369,10 +625,10 @@ func TestUserServiceBusinessLogic(t *testing.T) {
 		// Test password hashing with different passwords
 		passwords := []string{
 			"password123",
-			"",           // Empty password
-			"short",      // Short password
+			"",      // Empty password
+			"short", // Short password
 			"verylongpasswordwithmancharacters123456789", // Long password
-			"special!@#$%^&*()_+-=[]{}|;:,.<>?",           // Special characters
+			"special!@#$%^&*()_+-=[]{}|;:,.<>?",          // Special characters
 		}
 
 		for _, password := range passwords {
@@ -469,17 +725,17 @@ func TestBSONOperations(t *testing.T) {
 
 	t.Run("Invalid ObjectID parsing comprehensive", func(t *testing.T) {
 		invalidIDs := []string{
-			"",                                    // Empty
-			"123",                                 // Too short
-			"123456789012345678901234z",           // Invalid character z
-			"123456789012345678901234Z",           // Invalid character Z
-			"123456789012345678901234!",           // Invalid character !
-			"123456789012345678901234 ",           // Invalid character space
-			"gggggggggggggggggggggggg",            // Invalid hex characters
-			"GGGGGGGGGGGGGGGGGGGGGGGG",            // Invalid hex characters (uppercase)
-			"123456789012345678901234567890",      // Too long
-			"12345678901234567890123",             // One character short
-			"1234567890123456789012345",           // One character long
+			"",                               // Empty
+			"123",                            // Too short
+			"123456789012345678901234z",      // Invalid character z
+			"123456789012345678901234Z",      // Invalid character Z
+			"123456789012345678901234!",      // Invalid character !
+			"123456789012345678901234 ",      // Invalid character space
+			"gggggggggggggggggggggggg",       // Invalid hex characters
+			"GGGGGGGGGGGGGGGGGGGGGGGG",       // Invalid hex characters (uppercase)
+			"123456789012345678901234567890", // Too long
+			"12345678901234567890123",        // One character short
+			"1234567890123456789012345",      // One character long
 		}
 
 		for _, invalidID := range invalidIDs {
@@ -655,7 +911,7 @@ func TestContextOperations(t *testing.T) {
 
 	t.Run("Context with values", func(t *testing.T) {
 		type contextKey string
-		
+
 		testCases := []struct {
 			key   contextKey
 			value interface{}
@@ -788,4 +1044,787 @@ func TestTimeOperations(t *testing.T) {
 			t.Error("Expected new UpdatedAt to be after original UpdatedAt")
 		}
 	})
-}
\ No newline at end of file
+}
+
+// TestSetRoles_Validation tests that bad IDs and roles are rejected before any DB call
+func TestSetRoles_Validation(t *testing.T) {
+	ctx := context.Background()
+	service := NewUserService(&MockDatabase{})
+
+	_, err := service.SetRoles(ctx, "invalid-id", []string{models.RoleAdmin})
+	if !errors.Is(err, ErrInvalidUserID) {
+		t.Errorf("Expected ErrInvalidUserID, got %v", err)
+	}
+
+	_, err = service.SetRoles(ctx, bson.NewObjectID().Hex(), []string{"superuser"})
+	if !errors.Is(err, models.ErrInvalidRole) {
+		t.Errorf("Expected ErrInvalidRole, got %v", err)
+	}
+}
+
+// TestPasswordProjection checks that reads send the password exclusion to MongoDB,
+// except for the lookups used to verify credentials
+func TestPasswordProjection(t *testing.T) {
+	ctx := context.Background()
+	id := bson.NewObjectID()
+	stored := v1bson.D{
+		{Key: "_id", Value: id},
+		{Key: "user_id", Value: "alice"},
+		{Key: "email", Value: "alice@example.com"},
+	}
+	withPassword := append(v1bson.D{{Key: "password", Value: "$2a$10$hash"}}, stored...)
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	single := []struct {
+		name            string
+		lookup          func(s *UserService) (*models.User, error)
+		includePassword bool
+	}{
+		{"GetUserByID", func(s *UserService) (*models.User, error) { return s.GetUserByID(ctx, id.Hex()) }, false},
+		{"GetUserByUserID", func(s *UserService) (*models.User, error) { return s.GetUserByUserID(ctx, "alice") }, false},
+		{"GetUserByEmail", func(s *UserService) (*models.User, error) { return s.GetUserByEmail(ctx, "alice@example.com") }, false},
+		{"GetUserByUserIDWithPassword", func(s *UserService) (*models.User, error) { return s.GetUserByUserIDWithPassword(ctx, "alice") }, true},
+		{"GetUserByEmailWithPassword", func(s *UserService) (*models.User, error) {
+			return s.GetUserByEmailWithPassword(ctx, "alice@example.com")
+		}, true},
+		{"GetUserByIdentifier", func(s *UserService) (*models.User, error) { return s.GetUserByIdentifier(ctx, "alice") }, true},
+	}
+
+	for _, tc := range single {
+		mt.Run(tc.name, func(mt *mtest.T) {
+			// The mock server returns what MongoDB would after applying the projection
+			doc := stored
+			if tc.includePassword {
+				doc = withPassword
+			}
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, doc))
+
+			user, err := tc.lookup(NewUserService(mt.DB))
+			if err != nil || user == nil {
+				mt.Fatalf("Expected a user, got %v (error: %v)", user, err)
+			}
+
+			assertPasswordProjection(mt, tc.includePassword)
+			if tc.includePassword && user.Password == "" {
+				mt.Error("Expected password hash to be loaded for login lookups")
+			}
+			if !tc.includePassword && user.Password != "" {
+				mt.Errorf("Expected empty password, got '%s'", user.Password)
+			}
+		})
+	}
+
+	lists := []struct {
+		name   string
+		lookup func(s *UserService) ([]*models.User, error)
+	}{
+		{"ListUsers", func(s *UserService) ([]*models.User, error) { return s.ListUsers(ctx, models.ListUsersOptions{}) }},
+		{"SearchUsers", func(s *UserService) ([]*models.User, error) { return s.SearchUsers(ctx, "ali") }},
+	}
+
+	for _, tc := range lists {
+		mt.Run(tc.name, func(mt *mtest.T) {
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, stored))
+
+			users, err := tc.lookup(NewUserService(mt.DB))
+			if err != nil || len(users) != 1 {
+				mt.Fatalf("Expected one user, got %v (error: %v)", users, err)
+			}
+
+			assertPasswordProjection(mt, false)
+			if users[0].Password != "" {
+				mt.Errorf("Expected empty password, got '%s'", users[0].Password)
+			}
+		})
+	}
+}
+
+// assertPasswordProjection checks the projection of the last find command
+func assertPasswordProjection(mt *mtest.T, includePassword bool) {
+	mt.Helper()
+
+	started := mt.GetStartedEvent()
+	if started == nil || started.CommandName != "find" {
+		mt.Fatalf("Expected a find command, got %v", started)
+	}
+
+	projection, err := started.Command.LookupErr("projection")
+	if includePassword {
+		if err == nil {
+			mt.Errorf("Expected no projection, got %v", projection)
+		}
+		return
+	}
+
+	if err != nil {
+		mt.Fatalf("Expected a projection, got none")
+	}
+	if value, ok := projection.Document().Lookup("password").AsInt32OK(); !ok || value != 0 {
+		mt.Errorf("Expected password to be excluded, got %v", projection)
+	}
+}
+
+func TestEnsureIndexes(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("creates every index", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)
+
+		if err := NewUserService(mt.DB).EnsureIndexes(context.Background()); err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+
+		started := mt.GetStartedEvent()
+		if started == nil || started.CommandName != "createIndexes" {
+			mt.Fatalf("Expected a createIndexes command, got %v", started)
+		}
+
+		values, err := started.Command.Lookup("indexes").Array().Values()
+		if err != nil {
+			mt.Fatalf("Failed to read indexes: %v", err)
+		}
+		if len(values) != 4 {
+			mt.Errorf("Expected 4 indexes, got %d", len(values))
+		}
+
+		ttlIndexes := []struct {
+			collection  string
+			field       string
+			expireAfter int64
+		}{
+			{passwordResetCollection, "expires_at", 0},
+			{refreshTokenCollection, "expires_at", 0},
+			{idempotencyCollection, "created_at", int64(IdempotencyKeyTTL / time.Second)},
+		}
+		for _, expected := range ttlIndexes {
+			started := mt.GetStartedEvent()
+			if started == nil || started.Command.Lookup("createIndexes").StringValue() != expected.collection {
+				mt.Fatalf("Expected a createIndexes command on %s, got %v", expected.collection, started)
+			}
+			index := started.Command.Lookup("indexes").Array().Index(0).Value().Document()
+			if _, err := index.LookupErr("key", expected.field); err != nil {
+				mt.Errorf("Expected the %s TTL index on %s, got %v", expected.collection, expected.field, index)
+			}
+			if expire := index.Lookup("expireAfterSeconds").AsInt64(); expire != expected.expireAfter {
+				mt.Errorf("Expected %s to expire after %ds, got %ds", expected.collection, expected.expireAfter, expire)
+			}
+		}
+
+		audit := mt.GetStartedEvent()
+		if audit == nil || audit.Command.Lookup("createIndexes").StringValue() != auditCollection {
+			mt.Fatalf("Expected a createIndexes command on %s, got %v", auditCollection, audit)
+		}
+		if _, err := audit.Command.Lookup("indexes").Array().Index(0).Value().Document().LookupErr("key", "target_id"); err != nil {
+			mt.Errorf("Expected audit events to be indexed by target_id, got %v", audit.Command)
+		}
+
+		sessions := mt.GetStartedEvent()
+		if sessions == nil || sessions.Command.Lookup("createIndexes").StringValue() != refreshTokenCollection {
+			mt.Fatalf("Expected a createIndexes command on %s, got %v", refreshTokenCollection, sessions)
+		}
+		if _, err := sessions.Command.Lookup("indexes").Array().Index(0).Value().Document().LookupErr("key", "user_id"); err != nil {
+			mt.Errorf("Expected refresh tokens to be indexed by user_id, got %v", sessions.Command)
+		}
+	})
+}
+
+func TestWithOperationTimeout(t *testing.T) {
+	t.Run("bounds each call", func(t *testing.T) {
+		service := NewUserService(&MockDatabase{}, WithOperationTimeout(time.Second))
+
+		ctx, cancel := service.withOperationTimeout(context.Background())
+		defer cancel()
+
+		deadline, ok := ctx.Deadline()
+		if !ok || time.Until(deadline) > time.Second {
+			t.Errorf("Expected a deadline within 1s, got %v (set: %v)", deadline, ok)
+		}
+	})
+
+	t.Run("keeps an earlier caller deadline", func(t *testing.T) {
+		service := NewUserService(&MockDatabase{}, WithOperationTimeout(time.Hour))
+		parent, cancelParent := context.WithTimeout(context.Background(), time.Second)
+		defer cancelParent()
+
+		ctx, cancel := service.withOperationTimeout(parent)
+		defer cancel()
+
+		if deadline, _ := ctx.Deadline(); time.Until(deadline) > time.Second {
+			t.Errorf("Expected the caller's deadline to win, got %v", deadline)
+		}
+	})
+
+	t.Run("off by default", func(t *testing.T) {
+		ctx, cancel := NewUserService(&MockDatabase{}).withOperationTimeout(context.Background())
+		defer cancel()
+
+		if _, ok := ctx.Deadline(); ok {
+			t.Error("Expected no deadline")
+		}
+	})
+}
+
+func TestWithListReadPreference(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("routes list reads only", func(mt *mtest.T) {
+		service := NewUserService(mt.DB, WithListReadPreference(readpref.Nearest()))
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch),
+		)
+
+		if _, err := service.ListUsers(context.Background(), models.ListUsersOptions{}); err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		listed := mt.GetStartedEvent()
+		if mode := listed.Command.Lookup("$readPreference", "mode").StringValue(); mode != "nearest" {
+			mt.Errorf("Expected the list read to go to the nearest member, got %v", listed.Command)
+		}
+
+		if _, err := service.GetUserByUserID(context.Background(), "alice"); err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		found := mt.GetStartedEvent()
+		if mode, _ := found.Command.Lookup("$readPreference", "mode").StringValueOK(); mode == "nearest" {
+			mt.Errorf("Expected lookups to keep the client read preference, got %v", found.Command)
+		}
+	})
+}
+
+func TestCountUsers_EmailDomain(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("matches the stored domain", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, v1bson.D{{Key: "n", Value: 3}}))
+
+		count, err := NewUserService(mt.DB).CountUsers(context.Background(), "Example.com")
+		if err != nil || count != 3 {
+			mt.Fatalf("Expected 3 users, got %d (error: %v)", count, err)
+		}
+		stages, _ := mt.GetStartedEvent().Command.Lookup("pipeline").Array().Values()
+		if len(stages) == 0 {
+			mt.Fatal("Expected a count pipeline")
+		}
+		if domain, ok := stages[0].Document().Lookup("$match", "$or", "0", "email_domain").StringValueOK(); !ok || domain != "example.com" {
+			mt.Errorf("Expected an equality match on email_domain, got %v", stages[0])
+		}
+	})
+}
+
+func TestListUsers_Page(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("skips, limits and counts on the server", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, v1bson.D{{Key: "n", Value: 120}}),
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch),
+		)
+		service := NewUserService(mt.DB)
+		opts := models.ListUsersOptions{EmailDomain: "example.com", Offset: 50, Limit: 25}
+
+		count, err := service.CountListedUsers(context.Background(), opts)
+		if err != nil || count != 120 {
+			mt.Fatalf("Expected 120 users, got %d (error: %v)", count, err)
+		}
+		// CountDocuments runs as $match then $group; a skip would add a stage between them
+		counted := mt.GetStartedEvent()
+		stages, _ := counted.Command.Lookup("pipeline").Array().Values()
+		if len(stages) != 2 {
+			mt.Errorf("Expected the count to ignore the offset and limit, got %v", counted.Command)
+		} else if _, err := stages[0].Document().LookupErr("$match", "$or"); err != nil {
+			mt.Errorf("Expected the count to apply the list filter, got %v", counted.Command)
+		}
+
+		if _, err := service.ListUsers(context.Background(), opts); err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		found := mt.GetStartedEvent()
+		if skip, limit := found.Command.Lookup("skip").AsInt64(), found.Command.Lookup("limit").AsInt64(); skip != 50 || limit != 25 {
+			mt.Errorf("Expected skip 50 and limit 25, got %d and %d", skip, limit)
+		}
+		// Users created at the same instant must keep one order from page to page
+		sort, err := found.Command.Lookup("sort").Document().Elements()
+		if err != nil || len(sort) != 2 || sort[0].Key() != "created_at" || sort[1].Key() != "_id" {
+			mt.Fatalf("Expected a sort on created_at then _id, got %v", found.Command.Lookup("sort"))
+		}
+		for _, key := range sort {
+			if direction := key.Value().AsInt64(); direction != -1 {
+				mt.Errorf("Expected %s to sort newest first, got %d", key.Key(), direction)
+			}
+		}
+	})
+}
+
+func TestListUsers_CursorError(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("reports a failed getMore", func(mt *mtest.T) {
+		// A live cursor id makes Next ask for another batch, and with no response
+		// queued that getMore fails the way a cancelled or timed-out one would
+		mt.AddMockResponses(mtest.CreateCursorResponse(1, "test.users", mtest.FirstBatch))
+
+		if _, err := NewUserService(mt.DB).ListUsers(context.Background(), models.ListUsersOptions{}); err == nil {
+			mt.Error("Expected the cursor error to be returned")
+		}
+	})
+}
+
+func TestUpdateUser_DuplicateKeyRace(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("reports the taken email", func(mt *mtest.T) {
+		// The pre-check finds no other user, but the unique index rejects the write
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch),
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{
+				Code:    11000,
+				Message: `E11000 duplicate key error collection: test.users index: email_1 dup key: { email: "taken@example.com" }`,
+			}),
+		)
+
+		email := "taken@example.com"
+		_, err := NewUserService(mt.DB).UpdateUser(context.Background(), bson.NewObjectID().Hex(), &models.UpdateUserRequest{Email: &email}, nil)
+		if !errors.Is(err, ErrEmailExists) {
+			mt.Errorf("Expected ErrEmailExists, got %v", err)
+		}
+	})
+}
+
+func TestDeleteUser_ReturnsDeletedUser(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	id := bson.NewObjectID()
+
+	mt.Run("returns the user", func(mt *mtest.T) {
+		doc := v1bson.D{{Key: "_id", Value: id}, {Key: "user_id", Value: "alice"}, {Key: "email", Value: "alice@example.com"}}
+		mt.AddMockResponses(mtest.CreateSuccessResponse(v1bson.E{Key: "value", Value: doc}))
+
+		user, err := NewUserService(mt.DB).DeleteUser(context.Background(), id.Hex())
+		if err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		if user.UserID != "alice" || user.ID != id {
+			mt.Errorf("Expected the deleted user, got %+v", user)
+		}
+
+		started := mt.GetStartedEvent()
+		if started == nil || started.CommandName != "findAndModify" {
+			mt.Fatalf("Expected a findAndModify command, got %v", started)
+		}
+		if _, err := pipelineSet(mt, started.Command.Lookup("update")).LookupErr("deleted_at", "$literal"); err != nil {
+			mt.Errorf("Expected a soft delete, got %v", started.Command)
+		}
+		if _, err := started.Command.LookupErr("fields", "password"); err != nil {
+			mt.Errorf("Expected the password to be projected out, got %v", started.Command)
+		}
+	})
+
+	mt.Run("missing user", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(v1bson.E{Key: "value", Value: nil}))
+
+		if _, err := NewUserService(mt.DB).DeleteUser(context.Background(), id.Hex()); !errors.Is(err, ErrUserNotFound) {
+			mt.Errorf("Expected ErrUserNotFound, got %v", err)
+		}
+	})
+}
+
+func TestUpdateUser_MonotonicUpdatedAt(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("computes updated_at from the stored value", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 1}, v1bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 1}),
+			auditRecorded,
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, v1bson.D{{Key: "_id", Value: bson.NewObjectID()}}),
+		)
+
+		password := "newpassword"
+		_, err := NewUserService(mt.DB).UpdateUser(context.Background(), bson.NewObjectID().Hex(), &models.UpdateUserRequest{Password: &password}, nil)
+		if err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+
+		update := mt.GetStartedEvent()
+		stages, err := update.Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u").Array().Values()
+		if err != nil || len(stages) != 1 {
+			mt.Fatalf("Expected a single-stage update pipeline, got %v", update.Command)
+		}
+		set := stages[0].Document().Lookup("$set").Document()
+		if _, err := set.LookupErr("updated_at", "$cond"); err != nil {
+			mt.Errorf("Expected updated_at to be derived from the stored value, got %v", set)
+		}
+		// A bcrypt hash starts with "$", which a pipeline would otherwise read as a field path
+		if hash, ok := set.Lookup("password", "$literal").StringValueOK(); !ok || hash == password {
+			mt.Errorf("Expected the password hash as a literal, got %v", set)
+		}
+	})
+}
+
+// pipelineSet returns the $set stage of an update built by updatePipeline, failing
+// unless updated_at is derived from the stored value
+func pipelineSet(mt *mtest.T, update v1bson.RawValue) v1bson.Raw {
+	mt.Helper()
+	array, ok := update.ArrayOK()
+	if !ok {
+		mt.Fatalf("Expected an update pipeline, got %v", update)
+	}
+	stages, err := array.Values()
+	if err != nil || len(stages) != 1 {
+		mt.Fatalf("Expected a single-stage update pipeline, got %v", update)
+	}
+	set := stages[0].Document().Lookup("$set").Document()
+	if _, err := set.LookupErr("updated_at", "$cond"); err != nil {
+		mt.Fatalf("Expected updated_at to be derived from the stored value, got %v", set)
+	}
+	return set
+}
+
+func TestUpdateUser_RevokesRefreshTokens(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	id := bson.NewObjectID()
+	password, firstName := "n3wpassword", "Alice"
+
+	for _, tc := range []struct {
+		name    string
+		req     *models.UpdateUserRequest
+		revoked bool
+	}{
+		{"password change", &models.UpdateUserRequest{Password: &password}, true},
+		{"profile change", &models.UpdateUserRequest{FirstName: &firstName}, false},
+	} {
+		mt.Run(tc.name, func(mt *mtest.T) {
+			responses := []v1bson.D{mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 1}, v1bson.E{Key: "nModified", Value: 1})}
+			if tc.revoked {
+				responses = append(responses, mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 2}))
+			}
+			responses = append(responses, auditRecorded, mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, v1bson.D{{Key: "_id", Value: id}}))
+			mt.AddMockResponses(responses...)
+
+			if _, err := NewUserService(mt.DB).UpdateUser(context.Background(), id.Hex(), tc.req, nil); err != nil {
+				mt.Fatalf("Expected no error, got %v", err)
+			}
+
+			mt.GetStartedEvent() // update
+			next := mt.GetStartedEvent()
+			revoked := next != nil && next.CommandName == "delete" && next.Command.Lookup("delete").StringValue() == refreshTokenCollection
+			if revoked != tc.revoked {
+				mt.Errorf("Expected refresh tokens revoked: %v, got command %v", tc.revoked, next)
+			}
+			if revoked && !bytes.HasSuffix(next.Command.Lookup("deletes").Array().Index(0).Value().Document().Lookup("q", "user_id").Value, id[:]) {
+				mt.Errorf("Expected only the user's tokens to be revoked, got %v", next.Command)
+			}
+		})
+	}
+}
+
+func TestNextUpdatedAt(t *testing.T) {
+	now := time.Date(2024, 6, 4, 12, 0, 0, 0, time.UTC)
+	cond, ok := nextUpdatedAt(now)["$cond"].(bson.A)
+	if !ok || len(cond) != 3 {
+		t.Fatalf("Expected a $cond with three operands, got %v", cond)
+	}
+
+	// Only a stored value before now is replaced by now; otherwise it moves forward by 1ms
+	if !reflect.DeepEqual(cond[0], bson.M{"$gt": bson.A{now, "$updated_at"}}) {
+		t.Errorf("Unexpected condition %v", cond[0])
+	}
+	if cond[1] != now {
+		t.Errorf("Expected now when the stored value is older, got %v", cond[1])
+	}
+	if !reflect.DeepEqual(cond[2], bson.M{"$add": bson.A{"$updated_at", 1}}) {
+		t.Errorf("Expected the stored value plus 1ms otherwise, got %v", cond[2])
+	}
+}
+
+// TestUpdateUser_RapidUpdates runs two updates back to back against a real MongoDB,
+// with the stored updated_at ahead of this clock as if another instance ran fast
+func TestUpdateUser_RapidUpdates(t *testing.T) {
+	uri := os.Getenv("MONGODB_URI")
+	if uri == "" {
+		uri = "mongodb://localhost:27017"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).SetServerSelectionTimeout(2*time.Second))
+	if err != nil {
+		t.Skipf("Skipping test due to MongoDB connection error: %v", err)
+	}
+	defer client.Disconnect(context.Background())
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Skipf("Skipping test due to MongoDB connection error: %v", err)
+	}
+
+	db := client.Database("monotonic_updated_at_test")
+	defer db.Drop(context.Background())
+
+	ahead := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	id := bson.NewObjectID()
+	if _, err := db.Collection(usersCollection).InsertOne(ctx, bson.M{"_id": id, "user_id": "alice", "updated_at": ahead}); err != nil {
+		t.Fatalf("Failed to insert user: %v", err)
+	}
+
+	service := NewUserService(db)
+	previous := ahead
+	for _, email := range []string{"first@example.com", "second@example.com"} {
+		user, err := service.UpdateUser(ctx, id.Hex(), &models.UpdateUserRequest{Email: &email}, nil)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !user.UpdatedAt.After(previous) {
+			t.Errorf("Expected updated_at to move past %v, got %v", previous, user.UpdatedAt)
+		}
+		previous = user.UpdatedAt
+	}
+}
+
+func TestWriters_MonotonicUpdatedAt(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	id := bson.NewObjectID()
+
+	for _, tc := range []struct {
+		name  string
+		write func(service *UserService) (*models.User, error)
+		field string
+	}{
+		{"SetRoles", func(service *UserService) (*models.User, error) {
+			return service.SetRoles(context.Background(), id.Hex(), []string{models.RoleUser})
+		}, "roles"},
+		{"RestoreUser", func(service *UserService) (*models.User, error) {
+			return service.RestoreUser(context.Background(), id.Hex())
+		}, "deleted_at"},
+	} {
+		mt.Run(tc.name, func(mt *mtest.T) {
+			mt.AddMockResponses(
+				mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 1}, v1bson.E{Key: "nModified", Value: 1}),
+				auditRecorded,
+				mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, v1bson.D{{Key: "_id", Value: id}}),
+			)
+
+			if _, err := tc.write(NewUserService(mt.DB)); err != nil {
+				mt.Fatalf("Expected no error, got %v", err)
+			}
+
+			update := mt.GetStartedEvent()
+			set := pipelineSet(mt, update.Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u"))
+			if _, err := set.LookupErr(tc.field); err != nil {
+				mt.Errorf("Expected %s to be written, got %v", tc.field, set)
+			}
+		})
+	}
+}
+
+// TestUpdatedAt_NeverDecreases runs an update followed by the other writers against a
+// real MongoDB, with the stored updated_at ahead of this clock as in TestUpdateUser_RapidUpdates
+func TestUpdatedAt_NeverDecreases(t *testing.T) {
+	uri := os.Getenv("MONGODB_URI")
+	if uri == "" {
+		uri = "mongodb://localhost:27017"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).SetServerSelectionTimeout(2*time.Second))
+	if err != nil {
+		t.Skipf("Skipping test due to MongoDB connection error: %v", err)
+	}
+	defer client.Disconnect(context.Background())
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Skipf("Skipping test due to MongoDB connection error: %v", err)
+	}
+
+	db := client.Database("updated_at_never_decreases_test")
+	defer db.Drop(context.Background())
+
+	ahead := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	id := bson.NewObjectID()
+	if _, err := db.Collection(usersCollection).InsertOne(ctx, bson.M{"_id": id, "user_id": "alice", "updated_at": ahead}); err != nil {
+		t.Fatalf("Failed to insert user: %v", err)
+	}
+
+	service := NewUserService(db)
+	email := "alice@example.com"
+	steps := []struct {
+		name  string
+		write func() (*models.User, error)
+	}{
+		{"UpdateUser", func() (*models.User, error) {
+			return service.UpdateUser(ctx, id.Hex(), &models.UpdateUserRequest{Email: &email}, nil)
+		}},
+		{"SetRoles", func() (*models.User, error) {
+			return service.SetRoles(ctx, id.Hex(), []string{models.RoleUser, models.RoleAdmin})
+		}},
+		{"SetStatus", func() (*models.User, error) {
+			return service.SetStatus(ctx, id.Hex(), models.StatusDisabled)
+		}},
+	}
+
+	previous := ahead
+	for _, step := range steps {
+		user, err := step.write()
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", step.name, err)
+		}
+		if !user.UpdatedAt.After(previous) {
+			t.Errorf("%s: expected updated_at to move past %v, got %v", step.name, previous, user.UpdatedAt)
+		}
+		previous = user.UpdatedAt
+	}
+}
+
+func TestUpdateUser_ProfileFields(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("sets and clears fields", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 1}, v1bson.E{Key: "nModified", Value: 1}),
+			auditRecorded,
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, v1bson.D{{Key: "_id", Value: bson.NewObjectID()}}),
+		)
+
+		firstName := "  Alice "
+		cleared := ""
+		req := &models.UpdateUserRequest{FirstName: &firstName, AvatarURL: &cleared}
+		if _, err := NewUserService(mt.DB).UpdateUser(context.Background(), bson.NewObjectID().Hex(), req, nil); err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+
+		update := mt.GetStartedEvent()
+		set := update.Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u").Array().Index(0).Value().Document().Lookup("$set").Document()
+		if name := set.Lookup("first_name", "$literal").StringValue(); name != "Alice" {
+			mt.Errorf("Expected the trimmed first_name, got %q", name)
+		}
+		if removed, _ := set.Lookup("avatar_url").StringValueOK(); removed != "$$REMOVE" {
+			mt.Errorf("Expected avatar_url to be removed, got %v", set)
+		}
+		if _, err := set.LookupErr("last_name"); err == nil {
+			mt.Errorf("Expected last_name to be left alone, got %v", set)
+		}
+	})
+}
+
+func TestWithClock(t *testing.T) {
+	now := time.Date(2024, 6, 4, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("stamps created_at and updated_at on create", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(), // commitTransaction
+		)
+
+		user, err := NewUserService(mt.DB, WithClock(clock)).CreateUser(context.Background(), &models.CreateUserRequest{
+			UserID:   "alice",
+			Email:    "alice@example.com",
+			Password: "password123",
+		})
+		if err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+		if !user.CreatedAt.Equal(now) || !user.UpdatedAt.Equal(now) {
+			mt.Errorf("Expected both timestamps to be %v, got %v and %v", now, user.CreatedAt, user.UpdatedAt)
+		}
+
+		mt.GetStartedEvent() // find by user_id
+		mt.GetStartedEvent() // find by email
+		insert := mt.GetStartedEvent()
+		stored := insert.Command.Lookup("documents").Array().Index(0).Value().Document()
+		if createdAt := stored.Lookup("created_at").Time(); !createdAt.Equal(now) {
+			mt.Errorf("Expected created_at %v to be stored, got %v", now, createdAt)
+		}
+	})
+
+	mt.Run("passes the clock to the updated_at pipeline", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(v1bson.E{Key: "n", Value: 1}, v1bson.E{Key: "nModified", Value: 1}),
+			auditRecorded,
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, v1bson.D{{Key: "_id", Value: bson.NewObjectID()}}),
+		)
+
+		firstName := "Bob"
+		_, err := NewUserService(mt.DB, WithClock(clock)).UpdateUser(context.Background(), bson.NewObjectID().Hex(), &models.UpdateUserRequest{FirstName: &firstName}, nil)
+		if err != nil {
+			mt.Fatalf("Expected no error, got %v", err)
+		}
+
+		update := mt.GetStartedEvent()
+		set := update.Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u").Array().Index(0).Value().Document().Lookup("$set").Document()
+		// $cond: [{$gt: [now, "$updated_at"]}, now, ...]
+		if candidate := set.Lookup("updated_at", "$cond").Array().Index(1).Value().Time(); !candidate.Equal(now) {
+			mt.Errorf("Expected the clock's time as the candidate updated_at, got %v", candidate)
+		}
+	})
+
+	t.Run("defaults to time.Now", func(t *testing.T) {
+		before := time.Now()
+		if got := NewUserService(&MockDatabase{}, WithClock(nil)).now(); got.Before(before) {
+			t.Errorf("Expected the current time, got %v", got)
+		}
+	})
+}
+
+func TestGetUserByIdentifier(t *testing.T) {
+	ctx := context.Background()
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("One query for both fields", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, v1bson.D{
+			{Key: "_id", Value: bson.NewObjectID()},
+			{Key: "user_id", Value: "alice"},
+			{Key: "email", Value: "alice@example.com"},
+		}))
+
+		user, err := NewUserService(mt.DB).GetUserByIdentifier(ctx, " Alice@Example.com")
+		if err != nil || user == nil || user.UserID != "alice" {
+			mt.Fatalf("Expected alice, got %v (error: %v)", user, err)
+		}
+
+		started := mt.GetStartedEvent()
+		clauses, ok := started.Command.Lookup("filter", "$or").ArrayOK()
+		if !ok {
+			mt.Fatalf("Expected an $or filter, got %v", started.Command.Lookup("filter"))
+		}
+		values, _ := clauses.Values()
+		if len(values) != 2 ||
+			values[0].Document().Lookup("user_id").StringValue() != " Alice@Example.com" ||
+			values[1].Document().Lookup("email").StringValue() != "alice@example.com" {
+			mt.Errorf("Expected user_id as given and the normalized email, got %v", clauses)
+		}
+	})
+
+	mt.Run("user_id match wins", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch,
+			v1bson.D{{Key: "_id", Value: bson.NewObjectID()}, {Key: "user_id", Value: "bob"}, {Key: "email", Value: "carol@example.com"}},
+			v1bson.D{{Key: "_id", Value: bson.NewObjectID()}, {Key: "user_id", Value: "carol@example.com"}, {Key: "email", Value: "dave@example.com"}},
+		))
+
+		user, err := NewUserService(mt.DB).GetUserByIdentifier(ctx, "carol@example.com")
+		if err != nil || user == nil || user.Email != "dave@example.com" {
+			mt.Errorf("Expected the user whose user_id matched, got %v (error: %v)", user, err)
+		}
+	})
+
+	mt.Run("No match", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch))
+
+		user, err := NewUserService(mt.DB).GetUserByIdentifier(ctx, "nobody")
+		if err != nil || user != nil {
+			mt.Errorf("Expected no user and no error, got %v (error: %v)", user, err)
+		}
+	})
+}
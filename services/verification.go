@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"go-mongodb-test/auth"
+	"go-mongodb-test/models"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// issueVerificationToken marks user as unverified and gives it a fresh token
+// to be delivered to the user's email address
+func issueVerificationToken(user *models.User) error {
+	token, err := auth.GenerateRandomToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate verification token: %w", err)
+	}
+
+	user.EmailVerified = false
+	user.VerificationToken = token
+	return nil
+}
+
+// VerifyEmail marks the user holding token as verified and consumes the token
+func (s *UserService) VerifyEmail(ctx context.Context, token string) error {
+	ctx, span := startSpan(ctx, "VerifyEmail", "updateOne")
+	defer span.End()
+	ctx, cancel := s.withOperationTimeout(ctx)
+	defer cancel()
+
+	if token == "" {
+		return ErrInvalidVerificationToken
+	}
+
+	if err := s.requireCollections(); err != nil {
+		return err
+	}
+
+	result, err := s.collection.UpdateOne(
+		ctx,
+		notDeleted(bson.M{"verification_token": token}),
+		updatePipeline(s.now(), bson.M{"email_verified": true}, "verification_token"),
+	)
+	if err != nil {
+		return spanError(span, fmt.Errorf("failed to verify email: %w", err))
+	}
+
+	// The filter is on the token, so the verified user's ID is not known here
+	s.cache.purge()
+
+	if result.MatchedCount == 0 {
+		return ErrInvalidVerificationToken
+	}
+
+	return nil
+}
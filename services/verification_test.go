@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go-mongodb-test/models"
+)
+
+func TestIssueVerificationToken(t *testing.T) {
+	user := &models.User{EmailVerified: true}
+
+	if err := issueVerificationToken(user); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if user.EmailVerified {
+		t.Error("Expected user to be marked unverified")
+	}
+	if user.VerificationToken == "" {
+		t.Error("Expected a verification token to be set")
+	}
+
+	previous := user.VerificationToken
+	if err := issueVerificationToken(user); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if user.VerificationToken == previous {
+		t.Error("Expected a fresh token on each call")
+	}
+}
+
+// TestVerifyEmail_EmptyToken tests that an empty token is rejected before any DB call
+func TestVerifyEmail_EmptyToken(t *testing.T) {
+	service := NewUserService(&MockDatabase{})
+
+	err := service.VerifyEmail(context.Background(), "")
+	if !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Errorf("Expected ErrInvalidVerificationToken, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEnabled(t *testing.T) {
+	tests := []struct {
+		name           string
+		endpoint       string
+		tracesEndpoint string
+		expected       bool
+	}{
+		{"No endpoint", "", "", false},
+		{"Generic endpoint", "http://localhost:4318", "", true},
+		{"Traces endpoint", "", "http://localhost:4318/v1/traces", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", tt.endpoint)
+			t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", tt.tracesEndpoint)
+
+			if got := Enabled(); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSetupTracing(t *testing.T) {
+	t.Run("Disabled without endpoint", func(t *testing.T) {
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+		t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+
+		shutdown, err := SetupTracing(context.Background())
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if err := shutdown(context.Background()); err != nil {
+			t.Errorf("Expected no error from shutdown, got %v", err)
+		}
+	})
+
+	t.Run("Enabled with endpoint", func(t *testing.T) {
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318")
+		t.Setenv("OTEL_SERVICE_NAME", "test-service")
+
+		shutdown, err := SetupTracing(context.Background())
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			t.Errorf("Expected no error from shutdown, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,56 @@
+// Package version reports what build of the service is running.
+//
+// The values are set at build time, for example:
+//
+//	go build -ldflags "-X go-mongodb-test/version.Version=1.2.0 \
+//		-X go-mongodb-test/version.Commit=$(git rev-parse --short HEAD) \
+//		-X go-mongodb-test/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+
+	mongoversion "go.mongodb.org/mongo-driver/version"
+)
+
+// Build metadata, overridden with -ldflags -X
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+// Info describes the running build
+type Info struct {
+	Version       string `json:"version" example:"1.2.0"`
+	Commit        string `json:"commit" example:"09ed114"`
+	BuildTime     string `json:"build_time" example:"2024-06-04T12:00:00Z"`
+	GoVersion     string `json:"go_version" example:"go1.24.0"`
+	MongoDBDriver string `json:"mongodb_driver" example:"1.17.3"`
+}
+
+// Get returns the build metadata. When Commit or BuildTime were not set with
+// -ldflags, the VCS details recorded by the Go toolchain are used if present.
+func Get() Info {
+	info := Info{
+		Version:       Version,
+		Commit:        Commit,
+		BuildTime:     BuildTime,
+		GoVersion:     runtime.Version(),
+		MongoDBDriver: mongoversion.Driver,
+	}
+
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range buildInfo.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "unknown":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildTime == "unknown":
+				info.BuildTime = setting.Value
+			}
+		}
+	}
+
+	return info
+}
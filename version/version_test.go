@@ -0,0 +1,34 @@
+package version
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	info := Get()
+
+	if info.Version != Version {
+		t.Errorf("Expected version '%s', got '%s'", Version, info.Version)
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Errorf("Expected Go version '%s', got '%s'", runtime.Version(), info.GoVersion)
+	}
+	if info.MongoDBDriver == "" {
+		t.Error("Expected MongoDB driver version to be set")
+	}
+}
+
+func TestGet_LdflagsOverride(t *testing.T) {
+	oldVersion, oldCommit, oldBuildTime := Version, Commit, BuildTime
+	t.Cleanup(func() {
+		Version, Commit, BuildTime = oldVersion, oldCommit, oldBuildTime
+	})
+
+	Version, Commit, BuildTime = "1.2.0", "abc1234", "2024-06-04T12:00:00Z"
+
+	info := Get()
+	if info.Version != "1.2.0" || info.Commit != "abc1234" || info.BuildTime != "2024-06-04T12:00:00Z" {
+		t.Errorf("Expected ldflags values to be reported, got %+v", info)
+	}
+}